@@ -9,9 +9,13 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"shared/audit"
+	sharedconfig "shared/config"
 	"shared/log"
 	"shared/messagebus"
 	"shared/metrics"
+	"shared/middleware"
+	"shared/models"
 	"shared/repository"
 	"shared/tracing"
 	"syscall"
@@ -20,12 +24,65 @@ import (
 	"github.com/nats-io/nats.go"
 )
 
+// shutdownTimeout bounds how long shutdown waits for the analysis in flight to finish
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	cfg := config.Load()
 	log := log.SetupFromEnv(cfg.Service.Name)
 
+	// The effective configuration is printed before it is checked, so the values behind the problems show
+	printConfig := sharedconfig.PrintRequested(os.Args[1:])
+	if printConfig {
+		if err := sharedconfig.Print(os.Stdout, cfg.Redacted()); err != nil {
+			log.Error("Failed to print configuration", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		sharedconfig.LogProblems(log, err)
+		os.Exit(1)
+	}
+	if printConfig {
+		return
+	}
+
 	log.Info("Starting analyzer service", slog.String("version", cfg.Service.Version))
 
+	exclusions, err := analyzer.CompileExclusionPatterns(cfg.Analysis.LinkExcludePatterns)
+	if err != nil {
+		log.Error("Failed to compile link exclusion patterns", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	trackers, err := analyzer.LoadTrackerSignatures(cfg.Analysis.TrackerSignaturesFile)
+	if err != nil {
+		log.Error("Failed to load tracker signatures", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	subTaskEvents, err := analyzer.ParseSubTaskEventGranularity(cfg.Events.SubTaskGranularity)
+	if err != nil {
+		log.Error("Failed to parse subtask event granularity", slog.Any("error", err))
+		os.Exit(1)
+	}
+	log.Info("Subtask event granularity configured", slog.String("granularity", string(subTaskEvents)))
+
+	verifyScope, err := models.ParseLinkScope(cfg.Analysis.VerifyScope)
+	if err != nil {
+		log.Error("Failed to parse link verify scope", slog.String("scope", cfg.Analysis.VerifyScope), slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	headerProfile, err := models.ParseHeaderProfile(cfg.Analysis.VerifyHeaderProfile)
+	if err != nil {
+		log.Error("Failed to parse link verify header profile", slog.String("profile", cfg.Analysis.VerifyHeaderProfile), slog.Any("error", err))
+		os.Exit(1)
+	}
+	if headerProfile != models.HeaderProfileDefault {
+		log.Info("Links are verified with browser headers by default", slog.String("profile", string(headerProfile)))
+	}
+
 	ctx := context.Background()
 	shutdown, err := tracing.SetupOTelSDK(ctx, cfg.Tracing)
 	if err != nil {
@@ -34,6 +91,14 @@ func main() {
 	}
 	defer shutdown(ctx)
 
+	// Audit records go to their own sink, apart from the service logs
+	auditLog, err := audit.Open(cfg.Audit.Path, cfg.Service.Name)
+	if err != nil {
+		log.Error("Failed to open audit log", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer auditLog.Close()
+
 	jobRepo, taskRepo, publisher, client, metrics, cleanup, err := initializeDependencies(cfg)
 	if err != nil {
 		log.Error("Failed to initialize dependencies", slog.Any("error", err))
@@ -41,6 +106,15 @@ func main() {
 	}
 	defer cleanup()
 
+	// One limiter for the process, so concurrent jobs share each host's budget
+	var hostLimiter *analyzer.HostRateLimiter
+	if cfg.HostRate.RequestsPerSecond > 0 {
+		hostLimiter = analyzer.NewHostRateLimiter(cfg.HostRate.RequestsPerSecond, cfg.HostRate.Burst)
+		log.Info("Host rate limit configured",
+			slog.Float64("requestsPerSecond", cfg.HostRate.RequestsPerSecond),
+			slog.Int("burst", cfg.HostRate.Burst))
+	}
+
 	anlyzr := analyzer.NewAnalyzer(
 		jobRepo,
 		taskRepo,
@@ -48,7 +122,15 @@ func main() {
 		analyzer.WithHTTPClient(client),
 		analyzer.WithMetrics(metrics),
 		analyzer.WithLogger(log),
+		analyzer.WithAuditLogger(auditLog),
 		analyzer.WithConfig(cfg),
+		analyzer.WithExclusionPatterns(exclusions),
+		analyzer.WithTrackerSignatures(trackers),
+		analyzer.WithSubTaskEventGranularity(subTaskEvents),
+		analyzer.WithHostRateLimiter(hostLimiter),
+		analyzer.WithVerifyScope(verifyScope),
+		analyzer.WithHeaderProfile(headerProfile),
+		analyzer.WithBroadcastLinks(cfg.Events.BroadcastLinks),
 	)
 
 	sub, err := publisher.SubscribeToAnalyzeMessage(anlyzr.ProcessAnalyzeMessage)
@@ -56,11 +138,29 @@ func main() {
 		log.Error("Failed to subscribe to analyze message", slog.Any("error", err))
 		os.Exit(1)
 	}
-	defer sub.Unsubscribe()
+
+	// Report the backlog so the API can tell users how long their jobs will wait
+	stopLoadReports := func() {}
+	if cfg.Events.LoadInterval > 0 {
+		stopLoadReports = anlyzr.StartLoadReports(sub, cfg.Events.LoadInterval)
+	}
 
 	log.Info("Analyzer service is running")
 
 	waitForShutdown(log)
+	stopLoadReports()
+
+	// Stop taking jobs and let the one in flight publish its final updates,
+	// the deferred cleanup then flushes and closes the NATS connection
+	if err := sub.Unsubscribe(); err != nil {
+		log.Error("Failed to unsubscribe from analyze message", slog.Any("error", err))
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := anlyzr.Wait(drainCtx); err != nil {
+		log.Warn("Analysis still in flight at shutdown, its final updates may be lost", slog.Any("error", err))
+	}
 }
 
 // initializeDependencies initializes individual dependencies
@@ -79,14 +179,24 @@ func initializeDependencies(cfg *config.Config) (
 	m.SetServiceInfo(cfg.Service.Version, runtime.Version())
 
 	// Start metrics server
-	srv := m.StartMetricsServer(cfg.Metrics.Port)
+	srv := m.StartMetricsServer(cfg.Metrics.Port, metrics.Route{
+		Method:  http.MethodGet,
+		Path:    "/debug/config",
+		Handler: middleware.AdminAuthMiddleware(cfg.Admin.Token)(middleware.ConfigHandler(cfg.Redacted())),
+	}, metrics.Route{
+		Method:  http.MethodPost,
+		Path:    "/admin/loglevel",
+		Handler: middleware.AdminAuthMiddleware(cfg.Admin.Token)(middleware.LogLevelHandler(slog.Default())),
+	})
 
 	// Initialize database
 	ddc, err := repository.NewDynamoDBClient(cfg.DynamoDB)
 	if err != nil {
 		return nil, nil, nil, nil, nil, nil, err
 	}
-	repository.SeedTables(ddc, cfg.DynamoDB, m)
+	if err := repository.SeedTables(ddc, cfg.DynamoDB, m); err != nil {
+		return nil, nil, nil, nil, nil, nil, err
+	}
 
 	jobs, err := repository.NewJobRepository(cfg.DynamoDB, repository.WithJobMetrics(m))
 	if err != nil {
@@ -99,12 +209,23 @@ func initializeDependencies(cfg *config.Config) (
 	}
 
 	// Initialize HTTP client with tracing
-	tr := http.DefaultTransport
-	tr = tracing.HTTPClientMiddleware()(tr)
+	tlsConfig, err := cfg.HTTP.TLSConfig()
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if cfg.DNS.Enabled {
+		// Link verification requests the same few hosts over and over, resolve each once per TTL
+		dns := analyzer.NewDNSCache(cfg.DNS.TTL, cfg.DNS.NegativeTTL, analyzer.WithDNSMetrics(m))
+		transport.DialContext = dns.DialContext
+	}
+	tr := tracing.HTTPClientMiddleware()(transport)
 
 	client := &http.Client{
-		Timeout:   cfg.HTTP.Timeout,
-		Transport: tr,
+		Timeout:       cfg.HTTP.Timeout,
+		Transport:     tr,
+		CheckRedirect: analyzer.CheckRedirect,
 	}
 
 	// Initialize NATS connection
@@ -113,9 +234,12 @@ func initializeDependencies(cfg *config.Config) (
 		return nil, nil, nil, nil, nil, nil, err
 	}
 
-	bus := messagebus.New(nc, m)
+	bus := messagebus.New(nc, m, messagebus.WithSlowHandlerThreshold(cfg.NATS.SlowHandlerThreshold))
 
 	cleanup := func() {
+		// Flushing waits for the server to acknowledge everything published so far,
+		// Close alone can drop a final job update still sitting in the write buffer
+		nc.FlushTimeout(5 * time.Second)
 		nc.Close()
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
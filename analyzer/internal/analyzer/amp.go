@@ -0,0 +1,75 @@
+package analyzer
+
+import (
+	"fmt"
+	"shared/models"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ampRuntimeURLs are the sources of the AMP runtime script an AMP page must load, the latest and the
+// long-term stable release
+var ampRuntimeURLs = []string{
+	"https://cdn.ampproject.org/v0.js",
+	"https://cdn.ampproject.org/lts/v0.js",
+}
+
+// ampSignals records the AMP markers and requirements met while traversing a page
+type ampSignals struct {
+	// marked is set when the <html> element carries the ⚡ or amp attribute
+	marked      bool
+	boilerplate bool
+	runtime     bool
+}
+
+// recordRoot records whether the <html> element marks the page as AMP
+func (a *ampSignals) recordRoot(n *html.Node) {
+	if hasAttribute(n, "⚡") || hasAttribute(n, "amp") {
+		a.marked = true
+	}
+}
+
+// recordStyle records the AMP boilerplate style, <style amp-boilerplate>
+func (a *ampSignals) recordStyle(n *html.Node) {
+	if hasAttribute(n, "amp-boilerplate") {
+		a.boilerplate = true
+	}
+}
+
+// recordScript records the AMP runtime script
+func (a *ampSignals) recordScript(src string) {
+	src = strings.TrimSpace(src)
+	for _, runtime := range ampRuntimeURLs {
+		if strings.EqualFold(src, runtime) {
+			a.runtime = true
+			return
+		}
+	}
+}
+
+// checkAMP flags what an AMP page is missing of the canonical link, the boilerplate style and the runtime script.
+// Without them the AMP cache rejects the page. Pages that are not marked as AMP are left alone.
+func (s *Analyzer) checkAMP(result *AnalysisResult) {
+	signals := result.amp
+	if !signals.marked {
+		return
+	}
+	result.isAMP = true
+
+	missing := func(message string) {
+		result.warnings = append(result.warnings, models.Warning{
+			Code:    models.WarningAMPRequirementMissing,
+			Message: fmt.Sprintf("The AMP page has no %s", message),
+		})
+	}
+	if result.canonical == "" {
+		missing(`<link rel="canonical">, which must name the page itself or its non-AMP version`)
+	}
+	if !signals.boilerplate {
+		missing("<style amp-boilerplate>")
+	}
+	if !signals.runtime {
+		missing("AMP runtime script, " + ampRuntimeURLs[0])
+	}
+}
@@ -0,0 +1,77 @@
+package analyzer
+
+import (
+	"log/slog"
+	"shared/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestAnalyzer_CheckAMP(t *testing.T) {
+	const requirements = `<link rel="canonical" href="/article"><style amp-boilerplate>body{visibility:hidden}</style>` +
+		`<script async src="https://cdn.ampproject.org/v0.js"></script>`
+
+	testCases := []struct {
+		name            string
+		content         string
+		expectedAMP     bool
+		expectedMissing []string
+	}{
+		{
+			name:        "Lightning",
+			content:     `<!doctype html><html ⚡ lang="en"><head>` + requirements + `</head><body></body></html>`,
+			expectedAMP: true,
+		},
+		{
+			name:        "AmpAttribute",
+			content:     `<html AMP><head>` + requirements + `</head><body></body></html>`,
+			expectedAMP: true,
+		},
+		{
+			name: "LTSRuntime",
+			content: `<html amp><head><link rel="canonical" href="/article"><style amp-boilerplate></style>` +
+				`<script async src="https://cdn.ampproject.org/lts/v0.js"></script></head><body></body></html>`,
+			expectedAMP: true,
+		},
+		{
+			name:            "MissingEverything",
+			content:         `<html ⚡><head><title>Article</title></head><body></body></html>`,
+			expectedAMP:     true,
+			expectedMissing: []string{"canonical", "amp-boilerplate", "runtime"},
+		},
+		{
+			name: "MissingBoilerplate",
+			content: `<html amp><head><link rel="canonical" href="/article"><style>body{}</style>` +
+				`<script async src="https://cdn.ampproject.org/v0.js"></script></head><body></body></html>`,
+			expectedAMP:     true,
+			expectedMissing: []string{"amp-boilerplate"},
+		},
+		// Requirements met by a page that is not marked as AMP do not make it one
+		{name: "NotAMP", content: `<html lang="en"><head>` + requirements + `</head><body></body></html>`},
+		{name: "AmpOnAnotherElement", content: `<html><head></head><body><div amp></div></body></html>`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+			doc, err := html.Parse(strings.NewReader(tc.content))
+			require.NoError(t, err)
+
+			result := &AnalysisResult{baseURL: "https://example.com", headings: make(map[string]int)}
+			s.traverseNode(doc, result)
+			s.checkAMP(result)
+
+			built := result.snapshot()
+			assert.Equal(t, tc.expectedAMP, built.IsAMP)
+			require.Len(t, built.Warnings, len(tc.expectedMissing))
+			for i, missing := range tc.expectedMissing {
+				assert.Equal(t, models.WarningAMPRequirementMissing, built.Warnings[i].Code)
+				assert.Contains(t, built.Warnings[i].Message, missing)
+			}
+		})
+	}
+}
@@ -3,28 +3,85 @@ package analyzer
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"net/url"
 	"shared/models"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/html"
 )
 
-// analyzeHTML performs complete HTML analysis
-func (s *Analyzer) analyzeHTML(ctx context.Context, jobID, content string, result *AnalysisResult) error {
+// analyzeHTML performs the HTML analysis phases selected for the job, the others are marked skipped.
+// Only a page that cannot be parsed returns an error. A phase failing after that is recorded on the result,
+// and the phases that do not depend on it still run.
+func (s *Analyzer) analyzeHTML(ctx context.Context, job *models.Job, content string, result *AnalysisResult) error {
+	jobID := job.ID
 	doc, err := s.parseHTML(ctx, jobID, content)
 	if err != nil {
 		return fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
-	s.detectHTMLVersion(ctx, jobID, content, result)
-	s.analyzeContent(ctx, jobID, doc, result)
-	s.verifyLinks(ctx, jobID, result)
+	if job.RunsTask(models.TaskTypeIdentifyingVersion) {
+		if err := s.detectHTMLVersion(ctx, jobID, content, result); err != nil {
+			s.recordTaskFailure(jobID, models.TaskTypeIdentifyingVersion, err, result)
+		}
+	}
+	analyzed := true
+	if job.RunsTask(models.TaskTypeAnalyzing) {
+		if err := s.analyzeContent(ctx, jobID, doc, result); err != nil {
+			s.recordTaskFailure(jobID, models.TaskTypeAnalyzing, err, result)
+			analyzed = false
+		}
+	}
+	s.persistPartialResult(ctx, job, result)
+
+	if job.RunsTask(models.TaskTypeVerifyingLinks) {
+		// The links of a page whose content analysis failed are incomplete, so none are verified
+		if !analyzed {
+			s.updateTaskStatus(ctx, jobID, models.TaskTypeVerifyingLinks, models.TaskStatusSkipped)
+		} else if err := s.verifyLinks(ctx, jobID, result); err != nil {
+			s.recordTaskFailure(jobID, models.TaskTypeVerifyingLinks, err, result)
+		}
+	}
+
+	for _, taskType := range result.skippedTasks {
+		s.updateTaskStatus(ctx, jobID, taskType, models.TaskStatusSkipped)
+	}
 
 	return nil
 }
 
+// recordTaskFailure records a phase that failed while the analysis went on.
+// The task is listed on the result with a warning, so the job can complete with what the other phases found.
+func (s *Analyzer) recordTaskFailure(jobID string, taskType models.TaskType, err error, result *AnalysisResult) {
+	s.log.Warn("Analysis task failed, continuing with the other tasks",
+		slog.String("jobId", jobID),
+		slog.String("taskType", string(taskType)),
+		slog.Any("error", err))
+
+	result.failedTasks = append(result.failedTasks, taskType)
+	result.warnings = append(result.warnings, models.Warning{
+		Code:    models.WarningTaskFailed,
+		Message: fmt.Sprintf("The %s task failed, its part of the result is missing or incomplete: %v", taskType, err),
+	})
+}
+
+// persistPartialResult stores the result gathered before link verification,
+// so the job keeps its title, headings and version if a later phase fails.
+// The status is left alone so the job's status history records no second running entry.
+func (s *Analyzer) persistPartialResult(ctx context.Context, job *models.Job, result *AnalysisResult) {
+	partial := result.snapshot()
+	partial.PartialResult = true
+
+	err := s.jobRepo.UpdateJob(ctx, job.ID, nil, &partial, attemptGuard(job)...)
+	if err != nil && !s.isStaleWrite(job, staleWritePartial, err) {
+		s.log.Warn("Failed to persist partial result",
+			slog.String("jobId", job.ID),
+			slog.Any("error", err))
+	}
+}
+
 // parseHTML parses HTML content and tracks the parsing task
 func (s *Analyzer) parseHTML(ctx context.Context, jobID, content string) (*html.Node, error) {
 	start := time.Now()
@@ -44,18 +101,32 @@ func (s *Analyzer) parseHTML(ctx context.Context, jobID, content string) (*html.
 	return doc, nil
 }
 
-// detectHTMLVersion identifies the HTML version from the document
-func (s *Analyzer) detectHTMLVersion(ctx context.Context, jobID, content string, result *AnalysisResult) {
+// detectHTMLVersion identifies the HTML version from the document.
+// A panic is recovered and reported as an error.
+func (s *Analyzer) detectHTMLVersion(ctx context.Context, jobID, content string, result *AnalysisResult) (err error) {
 	start := time.Now()
 	s.updateTaskStatus(ctx, jobID, models.TaskTypeIdentifyingVersion, models.TaskStatusRunning)
 
 	defer func() {
-		s.updateTaskStatus(ctx, jobID, models.TaskTypeIdentifyingVersion, models.TaskStatusCompleted)
-		s.metrics.RecordAnalysisTask(string(models.TaskTypeIdentifyingVersion), true, time.Since(start).Seconds())
+		if r := recover(); r != nil {
+			err = fmt.Errorf("version detection panicked: %v", r)
+		}
+		s.finishTask(ctx, jobID, models.TaskTypeIdentifyingVersion, start, err)
 	}()
 
 	content = strings.ToLower(content)
 	result.htmlVersion = s.parseHTMLVersion(content)
+	return nil
+}
+
+// finishTask marks a task completed, or failed when it returned an error, and records its duration
+func (s *Analyzer) finishTask(ctx context.Context, jobID string, taskType models.TaskType, start time.Time, err error) {
+	status := models.TaskStatusCompleted
+	if err != nil {
+		status = models.TaskStatusFailed
+	}
+	s.updateTaskStatus(ctx, jobID, taskType, status)
+	s.metrics.RecordAnalysisTask(string(taskType), err == nil, time.Since(start).Seconds())
 }
 
 // parseHTMLVersion parses HTML version from DOCTYPE declaration
@@ -94,41 +165,76 @@ func (s *Analyzer) parseHTMLVersion(content string) string {
 	return "No DOCTYPE or Unrecognized"
 }
 
-// analyzeContent performs content analysis using DFS traversal
-func (s *Analyzer) analyzeContent(ctx context.Context, jobID string, doc *html.Node, result *AnalysisResult) {
+// analyzeContent performs content analysis using DFS traversal.
+// A panic is recovered and reported as an error.
+func (s *Analyzer) analyzeContent(ctx context.Context, jobID string, doc *html.Node, result *AnalysisResult) (err error) {
 	start := time.Now()
 	s.updateTaskStatus(ctx, jobID, models.TaskTypeAnalyzing, models.TaskStatusRunning)
 
 	defer func() {
-		s.updateTaskStatus(ctx, jobID, models.TaskTypeAnalyzing, models.TaskStatusCompleted)
-		s.metrics.RecordAnalysisTask(string(models.TaskTypeAnalyzing), true, time.Since(start).Seconds())
+		if r := recover(); r != nil {
+			err = fmt.Errorf("content analysis panicked: %v", r)
+		}
+		s.finishTask(ctx, jobID, models.TaskTypeAnalyzing, start, err)
 	}()
 
 	s.traverseNode(doc, result)
+	s.followPagination(ctx, jobID, result)
+	s.findBrokenAnchors(result)
+	s.analyzeLinkStructure(result)
+	s.detectClientSideRendering(result)
+	s.checkAMP(result)
+	return nil
 }
 
-// traverseNode performs depth-first traversal of HTML nodes
+// traverseNode visits the elements under n in document order
 func (s *Analyzer) traverseNode(n *html.Node, result *AnalysisResult) {
-	if n.Type == html.ElementNode {
-		s.processElement(n, result)
-	}
-
-	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		s.traverseNode(c, result)
-	}
+	walkNodes(n, func(node *html.Node) bool {
+		switch node.Type {
+		case html.ElementNode:
+			s.processElement(node, result)
+		case html.TextNode:
+			result.rendering.recordText(node)
+		}
+		return true
+	})
 }
 
 // processElement processes different HTML elements
 func (s *Analyzer) processElement(n *html.Node, result *AnalysisResult) {
+	result.rendering.recordMountPoint(n)
+	s.recordAnchorTarget(n, result)
+
 	switch n.Data {
+	case "html":
+		result.amp.recordRoot(n)
 	case "title":
 		s.extractTitle(n, result)
 	case "h1", "h2", "h3", "h4", "h5", "h6":
 		s.extractHeading(n, result)
 	case "a":
 		s.extractLink(n, result)
+		s.extractPagination(n, result)
+	case "img":
+		s.extractImage(n, result)
 	case "form":
 		s.checkLoginForm(n, result)
+		s.checkFormSecurity(n, result)
+	case "base":
+		s.extractDocumentBase(n, result)
+	case "link":
+		s.extractCanonical(n, result)
+		s.extractPagination(n, result)
+	case "meta":
+		s.extractOpenGraphURL(n, result)
+		s.extractRobots(n, result)
+	case "style":
+		result.amp.recordStyle(n)
+	case "script":
+		src := s.getElementAttribute(n, "src")
+		result.rendering.recordScript(n, src)
+		result.amp.recordScript(src)
+		s.detectTrackers(n, src, result)
 	}
 }
 
@@ -147,24 +253,79 @@ func (s *Analyzer) extractHeading(n *html.Node, result *AnalysisResult) {
 // extractLink processes anchor elements
 func (s *Analyzer) extractLink(n *html.Node, result *AnalysisResult) {
 	href := s.getElementAttribute(n, "href")
+	s.recordFragmentLink(href, result)
 	if href == "" || !s.shouldProcessLink(href) {
 		return
 	}
 
-	resolvedURL := s.resolveURL(href, result.baseURL)
+	resolvedURL := s.resolveURL(href, result.resolutionBase())
 	if resolvedURL == "" {
 		return
 	}
+	resolvedURL, kept := s.collapseLink(resolvedURL, result)
+	if !kept {
+		return
+	}
 
 	result.links = append(result.links, resolvedURL)
 
-	if s.isExternalURL(resolvedURL, result.baseURL) {
-		atomic.AddInt32(&result.externalLinks, 1)
+	external, reason := s.classifyLink(resolvedURL, result.baseURL)
+	if external {
+		result.counts.inc(countExternalLinks)
 	} else {
-		atomic.AddInt32(&result.internalLinks, 1)
+		result.counts.inc(countInternalLinks)
+	}
+	if s.explainLinks() {
+		result.linkClassifications = append(result.linkClassifications, models.LinkClassification{
+			URL:      resolvedURL,
+			External: external,
+			Reason:   reason,
+		})
 	}
 }
 
+// extractDocumentBase records the href of the page's first <base> with one, which per the HTML spec replaces
+// the page URL when resolving relative links. An href that cannot be resolved leaves the page URL in use.
+func (s *Analyzer) extractDocumentBase(n *html.Node, result *AnalysisResult) {
+	if result.documentBaseSeen || !hasAttribute(n, "href") {
+		return
+	}
+	result.documentBaseSeen = true
+
+	href := strings.TrimSpace(s.getElementAttribute(n, "href"))
+	base := s.resolveURL(href, result.baseURL)
+	if parsed, err := url.Parse(base); err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") {
+		result.documentBase = base
+	}
+}
+
+// resolutionBase returns the URL relative links are resolved against, the page's <base> when it has one
+func (r *AnalysisResult) resolutionBase() string {
+	if r.documentBase != "" {
+		return r.documentBase
+	}
+	return r.baseURL
+}
+
+// extractImage collects the image source, each distinct image is kept once
+func (s *Analyzer) extractImage(n *html.Node, result *AnalysisResult) {
+	src := s.getElementAttribute(n, "src")
+	if src == "" || !s.shouldProcessLink(src) {
+		return
+	}
+
+	resolvedURL := s.resolveURL(src, result.resolutionBase())
+	if resolvedURL == "" || result.seenImages[resolvedURL] {
+		return
+	}
+
+	if result.seenImages == nil {
+		result.seenImages = make(map[string]bool)
+	}
+	result.seenImages[resolvedURL] = true
+	result.images = append(result.images, resolvedURL)
+}
+
 // checkLoginForm checks if a form is a login form
 func (s *Analyzer) checkLoginForm(n *html.Node, result *AnalysisResult) {
 	if s.isLoginForm(n) {
@@ -172,17 +333,72 @@ func (s *Analyzer) checkLoginForm(n *html.Node, result *AnalysisResult) {
 	}
 }
 
-// buildResult builds and returns the analysis result
-func (s *Analyzer) buildResult(result *AnalysisResult) models.AnalyzeResult {
-	return models.AnalyzeResult{
-		HtmlVersion:       result.htmlVersion,
-		PageTitle:         result.title,
-		Headings:          result.headings,
-		Links:             result.links,
-		InternalLinkCount: int(atomic.LoadInt32(&result.internalLinks)),
-		ExternalLinkCount: int(atomic.LoadInt32(&result.externalLinks)),
-		AccessibleLinks:   int(atomic.LoadInt32(&result.accessibleLinks)),
-		InaccessibleLinks: int(atomic.LoadInt32(&result.inaccessibleLinks)),
-		HasLoginForm:      result.hasLoginForm,
+// snapshot builds the analysis result from what has been gathered so far.
+// It is safe to call while links are being verified, the counts and the verification findings are read through
+// their guards and the other fields are no longer written by then.
+func (r *AnalysisResult) snapshot() models.AnalyzeResult {
+	built := models.AnalyzeResult{
+		HtmlVersion:       r.htmlVersion,
+		PageTitle:         r.title,
+		Headings:          r.headings,
+		Links:             r.links,
+		InternalLinkCount: r.counts.get(countInternalLinks),
+		ExternalLinkCount: r.counts.get(countExternalLinks),
+		AccessibleLinks:   r.counts.get(countAccessibleLinks),
+		InaccessibleLinks: r.counts.get(countInaccessibleLinks),
+		HasLoginForm:      r.hasLoginForm,
+		InsecureForms:     r.insecureForms,
+		ExcludedLinks:     r.counts.get(countExcludedLinks),
+		OutOfScopeLinks:   r.counts.get(countOutOfScopeLinks),
+		UnverifiedLinks:   r.counts.get(countUnverifiedLinks),
+
+		RedundantRedirectLinks: r.redundantRedirects,
+		RedundantRedirectCount: len(r.redundantRedirects),
+		BrokenAnchors:          r.brokenAnchors,
+		BrokenAnchorCount:      len(r.brokenAnchors),
+		AssetLinks:             r.assetCounts(),
+		LinkClassifications:    r.linkClassifications,
+		OriginalLinks:          r.originalLinks,
+		CollapsedLinks:         r.collapsedLinks,
+		TrackersDetected:       r.trackers,
+		TrackerCount:           len(r.trackers),
+		CanonicalURL:           r.canonical,
+		OpenGraphURL:           r.openGraphURL,
+		IsAMP:                  r.isAMP,
+		Pagination:             r.buildPagination(),
+		NoIndex:                r.noIndex,
+		NoFollow:               r.noFollow,
+
+		ImageCount:         len(r.images),
+		AccessibleImages:   r.counts.get(countAccessibleImages),
+		InaccessibleImages: r.counts.get(countInaccessibleImages),
+		UnverifiedImages:   r.counts.get(countUnverifiedImages),
+
+		InternalLinkDepthHistogram: r.linkDepthHistogram,
+		MaxInternalLinkDepth:       r.maxLinkDepth,
+		NavOnlyPage:                r.navOnly,
+
+		LikelyClientSideRendered: r.clientSideRendered,
+		Warnings:                 r.warnings,
+
+		// Counts are incomplete when a phase failed, and stay so once the job completes
+		PartialResult: len(r.failedTasks) > 0,
+		SkippedTasks:  r.skippedTasks,
+		FailedTasks:   r.failedTasks,
+	}
+
+	// The counts of a sampled verification cover only the sample, the estimates extrapolate them to every link
+	if r.sampler != nil {
+		accessible, inaccessible := r.sampler.estimate()
+		built.SampledVerification = true
+		built.SampleFraction = r.sampler.fraction
+		built.EstimatedAccessibleLinks = built.AccessibleLinks + accessible
+		built.EstimatedInaccessibleLinks = built.InaccessibleLinks + inaccessible
+		built.PartialResult = true
 	}
+
+	// Only the lists are cut, the counts above were taken from every link
+	built.LinksTruncated = truncateLinks(&built, r.maxStoredLinks)
+
+	return built
 }
@@ -4,9 +4,13 @@ import (
 	"analyzer/internal/config"
 	"log/slog"
 	"net/http"
+	"regexp"
+	"shared/audit"
 	"shared/messagebus"
 	"shared/metrics"
+	"shared/models"
 	"shared/repository"
+	"sync"
 	"time"
 )
 
@@ -18,27 +22,109 @@ type Analyzer struct {
 	client    *http.Client
 	metrics   metrics.AnalyzerMetricsInterface
 	log       *slog.Logger
+	audit     *audit.Logger
 	cfg       *config.Config
+
+	exclusions     []*regexp.Regexp
+	trackers       []TrackerSignature
+	subTaskEvents  SubTaskEventGranularity
+	hostLimiter    *HostRateLimiter
+	verifyScope    models.LinkScope
+	headerProfile  models.HeaderProfile
+	broadcastLinks bool
+	// samplingSeed makes the links sampled under the time budget deterministic when set
+	samplingSeed *uint64
+
+	// inFlight tracks the analyze messages being processed, so shutdown can wait for them
+	inFlight sync.WaitGroup
+	// progress holds the *jobProgress of every job being analyzed, keyed by job ID
+	progress sync.Map
+	// load tracks the jobs in flight and how long they wait and run, for the load reports
+	load loadTracker
 }
 
-// AnalysisResult holds the internal analysis results
+// AnalysisResult holds the internal analysis results.
+// Its counts and the redirects and assets found while verifying links are updated by the verification workers
+// while progress is published, so they are only reached through the methods in result.go. The other fields are
+// written while the page is traversed, before the workers start, and are not written again until they are done.
 type AnalysisResult struct {
-	htmlVersion       string
-	title             string
-	headings          map[string]int
-	links             []string
-	internalLinks     int32
-	externalLinks     int32
-	accessibleLinks   int32
-	inaccessibleLinks int32
-	hasLoginForm      bool
-	baseURL           string
+	htmlVersion   string
+	title         string
+	headings      map[string]int
+	links         []string
+	counts        resultCounts
+	hasLoginForm  bool
+	insecureForms []models.InsecureForm
+	baseURL       string
+	verifyScope   models.LinkScope
+	headerProfile models.HeaderProfile
+	// verifiedMu guards redirectTargets, mapping each verified link that was redirected to the URL it ended up at,
+	// and assetLinks, counting the accessible links to downloadable assets by media type
+	verifiedMu         sync.Mutex
+	redirectTargets    map[string]string
+	assetLinks         map[string]int
+	redundantRedirects []models.LinkRedirect
+
+	// fragmentLinks are the fragments of the links to the page itself, anchorTargets the IDs they can point to
+	fragmentLinks []string
+	anchorTargets map[string]bool
+	brokenAnchors []string
+	// linkClassifications explains the classification of each link, only kept when links are explained
+	linkClassifications []models.LinkClassification
+	// originalLinks are the links as written, in the order of links, seenLinks the links kept and collapsedLinks
+	// the number of others dropped for being the same once stripped. They are only kept when query params are stripped.
+	originalLinks  []string
+	seenLinks      map[string]bool
+	collapsedLinks int
+	// canonical and openGraphURL are the page's canonical link and og:url, resolved once the page URL is known
+	canonical    string
+	openGraphURL string
+	// documentBase is the href of the page's first <base>, resolved against baseURL. Relative links resolve
+	// against it in place of baseURL, while links are still classified against baseURL.
+	documentBase     string
+	documentBaseSeen bool
+	// trackers are the names of the third-party trackers found in the page's scripts, in order of appearance
+	trackers     []string
+	seenTrackers map[string]bool
+
+	skippedTasks []models.TaskType
+	// failedTasks are the tasks that failed while the analysis went on
+	failedTasks []models.TaskType
+
+	images     []string
+	seenImages map[string]bool
+
+	linkDepthHistogram map[string]int
+	maxLinkDepth       int
+	navOnly            bool
+
+	// pageBytes is the size of the analyzed markup
+	pageBytes          int
+	rendering          renderingSignals
+	clientSideRendered bool
+	// amp is what the page has of the AMP marker and requirements, isAMP is set once it is found marked
+	amp      ampSignals
+	isAMP    bool
+	warnings []models.Warning
+	// pagination is the rel="next" chain of the page, followed when configured
+	pagination paginationSignals
+	// noIndex and noFollow are the robots directives of the page's markup, its X-Robots-Tag is added to the result
+	noIndex  bool
+	noFollow bool
+
+	// budgetStart is when the page fetch started, the time budget runs from there.
+	// sampler is set once the budget ran short and only a sample of the links left is verified.
+	budgetStart time.Time
+	sampler     *linkSampler
+
+	// maxStoredLinks caps each link list of the result built, zero for no cap
+	maxStoredLinks int
 }
 
 // Option configures the Analyzer
 type Option func(*Analyzer)
 
-// WithHTTPClient sets a custom HTTP client
+// WithHTTPClient sets a custom HTTP client, its CheckRedirect should be CheckRedirect for the redirect limits to apply
 func WithHTTPClient(client *http.Client) Option {
 	return func(s *Analyzer) {
 		s.client = client
@@ -59,6 +145,13 @@ func WithLogger(log *slog.Logger) Option {
 	}
 }
 
+// WithAuditLogger sets the logger for the audit records of job status changes, none are written by default
+func WithAuditLogger(auditLog *audit.Logger) Option {
+	return func(s *Analyzer) {
+		s.audit = auditLog
+	}
+}
+
 // WithConfig sets the configuration
 func WithConfig(cfg *config.Config) Option {
 	return func(s *Analyzer) {
@@ -66,6 +159,66 @@ func WithConfig(cfg *config.Config) Option {
 	}
 }
 
+// WithExclusionPatterns sets the compiled patterns of links to exclude from verification
+func WithExclusionPatterns(patterns []*regexp.Regexp) Option {
+	return func(s *Analyzer) {
+		s.exclusions = patterns
+	}
+}
+
+// WithTrackerSignatures sets the signatures of the third-party trackers to detect, see LoadTrackerSignatures
+func WithTrackerSignatures(signatures []TrackerSignature) Option {
+	return func(s *Analyzer) {
+		s.trackers = signatures
+	}
+}
+
+// WithSubTaskEventGranularity sets which subtask updates are published, defaults to SubTaskEventsFull
+func WithSubTaskEventGranularity(granularity SubTaskEventGranularity) Option {
+	return func(s *Analyzer) {
+		s.subTaskEvents = granularity
+	}
+}
+
+// WithBroadcastLinks sets whether the completed job update carries the link lists of the result, defaults to true.
+// Without them clients get the counts and read the links from the job.
+func WithBroadcastLinks(include bool) Option {
+	return func(s *Analyzer) {
+		s.broadcastLinks = include
+	}
+}
+
+// WithVerifyScope sets the links verified for jobs that do not choose a scope, defaults to models.LinkScopeAll
+func WithVerifyScope(scope models.LinkScope) Option {
+	return func(s *Analyzer) {
+		s.verifyScope = scope
+	}
+}
+
+// WithHeaderProfile sets the request headers links are verified with for jobs that do not choose a profile,
+// defaults to models.HeaderProfileDefault
+func WithHeaderProfile(profile models.HeaderProfile) Option {
+	return func(s *Analyzer) {
+		s.headerProfile = profile
+	}
+}
+
+// WithHostRateLimiter sets the rate limiter applied to every outbound request by target host, none by default.
+// The limiter should be shared by everything requesting pages from this process.
+func WithHostRateLimiter(limiter *HostRateLimiter) Option {
+	return func(s *Analyzer) {
+		s.hostLimiter = limiter
+	}
+}
+
+// WithSamplingSeed seeds the choice of the links verified once the time budget runs short, making it repeatable.
+// The choice is random by default.
+func WithSamplingSeed(seed uint64) Option {
+	return func(s *Analyzer) {
+		s.samplingSeed = &seed
+	}
+}
+
 // NewAnalyzer creates a new analyzer with required dependencies and optional configurations
 func NewAnalyzer(
 	jobRepo repository.JobRepositoryInterface,
@@ -77,9 +230,15 @@ func NewAnalyzer(
 		jobRepo:   jobRepo,
 		taskRepo:  taskRepo,
 		publisher: publisher,
-		client:    &http.Client{Timeout: 20 * time.Second},
+		client:    &http.Client{Timeout: 20 * time.Second, CheckRedirect: CheckRedirect},
 		metrics:   metrics.NewNoOpAnalyzerMetrics(),
 		log:       slog.Default(),
+
+		subTaskEvents:  SubTaskEventsFull,
+		verifyScope:    models.LinkScopeAll,
+		headerProfile:  models.HeaderProfileDefault,
+		broadcastLinks: true,
+		trackers:       builtinTrackers,
 	}
 
 	for _, opt := range opts {
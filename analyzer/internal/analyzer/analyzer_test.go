@@ -9,16 +9,23 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"shared/audit"
 	"shared/messagebus"
+	"shared/metrics"
 	"shared/mocks"
 	"shared/models"
+	"shared/repository"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
+	"golang.org/x/net/html"
 )
 
 const shouldNotBeFound = "should_not_be_found"
@@ -68,6 +75,7 @@ type TestCase struct {
 	expectedAccessible   int
 	expectedInaccessible int
 	expectedLoginForm    bool
+	expectedDepths       map[string]int
 	description          string
 }
 
@@ -105,13 +113,14 @@ func setupMockAnalyzer(t *testing.T, htmlContent string, testURL string) (*Analy
 		Status: models.JobStatusPending,
 	}, nil).AnyTimes()
 
-	mockJobRepo.EXPECT().UpdateJob(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
-		func(ctx context.Context, jobID string, status *models.JobStatus, result *models.AnalyzeResult) error {
+	mockJobRepo.EXPECT().UpdateJob(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, jobID string, status *models.JobStatus, result *models.AnalyzeResult, opts ...repository.UpdateOption) error {
 			capturedResult = result
 			return nil
 		}).AnyTimes()
 
-	mockJobRepo.EXPECT().UpdateJobStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockJobRepo.EXPECT().UpdateJobStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockJobRepo.EXPECT().UpdateJobProgress(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 	mockTaskRepo.EXPECT().UpdateTaskStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
 	// Capture AddSubTaskByKey calls
@@ -175,6 +184,7 @@ func TestAnalyzer_HTMLAnalysis(t *testing.T) {
 			expectedAccessible:   8,
 			expectedInaccessible: 0,
 			expectedLoginForm:    true,
+			expectedDepths:       map[string]int{"1": 6},
 			description:          "Blog with mixed content, login form, and various link types",
 		},
 		{
@@ -283,6 +293,11 @@ func TestAnalyzer_HTMLAnalysis(t *testing.T) {
 			assert.Equal(t, tc.expectedAccessible, result.AccessibleLinks, "Accessible links count mismatch")
 			assert.Equal(t, tc.expectedInaccessible, result.InaccessibleLinks, "Inaccessible links count mismatch")
 			assert.Equal(t, tc.expectedLoginForm, result.HasLoginForm, "Login form detection mismatch")
+			assert.False(t, result.PartialResult, "Completed analysis should not be flagged partial")
+			if tc.expectedDepths != nil {
+				assert.Equal(t, tc.expectedDepths, result.InternalLinkDepthHistogram, "Internal link depth histogram mismatch")
+				assert.False(t, result.NavOnlyPage, "Page should not be flagged as nav-only")
+			}
 
 			totalExpectedLinks := tc.expectedExternal + tc.expectedInternal
 			if totalExpectedLinks > 0 {
@@ -334,13 +349,281 @@ func TestAnalyzer_JobNotFound(t *testing.T) {
 	mockTaskRepo := mocks.NewMockTaskRepositoryInterface(ctrl)
 	mockMessageBus := mocks.NewMockMessageBusInterface(ctrl)
 
-	mockJobRepo.EXPECT().GetJob(gomock.Any(), gomock.Any()).Return(nil, errors.New("job not found"))
+	// The message is dropped without writing to a job or tasks that do not exist
+	mockJobRepo.EXPECT().GetJob(gomock.Any(), "test-job-id").Return(nil, repository.ErrJobNotFound)
+
+	analyzer := NewAnalyzer(
+		mockJobRepo,
+		mockTaskRepo,
+		mockMessageBus,
+		WithLogger(slog.New(slog.DiscardHandler)),
+	)
+
+	msg, err := json.Marshal(messagebus.AnalyzeMessage{
+		JobId: "test-job-id",
+	})
+	assert.NoError(t, err, "Failed to marshal analyze message")
+
+	analyzer.ProcessAnalyzeMessage(context.Background(), &nats.Msg{
+		Data:    msg,
+		Subject: "url.analyze",
+	})
+}
+
+func TestAnalyzer_GetJobFails(t *testing.T) {
+	testCases := []struct {
+		name     string
+		writeErr error
+		// expected are the statuses of the stored tasks once the message was processed
+		expected map[models.TaskType]models.TaskStatus
+	}{
+		{
+			name: "PendingJob",
+			expected: map[models.TaskType]models.TaskStatus{
+				models.TaskTypeExtracting:     models.TaskStatusCompleted,
+				models.TaskTypeVerifyingLinks: models.TaskStatusFailed,
+			},
+		},
+		{
+			name:     "StartedByAnotherAttempt",
+			writeErr: repository.ErrStaleAttempt,
+			expected: map[models.TaskType]models.TaskStatus{
+				models.TaskTypeExtracting:     models.TaskStatusCompleted,
+				models.TaskTypeVerifyingLinks: models.TaskStatusRunning,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ctx := context.Background()
+			// The job selected two tasks, only those are stored
+			tasks := repository.NewMemoryTaskRepository()
+			require.NoError(t, tasks.CreateTasks(ctx,
+				&models.Task{JobID: "test-job-id", Type: models.TaskTypeExtracting, Status: models.TaskStatusCompleted},
+				&models.Task{JobID: "test-job-id", Type: models.TaskTypeVerifyingLinks, Status: models.TaskStatusRunning},
+			))
+
+			mockJobRepo := mocks.NewMockJobRepositoryInterface(ctrl)
+			mockJobRepo.EXPECT().GetJob(gomock.Any(), gomock.Any()).Return(nil, errors.New("database error"))
+			// The job is only failed while it is pending, an analysis may have started it
+			mockJobRepo.EXPECT().UpdateJobStatus(gomock.Any(), "test-job-id", models.JobStatusFailed, gomock.Any()).Return(tc.writeErr)
+
+			mockMessageBus := mocks.NewMockMessageBusInterface(ctrl)
+			mockMessageBus.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			mockMessageBus.EXPECT().PublishTaskStatusUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+			analyzer := NewAnalyzer(mockJobRepo, tasks, mockMessageBus, WithLogger(slog.New(slog.DiscardHandler)))
+
+			msg, err := json.Marshal(messagebus.AnalyzeMessage{JobId: "test-job-id", AttemptID: "attempt-1"})
+			require.NoError(t, err, "Failed to marshal analyze message")
+			analyzer.ProcessAnalyzeMessage(ctx, &nats.Msg{Data: msg, Subject: "url.analyze"})
+
+			stored, err := tasks.GetTasksByJobId(ctx, "test-job-id")
+			require.NoError(t, err)
+			statuses := make(map[models.TaskType]models.TaskStatus)
+			for _, task := range stored {
+				statuses[task.Type] = task.Status
+			}
+			assert.Equal(t, tc.expected, statuses)
+		})
+	}
+}
+
+func TestAnalyzer_RecoversAnalysisPanic(t *testing.T) {
+	testCases := []struct {
+		name  string
+		setup func(ctx context.Context, jobs repository.JobRepositoryInterface) error
+		// expectedStatus and expectedTaskStatus are the job's and its tasks' once the panic was recovered
+		expectedStatus     models.JobStatus
+		expectedTaskStatus models.TaskStatus
+	}{
+		{
+			name:               "FailsStartedJob",
+			setup:              func(context.Context, repository.JobRepositoryInterface) error { return nil },
+			expectedStatus:     models.JobStatusFailed,
+			expectedTaskStatus: models.TaskStatusFailed,
+		},
+		{
+			name: "SupersededAttempt",
+			setup: func(ctx context.Context, jobs repository.JobRepositoryInterface) error {
+				return jobs.UpdateJobStatus(ctx, "test-job-id", models.JobStatusRunning, repository.WithAttempt("attempt-2"))
+			},
+			expectedStatus:     models.JobStatusRunning,
+			expectedTaskStatus: models.TaskStatusPending,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ctx := context.Background()
+			jobs := repository.NewMemoryJobRepository()
+			require.NoError(t, jobs.CreateJob(ctx, &models.Job{ID: "test-job-id", URL: "https://example.com/", Status: models.JobStatusPending}))
+			tasks := repository.NewMemoryTaskRepository()
+			for _, taskType := range models.TaskTypes() {
+				require.NoError(t, tasks.CreateTasks(ctx, &models.Task{JobID: "test-job-id", Type: taskType, Status: models.TaskStatusPending}))
+			}
+
+			mockMessageBus := mocks.NewMockMessageBusInterface(ctrl)
+			mockMessageBus.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, m messagebus.JobUpdateMessage) error {
+					if m.Status == string(models.JobStatusFailed) {
+						assert.Equal(t, models.FailureCodeInternal, m.FailureCode)
+					}
+					return nil
+				}).AnyTimes()
+			mockMessageBus.EXPECT().PublishTaskStatusUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			mockMessageBus.EXPECT().PublishSubTaskUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+			// The analysis panics once it started the job, after the setup ran
+			transport := &sequenceRoundTripper{responses: []func(req *http.Request) (*http.Response, error){
+				func(req *http.Request) (*http.Response, error) {
+					if err := tc.setup(ctx, jobs); err != nil {
+						return nil, err
+					}
+					panic("corrupted page")
+				},
+			}}
+			analyzer := NewAnalyzer(jobs, tasks, mockMessageBus,
+				WithHTTPClient(&http.Client{Transport: transport}),
+				WithLogger(slog.New(slog.DiscardHandler)),
+			)
+
+			var err error
+			assert.NotPanics(t, func() {
+				err = analyzer.runAnalysis(ctx, messagebus.AnalyzeMessage{JobId: "test-job-id", AttemptID: "attempt-1"})
+			})
+			assert.ErrorIs(t, err, errAnalysisPanicked)
+			assert.ErrorContains(t, err, "corrupted page")
+
+			job, err := jobs.GetJob(ctx, "test-job-id")
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, job.Status)
+
+			stored, err := tasks.GetTasksByJobId(ctx, "test-job-id")
+			require.NoError(t, err)
+			require.NotEmpty(t, stored)
+			for _, task := range stored {
+				assert.Equal(t, tc.expectedTaskStatus, task.Status, "task %s", task.Type)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_RecoversPanicBeforeReadingJob(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Nothing is written for a job that was never read, the mocks expect no other call
+	mockJobRepo := mocks.NewMockJobRepositoryInterface(ctrl)
+	mockJobRepo.EXPECT().GetJob(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, id string) (*models.Job, error) {
+			panic("corrupted job")
+		}).Times(2)
+
+	analyzer := NewAnalyzer(
+		mockJobRepo,
+		mocks.NewMockTaskRepositoryInterface(ctrl),
+		mocks.NewMockMessageBusInterface(ctrl),
+		WithLogger(slog.New(slog.DiscardHandler)),
+	)
+
+	msg, err := json.Marshal(messagebus.AnalyzeMessage{JobId: "test-job-id"})
+	assert.NoError(t, err, "Failed to marshal analyze message")
+	assert.NotPanics(t, func() {
+		analyzer.ProcessAnalyzeMessage(context.Background(), &nats.Msg{Data: msg, Subject: "url.analyze"})
+	})
+
+	err = analyzer.runAnalysis(context.Background(), messagebus.AnalyzeMessage{JobId: "test-job-id"})
+	assert.ErrorIs(t, err, errAnalysisPanicked)
+	assert.ErrorContains(t, err, "corrupted job")
+}
+
+func TestAnalyzer_TraverseNode_DeeplyNested(t *testing.T) {
+	// Nest far deeper than the parser would, to make sure traversal does not recurse
+	const depth = 1_000_000
+
+	doc := &html.Node{Type: html.DocumentNode}
+	parent := doc
+	for range depth {
+		div := &html.Node{Type: html.ElementNode, Data: "div"}
+		parent.AppendChild(div)
+		parent = div
+	}
+
+	form := &html.Node{Type: html.ElementNode, Data: "form"}
+	for _, attrs := range [][]html.Attribute{
+		{{Key: "type", Val: "email"}},
+		{{Key: "type", Val: "password"}},
+		{{Key: "type", Val: "submit"}},
+	} {
+		form.AppendChild(&html.Node{Type: html.ElementNode, Data: "input", Attr: attrs})
+	}
+	parent.AppendChild(form)
+	parent.AppendChild(&html.Node{Type: html.ElementNode, Data: "a", Attr: []html.Attribute{{Key: "href", Val: "/deep"}}})
+	doc.AppendChild(&html.Node{Type: html.ElementNode, Data: "h1"})
+
+	analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+	result := &AnalysisResult{
+		headings: make(map[string]int),
+		links:    []string{},
+		baseURL:  "https://example.com/",
+	}
+	analyzer.traverseNode(doc, result)
+
+	assert.Equal(t, []string{"https://example.com/deep"}, result.links)
+	assert.True(t, result.hasLoginForm)
+	assert.Equal(t, map[string]int{"h1": 1}, result.headings, "siblings of the deepest branch are visited")
+}
+
+func TestWalkNodes_StopsEarly(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<p>1</p><p>2</p><div><p>3</p></div>`))
+	assert.NoError(t, err)
+
+	var visited []string
+	walkNodes(doc, func(n *html.Node) bool {
+		if n.Type == html.TextNode {
+			visited = append(visited, n.Data)
+		}
+		return len(visited) < 2
+	})
+	assert.Equal(t, []string{"1", "2"}, visited)
+
+	// Only the subtree of the root is visited
+	body := doc.FirstChild.LastChild
+	visited = nil
+	walkNodes(body.LastChild, func(n *html.Node) bool {
+		if n.Type == html.TextNode {
+			visited = append(visited, n.Data)
+		}
+		return true
+	})
+	assert.Equal(t, []string{"3"}, visited)
+}
+
+func TestAnalyzer_SkipsCancelledJob(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockJobRepo := mocks.NewMockJobRepositoryInterface(ctrl)
+	mockTaskRepo := mocks.NewMockTaskRepositoryInterface(ctrl)
+	mockMessageBus := mocks.NewMockMessageBusInterface(ctrl)
+
+	mockJobRepo.EXPECT().GetJob(gomock.Any(), gomock.Any()).Return(&models.Job{
+		ID:     "test-job-id",
+		URL:    "https://www.google.com",
+		Status: models.JobStatusCancelled,
+	}, nil)
 
-	// Should still attempt to update the job status and task statuses
-	mockJobRepo.EXPECT().UpdateJobStatus(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
-	mockTaskRepo.EXPECT().UpdateTaskStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(4)
-	mockMessageBus.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).Return(nil)
-	mockMessageBus.EXPECT().PublishTaskStatusUpdate(gomock.Any(), gomock.Any()).Return(nil).Times(4)
+	// Should not touch the job or its tasks once it has been cancelled
+	mockJobRepo.EXPECT().UpdateJobStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+	mockTaskRepo.EXPECT().UpdateTaskStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
 
 	analyzer := NewAnalyzer(
 		mockJobRepo,
@@ -360,6 +643,97 @@ func TestAnalyzer_JobNotFound(t *testing.T) {
 	})
 }
 
+func TestAnalyzer_StartJob_VersionConflict(t *testing.T) {
+	conflict := &repository.VersionConflictError{JobID: "test-job-id", Expected: 3}
+
+	testCases := []struct {
+		name        string
+		fresh       *models.Job
+		expectRetry bool
+		expectedErr error
+	}{
+		{
+			name:        "RetriedOnFreshState",
+			fresh:       &models.Job{ID: "test-job-id", Status: models.JobStatusPending, Version: 4},
+			expectRetry: true,
+		},
+		{
+			name:        "FinishedMeanwhile",
+			fresh:       &models.Job{ID: "test-job-id", Status: models.JobStatusCancelled, Version: 4},
+			expectedErr: errJobFinished,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockJobRepo := mocks.NewMockJobRepositoryInterface(ctrl)
+			mockMessageBus := mocks.NewMockMessageBusInterface(ctrl)
+
+			calls := []any{
+				mockJobRepo.EXPECT().UpdateJobStatus(gomock.Any(), "test-job-id", models.JobStatusRunning, gomock.Any()).Return(conflict),
+				mockJobRepo.EXPECT().GetJob(gomock.Any(), "test-job-id").Return(tc.fresh, nil),
+			}
+			if tc.expectRetry {
+				calls = append(calls,
+					mockJobRepo.EXPECT().UpdateJobStatus(gomock.Any(), "test-job-id", models.JobStatusRunning, gomock.Any()).Return(nil),
+					mockMessageBus.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).Return(nil),
+				)
+			}
+			gomock.InOrder(calls...)
+
+			analyzer := NewAnalyzer(mockJobRepo, nil, mockMessageBus, WithLogger(slog.New(slog.DiscardHandler)))
+			err := analyzer.startJob(context.Background(), &models.Job{ID: "test-job-id", Status: models.JobStatusPending, Version: 3}, "attempt-1")
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_WaitForInFlightMessage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockJobRepo := mocks.NewMockJobRepositoryInterface(ctrl)
+	mockTaskRepo := mocks.NewMockTaskRepositoryInterface(ctrl)
+	mockMessageBus := mocks.NewMockMessageBusInterface(ctrl)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mockJobRepo.EXPECT().GetJob(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, id string) (*models.Job, error) {
+		close(started)
+		<-release
+		return &models.Job{ID: id, Status: models.JobStatusCancelled}, nil
+	})
+
+	analyzer := NewAnalyzer(
+		mockJobRepo,
+		mockTaskRepo,
+		mockMessageBus,
+		WithLogger(slog.New(slog.DiscardHandler)),
+	)
+
+	msg, err := json.Marshal(messagebus.AnalyzeMessage{JobId: "test-job-id"})
+	assert.NoError(t, err, "Failed to marshal analyze message")
+
+	go analyzer.ProcessAnalyzeMessage(context.Background(), &nats.Msg{Data: msg, Subject: "url.analyze"})
+	<-started
+
+	// The message is still being processed, so waiting must time out
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, analyzer.Wait(ctx), context.DeadlineExceeded)
+
+	close(release)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, analyzer.Wait(ctx))
+}
+
 func TestAnalyzer_FailedToMarshalAnalyzeMessage(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -399,13 +773,19 @@ func TestAnalyzer_FailedToFetchContent(t *testing.T) {
 	}, nil)
 
 	var capturedJobStatus models.JobStatus
-	mockJobRepo.EXPECT().UpdateJobStatus(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, jobID string, status models.JobStatus) error {
+	mockJobRepo.EXPECT().UpdateJobStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, jobID string, status models.JobStatus, opts ...repository.UpdateOption) error {
 		capturedJobStatus = status
 		return nil
 	}).AnyTimes()
-	mockJobRepo.EXPECT().UpdateJob(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockJobRepo.EXPECT().UpdateJob(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 	mockTaskRepo.EXPECT().UpdateTaskStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
-	mockMessageBus.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	var failedUpdate messagebus.JobUpdateMessage
+	mockMessageBus.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, m messagebus.JobUpdateMessage) error {
+		if m.Status == string(models.JobStatusFailed) {
+			failedUpdate = m
+		}
+		return nil
+	}).AnyTimes()
 	mockMessageBus.EXPECT().PublishTaskStatusUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
 
 	mockHTTPClient := &http.Client{
@@ -434,4 +814,709 @@ func TestAnalyzer_FailedToFetchContent(t *testing.T) {
 	})
 
 	assert.Equal(t, models.JobStatusFailed, capturedJobStatus, "Job status should be failed")
+	assert.Equal(t, models.FailureCodeFetch, failedUpdate.FailureCode, "Failure should be classified as a fetch failure")
+	assert.Contains(t, failedUpdate.FailureReason, "The page could not be fetched")
+	assert.Equal(t, http.StatusBadRequest, failedUpdate.FetchStatusCode, "Failure should carry the status the page was served with")
+}
+
+// panickingLinkRoundTripper serves the page and panics on any other request, simulating a crash in link verification
+type panickingLinkRoundTripper struct {
+	pageURL     string
+	htmlContent string
+}
+
+func (p *panickingLinkRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.String() != p.pageURL {
+		panic("link verification exploded")
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(p.htmlContent)),
+		Request:    req,
+	}, nil
+}
+
+func TestAnalyzer_StoresPartialResultWhileRunning(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockJobRepo := mocks.NewMockJobRepositoryInterface(ctrl)
+	mockTaskRepo := mocks.NewMockTaskRepositoryInterface(ctrl)
+	mockMessageBus := mocks.NewMockMessageBusInterface(ctrl)
+
+	htmlContent := `<!DOCTYPE html><html><head><title>Running</title></head><body><h2>Hi</h2><a href="/a">A</a><a href="https://other.com">B</a></body></html>`
+
+	mockJobRepo.EXPECT().GetJob(gomock.Any(), gomock.Any()).Return(&models.Job{
+		ID:     "test-job-id",
+		URL:    "https://example.com/",
+		Status: models.JobStatusPending,
+	}, nil)
+	mockJobRepo.EXPECT().UpdateJobStatus(gomock.Any(), gomock.Any(), models.JobStatusRunning, gomock.Any()).Return(nil)
+	mockJobRepo.EXPECT().UpdateJobProgress(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	var stored []models.AnalyzeResult
+	var storedStatuses []models.JobStatus
+	mockJobRepo.EXPECT().UpdateJob(gomock.Any(), "test-job-id", gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, jobID string, status *models.JobStatus, result *models.AnalyzeResult, opts ...repository.UpdateOption) error {
+			if status != nil {
+				storedStatuses = append(storedStatuses, *status)
+			}
+			stored = append(stored, *result)
+			return nil
+		}).Times(2)
+
+	mockTaskRepo.EXPECT().UpdateTaskStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockTaskRepo.EXPECT().AddSubTaskByKey(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockTaskRepo.EXPECT().UpdateSubTaskByKey(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockMessageBus.EXPECT().PublishTaskStatusUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockMessageBus.EXPECT().PublishSubTaskUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockMessageBus.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	analyzer := NewAnalyzer(
+		mockJobRepo,
+		mockTaskRepo,
+		mockMessageBus,
+		WithHTTPClient(&http.Client{Transport: &MockHTTPRoundTripper{statusCode: http.StatusOK, htmlContent: htmlContent}}),
+		WithLogger(slog.New(slog.DiscardHandler)),
+	)
+
+	msg, err := json.Marshal(messagebus.AnalyzeMessage{
+		JobId: "test-job-id",
+	})
+	assert.NoError(t, err, "Failed to marshal analyze message")
+
+	analyzer.ProcessAnalyzeMessage(context.Background(), &nats.Msg{
+		Data:    msg,
+		Subject: "url.analyze",
+	})
+
+	// The partial result leaves the status alone
+	assert.Equal(t, []models.JobStatus{models.JobStatusCompleted}, storedStatuses)
+	if assert.Len(t, stored, 2) {
+		// Stored before verification, so the link counts are known but not their accessibility
+		partial := stored[0]
+		assert.True(t, partial.PartialResult)
+		assert.Equal(t, "Running", partial.PageTitle)
+		assert.Equal(t, map[string]int{"h2": 1}, partial.Headings)
+		assert.Equal(t, 1, partial.InternalLinkCount)
+		assert.Equal(t, 1, partial.ExternalLinkCount)
+		assert.Zero(t, partial.AccessibleLinks+partial.InaccessibleLinks)
+
+		final := stored[1]
+		assert.False(t, final.PartialResult)
+		assert.Equal(t, 2, final.AccessibleLinks)
+	}
+}
+
+func TestAnalyzer_LinkVerificationFailure_CompletesWithWarning(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockJobRepo := mocks.NewMockJobRepositoryInterface(ctrl)
+	mockTaskRepo := mocks.NewMockTaskRepositoryInterface(ctrl)
+	mockMessageBus := mocks.NewMockMessageBusInterface(ctrl)
+
+	const pageURL = "https://example.com/"
+	htmlContent := `<!DOCTYPE html><html><head><title>Partial</title></head><body><h1>Hello</h1><a href="/about">About</a></body></html>`
+
+	mockJobRepo.EXPECT().GetJob(gomock.Any(), gomock.Any()).Return(&models.Job{
+		ID:     "test-job-id",
+		URL:    pageURL,
+		Status: models.JobStatusPending,
+	}, nil)
+	mockJobRepo.EXPECT().UpdateJobStatus(gomock.Any(), gomock.Any(), models.JobStatusRunning, gomock.Any()).Return(nil)
+	mockJobRepo.EXPECT().UpdateJobProgress(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	var storedStatuses []models.JobStatus
+	var storedResult *models.AnalyzeResult
+	mockJobRepo.EXPECT().UpdateJob(gomock.Any(), "test-job-id", gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, jobID string, status *models.JobStatus, result *models.AnalyzeResult, opts ...repository.UpdateOption) error {
+			if status != nil {
+				storedStatuses = append(storedStatuses, *status)
+			}
+			storedResult = result
+			return nil
+		}).Times(2)
+
+	var capturedTaskStatuses sync.Map
+	mockTaskRepo.EXPECT().UpdateTaskStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, jobID string, taskType models.TaskType, status models.TaskStatus) error {
+			capturedTaskStatuses.Store(taskType, status)
+			return nil
+		}).AnyTimes()
+	mockTaskRepo.EXPECT().AddSubTaskByKey(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockTaskRepo.EXPECT().UpdateSubTaskByKey(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockMessageBus.EXPECT().PublishTaskStatusUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockMessageBus.EXPECT().PublishSubTaskUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	var completionMessage messagebus.JobUpdateMessage
+	var publishedStatuses []string
+	mockMessageBus.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, m messagebus.JobUpdateMessage) error {
+			if m.Status == string(models.JobStatusCompleted) {
+				completionMessage = m
+			}
+			// Progress updates repeat the running status, only the status changes are counted
+			if m.Status != string(models.JobStatusRunning) || m.Progress == nil {
+				publishedStatuses = append(publishedStatuses, m.Status)
+			}
+			return nil
+		}).AnyTimes()
+
+	analyzer := NewAnalyzer(
+		mockJobRepo,
+		mockTaskRepo,
+		mockMessageBus,
+		WithHTTPClient(&http.Client{Transport: &panickingLinkRoundTripper{pageURL: pageURL, htmlContent: htmlContent}}),
+		WithLogger(slog.New(slog.DiscardHandler)),
+	)
+
+	msg, err := json.Marshal(messagebus.AnalyzeMessage{
+		JobId: "test-job-id",
+	})
+	assert.NoError(t, err, "Failed to marshal analyze message")
+
+	analyzer.ProcessAnalyzeMessage(context.Background(), &nats.Msg{
+		Data:    msg,
+		Subject: "url.analyze",
+	})
+
+	// The partial result is stored without a status, then completed with the failed task listed
+	assert.Equal(t, []models.JobStatus{models.JobStatusCompleted}, storedStatuses)
+	if assert.NotNil(t, storedResult) {
+		assert.True(t, storedResult.PartialResult)
+		assert.Equal(t, "Partial", storedResult.PageTitle)
+		assert.Equal(t, "HTML5", storedResult.HtmlVersion)
+		assert.Equal(t, map[string]int{"h1": 1}, storedResult.Headings)
+		assert.Equal(t, []models.TaskType{models.TaskTypeVerifyingLinks}, storedResult.FailedTasks)
+		if assert.Len(t, storedResult.Warnings, 1) {
+			assert.Equal(t, models.WarningTaskFailed, storedResult.Warnings[0].Code)
+			assert.Contains(t, storedResult.Warnings[0].Message, "link verification exploded")
+		}
+	}
+
+	assert.Equal(t, []string{string(models.JobStatusRunning), string(models.JobStatusCompleted)}, publishedStatuses)
+	if assert.NotNil(t, completionMessage.Result, "completion update should carry the result") {
+		assert.True(t, completionMessage.Result.PartialResult)
+		assert.Equal(t, "Partial", completionMessage.Result.PageTitle)
+		assert.Equal(t, []models.TaskType{models.TaskTypeVerifyingLinks}, completionMessage.Result.FailedTasks)
+	}
+
+	status, _ := capturedTaskStatuses.Load(models.TaskTypeVerifyingLinks)
+	assert.Equal(t, models.TaskStatusFailed, status)
+	status, _ = capturedTaskStatuses.Load(models.TaskTypeAnalyzing)
+	assert.Equal(t, models.TaskStatusCompleted, status)
+	status, _ = capturedTaskStatuses.Load(models.TaskTypeIdentifyingVersion)
+	assert.Equal(t, models.TaskStatusCompleted, status)
+}
+
+func TestAnalyzer_ContentAnalysisFailure_KeepsOtherPhases(t *testing.T) {
+	analyzer, _, ctrl, _ := setupMockAnalyzer(t, "", "https://example.com")
+	defer ctrl.Finish()
+
+	// Replaces the task repository of the setup, so verifying a link would be an unexpected call
+	var taskStatuses sync.Map
+	mockTaskRepo := mocks.NewMockTaskRepositoryInterface(ctrl)
+	mockTaskRepo.EXPECT().UpdateTaskStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, jobID string, taskType models.TaskType, status models.TaskStatus) error {
+			taskStatuses.Store(taskType, status)
+			return nil
+		}).AnyTimes()
+	analyzer.taskRepo = mockTaskRepo
+
+	// Without its headings map the content analysis panics on the first heading
+	result := &AnalysisResult{baseURL: "https://example.com"}
+	job := &models.Job{ID: "test-job-id", URL: "https://example.com"}
+	err := analyzer.analyzeHTML(context.Background(), job,
+		`<!DOCTYPE html><html><body><h1>Broken</h1><a href="/about">About</a></body></html>`, result)
+	assert.NoError(t, err, "a failed phase should not fail the analysis")
+
+	built := result.snapshot()
+	assert.Equal(t, "HTML5", built.HtmlVersion, "version detection runs independently of content analysis")
+	assert.Equal(t, []models.TaskType{models.TaskTypeAnalyzing}, built.FailedTasks)
+	assert.True(t, built.PartialResult)
+	if assert.Len(t, built.Warnings, 1) {
+		assert.Equal(t, models.WarningTaskFailed, built.Warnings[0].Code)
+		assert.Contains(t, built.Warnings[0].Message, "analyzing")
+	}
+
+	for taskType, expected := range map[models.TaskType]models.TaskStatus{
+		models.TaskTypeExtracting:         models.TaskStatusCompleted,
+		models.TaskTypeIdentifyingVersion: models.TaskStatusCompleted,
+		models.TaskTypeAnalyzing:          models.TaskStatusFailed,
+		models.TaskTypeVerifyingLinks:     models.TaskStatusSkipped,
+	} {
+		status, _ := taskStatuses.Load(taskType)
+		assert.Equal(t, expected, status, taskType)
+	}
+}
+
+func TestAnalyzer_TaskSelection_SkipsUnselectedPhases(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockJobRepo := mocks.NewMockJobRepositoryInterface(ctrl)
+	mockTaskRepo := mocks.NewMockTaskRepositoryInterface(ctrl)
+	mockMessageBus := mocks.NewMockMessageBusInterface(ctrl)
+
+	const pageURL = "https://example.com/"
+	htmlContent := `<!DOCTYPE html><html><head><title>Subset</title></head><body><h1>Hello</h1><a href="/about">About</a></body></html>`
+
+	mockJobRepo.EXPECT().GetJob(gomock.Any(), gomock.Any()).Return(&models.Job{
+		ID:     "test-job-id",
+		URL:    pageURL,
+		Status: models.JobStatusPending,
+		Tasks:  []models.TaskType{models.TaskTypeExtracting, models.TaskTypeIdentifyingVersion},
+	}, nil)
+	mockJobRepo.EXPECT().UpdateJobStatus(gomock.Any(), gomock.Any(), models.JobStatusRunning, gomock.Any()).Return(nil)
+	mockJobRepo.EXPECT().UpdateJobProgress(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	var storedStatus models.JobStatus
+	var storedResult *models.AnalyzeResult
+	mockJobRepo.EXPECT().UpdateJob(gomock.Any(), "test-job-id", gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, jobID string, status *models.JobStatus, result *models.AnalyzeResult, opts ...repository.UpdateOption) error {
+			if status != nil {
+				storedStatus = *status
+			}
+			storedResult = result
+			return nil
+		}).Times(2)
+
+	var capturedTaskStatuses sync.Map
+	mockTaskRepo.EXPECT().UpdateTaskStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, jobID string, taskType models.TaskType, status models.TaskStatus) error {
+			capturedTaskStatuses.Store(taskType, status)
+			return nil
+		}).AnyTimes()
+	mockMessageBus.EXPECT().PublishTaskStatusUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockMessageBus.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	// Any link request panics, so the job only completes when verification is skipped
+	analyzer := NewAnalyzer(
+		mockJobRepo,
+		mockTaskRepo,
+		mockMessageBus,
+		WithHTTPClient(&http.Client{Transport: &panickingLinkRoundTripper{pageURL: pageURL, htmlContent: htmlContent}}),
+		WithLogger(slog.New(slog.DiscardHandler)),
+	)
+
+	msg, err := json.Marshal(messagebus.AnalyzeMessage{
+		JobId: "test-job-id",
+	})
+	assert.NoError(t, err, "Failed to marshal analyze message")
+
+	analyzer.ProcessAnalyzeMessage(context.Background(), &nats.Msg{
+		Data:    msg,
+		Subject: "url.analyze",
+	})
+
+	assert.Equal(t, models.JobStatusCompleted, storedStatus)
+	if assert.NotNil(t, storedResult) {
+		assert.Equal(t, "HTML5", storedResult.HtmlVersion)
+		assert.Empty(t, storedResult.PageTitle, "content analysis was not selected")
+		assert.Empty(t, storedResult.Headings)
+		assert.Equal(t, []models.TaskType{models.TaskTypeAnalyzing, models.TaskTypeVerifyingLinks}, storedResult.SkippedTasks)
+	}
+
+	expected := map[models.TaskType]models.TaskStatus{
+		models.TaskTypeExtracting:         models.TaskStatusCompleted,
+		models.TaskTypeIdentifyingVersion: models.TaskStatusCompleted,
+		models.TaskTypeAnalyzing:          models.TaskStatusSkipped,
+		models.TaskTypeVerifyingLinks:     models.TaskStatusSkipped,
+	}
+	for taskType, want := range expected {
+		status, _ := capturedTaskStatuses.Load(taskType)
+		assert.Equal(t, want, status, "task %s", taskType)
+	}
+}
+
+// invalidTaskTypeMetrics counts task updates refused for an unknown task type
+type invalidTaskTypeMetrics struct {
+	metrics.NoOpAnalyzerMetrics
+	invalid atomic.Int32
+}
+
+func (m *invalidTaskTypeMetrics) RecordInvalidTaskType() {
+	m.invalid.Add(1)
+}
+
+func TestAnalyzer_RefusesUnknownTaskType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// No repository or message bus expectations, an unknown task type must not reach either
+	mockJobRepo := mocks.NewMockJobRepositoryInterface(ctrl)
+	mockTaskRepo := mocks.NewMockTaskRepositoryInterface(ctrl)
+	mockMessageBus := mocks.NewMockMessageBusInterface(ctrl)
+	m := &invalidTaskTypeMetrics{}
+
+	analyzer := NewAnalyzer(
+		mockJobRepo,
+		mockTaskRepo,
+		mockMessageBus,
+		WithMetrics(m),
+		WithLogger(slog.New(slog.DiscardHandler)),
+	)
+
+	ctx := context.Background()
+	analyzer.updateTaskStatus(ctx, "test-job-id", "verifying_lnks", models.TaskStatusRunning)
+	assert.NoError(t, analyzer.publishSubTask(ctx, messagebus.SubTaskUpdateMessage{
+		Type:     messagebus.SubTaskUpdateMessageType,
+		JobID:    "test-job-id",
+		TaskType: "screenshot",
+		Key:      "1",
+		SubTask:  models.SubTask{Type: models.SubTaskTypeValidatingLink, Status: models.TaskStatusRunning},
+	}))
+
+	assert.Equal(t, int32(2), m.invalid.Load())
+}
+
+func TestAnalyzer_AuditsStatusChanges(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockJobRepo := mocks.NewMockJobRepositoryInterface(ctrl)
+	mockTaskRepo := mocks.NewMockTaskRepositoryInterface(ctrl)
+	mockMessageBus := mocks.NewMockMessageBusInterface(ctrl)
+
+	const pageURL = "https://example.com/"
+	mockJobRepo.EXPECT().GetJob(gomock.Any(), gomock.Any()).Return(&models.Job{
+		ID:     "test-job-id",
+		URL:    pageURL,
+		Status: models.JobStatusPending,
+		Tasks:  []models.TaskType{models.TaskTypeExtracting},
+	}, nil)
+	mockJobRepo.EXPECT().UpdateJobStatus(gomock.Any(), gomock.Any(), models.JobStatusRunning, gomock.Any()).Return(nil)
+	mockJobRepo.EXPECT().UpdateJobProgress(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockJobRepo.EXPECT().UpdateJob(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	mockTaskRepo.EXPECT().UpdateTaskStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockMessageBus.EXPECT().PublishTaskStatusUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockMessageBus.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	var buf bytes.Buffer
+	analyzer := NewAnalyzer(
+		mockJobRepo,
+		mockTaskRepo,
+		mockMessageBus,
+		WithHTTPClient(&http.Client{Transport: &panickingLinkRoundTripper{pageURL: pageURL, htmlContent: "<html></html>"}}),
+		WithLogger(slog.New(slog.DiscardHandler)),
+		WithAuditLogger(audit.NewLogger(&buf, "analyzer")),
+	)
+
+	msg, err := json.Marshal(messagebus.AnalyzeMessage{
+		JobId:  "test-job-id",
+		Source: audit.Source{RequestID: "req-1", SourceIP: "203.0.113.7"},
+	})
+	assert.NoError(t, err, "Failed to marshal analyze message")
+
+	analyzer.ProcessAnalyzeMessage(context.Background(), &nats.Msg{
+		Data:    msg,
+		Subject: "url.analyze",
+	})
+
+	var events, statuses []string
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var record map[string]any
+		if !assert.NoError(t, json.Unmarshal(line, &record)) {
+			continue
+		}
+		events = append(events, record["event"].(string))
+		statuses = append(statuses, record["status"].(string))
+		assert.Equal(t, "req-1", record["requestId"])
+		assert.Equal(t, "203.0.113.7", record["sourceIp"])
+	}
+	assert.Equal(t, []string{string(audit.EventJobStatusChanged), string(audit.EventJobCompleted)}, events)
+	assert.Equal(t, []string{string(models.JobStatusRunning), string(models.JobStatusCompleted)}, statuses)
+}
+
+func TestAnalyzer_CompleteJob_BroadcastLinks(t *testing.T) {
+	result := models.AnalyzeResult{
+		Links:                  []string{"https://example.com/a", "https://example.com/a/"},
+		InternalLinkCount:      2,
+		AccessibleLinks:        2,
+		RedundantRedirectLinks: []models.LinkRedirect{{From: "https://example.com/a", To: "https://example.com/a/"}},
+		RedundantRedirectCount: 1,
+	}
+
+	testCases := []struct {
+		name            string
+		opts            []Option
+		expectedLinks   []string
+		expectedOmitted bool
+	}{
+		{
+			name:          "Default",
+			expectedLinks: result.Links,
+		},
+		{
+			name:            "CountsOnly",
+			opts:            []Option{WithBroadcastLinks(false)},
+			expectedOmitted: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockJobRepo := mocks.NewMockJobRepositoryInterface(ctrl)
+			mockTaskRepo := mocks.NewMockTaskRepositoryInterface(ctrl)
+			mockMessageBus := mocks.NewMockMessageBusInterface(ctrl)
+
+			// The job is always stored with its links
+			var storedResult *models.AnalyzeResult
+			mockJobRepo.EXPECT().UpdateJob(gomock.Any(), "test-job-id", gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, jobID string, status *models.JobStatus, result *models.AnalyzeResult, opts ...repository.UpdateOption) error {
+					storedResult = result
+					return nil
+				})
+			var published messagebus.JobUpdateMessage
+			mockMessageBus.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, m messagebus.JobUpdateMessage) error {
+					published = m
+					return nil
+				})
+
+			opts := append([]Option{WithLogger(slog.New(slog.DiscardHandler))}, tc.opts...)
+			analyzer := NewAnalyzer(mockJobRepo, mockTaskRepo, mockMessageBus, opts...)
+
+			err := analyzer.completeJob(context.Background(), models.Job{ID: "test-job-id"}, result)
+			assert.NoError(t, err)
+
+			assert.Equal(t, result.Links, storedResult.Links)
+			assert.False(t, storedResult.LinksOmitted)
+
+			assert.Equal(t, tc.expectedLinks, published.Result.Links)
+			assert.Equal(t, tc.expectedOmitted, published.Result.LinksOmitted)
+			assert.Equal(t, 2, published.Result.InternalLinkCount)
+			assert.Equal(t, 1, published.Result.RedundantRedirectCount)
+			if tc.expectedOmitted {
+				assert.Nil(t, published.Result.RedundantRedirectLinks)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_TraverseNode_DocumentBase(t *testing.T) {
+	testCases := []struct {
+		name             string
+		content          string
+		expectedLinks    []string
+		expectedInternal int
+		expectedExternal int
+	}{
+		{
+			name:             "RelativeBase",
+			content:          `<head><base href="/docs/"></head><body><a href="intro">Intro</a><a href="/top">Top</a></body>`,
+			expectedLinks:    []string{"https://mirror.example.com/docs/intro", "https://mirror.example.com/top"},
+			expectedInternal: 2,
+		},
+		{
+			name:             "BaseWithoutHref",
+			content:          `<head><base target="_blank"><base href="/docs/"></head><body><a href="intro">Intro</a></body>`,
+			expectedLinks:    []string{"https://mirror.example.com/docs/intro"},
+			expectedInternal: 1,
+		},
+		{
+			name:             "UnusableBase",
+			content:          `<head><base href="javascript:void(0)"><base href="/docs/"></head><body><a href="intro">Intro</a></body>`,
+			expectedLinks:    []string{"https://mirror.example.com/section/intro"},
+			expectedInternal: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader(tc.content))
+			assert.NoError(t, err)
+
+			analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+			result := &AnalysisResult{headings: make(map[string]int), baseURL: "https://mirror.example.com/section/page"}
+			analyzer.traverseNode(doc, result)
+
+			assert.Equal(t, tc.expectedLinks, result.links)
+			assert.Equal(t, tc.expectedInternal, result.counts.get(countInternalLinks))
+			assert.Equal(t, tc.expectedExternal, result.counts.get(countExternalLinks))
+		})
+	}
+}
+
+func TestAnalyzer_TraverseNode_DocumentBaseFixture(t *testing.T) {
+	f, err := os.Open("testdata/base_href.html")
+	assert.NoError(t, err)
+	defer f.Close()
+	doc, err := html.Parse(f)
+	assert.NoError(t, err)
+
+	analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+	result := &AnalysisResult{headings: make(map[string]int), baseURL: "https://mirror.example.com/docs/index.html"}
+	analyzer.traverseNode(doc, result)
+
+	// Only the first <base> counts, and links are still classified against the page the mirror serves
+	assert.Equal(t, []string{
+		"https://docs.example.org/v2/install.html",
+		"https://docs.example.org/v1/changelog.html",
+		"https://docs.example.org/search",
+		"https://mirror.example.com/about",
+	}, result.links)
+	assert.Equal(t, []string{"https://docs.example.org/v2/images/diagram.png"}, result.images)
+	assert.Equal(t, 1, result.counts.get(countInternalLinks))
+	assert.Equal(t, 3, result.counts.get(countExternalLinks))
+}
+
+// staleWriteMetrics records the job writes dropped for a stale attempt
+type staleWriteMetrics struct {
+	metrics.NoOpAnalyzerMetrics
+	mu     sync.Mutex
+	writes []string
+}
+
+func (m *staleWriteMetrics) RecordStaleJobWrite(write string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.writes = append(m.writes, write)
+}
+
+func TestAnalyzer_StaleAttemptLosesRace(t *testing.T) {
+	testCases := []struct {
+		name           string
+		takeOver       func(ctx context.Context, jobs repository.JobRepositoryInterface) error
+		expectedStatus models.JobStatus
+		expectedWrites []string
+	}{
+		{
+			name: "RetriedMeanwhile",
+			takeOver: func(ctx context.Context, jobs repository.JobRepositoryInterface) error {
+				return jobs.UpdateJobStatus(ctx, "test-job-id", models.JobStatusRunning, repository.WithAttempt("attempt-2"))
+			},
+			expectedStatus: models.JobStatusRunning,
+			expectedWrites: []string{staleWritePartial, staleWriteComplete},
+		},
+		{
+			name: "FailedByOperator",
+			takeOver: func(ctx context.Context, jobs repository.JobRepositoryInterface) error {
+				return jobs.UpdateJobStatus(ctx, "test-job-id", models.JobStatusFailed,
+					repository.WithFailure(models.FailureCodeInternal, "analyzer lost the job"))
+			},
+			expectedStatus: models.JobStatusFailed,
+			expectedWrites: []string{staleWritePartial, staleWriteComplete},
+		},
+		{
+			name: "FetchFailsAfterRetry",
+			takeOver: func(ctx context.Context, jobs repository.JobRepositoryInterface) error {
+				if err := jobs.UpdateJobStatus(ctx, "test-job-id", models.JobStatusRunning, repository.WithAttempt("attempt-2")); err != nil {
+					return err
+				}
+				return errors.New("connection reset by peer")
+			},
+			expectedStatus: models.JobStatusRunning,
+			expectedWrites: []string{staleWriteFail},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ctx := context.Background()
+			jobs := repository.NewMemoryJobRepository()
+			assert.NoError(t, jobs.CreateJob(ctx, &models.Job{ID: "test-job-id", URL: "https://example.com/", Status: models.JobStatusPending}))
+
+			var published []string
+			var mu sync.Mutex
+			mockMessageBus := mocks.NewMockMessageBusInterface(ctrl)
+			mockMessageBus.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, m messagebus.JobUpdateMessage) error {
+					mu.Lock()
+					defer mu.Unlock()
+					published = append(published, m.Status)
+					return nil
+				}).AnyTimes()
+			mockMessageBus.EXPECT().PublishTaskStatusUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			mockMessageBus.EXPECT().PublishSubTaskUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+			// The takeover lands while the page is being fetched by the first attempt
+			transport := &sequenceRoundTripper{responses: []func(req *http.Request) (*http.Response, error){
+				func(req *http.Request) (*http.Response, error) {
+					if err := tc.takeOver(ctx, jobs); err != nil {
+						return nil, err
+					}
+					return statusResponse(http.StatusOK, `<html><head><title>Stale</title></head><body><h1>Hi</h1></body></html>`)(req)
+				},
+			}}
+			m := &staleWriteMetrics{}
+			analyzer := NewAnalyzer(jobs, repository.NewMemoryTaskRepository(), mockMessageBus,
+				WithHTTPClient(&http.Client{Transport: transport}),
+				WithLogger(slog.New(slog.DiscardHandler)),
+				WithMetrics(m),
+			)
+
+			msg, err := json.Marshal(messagebus.AnalyzeMessage{JobId: "test-job-id", AttemptID: "attempt-1"})
+			assert.NoError(t, err)
+			analyzer.ProcessAnalyzeMessage(ctx, &nats.Msg{Data: msg, Subject: "url.analyze"})
+
+			job, err := jobs.GetJob(ctx, "test-job-id")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, job.Status, "the newer state should win")
+			assert.Nil(t, job.Result, "the stale attempt's result should be dropped")
+			assert.Equal(t, tc.expectedWrites, m.writes)
+			// Progress updates go out while running, the stale attempt's outcome does not
+			assert.NotEmpty(t, published)
+			for _, status := range published {
+				assert.Equal(t, string(models.JobStatusRunning), status)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_ProcessAnalyzeMessage_StatusHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	jobs := repository.NewMemoryJobRepository()
+	assert.NoError(t, jobs.CreateJob(ctx, &models.Job{ID: "test-job-id", URL: "https://example.com/", Status: models.JobStatusPending}))
+
+	mockMessageBus := mocks.NewMockMessageBusInterface(ctrl)
+	mockMessageBus.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockMessageBus.EXPECT().PublishTaskStatusUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockMessageBus.EXPECT().PublishSubTaskUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	transport := &sequenceRoundTripper{responses: []func(req *http.Request) (*http.Response, error){
+		statusResponse(http.StatusOK, `<html><head><title>History</title></head><body><h1>Hi</h1></body></html>`),
+	}}
+	analyzer := NewAnalyzer(jobs, repository.NewMemoryTaskRepository(), mockMessageBus,
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithLogger(slog.New(slog.DiscardHandler)),
+	)
+
+	msg, err := json.Marshal(messagebus.AnalyzeMessage{JobId: "test-job-id", AttemptID: "attempt-1"})
+	assert.NoError(t, err)
+	analyzer.ProcessAnalyzeMessage(ctx, &nats.Msg{Data: msg, Subject: "url.analyze"})
+
+	job, err := jobs.GetJob(ctx, "test-job-id")
+	assert.NoError(t, err)
+	assert.NotNil(t, job.Result)
+
+	// The partial result stored before link verification does not record the running status again
+	var statuses []models.JobStatus
+	for _, change := range job.StatusHistory {
+		statuses = append(statuses, change.Status)
+	}
+	assert.Equal(t, []models.JobStatus{models.JobStatusPending, models.JobStatusRunning, models.JobStatusCompleted}, statuses)
+}
+
+func TestAnalyzer_AttemptOf(t *testing.T) {
+	analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+
+	assert.Equal(t, "attempt-1", analyzer.attemptOf(messagebus.AnalyzeMessage{JobId: "job-1", AttemptID: "attempt-1"}))
+
+	// Older publishers do not set an attempt, each message still gets its own
+	first := analyzer.attemptOf(messagebus.AnalyzeMessage{JobId: "job-1"})
+	assert.NotEmpty(t, first)
+	assert.NotEqual(t, first, analyzer.attemptOf(messagebus.AnalyzeMessage{JobId: "job-1"}))
 }
@@ -0,0 +1,74 @@
+package analyzer
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// checkAnchors reports whether in-page anchors are checked against the IDs on the page
+func (s *Analyzer) checkAnchors() bool {
+	return s.cfg != nil && s.cfg.Analysis.CheckAnchors
+}
+
+// recordAnchorTarget records the ID an element can be scrolled to by a fragment link.
+// Besides id attributes, the name of an <a> element is a target as well.
+func (s *Analyzer) recordAnchorTarget(n *html.Node, result *AnalysisResult) {
+	if !s.checkAnchors() {
+		return
+	}
+
+	id := s.getElementAttribute(n, "id")
+	if id == "" && n.Data == "a" {
+		id = s.getElementAttribute(n, "name")
+	}
+	if id == "" {
+		return
+	}
+
+	if result.anchorTargets == nil {
+		result.anchorTargets = make(map[string]bool)
+	}
+	result.anchorTargets[id] = true
+}
+
+// recordFragmentLink records the fragment of a link to a place on the page itself, such as href="#section"
+func (s *Analyzer) recordFragmentLink(href string, result *AnalysisResult) {
+	if !s.checkAnchors() || !strings.HasPrefix(href, "#") {
+		return
+	}
+
+	fragment := href[1:]
+	// An empty fragment and #top scroll to the top of the page without a target
+	if fragment == "" || strings.EqualFold(fragment, "top") {
+		return
+	}
+	if decoded, err := url.PathUnescape(fragment); err == nil {
+		fragment = decoded
+	}
+
+	result.fragmentLinks = append(result.fragmentLinks, fragment)
+}
+
+// findBrokenAnchors sets the fragment links of the traversed page that point to no element
+func (s *Analyzer) findBrokenAnchors(result *AnalysisResult) {
+	if s.checkAnchors() {
+		result.brokenAnchors = brokenAnchors(result.fragmentLinks, result.anchorTargets)
+	}
+}
+
+// brokenAnchors returns the fragment links with no element of their ID on the page, each once,
+// in the order they first appear
+func brokenAnchors(fragments []string, targets map[string]bool) []string {
+	var broken []string
+	seen := make(map[string]bool)
+	for _, fragment := range fragments {
+		if targets[fragment] || seen[fragment] {
+			continue
+		}
+		seen[fragment] = true
+		broken = append(broken, "#"+fragment)
+	}
+	return broken
+}
@@ -0,0 +1,70 @@
+package analyzer
+
+import (
+	"analyzer/internal/config"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+const anchorsPage = `<html><body>
+<nav>
+	<a href="#intro">Intro</a>
+	<a href="#pricing">Pricing</a>
+	<a href="#faq">FAQ</a>
+	<a href="#caf%C3%A9">Café</a>
+	<a href="#legacy">Legacy</a>
+	<a href="#pricing">Pricing again</a>
+	<a href="#Intro">Wrong case</a>
+	<a href="#">Top</a>
+	<a href="#top">Back to top</a>
+	<a href="/about#team">Team</a>
+</nav>
+<section id="intro"><h1>Intro</h1></section>
+<section id="café"><h2>Café</h2></section>
+<a name="legacy"></a>
+<div id="faq-section"></div>
+</body></html>`
+
+func TestAnalyzer_BrokenAnchors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		checkAnchors  bool
+		expectedLinks []string
+	}{
+		{
+			name:         "Enabled",
+			checkAnchors: true,
+			// IDs are case-sensitive, and links to other pages are not checked
+			expectedLinks: []string{"#pricing", "#faq", "#Intro"},
+		},
+		{
+			name:         "Disabled",
+			checkAnchors: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.Load()
+			cfg.Analysis.CheckAnchors = tc.checkAnchors
+			analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)), WithConfig(cfg))
+
+			doc, err := html.Parse(strings.NewReader(anchorsPage))
+			require.NoError(t, err)
+
+			result := &AnalysisResult{baseURL: "https://example.com", headings: make(map[string]int)}
+			analyzer.traverseNode(doc, result)
+			analyzer.findBrokenAnchors(result)
+
+			built := result.snapshot()
+			assert.Equal(t, tc.expectedLinks, built.BrokenAnchors)
+			assert.Equal(t, len(tc.expectedLinks), built.BrokenAnchorCount)
+			assert.Equal(t, []string{"https://example.com/about#team"}, built.Links, "fragment links are not verified")
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package analyzer
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"shared/models"
+	"strconv"
+	"strings"
+)
+
+// tinyAssetBytes is the size below which an asset is flagged, a download this small is usually an error page
+// or a placeholder served with the asset's content type
+const tinyAssetBytes = 1024
+
+// assetInfo describes a link pointing at a file to download rather than a web page
+type assetInfo struct {
+	contentType string
+	// contentLength is the size of the file in bytes, -1 when the server did not report it
+	contentLength int64
+}
+
+// assetOf returns the asset a successful response serves, nil when it serves a web page or its type is unknown.
+// A ranged GET only transfers the first byte, the size of the file is then taken from its Content-Range.
+func assetOf(resp *http.Response) *assetInfo {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+		return nil
+	}
+
+	asset := &assetInfo{contentType: mediaType, contentLength: resp.ContentLength}
+	if resp.StatusCode == http.StatusPartialContent {
+		asset.contentLength = rangeTotal(resp.Header.Get("Content-Range"))
+	}
+	return asset
+}
+
+// rangeTotal returns the complete length of a Content-Range such as "bytes 0-0/5120", -1 when it is unknown
+func rangeTotal(contentRange string) int64 {
+	_, total, ok := strings.Cut(contentRange, "/")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(total, 10, 64)
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
+
+// describe summarizes the asset for its subtask, such as "application/pdf, 1.2 MiB"
+func (a assetInfo) describe() string {
+	if a.contentLength < 0 {
+		return a.contentType + ", size unknown"
+	}
+	desc := a.contentType + ", " + formatBytes(a.contentLength)
+	if a.contentLength < tinyAssetBytes {
+		desc += ", suspiciously small"
+	}
+	return desc
+}
+
+// formatBytes formats a size with binary units, such as 512 B or 1.2 MiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 4; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTP"[exp])
+}
+
+// recordAsset adds the type and size of an asset to the subtask of its link and counts it on the result
+func (s *Analyzer) recordAsset(subTask *models.SubTask, asset assetInfo, result *AnalysisResult) {
+	subTask.ContentType = asset.contentType
+	if asset.contentLength >= 0 {
+		length := asset.contentLength
+		subTask.ContentLength = &length
+	}
+	subTask.Description += " (" + asset.describe() + ")"
+
+	result.countAsset(asset.contentType)
+}
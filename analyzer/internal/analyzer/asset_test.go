@@ -0,0 +1,179 @@
+package analyzer
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"shared/models"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assetRoundTripper answers each path with its headers, HEAD requests get no body
+type assetRoundTripper struct {
+	headers map[string]http.Header
+}
+
+func (m *assetRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	header := m.headers[req.URL.Path].Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	contentLength := int64(-1)
+	if n, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		contentLength = n
+	}
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader("")),
+		ContentLength: contentLength,
+		Request:       req,
+	}, nil
+}
+
+func TestAssetOf(t *testing.T) {
+	testCases := []struct {
+		name          string
+		statusCode    int
+		header        http.Header
+		contentLength int64
+		expected      *assetInfo
+		expectedDesc  string
+	}{
+		{
+			name:          "PDFWithLength",
+			statusCode:    http.StatusOK,
+			header:        http.Header{"Content-Type": {"application/pdf"}},
+			contentLength: 1258291,
+			expected:      &assetInfo{contentType: "application/pdf", contentLength: 1258291},
+			expectedDesc:  "application/pdf, 1.2 MiB",
+		},
+		{
+			name:          "PDFWithoutLength",
+			statusCode:    http.StatusOK,
+			header:        http.Header{"Content-Type": {"application/pdf"}},
+			contentLength: -1,
+			expected:      &assetInfo{contentType: "application/pdf", contentLength: -1},
+			expectedDesc:  "application/pdf, size unknown",
+		},
+		{
+			name:          "TinyAsset",
+			statusCode:    http.StatusOK,
+			header:        http.Header{"Content-Type": {"application/zip"}},
+			contentLength: 200,
+			expected:      &assetInfo{contentType: "application/zip", contentLength: 200},
+			expectedDesc:  "application/zip, 200 B, suspiciously small",
+		},
+		{
+			name:          "RangedGET",
+			statusCode:    http.StatusPartialContent,
+			header:        http.Header{"Content-Type": {"application/pdf"}, "Content-Range": {"bytes 0-0/5120"}},
+			contentLength: 1,
+			expected:      &assetInfo{contentType: "application/pdf", contentLength: 5120},
+			expectedDesc:  "application/pdf, 5.0 KiB",
+		},
+		{
+			name:          "RangedGETUnknownTotal",
+			statusCode:    http.StatusPartialContent,
+			header:        http.Header{"Content-Type": {"application/pdf"}, "Content-Range": {"bytes 0-0/*"}},
+			contentLength: 1,
+			expected:      &assetInfo{contentType: "application/pdf", contentLength: -1},
+			expectedDesc:  "application/pdf, size unknown",
+		},
+		{
+			name:          "HTML",
+			statusCode:    http.StatusOK,
+			header:        http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+			contentLength: 200,
+		},
+		{
+			name:          "XHTML",
+			statusCode:    http.StatusOK,
+			header:        http.Header{"Content-Type": {"application/xhtml+xml"}},
+			contentLength: 200,
+		},
+		{
+			name:          "NoContentType",
+			statusCode:    http.StatusOK,
+			header:        http.Header{},
+			contentLength: 200,
+		},
+		{
+			name:          "NotFound",
+			statusCode:    http.StatusNotFound,
+			header:        http.Header{"Content-Type": {"application/pdf"}},
+			contentLength: 200,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			asset := assetOf(&http.Response{StatusCode: tc.statusCode, Header: tc.header, ContentLength: tc.contentLength})
+			assert.Equal(t, tc.expected, asset)
+			if tc.expected != nil {
+				assert.Equal(t, tc.expectedDesc, asset.describe())
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "0 B", formatBytes(0))
+	assert.Equal(t, "1023 B", formatBytes(1023))
+	assert.Equal(t, "1.0 KiB", formatBytes(1024))
+	assert.Equal(t, "1.5 MiB", formatBytes(3*1024*1024/2))
+	assert.Equal(t, "2.0 GiB", formatBytes(2*1024*1024*1024))
+}
+
+func TestAnalyzer_VerifyLinks_Assets(t *testing.T) {
+	analyzer, _, ctrl, subTasks := setupMockAnalyzer(t, "", "https://example.com")
+	defer ctrl.Finish()
+
+	WithHTTPClient(&http.Client{Transport: &assetRoundTripper{headers: map[string]http.Header{
+		"/report.pdf":  {"Content-Type": {"application/pdf"}, "Content-Length": {"1258291"}},
+		"/stream.pdf":  {"Content-Type": {"application/pdf"}},
+		"/archive.zip": {"Content-Type": {"application/zip"}, "Content-Length": {"200"}},
+		"/about":       {"Content-Type": {"text/html; charset=utf-8"}, "Content-Length": {"4096"}},
+	}}})(analyzer)
+
+	result := &AnalysisResult{baseURL: "https://example.com", links: []string{
+		"https://example.com/report.pdf",
+		"https://example.com/stream.pdf",
+		"https://example.com/archive.zip",
+		"https://example.com/about",
+	}}
+	require.NoError(t, analyzer.verifyLinks(context.Background(), "test-job-id", result))
+
+	final := make(map[string]models.SubTask)
+	for _, st := range *subTasks {
+		if st.SubTask.Status == models.TaskStatusCompleted {
+			final[st.SubTask.URL] = st.SubTask
+		}
+	}
+	require.Len(t, final, 4)
+
+	length := int64(1258291)
+	assert.Equal(t, "application/pdf", final["https://example.com/report.pdf"].ContentType)
+	assert.Equal(t, &length, final["https://example.com/report.pdf"].ContentLength)
+	assert.Equal(t, "HTTP 200: OK (application/pdf, 1.2 MiB)", final["https://example.com/report.pdf"].Description)
+
+	assert.Equal(t, "application/pdf", final["https://example.com/stream.pdf"].ContentType)
+	assert.Nil(t, final["https://example.com/stream.pdf"].ContentLength)
+	assert.Equal(t, "HTTP 200: OK (application/pdf, size unknown)", final["https://example.com/stream.pdf"].Description)
+
+	assert.Equal(t, "HTTP 200: OK (application/zip, 200 B, suspiciously small)", final["https://example.com/archive.zip"].Description)
+
+	about := final["https://example.com/about"]
+	assert.Equal(t, "HTTP 200: OK", about.Description, "links to web pages should be reported as before")
+	assert.Empty(t, about.ContentType)
+	assert.Nil(t, about.ContentLength)
+
+	built := result.snapshot()
+	assert.Equal(t, map[string]int{"application/pdf": 2, "application/zip": 1}, built.AssetLinks)
+	assert.Equal(t, 4, built.AccessibleLinks)
+}
@@ -0,0 +1,180 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"shared/models"
+	"sync/atomic"
+	"time"
+)
+
+// budgetSamplingPoint is the share of the time budget spent before only a sample of the links left is verified
+const budgetSamplingPoint = 0.7
+
+// budgetSafetyFactor scales down the number of links expected to be verified in the rest of the budget,
+// leaving room for slower links than those verified so far
+const budgetSafetyFactor = 0.8
+
+// budgetSkippedDescription describes the subtask of a link left out of the sample verified under the time budget
+const budgetSkippedDescription = "not verified (time budget)"
+
+// linkStratum groups the links sampled together, each stratum is sampled in proportion to its size
+type linkStratum int
+
+const (
+	stratumInternal linkStratum = iota
+	stratumExternal
+	stratumImage
+	numStrata
+)
+
+// linkSampler is the sample of the links left when the time budget ran short.
+// It is built once, before any of those links is queued, and only its counters change afterwards.
+type linkSampler struct {
+	// keep holds the positions, among the links left, of those in the sample
+	keep     map[int]bool
+	fraction float64
+	// total is the number of links left per stratum, accessible and inaccessible the outcomes of those verified
+	total        [numStrata]int
+	accessible   [numStrata]atomic.Int32
+	inaccessible [numStrata]atomic.Int32
+}
+
+// newLinkSampler samples capacity of the remaining tasks, in proportion to the size of each stratum.
+// Every stratum with links keeps at least one of them, so each one has an accessibility rate to extrapolate.
+func newLinkSampler(remaining []linkTask, capacity int, rng *rand.Rand) *linkSampler {
+	sampler := &linkSampler{keep: make(map[int]bool)}
+
+	var positions [numStrata][]int
+	for i, task := range remaining {
+		positions[task.stratum] = append(positions[task.stratum], i)
+		sampler.total[task.stratum]++
+	}
+
+	fraction := min(1, float64(capacity)/float64(len(remaining)))
+	kept := 0
+	for _, stratum := range positions {
+		if len(stratum) == 0 {
+			continue
+		}
+		n := min(len(stratum), max(1, int(math.Round(float64(len(stratum))*fraction))))
+		for _, i := range rng.Perm(len(stratum))[:n] {
+			sampler.keep[stratum[i]] = true
+		}
+		kept += n
+	}
+	sampler.fraction = float64(kept) / float64(len(remaining))
+
+	return sampler
+}
+
+// record counts the outcome of a link of the sample
+func (ls *linkSampler) record(stratum linkStratum, accessible bool) {
+	if accessible {
+		ls.accessible[stratum].Add(1)
+	} else {
+		ls.inaccessible[stratum].Add(1)
+	}
+}
+
+// estimate extrapolates the accessibility of the internal and external links verified in the sample to those that
+// were not, returning the number of those expected to be accessible and inaccessible.
+// A stratum of which no link could be verified adds nothing.
+func (ls *linkSampler) estimate() (accessible, inaccessible int) {
+	var accessibleShare, inaccessibleShare float64
+	for _, stratum := range []linkStratum{stratumInternal, stratumExternal} {
+		ok := int(ls.accessible[stratum].Load())
+		failed := int(ls.inaccessible[stratum].Load())
+		verified := ok + failed
+		if verified == 0 {
+			continue
+		}
+		unverified := float64(ls.total[stratum] - verified)
+		accessibleShare += unverified * float64(ok) / float64(verified)
+		inaccessibleShare += unverified * float64(failed) / float64(verified)
+	}
+	return int(math.Round(accessibleShare)), int(math.Round(inaccessibleShare))
+}
+
+// timeBudget returns how long a job aims to take from the page fetch on, zero for no budget
+func (s *Analyzer) timeBudget() time.Duration {
+	if s.cfg == nil {
+		return 0
+	}
+	return s.cfg.Analysis.TimeBudget
+}
+
+// budgetLeft returns the time left of the job's budget, false when there is no budget
+func (s *Analyzer) budgetLeft(result *AnalysisResult) (time.Duration, bool) {
+	budget := s.timeBudget()
+	if budget <= 0 || result.budgetStart.IsZero() {
+		return 0, false
+	}
+	return budget - time.Since(result.budgetStart), true
+}
+
+// samplingDue reports whether enough of the job's budget is spent for only a sample of the links left to be verified
+func (s *Analyzer) samplingDue(result *AnalysisResult) bool {
+	left, ok := s.budgetLeft(result)
+	return ok && left <= time.Duration(float64(s.timeBudget())*(1-budgetSamplingPoint))
+}
+
+// samplingRand returns the source of the sample, seeded by WithSamplingSeed when set
+func (s *Analyzer) samplingRand() *rand.Rand {
+	seed := rand.Uint64()
+	if s.samplingSeed != nil {
+		seed = *s.samplingSeed
+	}
+	return rand.New(rand.NewPCG(seed, 0))
+}
+
+// sampleCapacity estimates how many more links can be verified before the budget ends, from the rate of those
+// verified since verifyStart. The links queued but not finished yet are taken off, they take up that time first.
+// Before any link finished there is no rate, and a round of the workers is assumed.
+func (s *Analyzer) sampleCapacity(result *AnalysisResult, verifyStart time.Time, queued, workers int) int {
+	done := result.counts.sum(countAccessibleLinks, countInaccessibleLinks, countAccessibleImages, countInaccessibleImages)
+	elapsed := time.Since(verifyStart)
+	if done == 0 || elapsed <= 0 {
+		return workers
+	}
+
+	left, _ := s.budgetLeft(result)
+	rate := float64(done) / elapsed.Seconds()
+	capacity := int(rate*max(0, left.Seconds())*budgetSafetyFactor) - (queued - done)
+	return max(0, capacity)
+}
+
+// startSampling samples the remaining links once the budget ran short, warning that the result is an estimate
+func (s *Analyzer) startSampling(jobID string, result *AnalysisResult, remaining []linkTask, capacity int) {
+	result.sampler = newLinkSampler(remaining, capacity, s.samplingRand())
+	result.warnings = append(result.warnings, models.Warning{
+		Code: models.WarningSampledVerification,
+		Message: fmt.Sprintf("The time budget ran short, %.0f%% of the %d links left were verified and the rest estimated",
+			result.sampler.fraction*100, len(remaining)),
+	})
+
+	s.log.Info("Sampling link verification to stay within the time budget",
+		"jobId", jobID,
+		"remainingLinks", len(remaining),
+		"capacity", capacity,
+		"sampleFraction", result.sampler.fraction)
+}
+
+// skipBudgetTask marks a link skipped because it was left out of the sample or the budget ended before its turn,
+// counting it as unverified
+func (s *Analyzer) skipBudgetTask(ctx context.Context, jobID string, task linkTask, result *AnalysisResult) {
+	s.addSubTask(ctx, jobID, models.TaskTypeVerifyingLinks, task.key, models.SubTask{
+		Type:        task.subTaskType,
+		Status:      models.TaskStatusSkipped,
+		URL:         task.link,
+		Description: budgetSkippedDescription,
+	})
+
+	if task.subTaskType == models.SubTaskTypeValidatingImage {
+		result.counts.inc(countUnverifiedImages)
+	} else {
+		result.counts.inc(countUnverifiedLinks)
+	}
+}
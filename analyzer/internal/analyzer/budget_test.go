@@ -0,0 +1,144 @@
+package analyzer
+
+import (
+	"analyzer/internal/config"
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"shared/models"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLinkSampler(t *testing.T) {
+	var remaining []linkTask
+	for i := range 60 {
+		remaining = append(remaining, linkTask{link: "https://example.com/" + strconv.Itoa(i), stratum: stratumInternal})
+	}
+	for i := range 30 {
+		remaining = append(remaining, linkTask{link: "https://other.com/" + strconv.Itoa(i), stratum: stratumExternal})
+	}
+	remaining = append(remaining, linkTask{link: "https://example.com/logo.png", stratum: stratumImage})
+
+	sampler := newLinkSampler(remaining, 30, rand.New(rand.NewPCG(42, 0)))
+
+	kept := [numStrata]int{}
+	for i := range sampler.keep {
+		kept[remaining[i].stratum]++
+	}
+	assert.Equal(t, 20, kept[stratumInternal], "strata should be sampled in proportion to their size")
+	assert.Equal(t, 10, kept[stratumExternal])
+	assert.Equal(t, 1, kept[stratumImage], "a stratum should keep at least one link")
+	assert.InDelta(t, 31.0/91, sampler.fraction, 1e-9)
+
+	again := newLinkSampler(remaining, 30, rand.New(rand.NewPCG(42, 0)))
+	assert.Equal(t, sampler.keep, again.keep, "the same seed should sample the same links")
+
+	all := newLinkSampler(remaining, 500, rand.New(rand.NewPCG(42, 0)))
+	assert.Len(t, all.keep, len(remaining))
+	assert.Equal(t, 1.0, all.fraction)
+}
+
+func TestLinkSampler_Estimate(t *testing.T) {
+	sampler := &linkSampler{}
+	sampler.total[stratumInternal] = 100
+	sampler.total[stratumExternal] = 40
+	sampler.total[stratumImage] = 10
+
+	// 10 internal links verified, 8 accessible: the 90 others are expected to be 72 accessible and 18 not
+	for i := range 10 {
+		sampler.record(stratumInternal, i < 8)
+	}
+	// 4 external links verified, all inaccessible
+	for range 4 {
+		sampler.record(stratumExternal, false)
+	}
+	// Images are sampled but not estimated
+	sampler.record(stratumImage, true)
+
+	accessible, inaccessible := sampler.estimate()
+	assert.Equal(t, 72, accessible)
+	assert.Equal(t, 18+36, inaccessible)
+
+	// A stratum without verified links has no rate to extrapolate
+	accessible, inaccessible = (&linkSampler{total: [numStrata]int{50, 0, 0}}).estimate()
+	assert.Zero(t, accessible)
+	assert.Zero(t, inaccessible)
+}
+
+func TestAnalyzer_VerifyLinks_TimeBudget(t *testing.T) {
+	analyzer, _, ctrl, subTasks := setupMockAnalyzer(t, "", "https://example.com")
+	defer ctrl.Finish()
+
+	const budget = 500 * time.Millisecond
+	cfg := config.Load()
+	cfg.HTTP.MaxConcurrent = 2
+	cfg.Analysis.TimeBudget = budget
+	WithConfig(cfg)(analyzer)
+	WithSamplingSeed(7)(analyzer)
+	WithHTTPClient(&http.Client{Transport: &latencyRoundTripper{
+		latency: 20 * time.Millisecond,
+		next:    &MockHTTPRoundTripper{statusCode: http.StatusOK},
+	}})(analyzer)
+
+	// 200 links at 20ms each over 2 workers take 2s, four times the budget
+	result := &AnalysisResult{baseURL: "https://example.com", budgetStart: time.Now()}
+	for i := range 150 {
+		result.links = append(result.links, "https://example.com/page-"+strconv.Itoa(i))
+	}
+	for i := range 50 {
+		result.links = append(result.links, "https://other.com/page-"+strconv.Itoa(i))
+	}
+	require.NoError(t, analyzer.verifyLinks(context.Background(), "test-job-id", result))
+	elapsed := time.Since(result.budgetStart)
+
+	assert.Less(t, elapsed, budget+150*time.Millisecond, "verification should end close to the budget")
+	require.NotNil(t, result.sampler)
+
+	built := result.snapshot()
+	assert.True(t, built.SampledVerification)
+	assert.True(t, built.PartialResult)
+	assert.Greater(t, built.SampleFraction, 0.0)
+	assert.Less(t, built.SampleFraction, 1.0)
+	assert.Positive(t, built.UnverifiedLinks)
+	assert.Equal(t, 200, built.AccessibleLinks+built.UnverifiedLinks)
+	assert.Equal(t, 200, built.EstimatedAccessibleLinks, "every link sampled was accessible")
+	assert.Zero(t, built.EstimatedInaccessibleLinks)
+	require.Len(t, built.Warnings, 1)
+	assert.Equal(t, models.WarningSampledVerification, built.Warnings[0].Code)
+
+	skipped := 0
+	for _, st := range *subTasks {
+		if st.SubTask.Status == models.TaskStatusSkipped {
+			assert.Equal(t, budgetSkippedDescription, st.SubTask.Description)
+			skipped++
+		}
+	}
+	assert.Equal(t, built.UnverifiedLinks, skipped)
+}
+
+func TestAnalyzer_VerifyLinks_WithinTimeBudget(t *testing.T) {
+	analyzer, _, ctrl, _ := setupMockAnalyzer(t, "", "https://example.com")
+	defer ctrl.Finish()
+
+	cfg := config.Load()
+	cfg.Analysis.TimeBudget = time.Minute
+	WithConfig(cfg)(analyzer)
+	WithHTTPClient(&http.Client{Transport: &MockHTTPRoundTripper{statusCode: http.StatusOK}})(analyzer)
+
+	result := &AnalysisResult{baseURL: "https://example.com", budgetStart: time.Now()}
+	for i := range 20 {
+		result.links = append(result.links, "https://example.com/page-"+strconv.Itoa(i))
+	}
+	require.NoError(t, analyzer.verifyLinks(context.Background(), "test-job-id", result))
+
+	built := result.snapshot()
+	assert.False(t, built.SampledVerification)
+	assert.Equal(t, 20, built.AccessibleLinks)
+	assert.Zero(t, built.EstimatedAccessibleLinks)
+	assert.Empty(t, built.Warnings)
+}
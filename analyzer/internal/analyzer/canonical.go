@@ -0,0 +1,94 @@
+package analyzer
+
+import (
+	"fmt"
+	"net/url"
+	"shared/models"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// extractCanonical records the href of the page's first <link rel="canonical">, later ones are ignored as
+// search engines do
+func (s *Analyzer) extractCanonical(n *html.Node, result *AnalysisResult) {
+	if result.canonical != "" {
+		return
+	}
+
+	for _, rel := range strings.Fields(s.getElementAttribute(n, "rel")) {
+		if strings.EqualFold(rel, "canonical") {
+			result.canonical = strings.TrimSpace(s.getElementAttribute(n, "href"))
+			return
+		}
+	}
+}
+
+// extractOpenGraphURL records the content of the page's first <meta property="og:url">
+func (s *Analyzer) extractOpenGraphURL(n *html.Node, result *AnalysisResult) {
+	if result.openGraphURL == "" && s.getElementAttribute(n, "property") == "og:url" {
+		result.openGraphURL = strings.TrimSpace(s.getElementAttribute(n, "content"))
+	}
+}
+
+// checkCanonical resolves the canonical URL and og:url against the URL the page was served from, after redirects,
+// and checks they name that URL. A canonical pointing to another URL is flagged with a warning, search engines
+// then index that URL in place of the page.
+func (s *Analyzer) checkCanonical(result *models.AnalyzeResult, pageURL string) {
+	page, err := url.Parse(pageURL)
+	if pageURL == "" || err != nil {
+		return
+	}
+
+	canonical := resolveAgainst(page, &result.CanonicalURL)
+	openGraph := resolveAgainst(page, &result.OpenGraphURL)
+	consistency := canonicalConsistency(page, result.CanonicalURL, canonical, result.OpenGraphURL, openGraph)
+	result.CanonicalConsistency = &consistency
+
+	if result.CanonicalURL == "" || canonical != nil && normalizeURL(canonical) == normalizeURL(page) {
+		return
+	}
+	result.CanonicalMismatch = true
+	result.Warnings = append(result.Warnings, models.Warning{
+		Code:    models.WarningCanonicalMismatch,
+		Message: fmt.Sprintf("The canonical URL %s is not the page's URL %s, search engines will index it instead", result.CanonicalURL, pageURL),
+	})
+}
+
+// resolveAgainst resolves the URL ref points to against the page URL, replacing it with the absolute form.
+// It returns nil when ref is empty or cannot be parsed, leaving it as it is.
+func resolveAgainst(page *url.URL, ref *string) *url.URL {
+	if *ref == "" {
+		return nil
+	}
+	resolved, err := page.Parse(*ref)
+	if err != nil {
+		return nil
+	}
+	*ref = resolved.String()
+	return resolved
+}
+
+// canonicalConsistency compares the canonical URL, then the og:url, with the page URL.
+// An unparsable canonical URL names no page and counts as another URL.
+func canonicalConsistency(page *url.URL, rawCanonical string, canonical *url.URL, rawOpenGraph string, openGraph *url.URL) models.CanonicalConsistency {
+	inconsistent := func(reason models.CanonicalInconsistencyReason) models.CanonicalConsistency {
+		return models.CanonicalConsistency{Reason: reason}
+	}
+
+	switch {
+	case rawCanonical == "":
+		return inconsistent(models.CanonicalReasonMissing)
+	case canonical == nil:
+		return inconsistent(models.CanonicalReasonDifferentURL)
+	case normalizedHost(canonical) != normalizedHost(page):
+		return inconsistent(models.CanonicalReasonDifferentHost)
+	case strings.EqualFold(page.Scheme, "https") && strings.EqualFold(canonical.Scheme, "http"):
+		return inconsistent(models.CanonicalReasonInsecure)
+	case normalizeURL(canonical) != normalizeURL(page):
+		return inconsistent(models.CanonicalReasonDifferentURL)
+	case rawOpenGraph != "" && (openGraph == nil || normalizeURL(openGraph) != normalizeURL(page)):
+		return inconsistent(models.CanonicalReasonOpenGraphMismatch)
+	}
+	return models.CanonicalConsistency{Matches: true}
+}
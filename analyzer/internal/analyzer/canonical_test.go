@@ -0,0 +1,273 @@
+package analyzer
+
+import (
+	"log/slog"
+	"os"
+	"shared/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestAnalyzer_ExtractCanonical(t *testing.T) {
+	testCases := []struct {
+		name     string
+		head     string
+		expected string
+	}{
+		{
+			name:     "Canonical",
+			head:     `<link rel="stylesheet" href="/style.css"><link rel="canonical" href=" /about ">`,
+			expected: "/about",
+		},
+		{
+			name:     "RelTokens",
+			head:     `<link rel="Canonical alternate" href="https://example.com/about">`,
+			expected: "https://example.com/about",
+		},
+		{
+			name:     "FirstWins",
+			head:     `<link rel="canonical" href="/first"><link rel="canonical" href="/second">`,
+			expected: "/first",
+		},
+		{
+			name: "None",
+			head: `<link rel="alternate" hreflang="de" href="/de/about">`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+			doc, err := html.Parse(strings.NewReader("<html><head>" + tc.head + "</head><body></body></html>"))
+			require.NoError(t, err)
+
+			result := &AnalysisResult{baseURL: "https://example.com", headings: make(map[string]int)}
+			analyzer.traverseNode(doc, result)
+
+			assert.Equal(t, tc.expected, result.snapshot().CanonicalURL)
+		})
+	}
+}
+
+func TestAnalyzer_CheckCanonical(t *testing.T) {
+	testCases := []struct {
+		name              string
+		canonical         string
+		pageURL           string
+		expectedCanonical string
+		expectedMismatch  bool
+	}{
+		{
+			name:              "Same",
+			canonical:         "https://example.com/about",
+			pageURL:           "https://example.com/about",
+			expectedCanonical: "https://example.com/about",
+		},
+		{
+			name:              "Relative",
+			canonical:         "/about?lang=en",
+			pageURL:           "https://example.com/about?lang=en",
+			expectedCanonical: "https://example.com/about?lang=en",
+		},
+		{
+			name:              "Normalized",
+			canonical:         "HTTPS://Example.com:443#top",
+			pageURL:           "https://example.com/",
+			expectedCanonical: "https://Example.com:443#top",
+		},
+		{
+			name:              "OtherPage",
+			canonical:         "https://example.com/",
+			pageURL:           "https://example.com/blog/post-1",
+			expectedCanonical: "https://example.com/",
+			expectedMismatch:  true,
+		},
+		{
+			name:              "OtherScheme",
+			canonical:         "http://example.com/about",
+			pageURL:           "https://example.com/about",
+			expectedCanonical: "http://example.com/about",
+			expectedMismatch:  true,
+		},
+		{
+			name:              "TrailingSlash",
+			canonical:         "/about/",
+			pageURL:           "https://example.com/about",
+			expectedCanonical: "https://example.com/about/",
+		},
+		{
+			name:    "NoCanonical",
+			pageURL: "https://example.com/about",
+		},
+		{
+			name:              "Unparsable",
+			canonical:         "https://exa mple.com/%zz",
+			pageURL:           "https://example.com/about",
+			expectedCanonical: "https://exa mple.com/%zz",
+			expectedMismatch:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+			result := models.AnalyzeResult{CanonicalURL: tc.canonical}
+
+			analyzer.checkCanonical(&result, tc.pageURL)
+
+			assert.Equal(t, tc.expectedCanonical, result.CanonicalURL)
+			assert.Equal(t, tc.expectedMismatch, result.CanonicalMismatch)
+			if tc.expectedMismatch {
+				require.Len(t, result.Warnings, 1)
+				assert.Equal(t, models.WarningCanonicalMismatch, result.Warnings[0].Code)
+			} else {
+				assert.Empty(t, result.Warnings)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_ApplyPageDetails_CanonicalAfterRedirect(t *testing.T) {
+	analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+
+	// The page was requested without the trailing slash, its canonical names the URL it was redirected to
+	result := models.AnalyzeResult{CanonicalURL: "/docs/"}
+	analyzer.applyPageDetails(&result, &fetchedPage{
+		redirects: []string{"https://example.com/docs", "https://example.com/docs/"},
+		url:       "https://example.com/docs/",
+	})
+
+	assert.Equal(t, "https://example.com/docs/", result.CanonicalURL)
+	assert.False(t, result.CanonicalMismatch)
+}
+
+func TestCanonicalConsistency(t *testing.T) {
+	testCases := []struct {
+		name      string
+		pageURL   string
+		canonical string
+		ogURL     string
+		expected  models.CanonicalConsistency
+	}{
+		{
+			name:      "Matches",
+			pageURL:   "https://example.com/about",
+			canonical: "https://example.com/about",
+			ogURL:     "https://example.com/about",
+			expected:  models.CanonicalConsistency{Matches: true},
+		},
+		{
+			name:      "NormalizedMatch",
+			pageURL:   "https://Example.com/about/",
+			canonical: "https://example.com:443/about",
+			ogURL:     "/about#top",
+			expected:  models.CanonicalConsistency{Matches: true},
+		},
+		{
+			name:      "NoOpenGraph",
+			pageURL:   "http://example.com:80/",
+			canonical: "http://example.com",
+			expected:  models.CanonicalConsistency{Matches: true},
+		},
+		{
+			name:     "Missing",
+			pageURL:  "https://example.com/about",
+			ogURL:    "https://example.com/about",
+			expected: models.CanonicalConsistency{Reason: models.CanonicalReasonMissing},
+		},
+		{
+			name:      "DifferentHost",
+			pageURL:   "https://example.com/about",
+			canonical: "https://example.org/about",
+			expected:  models.CanonicalConsistency{Reason: models.CanonicalReasonDifferentHost},
+		},
+		{
+			name:      "Subdomain",
+			pageURL:   "https://example.com/about",
+			canonical: "https://www.example.com/about",
+			expected:  models.CanonicalConsistency{Reason: models.CanonicalReasonDifferentHost},
+		},
+		{
+			name:      "InsecureCanonical",
+			pageURL:   "https://example.com/about",
+			canonical: "http://example.com/about",
+			expected:  models.CanonicalConsistency{Reason: models.CanonicalReasonInsecure},
+		},
+		{
+			name:      "SecureCanonicalOnHTTPPage",
+			pageURL:   "http://example.com/about",
+			canonical: "https://example.com/about",
+			expected:  models.CanonicalConsistency{Reason: models.CanonicalReasonDifferentURL},
+		},
+		{
+			name:      "DifferentPath",
+			pageURL:   "https://example.com/blog/post-1",
+			canonical: "/",
+			expected:  models.CanonicalConsistency{Reason: models.CanonicalReasonDifferentURL},
+		},
+		{
+			name:      "DifferentQuery",
+			pageURL:   "https://example.com/products?page=2",
+			canonical: "/products",
+			expected:  models.CanonicalConsistency{Reason: models.CanonicalReasonDifferentURL},
+		},
+		{
+			name:      "UnparsableCanonical",
+			pageURL:   "https://example.com/about",
+			canonical: "https://exa mple.com/%zz",
+			expected:  models.CanonicalConsistency{Reason: models.CanonicalReasonDifferentURL},
+		},
+		{
+			name:      "OpenGraphMismatch",
+			pageURL:   "https://example.com/about",
+			canonical: "https://example.com/about",
+			ogURL:     "https://example.com/",
+			expected:  models.CanonicalConsistency{Reason: models.CanonicalReasonOpenGraphMismatch},
+		},
+		{
+			// The canonical URL is checked first, its reason wins
+			name:      "BothMismatch",
+			pageURL:   "https://example.com/about",
+			canonical: "https://example.org/about",
+			ogURL:     "https://example.com/",
+			expected:  models.CanonicalConsistency{Reason: models.CanonicalReasonDifferentHost},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+			result := models.AnalyzeResult{CanonicalURL: tc.canonical, OpenGraphURL: tc.ogURL}
+
+			analyzer.checkCanonical(&result, tc.pageURL)
+
+			require.NotNil(t, result.CanonicalConsistency)
+			assert.Equal(t, tc.expected, *result.CanonicalConsistency)
+		})
+	}
+}
+
+func TestAnalyzer_CanonicalConsistency_CrossDomainFixture(t *testing.T) {
+	analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+
+	f, err := os.Open("testdata/canonical_cross_domain.html")
+	require.NoError(t, err)
+	defer f.Close()
+	doc, err := html.Parse(f)
+	require.NoError(t, err)
+
+	pageURL := "https://aggregator.example.com/news/article"
+	analysis := &AnalysisResult{baseURL: pageURL, headings: make(map[string]int)}
+	analyzer.traverseNode(doc, analysis)
+	result := analysis.snapshot()
+	analyzer.checkCanonical(&result, pageURL)
+
+	assert.Equal(t, "https://original-news.example.org/2024/05/article", result.CanonicalURL)
+	assert.Equal(t, "https://original-news.example.org/2024/05/article", result.OpenGraphURL)
+	assert.Equal(t, &models.CanonicalConsistency{Reason: models.CanonicalReasonDifferentHost}, result.CanonicalConsistency)
+	assert.True(t, result.CanonicalMismatch)
+}
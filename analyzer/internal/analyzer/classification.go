@@ -0,0 +1,27 @@
+package analyzer
+
+import (
+	"shared/log"
+	"shared/models"
+)
+
+// explainLinks reports whether the reason each link was classified internal or external is recorded
+func (s *Analyzer) explainLinks() bool {
+	return s.cfg != nil && s.cfg.Analysis.ExplainLinks
+}
+
+// isExternalURL determines if a URL is external to the base domain
+func (s *Analyzer) isExternalURL(absoluteURL, baseURL string) bool {
+	external, _ := s.classifyLink(absoluteURL, baseURL)
+	return external
+}
+
+// classifyLink determines if a URL is external to the base domain, along with the rule that decided it.
+// The rule is shared with the API, which classifies the links it exports the same way.
+func (s *Analyzer) classifyLink(absoluteURL, baseURL string) (bool, models.LinkClassificationReason) {
+	external, reason := models.ClassifyLink(absoluteURL, baseURL)
+	if reason == models.LinkReasonUnparsable {
+		s.log.Error("Failed to parse URL for external check", log.URL("url", absoluteURL), log.URL("baseURL", baseURL))
+	}
+	return external, reason
+}
@@ -0,0 +1,135 @@
+package analyzer
+
+import (
+	"analyzer/internal/config"
+	"log/slog"
+	"shared/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestAnalyzer_ClassifyLink(t *testing.T) {
+	testCases := []struct {
+		name             string
+		link             string
+		baseURL          string
+		expectedExternal bool
+		expectedReason   models.LinkClassificationReason
+	}{
+		{
+			name:           "SameOrigin",
+			link:           "https://example.com/about",
+			baseURL:        "https://example.com",
+			expectedReason: models.LinkReasonSameOrigin,
+		},
+		{
+			name:             "NoBaseURL",
+			link:             "https://example.com/about",
+			expectedExternal: true,
+			expectedReason:   models.LinkReasonNoBaseURL,
+		},
+		{
+			name:             "Unparsable",
+			link:             "https://exa mple.com/%zz",
+			baseURL:          "https://example.com",
+			expectedExternal: true,
+			expectedReason:   models.LinkReasonUnparsable,
+		},
+		{
+			name:             "DifferentScheme",
+			link:             "http://example.com/about",
+			baseURL:          "https://example.com",
+			expectedExternal: true,
+			expectedReason:   models.LinkReasonDifferentScheme,
+		},
+		{
+			name:             "DifferentPort",
+			link:             "https://example.com:8443/about",
+			baseURL:          "https://example.com",
+			expectedExternal: true,
+			expectedReason:   models.LinkReasonDifferentPort,
+		},
+		{
+			name:             "Subdomain",
+			link:             "https://blog.example.com/post",
+			baseURL:          "https://example.com",
+			expectedExternal: true,
+			expectedReason:   models.LinkReasonSubdomain,
+		},
+		{
+			name:             "SimilarHost",
+			link:             "https://notexample.com",
+			baseURL:          "https://example.com",
+			expectedExternal: true,
+			expectedReason:   models.LinkReasonDifferentHost,
+		},
+		{
+			name:             "ParentDomain",
+			link:             "https://example.com",
+			baseURL:          "https://www.example.com",
+			expectedExternal: true,
+			expectedReason:   models.LinkReasonDifferentHost,
+		},
+	}
+
+	analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			external, reason := analyzer.classifyLink(tc.link, tc.baseURL)
+			assert.Equal(t, tc.expectedExternal, external)
+			assert.Equal(t, tc.expectedReason, reason)
+			assert.Equal(t, external, analyzer.isExternalURL(tc.link, tc.baseURL))
+		})
+	}
+}
+
+func TestAnalyzer_ExplainLinks(t *testing.T) {
+	const page = `<html><body>
+<a href="/pricing">Pricing</a>
+<a href="https://blog.example.com">Blog</a>
+<a href="http://example.com/legacy">Legacy</a>
+</body></html>`
+
+	testCases := []struct {
+		name     string
+		explain  bool
+		expected []models.LinkClassification
+	}{
+		{
+			name:    "Enabled",
+			explain: true,
+			expected: []models.LinkClassification{
+				{URL: "https://example.com/pricing", Reason: models.LinkReasonSameOrigin},
+				{URL: "https://blog.example.com", External: true, Reason: models.LinkReasonSubdomain},
+				{URL: "http://example.com/legacy", External: true, Reason: models.LinkReasonDifferentScheme},
+			},
+		},
+		{
+			name:    "Disabled",
+			explain: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.Load()
+			cfg.Analysis.ExplainLinks = tc.explain
+			analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)), WithConfig(cfg))
+
+			doc, err := html.Parse(strings.NewReader(page))
+			require.NoError(t, err)
+
+			result := &AnalysisResult{baseURL: "https://example.com", headings: make(map[string]int)}
+			analyzer.traverseNode(doc, result)
+
+			built := result.snapshot()
+			assert.Equal(t, tc.expected, built.LinkClassifications)
+			assert.Equal(t, 1, built.InternalLinkCount)
+			assert.Equal(t, 2, built.ExternalLinkCount)
+		})
+	}
+}
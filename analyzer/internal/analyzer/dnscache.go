@@ -0,0 +1,187 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"shared/metrics"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxDNSCacheTTL caps how long resolved addresses are reused
+	MaxDNSCacheTTL = 5 * time.Minute
+	// minDNSPruneThreshold is the number of cached hosts above which expired entries are dropped
+	minDNSPruneThreshold = 1024
+)
+
+// Resolver looks up the addresses of a host, *net.Resolver implements it
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// DNSCache resolves hosts for outbound connections and reuses the addresses, shared by every analysis in the process.
+// Go's resolver does not expose record TTLs, so addresses are kept for a fixed TTL and hosts that do not exist
+// for a shorter negative TTL. Lookups failing for any other reason, such as a timeout, are not cached.
+type DNSCache struct {
+	resolver    Resolver
+	dialer      *net.Dialer
+	ttl         time.Duration
+	negativeTTL time.Duration
+	metrics     metrics.AnalyzerMetricsInterface
+	now         func() time.Time
+
+	mu             sync.Mutex
+	entries        map[string]dnsEntry
+	pruneThreshold int
+}
+
+// dnsEntry is the outcome of a lookup, kept until expires
+type dnsEntry struct {
+	addrs   []net.IPAddr
+	err     error
+	expires time.Time
+}
+
+// DNSCacheOption configures the DNSCache
+type DNSCacheOption func(*DNSCache)
+
+// WithDNSResolver sets the resolver looking up hosts, net.DefaultResolver by default
+func WithDNSResolver(resolver Resolver) DNSCacheOption {
+	return func(c *DNSCache) {
+		c.resolver = resolver
+	}
+}
+
+// WithDNSDialer sets the dialer connecting to the resolved addresses
+func WithDNSDialer(dialer *net.Dialer) DNSCacheOption {
+	return func(c *DNSCache) {
+		c.dialer = dialer
+	}
+}
+
+// WithDNSMetrics sets the metrics collector counting cache hits and misses
+func WithDNSMetrics(m metrics.AnalyzerMetricsInterface) DNSCacheOption {
+	return func(c *DNSCache) {
+		c.metrics = m
+	}
+}
+
+// NewDNSCache creates a cache keeping addresses for ttl and missing hosts for negativeTTL, both capped at MaxDNSCacheTTL
+func NewDNSCache(ttl, negativeTTL time.Duration, opts ...DNSCacheOption) *DNSCache {
+	c := &DNSCache{
+		resolver:    net.DefaultResolver,
+		dialer:      &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		ttl:         min(ttl, MaxDNSCacheTTL),
+		negativeTTL: min(negativeTTL, MaxDNSCacheTTL),
+		metrics:     metrics.NewNoOpAnalyzerMetrics(),
+		now:         time.Now,
+
+		entries:        make(map[string]dnsEntry),
+		pruneThreshold: minDNSPruneThreshold,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// DialContext connects to address like net.Dialer.DialContext, resolving its host through the cache.
+// The addresses of the host are tried in order until one connects.
+func (c *DNSCache) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return c.dialer.DialContext(ctx, network, address)
+	}
+
+	addrs, err := c.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var dialErr error
+	for _, addr := range addrs {
+		if !matchesNetwork(network, addr.IP) {
+			continue
+		}
+		conn, err := c.dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		dialErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if dialErr == nil {
+		dialErr = &net.AddrError{Err: "no suitable address found", Addr: host}
+	}
+	return nil, dialErr
+}
+
+// lookup returns the addresses of host, from the cache while they are fresh
+func (c *DNSCache) lookup(ctx context.Context, host string) ([]net.IPAddr, error) {
+	key := strings.ToLower(host)
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if ok && c.now().Before(entry.expires) {
+		c.metrics.RecordDNSCacheLookup("hit")
+		return entry.addrs, entry.err
+	}
+	c.metrics.RecordDNSCacheLookup("miss")
+
+	addrs, err := c.resolver.LookupIPAddr(ctx, host)
+	switch {
+	case err == nil:
+		c.store(key, dnsEntry{addrs: addrs, expires: c.now().Add(c.ttl)})
+	case isNotFound(err):
+		c.store(key, dnsEntry{err: err, expires: c.now().Add(c.negativeTTL)})
+	}
+	return addrs, err
+}
+
+// store caches the outcome of a lookup, dropping the expired entries once many hosts are cached
+func (c *DNSCache) store(key string, entry dnsEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.pruneThreshold {
+		now := c.now()
+		for host, cached := range c.entries {
+			if !now.Before(cached.expires) {
+				delete(c.entries, host)
+			}
+		}
+		// The threshold grows with the live entries, so they are not scanned on every insert
+		c.pruneThreshold = max(2*len(c.entries), minDNSPruneThreshold)
+	}
+	c.entries[key] = entry
+}
+
+// isNotFound reports whether a lookup failed because the host does not exist
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// matchesNetwork reports whether ip can be dialed on network, tcp4 and tcp6 only take their own family
+func matchesNetwork(network string, ip net.IP) bool {
+	switch network {
+	case "tcp4", "udp4":
+		return ip.To4() != nil
+	case "tcp6", "udp6":
+		return ip.To4() == nil
+	}
+	return true
+}
@@ -0,0 +1,193 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"shared/metrics"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingResolver answers every host with its addresses, or err, and counts the lookups
+type countingResolver struct {
+	mu      sync.Mutex
+	addrs   []net.IPAddr
+	err     error
+	lookups map[string]int
+}
+
+func (r *countingResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lookups == nil {
+		r.lookups = make(map[string]int)
+	}
+	r.lookups[host]++
+	return r.addrs, r.err
+}
+
+func (r *countingResolver) count(host string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookups[host]
+}
+
+// dnsLookupMetrics counts the cache lookups by outcome
+type dnsLookupMetrics struct {
+	metrics.AnalyzerMetricsInterface
+	mu       sync.Mutex
+	outcomes map[string]int
+}
+
+func (m *dnsLookupMetrics) RecordDNSCacheLookup(outcome string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outcomes[outcome]++
+}
+
+// newTestDNSCache returns a cache answering from resolver, with a clock the test moves forward
+func newTestDNSCache(resolver Resolver, ttl, negativeTTL time.Duration) (*DNSCache, *time.Time) {
+	now := time.Now()
+	cache := NewDNSCache(ttl, negativeTTL, WithDNSResolver(resolver))
+	cache.now = func() time.Time { return now }
+	return cache, &now
+}
+
+func TestDNSCache_DialContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+
+	resolver := &countingResolver{addrs: []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}}
+	m := &dnsLookupMetrics{AnalyzerMetricsInterface: metrics.NewNoOpAnalyzerMetrics(), outcomes: make(map[string]int)}
+	cache, now := newTestDNSCache(resolver, time.Minute, 30*time.Second)
+	cache.metrics = m
+
+	dial := func(address string) {
+		conn, err := cache.DialContext(context.Background(), "tcp", address)
+		require.NoError(t, err)
+		assert.Equal(t, listener.Addr().String(), conn.RemoteAddr().String(), "the resolved address should be dialed")
+		conn.Close()
+	}
+
+	dial(net.JoinHostPort("example.com", port))
+	dial(net.JoinHostPort("EXAMPLE.com", port))
+	assert.Equal(t, 1, resolver.count("example.com"), "the second dial should reuse the cached addresses")
+
+	*now = now.Add(time.Minute)
+	dial(net.JoinHostPort("example.com", port))
+	assert.Equal(t, 2, resolver.count("example.com"), "expired addresses should be looked up again")
+
+	dial(listener.Addr().String())
+	assert.Zero(t, resolver.count("127.0.0.1"), "IP addresses should be dialed without a lookup")
+
+	assert.Equal(t, map[string]int{"hit": 1, "miss": 2}, m.outcomes)
+
+	_, err = cache.DialContext(context.Background(), "tcp6", net.JoinHostPort("example.com", port))
+	var addrErr *net.AddrError
+	assert.ErrorAs(t, err, &addrErr, "an IPv4-only host should not be dialed over tcp6")
+}
+
+func TestDNSCache_LookupFailures(t *testing.T) {
+	testCases := []struct {
+		name            string
+		err             error
+		expectedLookups int
+	}{
+		{
+			name:            "NotFound",
+			err:             &net.DNSError{Err: "no such host", Name: "missing.example", IsNotFound: true},
+			expectedLookups: 1,
+		},
+		{
+			name:            "Timeout",
+			err:             &net.DNSError{Err: "i/o timeout", Name: "missing.example", IsTimeout: true},
+			expectedLookups: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resolver := &countingResolver{err: tc.err}
+			cache, now := newTestDNSCache(resolver, time.Minute, 30*time.Second)
+
+			for range 2 {
+				_, err := cache.DialContext(context.Background(), "tcp", "missing.example:80")
+				assert.True(t, errors.Is(err, tc.err))
+			}
+			assert.Equal(t, tc.expectedLookups, resolver.count("missing.example"))
+
+			*now = now.Add(30 * time.Second)
+			_, err := cache.DialContext(context.Background(), "tcp", "missing.example:80")
+			assert.Error(t, err)
+			assert.Equal(t, tc.expectedLookups+1, resolver.count("missing.example"), "the failure should not outlive the negative TTL")
+		})
+	}
+}
+
+func TestNewDNSCache_CapsTTL(t *testing.T) {
+	cache := NewDNSCache(time.Hour, time.Hour)
+	assert.Equal(t, MaxDNSCacheTTL, cache.ttl)
+	assert.Equal(t, MaxDNSCacheTTL, cache.negativeTTL)
+}
+
+func TestDNSCache_PrunesExpiredEntries(t *testing.T) {
+	resolver := &countingResolver{addrs: []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}}
+	cache, now := newTestDNSCache(resolver, time.Minute, time.Minute)
+	cache.pruneThreshold = 2
+
+	_, err := cache.lookup(context.Background(), "old.example")
+	require.NoError(t, err)
+	*now = now.Add(time.Minute)
+	_, err = cache.lookup(context.Background(), "fresh.example")
+	require.NoError(t, err)
+	_, err = cache.lookup(context.Background(), "new.example")
+	require.NoError(t, err)
+
+	assert.NotContains(t, cache.entries, "old.example")
+	assert.Contains(t, cache.entries, "fresh.example")
+	assert.Contains(t, cache.entries, "new.example")
+}
+
+func TestDNSCache_HTTPTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	resolver := &countingResolver{addrs: []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}}
+	cache, _ := newTestDNSCache(resolver, time.Minute, 30*time.Second)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = cache.DialContext
+	transport.DisableKeepAlives = true
+	client := &http.Client{Transport: transport}
+
+	for range 3 {
+		resp, err := client.Get("http://site.example:" + port + "/")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	}
+	assert.Equal(t, 1, resolver.count("site.example"))
+}
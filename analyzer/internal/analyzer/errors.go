@@ -0,0 +1,84 @@
+package analyzer
+
+import (
+	"errors"
+	"shared/models"
+)
+
+// FetchError is the failure to fetch the page of a job, the site could not be reached or refused the request
+type FetchError struct {
+	Err error
+}
+
+func (e *FetchError) Error() string {
+	return "failed to fetch content: " + e.Err.Error()
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is the error status a page was served with
+type StatusError struct {
+	StatusCode int
+	// Status is the status line, such as 404 Not Found
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return "failed to fetch content: " + e.Status
+}
+
+// fetchStatusOf returns the HTTP status a failed fetch was answered with, zero when no response came back
+func fetchStatusOf(err error) int {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	return 0
+}
+
+// ParseError is the failure to parse the fetched page as HTML
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return "failed to analyze HTML: " + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// RepositoryError is the failure to read or update a job in storage, Op describes what was attempted
+type RepositoryError struct {
+	Op  string
+	Err error
+}
+
+func (e *RepositoryError) Error() string {
+	return "failed to " + e.Op + ": " + e.Err.Error()
+}
+
+func (e *RepositoryError) Unwrap() error {
+	return e.Err
+}
+
+// failureOf classifies the error a job failed with, returning the code and the reason shown to users.
+// Only fetch errors are detailed, the others say nothing users could act on.
+func failureOf(err error) (models.FailureCode, string) {
+	var fetchErr *FetchError
+	var parseErr *ParseError
+	var repoErr *RepositoryError
+	switch {
+	case errors.As(err, &fetchErr):
+		return models.FailureCodeFetch, "The page could not be fetched: " + fetchErr.Err.Error()
+	case errors.As(err, &parseErr):
+		return models.FailureCodeParse, "The page could not be parsed as HTML"
+	case errors.As(err, &repoErr):
+		return models.FailureCodeRepository, "The job could not be saved, try submitting it again later"
+	default:
+		return models.FailureCodeInternal, "The analysis failed unexpectedly"
+	}
+}
@@ -0,0 +1,58 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"shared/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailureOf(t *testing.T) {
+	testCases := []struct {
+		name           string
+		err            error
+		expectedCode   models.FailureCode
+		expectedReason string
+	}{
+		{
+			name:           "Fetch",
+			err:            &FetchError{Err: errors.New("giving up after 3 attempts: failed to fetch content: 503 Service Unavailable")},
+			expectedCode:   models.FailureCodeFetch,
+			expectedReason: "The page could not be fetched: giving up after 3 attempts: failed to fetch content: 503 Service Unavailable",
+		},
+		{
+			name:           "Parse",
+			err:            &ParseError{Err: errors.New("failed to parse HTML: unexpected EOF")},
+			expectedCode:   models.FailureCodeParse,
+			expectedReason: "The page could not be parsed as HTML",
+		},
+		{
+			name:           "Repository",
+			err:            &RepositoryError{Op: "get job", Err: errors.New("ProvisionedThroughputExceededException")},
+			expectedCode:   models.FailureCodeRepository,
+			expectedReason: "The job could not be saved, try submitting it again later",
+		},
+		{
+			name:           "Wrapped",
+			err:            fmt.Errorf("analysis aborted: %w", &FetchError{Err: errors.New("no such host")}),
+			expectedCode:   models.FailureCodeFetch,
+			expectedReason: "The page could not be fetched: no such host",
+		},
+		{
+			name:           "Panic",
+			err:            fmt.Errorf("%w: index out of range", errAnalysisPanicked),
+			expectedCode:   models.FailureCodeInternal,
+			expectedReason: "The analysis failed unexpectedly",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, reason := failureOf(tc.err)
+			assert.Equal(t, tc.expectedCode, code)
+			assert.Equal(t, tc.expectedReason, reason)
+		})
+	}
+}
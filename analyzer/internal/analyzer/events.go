@@ -0,0 +1,129 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"shared/messagebus"
+	"shared/models"
+	"time"
+)
+
+// SubTaskEventGranularity controls which subtask updates are published to the message bus.
+// Subtasks are persisted regardless, only the published events are affected.
+type SubTaskEventGranularity string
+
+const (
+	// SubTaskEventsFull publishes every subtask state change
+	SubTaskEventsFull SubTaskEventGranularity = "full"
+	// SubTaskEventsFinalOnly publishes a subtask only once it reaches a terminal status
+	SubTaskEventsFinalOnly SubTaskEventGranularity = "final-only"
+	// SubTaskEventsSummary publishes no subtask updates, only periodic progress of the parent task
+	SubTaskEventsSummary SubTaskEventGranularity = "summary"
+)
+
+// defaultProgressInterval is how often verification progress is published without a config
+const defaultProgressInterval = time.Second
+
+// ParseSubTaskEventGranularity validates a configured subtask event granularity
+func ParseSubTaskEventGranularity(value string) (SubTaskEventGranularity, error) {
+	switch g := SubTaskEventGranularity(value); g {
+	case SubTaskEventsFull, SubTaskEventsFinalOnly, SubTaskEventsSummary:
+		return g, nil
+	default:
+		return "", fmt.Errorf("invalid subtask event granularity %q, expected %s, %s or %s",
+			value, SubTaskEventsFull, SubTaskEventsFinalOnly, SubTaskEventsSummary)
+	}
+}
+
+// shouldPublishSubTask reports whether the subtask update is published under the active granularity
+func (s *Analyzer) shouldPublishSubTask(subTask models.SubTask) bool {
+	switch s.subTaskEvents {
+	case SubTaskEventsFinalOnly:
+		return subTask.Status.IsTerminal()
+	case SubTaskEventsSummary:
+		return false
+	default:
+		return true
+	}
+}
+
+// publishSubTask publishes the subtask update unless the active granularity suppresses it
+func (s *Analyzer) publishSubTask(ctx context.Context, m messagebus.SubTaskUpdateMessage) error {
+	if !s.isKnownTaskType(m.JobID, models.TaskType(m.TaskType)) {
+		return nil
+	}
+	if !s.shouldPublishSubTask(m.SubTask) {
+		s.metrics.RecordSuppressedSubTaskEvent(string(s.subTaskEvents))
+		return nil
+	}
+	return s.publisher.PublishSubTaskUpdate(ctx, m)
+}
+
+// startProgressReporter periodically publishes how many of the total links have finished verification,
+// along with the running tallies of accessible and inaccessible links. It publishes at most once per
+// progress interval and only when more links finished, and the returned function stops it after
+// publishing the final counts.
+func (s *Analyzer) startProgressReporter(ctx context.Context, jobID string, total int, result *AnalysisResult) func() {
+	finished := func() int {
+		return finishedLinks(result)
+	}
+
+	publish := func(completed int) {
+		if err := s.publisher.PublishTaskStatusUpdate(ctx, messagebus.TaskStatusUpdateMessage{
+			Type:     messagebus.TaskStatusUpdateMessageType,
+			JobID:    jobID,
+			TaskType: string(models.TaskTypeVerifyingLinks),
+			Status:   string(models.TaskStatusRunning),
+			Progress: &messagebus.TaskProgress{
+				Completed:    completed,
+				Total:        total,
+				Accessible:   result.counts.get(countAccessibleLinks),
+				Inaccessible: result.counts.get(countInaccessibleLinks),
+			},
+		}); err != nil {
+			s.log.Error("Failed to publish task progress", "error", err)
+		}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(s.progressInterval())
+		defer ticker.Stop()
+
+		last := -1
+		for {
+			select {
+			case <-stop:
+				publish(finished())
+				return
+			case <-ticker.C:
+				if completed := finished(); completed != last {
+					publish(completed)
+					last = completed
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// progressInterval returns how often verification progress is published
+func (s *Analyzer) progressInterval() time.Duration {
+	if s.cfg != nil && s.cfg.Events.ProgressInterval > 0 {
+		return s.cfg.Events.ProgressInterval
+	}
+	return defaultProgressInterval
+}
+
+// finishedLinks counts the links and images whose verification has finished
+func finishedLinks(result *AnalysisResult) int {
+	return result.counts.sum(countAccessibleLinks, countInaccessibleLinks, countExcludedLinks, countOutOfScopeLinks,
+		countUnverifiedLinks, countAccessibleImages, countInaccessibleImages, countUnverifiedImages)
+}
@@ -0,0 +1,195 @@
+package analyzer
+
+import (
+	"analyzer/internal/config"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"shared/messagebus"
+	"shared/metrics"
+	"shared/mocks"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// suppressionMetrics counts suppressed subtask events
+type suppressionMetrics struct {
+	metrics.NoOpAnalyzerMetrics
+	suppressed atomic.Int32
+}
+
+func (m *suppressionMetrics) RecordSuppressedSubTaskEvent(granularity string) {
+	m.suppressed.Add(1)
+}
+
+func TestParseSubTaskEventGranularity(t *testing.T) {
+	for _, value := range []string{"full", "final-only", "summary"} {
+		granularity, err := ParseSubTaskEventGranularity(value)
+		assert.NoError(t, err)
+		assert.Equal(t, SubTaskEventGranularity(value), granularity)
+	}
+
+	_, err := ParseSubTaskEventGranularity("verbose")
+	assert.Error(t, err)
+}
+
+func TestAnalyzer_SubTaskEventGranularity_Integration(t *testing.T) {
+	// Two verified links publish add, running and final updates, the excluded link a single terminal add.
+	// The progress of the parent task is published under every granularity.
+	testCases := []struct {
+		granularity        SubTaskEventGranularity
+		port               int
+		expectedSubTasks   int
+		expectedSuppressed int
+	}{
+		{granularity: SubTaskEventsFull, port: 8430, expectedSubTasks: 7, expectedSuppressed: 0},
+		{granularity: SubTaskEventsFinalOnly, port: 8431, expectedSubTasks: 3, expectedSuppressed: 4},
+		{granularity: SubTaskEventsSummary, port: 8432, expectedSubTasks: 0, expectedSuppressed: 7},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.granularity), func(t *testing.T) {
+			opts := natsserver.DefaultTestOptions
+			opts.Port = tc.port
+			server := natsserver.RunServer(&opts)
+			defer server.Shutdown()
+
+			nc, err := nats.Connect("nats://127.0.0.1:" + strconv.Itoa(tc.port))
+			require.NoError(t, err, "Should connect to NATS")
+			defer nc.Close()
+
+			subTaskSub, err := nc.SubscribeSync(string(messagebus.SubTaskUpdateMessageType))
+			require.NoError(t, err)
+			taskSub, err := nc.SubscribeSync(string(messagebus.TaskStatusUpdateMessageType))
+			require.NoError(t, err)
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockTaskRepo := mocks.NewMockTaskRepositoryInterface(ctrl)
+			// Persistence is unaffected by the granularity
+			mockTaskRepo.EXPECT().AddSubTaskByKey(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(3)
+			mockTaskRepo.EXPECT().UpdateSubTaskByKey(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(4)
+			mockTaskRepo.EXPECT().UpdateTaskStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+			exclusions, err := CompileExclusionPatterns([]string{`/logout\b`})
+			require.NoError(t, err)
+			m := &suppressionMetrics{}
+			analyzer := NewAnalyzer(nil, mockTaskRepo, messagebus.New(nc, nil),
+				WithHTTPClient(&http.Client{Transport: &MockHTTPRoundTripper{statusCode: http.StatusOK}}),
+				WithLogger(slog.New(slog.DiscardHandler)),
+				WithMetrics(m),
+				WithExclusionPatterns(exclusions),
+				WithSubTaskEventGranularity(tc.granularity),
+			)
+
+			result := &AnalysisResult{links: []string{
+				"https://example.com/about",
+				"https://example.com/logout",
+				"https://example.com/contact",
+			}}
+			require.NoError(t, analyzer.verifyLinks(context.Background(), "test-job-id", result))
+
+			// Messages are delivered before the flush returns since the subscriptions share the connection
+			require.NoError(t, nc.Flush())
+
+			subTasks, _, err := subTaskSub.Pending()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedSubTasks, subTasks)
+			assert.Equal(t, int32(tc.expectedSuppressed), m.suppressed.Load())
+
+			var progress []messagebus.TaskProgress
+			for {
+				msg, err := taskSub.NextMsg(100 * time.Millisecond)
+				if err != nil {
+					break
+				}
+				var update messagebus.TaskStatusUpdateMessage
+				require.NoError(t, json.Unmarshal(msg.Data, &update))
+				if update.Progress != nil {
+					progress = append(progress, *update.Progress)
+				}
+			}
+
+			require.NotEmpty(t, progress)
+			assert.Equal(t, messagebus.TaskProgress{Completed: 3, Total: 3, Accessible: 2}, progress[len(progress)-1])
+		})
+	}
+}
+
+func TestAnalyzer_ProgressTallies_Integration(t *testing.T) {
+	opts := natsserver.DefaultTestOptions
+	opts.Port = 8445
+	server := natsserver.RunServer(&opts)
+	defer server.Shutdown()
+
+	nc, err := nats.Connect("nats://127.0.0.1:8445")
+	require.NoError(t, err, "Should connect to NATS")
+	defer nc.Close()
+	taskSub, err := nc.SubscribeSync(string(messagebus.TaskStatusUpdateMessageType))
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockTaskRepo := mocks.NewMockTaskRepositoryInterface(ctrl)
+	mockTaskRepo.EXPECT().AddSubTaskByKey(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockTaskRepo.EXPECT().UpdateSubTaskByKey(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockTaskRepo.EXPECT().UpdateTaskStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	// Every other link is broken, each taking long enough for several progress updates to go out along the way
+	slow := func(statusCode int) func(req *http.Request) (*http.Response, error) {
+		return func(req *http.Request) (*http.Response, error) {
+			time.Sleep(5 * time.Millisecond)
+			return statusResponse(statusCode, "")(req)
+		}
+	}
+	var responses []func(req *http.Request) (*http.Response, error)
+	var links []string
+	for i := range 20 {
+		responses = append(responses, slow(http.StatusOK), slow(http.StatusNotFound))
+		links = append(links, "https://example.com/ok/"+strconv.Itoa(i), "https://example.com/broken/"+strconv.Itoa(i))
+	}
+
+	cfg := config.Load()
+	cfg.HTTP.MaxConcurrent = 2
+	cfg.Events.ProgressInterval = 10 * time.Millisecond
+	analyzer := NewAnalyzer(nil, mockTaskRepo, messagebus.New(nc, nil),
+		WithHTTPClient(&http.Client{Transport: &sequenceRoundTripper{responses: responses}}),
+		WithLogger(slog.New(slog.DiscardHandler)),
+		WithConfig(cfg),
+	)
+	require.NoError(t, analyzer.verifyLinks(context.Background(), "test-job-id", &AnalysisResult{links: links}))
+	require.NoError(t, nc.Flush())
+
+	var progress []messagebus.TaskProgress
+	for {
+		msg, err := taskSub.NextMsg(100 * time.Millisecond)
+		if err != nil {
+			break
+		}
+		var update messagebus.TaskStatusUpdateMessage
+		require.NoError(t, json.Unmarshal(msg.Data, &update))
+		if update.Progress != nil {
+			progress = append(progress, *update.Progress)
+		}
+	}
+
+	require.Greater(t, len(progress), 2, "tallies are published while links are verified, not only at the end")
+	for i, p := range progress {
+		assert.LessOrEqual(t, p.Accessible+p.Inaccessible, p.Completed)
+		if i > 0 {
+			assert.GreaterOrEqual(t, p.Completed, progress[i-1].Completed)
+			assert.GreaterOrEqual(t, p.Accessible, progress[i-1].Accessible)
+			assert.GreaterOrEqual(t, p.Inaccessible, progress[i-1].Inaccessible)
+		}
+	}
+	assert.Equal(t, messagebus.TaskProgress{Completed: 40, Total: 40, Accessible: 20, Inaccessible: 20}, progress[len(progress)-1])
+}
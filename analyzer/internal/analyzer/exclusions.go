@@ -0,0 +1,40 @@
+package analyzer
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// CompileExclusionPatterns compiles the link exclusion patterns, failing on the first invalid one
+func CompileExclusionPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid link exclusion pattern %q: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// matchExclusion returns the first exclusion pattern matching the link, or an empty string
+func (s *Analyzer) matchExclusion(link string) string {
+	for _, re := range s.exclusions {
+		if re.MatchString(link) {
+			return re.String()
+		}
+	}
+	return ""
+}
+
+// isOwnServiceLink reports whether the link points at one of the platform's own hosts, whose pages lead back
+// into the platform
+func (s *Analyzer) isOwnServiceLink(link string) bool {
+	if s.cfg == nil {
+		return false
+	}
+	u, err := url.Parse(link)
+	return err == nil && s.cfg.Self.IsOwnHost(u.Host)
+}
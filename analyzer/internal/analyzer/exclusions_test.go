@@ -0,0 +1,134 @@
+package analyzer
+
+import (
+	"analyzer/internal/config"
+	"context"
+	"log/slog"
+	"net/http"
+	sharedconfig "shared/config"
+	"shared/models"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileExclusionPatterns_InvalidPattern(t *testing.T) {
+	_, err := CompileExclusionPatterns([]string{`/logout`, `/cart/(add`})
+	assert.ErrorContains(t, err, `invalid link exclusion pattern "/cart/(add"`)
+}
+
+func TestAnalyzer_MatchExclusion_DefaultPatterns(t *testing.T) {
+	exclusions, err := CompileExclusionPatterns(config.Load().Analysis.LinkExcludePatterns)
+	require.NoError(t, err)
+	analyzer := NewAnalyzer(nil, nil, nil, WithExclusionPatterns(exclusions))
+
+	testCases := []struct {
+		link     string
+		excluded bool
+	}{
+		{link: "https://example.com/logout", excluded: true},
+		{link: "https://example.com/account/Sign-Out?next=/", excluded: true},
+		{link: "https://example.com/user/logoff", excluded: true},
+		{link: "https://example.com/newsletter/unsubscribe?token=abc", excluded: true},
+		{link: "https://example.com/mail?unsubscribe=1", excluded: true},
+		{link: "https://example.com/cart/add?id=42", excluded: true},
+		{link: "https://example.com/logoutreasons", excluded: false},
+		{link: "https://example.com/blog/logging-out-of-habits", excluded: false},
+		{link: "https://example.com/cart", excluded: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.link, func(t *testing.T) {
+			assert.Equal(t, tc.excluded, analyzer.matchExclusion(tc.link) != "")
+		})
+	}
+}
+
+func TestAnalyzer_VerifyLinks_SkipsExcludedLinks(t *testing.T) {
+	analyzer, _, ctrl, subTasks := setupMockAnalyzer(t, "", "https://example.com")
+	defer ctrl.Finish()
+
+	exclusions, err := CompileExclusionPatterns([]string{`/logout\b`})
+	require.NoError(t, err)
+	transport := &recordingRoundTripper{next: &MockHTTPRoundTripper{statusCode: http.StatusOK}}
+	WithHTTPClient(&http.Client{Transport: transport})(analyzer)
+	WithExclusionPatterns(exclusions)(analyzer)
+	WithLogger(slog.New(slog.DiscardHandler))(analyzer)
+
+	result := &AnalysisResult{
+		links: []string{
+			"https://example.com/about",
+			"https://example.com/logout",
+			"https://example.com/" + shouldNotBeFound,
+		},
+	}
+	require.NoError(t, analyzer.verifyLinks(context.Background(), "test-job-id", result))
+
+	assert.NotContains(t, transport.urls, "https://example.com/logout", "excluded links must not be requested")
+	assert.Len(t, transport.urls, 2)
+
+	built := result.snapshot()
+	assert.Len(t, built.Links, 3, "excluded links are still listed")
+	assert.Equal(t, 1, built.ExcludedLinks)
+	assert.Equal(t, 1, built.AccessibleLinks)
+	assert.Equal(t, 1, built.InaccessibleLinks)
+
+	var excluded []models.SubTask
+	for _, c := range *subTasks {
+		if c.SubTask.URL == "https://example.com/logout" {
+			excluded = append(excluded, c.SubTask)
+		}
+	}
+	require.Len(t, excluded, 1, "excluded link should get a single subtask")
+	assert.Equal(t, models.TaskStatusSkipped, excluded[0].Status)
+	assert.Equal(t, `Excluded by pattern /logout\b`, excluded[0].Description)
+}
+
+func TestAnalyzer_VerifyLinks_SkipsOwnServiceLinks(t *testing.T) {
+	analyzer, _, ctrl, subTasks := setupMockAnalyzer(t, "", "https://example.com")
+	defer ctrl.Finish()
+
+	transport := &recordingRoundTripper{next: &MockHTTPRoundTripper{statusCode: http.StatusOK}}
+	WithHTTPClient(&http.Client{Transport: transport})(analyzer)
+	WithConfig(&config.Config{Self: sharedconfig.SelfProtectionConfig{OwnHosts: []string{"analyzer.example.net"}}})(analyzer)
+	WithLogger(slog.New(slog.DiscardHandler))(analyzer)
+
+	result := &AnalysisResult{
+		links: []string{
+			"https://example.com/about",
+			"https://Analyzer.Example.NET/jobs/42",
+			"https://analyzer.example.net:8080/analyze",
+		},
+	}
+	require.NoError(t, analyzer.verifyLinks(context.Background(), "test-job-id", result))
+
+	assert.Equal(t, []string{"https://example.com/about"}, transport.urls, "links to the platform must not be requested")
+	assert.Equal(t, 2, result.snapshot().ExcludedLinks)
+
+	var skipped []models.SubTask
+	for _, c := range *subTasks {
+		if c.SubTask.Status == models.TaskStatusSkipped {
+			skipped = append(skipped, c.SubTask)
+		}
+	}
+	require.Len(t, skipped, 2)
+	for _, st := range skipped {
+		assert.Equal(t, "Own service excluded", st.Description)
+	}
+}
+
+// recordingRoundTripper records requested URLs before passing them on
+type recordingRoundTripper struct {
+	next http.RoundTripper
+	mu   sync.Mutex
+	urls []string
+}
+
+func (r *recordingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	r.urls = append(r.urls, req.URL.String())
+	r.mu.Unlock()
+	return r.next.RoundTrip(req)
+}
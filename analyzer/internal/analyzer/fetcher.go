@@ -1,37 +1,310 @@
 package analyzer
 
 import (
+	"analyzer/internal/config"
+	"compress/flate"
+	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"shared/log"
+	"shared/models"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
-// fetchContent fetches HTML content from a URL
-func (s *Analyzer) fetchContent(ctx context.Context, url string) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// Content fetch attempt outcomes reported to metrics
+const (
+	fetchOutcomeSuccess   = "success"
+	fetchOutcomeRetryable = "retryable"
+	fetchOutcomeFailed    = "failed"
+)
+
+// maxHeaderValueLength bounds each captured header value to keep job items small
+const maxHeaderValueLength = 1024
+
+// defaultMaxContentBytes caps the decoded page body when no limit is configured
+const defaultMaxContentBytes = 10 * 1024 * 1024
+
+// acceptedEncodings is sent as the Accept-Encoding of the page fetch.
+// Setting it ourselves turns off the transport's transparent gzip handling, so the decoded size can be bounded here.
+const acceptedEncodings = "gzip"
+
+// errContentTooLarge is returned for a page whose decoded body is larger than the configured limit
+var errContentTooLarge = errors.New("content too large")
+
+// fetchedPage is the outcome of a successful content fetch
+type fetchedPage struct {
+	content string
+	header  http.Header
+	// statusCode is the HTTP status the page was served with
+	statusCode int
+	// encoding is the content coding the body was served with, empty when it was not encoded
+	encoding string
+	// transferredBytes is the size of the body as received, before decoding
+	transferredBytes int64
+	// redirects lists the URLs the page was reached through, nil when it was not redirected
+	redirects []string
+	// url is the URL the page was served from, after any redirects
+	url string
+	// timing breaks down the time taken by the fetch
+	timing *models.FetchTiming
+}
+
+// fetchContent fetches HTML content from a URL, retrying transient failures with backoff
+func (s *Analyzer) fetchContent(ctx context.Context, url string) (*fetchedPage, error) {
+	cfg := s.fetchConfig()
+	attempts := cfg.MaxRetries + 1
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			backoff := retryBackoff(cfg, attempt-1)
+			s.log.Warn("Retrying content fetch",
+				log.URL("url", url),
+				slog.Int("attempt", attempt),
+				slog.Duration("backoff", backoff),
+				log.Error(lastErr))
+
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("content fetch cancelled after %d attempts: %w", attempt-1, lastErr)
+			case <-time.After(backoff):
+			}
+		}
+
+		page, retryable, err := s.fetchOnce(ctx, url)
+		if err == nil {
+			s.metrics.RecordContentFetchAttempt(attempt, fetchOutcomeSuccess)
+			return page, nil
+		}
+
+		lastErr = err
+		if !retryable {
+			s.metrics.RecordContentFetchAttempt(attempt, fetchOutcomeFailed)
+			return nil, err
+		}
+		s.metrics.RecordContentFetchAttempt(attempt, fetchOutcomeRetryable)
+	}
+
+	return nil, fmt.Errorf("giving up after %d attempts: %w", attempts, lastErr)
+}
+
+// fetchOnce performs a single content fetch and reports whether a failure is worth retrying
+func (s *Analyzer) fetchOnce(ctx context.Context, url string) (*fetchedPage, bool, error) {
+	req, err := http.NewRequestWithContext(withRedirectLimit(ctx, s.fetchConfig().MaxRedirects), http.MethodGet, url, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept-Encoding", acceptedEncodings)
+
+	if err := s.waitForHost(ctx, url, "content_fetch"); err != nil {
+		return nil, false, fmt.Errorf("cancelled while waiting for host rate limit: %w", err)
 	}
 
 	start := time.Now()
-	resp, err := s.client.Do(req)
+	timer := newFetchTimer(start)
+	resp, err := s.client.Do(req.WithContext(timer.trace(req.Context())))
 	if err != nil {
-		return "", fmt.Errorf("failed to execute request: %w", err)
+		// Network errors are transient unless the job itself was cancelled, redirects would be refused again
+		var redirectErr *redirectError
+		retryable := ctx.Err() == nil && !errors.Is(err, context.Canceled) && !errors.As(err, &redirectErr)
+		return nil, retryable, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	s.metrics.RecordHTTPClientRequest(resp.StatusCode, time.Since(start).Seconds(), req.Method, "content_fetch")
 
-	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("failed to fetch content: %s", resp.Status)
+	if !isSuccessStatus(resp.StatusCode) && !s.fetchConfig().AnalyzeErrorPages {
+		return nil, isRetryableStatus(resp.StatusCode), &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	page, err := s.readPage(resp)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
+		// A page that is too large or badly encoded will be the same on the next attempt
+		retryable := !errors.Is(err, errContentTooLarge) && !isDecodeError(err)
+		return nil, retryable, err
 	}
 
-	return string(body), nil
+	page.statusCode = resp.StatusCode
+	page.timing = timer.finish(time.Now())
+	page.redirects = redirectChain(resp)
+	page.url = url
+	if resp.Request != nil {
+		page.url = resp.Request.URL.String()
+	}
+
+	s.metrics.RecordContentFetchSize(contentEncodingLabel(page.encoding), page.transferredBytes, int64(len(page.content)))
+	s.recordFetchTiming(page.timing)
+	return page, false, nil
+}
+
+// readPage decodes the response body according to its Content-Encoding,
+// failing with errContentTooLarge once the decoded body passes the size limit
+func (s *Analyzer) readPage(resp *http.Response) (*fetchedPage, error) {
+	wire := &countingReader{r: resp.Body}
+	var body io.Reader = wire
+
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		encoding = ""
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(wire)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode gzip response body: %w", err)
+		}
+		defer zr.Close()
+		body, encoding = zr, "gzip"
+	default:
+		return nil, fmt.Errorf("failed to decode response body: %w: unsupported content encoding %q", errUnsupportedEncoding, encoding)
+	}
+
+	limit := s.maxContentBytes()
+	content, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if int64(len(content)) > limit {
+		return nil, fmt.Errorf("%w: more than %d bytes after reading %d bytes of %s body",
+			errContentTooLarge, limit, wire.n, contentEncodingLabel(encoding))
+	}
+
+	return &fetchedPage{
+		content:          string(content),
+		header:           resp.Header,
+		encoding:         encoding,
+		transferredBytes: wire.n,
+	}, nil
+}
+
+// errUnsupportedEncoding is returned for a body served with a content coding that was not requested
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// isDecodeError reports whether a body read failed because its encoding is invalid rather than the connection
+func isDecodeError(err error) bool {
+	var corrupt flate.CorruptInputError
+	return errors.Is(err, errUnsupportedEncoding) ||
+		errors.Is(err, gzip.ErrHeader) ||
+		errors.Is(err, gzip.ErrChecksum) ||
+		errors.As(err, &corrupt)
+}
+
+// contentEncodingLabel names an encoding for metrics and messages, identity when the body was not encoded
+func contentEncodingLabel(encoding string) string {
+	if encoding == "" {
+		return "identity"
+	}
+	return encoding
+}
+
+// maxContentBytes returns the decoded page size limit
+func (s *Analyzer) maxContentBytes() int64 {
+	if cfg := s.fetchConfig(); cfg.MaxContentBytes > 0 {
+		return int64(cfg.MaxContentBytes)
+	}
+	return defaultMaxContentBytes
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// applyPageDetails records what is known about the fetched page on its result
+func (s *Analyzer) applyPageDetails(result *models.AnalyzeResult, page *fetchedPage) {
+	result.ResponseHeaders = s.captureResponseHeaders(page.header)
+	result.ContentEncoding = page.encoding
+	result.TransferredBytes = page.transferredBytes
+	result.ContentBytes = int64(len(page.content))
+	result.RedirectChain = page.redirects
+	result.FetchTiming = page.timing
+	s.checkCanonical(result, page.url)
+	checkRobotsHeader(result, page.header)
+
+	// Only reached for error pages when they are analyzed, the result is of the error page rather than the page
+	if page.statusCode != 0 && !isSuccessStatus(page.statusCode) {
+		result.Warnings = append(result.Warnings, models.Warning{
+			Code:    models.WarningErrorPage,
+			Message: fmt.Sprintf("The page was served with HTTP %d, the result describes its error page", page.statusCode),
+		})
+	}
+}
+
+// captureResponseHeaders selects the configured response headers of the fetched page.
+// Keys are lower-cased and absent headers are omitted, nil is returned when capture is disabled.
+func (s *Analyzer) captureResponseHeaders(header http.Header) map[string]string {
+	cfg := s.fetchConfig()
+	if !cfg.CaptureHeaders {
+		return nil
+	}
+
+	captured := make(map[string]string)
+	for _, name := range cfg.CapturedHeaders {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+
+		value := strings.Join(values, ", ")
+		captured[strings.ToLower(name)] = truncateUTF8(value, maxHeaderValueLength)
+	}
+
+	return captured
+}
+
+// truncateUTF8 cuts s to at most n bytes, backing off to the start of a rune so no rune is split
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// fetchConfig returns the content fetch configuration, without retries when unconfigured
+func (s *Analyzer) fetchConfig() config.FetchConfig {
+	if s.cfg != nil {
+		return s.cfg.Fetch
+	}
+	return config.FetchConfig{}
+}
+
+// isSuccessStatus reports whether a response status is 2xx
+func isSuccessStatus(statusCode int) bool {
+	return statusCode >= 200 && statusCode < 300
+}
+
+// isRetryableStatus reports whether a response status indicates a transient failure
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// retryBackoff returns the exponential backoff before the given retry (1-based), capped at MaxRetryBackoff
+func retryBackoff(cfg config.FetchConfig, retry int) time.Duration {
+	backoff := cfg.RetryBackoff
+	for i := 1; i < retry; i++ {
+		backoff *= 2
+		if cfg.MaxRetryBackoff > 0 && backoff >= cfg.MaxRetryBackoff {
+			return cfg.MaxRetryBackoff
+		}
+	}
+
+	if cfg.MaxRetryBackoff > 0 && backoff > cfg.MaxRetryBackoff {
+		return cfg.MaxRetryBackoff
+	}
+	return backoff
 }
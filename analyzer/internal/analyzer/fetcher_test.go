@@ -0,0 +1,360 @@
+package analyzer
+
+import (
+	"analyzer/internal/config"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"shared/models"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sequenceRoundTripper replays a fixed sequence of responses, repeating the last one
+type sequenceRoundTripper struct {
+	mu        sync.Mutex
+	responses []func(req *http.Request) (*http.Response, error)
+	calls     int
+}
+
+func (m *sequenceRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := min(m.calls, len(m.responses)-1)
+	m.calls++
+	return m.responses[i](req)
+}
+
+func statusResponse(statusCode int, body string) func(req *http.Request) (*http.Response, error) {
+	return func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: statusCode,
+			Status:     http.StatusText(statusCode),
+			Header:     make(http.Header),
+			Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+			Request:    req,
+		}, nil
+	}
+}
+
+func networkError(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("connection reset by peer")
+}
+
+func TestAnalyzer_FetchContentRetry(t *testing.T) {
+	testCases := []struct {
+		name          string
+		responses     []func(req *http.Request) (*http.Response, error)
+		expectedCalls int
+		expectedBody  string
+		expectedError string
+		// expectedStatus is the status the page was served with, or the failed fetch was answered with
+		expectedStatus    int
+		analyzeErrorPages bool
+	}{
+		{
+			name:           "SuccessFirstAttempt",
+			responses:      []func(req *http.Request) (*http.Response, error){statusResponse(200, "ok")},
+			expectedCalls:  1,
+			expectedBody:   "ok",
+			expectedStatus: 200,
+		},
+		{
+			name:           "RecoversFrom503",
+			responses:      []func(req *http.Request) (*http.Response, error){statusResponse(503, ""), statusResponse(200, "ok")},
+			expectedCalls:  2,
+			expectedBody:   "ok",
+			expectedStatus: 200,
+		},
+		{
+			name:           "RecoversFromNetworkError",
+			responses:      []func(req *http.Request) (*http.Response, error){networkError, statusResponse(429, ""), statusResponse(200, "ok")},
+			expectedCalls:  3,
+			expectedBody:   "ok",
+			expectedStatus: 200,
+		},
+		{
+			name:           "RetriesExhausted",
+			responses:      []func(req *http.Request) (*http.Response, error){statusResponse(502, "")},
+			expectedCalls:  3,
+			expectedError:  "giving up after 3 attempts",
+			expectedStatus: 502,
+		},
+		{
+			name:          "NetworkErrorsExhausted",
+			responses:     []func(req *http.Request) (*http.Response, error){networkError},
+			expectedCalls: 3,
+			expectedError: "giving up after 3 attempts",
+		},
+		{
+			name:           "NonRetryableStatus",
+			responses:      []func(req *http.Request) (*http.Response, error){statusResponse(404, "")},
+			expectedCalls:  1,
+			expectedError:  "failed to fetch content",
+			expectedStatus: 404,
+		},
+		{
+			name:           "NonSuccessStatus",
+			responses:      []func(req *http.Request) (*http.Response, error){statusResponse(300, "choices")},
+			expectedCalls:  1,
+			expectedError:  "failed to fetch content",
+			expectedStatus: 300,
+		},
+		{
+			name:              "AnalyzesErrorPage",
+			responses:         []func(req *http.Request) (*http.Response, error){statusResponse(404, "not found")},
+			expectedCalls:     1,
+			expectedBody:      "not found",
+			expectedStatus:    404,
+			analyzeErrorPages: true,
+		},
+		{
+			name:              "ErrorPageNotRetried",
+			responses:         []func(req *http.Request) (*http.Response, error){statusResponse(503, "maintenance"), statusResponse(200, "ok")},
+			expectedCalls:     1,
+			expectedBody:      "maintenance",
+			expectedStatus:    503,
+			analyzeErrorPages: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			transport := &sequenceRoundTripper{responses: tc.responses}
+			s := NewAnalyzer(nil, nil, nil,
+				WithHTTPClient(&http.Client{Transport: transport}),
+				WithLogger(slog.New(slog.DiscardHandler)),
+				WithConfig(&config.Config{Fetch: config.FetchConfig{
+					MaxRetries:        2,
+					RetryBackoff:      time.Millisecond,
+					AnalyzeErrorPages: tc.analyzeErrorPages,
+				}}),
+			)
+
+			page, err := s.fetchContent(context.Background(), "https://example.com")
+
+			assert.Equal(t, tc.expectedCalls, transport.calls, "Attempt count mismatch")
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+				assert.Equal(t, tc.expectedStatus, fetchStatusOf(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedBody, page.content)
+			assert.Equal(t, tc.expectedStatus, page.statusCode)
+		})
+	}
+}
+
+func TestAnalyzer_ApplyPageDetails_ErrorPage(t *testing.T) {
+	s := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+
+	var result models.AnalyzeResult
+	s.applyPageDetails(&result, &fetchedPage{statusCode: http.StatusNotFound, url: "https://example.com"})
+	require.Len(t, result.Warnings, 1)
+	assert.Equal(t, models.WarningErrorPage, result.Warnings[0].Code)
+	assert.Contains(t, result.Warnings[0].Message, "HTTP 404")
+
+	result = models.AnalyzeResult{}
+	s.applyPageDetails(&result, &fetchedPage{statusCode: http.StatusOK, url: "https://example.com"})
+	for _, warning := range result.Warnings {
+		assert.NotEqual(t, models.WarningErrorPage, warning.Code)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	cfg := config.FetchConfig{RetryBackoff: 100 * time.Millisecond, MaxRetryBackoff: 300 * time.Millisecond}
+
+	assert.Equal(t, 100*time.Millisecond, retryBackoff(cfg, 1))
+	assert.Equal(t, 200*time.Millisecond, retryBackoff(cfg, 2))
+	assert.Equal(t, 300*time.Millisecond, retryBackoff(cfg, 3))
+	assert.Equal(t, 300*time.Millisecond, retryBackoff(cfg, 10))
+}
+
+func TestAnalyzer_CaptureResponseHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Security-Policy", "default-src 'self'")
+	header.Set("X-Frame-Options", "DENY")
+	header.Add("Permissions-Policy", "camera=()")
+	header.Add("Permissions-Policy", "microphone=()")
+	header.Set("Set-Cookie", "session=secret")
+	header.Set("Server", strings.Repeat("x", maxHeaderValueLength+10))
+	// The two-byte é straddles the limit
+	header.Set("Content-Language", strings.Repeat("x", maxHeaderValueLength-1)+"éé")
+
+	testCases := []struct {
+		name     string
+		cfg      config.FetchConfig
+		expected map[string]string
+	}{
+		{
+			name:     "Disabled",
+			cfg:      config.FetchConfig{CapturedHeaders: []string{"X-Frame-Options"}},
+			expected: nil,
+		},
+		{
+			name: "CuratedSubset",
+			cfg: config.FetchConfig{
+				CaptureHeaders:  true,
+				CapturedHeaders: []string{"content-security-policy", "X-Frame-Options", "Permissions-Policy", "Strict-Transport-Security", "Server", "Content-Language"},
+			},
+			expected: map[string]string{
+				"content-security-policy": "default-src 'self'",
+				"x-frame-options":         "DENY",
+				"permissions-policy":      "camera=(), microphone=()",
+				"server":                  strings.Repeat("x", maxHeaderValueLength),
+				"content-language":        strings.Repeat("x", maxHeaderValueLength-1),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewAnalyzer(nil, nil, nil,
+				WithLogger(slog.New(slog.DiscardHandler)),
+				WithConfig(&config.Config{Fetch: tc.cfg}),
+			)
+
+			captured := s.captureResponseHeaders(header)
+			assert.Equal(t, tc.expected, captured)
+			for name, value := range captured {
+				assert.True(t, utf8.ValidString(value), "%s should stay valid UTF-8", name)
+			}
+		})
+	}
+}
+
+// encodedResponse serves body with the given Content-Encoding, recording the Accept-Encoding it was asked for
+func encodedResponse(encoding string, body []byte, acceptEncoding *string) func(req *http.Request) (*http.Response, error) {
+	return func(req *http.Request) (*http.Response, error) {
+		*acceptEncoding = req.Header.Get("Accept-Encoding")
+		header := make(http.Header)
+		if encoding != "" {
+			header.Set("Content-Encoding", encoding)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Header:     header,
+			Body:       io.NopCloser(bytes.NewReader(body)),
+			Request:    req,
+		}, nil
+	}
+}
+
+func gzipped(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestAnalyzer_FetchContentEncoding(t *testing.T) {
+	page := "<html><body>" + strings.Repeat("<p>compressible</p>", 200) + "</body></html>"
+	bomb, err := os.ReadFile("testdata/gzip_bomb.html.gz")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name             string
+		encoding         string
+		body             []byte
+		maxContentBytes  int
+		expectedEncoding string
+		expectedError    error
+		expectedCalls    int
+	}{
+		{
+			name:          "Identity",
+			body:          []byte(page),
+			expectedCalls: 1,
+		},
+		{
+			name:             "Gzip",
+			encoding:         "gzip",
+			body:             gzipped(t, page),
+			expectedEncoding: "gzip",
+			expectedCalls:    1,
+		},
+		{
+			name:             "LegacyGzipName",
+			encoding:         "X-Gzip",
+			body:             gzipped(t, page),
+			expectedEncoding: "gzip",
+			expectedCalls:    1,
+		},
+		{
+			name:            "IdentityTooLarge",
+			body:            []byte(page),
+			maxContentBytes: len(page) - 1,
+			expectedError:   errContentTooLarge,
+			expectedCalls:   1,
+		},
+		{
+			name:          "GzipBomb",
+			encoding:      "gzip",
+			body:          bomb,
+			expectedError: errContentTooLarge,
+			expectedCalls: 1,
+		},
+		{
+			name:          "CorruptGzip",
+			encoding:      "gzip",
+			body:          []byte("not gzip at all"),
+			expectedError: gzip.ErrHeader,
+			expectedCalls: 1,
+		},
+		{
+			name:          "UnrequestedEncoding",
+			encoding:      "br",
+			body:          []byte(page),
+			expectedError: errUnsupportedEncoding,
+			expectedCalls: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var acceptEncoding string
+			transport := &sequenceRoundTripper{responses: []func(req *http.Request) (*http.Response, error){
+				encodedResponse(tc.encoding, tc.body, &acceptEncoding),
+			}}
+			s := NewAnalyzer(nil, nil, nil,
+				WithHTTPClient(&http.Client{Transport: transport}),
+				WithLogger(slog.New(slog.DiscardHandler)),
+				WithConfig(&config.Config{Fetch: config.FetchConfig{
+					MaxRetries:      2,
+					RetryBackoff:    time.Millisecond,
+					MaxContentBytes: tc.maxContentBytes,
+				}}),
+			)
+
+			fetched, err := s.fetchContent(context.Background(), "https://example.com")
+
+			assert.Equal(t, acceptedEncodings, acceptEncoding)
+			assert.Equal(t, tc.expectedCalls, transport.calls, "decoding failures should not be retried")
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, page, fetched.content)
+			assert.Equal(t, tc.expectedEncoding, fetched.encoding)
+			assert.Equal(t, int64(len(tc.body)), fetched.transferredBytes)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"shared/models"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEmittedFindingsAreCataloged reads the analyzer's sources for the warnings it raises and checks that each one
+// is raised with a code constant of shared/models that resolves in the finding catalog
+func TestEmittedFindingsAreCataloged(t *testing.T) {
+	constants := stringConstants(t, "../../../shared/models")
+
+	files, err := filepath.Glob("*.go")
+	require.NoError(t, err)
+
+	emitted := 0
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		require.NoError(t, err)
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			lit, ok := n.(*ast.CompositeLit)
+			if !ok || !isModelsSelector(lit.Type, "Warning") {
+				return true
+			}
+
+			emitted++
+			pos := fset.Position(lit.Pos())
+			code := warningCode(lit)
+			sel, ok := code.(*ast.SelectorExpr)
+			if !ok || !isModelsSelector(sel, sel.Sel.Name) {
+				t.Errorf("%s: warning raised without a code constant of shared/models", pos)
+				return true
+			}
+
+			value, ok := constants[sel.Sel.Name]
+			if !assert.True(t, ok, "%s: models.%s is not a string constant", pos, sel.Sel.Name) {
+				return true
+			}
+			_, ok = models.LookupFinding(value)
+			assert.True(t, ok, "%s: code %q of models.%s is missing from the finding catalog", pos, value, sel.Sel.Name)
+			return true
+		})
+	}
+
+	assert.NotZero(t, emitted, "no warnings found in the analyzer's sources")
+}
+
+// warningCode returns the Code field of a models.Warning literal, nil when it is not set by name
+func warningCode(lit *ast.CompositeLit) ast.Expr {
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		if key, ok := kv.Key.(*ast.Ident); ok && key.Name == "Code" {
+			return kv.Value
+		}
+	}
+	return nil
+}
+
+// isModelsSelector reports whether expr is models.<name>
+func isModelsSelector(expr ast.Expr, name string) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	return ok && pkg.Name == "models" && sel.Sel.Name == name
+}
+
+// stringConstants returns the string constants declared in the package in dir, by name
+func stringConstants(t *testing.T, dir string) map[string]string {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	require.NoError(t, err)
+
+	constants := make(map[string]string)
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		require.NoError(t, err)
+
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				value := spec.(*ast.ValueSpec)
+				for i, ident := range value.Names {
+					if i >= len(value.Values) {
+						continue
+					}
+					if lit, ok := value.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+						constants[ident.Name], _ = strconv.Unquote(lit.Value)
+					}
+				}
+			}
+		}
+	}
+	return constants
+}
@@ -0,0 +1,67 @@
+package analyzer
+
+import (
+	"fmt"
+	"net/url"
+	"shared/models"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// checkFormSecurity flags the form when it exposes what users enter in it: when it submits to an http:// URL
+// from an HTTPS page, or submits a password field with GET. A form may be flagged for both.
+// Forms without an action submit to the page itself, so they share its scheme.
+func (s *Analyzer) checkFormSecurity(n *html.Node, result *AnalysisResult) {
+	action := s.formAction(n, result)
+
+	if hasScheme(result.baseURL, "https") && hasScheme(action, "http") {
+		s.flagInsecureForm(result, action, models.InsecureFormHTTPAction,
+			fmt.Sprintf("The form submitting to %s is on an HTTPS page, what users enter in it is sent unencrypted", action))
+	}
+
+	// A missing or unknown method submits with GET
+	method := strings.ToLower(strings.TrimSpace(s.getElementAttribute(n, "method")))
+	if method != "post" && method != "dialog" && s.hasPasswordField(n) {
+		s.flagInsecureForm(result, action, models.InsecureFormPasswordGET,
+			fmt.Sprintf("The form submitting to %s sends its password field with GET, putting the password in the URL", action))
+	}
+}
+
+// formAction returns the URL the form submits to: its action resolved against the page's base, or the page URL
+// when it has none. An action that cannot be resolved is returned as written.
+func (s *Analyzer) formAction(n *html.Node, result *AnalysisResult) string {
+	action := strings.TrimSpace(s.getElementAttribute(n, "action"))
+	if action == "" {
+		return result.baseURL
+	}
+	if resolved := s.resolveURL(action, result.resolutionBase()); resolved != "" {
+		return resolved
+	}
+	return action
+}
+
+// hasPasswordField reports whether the form has a password input
+func (s *Analyzer) hasPasswordField(form *html.Node) bool {
+	found := false
+	walkNodes(form, func(node *html.Node) bool {
+		if node.Type == html.ElementNode && node.Data == "input" &&
+			strings.EqualFold(s.getElementAttribute(node, "type"), "password") {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// flagInsecureForm lists the form on the result with a warning explaining the risk
+func (s *Analyzer) flagInsecureForm(result *AnalysisResult, action string, reason models.InsecureFormReason, message string) {
+	result.insecureForms = append(result.insecureForms, models.InsecureForm{Action: action, Reason: reason})
+	result.warnings = append(result.warnings, models.Warning{Code: models.WarningInsecureForm, Message: message})
+}
+
+// hasScheme reports whether rawURL parses with the scheme, ignoring case
+func hasScheme(rawURL, scheme string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && strings.EqualFold(u.Scheme, scheme)
+}
@@ -0,0 +1,121 @@
+package analyzer
+
+import (
+	"log/slog"
+	"os"
+	"shared/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestAnalyzer_CheckFormSecurity(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pageURL  string
+		content  string
+		expected []models.InsecureForm
+	}{
+		{
+			name:     "HTTPActionFromHTTPS",
+			pageURL:  "https://example.com/account",
+			content:  `<form action="http://example.com/login" method="post"><input name="q"></form>`,
+			expected: []models.InsecureForm{{Action: "http://example.com/login", Reason: models.InsecureFormHTTPAction}},
+		},
+		{
+			name:    "HTTPActionFromHTTP",
+			pageURL: "http://example.com/account",
+			content: `<form action="http://example.com/login" method="post"><input name="q"></form>`,
+		},
+		{
+			name:    "HTTPSAction",
+			pageURL: "https://example.com/account",
+			content: `<form action="https://example.com/login" method="post"><input name="q"></form>`,
+		},
+		{
+			name:    "EmptyActionSubmitsToPage",
+			pageURL: "https://example.com/account",
+			content: `<form action="" method="post"><input name="q"></form><form method="post"><input name="q"></form>`,
+		},
+		{
+			name:     "RelativeActionResolvesAgainstBase",
+			pageURL:  "https://example.com/account",
+			content:  `<head><base href="http://legacy.example.com/"></head><form action="login" method="post"></form>`,
+			expected: []models.InsecureForm{{Action: "http://legacy.example.com/login", Reason: models.InsecureFormHTTPAction}},
+		},
+		{
+			name:     "PasswordWithGET",
+			pageURL:  "https://example.com/account",
+			content:  `<form action="/login" method="GET"><input type="password" name="pw"></form>`,
+			expected: []models.InsecureForm{{Action: "https://example.com/login", Reason: models.InsecureFormPasswordGET}},
+		},
+		{
+			name:     "PasswordWithoutMethod",
+			pageURL:  "https://example.com/account",
+			content:  `<form><div><input type="password" name="pw"></div></form>`,
+			expected: []models.InsecureForm{{Action: "https://example.com/account", Reason: models.InsecureFormPasswordGET}},
+		},
+		{
+			name:    "PasswordWithPOST",
+			pageURL: "https://example.com/account",
+			content: `<form method="post"><input type="password" name="pw"></form>`,
+		},
+		{
+			name:    "GETWithoutPassword",
+			pageURL: "https://example.com/account",
+			content: `<form action="/search"><input name="q"></form>`,
+		},
+		{
+			name:    "BothRules",
+			pageURL: "https://example.com/account",
+			content: `<form action="http://example.com/login"><input type="password" name="pw"></form>`,
+			expected: []models.InsecureForm{
+				{Action: "http://example.com/login", Reason: models.InsecureFormHTTPAction},
+				{Action: "http://example.com/login", Reason: models.InsecureFormPasswordGET},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader(tc.content))
+			require.NoError(t, err)
+
+			analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+			result := &AnalysisResult{headings: make(map[string]int), baseURL: tc.pageURL}
+			analyzer.traverseNode(doc, result)
+
+			built := result.snapshot()
+			assert.Equal(t, tc.expected, built.InsecureForms)
+			require.Len(t, built.Warnings, len(tc.expected))
+			for _, warning := range built.Warnings {
+				assert.Equal(t, models.WarningInsecureForm, warning.Code)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_CheckFormSecurityFixture(t *testing.T) {
+	f, err := os.Open("testdata/insecure_forms.html")
+	require.NoError(t, err)
+	defer f.Close()
+	doc, err := html.Parse(f)
+	require.NoError(t, err)
+
+	analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+	result := &AnalysisResult{headings: make(map[string]int), baseURL: "https://example.com/account"}
+	analyzer.traverseNode(doc, result)
+
+	// The search form has no password and the newsletter form posts to the page itself
+	assert.Equal(t, []models.InsecureForm{
+		{Action: "http://legacy.example.com/login", Reason: models.InsecureFormHTTPAction},
+		{Action: "https://example.com/session", Reason: models.InsecureFormPasswordGET},
+	}, result.insecureForms)
+	assert.True(t, result.hasLoginForm)
+	require.Len(t, result.warnings, 2)
+	assert.Equal(t, "The form submitting to http://legacy.example.com/login is on an HTTPS page, what users enter in it is sent unencrypted", result.warnings[0].Message)
+	assert.Equal(t, "The form submitting to https://example.com/session sends its password field with GET, putting the password in the URL", result.warnings[1].Message)
+}
@@ -0,0 +1,125 @@
+package analyzer
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"shared/mocks"
+	"strings"
+	"testing"
+
+	"go.uber.org/mock/gomock"
+	"golang.org/x/net/html"
+)
+
+// maxFuzzDocumentSize bounds the documents fed to the end-to-end fuzz target, so each run stays fast
+const maxFuzzDocumentSize = 64 * 1024
+
+// addTestdataSeeds adds the HTML files under testdata to the seed corpus
+func addTestdataSeeds(f *testing.F) {
+	files, err := filepath.Glob("testdata/*.html")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(content))
+	}
+}
+
+func newFuzzAnalyzer() *Analyzer {
+	return NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+}
+
+func FuzzParseHTMLVersion(f *testing.F) {
+	addTestdataSeeds(f)
+	f.Add(`<?xml version="1.0"?>`)
+	f.Add("<!doctype html system>")
+
+	s := newFuzzAnalyzer()
+	f.Fuzz(func(t *testing.T, content string) {
+		if version := s.parseHTMLVersion(strings.ToLower(content)); version == "" {
+			t.Fatalf("no version reported for %q", content)
+		}
+	})
+}
+
+func FuzzResolveURL(f *testing.F) {
+	f.Add("/about", "https://example.com/")
+	f.Add("../../a?b=c#d", "https://example.com/x/y/z")
+	f.Add("https://other.com/page", "https://example.com")
+	f.Add("//cdn.example.com/lib.js", "http://example.com")
+	f.Add("%zz", "https://example.com")
+	f.Add("/page", "")
+
+	s := newFuzzAnalyzer()
+	f.Fuzz(func(t *testing.T, href, baseURL string) {
+		resolved := s.resolveURL(href, baseURL)
+		if s.isAbsoluteURL(href) && resolved != href {
+			t.Fatalf("absolute url %q resolved to %q", href, resolved)
+		}
+		if resolved == "" {
+			return
+		}
+
+		if baseURL == "" && !s.isExternalURL(resolved, baseURL) {
+			t.Fatalf("%q is internal without a base url", resolved)
+		}
+		s.isExternalURL(resolved, baseURL)
+	})
+}
+
+func FuzzShouldProcessLink(f *testing.F) {
+	for _, href := range []string{"", "/", "#top", "javascript:void(0)", "mailto:a@example.com", "/about", "https://example.com"} {
+		f.Add(href)
+	}
+
+	s := newFuzzAnalyzer()
+	f.Fuzz(func(t *testing.T, href string) {
+		process := s.shouldProcessLink(href)
+		if process && (href == "" || href == "/" || strings.HasPrefix(href, "#")) {
+			t.Fatalf("%q should not be processed", href)
+		}
+	})
+}
+
+func FuzzAnalyzeContent(f *testing.F) {
+	addTestdataSeeds(f)
+	f.Add(strings.Repeat("<div>", 2000) + `<a href="/deep">deep</a>`)
+	f.Add(`<form><input name="user"><input type="password"><button></button></form><title></title>`)
+
+	f.Fuzz(func(t *testing.T, content string) {
+		if len(content) > maxFuzzDocumentSize {
+			t.Skip("document too large")
+		}
+
+		// The controller reports to the fuzz target's t, it must not be shared across inputs
+		ctrl := gomock.NewController(t)
+		mockTaskRepo := mocks.NewMockTaskRepositoryInterface(ctrl)
+		mockMessageBus := mocks.NewMockMessageBusInterface(ctrl)
+		mockTaskRepo.EXPECT().UpdateTaskStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		mockMessageBus.EXPECT().PublishTaskStatusUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+		s := NewAnalyzer(nil, mockTaskRepo, mockMessageBus, WithLogger(slog.New(slog.DiscardHandler)))
+
+		doc, err := html.Parse(strings.NewReader(content))
+		if err != nil {
+			t.Skip("unparsable document")
+		}
+
+		result := &AnalysisResult{
+			headings:           make(map[string]int),
+			links:              []string{},
+			baseURL:            "https://example.com/",
+			linkDepthHistogram: make(map[string]int),
+		}
+		s.analyzeContent(context.Background(), "fuzz-job", doc, result)
+
+		if counted := result.counts.sum(countInternalLinks, countExternalLinks); counted != len(result.links) {
+			t.Fatalf("%d links counted, %d collected", counted, len(result.links))
+		}
+	})
+}
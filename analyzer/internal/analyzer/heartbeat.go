@@ -0,0 +1,72 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"shared/models"
+	"shared/repository"
+	"time"
+)
+
+// heartbeatInterval returns how often a running job records a heartbeat, zero when it does not
+func (s *Analyzer) heartbeatInterval() time.Duration {
+	if s.cfg == nil {
+		return 0
+	}
+	return s.cfg.Events.HeartbeatInterval
+}
+
+// startHeartbeat records a heartbeat of the started job every heartbeat interval, so the API's reconciler can
+// tell a slow job from one whose analyzer died. Heartbeats end with ctx, once the job no longer runs under its
+// attempt or after the maximum heartbeat duration. The returned function stops them and waits until they did.
+func (s *Analyzer) startHeartbeat(ctx context.Context, job *models.Job) func() {
+	interval := s.heartbeatInterval()
+	if interval <= 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	ticker := time.NewTicker(interval)
+	until := time.Now().Add(s.cfg.Events.HeartbeatMaxDuration)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.runHeartbeat(ctx, job.ID, job.AttemptID, ticker.C, until)
+	}()
+
+	return func() {
+		cancel()
+		ticker.Stop()
+		<-done
+	}
+}
+
+// runHeartbeat records a heartbeat at every tick until ctx ends, a tick comes after until or the attempt is stale
+func (s *Analyzer) runHeartbeat(ctx context.Context, jobID, attemptID string, ticks <-chan time.Time, until time.Time) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticks:
+			if now.After(until) {
+				s.log.Warn("Stopping heartbeats of a job running past the maximum heartbeat duration",
+					slog.String("jobId", jobID))
+				return
+			}
+
+			err := s.jobRepo.HeartbeatJob(ctx, jobID, attemptID, now)
+			if errors.Is(err, repository.ErrStaleAttempt) {
+				s.log.Info("Stopping heartbeats of a job no longer run by this analysis",
+					slog.String("jobId", jobID),
+					slog.String("attemptId", attemptID))
+				return
+			}
+			if err != nil && ctx.Err() == nil {
+				s.log.Warn("Failed to record job heartbeat",
+					slog.String("jobId", jobID),
+					slog.Any("error", err))
+			}
+		}
+	}
+}
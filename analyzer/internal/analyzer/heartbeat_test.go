@@ -0,0 +1,102 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"shared/mocks"
+	"shared/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAnalyzer_RunHeartbeat(t *testing.T) {
+	start := time.Date(2024, 5, 8, 12, 0, 0, 0, time.UTC)
+	until := start.Add(time.Minute)
+
+	testCases := []struct {
+		name       string
+		ticks      []time.Time
+		setupMocks func(*mocks.MockJobRepositoryInterface)
+		// exits tells whether the heartbeats end on their own once the ticks were sent
+		exits bool
+	}{
+		{
+			name:  "EveryTick",
+			ticks: []time.Time{start.Add(30 * time.Second), until},
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				gomock.InOrder(
+					jobRepo.EXPECT().HeartbeatJob(gomock.Any(), "job-1", "attempt-1", start.Add(30*time.Second)).Return(nil),
+					jobRepo.EXPECT().HeartbeatJob(gomock.Any(), "job-1", "attempt-1", until).Return(nil),
+				)
+			},
+		},
+		{
+			name:  "ErrorsDoNotStop",
+			ticks: []time.Time{start.Add(30 * time.Second), start.Add(60 * time.Second)},
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				jobRepo.EXPECT().HeartbeatJob(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("database error")).Times(2)
+			},
+		},
+		{
+			name:  "StaleAttempt",
+			ticks: []time.Time{start.Add(30 * time.Second)},
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				jobRepo.EXPECT().HeartbeatJob(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(repository.ErrStaleAttempt)
+			},
+			exits: true,
+		},
+		{
+			name:       "PastMaxDuration",
+			ticks:      []time.Time{until.Add(time.Second)},
+			setupMocks: func(*mocks.MockJobRepositoryInterface) {},
+			exits:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockJobRepo := mocks.NewMockJobRepositoryInterface(ctrl)
+			tc.setupMocks(mockJobRepo)
+			s := NewAnalyzer(mockJobRepo, mocks.NewMockTaskRepositoryInterface(ctrl), mocks.NewMockMessageBusInterface(ctrl))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			ticks := make(chan time.Time)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				s.runHeartbeat(ctx, "job-1", "attempt-1", ticks, until)
+			}()
+
+			for _, tick := range tc.ticks {
+				ticks <- tick
+			}
+			if !tc.exits {
+				cancel()
+			}
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("heartbeats did not stop")
+			}
+		})
+	}
+}
+
+func TestAnalyzer_StartHeartbeat_Disabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Without a heartbeat interval the repository is never called
+	s := NewAnalyzer(mocks.NewMockJobRepositoryInterface(ctrl), mocks.NewMockTaskRepositoryInterface(ctrl), mocks.NewMockMessageBusInterface(ctrl))
+	stop := s.startHeartbeat(context.Background(), nil)
+	assert.NotNil(t, stop)
+	stop()
+}
@@ -0,0 +1,110 @@
+package analyzer
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// minHostPruneThreshold is the number of tracked hosts above which idle buckets are dropped
+const minHostPruneThreshold = 1024
+
+// HostRateLimiter is a token bucket per target host, shared by every analysis running in the process.
+// Two jobs requesting the same host draw from the same bucket, so together they stay within its rate.
+type HostRateLimiter struct {
+	rps   rate.Limit
+	burst int
+
+	mu             sync.Mutex
+	buckets        map[string]*rate.Limiter
+	pruneThreshold int
+}
+
+// NewHostRateLimiter creates a limiter allowing rps requests per second to each host, with bursts of up to burst requests
+func NewHostRateLimiter(rps float64, burst int) *HostRateLimiter {
+	return &HostRateLimiter{
+		rps:            rate.Limit(rps),
+		burst:          max(burst, 1),
+		buckets:        make(map[string]*rate.Limiter),
+		pruneThreshold: minHostPruneThreshold,
+	}
+}
+
+// Wait blocks until a request to host is allowed and returns how long it waited.
+// If ctx is done first, the token is given back and the context error returned.
+func (l *HostRateLimiter) Wait(ctx context.Context, host string) (time.Duration, error) {
+	reservation := l.bucket(strings.ToLower(host)).Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		return 0, nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return delay, nil
+	case <-ctx.Done():
+		reservation.Cancel()
+		return 0, ctx.Err()
+	}
+}
+
+// bucket returns the token bucket of a host, creating it on first use
+func (l *HostRateLimiter) bucket(host string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limiter, ok := l.buckets[host]; ok {
+		return limiter
+	}
+
+	if len(l.buckets) >= l.pruneThreshold {
+		l.pruneIdle()
+	}
+
+	limiter := rate.NewLimiter(l.rps, l.burst)
+	l.buckets[host] = limiter
+	return limiter
+}
+
+// pruneIdle drops the buckets that have refilled completely, they behave exactly like new ones.
+// The threshold grows with the hosts still in use, so busy hosts are not scanned on every insert.
+func (l *HostRateLimiter) pruneIdle() {
+	now := time.Now()
+	for host, limiter := range l.buckets {
+		if limiter.TokensAt(now) >= float64(l.burst) {
+			delete(l.buckets, host)
+		}
+	}
+	l.pruneThreshold = max(minHostPruneThreshold, 2*len(l.buckets))
+}
+
+// waitForHost waits for the shared host rate limiter before a request to rawURL, recording any throttling.
+// Without a limiter it returns immediately.
+func (s *Analyzer) waitForHost(ctx context.Context, rawURL, requestType string) error {
+	if s.hostLimiter == nil {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		// The request itself reports the invalid URL
+		return nil
+	}
+
+	wait, err := s.hostLimiter.Wait(ctx, u.Hostname())
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		s.log.Debug("Throttled request to host", "host", u.Hostname(), "wait", wait, "requestType", requestType)
+		s.metrics.RecordHostRateLimitWait(requestType, wait.Seconds())
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package analyzer
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"shared/metrics"
+	"shared/models"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostRateLimiter_BucketPerHost(t *testing.T) {
+	limiter := NewHostRateLimiter(10, 2)
+	ctx := context.Background()
+
+	for range 2 {
+		wait, err := limiter.Wait(ctx, "example.com")
+		require.NoError(t, err)
+		assert.Zero(t, wait, "requests within the burst should not wait")
+	}
+
+	// Other hosts have their own bucket
+	wait, err := limiter.Wait(ctx, "other.com")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	// Host names are compared case-insensitively
+	wait, err = limiter.Wait(ctx, "EXAMPLE.com")
+	require.NoError(t, err)
+	assert.Greater(t, wait, 50*time.Millisecond)
+}
+
+func TestHostRateLimiter_CancelledWaitReturnsToken(t *testing.T) {
+	limiter := NewHostRateLimiter(0.1, 1)
+
+	_, err := limiter.Wait(context.Background(), "example.com")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = limiter.Wait(ctx, "example.com")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Without the cancelled reservation the bucket is back to empty, not in debt
+	assert.InDelta(t, 0, limiter.bucket("example.com").TokensAt(time.Now()), 0.01)
+}
+
+func TestHostRateLimiter_PrunesIdleHosts(t *testing.T) {
+	limiter := NewHostRateLimiter(0.1, 2)
+	limiter.pruneThreshold = 2
+
+	_, err := limiter.Wait(context.Background(), "busy.com")
+	require.NoError(t, err)
+	limiter.bucket("idle.com")
+	limiter.bucket("new.com")
+
+	var hosts []string
+	for host := range limiter.buckets {
+		hosts = append(hosts, host)
+	}
+	slices.Sort(hosts)
+	assert.Equal(t, []string{"busy.com", "new.com"}, hosts, "only buckets that refilled should be dropped")
+}
+
+// hostWaitMetrics counts the requests reported as throttled by the host rate limit
+type hostWaitMetrics struct {
+	metrics.AnalyzerMetricsInterface
+	throttled atomic.Int32
+}
+
+func (m *hostWaitMetrics) RecordHostRateLimitWait(requestType string, wait float64) {
+	m.throttled.Add(1)
+}
+
+func TestAnalyzer_VerifyLink_SharedHostRateLimit(t *testing.T) {
+	m := &hostWaitMetrics{AnalyzerMetricsInterface: metrics.NewNoOpAnalyzerMetrics()}
+	limiter := NewHostRateLimiter(50, 1)
+
+	// Two analyzers stand in for concurrent jobs, drawing from the same limiter
+	newAnalyzer := func() *Analyzer {
+		return NewAnalyzer(nil, nil, nil,
+			WithHTTPClient(&http.Client{Transport: &MockHTTPRoundTripper{statusCode: http.StatusOK}}),
+			WithLogger(slog.New(slog.DiscardHandler)),
+			WithMetrics(m),
+			WithHostRateLimiter(limiter),
+		)
+	}
+	analyzers := []*Analyzer{newAnalyzer(), newAnalyzer()}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			check := analyzers[i%2].verifyLink(context.Background(), "https://example.com/page", models.HeaderProfileDefault)
+			assert.Equal(t, models.TaskStatusCompleted, check.status)
+		}()
+	}
+	wg.Wait()
+
+	// Ten requests at 50 per second with a burst of one take at least 180ms
+	assert.GreaterOrEqual(t, time.Since(start), 170*time.Millisecond)
+	assert.Equal(t, int32(9), m.throttled.Load())
+}
+
+func TestAnalyzer_VerifyLink_HostRateLimitCancelled(t *testing.T) {
+	transport := &countingRoundTripper{next: &MockHTTPRoundTripper{statusCode: http.StatusOK}}
+	limiter := NewHostRateLimiter(0.1, 1)
+	analyzer := NewAnalyzer(nil, nil, nil,
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithLogger(slog.New(slog.DiscardHandler)),
+		WithHostRateLimiter(limiter),
+	)
+
+	check := analyzer.verifyLink(context.Background(), "https://example.com/a", models.HeaderProfileDefault)
+	assert.Equal(t, models.TaskStatusCompleted, check.status)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	check = analyzer.verifyLink(ctx, "https://example.com/b", models.HeaderProfileDefault)
+
+	assert.Equal(t, models.TaskStatusFailed, check.status)
+	assert.Contains(t, check.description, "host rate limit")
+	assert.Equal(t, 1, transport.calls, "a request cancelled while throttled should not be sent")
+}
@@ -0,0 +1,52 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"shared/models"
+	"syscall"
+)
+
+// classifyRequestError classifies a link request that got no response and describes the failure in a few words,
+// rather than with the transport's error naming the addresses it dialed
+func classifyRequestError(err error) (models.LinkErrorCode, string) {
+	if desc, ok := describeRedirectError(err); ok {
+		return models.LinkErrorTooManyRedirects, desc
+	}
+	if code, desc, ok := describeTLSError(err); ok {
+		return code, desc
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return models.LinkErrorDNSNotFound, fmt.Sprintf("Host %s not found", dnsErr.Name)
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout(), errors.Is(err, context.DeadlineExceeded):
+		return models.LinkErrorTimeout, "Connection timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return models.LinkErrorConnectionRefused, "Connection refused"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return models.LinkErrorConnectionReset, "Connection closed by the server"
+	case dnsErr != nil:
+		return models.LinkErrorRequestFailed, fmt.Sprintf("DNS lookup of %s failed", dnsErr.Name)
+	}
+	return models.LinkErrorRequestFailed, "Request failed: " + rootCause(err).Error()
+}
+
+// rootCause returns the innermost error err wraps, leaving out the operations and addresses wrapped around it
+func rootCause(err error) error {
+	for {
+		inner := errors.Unwrap(err)
+		if inner == nil {
+			return err
+		}
+		err = inner
+	}
+}
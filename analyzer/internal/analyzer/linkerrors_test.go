@@ -0,0 +1,195 @@
+package analyzer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"shared/metrics"
+	"shared/models"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dialError wraps err the way the transport reports a failed dial to example.com
+func dialError(err error) error {
+	return &url.Error{Op: "Head", URL: "https://example.com", Err: &net.OpError{
+		Op:   "dial",
+		Net:  "tcp",
+		Addr: &net.TCPAddr{IP: net.ParseIP("93.184.216.34"), Port: 443},
+		Err:  err,
+	}}
+}
+
+func TestClassifyRequestError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		err          error
+		expectedCode models.LinkErrorCode
+		expectedDesc string
+	}{
+		{
+			name:         "DNSNotFound",
+			err:          dialError(&net.DNSError{Err: "no such host", Name: "nowhere.example", IsNotFound: true}),
+			expectedCode: models.LinkErrorDNSNotFound,
+			expectedDesc: "Host nowhere.example not found",
+		},
+		{
+			name:         "DNSServerFailure",
+			err:          dialError(&net.DNSError{Err: "server misbehaving", Name: "example.com", Server: "10.0.0.1:53"}),
+			expectedCode: models.LinkErrorRequestFailed,
+			expectedDesc: "DNS lookup of example.com failed",
+		},
+		{
+			name:         "DNSTimeout",
+			err:          dialError(&net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}),
+			expectedCode: models.LinkErrorTimeout,
+			expectedDesc: "Connection timeout",
+		},
+		{
+			name:         "ConnectionRefused",
+			err:          dialError(os.NewSyscallError("connect", syscall.ECONNREFUSED)),
+			expectedCode: models.LinkErrorConnectionRefused,
+			expectedDesc: "Connection refused",
+		},
+		{
+			name:         "ConnectionReset",
+			err:          &url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}},
+			expectedCode: models.LinkErrorConnectionReset,
+			expectedDesc: "Connection closed by the server",
+		},
+		{
+			name:         "ClosedBeforeResponse",
+			err:          &url.Error{Op: "Head", URL: "https://example.com", Err: io.EOF},
+			expectedCode: models.LinkErrorConnectionReset,
+			expectedDesc: "Connection closed by the server",
+		},
+		{
+			name:         "DialTimeout",
+			err:          dialError(os.ErrDeadlineExceeded),
+			expectedCode: models.LinkErrorTimeout,
+			expectedDesc: "Connection timeout",
+		},
+		{
+			name:         "ContextDeadline",
+			err:          &url.Error{Op: "Head", URL: "https://example.com", Err: context.DeadlineExceeded},
+			expectedCode: models.LinkErrorTimeout,
+			expectedDesc: "Connection timeout",
+		},
+		{
+			name: "CertificateExpired",
+			err: &url.Error{Op: "Head", URL: "https://example.com", Err: &tls.CertificateVerificationError{
+				Err: x509.CertificateInvalidError{Cert: &x509.Certificate{}, Reason: x509.Expired},
+			}},
+			expectedCode: models.LinkErrorTLSCertificate,
+			expectedDesc: "TLS certificate expired or not yet valid",
+		},
+		{
+			name: "CertificateHostnameMismatch",
+			err: &url.Error{Op: "Head", URL: "https://example.com", Err: &tls.CertificateVerificationError{
+				Err: x509.HostnameError{Certificate: &x509.Certificate{DNSNames: []string{"example.org"}}, Host: "example.com"},
+			}},
+			expectedCode: models.LinkErrorTLSCertificate,
+			expectedDesc: "TLS certificate is not valid for example.com",
+		},
+		{
+			name:         "CertificateUnknownAuthority",
+			err:          &url.Error{Op: "Head", URL: "https://example.com", Err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}},
+			expectedCode: models.LinkErrorTLSCertificate,
+			expectedDesc: "TLS certificate signed by an unknown authority",
+		},
+		{
+			name: "CertificateOtherwiseInvalid",
+			err: &url.Error{Op: "Head", URL: "https://example.com", Err: &tls.CertificateVerificationError{
+				Err: x509.CertificateInvalidError{Cert: &x509.Certificate{}, Reason: x509.NotAuthorizedToSign},
+			}},
+			expectedCode: models.LinkErrorTLSCertificate,
+			expectedDesc: "TLS certificate rejected: x509: certificate is not authorized to sign other certificates",
+		},
+		{
+			name:         "HandshakeFailure",
+			err:          &url.Error{Op: "Head", URL: "https://example.com", Err: tls.AlertError(40)},
+			expectedCode: models.LinkErrorTLSHandshake,
+			expectedDesc: "TLS handshake failed: tls: handshake failure",
+		},
+		{
+			name: "TooManyRedirects",
+			err: &url.Error{Op: "Head", URL: "https://example.com/a", Err: &redirectError{
+				err:   errTooManyRedirects,
+				chain: []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"},
+			}},
+			expectedCode: models.LinkErrorTooManyRedirects,
+			expectedDesc: "Too many redirects, stopped after 1: https://example.com/a → https://example.com/b → https://example.com/c",
+		},
+		{
+			name:         "Unclassified",
+			err:          dialError(os.NewSyscallError("connect", syscall.ENETUNREACH)),
+			expectedCode: models.LinkErrorRequestFailed,
+			expectedDesc: "Request failed: network is unreachable",
+		},
+		{
+			name:         "Plain",
+			err:          errors.New("unsupported request"),
+			expectedCode: models.LinkErrorRequestFailed,
+			expectedDesc: "Request failed: unsupported request",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, desc := classifyRequestError(tc.err)
+			assert.Equal(t, tc.expectedCode, code)
+			assert.Equal(t, tc.expectedDesc, desc)
+			assert.NotContains(t, desc, "93.184.216.34", "the addresses dialed are left out")
+		})
+	}
+}
+
+// linkErrorMetrics counts the link verification errors by code
+type linkErrorMetrics struct {
+	metrics.NoOpAnalyzerMetrics
+	mu    sync.Mutex
+	codes map[string]int
+}
+
+func (m *linkErrorMetrics) RecordLinkVerificationError(code string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.codes[code]++
+}
+
+func TestAnalyzer_VerifyLinks_ErrorCodes(t *testing.T) {
+	analyzer, _, ctrl, subTasks := setupMockAnalyzer(t, "", "https://example.com")
+	defer ctrl.Finish()
+
+	m := &linkErrorMetrics{codes: make(map[string]int)}
+	WithMetrics(m)(analyzer)
+	WithHTTPClient(&http.Client{Transport: &sequenceRoundTripper{responses: []func(req *http.Request) (*http.Response, error){
+		func(req *http.Request) (*http.Response, error) {
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+		},
+	}}})(analyzer)
+
+	result := &AnalysisResult{baseURL: "https://example.com", links: []string{"https://example.com/a", "https://example.com/b"}}
+	require.NoError(t, analyzer.verifyLinks(context.Background(), "test-job-id", result))
+
+	failed := 0
+	for _, st := range *subTasks {
+		if st.SubTask.Status == models.TaskStatusFailed {
+			failed++
+			assert.Equal(t, models.LinkErrorConnectionRefused, st.SubTask.ErrorCode)
+			assert.Equal(t, "Connection refused", st.SubTask.Description)
+		}
+	}
+	assert.Equal(t, 2, failed)
+	assert.Equal(t, map[string]int{"connection_refused": 2}, m.codes)
+}
@@ -3,135 +3,447 @@ package analyzer
 import (
 	"context"
 	"fmt"
+	"io"
+	"math/rand/v2"
 	"net/http"
 	"net/url"
+	"shared/log"
 	"shared/messagebus"
 	"shared/models"
+	"slices"
 	"strconv"
 	"sync"
-	"sync/atomic"
 	"time"
 )
 
-// verifyLinks verifies all collected links concurrently
-func (s *Analyzer) verifyLinks(ctx context.Context, jobID string, result *AnalysisResult) {
+// defaultAllowedPorts are the explicit ports links may use when no configuration is set
+var defaultAllowedPorts = []int{80, 443}
+
+// maxVerifyBodyBytes is the most of a GET response body read while verifying a link
+const maxVerifyBodyBytes = 512
+
+// unverifiedDescription describes the subtask of a link left unverified because the analysis ended first
+const unverifiedDescription = "analysis timed out"
+
+// verifyLinks verifies all collected links, and images when enabled, concurrently.
+// A panic while verifying is recovered and reported as an error.
+func (s *Analyzer) verifyLinks(ctx context.Context, jobID string, result *AnalysisResult) (err error) {
 	start := time.Now()
 	s.updateTaskStatus(ctx, jobID, models.TaskTypeVerifyingLinks, models.TaskStatusRunning)
 
 	defer func() {
-		s.updateTaskStatus(ctx, jobID, models.TaskTypeVerifyingLinks, models.TaskStatusCompleted)
-		s.metrics.RecordAnalysisTask(string(models.TaskTypeVerifyingLinks), true, time.Since(start).Seconds())
+		if r := recover(); r != nil {
+			err = fmt.Errorf("link verification panicked: %v", r)
+		}
+		s.finishTask(ctx, jobID, models.TaskTypeVerifyingLinks, start, err)
 	}()
 
-	count := len(result.links)
+	images := s.imagesToVerify(result)
+	count := len(result.links) + len(images)
 	if count == 0 {
-		return
+		return nil
 	}
 
-	s.log.Info("Starting link verification", "linkCount", count)
-
-	// Track concurrent link verifications
-	s.metrics.SetConcurrentLinkVerifications(count)
-	defer s.metrics.SetConcurrentLinkVerifications(0)
+	s.log.Info("Starting link verification", "linkCount", len(result.links), "imageCount", len(images))
+	if result.headerProfile != models.HeaderProfileDefault {
+		s.log.Info("Verifying links with browser headers", "jobId", jobID, "headerProfile", result.headerProfile)
+	}
 
 	maxConcurrent := 10
-	if s.cfg != nil {
+	if s.cfg != nil && s.cfg.HTTP.MaxConcurrent > 0 {
 		maxConcurrent = s.cfg.HTTP.MaxConcurrent
 	}
+	workers := min(maxConcurrent, count)
 
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, maxConcurrent)
+	// Track concurrent link verifications
+	s.metrics.SetConcurrentLinkVerifications(workers)
+	defer s.metrics.SetConcurrentLinkVerifications(0)
 
-	for i, link := range result.links {
-		key := strconv.Itoa(i + 1)
-		s.addSubTask(ctx, jobID, models.TaskTypeVerifyingLinks, key, link)
+	// Consumers follow the running tallies through the parent task's progress, and without per-link events
+	// it is all they see of verification
+	stopProgress := s.startProgressReporter(ctx, jobID, count, result)
+	defer stopProgress()
+	stopJobProgress := s.startJobProgressReporter(ctx, jobID, count, result)
+	defer stopJobProgress()
 
-		s.log.Debug("Added subtask for link verification", "key", key, "url", link)
+	verifyStart := time.Now()
+	var wg sync.WaitGroup
+	var panicOnce sync.Once
+	var panicErr error
+	tasks := make(chan linkTask, workers)
 
+	for range workers {
 		wg.Add(1)
-		go func(ctx context.Context, link, key string) {
+		go func() {
 			defer wg.Done()
+			for task := range tasks {
+				// Links already queued when the analysis ended are drained without being requested
+				if ctx.Err() != nil || waitJitter(ctx, s.verifyJitter()) != nil {
+					s.abandonLinkTask(ctx, jobID, task, result)
+					continue
+				}
+				// The sample is sized to fit the budget, links of it still waiting once the budget ended are left out
+				if left, _ := s.budgetLeft(result); task.sampled && left <= 0 {
+					s.skipBudgetTask(ctx, jobID, task, result)
+					continue
+				}
+				if err := s.verifyLinkTask(ctx, jobID, task, result); err != nil {
+					panicOnce.Do(func() {
+						panicErr = err
+					})
+				}
+			}
+		}()
+	}
+
+	s.enqueueLinks(ctx, jobID, result, images, tasks, verifyStart, workers)
+	wg.Wait()
+	result.redundantRedirects = redundantRedirects(result.baseURL, result.links, result.redirects())
+	if panicErr != nil {
+		return panicErr
+	}
+	if err := ctx.Err(); err != nil {
+		s.log.Warn("Abandoned link verification",
+			"unverifiedLinks", result.counts.get(countUnverifiedLinks),
+			"unverifiedImages", result.counts.get(countUnverifiedImages))
+		return fmt.Errorf("link verification abandoned: %w", err)
+	}
+
+	s.log.Info("Completed link verification", "linkCount", len(result.links), "imageCount", len(images))
+	return nil
+}
+
+// verifyJitter returns the longest random delay taken before each link request
+func (s *Analyzer) verifyJitter() time.Duration {
+	if s.cfg == nil {
+		return 0
+	}
+	return s.cfg.Analysis.VerifyMaxJitter
+}
+
+// waitJitter waits a random delay of up to maxJitter, so the workers do not all hit the hosts at the same instant.
+// The per-host rate limit still applies after the delay. It returns ctx's error when ctx ends first.
+func waitJitter(ctx context.Context, maxJitter time.Duration) error {
+	if maxJitter <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(rand.N(maxJitter))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// linkCheck is the outcome of verifying a link
+type linkCheck struct {
+	status      models.TaskStatus
+	description string
+	// redirectedTo is the URL the link ended up at, empty when it was not redirected
+	redirectedTo string
+	// asset is set when the link points at a file to download rather than a web page
+	asset *assetInfo
+	// statusCode is the status the link was answered with, zero when no response came back
+	statusCode int
+	// errorCode classifies the failure when no response came back
+	errorCode models.LinkErrorCode
+}
+
+// linkTask is a link or image queued for verification along with its subtask key.
+// sampled is set for the links queued as part of the sample verified under the time budget.
+type linkTask struct {
+	link        string
+	key         string
+	subTaskType models.SubTaskType
+	stratum     linkStratum
+	sampled     bool
+}
 
-			sem <- struct{}{}
-			defer func() {
-				<-sem
-			}()
+// imagesToVerify returns the image sources to verify, none unless image verification is enabled
+func (s *Analyzer) imagesToVerify(result *AnalysisResult) []string {
+	if s.cfg == nil || !s.cfg.Analysis.VerifyImages {
+		return nil
+	}
+	return result.images
+}
 
-			s.updateSubTask(ctx, jobID, models.TaskTypeVerifyingLinks, key, models.SubTask{
-				Type:   models.SubTaskTypeValidatingLink,
-				Status: models.TaskStatusRunning,
-				URL:    link,
+// enqueueLinks adds a subtask per link and image and queues them for the workers, closing the queue when done.
+// Links outside the verify scope, excluded or pointing at the platform itself are recorded as skipped without being queued.
+// The scope only applies to links, images are verified wherever they are hosted.
+// Once the time budget runs short, only a sample of the links left is queued and the others are skipped.
+func (s *Analyzer) enqueueLinks(ctx context.Context, jobID string, result *AnalysisResult, images []string, tasks chan<- linkTask, verifyStart time.Time, workers int) {
+	defer close(tasks)
+
+	var queue []linkTask
+	for i, link := range result.links {
+		key := strconv.Itoa(i + 1)
+		external := s.isExternalURL(link, result.baseURL)
+		if !result.verifyScope.Includes(external) {
+			s.addSubTask(ctx, jobID, models.TaskTypeVerifyingLinks, key, models.SubTask{
+				Type:        models.SubTaskTypeValidatingLink,
+				Status:      models.TaskStatusSkipped,
+				URL:         link,
+				Description: fmt.Sprintf("Out of scope, only %s links are verified", result.verifyScope),
 			})
+			result.counts.inc(countOutOfScopeLinks)
+			continue
+		}
 
-			start := time.Now()
-			status, desc := s.verifyLink(ctx, link)
-			d := time.Since(start).Seconds()
+		if pattern := s.matchExclusion(link); pattern != "" {
+			s.addSubTask(ctx, jobID, models.TaskTypeVerifyingLinks, key, models.SubTask{
+				Type:        models.SubTaskTypeValidatingLink,
+				Status:      models.TaskStatusSkipped,
+				URL:         link,
+				Description: fmt.Sprintf("Excluded by pattern %s", pattern),
+			})
+			result.counts.inc(countExcludedLinks)
+			continue
+		}
 
-			s.updateSubTask(ctx, jobID, models.TaskTypeVerifyingLinks, key, models.SubTask{
+		if s.isOwnServiceLink(link) {
+			s.addSubTask(ctx, jobID, models.TaskTypeVerifyingLinks, key, models.SubTask{
 				Type:        models.SubTaskTypeValidatingLink,
-				Status:      status,
+				Status:      models.TaskStatusSkipped,
 				URL:         link,
-				Description: desc,
+				Description: "Own service excluded",
 			})
+			result.counts.inc(countExcludedLinks)
+			continue
+		}
+
+		stratum := stratumInternal
+		if external {
+			stratum = stratumExternal
+		}
+		queue = append(queue, linkTask{link: link, key: key, subTaskType: models.SubTaskTypeValidatingLink, stratum: stratum})
+	}
 
-			if status == models.TaskStatusCompleted {
-				atomic.AddInt32(&result.accessibleLinks, 1)
-			} else {
-				atomic.AddInt32(&result.inaccessibleLinks, 1)
+	// Image keys are prefixed so they never collide with the link positions
+	for i, image := range images {
+		key := "image-" + strconv.Itoa(i+1)
+		queue = append(queue, linkTask{link: image, key: key, subTaskType: models.SubTaskTypeValidatingImage, stratum: stratumImage})
+	}
+
+	// sampleStart is the position in the queue of the first of the links left when sampling started
+	sampleStart := 0
+	for i, task := range queue {
+		if result.sampler == nil && ctx.Err() == nil && s.samplingDue(result) {
+			s.startSampling(jobID, result, queue[i:], s.sampleCapacity(result, verifyStart, i, workers))
+			sampleStart = i
+		}
+		if result.sampler != nil {
+			if !result.sampler.keep[i-sampleStart] {
+				s.skipBudgetTask(ctx, jobID, task, result)
+				continue
 			}
+			task.sampled = true
+		}
+		s.queueLink(ctx, jobID, task, result, tasks)
+	}
+}
 
-			s.metrics.RecordLinkVerification(status == models.TaskStatusCompleted, d)
+// queueLink adds the pending subtask of a link and hands it to a worker, waiting for one to be free.
+// Once ctx is done the link is recorded as unverified instead of being queued.
+func (s *Analyzer) queueLink(ctx context.Context, jobID string, task linkTask, result *AnalysisResult, tasks chan<- linkTask) {
+	if ctx.Err() != nil {
+		s.abandonLinkTask(ctx, jobID, task, result)
+		return
+	}
 
-		}(ctx, link, key)
+	s.addSubTask(ctx, jobID, models.TaskTypeVerifyingLinks, task.key, models.SubTask{
+		Type:   task.subTaskType,
+		Status: models.TaskStatusPending,
+		URL:    task.link,
+	})
+	s.log.Debug("Added subtask for link verification", "key", task.key, log.URL("url", task.link))
+
+	select {
+	case tasks <- task:
+	case <-ctx.Done():
+		s.abandonLinkTask(ctx, jobID, task, result)
 	}
+}
 
-	wg.Wait()
-	s.log.Info("Completed link verification", "linkCount", count)
+// abandonLinkTask marks a link skipped because the analysis ended before it was verified, counting it as unverified.
+// The subtask is written without ctx's cancellation, as ctx is already done.
+func (s *Analyzer) abandonLinkTask(ctx context.Context, jobID string, task linkTask, result *AnalysisResult) {
+	s.addSubTask(context.WithoutCancel(ctx), jobID, models.TaskTypeVerifyingLinks, task.key, models.SubTask{
+		Type:        task.subTaskType,
+		Status:      models.TaskStatusSkipped,
+		URL:         task.link,
+		Description: unverifiedDescription,
+	})
+
+	if task.subTaskType == models.SubTaskTypeValidatingImage {
+		result.counts.inc(countUnverifiedImages)
+	} else {
+		result.counts.inc(countUnverifiedLinks)
+	}
+}
+
+// verifyLinkTask verifies a queued link and records the outcome on its subtask and the result.
+// A panic is recovered and returned so the worker can move on to the next link.
+func (s *Analyzer) verifyLinkTask(ctx context.Context, jobID string, task linkTask, result *AnalysisResult) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("link verification panicked for %s: %v", task.link, r)
+		}
+	}()
+
+	s.updateSubTask(ctx, jobID, models.TaskTypeVerifyingLinks, task.key, models.SubTask{
+		Type:   task.subTaskType,
+		Status: models.TaskStatusRunning,
+		URL:    task.link,
+	})
+
+	start := time.Now()
+	check := s.verifyLink(ctx, task.link, result.headerProfile)
+	d := time.Since(start).Seconds()
+
+	// A request cut short by the analysis ending says nothing about the link
+	if check.status != models.TaskStatusCompleted && ctx.Err() != nil {
+		s.abandonLinkTask(ctx, jobID, task, result)
+		return nil
+	}
+
+	accessible := check.status == models.TaskStatusCompleted
+	subTask := models.SubTask{
+		Type:        task.subTaskType,
+		Status:      check.status,
+		URL:         task.link,
+		Description: check.description,
+		ErrorCode:   check.errorCode,
+	}
+	if check.errorCode != "" {
+		s.metrics.RecordLinkVerificationError(string(check.errorCode))
+	}
+	if accessible && check.asset != nil && task.subTaskType == models.SubTaskTypeValidatingLink {
+		s.recordAsset(&subTask, *check.asset, result)
+	}
+	s.updateSubTask(ctx, jobID, models.TaskTypeVerifyingLinks, task.key, subTask)
+
+	if task.sampled {
+		result.sampler.record(task.stratum, accessible)
+	}
+	if task.subTaskType == models.SubTaskTypeValidatingImage {
+		if accessible {
+			result.counts.inc(countAccessibleImages)
+		} else {
+			result.counts.inc(countInaccessibleImages)
+		}
+		return nil
+	}
+
+	if accessible {
+		result.counts.inc(countAccessibleLinks)
+	} else {
+		result.counts.inc(countInaccessibleLinks)
+	}
+	if check.redirectedTo != "" {
+		result.recordRedirect(task.link, check.redirectedTo)
+	}
+
+	s.metrics.RecordLinkVerification(ctx, accessible, d)
+	return nil
 }
 
-// verifyLink verifies a single link
-func (s *Analyzer) verifyLink(ctx context.Context, link string) (models.TaskStatus, string) {
+// verifyLink verifies a single link with the request headers of profile
+func (s *Analyzer) verifyLink(ctx context.Context, link string, profile models.HeaderProfile) linkCheck {
 	u, err := url.Parse(link)
 	if err != nil {
 		msg := fmt.Sprintf("Invalid URL: %s", err.Error())
-		s.log.Error("Error parsing URL", "url", link, "error", err)
-		return models.TaskStatusFailed, msg
+		s.log.Error("Error parsing URL", log.URL("url", link), log.Error(err))
+		return linkCheck{status: models.TaskStatusFailed, description: msg}
 	}
 
 	if u.Scheme != "http" && u.Scheme != "https" {
 		desc := fmt.Sprintf("Unsupported protocol: %s", u.Scheme)
-		s.log.Debug("Skipping non-HTTP URL", "url", link, "scheme", u.Scheme)
-		return models.TaskStatusSkipped, desc
+		s.log.Debug("Skipping non-HTTP URL", log.URL("url", link), "scheme", u.Scheme)
+		return linkCheck{status: models.TaskStatusSkipped, description: desc}
 	}
 
+	if !s.isPortAllowed(u) {
+		s.log.Debug("Skipping URL on disallowed port", log.URL("url", link), "port", u.Port())
+		return linkCheck{status: models.TaskStatusSkipped, description: "port not allowed"}
+	}
+
+	check := s.checkLink(ctx, link, profile)
+	if profile == models.HeaderProfileFallback && isBlockedStatus(check.statusCode) && ctx.Err() == nil {
+		check = s.retryAsBrowser(ctx, link, check)
+	}
+	return check
+}
+
+// checkLink requests a link with HEAD, and with GET when the server does not support HEAD
+func (s *Analyzer) checkLink(ctx context.Context, link string, profile models.HeaderProfile) linkCheck {
 	// Start with HEAD request
-	status, desc, retry := s.tryHEADRequest(ctx, link)
+	check, retry := s.tryHEADRequest(ctx, link, profile)
 
 	// If HEAD failed with specific errors that suggest GET might work, retry with GET
 	if retry {
-		s.log.Debug("Retrying with GET request", "url", link, "reason", "HEAD request failed or not supported")
-		status, desc = s.tryGETRequest(ctx, link)
+		if ctx.Err() != nil {
+			return linkCheck{status: models.TaskStatusSkipped, description: unverifiedDescription}
+		}
+		s.log.Debug("Retrying with GET request", log.URL("url", link), "reason", "HEAD request failed or not supported")
+		check = s.tryGETRequest(ctx, link, profile)
+	}
+
+	return check
+}
+
+// verifyMaxRedirects returns the number of redirects followed when verifying a link
+func (s *Analyzer) verifyMaxRedirects() int {
+	if s.cfg != nil {
+		return s.cfg.Analysis.VerifyMaxRedirects
+	}
+	return defaultMaxRedirects
+}
+
+// isPortAllowed checks the link's explicit port against the allowed ports,
+// links without a port use the scheme default and are always allowed
+func (s *Analyzer) isPortAllowed(u *url.URL) bool {
+	port := u.Port()
+	if port == "" {
+		return true
+	}
+
+	allowed := defaultAllowedPorts
+	if s.cfg != nil {
+		allowed = s.cfg.HTTP.AllowedPorts
 	}
 
-	return status, desc
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return false
+	}
+	return slices.Contains(allowed, p)
 }
 
 // tryHEADRequest attempts to verify a link using HEAD request
-func (s *Analyzer) tryHEADRequest(ctx context.Context, link string) (models.TaskStatus, string, bool) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodHead, link, nil)
+func (s *Analyzer) tryHEADRequest(ctx context.Context, link string, profile models.HeaderProfile) (linkCheck, bool) {
+	req, err := http.NewRequestWithContext(withRedirectLimit(ctx, s.verifyMaxRedirects()), http.MethodHead, link, nil)
 	if err != nil {
 		msg := fmt.Sprintf("HEAD request creation failed: %s", err.Error())
-		s.log.Error("Failed to create HEAD request", "url", link, "error", err)
-		return models.TaskStatusFailed, msg, false
+		s.log.Error("Failed to create HEAD request", log.URL("url", link), log.Error(err))
+		return linkCheck{status: models.TaskStatusFailed, description: msg}, false
+	}
+	setProfileHeaders(req.Header, profile)
+
+	if err := s.waitForHost(ctx, link, "link_verification"); err != nil {
+		return linkCheck{status: models.TaskStatusFailed, description: fmt.Sprintf("Cancelled while waiting for host rate limit: %s", err.Error())}, false
 	}
 
 	start := time.Now()
 	resp, err := s.client.Do(req)
 	if err != nil {
-		msg := s.formatRequestError(err)
-		s.log.Debug("HEAD request failed", "url", link, "error", err)
+		code, msg := classifyRequestError(err)
+		s.log.Debug("HEAD request failed", log.URL("url", link), "code", code, log.Error(err))
 		s.metrics.RecordHTTPClientRequest(0, time.Since(start).Seconds(), http.MethodHead, "link_verification")
-		return models.TaskStatusFailed, msg, false
+		return linkCheck{status: models.TaskStatusFailed, description: msg, errorCode: code}, false
 	}
 	defer resp.Body.Close()
 
@@ -141,51 +453,104 @@ func (s *Analyzer) tryHEADRequest(ctx context.Context, link string) (models.Task
 	retry := s.shouldRetryWithGET(resp.StatusCode)
 
 	if retry {
-		return models.TaskStatusPending, "HEAD not supported, retrying with GET", true
+		return linkCheck{status: models.TaskStatusPending, description: "HEAD not supported, retrying with GET"}, true
 	}
 
 	// Process successful HEAD response
-	desc := s.formatResponse(resp)
+	check := linkCheck{
+		description:  appendRedirects(s.formatResponse(resp), resp),
+		redirectedTo: redirectTarget(resp),
+		asset:        assetOf(resp),
+		statusCode:   resp.StatusCode,
+	}
 
 	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
-		s.log.Debug("Link verified with HEAD", "url", link, "statusCode", resp.StatusCode)
-		return models.TaskStatusCompleted, desc, false
+		s.log.Debug("Link verified with HEAD", log.URL("url", link), "statusCode", resp.StatusCode)
+		check.status = models.TaskStatusCompleted
+		return check, false
 	}
 
-	s.log.Debug("Link verification failed with HEAD", "url", link, "statusCode", resp.StatusCode)
-	return models.TaskStatusFailed, desc, false
+	s.log.Debug("Link verification failed with HEAD", log.URL("url", link), "statusCode", resp.StatusCode)
+	check.status = models.TaskStatusFailed
+	return check, false
+}
+
+// tryGETRequest attempts to verify a link using GET request (fallback).
+// Only the first byte is requested, servers rejecting the range get a single plain GET.
+func (s *Analyzer) tryGETRequest(ctx context.Context, link string, profile models.HeaderProfile) linkCheck {
+	check, rangeRejected := s.sendGETRequest(ctx, link, profile, true)
+	if rangeRejected {
+		s.log.Debug("Range not satisfiable, retrying with plain GET", log.URL("url", link))
+		check, _ = s.sendGETRequest(ctx, link, profile, false)
+	}
+	return check
 }
 
-// tryGETRequest attempts to verify a link using GET request (fallback)
-func (s *Analyzer) tryGETRequest(ctx context.Context, link string) (models.TaskStatus, string) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+// sendGETRequest sends a GET request, reading at most maxVerifyBodyBytes of the body.
+// It reports whether the server rejected the requested range.
+func (s *Analyzer) sendGETRequest(ctx context.Context, link string, profile models.HeaderProfile, ranged bool) (linkCheck, bool) {
+	req, err := http.NewRequestWithContext(withRedirectLimit(ctx, s.verifyMaxRedirects()), http.MethodGet, link, nil)
 	if err != nil {
 		msg := fmt.Sprintf("GET request creation failed: %s", err.Error())
-		s.log.Error("Failed to create GET request", "url", link, "error", err)
-		return models.TaskStatusFailed, msg
+		s.log.Error("Failed to create GET request", log.URL("url", link), log.Error(err))
+		return linkCheck{status: models.TaskStatusFailed, description: msg}, false
+	}
+
+	// The body is discarded, so skip compression and ask for as little of it as possible
+	setProfileHeaders(req.Header, profile)
+	req.Header.Set("Accept-Encoding", "identity")
+	if ranged {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+
+	if err := s.waitForHost(ctx, link, "link_verification"); err != nil {
+		return linkCheck{status: models.TaskStatusFailed, description: fmt.Sprintf("Cancelled while waiting for host rate limit: %s", err.Error())}, false
 	}
 
 	start := time.Now()
 	resp, err := s.client.Do(req)
 	if err != nil {
-		msg := s.formatRequestError(err)
-		s.log.Error("GET request failed", "url", link, "error", err)
+		code, msg := classifyRequestError(err)
+		s.log.Error("GET request failed", log.URL("url", link), "code", code, log.Error(err))
 		s.metrics.RecordHTTPClientRequest(0, time.Since(start).Seconds(), http.MethodGet, "link_verification")
-		return models.TaskStatusFailed, msg
+		return linkCheck{status: models.TaskStatusFailed, description: msg, errorCode: code}, false
 	}
 	defer resp.Body.Close()
 
+	// Servers ignoring the range send the full body, drain only a little of it before closing
+	_, _ = io.CopyN(io.Discard, resp.Body, maxVerifyBodyBytes)
+
 	s.metrics.RecordHTTPClientRequest(resp.StatusCode, time.Since(start).Seconds(), http.MethodGet, "link_verification")
 
+	if ranged && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return linkCheck{status: models.TaskStatusFailed, description: s.formatResponse(resp)}, true
+	}
+
 	desc := s.formatResponse(resp)
+	if ranged {
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+			desc += " (range honored)"
+		case http.StatusOK:
+			desc += " (range ignored)"
+		}
+	}
+	check := linkCheck{
+		description:  appendRedirects(desc, resp),
+		redirectedTo: redirectTarget(resp),
+		asset:        assetOf(resp),
+		statusCode:   resp.StatusCode,
+	}
 
 	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
-		s.log.Debug("Link verified with GET", "url", link, "statusCode", resp.StatusCode)
-		return models.TaskStatusCompleted, desc
+		s.log.Debug("Link verified with GET", log.URL("url", link), "statusCode", resp.StatusCode)
+		check.status = models.TaskStatusCompleted
+		return check, false
 	}
 
-	s.log.Debug("Link verification failed with GET", "url", link, "statusCode", resp.StatusCode)
-	return models.TaskStatusFailed, desc
+	s.log.Debug("Link verification failed with GET", log.URL("url", link), "statusCode", resp.StatusCode)
+	check.status = models.TaskStatusFailed
+	return check, false
 }
 
 // shouldRetryWithGET determines if we should retry a failed HEAD request with GET
@@ -202,18 +567,6 @@ func (s *Analyzer) shouldRetryWithGET(statusCode int) bool {
 	}
 }
 
-// formatRequestError formats HTTP request errors consistently
-func (s *Analyzer) formatRequestError(err error) string {
-	if urlErr, ok := err.(*url.Error); ok {
-		if urlErr.Timeout() {
-			return "Connection timeout"
-		} else {
-			return fmt.Sprintf("Connection error: %s", urlErr.Err.Error())
-		}
-	}
-	return fmt.Sprintf("Request failed: %s", err.Error())
-}
-
 // formatResponse formats HTTP response information consistently
 func (s *Analyzer) formatResponse(resp *http.Response) string {
 	description := fmt.Sprintf("HTTP %d: %s", resp.StatusCode, http.StatusText(resp.StatusCode))
@@ -229,18 +582,12 @@ func (s *Analyzer) formatResponse(resp *http.Response) string {
 }
 
 // addSubTask adds a subtask and publishes an event
-func (s *Analyzer) addSubTask(ctx context.Context, jobID string, taskType models.TaskType, key, url string) {
-	subTask := models.SubTask{
-		Type:   models.SubTaskTypeValidatingLink,
-		Status: models.TaskStatusPending,
-		URL:    url,
-	}
-
+func (s *Analyzer) addSubTask(ctx context.Context, jobID string, taskType models.TaskType, key string, subTask models.SubTask) {
 	if err := s.taskRepo.AddSubTaskByKey(ctx, jobID, taskType, key, subTask); err != nil {
 		s.log.Error("Failed to add subtask", "error", err)
 	}
 
-	if err := s.publisher.PublishSubTaskUpdate(ctx, messagebus.SubTaskUpdateMessage{
+	if err := s.publishSubTask(ctx, messagebus.SubTaskUpdateMessage{
 		Type:     messagebus.SubTaskUpdateMessageType,
 		JobID:    jobID,
 		TaskType: string(taskType),
@@ -257,7 +604,7 @@ func (s *Analyzer) updateSubTask(ctx context.Context, jobID string, taskType mod
 		s.log.Error("Failed to update subtask", "error", err)
 	}
 
-	if err := s.publisher.PublishSubTaskUpdate(ctx, messagebus.SubTaskUpdateMessage{
+	if err := s.publishSubTask(ctx, messagebus.SubTaskUpdateMessage{
 		Type:     messagebus.SubTaskUpdateMessageType,
 		JobID:    jobID,
 		TaskType: string(taskType),
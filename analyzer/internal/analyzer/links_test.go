@@ -0,0 +1,417 @@
+package analyzer
+
+import (
+	"analyzer/internal/config"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"shared/models"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestAnalyzer_VerifyLink_PortPolicy(t *testing.T) {
+	testCases := []struct {
+		name           string
+		link           string
+		allowedPorts   []int
+		expectedStatus models.TaskStatus
+		expectRequest  bool
+	}{
+		{name: "SchemeDefaultHTTP", link: "http://example.com/", expectedStatus: models.TaskStatusCompleted, expectRequest: true},
+		{name: "SchemeDefaultHTTPS", link: "https://example.com/", expectedStatus: models.TaskStatusCompleted, expectRequest: true},
+		{name: "ExplicitDefaultPort", link: "https://example.com:443/", expectedStatus: models.TaskStatusCompleted, expectRequest: true},
+		{name: "SSHPort", link: "http://example.com:22/", expectedStatus: models.TaskStatusSkipped},
+		{name: "RedisPort", link: "http://example.com:6379/", expectedStatus: models.TaskStatusSkipped},
+		{name: "ConfiguredPort", link: "http://example.com:8080/", allowedPorts: []int{80, 443, 8080}, expectedStatus: models.TaskStatusCompleted, expectRequest: true},
+		{name: "DefaultNotConfigured", link: "http://example.com:80/", allowedPorts: []int{8080}, expectedStatus: models.TaskStatusSkipped},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			transport := &countingRoundTripper{next: &MockHTTPRoundTripper{statusCode: http.StatusOK}}
+			opts := []Option{
+				WithHTTPClient(&http.Client{Transport: transport}),
+				WithLogger(slog.New(slog.DiscardHandler)),
+			}
+			if tc.allowedPorts != nil {
+				cfg := config.Load()
+				cfg.HTTP.AllowedPorts = tc.allowedPorts
+				opts = append(opts, WithConfig(cfg))
+			}
+			analyzer := NewAnalyzer(nil, nil, nil, opts...)
+
+			check := analyzer.verifyLink(context.Background(), tc.link, models.HeaderProfileDefault)
+
+			assert.Equal(t, tc.expectedStatus, check.status)
+			assert.Equal(t, tc.expectRequest, transport.calls > 0, "request should only be sent to allowed ports")
+			if check.status == models.TaskStatusSkipped {
+				assert.Equal(t, "port not allowed", check.description)
+			}
+		})
+	}
+}
+
+// countingRoundTripper counts requests before passing them on
+type countingRoundTripper struct {
+	next  http.RoundTripper
+	calls int
+}
+
+func (c *countingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.calls++
+	return c.next.RoundTrip(req)
+}
+
+func TestAnalyzer_VerifyLinks_BoundedWorkers(t *testing.T) {
+	analyzer, _, ctrl, subTasks := setupMockAnalyzer(t, "", "https://example.com")
+	defer ctrl.Finish()
+
+	cfg := config.Load()
+	cfg.HTTP.MaxConcurrent = 3
+	transport := &inFlightRoundTripper{next: &MockHTTPRoundTripper{statusCode: http.StatusOK}}
+	WithConfig(cfg)(analyzer)
+	WithHTTPClient(&http.Client{Transport: transport})(analyzer)
+
+	result := &AnalysisResult{}
+	for i := range 50 {
+		result.links = append(result.links, "https://example.com/page-"+strconv.Itoa(i))
+	}
+	require.NoError(t, analyzer.verifyLinks(context.Background(), "test-job-id", result))
+
+	assert.LessOrEqual(t, transport.maxInFlight.Load(), int32(3), "no more links than workers should be verified at once")
+	assert.Equal(t, 50, result.counts.get(countAccessibleLinks))
+	// Every link is added, marked running and finished
+	assert.Len(t, *subTasks, 150)
+}
+
+func TestWaitJitter(t *testing.T) {
+	assert.NoError(t, waitJitter(context.Background(), 0))
+
+	start := time.Now()
+	for range 5 {
+		require.NoError(t, waitJitter(context.Background(), 10*time.Millisecond))
+	}
+	assert.Less(t, time.Since(start), 200*time.Millisecond, "each delay should stay under the maximum")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, waitJitter(ctx, time.Hour), context.Canceled, "a delay should end with the analysis")
+}
+
+func TestAnalyzer_VerifyLinks_Jitter(t *testing.T) {
+	analyzer, _, ctrl, subTasks := setupMockAnalyzer(t, "", "https://example.com")
+	defer ctrl.Finish()
+
+	cfg := config.Load()
+	cfg.HTTP.MaxConcurrent = 5
+	cfg.Analysis.VerifyMaxJitter = 20 * time.Millisecond
+	WithConfig(cfg)(analyzer)
+	WithHTTPClient(&http.Client{Transport: &MockHTTPRoundTripper{statusCode: http.StatusOK}})(analyzer)
+	WithHostRateLimiter(NewHostRateLimiter(1000, 5))(analyzer)
+
+	result := &AnalysisResult{}
+	for i := range 20 {
+		result.links = append(result.links, "https://example.com/page-"+strconv.Itoa(i))
+	}
+	require.NoError(t, analyzer.verifyLinks(context.Background(), "test-job-id", result))
+
+	assert.Equal(t, 20, result.counts.get(countAccessibleLinks))
+	assert.Zero(t, result.counts.get(countUnverifiedLinks))
+	assert.Len(t, *subTasks, 60)
+}
+
+// inFlightRoundTripper tracks the highest number of concurrent requests
+type inFlightRoundTripper struct {
+	next        http.RoundTripper
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+}
+
+func (r *inFlightRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	current := r.inFlight.Add(1)
+	defer r.inFlight.Add(-1)
+	for {
+		highest := r.maxInFlight.Load()
+		if current <= highest || r.maxInFlight.CompareAndSwap(highest, current) {
+			break
+		}
+	}
+
+	time.Sleep(time.Millisecond)
+	return r.next.RoundTrip(req)
+}
+
+// latencyRoundTripper delays every request, failing it when its context ends first
+type latencyRoundTripper struct {
+	latency time.Duration
+	next    http.RoundTripper
+}
+
+func (r *latencyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case <-time.After(r.latency):
+		return r.next.RoundTrip(req)
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+}
+
+func TestAnalyzer_VerifyLinks_AbandonedOnTimeout(t *testing.T) {
+	analyzer, _, ctrl, subTasks := setupMockAnalyzer(t, "", "https://example.com")
+	defer ctrl.Finish()
+
+	cfg := config.Load()
+	cfg.HTTP.MaxConcurrent = 2
+	cfg.Analysis.VerifyImages = true
+	WithConfig(cfg)(analyzer)
+	WithHTTPClient(&http.Client{Transport: &latencyRoundTripper{
+		latency: 50 * time.Millisecond,
+		next:    &MockHTTPRoundTripper{statusCode: http.StatusOK},
+	}})(analyzer)
+
+	result := &AnalysisResult{}
+	for i := range 20 {
+		result.links = append(result.links, "https://example.com/page-"+strconv.Itoa(i))
+	}
+	result.images = []string{"https://example.com/logo.png"}
+
+	// Verifying everything takes 21 requests of 50ms over 2 workers, the job only has time for a few
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := analyzer.verifyLinks(ctx, "test-job-id", result)
+	elapsed := time.Since(start)
+
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, elapsed, 250*time.Millisecond, "queued links should not be requested once the job timed out")
+
+	assert.Positive(t, result.counts.get(countAccessibleLinks))
+	assert.Positive(t, result.counts.get(countUnverifiedLinks))
+	assert.Zero(t, result.counts.get(countInaccessibleLinks), "links cut short by the timeout are not inaccessible")
+	assert.Equal(t, 20, result.counts.sum(countAccessibleLinks, countUnverifiedLinks))
+	assert.Equal(t, 1, result.counts.sum(countAccessibleImages, countUnverifiedImages))
+	assert.Equal(t, 21, finishedLinks(result))
+
+	// Every subtask ends verified or skipped as unverified
+	final := make(map[string]models.SubTask)
+	for _, capture := range *subTasks {
+		final[capture.Key] = capture.SubTask
+	}
+	require.Len(t, final, 21)
+	unverified := 0
+	for key, subTask := range final {
+		if subTask.Status == models.TaskStatusSkipped {
+			assert.Equal(t, unverifiedDescription, subTask.Description, key)
+			unverified++
+			continue
+		}
+		assert.Equal(t, models.TaskStatusCompleted, subTask.Status, key)
+	}
+	assert.Equal(t, result.counts.sum(countUnverifiedLinks, countUnverifiedImages), unverified)
+}
+
+func TestAnalyzer_ExtractImages(t *testing.T) {
+	analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+	doc, err := html.Parse(strings.NewReader(`<html><body>
+		<img src="/logo.png"><img src="/logo.png">
+		<img src="https://cdn.example.com/hero.jpg">
+		<img src="data:image/png;base64,iVBORw0KGgo="><img alt="no source">
+	</body></html>`))
+	require.NoError(t, err)
+
+	result := &AnalysisResult{baseURL: "https://example.com", headings: make(map[string]int)}
+	analyzer.traverseNode(doc, result)
+
+	assert.Equal(t, []string{"https://example.com/logo.png", "https://cdn.example.com/hero.jpg"}, result.images)
+}
+
+func TestAnalyzer_VerifyLinks_Images(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		verifyImages         bool
+		expectedAccessible   int
+		expectedInaccessible int
+		expectedSubTasks     int
+	}{
+		{name: "Enabled", verifyImages: true, expectedAccessible: 1, expectedInaccessible: 1, expectedSubTasks: 9},
+		// Only the link is checked, images are counted but not requested
+		{name: "Disabled", verifyImages: false, expectedSubTasks: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			analyzer, _, ctrl, subTasks := setupMockAnalyzer(t, "", "https://example.com")
+			defer ctrl.Finish()
+
+			cfg := config.Load()
+			cfg.Analysis.VerifyImages = tc.verifyImages
+			WithConfig(cfg)(analyzer)
+
+			result := &AnalysisResult{
+				links:  []string{"https://example.com/about"},
+				images: []string{"https://example.com/logo.png", "https://example.com/" + shouldNotBeFound + ".png"},
+			}
+			require.NoError(t, analyzer.verifyLinks(context.Background(), "test-job-id", result))
+
+			built := result.snapshot()
+			assert.Equal(t, 2, built.ImageCount)
+			assert.Equal(t, tc.expectedAccessible, built.AccessibleImages)
+			assert.Equal(t, tc.expectedInaccessible, built.InaccessibleImages)
+			assert.Equal(t, 1, built.AccessibleLinks, "images must not count towards links")
+			assert.Equal(t, 0, built.InaccessibleLinks)
+
+			require.Len(t, *subTasks, tc.expectedSubTasks)
+			for _, c := range *subTasks {
+				if strings.HasPrefix(c.Key, "image-") {
+					assert.Equal(t, models.SubTaskTypeValidatingImage, c.SubTask.Type)
+				} else {
+					assert.Equal(t, models.SubTaskTypeValidatingLink, c.SubTask.Type)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzer_VerifyLinks_Scope(t *testing.T) {
+	links := []string{"https://example.com/about", "https://other.com/page", "https://example.com/contact"}
+
+	testCases := []struct {
+		name               string
+		configured         models.LinkScope
+		requested          models.LinkScope
+		expectedVerified   []string
+		expectedOutOfScope int
+	}{
+		{name: "All", configured: models.LinkScopeAll, expectedVerified: links},
+		{name: "ConfiguredInternal", configured: models.LinkScopeInternal, expectedVerified: []string{links[0], links[2]}, expectedOutOfScope: 1},
+		{name: "RequestedExternal", configured: models.LinkScopeInternal, requested: models.LinkScopeExternal, expectedVerified: []string{links[1]}, expectedOutOfScope: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			analyzer, _, ctrl, subTasks := setupMockAnalyzer(t, "", "https://example.com")
+			defer ctrl.Finish()
+			WithVerifyScope(tc.configured)(analyzer)
+
+			job := &models.Job{ID: "test-job-id", URL: "https://example.com", VerifyScope: tc.requested}
+			result := &AnalysisResult{
+				links:       links,
+				baseURL:     job.URL,
+				verifyScope: analyzer.verifyScopeFor(job),
+			}
+			require.NoError(t, analyzer.verifyLinks(context.Background(), job.ID, result))
+
+			var verified []string
+			outOfScope := 0
+			for _, c := range *subTasks {
+				switch {
+				case c.SubTask.Status == models.TaskStatusCompleted:
+					verified = append(verified, c.SubTask.URL)
+				case c.SubTask.Status == models.TaskStatusSkipped:
+					assert.Contains(t, c.SubTask.Description, "Out of scope")
+					outOfScope++
+				}
+			}
+			assert.ElementsMatch(t, tc.expectedVerified, verified)
+			assert.Equal(t, tc.expectedOutOfScope, outOfScope)
+
+			built := result.snapshot()
+			assert.Equal(t, tc.expectedOutOfScope, built.OutOfScopeLinks)
+			assert.Equal(t, 0, built.ExcludedLinks, "out of scope links are counted apart from excluded ones")
+			assert.Equal(t, len(tc.expectedVerified), built.AccessibleLinks)
+			assert.Equal(t, len(links), finishedLinks(result))
+		})
+	}
+}
+
+func TestAnalyzer_TryGETRequest_Range(t *testing.T) {
+	testCases := []struct {
+		name             string
+		responses        []int
+		expectedStatus   models.TaskStatus
+		expectedDesc     string
+		expectedRequests int
+	}{
+		{name: "RangeHonored", responses: []int{http.StatusPartialContent}, expectedStatus: models.TaskStatusCompleted, expectedDesc: "HTTP 206: Partial Content (range honored)", expectedRequests: 1},
+		{name: "RangeIgnored", responses: []int{http.StatusOK}, expectedStatus: models.TaskStatusCompleted, expectedDesc: "HTTP 200: OK (range ignored)", expectedRequests: 1},
+		{name: "RangeRejected", responses: []int{http.StatusRequestedRangeNotSatisfiable, http.StatusOK}, expectedStatus: models.TaskStatusCompleted, expectedDesc: "HTTP 200: OK", expectedRequests: 2},
+		{name: "RangeRejectedThenNotFound", responses: []int{http.StatusRequestedRangeNotSatisfiable, http.StatusNotFound}, expectedStatus: models.TaskStatusFailed, expectedDesc: "HTTP 404: Not Found", expectedRequests: 2},
+		{name: "RangeRejectedTwice", responses: []int{http.StatusRequestedRangeNotSatisfiable, http.StatusRequestedRangeNotSatisfiable}, expectedStatus: models.TaskStatusFailed, expectedDesc: "HTTP 416: Requested Range Not Satisfiable", expectedRequests: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			transport := &rangeRoundTripper{responses: tc.responses}
+			analyzer := NewAnalyzer(nil, nil, nil,
+				WithHTTPClient(&http.Client{Transport: transport}),
+				WithLogger(slog.New(slog.DiscardHandler)),
+			)
+
+			check := analyzer.tryGETRequest(context.Background(), "https://example.com/large.pdf", models.HeaderProfileDefault)
+
+			assert.Equal(t, tc.expectedStatus, check.status)
+			assert.Equal(t, tc.expectedDesc, check.description)
+			require.Len(t, transport.requests, tc.expectedRequests)
+
+			assert.Equal(t, "bytes=0-0", transport.requests[0].Header.Get("Range"))
+			if tc.expectedRequests > 1 {
+				assert.Empty(t, transport.requests[1].Header.Get("Range"), "the fallback must be a plain GET")
+			}
+			for _, req := range transport.requests {
+				assert.Equal(t, "identity", req.Header.Get("Accept-Encoding"))
+			}
+			for _, body := range transport.bodies {
+				assert.LessOrEqual(t, body.read, int64(maxVerifyBodyBytes), "the body should not be downloaded")
+			}
+		})
+	}
+}
+
+// rangeRoundTripper answers with the given status codes in turn, each with a large body
+type rangeRoundTripper struct {
+	responses []int
+	requests  []*http.Request
+	bodies    []*countingBody
+}
+
+func (r *rangeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	statusCode := r.responses[len(r.requests)]
+	r.requests = append(r.requests, req)
+
+	body := &countingBody{remaining: 10 << 20}
+	r.bodies = append(r.bodies, body)
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     make(http.Header),
+		Body:       body,
+		Request:    req,
+	}, nil
+}
+
+// countingBody is an endless-looking body that counts how much of it was read
+type countingBody struct {
+	remaining int64
+	read      int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n := min(int64(len(p)), b.remaining)
+	b.remaining -= n
+	b.read += n
+	if b.remaining == 0 {
+		return int(n), io.EOF
+	}
+	return int(n), nil
+}
+
+func (b *countingBody) Close() error { return nil }
@@ -0,0 +1,140 @@
+package analyzer
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"shared/messagebus"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// loadSmoothing is the weight of the latest sample in the rolling averages of the load report
+const loadSmoothing = 0.2
+
+// MessageQueue reports how many received messages wait to be handled, *nats.Subscription implements it
+type MessageQueue interface {
+	Pending() (int, int, error)
+}
+
+// loadTracker keeps the figures of the load the analyzer reports
+type loadTracker struct {
+	mu          sync.Mutex
+	inFlight    int
+	avgWait     time.Duration
+	avgDuration time.Duration
+	// waits and durations count the samples folded into the averages
+	waits     int
+	durations int
+}
+
+// started records an analysis starting after waiting in the queue, a negative wait is not known
+func (l *loadTracker) started(wait time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight++
+	if wait >= 0 {
+		l.avgWait = rollingAverage(l.avgWait, wait, l.waits)
+		l.waits++
+	}
+}
+
+// finished records an analysis ending after running for d
+func (l *loadTracker) finished(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	l.avgDuration = rollingAverage(l.avgDuration, d, l.durations)
+	l.durations++
+}
+
+// snapshot returns the jobs in flight and the rolling averages of their wait and duration
+func (l *loadTracker) snapshot() (inFlight int, avgWait, avgDuration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight, l.avgWait, l.avgDuration
+}
+
+// rollingAverage folds a sample into an exponentially weighted average, the first sample is taken as is
+func rollingAverage(avg, sample time.Duration, samples int) time.Duration {
+	if samples == 0 {
+		return sample
+	}
+	return time.Duration(loadSmoothing*float64(sample) + (1-loadSmoothing)*float64(avg))
+}
+
+// queueWait returns how long an analyze message waited since it was published, -1 when it carries no timestamp
+func queueWait(msg *nats.Msg, now time.Time) time.Duration {
+	if msg.Header == nil {
+		return -1
+	}
+
+	publishedAt, err := time.Parse(time.RFC3339Nano, msg.Header.Get(messagebus.PublishedAtHeader))
+	if err != nil {
+		return -1
+	}
+	return max(now.Sub(publishedAt), 0)
+}
+
+// StartLoadReports publishes the analyzer's load right away and then every interval,
+// with the depth of queue as its backlog. The returned function stops the reports.
+func (s *Analyzer) StartLoadReports(queue MessageQueue, interval time.Duration) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.publishLoad(queue)
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// publishLoad publishes the current load of this replica
+func (s *Analyzer) publishLoad(queue MessageQueue) {
+	depth, _, err := queue.Pending()
+	if err != nil {
+		s.log.Warn("Failed to read analyze queue depth", slog.Any("error", err))
+		depth = 0
+	}
+
+	inFlight, avgWait, avgDuration := s.load.snapshot()
+	err = s.publisher.PublishAnalyzerLoad(context.Background(), messagebus.AnalyzerLoadMessage{
+		InstanceID:         s.instanceID(),
+		QueueDepth:         depth,
+		InFlight:           inFlight,
+		AvgWaitSeconds:     avgWait.Seconds(),
+		AvgDurationSeconds: avgDuration.Seconds(),
+		SentAt:             time.Now().UTC(),
+	})
+	if err != nil {
+		s.log.Error("Failed to publish analyzer load", slog.Any("error", err))
+	}
+}
+
+// instanceID returns the ID of this replica
+func (s *Analyzer) instanceID() string {
+	if s.cfg != nil && s.cfg.Service.InstanceID != "" {
+		return s.cfg.Service.InstanceID
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
@@ -0,0 +1,130 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"shared/messagebus"
+	"shared/mocks"
+	"strconv"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// fixedQueue reports a fixed number of pending messages
+type fixedQueue int
+
+func (q fixedQueue) Pending() (int, int, error) {
+	return int(q), 0, nil
+}
+
+func TestLoadTracker(t *testing.T) {
+	var load loadTracker
+
+	load.started(2 * time.Second)
+	load.started(-1)
+	inFlight, avgWait, avgDuration := load.snapshot()
+	assert.Equal(t, 2, inFlight)
+	assert.Equal(t, 2*time.Second, avgWait, "An unknown wait is not averaged in")
+	assert.Zero(t, avgDuration)
+
+	load.finished(10 * time.Second)
+	load.finished(20 * time.Second)
+	inFlight, _, avgDuration = load.snapshot()
+	assert.Equal(t, 0, inFlight)
+	assert.Equal(t, 12*time.Second, avgDuration)
+}
+
+func TestQueueWait(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name         string
+		header       nats.Header
+		expectedWait time.Duration
+	}{
+		{
+			name:         "MissingHeaders",
+			expectedWait: -1,
+		},
+		{
+			name:         "MalformedTimestamp",
+			header:       nats.Header{messagebus.PublishedAtHeader: []string{"yesterday"}},
+			expectedWait: -1,
+		},
+		{
+			name:         "ValidTimestamp",
+			header:       nats.Header{messagebus.PublishedAtHeader: []string{now.Add(-3 * time.Second).Format(time.RFC3339Nano)}},
+			expectedWait: 3 * time.Second,
+		},
+		{
+			name:         "ClockSkew",
+			header:       nats.Header{messagebus.PublishedAtHeader: []string{now.Add(time.Second).Format(time.RFC3339Nano)}},
+			expectedWait: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedWait, queueWait(&nats.Msg{Header: tc.header}, now))
+		})
+	}
+}
+
+func TestAnalyzer_LoadReports_Integration(t *testing.T) {
+	opts := natsserver.DefaultTestOptions
+	opts.Port = 8433
+	server := natsserver.RunServer(&opts)
+	defer server.Shutdown()
+
+	nc, err := nats.Connect("nats://127.0.0.1:" + strconv.Itoa(opts.Port))
+	require.NoError(t, err)
+	defer nc.Close()
+	bus := messagebus.New(nc, nil)
+
+	received := make(chan messagebus.AnalyzerLoadMessage, 10)
+	sub, err := bus.SubscribeToAnalyzerLoad(func(ctx context.Context, m *nats.Msg) {
+		var msg messagebus.AnalyzerLoadMessage
+		if err := json.Unmarshal(m.Data, &msg); err == nil {
+			received <- msg
+		}
+	})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+	require.NoError(t, nc.Flush())
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	analyzer := NewAnalyzer(
+		mocks.NewMockJobRepositoryInterface(ctrl),
+		mocks.NewMockTaskRepositoryInterface(ctrl),
+		bus,
+		WithLogger(slog.New(slog.DiscardHandler)),
+	)
+	analyzer.load.started(4 * time.Second)
+	analyzer.load.finished(30 * time.Second)
+	analyzer.load.started(time.Second)
+
+	stop := analyzer.StartLoadReports(fixedQueue(5), time.Hour)
+	defer stop()
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, messagebus.AnalyzerLoadMessageType, msg.Type)
+		assert.NotEmpty(t, msg.InstanceID)
+		assert.Equal(t, 5, msg.QueueDepth)
+		assert.Equal(t, 1, msg.InFlight)
+		assert.InDelta(t, 3.4, msg.AvgWaitSeconds, 0.001)
+		assert.Equal(t, 30.0, msg.AvgDurationSeconds)
+		assert.WithinDuration(t, time.Now(), msg.SentAt, 5*time.Second)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the load report")
+	}
+}
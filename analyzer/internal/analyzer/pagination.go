@@ -0,0 +1,153 @@
+package analyzer
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"net/url"
+	"shared/log"
+	"shared/models"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// paginationSignals is the rel="next" chain of the analyzed page: the first next and previous pages it names,
+// and once the chain is followed the pages merged into the result and why following stopped
+type paginationSignals struct {
+	next  string
+	prev  string
+	pages []models.PaginationPage
+	stop  models.PaginationStopReason
+}
+
+// followsPagination reports whether the rel="next" chain of a page is followed, it is not unless configured
+func (s *Analyzer) followsPagination() bool {
+	return s.cfg != nil && s.cfg.Analysis.FollowPagination
+}
+
+// paginationMaxPages returns how many next pages are followed after the analyzed one
+func (s *Analyzer) paginationMaxPages() int {
+	if s.cfg == nil {
+		return 0
+	}
+	return s.cfg.Analysis.PaginationMaxPages
+}
+
+// extractPagination records the first next and previous page named by a <link> or <a> with rel="next" or
+// rel="prev", previous being an alias of prev. Hrefs are resolved like links.
+func (s *Analyzer) extractPagination(n *html.Node, result *AnalysisResult) {
+	for _, rel := range strings.Fields(s.getElementAttribute(n, "rel")) {
+		var target *string
+		switch strings.ToLower(rel) {
+		case "next":
+			target = &result.pagination.next
+		case "prev", "previous":
+			target = &result.pagination.prev
+		default:
+			continue
+		}
+
+		href := strings.TrimSpace(s.getElementAttribute(n, "href"))
+		if *target != "" || href == "" || !s.shouldProcessLink(href) {
+			continue
+		}
+		*target = s.resolveURL(href, result.resolutionBase())
+	}
+}
+
+// followPagination follows the rel="next" chain of the analyzed page, merging the headings and links of up to
+// paginationMaxPages next pages into the result. Only pages on the host of the analyzed page are followed,
+// and the chain ends at the first page already in it, so a page naming itself or an earlier page stops it.
+func (s *Analyzer) followPagination(ctx context.Context, jobID string, result *AnalysisResult) {
+	if !s.followsPagination() || result.pagination.next == "" {
+		return
+	}
+	first, err := url.Parse(result.baseURL)
+	if err != nil {
+		return
+	}
+
+	visited := map[string]bool{normalizeURL(first): true}
+	result.pagination.pages = []models.PaginationPage{{
+		URL:       result.baseURL,
+		Headings:  maps.Clone(result.headings),
+		LinkCount: len(result.links),
+	}}
+
+	for next := result.pagination.next; next != ""; {
+		u, err := url.Parse(next)
+		switch {
+		case err != nil || normalizedHost(u) != normalizedHost(first):
+			result.pagination.stop = models.PaginationStopOtherHost
+		case visited[normalizeURL(u)]:
+			result.pagination.stop = models.PaginationStopLoop
+		case len(result.pagination.pages)-1 >= s.paginationMaxPages():
+			result.pagination.stop = models.PaginationStopMaxPages
+		}
+		if result.pagination.stop != "" {
+			return
+		}
+		visited[normalizeURL(u)] = true
+
+		page, err := s.fetchContent(ctx, next)
+		if err != nil {
+			s.log.Warn("Failed to fetch next page, pagination chain ends here",
+				slog.String("jobId", jobID),
+				log.URL("url", next),
+				log.Error(err))
+			result.pagination.stop = models.PaginationStopFetchFailed
+			return
+		}
+		doc, err := html.Parse(strings.NewReader(page.content))
+		if err != nil {
+			result.pagination.stop = models.PaginationStopFetchFailed
+			return
+		}
+
+		pageResult := &AnalysisResult{
+			baseURL:   next,
+			headings:  make(map[string]int),
+			links:     []string{},
+			seenLinks: result.seenLinks,
+		}
+		s.traverseNode(doc, pageResult)
+		mergePaginationPage(result, pageResult)
+		next = pageResult.pagination.next
+	}
+}
+
+// mergePaginationPage adds the headings and links of a next page to the result, recording which links are its own.
+// Links collapsed once stripped of tracking parameters are collapsed across the pages of the chain.
+func mergePaginationPage(result, page *AnalysisResult) {
+	result.pagination.pages = append(result.pagination.pages, models.PaginationPage{
+		URL:        page.baseURL,
+		Headings:   page.headings,
+		LinkOffset: len(result.links),
+		LinkCount:  len(page.links),
+	})
+
+	for level, count := range page.headings {
+		result.headings[level] += count
+	}
+	result.links = append(result.links, page.links...)
+	result.counts.add(countInternalLinks, page.counts.get(countInternalLinks))
+	result.counts.add(countExternalLinks, page.counts.get(countExternalLinks))
+	result.linkClassifications = append(result.linkClassifications, page.linkClassifications...)
+	result.originalLinks = append(result.originalLinks, page.originalLinks...)
+	result.collapsedLinks += page.collapsedLinks
+	result.seenLinks = page.seenLinks
+}
+
+// buildPagination returns the pagination of the result, nil when the page names no next or previous page
+func (r *AnalysisResult) buildPagination() *models.Pagination {
+	if r.pagination.next == "" && r.pagination.prev == "" {
+		return nil
+	}
+	return &models.Pagination{
+		NextURL:    r.pagination.next,
+		PrevURL:    r.pagination.prev,
+		Pages:      r.pagination.pages,
+		StopReason: r.pagination.stop,
+	}
+}
@@ -0,0 +1,219 @@
+package analyzer
+
+import (
+	"analyzer/internal/config"
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"shared/models"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+// pagesRoundTripper serves the fixture of each URL, 404 for the others, and records the URLs requested
+type pagesRoundTripper struct {
+	mu        sync.Mutex
+	pages     map[string]string
+	requested []string
+}
+
+func (m *pagesRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requested = append(m.requested, req.URL.String())
+
+	fixture, ok := m.pages[req.URL.String()]
+	if !ok {
+		return statusResponse(http.StatusNotFound, "")(req)
+	}
+	content, err := os.ReadFile("testdata/" + fixture)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/html"}},
+		Body:       io.NopCloser(bytes.NewReader(content)),
+		Request:    req,
+	}, nil
+}
+
+func TestAnalyzer_ExtractPagination(t *testing.T) {
+	testCases := []struct {
+		name         string
+		html         string
+		expectedNext string
+		expectedPrev string
+	}{
+		{
+			name:         "LinkRel",
+			html:         `<head><link rel="prev" href="https://example.com/list?page=1"><link rel="next" href="https://example.com/list?page=3"></head>`,
+			expectedNext: "https://example.com/list?page=3",
+			expectedPrev: "https://example.com/list?page=1",
+		},
+		{
+			name:         "AnchorRel",
+			html:         `<body><a rel="nofollow Next" href="/list?page=2">More</a></body>`,
+			expectedNext: "https://example.com/list?page=2",
+		},
+		{
+			name:         "PreviousAlias",
+			html:         `<body><a rel="previous" href="page-1.html">Back</a></body>`,
+			expectedPrev: "https://example.com/blog/page-1.html",
+		},
+		{
+			name:         "FirstWins",
+			html:         `<head><link rel="next" href="/list?page=2"></head><body><a rel="next" href="/other">Next</a></body>`,
+			expectedNext: "https://example.com/list?page=2",
+		},
+		{
+			name: "None",
+			html: `<body><a href="/list?page=2">2</a><a rel="next" href="javascript:void(0)">Next</a></body>`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+			doc, err := html.Parse(strings.NewReader("<html>" + tc.html + "</html>"))
+			require.NoError(t, err)
+
+			result := &AnalysisResult{baseURL: "https://example.com/blog/", headings: make(map[string]int)}
+			analyzer.traverseNode(doc, result)
+
+			pagination := result.snapshot().Pagination
+			if tc.expectedNext == "" && tc.expectedPrev == "" {
+				assert.Nil(t, pagination)
+				return
+			}
+			require.NotNil(t, pagination)
+			assert.Equal(t, tc.expectedNext, pagination.NextURL)
+			assert.Equal(t, tc.expectedPrev, pagination.PrevURL)
+			assert.Empty(t, pagination.Pages, "the chain is only followed when configured")
+		})
+	}
+}
+
+func TestAnalyzer_FollowPagination(t *testing.T) {
+	chain := map[string]string{
+		"https://example.com/archive":        "pagination_page1.html",
+		"https://example.com/archive?page=2": "pagination_page2.html",
+		"https://example.com/archive?page=3": "pagination_page3.html",
+	}
+
+	testCases := []struct {
+		name              string
+		startURL          string
+		pages             map[string]string
+		maxPages          int
+		expectedPages     []models.PaginationPage
+		expectedStop      models.PaginationStopReason
+		expectedHeadings  map[string]int
+		expectedRequested int
+	}{
+		{
+			name:     "ThreePageChain",
+			startURL: "https://example.com/archive",
+			pages:    chain,
+			maxPages: 5,
+			expectedPages: []models.PaginationPage{
+				{URL: "https://example.com/archive", Headings: map[string]int{"h1": 1, "h2": 1}, LinkOffset: 0, LinkCount: 3},
+				{URL: "https://example.com/archive?page=2", Headings: map[string]int{"h1": 1, "h2": 2}, LinkOffset: 3, LinkCount: 2},
+				{URL: "https://example.com/archive?page=3", Headings: map[string]int{"h1": 1, "h3": 1}, LinkOffset: 5, LinkCount: 1},
+			},
+			expectedHeadings:  map[string]int{"h1": 3, "h2": 3, "h3": 1},
+			expectedRequested: 2,
+		},
+		{
+			name:     "MaxPages",
+			startURL: "https://example.com/archive",
+			pages:    chain,
+			maxPages: 1,
+			expectedPages: []models.PaginationPage{
+				{URL: "https://example.com/archive", Headings: map[string]int{"h1": 1, "h2": 1}, LinkOffset: 0, LinkCount: 3},
+				{URL: "https://example.com/archive?page=2", Headings: map[string]int{"h1": 1, "h2": 2}, LinkOffset: 3, LinkCount: 2},
+			},
+			expectedStop:      models.PaginationStopMaxPages,
+			expectedHeadings:  map[string]int{"h1": 2, "h2": 3},
+			expectedRequested: 1,
+		},
+		{
+			name:     "SelfReferencingLoop",
+			startURL: "https://example.com/results",
+			pages:    map[string]string{"https://example.com/results": "pagination_loop.html"},
+			maxPages: 5,
+			expectedPages: []models.PaginationPage{
+				{URL: "https://example.com/results", Headings: map[string]int{"h1": 1}, LinkOffset: 0, LinkCount: 1},
+			},
+			expectedStop:      models.PaginationStopLoop,
+			expectedHeadings:  map[string]int{"h1": 1},
+			expectedRequested: 0,
+		},
+		{
+			name:     "OtherHost",
+			startURL: "https://example.com/mirror",
+			pages:    map[string]string{"https://example.com/mirror": "pagination_other_host.html"},
+			maxPages: 5,
+			expectedPages: []models.PaginationPage{
+				{URL: "https://example.com/mirror", Headings: map[string]int{"h1": 1}, LinkOffset: 0, LinkCount: 1},
+			},
+			expectedStop:      models.PaginationStopOtherHost,
+			expectedHeadings:  map[string]int{"h1": 1},
+			expectedRequested: 0,
+		},
+		{
+			name:     "FetchFailed",
+			startURL: "https://example.com/archive",
+			pages:    map[string]string{"https://example.com/archive": "pagination_page1.html"},
+			maxPages: 5,
+			expectedPages: []models.PaginationPage{
+				{URL: "https://example.com/archive", Headings: map[string]int{"h1": 1, "h2": 1}, LinkOffset: 0, LinkCount: 3},
+			},
+			expectedStop:      models.PaginationStopFetchFailed,
+			expectedHeadings:  map[string]int{"h1": 1, "h2": 1},
+			expectedRequested: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			transport := &pagesRoundTripper{pages: tc.pages}
+			analyzer := NewAnalyzer(nil, nil, nil,
+				WithHTTPClient(&http.Client{Transport: transport}),
+				WithLogger(slog.New(slog.DiscardHandler)),
+				WithConfig(&config.Config{Analysis: config.AnalysisConfig{
+					FollowPagination:   true,
+					PaginationMaxPages: tc.maxPages,
+				}}),
+			)
+
+			f, err := os.Open("testdata/" + tc.pages[tc.startURL])
+			require.NoError(t, err)
+			defer f.Close()
+			doc, err := html.Parse(f)
+			require.NoError(t, err)
+
+			result := &AnalysisResult{baseURL: tc.startURL, headings: make(map[string]int), links: []string{}}
+			analyzer.traverseNode(doc, result)
+			analyzer.followPagination(context.Background(), "job-1", result)
+
+			pagination := result.snapshot().Pagination
+			require.NotNil(t, pagination)
+			assert.Equal(t, tc.expectedPages, pagination.Pages)
+			assert.Equal(t, tc.expectedStop, pagination.StopReason)
+			assert.Equal(t, tc.expectedHeadings, result.headings)
+			assert.Len(t, transport.requested, tc.expectedRequested)
+
+			last := tc.expectedPages[len(tc.expectedPages)-1]
+			assert.Len(t, result.links, last.LinkOffset+last.LinkCount, "the links of every page followed are merged")
+		})
+	}
+}
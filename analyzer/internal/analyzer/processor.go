@@ -3,34 +3,57 @@ package analyzer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"runtime/debug"
+	"shared/audit"
+	"shared/log"
 	"shared/messagebus"
 	"shared/models"
+	"shared/repository"
 	"time"
 
 	"github.com/nats-io/nats.go"
 )
 
+// errAnalysisPanicked classifies the failure of an analysis that panicked
+var errAnalysisPanicked = errors.New("analysis panicked")
+
+// errJobFinished reports that another writer finished the job before the analysis could start it
+var errJobFinished = errors.New("job has already finished")
+
+// maxStartAttempts is how many times a job's move to running is tried against fresh state after version conflicts
+const maxStartAttempts = 3
+
 // ProcessAnalyzeMessage handles incoming analyze messages
 func (s *Analyzer) ProcessAnalyzeMessage(ctx context.Context, msg *nats.Msg) {
+	s.inFlight.Add(1)
+	defer s.inFlight.Done()
+
 	var am messagebus.AnalyzeMessage
 	if err := json.Unmarshal(msg.Data, &am); err != nil {
 		s.log.Error("Failed to unmarshal analyze message",
 			slog.Any("error", err),
-			slog.String("data", string(msg.Data)))
+			slog.String("data", log.Truncate(string(msg.Data), log.MaxPayloadBytes)))
 		return
 	}
 
 	s.log.Info("Processing analyze request", slog.String("jobId", am.JobId))
 
+	// Status changes are audited against the request that submitted the job
+	ctx = audit.WithSource(ctx, am.Source)
+
 	start := time.Now()
-	err := s.analyzeURL(ctx, am)
+	s.load.started(queueWait(msg, start))
+	defer func() { s.load.finished(time.Since(start)) }()
+
+	err := s.runAnalysis(ctx, am)
 	if err != nil {
 		s.log.Error("Failed to process analyze request",
 			slog.String("jobId", am.JobId),
-			slog.Any("error", err))
-		s.metrics.RecordAnalysisJob(false, time.Since(start).Seconds())
+			log.Error(err))
+		s.metrics.RecordAnalysisJob(ctx, false, time.Since(start).Seconds())
 		return
 	}
 
@@ -39,106 +62,367 @@ func (s *Analyzer) ProcessAnalyzeMessage(ctx context.Context, msg *nats.Msg) {
 		slog.String("jobId", am.JobId),
 		slog.Duration("processingTime", d))
 
-	s.metrics.RecordAnalysisJob(true, d.Seconds())
+	s.metrics.RecordAnalysisJob(ctx, true, d.Seconds())
+}
+
+// Wait blocks until the analyze messages being processed have finished, or until ctx is done.
+// Callers stop the subscription first so no new message starts while waiting.
+func (s *Analyzer) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-// analyzeURL performs the complete URL analysis workflow
-func (s *Analyzer) analyzeURL(ctx context.Context, am messagebus.AnalyzeMessage) error {
+// runAnalysis analyzes the URL of the message.
+// A panic is recovered and fails the job with an errAnalysisPanicked error, so it cannot take down the subscription.
+func (s *Analyzer) runAnalysis(ctx context.Context, am messagebus.AnalyzeMessage) (err error) {
+	// job is the job once read, the recovery fails it under the attempt this analysis started it with
+	var job *models.Job
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", errAnalysisPanicked, r)
+			s.log.Error("Analysis panicked",
+				slog.String("jobId", am.JobId),
+				slog.Any("panic", r),
+				slog.String("stack", string(debug.Stack())))
+			if job != nil {
+				s.failAllTasks(ctx, job, err)
+			}
+		}
+	}()
+
+	job, err = s.loadJob(ctx, am)
+	if err != nil || job == nil {
+		return err
+	}
+	return s.analyzeURL(ctx, am, job)
+}
+
+// loadJob reads the job of the message, nil when there is nothing left to analyze
+func (s *Analyzer) loadJob(ctx context.Context, am messagebus.AnalyzeMessage) (*models.Job, error) {
 	job, err := s.jobRepo.GetJob(ctx, am.JobId)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Purged before the message was consumed, there is no job or task left to fail
+		s.log.Warn("Dropping analyze message of a job that does not exist",
+			slog.String("jobId", am.JobId))
+		return nil, nil
+	}
 	if err != nil {
-		s.failAllTasks(ctx, am.JobId)
-		return fmt.Errorf("job not found: %w", err)
+		err = &RepositoryError{Op: "get job", Err: err}
+		s.failUnreadJob(ctx, am.JobId, err)
+		return nil, err
 	}
 
+	if job.Status.IsTerminal() {
+		s.log.Info("Skipping job that has already finished",
+			slog.String("jobId", am.JobId),
+			slog.String("status", string(job.Status)))
+		return nil, nil
+	}
+	// The stored attempt is another analysis's, this one holds the job once it started it
+	job.AttemptID = ""
+	return job, nil
+}
+
+// analyzeURL performs the complete URL analysis workflow of a job read by loadJob.
+// startJob sets the job's attempt, which the writes after it are guarded with.
+func (s *Analyzer) analyzeURL(ctx context.Context, am messagebus.AnalyzeMessage, job *models.Job) error {
 	s.log.Info("Starting analysis",
 		slog.String("jobId", am.JobId),
-		slog.String("url", job.URL))
+		log.URL("url", job.URL))
 
-	if err := s.updateJobStatus(ctx, am.JobId, models.JobStatusRunning); err != nil {
-		s.failAllTasks(ctx, am.JobId)
-		return fmt.Errorf("failed to update job status: %w", err)
+	stopTracking := s.trackProgress(job)
+	defer stopTracking()
+
+	if err := s.startJob(ctx, job, s.attemptOf(am)); err != nil {
+		if errors.Is(err, errJobFinished) {
+			s.log.Info("Skipping job that finished before the analysis started",
+				slog.String("jobId", am.JobId))
+			return nil
+		}
+		err = &RepositoryError{Op: "update job status", Err: err}
+		s.failAllTasks(ctx, job, err)
+		return err
 	}
 
-	content, err := s.fetchContent(ctx, job.URL)
+	stopHeartbeat := s.startHeartbeat(ctx, job)
+	defer stopHeartbeat()
+
+	// The time budget of the job runs from the fetch on
+	fetchStart := time.Now()
+	page, err := s.fetchContent(ctx, job.URL)
 	if err != nil {
-		s.failAllTasks(ctx, am.JobId)
-		return fmt.Errorf("failed to fetch content: %w", err)
+		job.FetchStatusCode = fetchStatusOf(err)
+		err = &FetchError{Err: err}
+		s.failAllTasks(ctx, job, err)
+		return err
 	}
+	job.FetchStatusCode = page.statusCode
 
-	result, err := s.performAnalysis(ctx, am.JobId, job.URL, content)
+	// Nothing can be analyzed from a page that cannot be parsed, other failures leave the job completed with warnings
+	result, err := s.performAnalysis(ctx, job, page.content, fetchStart)
 	if err != nil {
-		s.failAllTasks(ctx, am.JobId)
-		return fmt.Errorf("failed to analyze HTML: %w", err)
+		err = &ParseError{Err: err}
+		s.failAllTasks(ctx, job, err)
+		return err
 	}
+	s.applyPageDetails(&result, page)
 
 	return s.completeJob(ctx, *job, result)
 }
 
-// performAnalysis creates and runs the HTML analyzer
-func (s *Analyzer) performAnalysis(ctx context.Context, jobID, url, content string) (models.AnalyzeResult, error) {
+// performAnalysis creates and runs the HTML analyzer, the time budget of the job running from budgetStart
+func (s *Analyzer) performAnalysis(ctx context.Context, job *models.Job, content string, budgetStart time.Time) (models.AnalyzeResult, error) {
 	result := &AnalysisResult{
-		headings: make(map[string]int),
-		links:    []string{},
-		baseURL:  url,
+		headings:           make(map[string]int),
+		links:              []string{},
+		baseURL:            job.URL,
+		linkDepthHistogram: make(map[string]int),
+		skippedTasks:       job.SkippedTasks(),
+		verifyScope:        s.verifyScopeFor(job),
+		headerProfile:      s.headerProfileFor(job),
+		pageBytes:          len(content),
+		budgetStart:        budgetStart,
+		maxStoredLinks:     s.maxStoredLinks(),
 	}
 
-	if err := s.analyzeHTML(ctx, jobID, content, result); err != nil {
+	if err := s.analyzeHTML(ctx, job, content, result); err != nil {
 		return models.AnalyzeResult{}, err
 	}
 
-	return s.buildResult(result), nil
+	return result.snapshot(), nil
+}
+
+// verifyScopeFor returns the links verified for a job, its own scope or else the configured one
+func (s *Analyzer) verifyScopeFor(job *models.Job) models.LinkScope {
+	if job.VerifyScope != "" {
+		return job.VerifyScope
+	}
+	return s.verifyScope
+}
+
+// headerProfileFor returns the request headers links are verified with for a job, its own profile or else the
+// configured one
+func (s *Analyzer) headerProfileFor(job *models.Job) models.HeaderProfile {
+	if job.HeaderProfile != "" {
+		return job.HeaderProfile
+	}
+	return s.headerProfile
+}
+
+// attemptOf returns the attempt ID of an analyze message.
+// Messages from publishers that do not set one get an ID of this replica's own, so the job is still guarded.
+func (s *Analyzer) attemptOf(am messagebus.AnalyzeMessage) string {
+	if am.AttemptID != "" {
+		return am.AttemptID
+	}
+	return fmt.Sprintf("%s-%d", s.instanceID(), time.Now().UnixNano())
+}
+
+// startJob moves the job to running under attemptID, on condition that it is still at the version it was read at.
+// When another writer, such as a cancel or a redelivered message, updated the job first, the job is read
+// again and the move retried on the fresh state. A job that finished meanwhile yields errJobFinished.
+// Once started, job.AttemptID is set and the job is only finished while it still runs under that attempt.
+func (s *Analyzer) startJob(ctx context.Context, job *models.Job, attemptID string) error {
+	version := job.Version
+	for attempt := 1; ; attempt++ {
+		err := s.updateJobStatus(ctx, job, models.JobStatusRunning, repository.IfVersion(version), repository.WithAttempt(attemptID))
+		if err == nil {
+			job.AttemptID = attemptID
+		}
+		if !errors.Is(err, repository.ErrVersionConflict) || attempt == maxStartAttempts {
+			return err
+		}
+
+		fresh, err := s.jobRepo.GetJob(ctx, job.ID)
+		if err != nil {
+			return err
+		}
+		if fresh.Status.IsTerminal() {
+			return errJobFinished
+		}
+
+		s.log.Debug("Retrying job start after a concurrent update",
+			slog.String("jobId", job.ID),
+			slog.Int64("expectedVersion", version),
+			slog.Int64("version", fresh.Version))
+		version = fresh.Version
+	}
 }
 
 // updateJobStatus updates job status and publishes update
-func (s *Analyzer) updateJobStatus(ctx context.Context, jobID string, status models.JobStatus) error {
-	if err := s.jobRepo.UpdateJobStatus(ctx, jobID, status); err != nil {
+func (s *Analyzer) updateJobStatus(ctx context.Context, job *models.Job, status models.JobStatus, opts ...repository.UpdateOption) error {
+	if err := s.jobRepo.UpdateJobStatus(ctx, job.ID, status, opts...); err != nil {
 		return err
 	}
+	s.auditStatus(ctx, job.ID, status)
 
 	return s.publisher.PublishJobUpdate(ctx, messagebus.JobUpdateMessage{
-		Type:   messagebus.JobUpdateMessageType,
-		JobID:  jobID,
-		Status: string(status),
-		Result: nil,
+		Type:     messagebus.JobUpdateMessageType,
+		JobID:    job.ID,
+		Status:   string(status),
+		URL:      job.URL,
+		Result:   nil,
+		Progress: terminalProgress(status),
 	})
 }
 
+// auditStatus writes the audit record of a stored job status change
+func (s *Analyzer) auditStatus(ctx context.Context, jobID string, status models.JobStatus) {
+	event := audit.EventJobStatusChanged
+	switch status {
+	case models.JobStatusCompleted:
+		event = audit.EventJobCompleted
+	case models.JobStatusFailed:
+		event = audit.EventJobFailed
+	}
+
+	s.audit.Record(ctx, audit.Record{Event: event, JobID: jobID, Status: string(status)})
+}
+
 // completeJob finalizes the job with results
 func (s *Analyzer) completeJob(ctx context.Context, job models.Job, result models.AnalyzeResult) error {
 	s.log.Info("HTML analysis completed",
 		slog.String("jobId", job.ID),
 		slog.String("htmlVersion", result.HtmlVersion),
 		slog.Int("linkCount", len(result.Links)),
+		slog.Bool("linksTruncated", result.LinksTruncated),
 		slog.Int("internalLinks", result.InternalLinkCount),
 		slog.Int("externalLinks", result.ExternalLinkCount),
 		slog.Int("accessibleLinks", result.AccessibleLinks),
 		slog.Int("inaccessibleLinks", result.InaccessibleLinks),
-		slog.Bool("hasLoginForm", result.HasLoginForm))
+		slog.Bool("hasLoginForm", result.HasLoginForm),
+		slog.Any("failedTasks", result.FailedTasks))
 
 	completedStatus := models.JobStatusCompleted
-	if err := s.jobRepo.UpdateJob(ctx, job.ID, &completedStatus, &result); err != nil {
+	opts := append(attemptGuard(&job), repository.WithFetchStatus(job.FetchStatusCode))
+	if err := s.jobRepo.UpdateJob(ctx, job.ID, &completedStatus, &result, opts...); err != nil {
+		if s.isStaleWrite(&job, staleWriteComplete, err) {
+			return nil
+		}
 		return fmt.Errorf("failed to update job: %w", err)
 	}
+	s.auditStatus(ctx, job.ID, completedStatus)
+
+	// The update goes to every client, pages with thousands of links make it large
+	broadcast := result
+	if !s.broadcastLinks {
+		broadcast = result.WithoutLinks()
+	}
 
 	return s.publisher.PublishJobUpdate(ctx, messagebus.JobUpdateMessage{
-		Type:   messagebus.JobUpdateMessageType,
-		JobID:  job.ID,
-		Status: string(models.JobStatusCompleted),
-		Result: &result,
+		Type:            messagebus.JobUpdateMessageType,
+		JobID:           job.ID,
+		Status:          string(models.JobStatusCompleted),
+		URL:             job.URL,
+		Result:          &broadcast,
+		Progress:        terminalProgress(models.JobStatusCompleted),
+		FetchStatusCode: job.FetchStatusCode,
 	})
 }
 
-// failAllTasks marks all tasks as failed
-func (s *Analyzer) failAllTasks(ctx context.Context, jobID string) {
-	s.updateTaskStatus(ctx, jobID, models.TaskTypeExtracting, models.TaskStatusFailed)
-	s.updateTaskStatus(ctx, jobID, models.TaskTypeIdentifyingVersion, models.TaskStatusFailed)
-	s.updateTaskStatus(ctx, jobID, models.TaskTypeAnalyzing, models.TaskStatusFailed)
-	s.updateTaskStatus(ctx, jobID, models.TaskTypeVerifyingLinks, models.TaskStatusFailed)
-	s.updateJobStatus(ctx, jobID, models.JobStatusFailed)
+// Writes of a job that can be refused for a stale attempt, reported to metrics
+const (
+	staleWriteComplete = "complete"
+	staleWriteFail     = "fail"
+	staleWritePartial  = "partial_result"
+)
+
+// attemptGuard returns the update option making a write conditional on the job still running under the attempt
+// of this analysis. Before the analysis started the job, the write is conditional on the job still being pending,
+// so it cannot overwrite the state of another attempt.
+func attemptGuard(job *models.Job) []repository.UpdateOption {
+	if job.AttemptID == "" {
+		return []repository.UpdateOption{repository.IfPending()}
+	}
+	return []repository.UpdateOption{repository.IfAttempt(job.AttemptID)}
+}
+
+// isStaleWrite reports whether a write was refused because the job finished or was started again by another
+// attempt in the meantime. The write is then dropped: the job's newer state wins over this analysis.
+func (s *Analyzer) isStaleWrite(job *models.Job, write string, err error) bool {
+	if !errors.Is(err, repository.ErrStaleAttempt) {
+		return false
+	}
+
+	s.log.Warn("Dropping job update of a superseded analysis attempt",
+		slog.String("jobId", job.ID),
+		slog.String("attemptId", job.AttemptID),
+		slog.String("write", write))
+	s.metrics.RecordStaleJobWrite(write)
+	return true
+}
+
+// failAllTasks fails the job with the code and reason of cause, then marks all tasks selected for it as failed,
+// skipped tasks keeping their status. The tasks are left alone when the job's newer state won over this analysis.
+func (s *Analyzer) failAllTasks(ctx context.Context, job *models.Job, cause error) {
+	if stale, _ := s.failJob(ctx, job, cause); stale {
+		return
+	}
+	for _, taskType := range models.TaskTypes() {
+		if job.RunsTask(taskType) {
+			s.updateTaskStatus(ctx, job.ID, taskType, models.TaskStatusFailed)
+		}
+	}
+}
+
+// failUnreadJob fails a job that could not be read, only while it is still pending as no attempt started it.
+// Not knowing which tasks the job selected, it fails the stored tasks that have not finished.
+func (s *Analyzer) failUnreadJob(ctx context.Context, jobID string, cause error) {
+	if stale, _ := s.failJob(ctx, &models.Job{ID: jobID}, cause); stale {
+		return
+	}
+
+	tasks, err := s.taskRepo.GetTasksByJobId(ctx, jobID)
+	if err != nil {
+		s.log.Error("Failed to get tasks of unread job", slog.String("jobId", jobID), slog.Any("error", err))
+		return
+	}
+	for _, task := range tasks {
+		if !task.Status.IsTerminal() {
+			s.updateTaskStatus(ctx, jobID, task.Type, models.TaskStatusFailed)
+		}
+	}
+}
+
+// failJob marks the job failed and publishes the update, both carrying why it failed.
+// It reports whether the write was dropped as stale.
+func (s *Analyzer) failJob(ctx context.Context, job *models.Job, cause error) (bool, error) {
+	code, reason := failureOf(cause)
+	status := models.JobStatusFailed
+	opts := append(attemptGuard(job), repository.WithFailure(code, reason), repository.WithFetchStatus(job.FetchStatusCode))
+	if err := s.jobRepo.UpdateJobStatus(ctx, job.ID, status, opts...); err != nil {
+		return s.isStaleWrite(job, staleWriteFail, err), err
+	}
+	s.auditStatus(ctx, job.ID, status)
+
+	return false, s.publisher.PublishJobUpdate(ctx, messagebus.JobUpdateMessage{
+		Type:            messagebus.JobUpdateMessageType,
+		JobID:           job.ID,
+		Status:          string(status),
+		URL:             job.URL,
+		Progress:        terminalProgress(status),
+		FailureCode:     code,
+		FailureReason:   reason,
+		FetchStatusCode: job.FetchStatusCode,
+	})
 }
 
 // updateTaskStatus updates task status and publishes update
 func (s *Analyzer) updateTaskStatus(ctx context.Context, jobID string, taskType models.TaskType, status models.TaskStatus) {
+	if !s.isKnownTaskType(jobID, taskType) {
+		return
+	}
+
 	if err := s.taskRepo.UpdateTaskStatus(ctx, jobID, taskType, status); err != nil {
 		s.log.Error("Failed to update task status",
 			slog.String("jobId", jobID),
@@ -159,4 +443,20 @@ func (s *Analyzer) updateTaskStatus(ctx context.Context, jobID string, taskType
 			slog.String("status", string(status)),
 			slog.Any("error", err))
 	}
+
+	s.reportTaskProgress(ctx, jobID, taskType, status)
+}
+
+// isKnownTaskType reports whether the task type is registered.
+// An unknown type is a programming error, so it is logged and counted instead of being persisted or published.
+func (s *Analyzer) isKnownTaskType(jobID string, taskType models.TaskType) bool {
+	if models.IsValidTaskType(taskType) {
+		return true
+	}
+
+	s.log.Error("Refusing update for unknown task type",
+		slog.String("jobId", jobID),
+		slog.String("taskType", string(taskType)))
+	s.metrics.RecordInvalidTaskType()
+	return false
 }
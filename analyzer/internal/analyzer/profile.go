@@ -0,0 +1,51 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"shared/log"
+	"shared/models"
+)
+
+// browserUserAgent is the User-Agent of the browser header profile, that of a desktop Chrome
+const browserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+// browserHeaders are the headers a desktop browser sends when opening a link, sent by the browser header profile.
+// Accept-Encoding is left to each request, as link verification discards the body.
+var browserHeaders = map[string]string{
+	"User-Agent":                browserUserAgent,
+	"Accept":                    "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
+	"Accept-Language":           "en-US,en;q=0.9",
+	"Sec-Fetch-Dest":            "document",
+	"Sec-Fetch-Mode":            "navigate",
+	"Sec-Fetch-Site":            "none",
+	"Sec-Fetch-User":            "?1",
+	"Upgrade-Insecure-Requests": "1",
+}
+
+// setProfileHeaders sets the headers of a header profile on a link verification request.
+// Only the browser profile sets any, the fallback profile starts out with the default headers.
+func setProfileHeaders(header http.Header, profile models.HeaderProfile) {
+	if profile != models.HeaderProfileBrowser {
+		return
+	}
+	for name, value := range browserHeaders {
+		header.Set(name, value)
+	}
+}
+
+// isBlockedStatus reports whether a link's status suggests the request was refused for not coming from a browser
+func isBlockedStatus(statusCode int) bool {
+	return statusCode == http.StatusForbidden || statusCode == http.StatusTooManyRequests
+}
+
+// retryAsBrowser verifies a link the default headers were refused for once more, with the browser's.
+// The description tells that the outcome was reached with the browser headers, and what the default ones got.
+func (s *Analyzer) retryAsBrowser(ctx context.Context, link string, blocked linkCheck) linkCheck {
+	s.log.Debug("Retrying link with browser headers", log.URL("url", link), "statusCode", blocked.statusCode)
+
+	check := s.checkLink(ctx, link, models.HeaderProfileBrowser)
+	check.description += fmt.Sprintf(" (with browser headers, default headers got HTTP %d)", blocked.statusCode)
+	return check
+}
@@ -0,0 +1,167 @@
+package analyzer
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"shared/models"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// botBlockingRoundTripper answers requests without a browser User-Agent with blockedStatus and the others with 200,
+// recording the headers of every request
+type botBlockingRoundTripper struct {
+	blockedStatus int
+	mu            sync.Mutex
+	headers       []http.Header
+}
+
+func (b *botBlockingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	b.mu.Lock()
+	b.headers = append(b.headers, req.Header.Clone())
+	b.mu.Unlock()
+
+	statusCode := http.StatusOK
+	if !strings.HasPrefix(req.Header.Get("User-Agent"), "Mozilla/") {
+		statusCode = b.blockedStatus
+	}
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}, nil
+}
+
+func TestSetProfileHeaders(t *testing.T) {
+	testCases := []struct {
+		name     string
+		profile  models.HeaderProfile
+		expected http.Header
+	}{
+		{name: "Default", profile: models.HeaderProfileDefault, expected: http.Header{}},
+		// The fallback profile starts out with the default headers
+		{name: "Fallback", profile: models.HeaderProfileFallback, expected: http.Header{}},
+		{name: "Unset", profile: "", expected: http.Header{}},
+		{
+			name:    "Browser",
+			profile: models.HeaderProfileBrowser,
+			expected: http.Header{
+				"User-Agent":                {browserUserAgent},
+				"Accept":                    {"text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8"},
+				"Accept-Language":           {"en-US,en;q=0.9"},
+				"Sec-Fetch-Dest":            {"document"},
+				"Sec-Fetch-Mode":            {"navigate"},
+				"Sec-Fetch-Site":            {"none"},
+				"Sec-Fetch-User":            {"?1"},
+				"Upgrade-Insecure-Requests": {"1"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			setProfileHeaders(header, tc.profile)
+			assert.Equal(t, tc.expected, header)
+		})
+	}
+}
+
+func TestAnalyzer_VerifyLink_HeaderProfile(t *testing.T) {
+	testCases := []struct {
+		name          string
+		profile       models.HeaderProfile
+		blockedStatus int
+		expected      models.TaskStatus
+		// expectedAgents are the User-Agents of the requests sent, in order, empty for the client's own
+		expectedAgents      []string
+		expectedDescription string
+	}{
+		{
+			name:                "DefaultBlocked",
+			profile:             models.HeaderProfileDefault,
+			blockedStatus:       http.StatusForbidden,
+			expected:            models.TaskStatusFailed,
+			expectedAgents:      []string{""},
+			expectedDescription: "HTTP 403: Forbidden",
+		},
+		{
+			name:                "Browser",
+			profile:             models.HeaderProfileBrowser,
+			blockedStatus:       http.StatusForbidden,
+			expected:            models.TaskStatusCompleted,
+			expectedAgents:      []string{browserUserAgent},
+			expectedDescription: "HTTP 200: OK",
+		},
+		{
+			name:                "FallbackAfter403",
+			profile:             models.HeaderProfileFallback,
+			blockedStatus:       http.StatusForbidden,
+			expected:            models.TaskStatusCompleted,
+			expectedAgents:      []string{"", browserUserAgent},
+			expectedDescription: "HTTP 200: OK (with browser headers, default headers got HTTP 403)",
+		},
+		{
+			name:                "FallbackAfter429",
+			profile:             models.HeaderProfileFallback,
+			blockedStatus:       http.StatusTooManyRequests,
+			expected:            models.TaskStatusCompleted,
+			expectedAgents:      []string{"", browserUserAgent},
+			expectedDescription: "HTTP 200: OK (with browser headers, default headers got HTTP 429)",
+		},
+		{
+			name:                "FallbackNotForOtherStatuses",
+			profile:             models.HeaderProfileFallback,
+			blockedStatus:       http.StatusNotFound,
+			expected:            models.TaskStatusFailed,
+			expectedAgents:      []string{""},
+			expectedDescription: "HTTP 404: Not Found",
+		},
+		{
+			name:                "FallbackNotNeeded",
+			profile:             models.HeaderProfileFallback,
+			blockedStatus:       http.StatusOK,
+			expected:            models.TaskStatusCompleted,
+			expectedAgents:      []string{""},
+			expectedDescription: "HTTP 200: OK",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			transport := &botBlockingRoundTripper{blockedStatus: tc.blockedStatus}
+			s := NewAnalyzer(nil, nil, nil,
+				WithHTTPClient(&http.Client{Transport: transport}),
+				WithLogger(slog.New(slog.DiscardHandler)))
+
+			check := s.verifyLink(context.Background(), "https://example.com/page", tc.profile)
+
+			assert.Equal(t, tc.expected, check.status)
+			assert.Equal(t, tc.expectedDescription, check.description)
+			require.Len(t, transport.headers, len(tc.expectedAgents))
+			for i, agent := range tc.expectedAgents {
+				assert.Equal(t, agent, transport.headers[i].Get("User-Agent"), "request %d", i)
+				if agent == browserUserAgent {
+					assert.Equal(t, "navigate", transport.headers[i].Get("Sec-Fetch-Mode"), "request %d", i)
+				} else {
+					assert.Empty(t, transport.headers[i].Get("Sec-Fetch-Mode"), "request %d", i)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzer_HeaderProfileFor(t *testing.T) {
+	s := NewAnalyzer(nil, nil, nil, WithHeaderProfile(models.HeaderProfileFallback))
+
+	assert.Equal(t, models.HeaderProfileFallback, s.headerProfileFor(&models.Job{}))
+	assert.Equal(t, models.HeaderProfileBrowser, s.headerProfileFor(&models.Job{HeaderProfile: models.HeaderProfileBrowser}))
+	assert.Equal(t, models.HeaderProfileDefault, NewAnalyzer(nil, nil, nil).headerProfileFor(&models.Job{}))
+}
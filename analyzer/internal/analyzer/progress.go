@@ -0,0 +1,163 @@
+package analyzer
+
+import (
+	"context"
+	"log/slog"
+	"shared/messagebus"
+	"shared/models"
+	"sync"
+	"time"
+)
+
+// jobProgress tracks the task states of a running job to compute its overall progress
+type jobProgress struct {
+	// url is the job's page, carried by its progress updates
+	url       string
+	mu        sync.Mutex
+	tasks     map[models.TaskType]models.TaskState
+	published float64
+}
+
+// newJobProgress starts tracking a job with its selected tasks pending and the others skipped
+func newJobProgress(job *models.Job) *jobProgress {
+	p := &jobProgress{url: job.URL, tasks: make(map[models.TaskType]models.TaskState)}
+	for _, taskType := range models.TaskTypes() {
+		status := models.TaskStatusPending
+		if !job.RunsTask(taskType) {
+			status = models.TaskStatusSkipped
+		}
+		p.tasks[taskType] = models.TaskState{Type: taskType, Status: status}
+	}
+	return p
+}
+
+// setStatus records a task status change.
+// It returns the new progress and whether it advanced since the last one returned.
+func (p *jobProgress) setStatus(taskType models.TaskType, status models.TaskStatus) (float64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state := p.tasks[taskType]
+	state.Type, state.Status = taskType, status
+	p.tasks[taskType] = state
+	return p.advance()
+}
+
+// setSubTasks records how many subtasks of a running task have finished, see setStatus for the result
+func (p *jobProgress) setSubTasks(taskType models.TaskType, completed, total int) (float64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state := p.tasks[taskType]
+	state.Completed, state.Total = completed, total
+	p.tasks[taskType] = state
+	return p.advance()
+}
+
+// advance computes the progress, reporting it as advanced only when it grew past the last one.
+// Callers hold the lock.
+func (p *jobProgress) advance() (float64, bool) {
+	states := make([]models.TaskState, 0, len(p.tasks))
+	for _, state := range p.tasks {
+		states = append(states, state)
+	}
+
+	progress := models.JobProgress(models.JobStatusRunning, states)
+	if progress <= p.published {
+		return p.published, false
+	}
+	p.published = progress
+	return progress, true
+}
+
+// trackProgress starts tracking the progress of the job, the returned function stops it
+func (s *Analyzer) trackProgress(job *models.Job) func() {
+	s.progress.Store(job.ID, newJobProgress(job))
+	return func() {
+		s.progress.Delete(job.ID)
+	}
+}
+
+// jobProgressFor returns the progress tracker of the job, nil when the job is not tracked
+func (s *Analyzer) jobProgressFor(jobID string) *jobProgress {
+	p, ok := s.progress.Load(jobID)
+	if !ok {
+		return nil
+	}
+	return p.(*jobProgress)
+}
+
+// reportTaskProgress records the task status in the job progress and publishes the progress when it advanced
+func (s *Analyzer) reportTaskProgress(ctx context.Context, jobID string, taskType models.TaskType, status models.TaskStatus) {
+	p := s.jobProgressFor(jobID)
+	if p == nil {
+		return
+	}
+	if progress, advanced := p.setStatus(taskType, status); advanced {
+		s.publishProgress(ctx, jobID, p.url, progress)
+	}
+}
+
+// publishProgress stores the progress on the job and publishes it with a running job update
+func (s *Analyzer) publishProgress(ctx context.Context, jobID, url string, progress float64) {
+	if err := s.jobRepo.UpdateJobProgress(ctx, jobID, progress); err != nil {
+		s.log.Error("Failed to update job progress",
+			slog.String("jobId", jobID),
+			slog.Any("error", err))
+	}
+
+	if err := s.publisher.PublishJobUpdate(ctx, messagebus.JobUpdateMessage{
+		Type:     messagebus.JobUpdateMessageType,
+		JobID:    jobID,
+		Status:   string(models.JobStatusRunning),
+		URL:      url,
+		Progress: &progress,
+	}); err != nil {
+		s.log.Error("Failed to publish job progress",
+			slog.String("jobId", jobID),
+			slog.Any("error", err))
+	}
+}
+
+// startJobProgressReporter periodically folds the finished link count into the job progress while links are verified,
+// publishing it when it advanced. The returned function stops the reporter.
+func (s *Analyzer) startJobProgressReporter(ctx context.Context, jobID string, total int, result *AnalysisResult) func() {
+	p := s.jobProgressFor(jobID)
+	if p == nil {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(s.progressInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if progress, advanced := p.setSubTasks(models.TaskTypeVerifyingLinks, finishedLinks(result), total); advanced {
+					s.publishProgress(ctx, jobID, p.url, progress)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// terminalProgress returns the progress carried by a job update with the status, nil while the job is not finished
+func terminalProgress(status models.JobStatus) *float64 {
+	progress, ok := models.TerminalProgress(status)
+	if !ok {
+		return nil
+	}
+	return &progress
+}
@@ -0,0 +1,124 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"shared/messagebus"
+	"shared/mocks"
+	"shared/models"
+	"sync"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestJobProgress_OnlyAdvances(t *testing.T) {
+	p := newJobProgress(&models.Job{ID: "job-1", Tasks: []models.TaskType{
+		models.TaskTypeExtracting,
+		models.TaskTypeVerifyingLinks,
+	}})
+
+	// Identifying and analyzing are skipped, so they count from the start
+	progress, advanced := p.setStatus(models.TaskTypeExtracting, models.TaskStatusRunning)
+	assert.True(t, advanced)
+	assert.Equal(t, 30.0, progress)
+
+	progress, advanced = p.setStatus(models.TaskTypeExtracting, models.TaskStatusCompleted)
+	assert.True(t, advanced)
+	assert.Equal(t, 40.0, progress)
+
+	progress, advanced = p.setSubTasks(models.TaskTypeVerifyingLinks, 1, 4)
+	assert.False(t, advanced, "subtasks count once the task is running")
+	assert.Equal(t, 40.0, progress)
+
+	p.setStatus(models.TaskTypeVerifyingLinks, models.TaskStatusRunning)
+	progress, advanced = p.setSubTasks(models.TaskTypeVerifyingLinks, 2, 4)
+	assert.True(t, advanced)
+	assert.Equal(t, 70.0, progress)
+
+	// A failing task never takes the progress back
+	progress, advanced = p.setStatus(models.TaskTypeVerifyingLinks, models.TaskStatusFailed)
+	assert.False(t, advanced)
+	assert.Equal(t, 70.0, progress)
+}
+
+func TestAnalyzer_PublishesJobProgress(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockJobRepo := mocks.NewMockJobRepositoryInterface(ctrl)
+	mockTaskRepo := mocks.NewMockTaskRepositoryInterface(ctrl)
+	mockMessageBus := mocks.NewMockMessageBusInterface(ctrl)
+
+	htmlContent := `<!DOCTYPE html><html><head><title>Progress</title></head><body><a href="/a">A</a><a href="/b">B</a></body></html>`
+
+	mockJobRepo.EXPECT().GetJob(gomock.Any(), gomock.Any()).Return(&models.Job{
+		ID:     "test-job-id",
+		URL:    "https://example.com/",
+		Status: models.JobStatusPending,
+	}, nil)
+	mockJobRepo.EXPECT().UpdateJobStatus(gomock.Any(), gomock.Any(), models.JobStatusRunning, gomock.Any()).Return(nil)
+	mockJobRepo.EXPECT().UpdateJob(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockTaskRepo.EXPECT().UpdateTaskStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockTaskRepo.EXPECT().AddSubTaskByKey(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockTaskRepo.EXPECT().UpdateSubTaskByKey(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockMessageBus.EXPECT().PublishTaskStatusUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockMessageBus.EXPECT().PublishSubTaskUpdate(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	var mu sync.Mutex
+	var stored []float64
+	mockJobRepo.EXPECT().UpdateJobProgress(gomock.Any(), "test-job-id", gomock.Any()).DoAndReturn(
+		func(ctx context.Context, jobID string, progress float64) error {
+			mu.Lock()
+			defer mu.Unlock()
+			stored = append(stored, progress)
+			return nil
+		}).AnyTimes()
+
+	var published []float64
+	var final messagebus.JobUpdateMessage
+	mockMessageBus.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, m messagebus.JobUpdateMessage) error {
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case m.Status == string(models.JobStatusRunning) && m.Progress != nil:
+				published = append(published, *m.Progress)
+			case m.Status == string(models.JobStatusCompleted):
+				final = m
+			}
+			return nil
+		}).AnyTimes()
+
+	analyzer := NewAnalyzer(
+		mockJobRepo,
+		mockTaskRepo,
+		mockMessageBus,
+		WithHTTPClient(&http.Client{Transport: &MockHTTPRoundTripper{statusCode: http.StatusOK, htmlContent: htmlContent}}),
+		WithLogger(slog.New(slog.DiscardHandler)),
+	)
+
+	msg, err := json.Marshal(messagebus.AnalyzeMessage{JobId: "test-job-id"})
+	assert.NoError(t, err, "Failed to marshal analyze message")
+
+	analyzer.ProcessAnalyzeMessage(context.Background(), &nats.Msg{
+		Data:    msg,
+		Subject: "url.analyze",
+	})
+
+	assert.NotEmpty(t, published)
+	assert.Equal(t, stored, published, "every published progress is stored on the job")
+	for i := 1; i < len(published); i++ {
+		assert.Greater(t, published[i], published[i-1], "progress must only advance")
+	}
+	assert.Equal(t, 100.0, published[len(published)-1], "all tasks completed")
+
+	if assert.NotNil(t, final.Progress) {
+		assert.Equal(t, 100.0, *final.Progress)
+	}
+	assert.Nil(t, analyzer.jobProgressFor("test-job-id"), "tracking stops with the analysis")
+}
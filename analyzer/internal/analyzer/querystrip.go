@@ -0,0 +1,80 @@
+package analyzer
+
+import (
+	"net/url"
+	"strings"
+)
+
+// strippedQueryParams returns the query parameters stripped from links, none unless configured
+func (s *Analyzer) strippedQueryParams() []string {
+	if s.cfg == nil {
+		return nil
+	}
+	return s.cfg.Analysis.StripQueryParams
+}
+
+// collapseLink returns the link counted and verified for the resolved URL of a link on the page, stripped of the
+// configured tracking parameters. It reports false when stripping changed the link into one already on the page,
+// in which case the link is counted as collapsed into the earlier one. Links the page repeats as written are kept.
+func (s *Analyzer) collapseLink(resolvedURL string, result *AnalysisResult) (string, bool) {
+	params := s.strippedQueryParams()
+	if len(params) == 0 {
+		return resolvedURL, true
+	}
+
+	link := stripQueryParams(resolvedURL, params)
+	if link != resolvedURL && result.seenLinks[link] {
+		result.collapsedLinks++
+		return "", false
+	}
+	if result.seenLinks == nil {
+		result.seenLinks = make(map[string]bool)
+	}
+	result.seenLinks[link] = true
+	result.originalLinks = append(result.originalLinks, resolvedURL)
+	return link, true
+}
+
+// stripQueryParams returns link without the query parameters named by params, leaving the others in their order.
+// Names match case-insensitively, and a param ending in * matches every name with that prefix.
+// The link is returned as is when it cannot be parsed or has none of the parameters.
+func stripQueryParams(link string, params []string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.RawQuery == "" {
+		return link
+	}
+
+	pairs := strings.Split(u.RawQuery, "&")
+	kept := pairs[:0:0]
+	for _, pair := range pairs {
+		name, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if !matchesQueryParam(name, params) {
+			kept = append(kept, pair)
+		}
+	}
+	if len(kept) == len(pairs) {
+		return link
+	}
+
+	u.RawQuery = strings.Join(kept, "&")
+	return u.String()
+}
+
+// matchesQueryParam reports whether the query parameter name is one of params
+func matchesQueryParam(name string, params []string) bool {
+	name = strings.ToLower(name)
+	for _, param := range params {
+		param = strings.ToLower(param)
+		if prefix, ok := strings.CutSuffix(param, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == param {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package analyzer
+
+import (
+	"analyzer/internal/config"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestStripQueryParams(t *testing.T) {
+	params := []string{"utm_*", "fbclid"}
+	testCases := []struct {
+		name     string
+		link     string
+		expected string
+	}{
+		{
+			name:     "NoQuery",
+			link:     "https://example.com/pricing",
+			expected: "https://example.com/pricing",
+		},
+		{
+			name:     "OnlyTrackingParams",
+			link:     "https://example.com/pricing?utm_source=news&utm_medium=email&fbclid=abc",
+			expected: "https://example.com/pricing",
+		},
+		{
+			name:     "KeepsOtherParamsInOrder",
+			link:     "https://example.com/search?q=go&utm_source=news&page=2",
+			expected: "https://example.com/search?q=go&page=2",
+		},
+		{
+			name:     "CaseInsensitive",
+			link:     "https://example.com/?UTM_Campaign=spring&FBCLID=abc",
+			expected: "https://example.com/",
+		},
+		{
+			name:     "EscapedName",
+			link:     "https://example.com/?utm%5Fsource=news&id=1",
+			expected: "https://example.com/?id=1",
+		},
+		{
+			name:     "PrefixOnlyWithStar",
+			link:     "https://example.com/?fbclid_extra=1&utm=2",
+			expected: "https://example.com/?fbclid_extra=1&utm=2",
+		},
+		{
+			name:     "KeepsFragment",
+			link:     "https://example.com/docs?utm_source=x#install",
+			expected: "https://example.com/docs#install",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, stripQueryParams(tc.link, params))
+		})
+	}
+}
+
+func TestAnalyzer_CollapseLinks(t *testing.T) {
+	const page = `<body>
+		<a href="/pricing?utm_source=news">Pricing</a>
+		<a href="/pricing?utm_source=ads&utm_medium=cpc">Pricing</a>
+		<a href="/pricing">Pricing</a>
+		<a href="https://partner.example.org/?fbclid=abc&ref=1">Partner</a>
+		<a href="/search?q=go">Search</a>
+		<a href="/search?q=go">Search again</a>
+	</body>`
+
+	testCases := []struct {
+		name              string
+		params            []string
+		expectedLinks     []string
+		expectedOriginals []string
+		expectedCollapsed int
+		expectedInternal  int
+	}{
+		{
+			name:   "Stripped",
+			params: []string{"utm_*", "fbclid"},
+			expectedLinks: []string{
+				"https://example.com/pricing",
+				"https://example.com/pricing",
+				"https://partner.example.org/?ref=1",
+				"https://example.com/search?q=go",
+				"https://example.com/search?q=go",
+			},
+			expectedOriginals: []string{
+				"https://example.com/pricing?utm_source=news",
+				"https://example.com/pricing",
+				"https://partner.example.org/?fbclid=abc&ref=1",
+				"https://example.com/search?q=go",
+				"https://example.com/search?q=go",
+			},
+			expectedCollapsed: 1,
+			expectedInternal:  4,
+		},
+		{
+			name: "Disabled",
+			expectedLinks: []string{
+				"https://example.com/pricing?utm_source=news",
+				"https://example.com/pricing?utm_source=ads&utm_medium=cpc",
+				"https://example.com/pricing",
+				"https://partner.example.org/?fbclid=abc&ref=1",
+				"https://example.com/search?q=go",
+				"https://example.com/search?q=go",
+			},
+			expectedInternal: 5,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.Load()
+			cfg.Analysis.StripQueryParams = tc.params
+			analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)), WithConfig(cfg))
+
+			doc, err := html.Parse(strings.NewReader(page))
+			require.NoError(t, err)
+
+			result := &AnalysisResult{baseURL: "https://example.com", headings: make(map[string]int)}
+			analyzer.traverseNode(doc, result)
+
+			built := result.snapshot()
+			assert.Equal(t, tc.expectedLinks, built.Links)
+			assert.Equal(t, tc.expectedOriginals, built.OriginalLinks)
+			assert.Equal(t, tc.expectedCollapsed, built.CollapsedLinks)
+			assert.Equal(t, tc.expectedInternal, built.InternalLinkCount)
+			assert.Equal(t, 1, built.ExternalLinkCount)
+		})
+	}
+}
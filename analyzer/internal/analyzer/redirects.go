@@ -0,0 +1,196 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"shared/models"
+	"slices"
+	"strings"
+)
+
+// defaultMaxRedirects is the number of redirects followed when no limit is configured, the same as net/http's
+const defaultMaxRedirects = 10
+
+var (
+	// errTooManyRedirects is returned for a request redirected more often than its limit allows
+	errTooManyRedirects = errors.New("too many redirects")
+	// errRedirectLoop is returned for a request redirected back to a URL it already visited
+	errRedirectLoop = errors.New("redirect loop")
+)
+
+// redirectError is returned by CheckRedirect, carrying the URLs visited up to the refused redirect
+type redirectError struct {
+	err   error
+	chain []string
+}
+
+func (e *redirectError) Error() string {
+	return fmt.Sprintf("%s: %s", e.err, joinRedirectChain(e.chain))
+}
+
+func (e *redirectError) Unwrap() error {
+	return e.err
+}
+
+// redirectLimitKey is the context key of a request's redirect limit
+type redirectLimitKey struct{}
+
+// withRedirectLimit sets the number of redirects CheckRedirect follows for requests made with ctx,
+// the default applies when it is not positive
+func withRedirectLimit(ctx context.Context, limit int) context.Context {
+	return context.WithValue(ctx, redirectLimitKey{}, limit)
+}
+
+// CheckRedirect is the redirect policy of the analyzer's HTTP client. It follows redirects up to the limit
+// set on the request's context, defaulting to 10, and refuses redirects back to a URL already visited.
+func CheckRedirect(req *http.Request, via []*http.Request) error {
+	limit, ok := req.Context().Value(redirectLimitKey{}).(int)
+	if !ok || limit <= 0 {
+		limit = defaultMaxRedirects
+	}
+
+	chain := make([]string, 0, len(via)+1)
+	for _, r := range via {
+		chain = append(chain, r.URL.String())
+	}
+
+	next := req.URL.String()
+	if slices.Contains(chain, next) {
+		return &redirectError{err: errRedirectLoop, chain: append(chain, next)}
+	}
+	if len(via) > limit {
+		return &redirectError{err: errTooManyRedirects, chain: append(chain, next)}
+	}
+	return nil
+}
+
+// redirectChain returns the URLs a response was redirected through, from the requested URL to the final one.
+// It returns nil when the response was not redirected.
+func redirectChain(resp *http.Response) []string {
+	if resp == nil || resp.Request == nil || resp.Request.Response == nil {
+		return nil
+	}
+
+	var chain []string
+	for req := resp.Request; req != nil; {
+		chain = append(chain, req.URL.String())
+		if req.Response == nil {
+			break
+		}
+		req = req.Response.Request
+	}
+	slices.Reverse(chain)
+	return chain
+}
+
+// redirectTarget returns the URL a redirected response ended up at, empty when it was not redirected
+func redirectTarget(resp *http.Response) string {
+	if chain := redirectChain(resp); chain != nil {
+		return chain[len(chain)-1]
+	}
+	return ""
+}
+
+// formatRedirectChain describes a redirect chain as "redirected 2 times: A → B → C"
+func formatRedirectChain(chain []string) string {
+	redirects := len(chain) - 1
+	times := "times"
+	if redirects == 1 {
+		times = "time"
+	}
+	return fmt.Sprintf("redirected %d %s: %s", redirects, times, joinRedirectChain(chain))
+}
+
+// joinRedirectChain joins the URLs of a redirect chain with arrows
+func joinRedirectChain(chain []string) string {
+	return strings.Join(chain, " → ")
+}
+
+// describeRedirectError describes a refused redirect for a subtask, reporting false for other errors
+func describeRedirectError(err error) (string, bool) {
+	var redirectErr *redirectError
+	if !errors.As(err, &redirectErr) {
+		return "", false
+	}
+	if errors.Is(redirectErr, errRedirectLoop) {
+		return "Redirect loop: " + joinRedirectChain(redirectErr.chain), true
+	}
+	return fmt.Sprintf("Too many redirects, stopped after %d: %s", len(redirectErr.chain)-2, joinRedirectChain(redirectErr.chain)), true
+}
+
+// appendRedirects adds the redirect chain of a response to its description, when it was redirected
+func appendRedirects(desc string, resp *http.Response) string {
+	if chain := redirectChain(resp); chain != nil {
+		return desc + ", " + formatRedirectChain(chain)
+	}
+	return desc
+}
+
+// redundantRedirects pairs the page's links to its own site that differ only by a trailing slash or a www prefix,
+// where following one redirected to the other. targets maps each redirected link to the URL it ended up at.
+func redundantRedirects(baseURL string, links []string, targets map[string]string) []models.LinkRedirect {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	linked := make(map[string]bool, len(links))
+	for _, link := range links {
+		linked[link] = true
+	}
+
+	var redirects []models.LinkRedirect
+	seen := make(map[string]bool)
+	for _, from := range links {
+		to, ok := targets[from]
+		if !ok || !linked[to] || seen[from] {
+			continue
+		}
+		if !sameSite(from, baseURL) || !isURLVariant(from, to) {
+			continue
+		}
+		seen[from] = true
+		redirects = append(redirects, models.LinkRedirect{From: from, To: to})
+	}
+	return redirects
+}
+
+// isURLVariant reports whether two different URLs only differ by a trailing slash on the path or a www prefix on the host
+func isURLVariant(a, b string) bool {
+	if a == b {
+		return false
+	}
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Scheme == ub.Scheme &&
+		siteHost(ua) == siteHost(ub) &&
+		strings.TrimSuffix(ua.EscapedPath(), "/") == strings.TrimSuffix(ub.EscapedPath(), "/") &&
+		ua.RawQuery == ub.RawQuery &&
+		ua.Fragment == ub.Fragment
+}
+
+// sameSite reports whether a link is on the same host as the page, with or without a www prefix
+func sameSite(link, baseURL string) bool {
+	ul, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(baseURL)
+	if err != nil {
+		return false
+	}
+	return siteHost(ul) == siteHost(ub)
+}
+
+// siteHost returns the host and port of a URL without its www prefix
+func siteHost(u *url.URL) string {
+	return strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+}
@@ -0,0 +1,317 @@
+package analyzer
+
+import (
+	"analyzer/internal/config"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"shared/messagebus"
+	"shared/models"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// redirectRoundTripper redirects the paths it maps to their target and serves every other path
+type redirectRoundTripper struct {
+	redirects map[string]string
+	calls     int
+}
+
+func (r *redirectRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.calls++
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader("<html><body>ok</body></html>")),
+		Request:    req,
+	}
+	if target, ok := r.redirects[req.URL.Path]; ok {
+		resp.StatusCode = http.StatusFound
+		resp.Header.Set("Location", target)
+	}
+	return resp, nil
+}
+
+// redirectingAnalyzer returns an analyzer whose client follows redirects with CheckRedirect
+func redirectingAnalyzer(transport http.RoundTripper, cfg *config.Config) *Analyzer {
+	return NewAnalyzer(nil, nil, nil,
+		WithHTTPClient(&http.Client{Transport: transport, CheckRedirect: CheckRedirect}),
+		WithLogger(slog.New(slog.DiscardHandler)),
+		WithConfig(cfg),
+	)
+}
+
+func TestAnalyzer_FetchContentRedirects(t *testing.T) {
+	testCases := []struct {
+		name          string
+		redirects     map[string]string
+		maxRedirects  int
+		expectedChain []string
+		expectedError error
+		expectedCalls int
+	}{
+		{
+			name:          "NotRedirected",
+			expectedCalls: 1,
+		},
+		{
+			name:          "Chain",
+			redirects:     map[string]string{"/a": "/b", "/b": "https://www.example.com/c"},
+			expectedChain: []string{"https://example.com/a", "https://example.com/b", "https://www.example.com/c"},
+			expectedCalls: 3,
+		},
+		{
+			name:          "UpToLimit",
+			redirects:     map[string]string{"/a": "/b", "/b": "/c"},
+			maxRedirects:  2,
+			expectedChain: []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"},
+			expectedCalls: 3,
+		},
+		{
+			// Refused redirects are not retried, they would be refused again
+			name:          "TooMany",
+			redirects:     map[string]string{"/a": "/b", "/b": "/c", "/c": "/d"},
+			maxRedirects:  2,
+			expectedError: errTooManyRedirects,
+			expectedCalls: 3,
+		},
+		{
+			name:          "Loop",
+			redirects:     map[string]string{"/a": "/b", "/b": "/a"},
+			expectedError: errRedirectLoop,
+			expectedCalls: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			transport := &redirectRoundTripper{redirects: tc.redirects}
+			s := redirectingAnalyzer(transport, &config.Config{
+				Fetch: config.FetchConfig{MaxRetries: 2, MaxRedirects: tc.maxRedirects},
+			})
+
+			page, err := s.fetchContent(context.Background(), "https://example.com/a")
+			assert.Equal(t, tc.expectedCalls, transport.calls)
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			var result models.AnalyzeResult
+			s.applyPageDetails(&result, page)
+			assert.Equal(t, tc.expectedChain, result.RedirectChain)
+		})
+	}
+}
+
+func TestAnalyzer_VerifyLink_Redirects(t *testing.T) {
+	testCases := []struct {
+		name           string
+		redirects      map[string]string
+		maxRedirects   int
+		expectedStatus models.TaskStatus
+		expectedDesc   string
+		expectedTarget string
+	}{
+		{
+			name:           "NotRedirected",
+			expectedStatus: models.TaskStatusCompleted,
+			expectedDesc:   "HTTP 200: OK",
+		},
+		{
+			name:           "Once",
+			redirects:      map[string]string{"/a": "/b"},
+			expectedStatus: models.TaskStatusCompleted,
+			expectedDesc:   "HTTP 200: OK, redirected 1 time: https://example.com/a → https://example.com/b",
+			expectedTarget: "https://example.com/b",
+		},
+		{
+			name:           "Chain",
+			redirects:      map[string]string{"/a": "/b", "/b": "/c", "/c": "/d"},
+			expectedStatus: models.TaskStatusCompleted,
+			expectedDesc: "HTTP 200: OK, redirected 3 times: " +
+				"https://example.com/a → https://example.com/b → https://example.com/c → https://example.com/d",
+			expectedTarget: "https://example.com/d",
+		},
+		{
+			name:           "TooMany",
+			redirects:      map[string]string{"/a": "/b", "/b": "/c"},
+			maxRedirects:   1,
+			expectedStatus: models.TaskStatusFailed,
+			expectedDesc:   "Too many redirects, stopped after 1: https://example.com/a → https://example.com/b → https://example.com/c",
+		},
+		{
+			name:           "Loop",
+			redirects:      map[string]string{"/a": "/b", "/b": "/c", "/c": "/b"},
+			expectedStatus: models.TaskStatusFailed,
+			expectedDesc:   "Redirect loop: https://example.com/a → https://example.com/b → https://example.com/c → https://example.com/b",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.Load()
+			cfg.Analysis.VerifyMaxRedirects = tc.maxRedirects
+			s := redirectingAnalyzer(&redirectRoundTripper{redirects: tc.redirects}, cfg)
+
+			check := s.verifyLink(context.Background(), "https://example.com/a", models.HeaderProfileDefault)
+
+			assert.Equal(t, tc.expectedStatus, check.status)
+			assert.Equal(t, tc.expectedDesc, check.description)
+			assert.Equal(t, tc.expectedTarget, check.redirectedTo)
+		})
+	}
+}
+
+func TestCheckRedirect_DefaultLimit(t *testing.T) {
+	via := make([]*http.Request, 0, defaultMaxRedirects+1)
+	for i := range defaultMaxRedirects + 1 {
+		req, err := http.NewRequest(http.MethodGet, "https://example.com/"+strings.Repeat("a", i+1), nil)
+		require.NoError(t, err)
+		via = append(via, req)
+	}
+	next, err := http.NewRequest(http.MethodGet, "https://example.com/next", nil)
+	require.NoError(t, err)
+
+	assert.NoError(t, CheckRedirect(next, via[:defaultMaxRedirects]))
+	assert.ErrorIs(t, CheckRedirect(next, via), errTooManyRedirects)
+}
+
+func TestRedundantRedirects(t *testing.T) {
+	testCases := []struct {
+		name     string
+		links    []string
+		targets  map[string]string
+		expected []models.LinkRedirect
+	}{
+		{
+			name:  "NotRedirected",
+			links: []string{"https://example.com/about", "https://example.com/about/"},
+		},
+		{
+			name:     "TrailingSlashAdded",
+			links:    []string{"https://example.com/about", "https://example.com/about/"},
+			targets:  map[string]string{"https://example.com/about": "https://example.com/about/"},
+			expected: []models.LinkRedirect{{From: "https://example.com/about", To: "https://example.com/about/"}},
+		},
+		{
+			name:     "TrailingSlashRemoved",
+			links:    []string{"https://example.com/blog/", "https://example.com/blog"},
+			targets:  map[string]string{"https://example.com/blog/": "https://example.com/blog"},
+			expected: []models.LinkRedirect{{From: "https://example.com/blog/", To: "https://example.com/blog"}},
+		},
+		{
+			name:     "WWWToApex",
+			links:    []string{"https://www.example.com/pricing", "https://example.com/pricing"},
+			targets:  map[string]string{"https://www.example.com/pricing": "https://example.com/pricing"},
+			expected: []models.LinkRedirect{{From: "https://www.example.com/pricing", To: "https://example.com/pricing"}},
+		},
+		{
+			name:     "WWWAndTrailingSlash",
+			links:    []string{"https://example.com/team", "https://www.example.com/team/"},
+			targets:  map[string]string{"https://example.com/team": "https://www.example.com/team/"},
+			expected: []models.LinkRedirect{{From: "https://example.com/team", To: "https://www.example.com/team/"}},
+		},
+		{
+			// A redirect to a variant the page never links to costs a hop, but there is no pair to report
+			name:    "TargetNotLinked",
+			links:   []string{"https://example.com/contact"},
+			targets: map[string]string{"https://example.com/contact": "https://example.com/contact/"},
+		},
+		{
+			name:    "DifferentPath",
+			links:   []string{"https://example.com/docs", "https://example.com/documentation"},
+			targets: map[string]string{"https://example.com/docs": "https://example.com/documentation"},
+		},
+		{
+			name:    "DifferentQuery",
+			links:   []string{"https://example.com/search?q=a", "https://example.com/search/?q=b"},
+			targets: map[string]string{"https://example.com/search?q=a": "https://example.com/search/?q=b"},
+		},
+		{
+			name:    "SchemeUpgrade",
+			links:   []string{"http://example.com/about", "https://example.com/about/"},
+			targets: map[string]string{"http://example.com/about": "https://example.com/about/"},
+		},
+		{
+			name:    "OtherSite",
+			links:   []string{"https://partner.example.org/news", "https://partner.example.org/news/"},
+			targets: map[string]string{"https://partner.example.org/news": "https://partner.example.org/news/"},
+		},
+		{
+			name:     "LinkedTwice",
+			links:    []string{"https://example.com/about", "https://example.com/about/", "https://example.com/about"},
+			targets:  map[string]string{"https://example.com/about": "https://example.com/about/"},
+			expected: []models.LinkRedirect{{From: "https://example.com/about", To: "https://example.com/about/"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, redundantRedirects("https://example.com", tc.links, tc.targets))
+		})
+	}
+}
+
+// variantRoundTripper serves page at the site root and redirects the URLs it maps to their target
+type variantRoundTripper struct {
+	page      string
+	redirects map[string]string
+}
+
+func (v *variantRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/html"}},
+		Body:       io.NopCloser(strings.NewReader("<html><body>ok</body></html>")),
+		Request:    req,
+	}
+	if target, ok := v.redirects[req.URL.String()]; ok {
+		resp.StatusCode = http.StatusMovedPermanently
+		resp.Header.Set("Location", target)
+	} else if req.URL.Path == "" || req.URL.Path == "/" {
+		resp.Body = io.NopCloser(strings.NewReader(v.page))
+	}
+	return resp, nil
+}
+
+func TestAnalyzer_RedundantRedirects_Fixture(t *testing.T) {
+	page, err := os.ReadFile("testdata/redirect_variants.html")
+	require.NoError(t, err)
+
+	analyzer, capturedResult, ctrl, _ := setupMockAnalyzer(t, string(page), "https://example.com")
+	defer ctrl.Finish()
+	WithHTTPClient(&http.Client{
+		Transport: &variantRoundTripper{page: string(page), redirects: map[string]string{
+			"https://example.com/about":       "https://example.com/about/",
+			"https://example.com/blog/":       "https://example.com/blog",
+			"https://www.example.com/pricing": "https://example.com/pricing",
+			"https://example.com/contact":     "https://example.com/contact/",
+			"https://example.com/docs":        "https://example.com/documentation",
+		}},
+		CheckRedirect: CheckRedirect,
+	})(analyzer)
+
+	msg, err := json.Marshal(messagebus.AnalyzeMessage{JobId: "test-job-id"})
+	require.NoError(t, err)
+	analyzer.ProcessAnalyzeMessage(context.Background(), &nats.Msg{Data: msg, Subject: "url.analyze"})
+
+	require.NotNil(t, *capturedResult)
+	result := *capturedResult
+	assert.Equal(t, []models.LinkRedirect{
+		{From: "https://example.com/about", To: "https://example.com/about/"},
+		{From: "https://example.com/blog/", To: "https://example.com/blog"},
+		{From: "https://www.example.com/pricing", To: "https://example.com/pricing"},
+	}, result.RedundantRedirectLinks)
+	assert.Equal(t, 3, result.RedundantRedirectCount)
+	assert.Equal(t, 10, result.AccessibleLinks)
+}
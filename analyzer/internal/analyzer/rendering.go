@@ -0,0 +1,132 @@
+package analyzer
+
+import (
+	"shared/models"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// csrMaxTextRatio is the share of visible text in the page's markup below which it reads as an empty shell
+	csrMaxTextRatio = 0.02
+
+	// csrMaxContentElements is the most links and headings, together, a client-side rendered shell is expected to have
+	csrMaxContentElements = 2
+
+	// csrMinInlineScriptBytes is the size of inline script from which a page is treated as carrying a bundle,
+	// without it a page needs at least one external script
+	csrMinInlineScriptBytes = 10 * 1024
+)
+
+// mountPointIDs are the ids frameworks conventionally render the application into
+var mountPointIDs = map[string]bool{
+	"root":      true, // Create React App, Vite
+	"app":       true, // Vue CLI
+	"__next":    true, // Next.js
+	"__nuxt":    true, // Nuxt
+	"svelte":    true, // SvelteKit
+	"___gatsby": true, // Gatsby
+}
+
+// mountPointElements are elements frameworks render the application into
+var mountPointElements = map[string]bool{
+	"app-root": true, // Angular CLI
+}
+
+// clientSideRenderingWarning explains the flag to readers of the result
+const clientSideRenderingWarning = "The page appears to be rendered by JavaScript in the browser. " +
+	"Its served HTML is mostly empty, so headings, links and forms may be missing from this result."
+
+// renderingSignals gathers what the traversal saw that hints at client-side rendering
+type renderingSignals struct {
+	textBytes         int
+	inlineScriptBytes int
+	externalScripts   int
+	// frameworkMarker is set by an empty framework mount point or an AngularJS ng-app attribute
+	frameworkMarker bool
+}
+
+// recordText adds the visible text of a text node, ignoring text inside elements that are not rendered
+func (r *renderingSignals) recordText(n *html.Node) {
+	if n.Parent != nil && n.Parent.Type == html.ElementNode {
+		switch n.Parent.Data {
+		case "script", "style", "noscript", "template":
+			return
+		}
+	}
+	r.textBytes += len(strings.TrimSpace(n.Data))
+}
+
+// recordScript counts an external script or the size of an inline one
+func (r *renderingSignals) recordScript(n *html.Node, src string) {
+	if src != "" {
+		r.externalScripts++
+		return
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.TextNode {
+			r.inlineScriptBytes += len(child.Data)
+		}
+	}
+}
+
+// recordMountPoint notes a framework mount point that was served without any content.
+// AngularJS compiles the templates in place, so its ng-app marks the page whatever it holds.
+func (r *renderingSignals) recordMountPoint(n *html.Node) {
+	for _, attr := range n.Attr {
+		switch {
+		case attr.Key == "ng-app" || attr.Key == "data-ng-app":
+			r.frameworkMarker = true
+		case attr.Key == "id" && mountPointIDs[attr.Val] && !hasContent(n):
+			r.frameworkMarker = true
+		}
+	}
+	if mountPointElements[n.Data] && !hasContent(n) {
+		r.frameworkMarker = true
+	}
+}
+
+// hasContent reports whether an element has child elements or non-blank text
+func hasContent(n *html.Node) bool {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case html.ElementNode:
+			return true
+		case html.TextNode:
+			if strings.TrimSpace(child.Data) != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// detectClientSideRendering flags pages that look like a shell for JavaScript to render into.
+// A page must carry scripts and have almost no links or headings, and either its markup must be nearly
+// all non-text or it must show a framework marker. Server-rendered framework pages keep their
+// content in the served HTML, so they are not flagged.
+func (s *Analyzer) detectClientSideRendering(result *AnalysisResult) {
+	signals := result.rendering
+
+	scripted := signals.externalScripts > 0 || signals.inlineScriptBytes >= csrMinInlineScriptBytes
+	sparse := len(result.links)+headingCount(result.headings) <= csrMaxContentElements
+	lowText := result.pageBytes > 0 && float64(signals.textBytes)/float64(result.pageBytes) < csrMaxTextRatio
+
+	if scripted && sparse && (lowText || signals.frameworkMarker) {
+		result.clientSideRendered = true
+		result.warnings = append(result.warnings, models.Warning{
+			Code:    models.WarningClientSideRendered,
+			Message: clientSideRenderingWarning,
+		})
+	}
+}
+
+// headingCount sums the headings of every level
+func headingCount(headings map[string]int) int {
+	total := 0
+	for _, count := range headings {
+		total += count
+	}
+	return total
+}
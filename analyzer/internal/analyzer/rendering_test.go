@@ -0,0 +1,107 @@
+package analyzer
+
+import (
+	"log/slog"
+	"shared/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+const createReactAppShell = `<!doctype html><html lang="en"><head><meta charset="utf-8"/>
+<link rel="icon" href="/favicon.ico"/><meta name="viewport" content="width=device-width,initial-scale=1"/>
+<meta name="theme-color" content="#000000"/><meta name="description" content="Web site created using create-react-app"/>
+<link rel="apple-touch-icon" href="/logo192.png"/><link rel="manifest" href="/manifest.json"/>
+<title>React App</title><script defer="defer" src="/static/js/main.4f2a9c1b.js"></script>
+<link href="/static/css/main.073c9b0a.css" rel="stylesheet"></head>
+<body><noscript>You need to enable JavaScript to run this app.</noscript><div id="root"></div></body></html>`
+
+const angularShell = `<!doctype html><html lang="en"><head><meta charset="utf-8"><title>Shop</title>
+<base href="/"><link rel="stylesheet" href="styles.ef46db3751d8e999.css"></head>
+<body><app-root></app-root>
+<script src="runtime.1a2b3c.js" type="module"></script><script src="main.4d5e6f.js" type="module"></script></body></html>`
+
+// nextSSRPage is server-rendered, its large hydration payload leaves little text in the markup
+var nextSSRPage = `<!DOCTYPE html><html><head><title>Blog</title><script src="/_next/static/chunks/main.js" defer></script></head>
+<body><div id="__next"><header><nav><a href="/">Home</a><a href="/posts">Posts</a><a href="/about">About</a></nav></header>
+<main><h1>Latest posts</h1><article><h2>Shipping faster</h2><p>How we cut our deploy times in half by caching build output.</p></article></main></div>
+<script id="__NEXT_DATA__" type="application/json">{"props":{"pageProps":{"posts":[` +
+	strings.Repeat(`{"title":"Shipping faster","body":"How we cut our deploy times in half."},`, 200) +
+	`{}]}}}</script></body></html>`
+
+const staticPage = `<!DOCTYPE html><html><head><title>Bakery</title></head><body>
+<h1>Our bakery</h1><p>Fresh bread every morning, baked in a wood-fired oven since 1952.</p>
+<p>Visit us at the market square, open from six in the morning until the bread runs out.</p>
+<a href="/menu">Menu</a></body></html>`
+
+func TestAnalyzer_DetectClientSideRendering(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		expected bool
+	}{
+		{name: "CreateReactAppShell", content: createReactAppShell, expected: true},
+		{name: "AngularShell", content: angularShell, expected: true},
+		{
+			name: "InlineBundle",
+			content: `<html><head><title>App</title></head><body><div id="app"></div><script>` +
+				strings.Repeat("var a=1;", csrMinInlineScriptBytes/8+1) + `</script></body></html>`,
+			expected: true,
+		},
+		{
+			name:     "AngularJSTemplate",
+			content:  `<html ng-app="shop"><head><script src="angular.min.js"></script></head><body><p>{{ greeting }}</p></body></html>`,
+			expected: true,
+		},
+		{name: "NextSSRPage", content: nextSSRPage},
+		{name: "StaticPage", content: staticPage},
+		// An empty page without scripts is simply empty
+		{name: "EmptyMountPointWithoutScripts", content: `<html><body><div id="root"></div></body></html>`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+			doc, err := html.Parse(strings.NewReader(tc.content))
+			require.NoError(t, err)
+
+			result := &AnalysisResult{
+				headings:           make(map[string]int),
+				links:              []string{},
+				baseURL:            "https://example.com",
+				linkDepthHistogram: make(map[string]int),
+				pageBytes:          len(tc.content),
+			}
+			s.traverseNode(doc, result)
+			s.detectClientSideRendering(result)
+
+			built := result.snapshot()
+			assert.Equal(t, tc.expected, built.LikelyClientSideRendered)
+			if tc.expected {
+				require.Len(t, built.Warnings, 1)
+				assert.Equal(t, models.WarningClientSideRendered, built.Warnings[0].Code)
+			} else {
+				assert.Empty(t, built.Warnings)
+			}
+		})
+	}
+}
+
+func TestRenderingSignals_IgnoresHiddenText(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<html><head><style>body{color:red}</style></head>
+<body><noscript>Enable JavaScript</noscript><script>render()</script><p> Hello </p></body></html>`))
+	require.NoError(t, err)
+
+	var signals renderingSignals
+	walkNodes(doc, func(n *html.Node) bool {
+		if n.Type == html.TextNode {
+			signals.recordText(n)
+		}
+		return true
+	})
+
+	assert.Equal(t, len("Hello"), signals.textBytes)
+}
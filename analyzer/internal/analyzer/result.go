@@ -0,0 +1,87 @@
+package analyzer
+
+import (
+	"maps"
+	"sync/atomic"
+)
+
+// resultCount names one of the counts of an AnalysisResult
+type resultCount int
+
+const (
+	countInternalLinks resultCount = iota
+	countExternalLinks
+	countAccessibleLinks
+	countInaccessibleLinks
+	countExcludedLinks
+	countOutOfScopeLinks
+	// countUnverifiedLinks and countUnverifiedImages count the ones left unverified because the analysis ended first
+	countUnverifiedLinks
+	countAccessibleImages
+	countInaccessibleImages
+	countUnverifiedImages
+	numResultCounts
+)
+
+// resultCounts are the counts of an AnalysisResult, safe to update and read from any goroutine
+type resultCounts struct {
+	values [numResultCounts]atomic.Int32
+}
+
+// inc adds one to a count
+func (c *resultCounts) inc(count resultCount) {
+	c.values[count].Add(1)
+}
+
+// add adds n to a count
+func (c *resultCounts) add(count resultCount, n int) {
+	c.values[count].Add(int32(n))
+}
+
+// get returns the current value of a count
+func (c *resultCounts) get(count resultCount) int {
+	return int(c.values[count].Load())
+}
+
+// sum returns the total of the current values of the given counts
+func (c *resultCounts) sum(counts ...resultCount) int {
+	total := 0
+	for _, count := range counts {
+		total += c.get(count)
+	}
+	return total
+}
+
+// recordRedirect records the URL a verified link ended up at after being redirected
+func (r *AnalysisResult) recordRedirect(link, target string) {
+	r.verifiedMu.Lock()
+	defer r.verifiedMu.Unlock()
+	if r.redirectTargets == nil {
+		r.redirectTargets = make(map[string]string)
+	}
+	r.redirectTargets[link] = target
+}
+
+// redirects returns a copy of the redirects recorded so far
+func (r *AnalysisResult) redirects() map[string]string {
+	r.verifiedMu.Lock()
+	defer r.verifiedMu.Unlock()
+	return maps.Clone(r.redirectTargets)
+}
+
+// countAsset counts an accessible link to a downloadable asset of the media type
+func (r *AnalysisResult) countAsset(contentType string) {
+	r.verifiedMu.Lock()
+	defer r.verifiedMu.Unlock()
+	if r.assetLinks == nil {
+		r.assetLinks = make(map[string]int)
+	}
+	r.assetLinks[contentType]++
+}
+
+// assetCounts returns a copy of the asset counts recorded so far, nil when no asset was found
+func (r *AnalysisResult) assetCounts() map[string]int {
+	r.verifiedMu.Lock()
+	defer r.verifiedMu.Unlock()
+	return maps.Clone(r.assetLinks)
+}
@@ -0,0 +1,90 @@
+package analyzer
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAnalysisResult_SnapshotWhileVerifying snapshots the result over and over while workers record verifications,
+// as progress publishing and partial persistence do. Run under -race it catches any field reached without its guard.
+func TestAnalysisResult_SnapshotWhileVerifying(t *testing.T) {
+	const workers, perWorker = 8, 200
+
+	result := &AnalysisResult{
+		baseURL:  "https://example.com",
+		headings: map[string]int{"h1": 1},
+		links:    []string{"https://example.com/a", "https://other.com/b"},
+	}
+	result.counts.inc(countInternalLinks)
+	result.counts.inc(countExternalLinks)
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWorker {
+				switch i % 4 {
+				case 0:
+					result.counts.inc(countAccessibleLinks)
+					result.recordRedirect("https://example.com/"+strconv.Itoa(w)+"-"+strconv.Itoa(i), "https://example.com/moved")
+				case 1:
+					result.counts.inc(countInaccessibleLinks)
+				case 2:
+					result.counts.inc(countAccessibleLinks)
+					result.countAsset("application/pdf")
+				case 3:
+					result.counts.inc(countUnverifiedImages)
+				}
+				runtime.Gosched()
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	previous := 0
+	for snapshotting := true; snapshotting; {
+		select {
+		case <-done:
+			snapshotting = false
+		default:
+		}
+
+		snapshot := result.snapshot()
+		finished := finishedLinks(result)
+		assert.GreaterOrEqual(t, finished, previous, "finished links never go back")
+		previous = finished
+		assert.Equal(t, 1, snapshot.InternalLinkCount)
+		assert.LessOrEqual(t, snapshot.AssetLinks["application/pdf"], workers*perWorker/4)
+		assert.LessOrEqual(t, len(result.redirects()), workers*perWorker/4)
+	}
+
+	snapshot := result.snapshot()
+	assert.Equal(t, workers*perWorker/2, snapshot.AccessibleLinks)
+	assert.Equal(t, workers*perWorker/4, snapshot.InaccessibleLinks)
+	assert.Equal(t, workers*perWorker/4, snapshot.UnverifiedImages)
+	assert.Equal(t, map[string]int{"application/pdf": workers * perWorker / 4}, snapshot.AssetLinks)
+	assert.Len(t, result.redirects(), workers*perWorker/4)
+	assert.Equal(t, workers*perWorker, finishedLinks(result))
+}
+
+func TestAnalysisResult_SnapshotCopiesVerificationFindings(t *testing.T) {
+	result := &AnalysisResult{}
+	assert.Nil(t, result.snapshot().AssetLinks, "no asset, no asset counts")
+
+	result.countAsset("application/pdf")
+	snapshot := result.snapshot()
+	result.countAsset("application/pdf")
+
+	assert.Equal(t, 1, snapshot.AssetLinks["application/pdf"], "a snapshot is not changed by later verifications")
+	assert.Equal(t, 2, result.snapshot().AssetLinks["application/pdf"])
+}
@@ -0,0 +1,48 @@
+package analyzer
+
+import (
+	"net/http"
+	"shared/models"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// robotsDirectives reports whether a robots directive list, as in <meta name="robots"> or X-Robots-Tag,
+// holds noindex or nofollow. Directives are comma-separated and case-insensitive, and none stands for both.
+// A directive scoped to a crawler, as in "googlebot: noindex", counts as well.
+func robotsDirectives(value string) (noIndex, noFollow bool) {
+	for _, directive := range strings.Split(value, ",") {
+		if _, scoped, ok := strings.Cut(directive, ":"); ok {
+			directive = scoped
+		}
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "noindex":
+			noIndex = true
+		case "nofollow":
+			noFollow = true
+		case "none":
+			noIndex, noFollow = true, true
+		}
+	}
+	return noIndex, noFollow
+}
+
+// extractRobots records the directives of a <meta name="robots">, several of them add up
+func (s *Analyzer) extractRobots(n *html.Node, result *AnalysisResult) {
+	if !strings.EqualFold(strings.TrimSpace(s.getElementAttribute(n, "name")), "robots") {
+		return
+	}
+	noIndex, noFollow := robotsDirectives(s.getElementAttribute(n, "content"))
+	result.noIndex = result.noIndex || noIndex
+	result.noFollow = result.noFollow || noFollow
+}
+
+// checkRobotsHeader adds the directives of the page's X-Robots-Tag headers to those of its markup
+func checkRobotsHeader(result *models.AnalyzeResult, header http.Header) {
+	for _, value := range header.Values("X-Robots-Tag") {
+		noIndex, noFollow := robotsDirectives(value)
+		result.NoIndex = result.NoIndex || noIndex
+		result.NoFollow = result.NoFollow || noFollow
+	}
+}
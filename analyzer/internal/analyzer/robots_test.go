@@ -0,0 +1,93 @@
+package analyzer
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestRobotsDirectives(t *testing.T) {
+	testCases := []struct {
+		value            string
+		expectedNoIndex  bool
+		expectedNoFollow bool
+	}{
+		{value: "noindex", expectedNoIndex: true},
+		{value: "NoIndex, NOFOLLOW", expectedNoIndex: true, expectedNoFollow: true},
+		{value: " index ,nofollow ", expectedNoFollow: true},
+		{value: "none", expectedNoIndex: true, expectedNoFollow: true},
+		{value: "googlebot: noindex", expectedNoIndex: true},
+		{value: "index, follow, max-snippet:20"},
+		{value: "unavailable_after: 25 Jun 2030 15:00:00 PST"},
+		{value: "noindexing, follow-up"},
+		{value: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			noIndex, noFollow := robotsDirectives(tc.value)
+			assert.Equal(t, tc.expectedNoIndex, noIndex)
+			assert.Equal(t, tc.expectedNoFollow, noFollow)
+		})
+	}
+}
+
+func TestAnalyzer_Robots(t *testing.T) {
+	testCases := []struct {
+		name             string
+		head             string
+		header           http.Header
+		expectedNoIndex  bool
+		expectedNoFollow bool
+	}{
+		{
+			name:            "Meta",
+			head:            `<meta name="Robots" content="noindex">`,
+			expectedNoIndex: true,
+		},
+		{
+			name:             "MetaTagsAddUp",
+			head:             `<meta name="robots" content="noindex"><meta name="robots" content="follow, nofollow">`,
+			expectedNoIndex:  true,
+			expectedNoFollow: true,
+		},
+		{
+			name: "OtherMeta",
+			head: `<meta name="description" content="noindex"><meta name="googlebot-news" content="nofollow">`,
+		},
+		{
+			name:             "Header",
+			head:             `<meta name="robots" content="index, follow">`,
+			header:           http.Header{"X-Robots-Tag": []string{"nofollow"}},
+			expectedNoFollow: true,
+		},
+		{
+			name:             "MetaAndHeader",
+			head:             `<meta name="robots" content="nofollow">`,
+			header:           http.Header{"X-Robots-Tag": []string{"otherbot: all", "NONE"}},
+			expectedNoIndex:  true,
+			expectedNoFollow: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+			doc, err := html.Parse(strings.NewReader("<html><head>" + tc.head + "</head><body></body></html>"))
+			require.NoError(t, err)
+
+			analysis := &AnalysisResult{baseURL: "https://example.com", headings: make(map[string]int)}
+			analyzer.traverseNode(doc, analysis)
+			result := analysis.snapshot()
+			analyzer.applyPageDetails(&result, &fetchedPage{statusCode: http.StatusOK, header: tc.header, url: "https://example.com"})
+
+			assert.Equal(t, tc.expectedNoIndex, result.NoIndex)
+			assert.Equal(t, tc.expectedNoFollow, result.NoFollow)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package analyzer
+
+import (
+	"analyzer/internal/config"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxDepthBucket is the depth from which internal links share the "deeper" bucket
+	maxDepthBucket = 3
+
+	// navOnlyMinLinks is the minimum number of internal links before a page can be flagged as nav-only
+	navOnlyMinLinks = 2
+)
+
+// analyzeLinkStructure buckets the resolved internal links by path depth
+// and flags pages whose internal links mostly point to a single path
+func (s *Analyzer) analyzeLinkStructure(result *AnalysisResult) {
+	pathCounts := make(map[string]int)
+	total := 0
+
+	for _, link := range result.links {
+		if s.isExternalURL(link, result.baseURL) {
+			continue
+		}
+
+		u, err := url.Parse(link)
+		if err != nil {
+			continue
+		}
+
+		segments := pathSegments(u.EscapedPath())
+		depth := len(segments)
+
+		result.linkDepthHistogram[depthBucket(depth)]++
+		if depth > result.maxLinkDepth {
+			result.maxLinkDepth = depth
+		}
+
+		pathCounts["/"+strings.Join(segments, "/")]++
+		total++
+	}
+
+	if total < navOnlyMinLinks {
+		return
+	}
+
+	maxCount := 0
+	for _, count := range pathCounts {
+		maxCount = max(maxCount, count)
+	}
+
+	result.navOnly = float64(maxCount)/float64(total) > s.navOnlyLinkFraction()
+}
+
+// navOnlyLinkFraction returns the configured nav-only threshold
+func (s *Analyzer) navOnlyLinkFraction() float64 {
+	if s.cfg != nil && s.cfg.Analysis.NavOnlyLinkFraction > 0 {
+		return s.cfg.Analysis.NavOnlyLinkFraction
+	}
+	return config.DefaultNavOnlyLinkFraction
+}
+
+// pathSegments splits an escaped URL path into its segments.
+// Empty and "." segments are dropped and ".." removes the previous segment,
+// while encoded slashes (%2F) stay part of their segment.
+func pathSegments(escapedPath string) []string {
+	segments := []string{}
+	for _, segment := range strings.Split(escapedPath, "/") {
+		switch segment {
+		case "", ".":
+			continue
+		case "..":
+			if len(segments) > 0 {
+				segments = segments[:len(segments)-1]
+			}
+		default:
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// depthBucket returns the histogram bucket for a path depth: "0", "1", "2" or "3+"
+func depthBucket(depth int) string {
+	if depth >= maxDepthBucket {
+		return strconv.Itoa(maxDepthBucket) + "+"
+	}
+	return strconv.Itoa(depth)
+}
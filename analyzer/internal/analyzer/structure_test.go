@@ -0,0 +1,117 @@
+package analyzer
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathSegments(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		expected int
+	}{
+		{name: "Root", path: "/", expected: 0},
+		{name: "Empty", path: "", expected: 0},
+		{name: "SingleSegment", path: "/about", expected: 1},
+		{name: "TrailingSlash", path: "/about/", expected: 1},
+		{name: "TwoSegments", path: "/docs/intro", expected: 2},
+		{name: "DuplicateSlashes", path: "//docs///intro", expected: 2},
+		{name: "EncodedSlash", path: "/files/a%2Fb", expected: 2},
+		{name: "EncodedSpace", path: "/my%20docs/page", expected: 2},
+		{name: "DotSegments", path: "/a/./b/../c", expected: 2},
+		{name: "ParentAboveRoot", path: "/../a", expected: 1},
+		{name: "Deep", path: "/a/b/c/d/e", expected: 5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Len(t, pathSegments(tc.path), tc.expected)
+		})
+	}
+}
+
+func TestDepthBucket(t *testing.T) {
+	assert.Equal(t, "0", depthBucket(0))
+	assert.Equal(t, "1", depthBucket(1))
+	assert.Equal(t, "2", depthBucket(2))
+	assert.Equal(t, "3+", depthBucket(3))
+	assert.Equal(t, "3+", depthBucket(12))
+}
+
+func TestAnalyzer_AnalyzeLinkStructure(t *testing.T) {
+	testCases := []struct {
+		name              string
+		links             []string
+		expectedHistogram map[string]int
+		expectedMaxDepth  int
+		expectedNavOnly   bool
+	}{
+		{
+			name: "MixedDepths",
+			links: []string{
+				"https://example.com/",
+				"https://example.com/about/",
+				"https://example.com/docs/intro",
+				"https://example.com/docs/a/b/c",
+				"https://external.com/a/b",
+			},
+			expectedHistogram: map[string]int{"0": 1, "1": 1, "2": 1, "3+": 1},
+			expectedMaxDepth:  4,
+			expectedNavOnly:   false,
+		},
+		{
+			name: "NavOnly",
+			links: []string{
+				"https://example.com/home",
+				"https://example.com/home/",
+				"https://example.com/home?tab=1",
+				"https://example.com/home#top",
+				"https://example.com/",
+			},
+			expectedHistogram: map[string]int{"0": 1, "1": 4},
+			expectedMaxDepth:  1,
+			expectedNavOnly:   false, // 4/5 is not more than the default 0.8
+		},
+		{
+			name: "NavOnlyAboveThreshold",
+			links: []string{
+				"https://example.com/home",
+				"https://example.com/home/",
+				"https://example.com/home?tab=1",
+				"https://example.com/home#top",
+				"https://example.com/home/.",
+				"https://example.com/",
+			},
+			expectedHistogram: map[string]int{"0": 1, "1": 5},
+			expectedMaxDepth:  1,
+			expectedNavOnly:   true,
+		},
+		{
+			name:              "SingleLinkNotNavOnly",
+			links:             []string{"https://example.com/home"},
+			expectedHistogram: map[string]int{"1": 1},
+			expectedMaxDepth:  1,
+			expectedNavOnly:   false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+			result := &AnalysisResult{
+				links:              tc.links,
+				baseURL:            "https://example.com",
+				linkDepthHistogram: make(map[string]int),
+			}
+
+			s.analyzeLinkStructure(result)
+
+			assert.Equal(t, tc.expectedHistogram, result.linkDepthHistogram)
+			assert.Equal(t, tc.expectedMaxDepth, result.maxLinkDepth)
+			assert.Equal(t, tc.expectedNavOnly, result.navOnly)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package analyzer
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"shared/models"
+	"sync"
+	"time"
+)
+
+// Content fetch phases reported to metrics
+const (
+	fetchPhaseDNS          = "dns"
+	fetchPhaseConnect      = "connect"
+	fetchPhaseTLSHandshake = "tls_handshake"
+	fetchPhaseTTFB         = "ttfb"
+	fetchPhaseDownload     = "download"
+	fetchPhaseTotal        = "total"
+)
+
+// fetchTimer collects the phase timings of a page fetch through an httptrace.ClientTrace.
+// The trace hooks may be called from the transport's dialing goroutines, so the times are guarded by mu.
+// Each redirect hop overwrites the connection phases, leaving those of the last connection opened.
+type fetchTimer struct {
+	mu sync.Mutex
+
+	start     time.Time
+	firstByte time.Time
+
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+
+	dns          time.Duration
+	connect      time.Duration
+	tlsHandshake time.Duration
+}
+
+// newFetchTimer starts timing a fetch whose request is sent at start
+func newFetchTimer(start time.Time) *fetchTimer {
+	return &fetchTimer{start: start}
+}
+
+// trace returns ctx carrying the timer's client trace, composed with any trace ctx already carries.
+// It must be set on the request before the client sends it, so every transport wrapping the connection sees it.
+func (t *fetchTimer) trace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.measure(&t.dns, &t.dnsStart) },
+		ConnectStart: func(network, addr string) {
+			t.mark(&t.connectStart)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				t.measure(&t.connect, &t.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				t.measure(&t.tlsHandshake, &t.tlsStart)
+			}
+		},
+		GotFirstResponseByte: func() { t.mark(&t.firstByte) },
+	})
+}
+
+// mark records the current time in at
+func (t *fetchTimer) mark(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*at = time.Now()
+}
+
+// measure records in d the time elapsed since the phase marked in since started
+func (t *fetchTimer) measure(d *time.Duration, since *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !since.IsZero() {
+		*d = time.Since(*since)
+	}
+}
+
+// finish returns the timing of the fetch whose body was fully read at end.
+// The first byte phases are left zero when the transport did not report the first response byte.
+func (t *fetchTimer) finish(end time.Time) *models.FetchTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timing := &models.FetchTiming{
+		DNSSeconds:          t.dns.Seconds(),
+		ConnectSeconds:      t.connect.Seconds(),
+		TLSHandshakeSeconds: t.tlsHandshake.Seconds(),
+		TotalSeconds:        max(end.Sub(t.start), 0).Seconds(),
+	}
+	if !t.firstByte.IsZero() {
+		timing.TimeToFirstByteSeconds = max(t.firstByte.Sub(t.start), 0).Seconds()
+		timing.DownloadSeconds = max(end.Sub(t.firstByte), 0).Seconds()
+	}
+	return timing
+}
+
+// recordFetchTiming reports the phases of a fetch to metrics, skipping the ones that did not happen
+func (s *Analyzer) recordFetchTiming(timing *models.FetchTiming) {
+	phases := []struct {
+		name    string
+		seconds float64
+	}{
+		{fetchPhaseDNS, timing.DNSSeconds},
+		{fetchPhaseConnect, timing.ConnectSeconds},
+		{fetchPhaseTLSHandshake, timing.TLSHandshakeSeconds},
+		{fetchPhaseTTFB, timing.TimeToFirstByteSeconds},
+		{fetchPhaseDownload, timing.DownloadSeconds},
+		{fetchPhaseTotal, timing.TotalSeconds},
+	}
+
+	for _, phase := range phases {
+		if phase.seconds > 0 {
+			s.metrics.RecordContentFetchPhase(phase.name, phase.seconds)
+		}
+	}
+}
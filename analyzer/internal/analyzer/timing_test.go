@@ -0,0 +1,104 @@
+package analyzer
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"shared/metrics"
+	"shared/models"
+	"shared/tracing"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fetchPhaseMetrics records the content fetch phases reported to metrics
+type fetchPhaseMetrics struct {
+	metrics.NoOpAnalyzerMetrics
+	mu     sync.Mutex
+	phases map[string]float64
+}
+
+func (m *fetchPhaseMetrics) RecordContentFetchPhase(phase string, duration float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.phases == nil {
+		m.phases = make(map[string]float64)
+	}
+	m.phases[phase] = duration
+}
+
+func TestAnalyzer_FetchContentTiming(t *testing.T) {
+	transport := &sequenceRoundTripper{responses: []func(req *http.Request) (*http.Response, error){
+		statusResponse(200, "<html></html>"),
+	}}
+	s := NewAnalyzer(nil, nil, nil,
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithLogger(slog.New(slog.DiscardHandler)),
+	)
+
+	page, err := s.fetchContent(context.Background(), "https://example.com")
+	require.NoError(t, err)
+
+	// The mock transport opens no connection, only the total is measured
+	require.NotNil(t, page.timing)
+	assert.GreaterOrEqual(t, page.timing.DNSSeconds, 0.0)
+	assert.GreaterOrEqual(t, page.timing.ConnectSeconds, 0.0)
+	assert.GreaterOrEqual(t, page.timing.TLSHandshakeSeconds, 0.0)
+	assert.GreaterOrEqual(t, page.timing.TimeToFirstByteSeconds, 0.0)
+	assert.GreaterOrEqual(t, page.timing.DownloadSeconds, 0.0)
+	assert.GreaterOrEqual(t, page.timing.TotalSeconds, 0.0)
+
+	var result models.AnalyzeResult
+	s.applyPageDetails(&result, page)
+	assert.Same(t, page.timing, result.FetchTiming)
+}
+
+func TestAnalyzer_FetchContentTiming_Server(t *testing.T) {
+	const latency = 100 * time.Millisecond
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(latency)
+		_, _ = w.Write([]byte("<html><title>slow</title></html>"))
+	}))
+	defer server.Close()
+
+	m := &fetchPhaseMetrics{}
+	// The span of the tracing transport must not replace the client trace of the request
+	s := NewAnalyzer(nil, nil, nil,
+		WithHTTPClient(&http.Client{Transport: tracing.HTTPClientMiddleware()(server.Client().Transport)}),
+		WithLogger(slog.New(slog.DiscardHandler)),
+		WithMetrics(m),
+	)
+
+	// A trace set by the caller keeps receiving its events
+	var callerFirstByte atomic.Bool
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		GotFirstResponseByte: func() { callerFirstByte.Store(true) },
+	})
+
+	page, err := s.fetchContent(ctx, server.URL)
+	require.NoError(t, err)
+	require.NotNil(t, page.timing)
+
+	timing := page.timing
+	assert.GreaterOrEqual(t, timing.TimeToFirstByteSeconds, latency.Seconds(), "TTFB should include the server latency")
+	assert.Greater(t, timing.ConnectSeconds, 0.0)
+	assert.Greater(t, timing.TLSHandshakeSeconds, 0.0)
+	assert.Zero(t, timing.DNSSeconds, "an IP address is not looked up")
+	assert.GreaterOrEqual(t, timing.DownloadSeconds, 0.0)
+	assert.InDelta(t, timing.TotalSeconds, timing.TimeToFirstByteSeconds+timing.DownloadSeconds, 1e-6)
+	assert.True(t, callerFirstByte.Load())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	assert.Equal(t, timing.TimeToFirstByteSeconds, m.phases[fetchPhaseTTFB])
+	assert.Equal(t, timing.TLSHandshakeSeconds, m.phases[fetchPhaseTLSHandshake])
+	assert.Equal(t, timing.TotalSeconds, m.phases[fetchPhaseTotal])
+	assert.NotContains(t, m.phases, fetchPhaseDNS)
+}
@@ -0,0 +1,46 @@
+package analyzer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"shared/models"
+	"strings"
+)
+
+// describeTLSError classifies and describes a failed TLS handshake for a subtask, such as with a site only speaking
+// a TLS version older than HTTP_MIN_TLS_VERSION or none of the allowed cipher suites. A certificate that could not
+// be verified is told apart by why, where x509 says so. It reports false for other errors.
+func describeTLSError(err error) (models.LinkErrorCode, string, bool) {
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &invalidErr) && invalidErr.Reason == x509.Expired {
+		return models.LinkErrorTLSCertificate, "TLS certificate expired or not yet valid", true
+	}
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &hostnameErr) {
+		return models.LinkErrorTLSCertificate, fmt.Sprintf("TLS certificate is not valid for %s", hostnameErr.Host), true
+	}
+	var authorityErr x509.UnknownAuthorityError
+	if errors.As(err, &authorityErr) {
+		return models.LinkErrorTLSCertificate, "TLS certificate signed by an unknown authority", true
+	}
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return models.LinkErrorTLSCertificate, fmt.Sprintf("TLS certificate rejected: %s", certErr.Err), true
+	}
+
+	// The client's own refusals, such as of a protocol version it does not accept, are plain errors
+	var alertErr tls.AlertError
+	var headerErr tls.RecordHeaderError
+	if !errors.As(err, &alertErr) && !errors.As(err, &headerErr) && !strings.Contains(err.Error(), "tls: ") {
+		return "", "", false
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	return models.LinkErrorTLSHandshake, fmt.Sprintf("TLS handshake failed: %s", err), true
+}
@@ -0,0 +1,78 @@
+package analyzer
+
+import (
+	"analyzer/internal/config"
+	"context"
+	"crypto/tls"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"shared/models"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzer_VerifyLink_TLS(t *testing.T) {
+	// The site only speaks TLS 1.2
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	// Each case connects afresh, with the server's certificate trusted and at least minVersion
+	trustingClient := func(minVersion uint16) *http.Client {
+		transport := srv.Client().Transport.(*http.Transport).Clone()
+		transport.TLSClientConfig.MinVersion = minVersion
+		return &http.Client{Transport: transport}
+	}
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name           string
+		client         func() *http.Client
+		expectedStatus models.TaskStatus
+		expectedDesc   string
+	}{
+		{
+			name:           "Accepted",
+			client:         func() *http.Client { return trustingClient(tls.VersionTLS12) },
+			expectedStatus: models.TaskStatusCompleted,
+			expectedDesc:   "HTTP 200: OK",
+		},
+		{
+			name:           "VersionTooOld",
+			client:         func() *http.Client { return trustingClient(tls.VersionTLS13) },
+			expectedStatus: models.TaskStatusFailed,
+			expectedDesc:   "TLS handshake failed: remote error: tls: protocol version not supported",
+		},
+		{
+			name:           "UntrustedCertificate",
+			client:         func() *http.Client { return &http.Client{} },
+			expectedStatus: models.TaskStatusFailed,
+			expectedDesc:   "TLS certificate signed by an unknown authority",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.Load()
+			cfg.HTTP.AllowedPorts = []int{port}
+			s := NewAnalyzer(nil, nil, nil, WithHTTPClient(tc.client()), WithConfig(cfg))
+
+			check := s.verifyLink(context.Background(), srv.URL, models.HeaderProfileDefault)
+
+			assert.Equal(t, tc.expectedStatus, check.status)
+			assert.Equal(t, tc.expectedDesc, check.description)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package analyzer
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// minInlineTokenLength keeps inline markers specific, a short token would match unrelated scripts
+const minInlineTokenLength = 6
+
+//go:embed trackers.json
+var builtinTrackersJSON []byte
+
+// builtinTrackers are the signatures of the common trackers, always detected
+var builtinTrackers = mustParseTrackerSignatures(builtinTrackersJSON)
+
+// TrackerSignature identifies a third-party tracker by the scripts it loads and the snippets it inlines
+type TrackerSignature struct {
+	Name string `json:"name"`
+	// Scripts match the src of external scripts
+	Scripts []ScriptPattern `json:"scripts"`
+	// Inline are tokens specific to the tracker's snippet, matched verbatim in inline scripts
+	Inline []string `json:"inline"`
+}
+
+// ScriptPattern matches a script src on its host, subdomains included, and on a path prefix when one is set
+type ScriptPattern struct {
+	Host string `json:"host"`
+	Path string `json:"path,omitempty"`
+}
+
+// LoadTrackerSignatures returns the built-in tracker signatures, followed by those of the JSON file at path if set
+func LoadTrackerSignatures(path string) ([]TrackerSignature, error) {
+	if path == "" {
+		return builtinTrackers, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tracker signatures: %w", err)
+	}
+	extra, err := parseTrackerSignatures(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tracker signatures in %s: %w", path, err)
+	}
+	return append(append([]TrackerSignature(nil), builtinTrackers...), extra...), nil
+}
+
+// parseTrackerSignatures decodes a JSON list of signatures, rejecting those that would match too broadly
+func parseTrackerSignatures(data []byte) ([]TrackerSignature, error) {
+	var signatures []TrackerSignature
+	if err := json.Unmarshal(data, &signatures); err != nil {
+		return nil, err
+	}
+
+	for i, sig := range signatures {
+		if sig.Name == "" {
+			return nil, fmt.Errorf("signature %d has no name", i)
+		}
+		if len(sig.Scripts) == 0 && len(sig.Inline) == 0 {
+			return nil, fmt.Errorf("signature %q matches neither scripts nor inline snippets", sig.Name)
+		}
+		for j, pattern := range sig.Scripts {
+			if pattern.Host == "" {
+				return nil, fmt.Errorf("signature %q has a script pattern without a host", sig.Name)
+			}
+			signatures[i].Scripts[j].Host = strings.ToLower(strings.TrimPrefix(pattern.Host, "."))
+		}
+		for _, token := range sig.Inline {
+			if len(token) < minInlineTokenLength {
+				return nil, fmt.Errorf("signature %q has inline token %q shorter than %d characters", sig.Name, token, minInlineTokenLength)
+			}
+		}
+	}
+	return signatures, nil
+}
+
+func mustParseTrackerSignatures(data []byte) []TrackerSignature {
+	signatures, err := parseTrackerSignatures(data)
+	if err != nil {
+		panic(errors.Join(err, errors.New("invalid built-in tracker signatures")))
+	}
+	return signatures
+}
+
+// matchesScript reports whether an external script src is served by the tracker.
+// Relative sources are the page's own scripts and never match.
+func (sig TrackerSignature) matchesScript(src string) bool {
+	u, err := url.Parse(strings.TrimSpace(src))
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, pattern := range sig.Scripts {
+		if host != pattern.Host && !strings.HasSuffix(host, "."+pattern.Host) {
+			continue
+		}
+		if strings.HasPrefix(u.Path, pattern.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesInline reports whether an inline script holds one of the tracker's snippet tokens
+func (sig TrackerSignature) matchesInline(code string) bool {
+	for _, token := range sig.Inline {
+		if strings.Contains(code, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// detectTrackers records the trackers a script element loads or inlines, each one once per page
+func (s *Analyzer) detectTrackers(n *html.Node, src string, result *AnalysisResult) {
+	var code strings.Builder
+	if src == "" {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.TextNode {
+				code.WriteString(child.Data)
+			}
+		}
+	}
+
+	for _, sig := range s.trackers {
+		if result.seenTrackers[sig.Name] {
+			continue
+		}
+		if src != "" && !sig.matchesScript(src) || src == "" && !sig.matchesInline(code.String()) {
+			continue
+		}
+
+		if result.seenTrackers == nil {
+			result.seenTrackers = make(map[string]bool)
+		}
+		result.seenTrackers[sig.Name] = true
+		result.trackers = append(result.trackers, sig.Name)
+	}
+}
@@ -0,0 +1,148 @@
+package analyzer
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+// detectFixtureTrackers parses a testdata page and returns the trackers detected in it
+func detectFixtureTrackers(t *testing.T, analyzer *Analyzer, file string) ([]string, int) {
+	t.Helper()
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+	doc, err := html.Parse(f)
+	require.NoError(t, err)
+
+	result := &AnalysisResult{baseURL: "https://example.com", headings: make(map[string]int)}
+	analyzer.traverseNode(doc, result)
+
+	built := result.snapshot()
+	return built.TrackersDetected, built.TrackerCount
+}
+
+func TestAnalyzer_DetectTrackers_Fixtures(t *testing.T) {
+	testCases := []struct {
+		name     string
+		htmlFile string
+		expected []string
+	}{
+		{
+			name:     "GA4",
+			htmlFile: "testdata/trackers_ga4.html",
+			expected: []string{"Google Analytics"},
+		},
+		{
+			// The GTM snippet loads gtm.js from script code, the noscript iframe is not a script
+			name:     "GTMAndPixel",
+			htmlFile: "testdata/trackers_gtm.html",
+			expected: []string{"Google Tag Manager", "Facebook Pixel"},
+		},
+		{
+			// Look-alike hosts, first-party scripts and prose naming trackers are not detections
+			name:     "Clean",
+			htmlFile: "testdata/trackers_clean.html",
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)))
+
+			trackers, count := detectFixtureTrackers(t, analyzer, tc.htmlFile)
+			assert.Equal(t, tc.expected, trackers)
+			assert.Equal(t, len(tc.expected), count)
+		})
+	}
+}
+
+func TestTrackerSignature_MatchesScript(t *testing.T) {
+	sig := TrackerSignature{Name: "GA", Scripts: []ScriptPattern{
+		{Host: "google-analytics.com"},
+		{Host: "googletagmanager.com", Path: "/gtag/js"},
+	}}
+
+	testCases := []struct {
+		name     string
+		src      string
+		expected bool
+	}{
+		{name: "Host", src: "https://google-analytics.com/analytics.js", expected: true},
+		{name: "Subdomain", src: "https://www.google-analytics.com/analytics.js", expected: true},
+		{name: "ProtocolRelative", src: "//www.googletagmanager.com/gtag/js?id=G-1", expected: true},
+		{name: "UpperCaseHost", src: "https://WWW.Google-Analytics.com/ga.js", expected: true},
+		{name: "OtherPath", src: "https://www.googletagmanager.com/gtm.js?id=GTM-1", expected: false},
+		{name: "LookAlikeHost", src: "https://notgoogle-analytics.com/analytics.js", expected: false},
+		{name: "HostInPath", src: "https://cdn.example.com/google-analytics.com/analytics.js", expected: false},
+		{name: "Relative", src: "/js/google-analytics.com.js", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, sig.matchesScript(tc.src))
+		})
+	}
+}
+
+func TestLoadTrackerSignatures(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	t.Run("BuiltinOnly", func(t *testing.T) {
+		signatures, err := LoadTrackerSignatures("")
+		require.NoError(t, err)
+		assert.Equal(t, builtinTrackers, signatures)
+	})
+
+	t.Run("Extra", func(t *testing.T) {
+		path := write("extra.json", `[{"name": "Plausible", "scripts": [{"host": ".Plausible.io"}], "inline": ["window.plausible"]}]`)
+		signatures, err := LoadTrackerSignatures(path)
+		require.NoError(t, err)
+		require.Len(t, signatures, len(builtinTrackers)+1)
+		assert.Equal(t, TrackerSignature{
+			Name:    "Plausible",
+			Scripts: []ScriptPattern{{Host: "plausible.io"}},
+			Inline:  []string{"window.plausible"},
+		}, signatures[len(signatures)-1])
+
+		analyzer := NewAnalyzer(nil, nil, nil, WithLogger(slog.New(slog.DiscardHandler)), WithTrackerSignatures(signatures))
+		page := write("plausible.html", `<html><head>
+			<script defer src="https://plausible.io/js/script.js"></script>
+			<script async src="https://www.googletagmanager.com/gtag/js?id=G-1"></script>
+		</head></html>`)
+		trackers, count := detectFixtureTrackers(t, analyzer, page)
+		assert.Equal(t, []string{"Plausible", "Google Analytics"}, trackers)
+		assert.Equal(t, 2, count)
+	})
+
+	invalid := map[string]string{
+		"Malformed":     `{"name": "X"}`,
+		"NoName":        `[{"scripts": [{"host": "x.com"}]}]`,
+		"NoMatchers":    `[{"name": "X"}]`,
+		"NoHost":        `[{"name": "X", "scripts": [{"path": "/x.js"}]}]`,
+		"GenericInline": `[{"name": "X", "inline": ["track"]}]`,
+	}
+	for name, content := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadTrackerSignatures(write(name+".json", content))
+			assert.Error(t, err)
+		})
+	}
+
+	t.Run("MissingFile", func(t *testing.T) {
+		_, err := LoadTrackerSignatures(filepath.Join(dir, "missing.json"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,38 @@
+package analyzer
+
+import "shared/models"
+
+// maxStoredLinks returns how many entries each link list of a result keeps, zero for all of them
+func (s *Analyzer) maxStoredLinks() int {
+	if s.cfg == nil {
+		return 0
+	}
+	return s.cfg.Analysis.MaxStoredLinks
+}
+
+// truncateLinks cuts the link lists of a result to their first limit entries, reporting whether any was cut.
+// The counts are left alone so they still cover every link, and the lists are resliced, never written to.
+func truncateLinks(result *models.AnalyzeResult, limit int) bool {
+	if limit <= 0 {
+		return false
+	}
+
+	var truncated bool
+	result.Links = firstEntries(result.Links, limit, &truncated)
+	result.OriginalLinks = firstEntries(result.OriginalLinks, limit, &truncated)
+	result.LinkClassifications = firstEntries(result.LinkClassifications, limit, &truncated)
+	result.BrokenAnchors = firstEntries(result.BrokenAnchors, limit, &truncated)
+	result.RedundantRedirectLinks = firstEntries(result.RedundantRedirectLinks, limit, &truncated)
+	// One entry per form and reason, so a page with many forms grows it as much as its links
+	result.InsecureForms = firstEntries(result.InsecureForms, limit, &truncated)
+	return truncated
+}
+
+// firstEntries returns the first limit entries of list, setting truncated when there were more
+func firstEntries[T any](list []T, limit int, truncated *bool) []T {
+	if len(list) <= limit {
+		return list
+	}
+	*truncated = true
+	return list[:limit:limit]
+}
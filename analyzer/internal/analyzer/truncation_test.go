@@ -0,0 +1,104 @@
+package analyzer
+
+import (
+	"shared/models"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncateLinks(t *testing.T) {
+	links := func(n int) []string {
+		list := make([]string, n)
+		for i := range list {
+			list[i] = "https://example.com/" + strconv.Itoa(i)
+		}
+		return list
+	}
+
+	testCases := []struct {
+		name              string
+		linkCount         int
+		limit             int
+		expectedLinks     int
+		expectedTruncated bool
+	}{
+		{name: "NoLimit", linkCount: 10, limit: 0, expectedLinks: 10},
+		{name: "BelowLimit", linkCount: 4, limit: 5, expectedLinks: 4},
+		{name: "AtLimit", linkCount: 5, limit: 5, expectedLinks: 5},
+		{name: "OneOverLimit", linkCount: 6, limit: 5, expectedLinks: 5, expectedTruncated: true},
+		{name: "FarOverLimit", linkCount: 1000, limit: 1, expectedLinks: 1, expectedTruncated: true},
+		{name: "NoLinks", linkCount: 0, limit: 1, expectedLinks: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := models.AnalyzeResult{Links: links(tc.linkCount), InternalLinkCount: tc.linkCount}
+
+			truncated := truncateLinks(&result, tc.limit)
+
+			assert.Equal(t, tc.expectedTruncated, truncated)
+			assert.Len(t, result.Links, tc.expectedLinks)
+			assert.Equal(t, links(tc.expectedLinks), result.Links, "the first links should be kept in order")
+			assert.Equal(t, tc.linkCount, result.InternalLinkCount, "the counts should cover every link")
+		})
+	}
+}
+
+func TestTruncateLinks_DerivedLists(t *testing.T) {
+	result := models.AnalyzeResult{
+		Links:         []string{"https://example.com/a", "https://example.com/b"},
+		OriginalLinks: []string{"https://example.com/a?utm_source=x", "https://example.com/b?utm_source=x"},
+		LinkClassifications: []models.LinkClassification{
+			{URL: "https://example.com/a", Reason: models.LinkReasonSameOrigin},
+			{URL: "https://example.com/b", Reason: models.LinkReasonSameOrigin},
+		},
+		BrokenAnchors:     []string{"#one", "#two", "#three"},
+		BrokenAnchorCount: 3,
+		RedundantRedirectLinks: []models.LinkRedirect{
+			{From: "https://example.com/a", To: "https://example.com/a/"},
+		},
+		RedundantRedirectCount: 1,
+		InsecureForms: []models.InsecureForm{
+			{Action: "http://example.com/login", Reason: models.InsecureFormHTTPAction},
+			{Action: "http://example.com/search", Reason: models.InsecureFormHTTPAction},
+			{Action: "http://example.com/signup", Reason: models.InsecureFormHTTPAction},
+		},
+	}
+
+	assert.True(t, truncateLinks(&result, 2), "the broken anchors alone exceed the limit")
+	assert.Len(t, result.Links, 2)
+	assert.Len(t, result.OriginalLinks, 2)
+	assert.Len(t, result.LinkClassifications, 2)
+	assert.Equal(t, []string{"#one", "#two"}, result.BrokenAnchors)
+	assert.Equal(t, 3, result.BrokenAnchorCount)
+	assert.Len(t, result.RedundantRedirectLinks, 1)
+	assert.Equal(t, 1, result.RedundantRedirectCount)
+	if assert.Len(t, result.InsecureForms, 2) {
+		assert.Equal(t, "http://example.com/search", result.InsecureForms[1].Action)
+	}
+}
+
+func TestAnalysisResult_SnapshotTruncatesLinks(t *testing.T) {
+	result := &AnalysisResult{
+		headings:       map[string]int{"h1": 1},
+		links:          []string{"https://example.com/a", "https://other.com/b", "https://example.com/c"},
+		maxStoredLinks: 2,
+	}
+	result.counts.add(countInternalLinks, 2)
+	result.counts.inc(countExternalLinks)
+
+	built := result.snapshot()
+
+	assert.True(t, built.LinksTruncated)
+	assert.Equal(t, []string{"https://example.com/a", "https://other.com/b"}, built.Links)
+	assert.Equal(t, 2, built.InternalLinkCount)
+	assert.Equal(t, 1, built.ExternalLinkCount)
+	assert.Len(t, result.links, 3, "the analysis should keep every link")
+
+	result.maxStoredLinks = 3
+	built = result.snapshot()
+	assert.False(t, built.LinksTruncated)
+	assert.Len(t, built.Links, 3)
+}
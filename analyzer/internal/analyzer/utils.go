@@ -2,6 +2,7 @@ package analyzer
 
 import (
 	"net/url"
+	"shared/log"
 	"strings"
 
 	"golang.org/x/net/html"
@@ -17,6 +18,16 @@ func (s *Analyzer) getElementAttribute(n *html.Node, key string) string {
 	return ""
 }
 
+// hasAttribute reports whether an element has the attribute, even when it is empty
+func hasAttribute(n *html.Node, key string) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
 // resolveURL resolves a relative URL to an absolute URL
 func (s *Analyzer) resolveURL(href, baseURL string) string {
 	// Already absolute URL
@@ -26,19 +37,19 @@ func (s *Analyzer) resolveURL(href, baseURL string) string {
 
 	// Need base URL to resolve relative URLs
 	if baseURL == "" {
-		s.log.Warn("Cannot resolve relative URL without base URL", "href", href)
+		s.log.Warn("Cannot resolve relative URL without base URL", log.URL("href", href))
 		return ""
 	}
 
 	base, err := url.Parse(baseURL)
 	if err != nil {
-		s.log.Error("Failed to parse base URL", "baseURL", baseURL, "error", err)
+		s.log.Error("Failed to parse base URL", log.URL("baseURL", baseURL), log.Error(err))
 		return ""
 	}
 
 	relativeURL, err := url.Parse(href)
 	if err != nil {
-		s.log.Error("Failed to parse relative URL", "href", href, "error", err)
+		s.log.Error("Failed to parse relative URL", log.URL("href", href), log.Error(err))
 		return ""
 	}
 
@@ -51,38 +62,6 @@ func (s *Analyzer) isAbsoluteURL(href string) bool {
 	return strings.HasPrefix(href, "http://") || strings.HasPrefix(href, "https://")
 }
 
-// isExternalURL determines if a URL is external to the base domain
-func (s *Analyzer) isExternalURL(absoluteURL, baseURL string) bool {
-	// If no base URL is set, assume external
-	if baseURL == "" {
-		return true
-	}
-
-	targetURL, err := url.Parse(absoluteURL)
-	if err != nil {
-		s.log.Error("Failed to parse target URL for external check", "url", absoluteURL, "error", err)
-		return true // Assume external on parse error
-	}
-
-	baseURLParsed, err := url.Parse(baseURL)
-	if err != nil {
-		s.log.Error("Failed to parse base URL for external check", "baseURL", baseURL, "error", err)
-		return true // Assume external on parse error
-	}
-
-	// Same scheme and host: internal
-	if targetURL.Scheme == baseURLParsed.Scheme && targetURL.Host == baseURLParsed.Host {
-		return false
-	}
-
-	// Different schemes: external
-	if targetURL.Scheme != baseURLParsed.Scheme {
-		return true
-	}
-
-	return true
-}
-
 // shouldProcessLink determines if a link should be processed
 func (s *Analyzer) shouldProcessLink(href string) bool {
 	if href == "" || href == "/" {
@@ -116,22 +95,40 @@ func (s *Analyzer) isLoginForm(formNode *html.Node) bool {
 
 // traverseFormInputs traverses form inputs to detect login form characteristics
 func (s *Analyzer) traverseFormInputs(n *html.Node, hasPassword, hasUsername, hasSubmit *bool) {
-	if n.Type == html.ElementNode {
-		switch n.Data {
-		case "input":
-			s.processInputElement(n, hasPassword, hasUsername, hasSubmit)
-		case "button":
-			s.processButtonElement(n, hasSubmit)
+	walkNodes(n, func(node *html.Node) bool {
+		if node.Type == html.ElementNode {
+			switch node.Data {
+			case "input":
+				s.processInputElement(node, hasPassword, hasUsername, hasSubmit)
+			case "button":
+				s.processButtonElement(node, hasSubmit)
+			}
 		}
-	}
 
-	// Recursively check child nodes
-	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		s.traverseFormInputs(c, hasPassword, hasUsername, hasSubmit)
 		// Early exit when all required components are found
-		if *hasPassword && *hasUsername && *hasSubmit {
-			break
+		return !(*hasPassword && *hasUsername && *hasSubmit)
+	})
+}
+
+// walkNodes visits root and its descendants depth-first in document order until visit returns false.
+// It follows the sibling and parent links instead of recursing, so deeply nested documents cannot exhaust the stack.
+func walkNodes(root *html.Node, visit func(*html.Node) bool) {
+	for n := root; n != nil; {
+		if !visit(n) {
+			return
 		}
+
+		if n.FirstChild != nil {
+			n = n.FirstChild
+			continue
+		}
+		for n != root && n.NextSibling == nil {
+			n = n.Parent
+		}
+		if n == root {
+			return
+		}
+		n = n.NextSibling
 	}
 }
 
@@ -193,3 +190,24 @@ func (s *Analyzer) isUsernameField(inputType, name, id, placeholder string) bool
 
 	return false
 }
+
+// normalizeURL returns the form of a URL two URLs naming the same page share: the scheme and host in lower case,
+// without a default port, a trailing slash or a fragment
+func normalizeURL(u *url.URL) string {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = normalizedHost(u)
+	n.Path = strings.TrimSuffix(n.Path, "/")
+	n.RawPath = ""
+	n.Fragment, n.RawFragment = "", ""
+	return n.String()
+}
+
+// normalizedHost returns the host of a URL in lower case, without the default port of its scheme
+func normalizedHost(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+	if port := u.Port(); port == "80" && strings.EqualFold(u.Scheme, "http") || port == "443" && strings.EqualFold(u.Scheme, "https") {
+		host = strings.TrimSuffix(host, ":"+port)
+	}
+	return host
+}
@@ -2,26 +2,269 @@ package config
 
 import (
 	"shared/config"
+	"shared/models"
+	"strings"
+	"time"
 )
 
+// DefaultNavOnlyLinkFraction is the nav-only threshold when NAV_ONLY_LINK_FRACTION is not set
+const DefaultNavOnlyLinkFraction = 0.8
+
 // Config holds all configuration for the analyzer service
 type Config struct {
 	Service  config.ServiceConfig
+	Admin    config.AdminConfig
+	Audit    config.AuditConfig
 	HTTP     config.HTTPClientConfig
+	Fetch    FetchConfig
+	Analysis AnalysisConfig
+	Events   EventsConfig
+	HostRate HostRateConfig
+	DNS      DNSCacheConfig
+	Self     config.SelfProtectionConfig
 	Metrics  config.MetricsConfig
 	Tracing  config.TracingConfig
 	DynamoDB config.DynamoDBConfig
 	NATS     config.NATSConfig
 }
 
+// FetchConfig holds settings for fetching the analyzed page
+type FetchConfig struct {
+	// MaxRetries is the number of retries after a transient failure (network error, 5xx or 429)
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled on every further retry
+	RetryBackoff time.Duration
+	// MaxRetryBackoff caps the delay between retries
+	MaxRetryBackoff time.Duration
+	// CaptureHeaders enables recording the CapturedHeaders of the fetched page on the result
+	CaptureHeaders bool
+	// CapturedHeaders lists the response headers to record, matched case-insensitively
+	CapturedHeaders []string
+	// MaxContentBytes caps the size of the page once decoded, larger pages fail the job
+	MaxContentBytes int
+	// MaxRedirects is the number of redirects followed to reach the page, more fail the job
+	MaxRedirects int
+	// AnalyzeErrorPages analyzes a page served with a status other than 2xx, such as a 404 page, instead of
+	// failing the job. Such statuses are then not retried.
+	AnalyzeErrorPages bool
+}
+
+// AnalysisConfig holds tunables for the HTML analysis
+type AnalysisConfig struct {
+	// NavOnlyLinkFraction is the share of internal links pointing to a single path
+	// above which a page is flagged as nav-only
+	NavOnlyLinkFraction float64
+	// LinkExcludePatterns are regular expressions matched against each link's absolute URL,
+	// matching links are listed but never requested
+	LinkExcludePatterns []string
+	// VerifyImages enables checking image sources alongside links, adding a request per image
+	VerifyImages bool
+	// VerifyScope selects the links verified for jobs that do not choose: all, internal or external
+	VerifyScope string
+	// VerifyHeaderProfile selects the request headers links are verified with for jobs that do not choose:
+	// default, browser or fallback
+	VerifyHeaderProfile string
+	// VerifyMaxRedirects is the number of redirects followed when verifying a link, more mark it inaccessible
+	VerifyMaxRedirects int
+	// VerifyMaxJitter is the longest random delay a worker waits before each link request, zero sends them right away
+	VerifyMaxJitter time.Duration
+	// TimeBudget is how long a job aims to take from the page fetch on, zero for no budget.
+	// Once most of it is spent, only a sample of the links left is verified.
+	TimeBudget time.Duration
+	// CheckAnchors enables reporting the in-page links to fragments no element on the page has as its ID
+	CheckAnchors bool
+	// ExplainLinks records for each link why it was counted as internal or external, a diagnostic aid
+	ExplainLinks bool
+	// TrackerSignaturesFile is a JSON file of tracker signatures detected on top of the built-in ones, empty for none
+	TrackerSignaturesFile string
+	// StripQueryParams are the query parameters removed from links before they are counted and verified, such as
+	// utm_* and fbclid, so links differing only by them count once. A trailing * matches any name with that prefix.
+	StripQueryParams []string
+	// FollowPagination follows the page's rel="next" chain on its own host, up to PaginationMaxPages more pages,
+	// merging their headings and links into the result as one document
+	FollowPagination   bool
+	PaginationMaxPages int
+	// MaxStoredLinks caps the links stored in a result, each list of links derived from them and the insecure forms,
+	// so a page with pathologically many links or forms cannot bloat the job. Every link is still counted and verified. Zero stores them all.
+	MaxStoredLinks int
+}
+
+// EventsConfig holds settings for the progress events published while analyzing
+type EventsConfig struct {
+	// SubTaskGranularity selects which subtask updates are published: full, final-only or summary
+	SubTaskGranularity string
+	// ProgressInterval is how often the link verification progress and its running tallies are published
+	ProgressInterval time.Duration
+	// LoadInterval is how often the analyzer publishes its load, zero disables the reports
+	LoadInterval time.Duration
+	// BroadcastLinks includes the link lists in the result of the completed job update, otherwise only their counts
+	BroadcastLinks bool
+	// HeartbeatInterval is how often a running job's heartbeat is recorded, zero disables heartbeats
+	HeartbeatInterval time.Duration
+	// HeartbeatMaxDuration caps how long a single job sends heartbeats, a job still running past it is left
+	// for the API's reconciler to fail as stuck
+	HeartbeatMaxDuration time.Duration
+}
+
+// HostRateConfig holds the per-host rate limit shared by all analyses running in the process
+type HostRateConfig struct {
+	// RequestsPerSecond is the steady rate of requests allowed to a single host, zero disables the limit
+	RequestsPerSecond float64
+	// Burst is how many requests a host may receive at once before the rate applies
+	Burst int
+}
+
+// DNSCacheConfig holds the cache of host lookups shared by all outbound requests of the process
+type DNSCacheConfig struct {
+	// Enabled resolves hosts through the cache, otherwise every connection looks its host up
+	Enabled bool
+	// TTL is how long resolved addresses are reused, capped at five minutes
+	TTL time.Duration
+	// NegativeTTL is how long a host that does not exist is remembered as missing
+	NegativeTTL time.Duration
+}
+
 // Load loads the configuration for the analyzer service
 func Load() *Config {
+	// A whole analysis runs inside the url.analyze handler, so only flag handlers that are very slow
+	natsCfg := config.NewNATSConfig()
+	natsCfg.SlowHandlerThreshold = config.GetDurationEnv("NATS_SLOW_HANDLER_THRESHOLD", 2*time.Minute)
+
 	return &Config{
-		Service:  config.NewServiceConfig("analyzer"),
-		HTTP:     config.NewHTTPClientConfig(),
+		Service: config.NewServiceConfig("analyzer"),
+		Admin:   config.NewAdminConfig(),
+		Audit:   config.NewAuditConfig(),
+		HTTP:    config.NewHTTPClientConfig(),
+		Fetch: FetchConfig{
+			MaxRetries:        config.GetIntEnv("FETCH_MAX_RETRIES", 2),
+			RetryBackoff:      config.GetDurationEnv("FETCH_RETRY_BACKOFF", 500*time.Millisecond),
+			MaxRetryBackoff:   config.GetDurationEnv("FETCH_MAX_RETRY_BACKOFF", 5*time.Second),
+			CaptureHeaders:    config.GetBoolEnv("FETCH_CAPTURE_HEADERS", false),
+			MaxContentBytes:   config.GetIntEnv("FETCH_MAX_CONTENT_BYTES", 10*1024*1024),
+			MaxRedirects:      config.GetIntEnv("FETCH_MAX_REDIRECTS", 10),
+			AnalyzeErrorPages: config.GetBoolEnv("FETCH_ANALYZE_ERROR_PAGES", false),
+			CapturedHeaders: config.GetStringSliceEnv("FETCH_CAPTURED_HEADERS", []string{
+				"Content-Security-Policy",
+				"Strict-Transport-Security",
+				"X-Frame-Options",
+				"X-Content-Type-Options",
+				"Referrer-Policy",
+				"Permissions-Policy",
+				"Cross-Origin-Opener-Policy",
+				"Server",
+			}),
+		},
+		Analysis: AnalysisConfig{
+			NavOnlyLinkFraction: config.GetFloatEnv("NAV_ONLY_LINK_FRACTION", DefaultNavOnlyLinkFraction),
+			// Patterns are comma-separated in the environment, so they cannot contain commas themselves
+			LinkExcludePatterns: config.GetStringSliceEnv("LINK_EXCLUDE_PATTERNS", []string{
+				`(?i)/(log|sign)[-_]?(out|off)\b`,
+				`(?i)[/?&](unsubscribe|optout|opt-out)\b`,
+				`(?i)/cart/add\b`,
+			}),
+			VerifyImages:          config.GetBoolEnv("VERIFY_IMAGES", false),
+			VerifyScope:           config.GetEnv("LINK_VERIFY_SCOPE", string(models.LinkScopeAll)),
+			VerifyMaxRedirects:    config.GetIntEnv("LINK_VERIFY_MAX_REDIRECTS", 10),
+			VerifyHeaderProfile:   config.GetEnv("LINK_VERIFY_HEADER_PROFILE", string(models.HeaderProfileDefault)),
+			CheckAnchors:          config.GetBoolEnv("CHECK_BROKEN_ANCHORS", false),
+			ExplainLinks:          config.GetBoolEnv("EXPLAIN_LINK_CLASSIFICATION", false),
+			TrackerSignaturesFile: config.GetEnv("TRACKER_SIGNATURES_FILE", ""),
+			StripQueryParams:      config.GetStringSliceEnv("LINK_STRIP_QUERY_PARAMS", nil),
+			VerifyMaxJitter:       config.GetDurationEnv("LINK_VERIFY_MAX_JITTER", 0),
+			TimeBudget:            config.GetDurationEnv("ANALYSIS_TIME_BUDGET", 0),
+			FollowPagination:      config.GetBoolEnv("FOLLOW_PAGINATION", false),
+			PaginationMaxPages:    config.GetIntEnv("PAGINATION_MAX_PAGES", 5),
+			MaxStoredLinks:        config.GetIntEnv("RESULT_MAX_LINKS", 5000),
+		},
+		Events: EventsConfig{
+			SubTaskGranularity:   config.GetEnv("SUBTASK_EVENT_GRANULARITY", "full"),
+			ProgressInterval:     config.GetDurationEnv("SUBTASK_PROGRESS_INTERVAL", time.Second),
+			BroadcastLinks:       config.GetBoolEnv("JOB_UPDATE_INCLUDE_LINKS", true),
+			LoadInterval:         config.GetDurationEnv("ANALYZER_LOAD_INTERVAL", 10*time.Second),
+			HeartbeatInterval:    config.GetDurationEnv("JOB_HEARTBEAT_INTERVAL", 30*time.Second),
+			HeartbeatMaxDuration: config.GetDurationEnv("JOB_HEARTBEAT_MAX_DURATION", time.Hour),
+		},
+		HostRate: HostRateConfig{
+			RequestsPerSecond: config.GetFloatEnv("HOST_RATE_LIMIT_RPS", 5),
+			Burst:             config.GetIntEnv("HOST_RATE_LIMIT_BURST", 10),
+		},
+		DNS: DNSCacheConfig{
+			Enabled:     config.GetBoolEnv("DNS_CACHE_ENABLED", true),
+			TTL:         config.GetDurationEnv("DNS_CACHE_TTL", time.Minute),
+			NegativeTTL: config.GetDurationEnv("DNS_CACHE_NEGATIVE_TTL", 30*time.Second),
+		},
+		Self:     config.NewSelfProtectionConfig(),
 		Metrics:  config.NewMetricsConfig("9091"),
 		Tracing:  config.NewTracingConfig("analyzer"),
 		DynamoDB: config.NewDynamoDBConfig(),
-		NATS:     config.NewNATSConfig(),
+		NATS:     natsCfg,
+	}
+}
+
+// Redacted returns a copy of the configuration with secrets masked, safe to expose to operators
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.Admin = c.Admin.Redacted()
+	redacted.DynamoDB = c.DynamoDB.Redacted()
+	redacted.NATS = c.NATS.Redacted()
+	return &redacted
+}
+
+// Validate checks the configuration, returning a *config.ValidationError listing every problem found.
+// The link scope, exclusion patterns and subtask granularity are parsed, and so checked, as the analyzer is set up.
+func (c *Config) Validate() error {
+	v := config.NewValidator()
+	c.Service.Check(v)
+	c.HTTP.Check(v)
+	c.Metrics.Check(v)
+	c.Tracing.Check(v)
+	c.DynamoDB.Check(v)
+	c.NATS.Check(v)
+	c.Self.Check(v)
+
+	v.Check(c.Fetch.MaxRetries >= 0, "FETCH_MAX_RETRIES must not be negative, got %d", c.Fetch.MaxRetries)
+	v.Duration("FETCH_RETRY_BACKOFF", c.Fetch.RetryBackoff)
+	v.Duration("FETCH_MAX_RETRY_BACKOFF", c.Fetch.MaxRetryBackoff)
+	v.Check(c.Fetch.MaxRetryBackoff >= c.Fetch.RetryBackoff,
+		"FETCH_MAX_RETRY_BACKOFF must not be shorter than FETCH_RETRY_BACKOFF, got %s and %s", c.Fetch.MaxRetryBackoff, c.Fetch.RetryBackoff)
+	v.Check(c.Fetch.MaxContentBytes > 0, "FETCH_MAX_CONTENT_BYTES must be positive, got %d", c.Fetch.MaxContentBytes)
+	v.Check(c.Fetch.MaxRedirects >= 0, "FETCH_MAX_REDIRECTS must not be negative, got %d", c.Fetch.MaxRedirects)
+
+	v.Check(c.Analysis.NavOnlyLinkFraction > 0 && c.Analysis.NavOnlyLinkFraction <= 1,
+		"NAV_ONLY_LINK_FRACTION must be above 0 and at most 1, got %g", c.Analysis.NavOnlyLinkFraction)
+	v.Check(c.Analysis.VerifyMaxRedirects >= 0, "LINK_VERIFY_MAX_REDIRECTS must not be negative, got %d", c.Analysis.VerifyMaxRedirects)
+	v.OptionalDuration("LINK_VERIFY_MAX_JITTER", c.Analysis.VerifyMaxJitter)
+	v.OptionalDuration("ANALYSIS_TIME_BUDGET", c.Analysis.TimeBudget)
+	if c.Analysis.TimeBudget > 0 {
+		v.Check(c.Analysis.VerifyMaxJitter < c.Analysis.TimeBudget,
+			"LINK_VERIFY_MAX_JITTER must be shorter than ANALYSIS_TIME_BUDGET, got %s and %s", c.Analysis.VerifyMaxJitter, c.Analysis.TimeBudget)
+	}
+	if c.Analysis.FollowPagination {
+		v.Check(c.Analysis.PaginationMaxPages > 0, "PAGINATION_MAX_PAGES must be positive while FOLLOW_PAGINATION is set, got %d", c.Analysis.PaginationMaxPages)
+	}
+	v.Check(c.Analysis.MaxStoredLinks >= 0, "RESULT_MAX_LINKS must not be negative, got %d", c.Analysis.MaxStoredLinks)
+	for _, param := range c.Analysis.StripQueryParams {
+		v.Check(param != "*" && !strings.Contains(strings.TrimSuffix(param, "*"), "*"),
+			"LINK_STRIP_QUERY_PARAMS entries must be parameter names, with * only after a prefix, got %q", param)
 	}
+
+	v.Duration("SUBTASK_PROGRESS_INTERVAL", c.Events.ProgressInterval)
+	v.OptionalDuration("ANALYZER_LOAD_INTERVAL", c.Events.LoadInterval)
+	v.OptionalDuration("JOB_HEARTBEAT_INTERVAL", c.Events.HeartbeatInterval)
+	if c.Events.HeartbeatInterval > 0 {
+		v.Check(c.Events.HeartbeatMaxDuration >= c.Events.HeartbeatInterval,
+			"JOB_HEARTBEAT_MAX_DURATION must not be shorter than JOB_HEARTBEAT_INTERVAL, got %s and %s", c.Events.HeartbeatMaxDuration, c.Events.HeartbeatInterval)
+	}
+
+	v.Check(c.HostRate.RequestsPerSecond >= 0, "HOST_RATE_LIMIT_RPS must not be negative, got %g", c.HostRate.RequestsPerSecond)
+	if c.HostRate.RequestsPerSecond > 0 {
+		v.Check(c.HostRate.Burst > 0, "HOST_RATE_LIMIT_BURST must be positive while the host rate limit is on, got %d", c.HostRate.Burst)
+	}
+
+	if c.DNS.Enabled {
+		v.Duration("DNS_CACHE_TTL", c.DNS.TTL)
+		v.OptionalDuration("DNS_CACHE_NEGATIVE_TTL", c.DNS.NegativeTTL)
+	}
+
+	return v.Err()
 }
@@ -8,6 +8,8 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"shared/audit"
+	sharedconfig "shared/config"
 	"shared/log"
 	"shared/messagebus"
 	"shared/metrics"
@@ -25,6 +27,22 @@ func main() {
 
 	// Setup logging
 	logger := log.SetupFromEnv(cfg.Service.Name)
+
+	// The effective configuration is printed before it is checked, so the values behind the problems show
+	printConfig := sharedconfig.PrintRequested(os.Args[1:])
+	if printConfig {
+		if err := sharedconfig.Print(os.Stdout, cfg.Redacted()); err != nil {
+			logger.Error("Failed to print configuration", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		sharedconfig.LogProblems(logger, err)
+		os.Exit(1)
+	}
+	if printConfig {
+		return
+	}
 	logger.Info("Starting API service")
 
 	// Setup tracing
@@ -35,6 +53,14 @@ func main() {
 	}
 	defer otelShutdown(ctx)
 
+	// Audit records go to their own sink, apart from the service logs
+	auditLog, err := audit.Open(cfg.Audit.Path, cfg.Service.Name)
+	if err != nil {
+		logger.Error("Failed to open audit log", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer auditLog.Close()
+
 	// Initialize dependencies
 	deps, cleanup, err := initializeDependencies(cfg, logger)
 	if err != nil {
@@ -47,11 +73,52 @@ func main() {
 	apiService := api.NewAPI(
 		deps.JobRepo,
 		deps.TaskRepo,
+		deps.GroupRepo,
 		deps.MessageBus,
 		deps.Metrics,
 		logger,
+		auditLog,
+		api.WithRestoreWindow(cfg.Trash.RestoreWindow),
+		api.WithAdminToken(cfg.Admin.Token),
+		api.WithLoadStaleAfter(cfg.Load.StaleAfter),
+		api.WithMaxQueueDepth(cfg.Load.MaxQueueDepth),
+		api.WithSubmitConcurrency(cfg.Submit.Concurrency),
+		api.WithURLRateLimit(cfg.URLRate.Limit, cfg.URLRate.Window),
+		api.WithSelfProtection(cfg.Self),
+		api.WithMaxEventStreams(cfg.Events.MaxStreams),
+		api.WithHeartbeatStaleAfter(cfg.Reconcile.HeartbeatStaleAfter),
+		api.WithJobCache(cfg.JobCache.TTL, cfg.JobCache.Size),
+		api.WithBackupRepository(deps.BackupRepo),
 	)
 
+	// Track group completion from job updates
+	groupSub, err := apiService.WatchGroups()
+	if err != nil {
+		logger.Error("Failed to subscribe to job updates", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer groupSub.Unsubscribe()
+
+	// Keep the analyzer load for the start delay hints
+	loadSub, err := apiService.WatchAnalyzerLoad()
+	if err != nil {
+		logger.Error("Failed to subscribe to analyzer load", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer loadSub.Unsubscribe()
+
+	// Purge deleted jobs once their restore window has passed, a zero interval leaves them in the trash
+	sweepCtx, stopSweeper := context.WithCancel(ctx)
+	defer stopSweeper()
+	if cfg.Trash.PurgeInterval > 0 {
+		go apiService.RunPurgeSweeper(sweepCtx, cfg.Trash.PurgeInterval)
+	}
+
+	// Fail the running jobs whose analysis stopped sending heartbeats, a zero interval leaves them running
+	if cfg.Reconcile.Interval > 0 {
+		go apiService.RunStuckJobReconciler(sweepCtx, cfg.Reconcile.Interval)
+	}
+
 	// Start server in goroutine
 	go func() {
 		logger.Info("Starting API server", slog.String("addr", cfg.HTTP.Addr))
@@ -68,8 +135,18 @@ func main() {
 
 	logger.Info("Shutting down API service", slog.String("signal", sig.String()))
 
+	// Fail readiness first and keep serving until load balancers stop routing here, a second signal cuts the wait short
+	apiService.Drain()
+	if cfg.Shutdown.PreStopDelay > 0 {
+		logger.Info("Waiting for traffic to drain", slog.Duration("delay", cfg.Shutdown.PreStopDelay))
+		select {
+		case <-time.After(cfg.Shutdown.PreStopDelay):
+		case <-sigCh:
+		}
+	}
+
 	// Graceful shutdown
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
 	defer cancel()
 
 	if err := apiService.Shutdown(shutdownCtx); err != nil {
@@ -82,6 +159,8 @@ func main() {
 type dependencies struct {
 	JobRepo    *repository.JobRepository
 	TaskRepo   *repository.TaskRepository
+	GroupRepo  *repository.GroupRepository
+	BackupRepo *repository.BackupRepository
 	MessageBus *messagebus.MessageBus
 	Metrics    *metrics.APIMetrics
 	NC         *nats.Conn
@@ -120,6 +199,16 @@ func initializeDependencies(cfg *config.Config, logger *slog.Logger) (*dependenc
 		return nil, nil, err
 	}
 
+	groupRepo, err := repository.NewGroupRepository(cfg.DynamoDB, repository.WithGroupMetrics(m))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	backupRepo, err := repository.NewBackupRepository(cfg.DynamoDB, repository.WithBackupMetrics(m))
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Connect to NATS
 	nc, err := nats.Connect(cfg.NATS.URL)
 	if err != nil {
@@ -127,11 +216,13 @@ func initializeDependencies(cfg *config.Config, logger *slog.Logger) (*dependenc
 	}
 
 	// Create message bus
-	mb := messagebus.New(nc, m)
+	mb := messagebus.New(nc, m, messagebus.WithSlowHandlerThreshold(cfg.NATS.SlowHandlerThreshold))
 
 	deps := &dependencies{
 		JobRepo:    jobRepo,
 		TaskRepo:   taskRepo,
+		GroupRepo:  groupRepo,
+		BackupRepo: backupRepo,
 		MessageBus: mb,
 		Metrics:    m,
 		NC:         nc,
@@ -0,0 +1,103 @@
+// Command webctl inspects and repairs jobs straight from the job and task tables, so it works while the API is down.
+// It reads the DYNAMODB_*, NATS_URL and AUDIT_LOG_PATH settings the services read.
+//
+//	go run ./cmd/webctl jobs list --status running --older-than 1h
+//	go run ./cmd/webctl jobs fail 01H8X8Z8Z8Z8Z8Z8Z8Z8Z8Z8Z8 --reason "analyzer lost the job" --yes
+//	go run ./cmd/webctl db backup --label pre-deploy
+package main
+
+import (
+	"api/internal/webctl"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"shared/audit"
+	"shared/config"
+	"shared/messagebus"
+	"shared/repository"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/oklog/ulid/v2"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	// Service logs would mix with the command output, only problems are logged
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	args := os.Args[1:]
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprint(os.Stderr, webctl.Usage)
+		return 2
+	}
+
+	dynamoCfg := config.NewDynamoDBConfig()
+	jobs, err := repository.NewJobRepository(dynamoCfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to create job repository:", err)
+		return 1
+	}
+	tasks, err := repository.NewTaskRepository(dynamoCfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to create task repository:", err)
+		return 1
+	}
+	backups, err := repository.NewBackupRepository(dynamoCfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to create backup repository:", err)
+		return 1
+	}
+
+	// Audit records go to stderr unless a file is set, stdout carries the command output
+	auditLog := audit.NewLogger(os.Stderr, "webctl")
+	if path := config.NewAuditConfig().Path; path != "" {
+		if auditLog, err = audit.Open(path, "webctl"); err != nil {
+			fmt.Fprintln(os.Stderr, "failed to open audit log:", err)
+			return 1
+		}
+	}
+	defer auditLog.Close()
+
+	natsURL := config.NewNATSConfig().URL
+	var nc *nats.Conn
+	connect := func() (messagebus.MessageBusInterface, error) {
+		conn, err := nats.Connect(natsURL, nats.Timeout(5*time.Second))
+		if err != nil {
+			return nil, err
+		}
+		nc = conn
+		return messagebus.New(nc, nil), nil
+	}
+	defer func() {
+		if nc != nil {
+			_ = nc.Drain()
+		}
+	}()
+
+	cli := webctl.New(jobs, tasks,
+		webctl.WithBusConnector(connect),
+		webctl.WithAuditLogger(auditLog),
+		webctl.WithBackups(backups))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	// The changes made are attributed to this invocation in the audit records
+	ctx = audit.WithSource(ctx, audit.Source{RequestID: "webctl-" + ulid.Make().String()})
+
+	if err := cli.Run(ctx, args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, webctl.ErrUsage) {
+			fmt.Fprint(os.Stderr, "\n"+webctl.Usage)
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
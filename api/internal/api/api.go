@@ -6,12 +6,17 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"shared/audit"
+	sharedconfig "shared/config"
 	"shared/messagebus"
 	"shared/metrics"
 	"shared/middleware"
 	"shared/models"
 	"shared/repository"
 	"shared/tracing"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/yousuf64/shift"
@@ -19,80 +24,236 @@ import (
 
 // API handles the HTTP server and routes
 type API struct {
-	jobRepo  repository.JobRepositoryInterface
-	taskRepo repository.TaskRepositoryInterface
-	mb       messagebus.MessageBusInterface
-	metrics  *metrics.APIMetrics
-	log      *slog.Logger
-	srv      *http.Server
+	jobRepo   repository.JobRepositoryInterface
+	taskRepo  repository.TaskRepositoryInterface
+	groupRepo repository.GroupRepositoryInterface
+	mb        messagebus.MessageBusInterface
+	metrics   *metrics.APIMetrics
+	log       *slog.Logger
+	audit     *audit.Logger
+	srv       *http.Server
+
+	// restoreWindow is how long a deleted job can be restored before it is purged
+	restoreWindow time.Duration
+	// adminToken grants the admin-only views of the public endpoints
+	adminToken string
+	// self holds the platform's own hosts, which jobs are refused for
+	self sharedconfig.SelfProtectionConfig
+	// urlRate caps the jobs created per URL, nil when they are not capped
+	urlRate *urlRateLimiter
+
+	// loads holds the latest load report of each analyzer replica by instance ID, each trusted for loadStaleAfter
+	loadMu         sync.RWMutex
+	loads          map[string]loadReport
+	loadStaleAfter time.Duration
+	// maxQueueDepth is the analyzer queue depth at which new jobs are refused, 0 when they never are
+	maxQueueDepth int
+	// heartbeatStaleAfter is how long a running job may go without a sign of life before the reconciler fails it
+	heartbeatStaleAfter time.Duration
+	// submitConcurrency is how many jobs of a group are submitted at once
+	submitConcurrency int
+	// eventStreams is the number of job event streams being served, at most maxEventStreams
+	eventStreams    atomic.Int32
+	maxEventStreams int
+	// eventHeartbeatInterval is how often a quiet event stream writes a heartbeat line
+	eventHeartbeatInterval time.Duration
+	// jobCache serves recently read finished jobs, nil when they are always read from the database
+	jobCache *jobCache
+	// backupRepo backs up the tables for the admin endpoints, nil when they are disabled
+	backupRepo repository.BackupRepositoryInterface
+
+	// draining is set once shutdown starts, failing readiness while requests are still served
+	draining atomic.Bool
+	// cancelRequests cancels the context of the requests still running when the shutdown deadline passes
+	cancelRequests context.CancelFunc
+	// submissions tracks the jobs being submitted, so shutdown waits for them to be queued or rolled back.
+	// submissionsClosed is set once shutdown waits for them, after which no submission starts.
+	submissions       sync.WaitGroup
+	submissionsMu     sync.Mutex
+	submissionsClosed bool
+}
+
+// Option configures the API
+type Option func(*API)
+
+// WithRestoreWindow sets how long a deleted job can be restored before the sweeper purges it
+func WithRestoreWindow(window time.Duration) Option {
+	return func(a *API) {
+		a.restoreWindow = window
+	}
+}
+
+// WithAdminToken sets the token that unlocks admin-only views, such as deleted jobs in the job list
+func WithAdminToken(token string) Option {
+	return func(a *API) {
+		a.adminToken = token
+	}
 }
 
 // AnalyzeRequest is the request body for the analyze endpoint
 type AnalyzeRequest struct {
 	URL string `json:"url"`
+	// Tasks selects the analysis tasks to run, all of them when empty
+	Tasks []string `json:"tasks,omitempty"`
+	// VerifyScope limits link verification to internal or external links, the analyzer's default when empty
+	VerifyScope string `json:"verify_scope,omitempty"`
+	// HeaderProfile selects the request headers links are verified with, the analyzer's default when empty
+	HeaderProfile string `json:"header_profile,omitempty"`
 }
 
 // AnalyzeResponse is the response body for the analyze endpoint
 type AnalyzeResponse struct {
-	Job models.Job `json:"job"`
+	Job          models.Job        `json:"job"`
+	SkippedTasks []models.TaskType `json:"skipped_tasks,omitempty"`
+	// EstimatedStartDelaySeconds hints how long the job waits before the analysis starts, left out when unknown
+	EstimatedStartDelaySeconds *float64 `json:"estimated_start_delay_seconds,omitempty"`
 }
 
 // NewAPI creates a new API with all dependencies
 func NewAPI(
 	jobRepo *repository.JobRepository,
 	taskRepo *repository.TaskRepository,
+	groupRepo *repository.GroupRepository,
 	mb *messagebus.MessageBus,
 	metrics *metrics.APIMetrics,
 	log *slog.Logger,
+	auditLog *audit.Logger,
+	opts ...Option,
 ) *API {
-	return &API{
-		jobRepo:  jobRepo,
-		taskRepo: taskRepo,
-		mb:       mb,
-		metrics:  metrics,
-		log:      log,
+	a := &API{
+		jobRepo:       jobRepo,
+		taskRepo:      taskRepo,
+		groupRepo:     groupRepo,
+		mb:            mb,
+		metrics:       metrics,
+		log:           log,
+		audit:         auditLog,
+		restoreWindow: defaultRestoreWindow,
+
+		loads:               make(map[string]loadReport),
+		loadStaleAfter:      defaultLoadStaleAfter,
+		heartbeatStaleAfter: defaultHeartbeatStaleAfter,
+		submitConcurrency:   defaultSubmitConcurrency,
+
+		maxEventStreams:        defaultMaxEventStreams,
+		eventHeartbeatInterval: eventHeartbeatInterval,
+	}
+
+	for _, opt := range opts {
+		opt(a)
 	}
+
+	return a
 }
 
 // Start starts the HTTP server
 func (a *API) Start(ctx context.Context, cfg *config.Config) error {
+	a.srv = a.newServer(ctx, cfg)
+	a.log.Info("API server starting", slog.String("addr", a.srv.Addr))
+	return a.srv.ListenAndServe()
+}
+
+// newServer builds the HTTP server, the requests it serves are cancelled by Shutdown once its deadline passes
+func (a *API) newServer(ctx context.Context, cfg *config.Config) *http.Server {
+	httpCfg := config.Load().HTTP
+	timeoutCfg := config.TimeoutConfig{SlowRequestThreshold: 2 * time.Second}
+	if cfg != nil {
+		httpCfg = cfg.HTTP
+		timeoutCfg = cfg.Timeouts
+	}
+
+	router := a.newRouter(cfg, httpCfg, timeoutCfg)
+
+	addr := ":8080"
+	if httpCfg.Addr != "" {
+		addr = httpCfg.Addr
+	}
+
+	ctx, a.cancelRequests = context.WithCancel(ctx)
+	return &http.Server{
+		Addr:         addr,
+		Handler:      router.Serve(),
+		BaseContext:  func(_ net.Listener) context.Context { return ctx },
+		ReadTimeout:  httpCfg.ReadTimeout,
+		WriteTimeout: httpCfg.WriteTimeout,
+		IdleTimeout:  httpCfg.IdleTimeout,
+	}
+}
+
+// newRouter builds the router with the middleware chain and all routes registered under the base path
+func (a *API) newRouter(cfg *config.Config, httpCfg sharedconfig.HTTPServerConfig, timeoutCfg config.TimeoutConfig) *shift.Router {
+	basePath := normalizeBasePath(httpCfg.BasePath)
+
 	router := shift.New()
 	router.Use(tracing.OtelMiddleware)
+	router.Use(middleware.RequestIDMiddleware)
+	router.Use(auditSourceMiddleware)
 	router.Use(middleware.CORSMiddleware)
 	if a.metrics != nil {
 		router.Use(a.metrics.HTTPMiddleware)
 	}
 	router.Use(middleware.ErrorMiddleware(a.log))
+	router.Use(a.slowRequestMiddleware(timeoutCfg.SlowRequestThreshold))
+	router.Use(routeTimeoutMiddleware(prefixRouteKeys(timeoutCfg.Routes, basePath), httpCfg.WriteTimeout))
 
 	// Register routes
-	router.OPTIONS("/*wildcard", middleware.OptionsHandler)
-	router.POST("/analyze", a.handleAnalyze)
-	router.GET("/jobs", a.handleGetJobs)
-	router.GET("/jobs/:job_id/tasks", a.handleGetTasksByJobID)
-
-	addr := ":8080"
-	if cfg != nil && cfg.HTTP.Addr != "" {
-		addr = cfg.HTTP.Addr
+	router.OPTIONS(basePath+"/*wildcard", middleware.OptionsHandler)
+	router.GET(basePath+"/readyz", a.handleReady)
+	router.POST(basePath+"/analyze", a.handleAnalyze)
+	router.GET(basePath+"/jobs", a.handleGetJobs)
+	router.GET(basePath+"/jobs/:job_id", a.handleGetJob)
+	router.GET(basePath+"/jobs/:job_id/tasks", a.handleGetTasksByJobID)
+	router.GET(basePath+"/jobs/:job_id/events", a.handleJobEvents)
+	router.GET(basePath+"/jobs/:job_id/findings", a.handleGetFindings)
+	router.GET(basePath+"/findings", a.handleGetFindingCatalog)
+	router.POST(basePath+"/tasks/batch", a.handleGetTasksBatch)
+	router.GET(basePath+"/jobs/:job_id/export", a.handleExportJob)
+	router.GET(basePath+"/jobs/:job_id/graph", a.handleGetGraph)
+	router.POST(basePath+"/jobs/:job_id/cancel", a.handleCancelJob)
+	router.DELETE(basePath+"/jobs/:job_id", a.handleDeleteJob)
+	router.POST(basePath+"/jobs/:job_id/restore", a.handleRestoreJob)
+	router.POST(basePath+"/analyze/group", a.handleAnalyzeGroup)
+	router.GET(basePath+"/groups/:group_id", a.handleGetGroup)
+	router.GET(basePath+"/system/load", a.handleGetSystemLoad)
+	if cfg != nil {
+		adminAuth := middleware.AdminAuthMiddleware(cfg.Admin.Token)
+		router.GET(basePath+"/debug/config", adminAuth(middleware.ConfigHandler(cfg.Redacted())))
+		router.POST(basePath+"/admin/cancel-all", adminAuth(a.handleCancelAll))
+		router.GET(basePath+"/admin/broken-links", adminAuth(a.handleGetBrokenLinks))
+		router.POST(basePath+"/admin/loglevel", adminAuth(middleware.LogLevelHandler(a.log)))
+		if a.backupRepo != nil {
+			router.POST(basePath+"/admin/backups", adminAuth(a.handleCreateBackup))
+			router.GET(basePath+"/admin/backups", adminAuth(a.handleListBackups))
+		}
 	}
 
-	a.srv = &http.Server{
-		Addr:         addr,
-		Handler:      router.Serve(),
-		BaseContext:  func(_ net.Listener) context.Context { return ctx },
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
-	}
+	return router
+}
 
-	a.log.Info("API server starting", slog.String("addr", addr))
-	return a.srv.ListenAndServe()
+// normalizeBasePath returns the base path with a leading slash and no trailing slash, empty for the root
+func normalizeBasePath(basePath string) string {
+	basePath = strings.Trim(strings.TrimSpace(basePath), "/")
+	if basePath == "" {
+		return ""
+	}
+	return "/" + basePath
 }
 
-// Shutdown gracefully shuts down the server
-func (a *API) Shutdown(ctx context.Context) error {
-	a.log.Info("Shutting down API server")
-	if a.srv != nil {
-		return a.srv.Shutdown(ctx)
+// prefixRouteKeys moves the per-route settings under the base path,
+// so they keep being configured by the unprefixed route templates
+func prefixRouteKeys(routes map[string]time.Duration, basePath string) map[string]time.Duration {
+	if basePath == "" {
+		return routes
+	}
+
+	prefixed := make(map[string]time.Duration, len(routes))
+	for key, timeout := range routes {
+		method, path, ok := strings.Cut(key, " ")
+		if !ok {
+			prefixed[key] = timeout
+			continue
+		}
+		prefixed[method+" "+basePath+path] = timeout
 	}
-	return nil
+	return prefixed
 }
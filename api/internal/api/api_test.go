@@ -0,0 +1,143 @@
+package api
+
+import (
+	"api/internal/config"
+	"net/http"
+	"net/http/httptest"
+	sharedconfig "shared/config"
+	"shared/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestNormalizeBasePath(t *testing.T) {
+	testCases := map[string]string{
+		"":          "",
+		"/":         "",
+		"api":       "/api",
+		"/api":      "/api",
+		"/api/":     "/api",
+		" /api/v1 ": "/api/v1",
+	}
+
+	for input, expected := range testCases {
+		assert.Equal(t, expected, normalizeBasePath(input), "input %q", input)
+	}
+}
+
+func TestPrefixRouteKeys(t *testing.T) {
+	routes := map[string]time.Duration{
+		"GET /jobs/:job_id/export": time.Minute,
+		"POST /analyze":            5 * time.Second,
+	}
+
+	assert.Equal(t, routes, prefixRouteKeys(routes, ""))
+	assert.Equal(t, map[string]time.Duration{
+		"GET /api/jobs/:job_id/export": time.Minute,
+		"POST /api/analyze":            5 * time.Second,
+	}, prefixRouteKeys(routes, "/api"))
+}
+
+func TestAPI_NewRouter_BasePath(t *testing.T) {
+	testCases := []struct {
+		name           string
+		basePath       string
+		method         string
+		path           string
+		expectJobs     bool
+		expectedStatus int
+	}{
+		{
+			name:           "NoBasePath",
+			method:         http.MethodGet,
+			path:           "/jobs",
+			expectJobs:     true,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "PrefixedRoute",
+			basePath:       "/api/",
+			method:         http.MethodGet,
+			path:           "/api/jobs",
+			expectJobs:     true,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "UnprefixedRouteNotFound",
+			basePath:       "/api",
+			method:         http.MethodGet,
+			path:           "/jobs",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "PrefixedPreflight",
+			basePath:       "/api",
+			method:         http.MethodOptions,
+			path:           "/api/jobs/job-1",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, _, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+
+			if tc.expectJobs {
+				mockJobRepo.EXPECT().GetAllJobs(gomock.Any()).Return([]*models.Job{}, nil)
+			}
+
+			httpCfg := sharedconfig.HTTPServerConfig{BasePath: tc.basePath, WriteTimeout: 15 * time.Second}
+			router := api.newRouter(nil, httpCfg, config.TimeoutConfig{SlowRequestThreshold: time.Second})
+
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			rr := httptest.NewRecorder()
+			router.Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func TestAPI_PreflightHeaders(t *testing.T) {
+	testCases := []struct {
+		name            string
+		requestHeaders  string
+		expectedAllowed string
+	}{
+		{
+			name:            "Requested",
+			requestHeaders:  "Idempotency-Key, X-Correlation-Id",
+			expectedAllowed: "Idempotency-Key, X-Correlation-Id",
+		},
+		{
+			name:            "Default",
+			expectedAllowed: "Content-Type, Authorization, X-Request-ID",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, _, _, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+
+			router := api.newRouter(nil, sharedconfig.HTTPServerConfig{WriteTimeout: 15 * time.Second}, config.TimeoutConfig{SlowRequestThreshold: time.Second})
+
+			req := httptest.NewRequest(http.MethodOptions, "/analyze", nil)
+			req.Header.Set("Origin", "https://app.example.com")
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			if tc.requestHeaders != "" {
+				req.Header.Set("Access-Control-Request-Headers", tc.requestHeaders)
+			}
+			rr := httptest.NewRecorder()
+			router.Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tc.expectedAllowed, rr.Header().Get("Access-Control-Allow-Headers"))
+			assert.Contains(t, rr.Header().Values("Vary"), "Access-Control-Request-Headers")
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"shared/middleware"
+	"shared/repository"
+
+	"github.com/yousuf64/shift"
+)
+
+// CreateBackupRequest is the request body for the backup endpoint
+type CreateBackupRequest struct {
+	// Label is included in the backup names, such as pre-deploy
+	Label string `json:"label"`
+	// Table is the table backed up, all tables when empty
+	Table string `json:"table,omitempty"`
+}
+
+// BackupsResponse is the response body for the backup endpoints
+type BackupsResponse struct {
+	Backups []repository.Backup `json:"backups"`
+}
+
+// WithBackupRepository enables the admin endpoints that back up the tables and list their backups
+func WithBackupRepository(repo repository.BackupRepositoryInterface) Option {
+	return func(a *API) {
+		a.backupRepo = repo
+	}
+}
+
+// handleCreateBackup handles the backup endpoint, starting on-demand backups of the tables before a risky deploy.
+// DynamoDB finishes the backups in the background, their status shows in the backup list.
+func (a *API) handleCreateBackup(w http.ResponseWriter, r *http.Request, _ shift.Route) error {
+	ctx := r.Context()
+
+	var req CreateBackupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return errors.Join(err, errors.New("failed to decode request"))
+	}
+
+	tables := repository.TableNames()
+	if req.Table != "" {
+		tables = []string{req.Table}
+	}
+
+	backups := make([]repository.Backup, 0, len(tables))
+	for _, table := range tables {
+		backup, err := a.backupRepo.CreateBackup(ctx, table, req.Label)
+		if err != nil {
+			if len(backups) > 0 {
+				a.log.Warn("Backed up only some of the tables", slog.Int("backedUp", len(backups)), slog.String("failedTable", table))
+			}
+			return a.writeBackupError(w, r, err)
+		}
+		backups = append(backups, *backup)
+	}
+
+	a.log.Info("Started table backups", slog.String("label", req.Label), slog.Int("count", len(backups)))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	return json.NewEncoder(w).Encode(BackupsResponse{Backups: backups})
+}
+
+// handleListBackups handles the backup list endpoint, listing the on-demand backups of the tables newest first.
+// The table query parameter narrows the list to one table.
+func (a *API) handleListBackups(w http.ResponseWriter, r *http.Request, _ shift.Route) error {
+	backups, err := a.backupRepo.ListBackups(r.Context(), r.URL.Query().Get("table"))
+	if err != nil {
+		return a.writeBackupError(w, r, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(BackupsResponse{Backups: backups})
+}
+
+// writeBackupError answers a failed backup request, returning the errors that are not the client's
+func (a *API) writeBackupError(w http.ResponseWriter, r *http.Request, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrUnknownTable), errors.Is(err, repository.ErrInvalidBackupLabel):
+		middleware.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return nil
+	case errors.Is(err, repository.ErrBackupsUnsupported):
+		middleware.WriteError(w, r, http.StatusNotImplemented, err.Error())
+		return nil
+	default:
+		return errors.Join(err, errors.New("failed to back up tables"))
+	}
+}
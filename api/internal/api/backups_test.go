@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"shared/mocks"
+	"shared/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAPI_HandleCreateBackup(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	backup := func(table string) *repository.Backup {
+		return &repository.Backup{Table: table, Name: table + "-pre-deploy-20240501T120000Z", Status: "CREATING", CreatedAt: created}
+	}
+
+	testCases := []struct {
+		name           string
+		body           CreateBackupRequest
+		setupMocks     func(backups *mocks.MockBackupRepositoryInterface)
+		expectedStatus int
+		expectedTables []string
+	}{
+		{
+			name: "AllTables",
+			body: CreateBackupRequest{Label: "pre-deploy"},
+			setupMocks: func(backups *mocks.MockBackupRepositoryInterface) {
+				for _, table := range repository.TableNames() {
+					backups.EXPECT().CreateBackup(gomock.Any(), table, "pre-deploy").Return(backup(table), nil)
+				}
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedTables: repository.TableNames(),
+		},
+		{
+			name: "OneTable",
+			body: CreateBackupRequest{Label: "pre-deploy", Table: repository.JobsTableName},
+			setupMocks: func(backups *mocks.MockBackupRepositoryInterface) {
+				backups.EXPECT().CreateBackup(gomock.Any(), repository.JobsTableName, "pre-deploy").Return(backup(repository.JobsTableName), nil)
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedTables: []string{repository.JobsTableName},
+		},
+		{
+			name: "InvalidLabel",
+			body: CreateBackupRequest{Label: "before sharding", Table: repository.JobsTableName},
+			setupMocks: func(backups *mocks.MockBackupRepositoryInterface) {
+				backups.EXPECT().CreateBackup(gomock.Any(), repository.JobsTableName, "before sharding").Return(nil, repository.ErrInvalidBackupLabel)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Unsupported",
+			body: CreateBackupRequest{Label: "pre-deploy", Table: repository.JobsTableName},
+			setupMocks: func(backups *mocks.MockBackupRepositoryInterface) {
+				backups.EXPECT().CreateBackup(gomock.Any(), repository.JobsTableName, "pre-deploy").Return(nil, repository.ErrBackupsUnsupported)
+			},
+			expectedStatus: http.StatusNotImplemented,
+		},
+		{
+			name: "BackupError",
+			body: CreateBackupRequest{Label: "pre-deploy"},
+			setupMocks: func(backups *mocks.MockBackupRepositoryInterface) {
+				backups.EXPECT().CreateBackup(gomock.Any(), repository.JobsTableName, "pre-deploy").Return(backup(repository.JobsTableName), nil)
+				backups.EXPECT().CreateBackup(gomock.Any(), repository.TasksTableName, "pre-deploy").Return(nil, errors.New("LimitExceededException"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, _, _, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+			backups := mocks.NewMockBackupRepositoryInterface(ctrl)
+			tc.setupMocks(backups)
+			WithBackupRepository(backups)(api)
+
+			req, err := makeRequest("POST", "/admin/backups", tc.body)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("POST", "/admin/backups", api.handleCreateBackup)
+			router.Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedStatus != http.StatusAccepted {
+				return
+			}
+
+			var resp BackupsResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			var tables []string
+			for _, backup := range resp.Backups {
+				tables = append(tables, backup.Table)
+			}
+			assert.Equal(t, tc.expectedTables, tables)
+		})
+	}
+}
+
+func TestAPI_HandleListBackups(t *testing.T) {
+	api, _, _, _, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+	backups := mocks.NewMockBackupRepositoryInterface(ctrl)
+	backups.EXPECT().ListBackups(gomock.Any(), repository.TasksTableName).Return([]repository.Backup{
+		{Table: repository.TasksTableName, Name: "tasks-backup", Status: "AVAILABLE"},
+	}, nil)
+	backups.EXPECT().ListBackups(gomock.Any(), "users").Return(nil, repository.ErrUnknownTable)
+	WithBackupRepository(backups)(api)
+	router := setupRouter("GET", "/admin/backups", api.handleListBackups)
+
+	req, err := makeRequest("GET", "/admin/backups?table="+repository.TasksTableName, nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.Serve().ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp BackupsResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp.Backups, 1)
+	assert.Equal(t, "tasks-backup", resp.Backups[0].Name)
+
+	req, err = makeRequest("GET", "/admin/backups?table=users", nil)
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	router.Serve().ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
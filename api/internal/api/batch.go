@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"shared/middleware"
+	"shared/models"
+	"strings"
+
+	"github.com/yousuf64/shift"
+)
+
+// maxBatchJobIDs bounds the jobs whose tasks are read by a single batch request
+const maxBatchJobIDs = 100
+
+// TasksBatchRequest is the request body for the batch tasks endpoint
+type TasksBatchRequest struct {
+	JobIDs []string `json:"job_ids"`
+}
+
+// handleGetTasksBatch handles the batch tasks endpoint, returning the tasks of several jobs keyed by job ID.
+// Jobs that do not exist or are deleted are left out rather than failing the request,
+// as a list view may well hold a job someone else just deleted.
+func (a *API) handleGetTasksBatch(w http.ResponseWriter, r *http.Request, _ shift.Route) error {
+	ctx := r.Context()
+
+	var req TasksBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return errors.Join(err, errors.New("failed to decode request"))
+	}
+	if err := validateTasksBatchRequest(req); err != nil {
+		middleware.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return nil
+	}
+
+	jobs, err := a.jobRepo.GetJobsByIDs(ctx, req.JobIDs)
+	if err != nil {
+		return errors.Join(err, errors.New("failed to get jobs"))
+	}
+
+	ids := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		if !job.IsDeleted() {
+			ids = append(ids, job.ID)
+		}
+	}
+
+	tasks := make(map[string][]models.Task)
+	if len(ids) > 0 {
+		tasks, err = a.taskRepo.GetTasksByJobIds(ctx, ids)
+		if err != nil {
+			return errors.Join(err, errors.New("failed to get tasks"))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(tasks)
+}
+
+// validateTasksBatchRequest checks the batch holds between one and maxBatchJobIDs job IDs, none of them empty
+func validateTasksBatchRequest(req TasksBatchRequest) error {
+	if len(req.JobIDs) == 0 || len(req.JobIDs) > maxBatchJobIDs {
+		return fmt.Errorf("job_ids must contain between 1 and %d ids", maxBatchJobIDs)
+	}
+	for _, id := range req.JobIDs {
+		if strings.TrimSpace(id) == "" {
+			return errors.New("job_ids must not contain empty ids")
+		}
+	}
+	return nil
+}
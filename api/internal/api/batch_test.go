@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"shared/mocks"
+	"shared/models"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAPI_HandleGetTasksBatch_TableDriven(t *testing.T) {
+	tooMany := make([]string, maxBatchJobIDs+1)
+	for i := range tooMany {
+		tooMany[i] = "job-" + strconv.Itoa(i)
+	}
+	deletedAt := time.Now().UTC()
+	tasks := map[string][]models.Task{
+		"job-1": {{JobID: "job-1", Type: models.TaskTypeExtracting, Status: models.TaskStatusCompleted}},
+	}
+
+	testCases := []struct {
+		name           string
+		body           any
+		setupMocks     func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface)
+		expectedStatus int
+		expectedTasks  map[string][]models.Task
+	}{
+		{
+			name: "Success",
+			body: TasksBatchRequest{JobIDs: []string{"job-1", "job-2", "missing"}},
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJobsByIDs(gomock.Any(), []string{"job-1", "job-2", "missing"}).Return([]*models.Job{
+					{ID: "job-1"},
+					{ID: "job-2", DeletedAt: &deletedAt},
+				}, nil)
+				// Deleted jobs are left out like missing ones
+				taskRepo.EXPECT().GetTasksByJobIds(gomock.Any(), []string{"job-1"}).Return(tasks, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedTasks:  tasks,
+		},
+		{
+			name: "NoJobFound",
+			body: TasksBatchRequest{JobIDs: []string{"missing"}},
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, _ *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJobsByIDs(gomock.Any(), gomock.Any()).Return(nil, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedTasks:  map[string][]models.Task{},
+		},
+		{
+			name:           "Empty",
+			body:           TasksBatchRequest{},
+			setupMocks:     func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "TooMany",
+			body:           TasksBatchRequest{JobIDs: tooMany},
+			setupMocks:     func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "EmptyID",
+			body:           TasksBatchRequest{JobIDs: []string{"job-1", " "}},
+			setupMocks:     func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "TaskRepositoryError",
+			body: TasksBatchRequest{JobIDs: []string{"job-1"}},
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJobsByIDs(gomock.Any(), gomock.Any()).Return([]*models.Job{{ID: "job-1"}}, nil)
+				taskRepo.EXPECT().GetTasksByJobIds(gomock.Any(), gomock.Any()).Return(nil, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, mockTaskRepo, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+			tc.setupMocks(mockJobRepo, mockTaskRepo)
+
+			req, err := makeRequest(http.MethodPost, "/tasks/batch", tc.body)
+			require.NoError(t, err)
+			rr := httptest.NewRecorder()
+			setupRouter(http.MethodPost, "/tasks/batch", api.handleGetTasksBatch).Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedTasks != nil {
+				var got map[string][]models.Task
+				require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+				assert.Equal(t, tc.expectedTasks, got)
+			}
+		})
+	}
+}
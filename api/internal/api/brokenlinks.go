@@ -0,0 +1,190 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"shared/middleware"
+	"shared/models"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yousuf64/shift"
+)
+
+const (
+	// brokenLinksPageSize is the number of completed jobs read per page of the broken links report by default
+	brokenLinksPageSize = 100
+	// brokenLinksMaxPageSize caps the jobs read per page, as the tasks of every matching job are read too
+	brokenLinksMaxPageSize = 500
+)
+
+// completedJobStatuses are the statuses of the jobs whose links the broken links report covers
+var completedJobStatuses = []models.JobStatus{models.JobStatusCompleted}
+
+// BrokenLinksResponse is the response body for the broken links report
+type BrokenLinksResponse struct {
+	Links []BrokenLink `json:"links"`
+	// JobsScanned is the number of jobs of the page whose links were read, after filtering
+	JobsScanned int `json:"jobs_scanned"`
+	// NextCursor reads the next page, it is left out on the last one
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// BrokenLink is a link found inaccessible by one or more jobs
+type BrokenLink struct {
+	URL string `json:"url"`
+	// StatusCode and Description are the outcome of the latest verification of the link
+	StatusCode  int    `json:"status_code,omitempty"`
+	Description string `json:"description"`
+	// Pages are the analyzed pages linking to it and JobIDs the jobs that found it, newest first
+	Pages  []string `json:"pages"`
+	JobIDs []string `json:"job_ids"`
+	// LastSeenAt is when the latest job that found it completed
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// brokenLinksFilter selects the jobs covered by the broken links report
+type brokenLinksFilter struct {
+	host  string
+	since time.Time
+}
+
+// matches reports whether the job is covered by the report
+func (f brokenLinksFilter) matches(job *models.Job) bool {
+	if job.IsDeleted() || job.Result == nil {
+		return false
+	}
+	if !f.since.IsZero() && job.CreatedAt.Before(f.since) {
+		return false
+	}
+	if f.host == "" {
+		return true
+	}
+	page, err := url.Parse(job.URL)
+	return err == nil && strings.EqualFold(page.Hostname(), f.host)
+}
+
+// handleGetBrokenLinks handles the broken links report endpoint.
+// It reads one page of completed jobs, newest first, and lists the links their verification found inaccessible,
+// each once. Links are only merged within a page, clients merge the pages by URL.
+func (a *API) handleGetBrokenLinks(w http.ResponseWriter, r *http.Request, _ shift.Route) error {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	limit := brokenLinksPageSize
+	if raw := query.Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > brokenLinksMaxPageSize {
+			middleware.WriteError(w, r, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(brokenLinksMaxPageSize))
+			return nil
+		}
+		limit = parsed
+	}
+
+	filter := brokenLinksFilter{host: strings.TrimSpace(query.Get("host"))}
+	if raw := query.Get("since"); raw != "" {
+		since, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			middleware.WriteError(w, r, http.StatusBadRequest, "since must be an RFC 3339 time such as 2024-05-01T00:00:00Z")
+			return nil
+		}
+		filter.since = since
+	}
+
+	jobs, next, err := a.jobRepo.GetJobsByStatus(ctx, completedJobStatuses, query.Get("cursor"), int64(limit))
+	if err != nil {
+		return errors.Join(err, errors.New("failed to get completed jobs"))
+	}
+
+	// Jobs come newest first, so none of the later pages can be recent enough once one is older than since
+	if !filter.since.IsZero() && len(jobs) > 0 && jobs[len(jobs)-1].CreatedAt.Before(filter.since) {
+		next = ""
+	}
+
+	var matched []*models.Job
+	var ids []string
+	for _, job := range jobs {
+		if filter.matches(job) {
+			matched = append(matched, job)
+			ids = append(ids, job.ID)
+		}
+	}
+
+	links := []BrokenLink{}
+	if len(matched) > 0 {
+		tasks, err := a.taskRepo.GetTasksByJobIds(ctx, ids)
+		if err != nil {
+			return errors.Join(err, errors.New("failed to get tasks"))
+		}
+		links = collectBrokenLinks(matched, tasks)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(BrokenLinksResponse{Links: links, JobsScanned: len(matched), NextCursor: next})
+}
+
+// collectBrokenLinks lists the links the jobs' verification found inaccessible, merging the ones found by several
+// jobs. Jobs are expected newest first, links come in the order they were first found.
+func collectBrokenLinks(jobs []*models.Job, tasks map[string][]models.Task) []BrokenLink {
+	links := []BrokenLink{}
+	positions := make(map[string]int)
+
+	for _, job := range jobs {
+		seenAt := job.UpdatedAt
+		if job.CompletedAt != nil {
+			seenAt = *job.CompletedAt
+		}
+
+		for _, subTask := range brokenSubTasks(tasks[job.ID]) {
+			i, ok := positions[subTask.URL]
+			if !ok {
+				i = len(links)
+				positions[subTask.URL] = i
+				links = append(links, BrokenLink{
+					URL:         subTask.URL,
+					StatusCode:  parseStatusCode(subTask.Description),
+					Description: subTask.Description,
+					LastSeenAt:  seenAt,
+				})
+			}
+
+			link := &links[i]
+			if !slices.Contains(link.Pages, job.URL) {
+				link.Pages = append(link.Pages, job.URL)
+			}
+			if !slices.Contains(link.JobIDs, job.ID) {
+				link.JobIDs = append(link.JobIDs, job.ID)
+			}
+		}
+	}
+	return links
+}
+
+// brokenSubTasks returns the link verification subtasks that failed, in the order of the links on the page
+func brokenSubTasks(tasks []models.Task) []models.SubTask {
+	var broken []models.SubTask
+	for _, task := range tasks {
+		if task.Type != models.TaskTypeVerifyingLinks {
+			continue
+		}
+
+		// Link subtasks are keyed by their 1-based position, image subtasks are left out
+		keys := make([]int, 0, len(task.SubTasks))
+		for key, subTask := range task.SubTasks {
+			position, err := strconv.Atoi(key)
+			if err == nil && subTask.Type == models.SubTaskTypeValidatingLink && subTask.Status == models.TaskStatusFailed {
+				keys = append(keys, position)
+			}
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			broken = append(broken, task.SubTasks[strconv.Itoa(key)])
+		}
+	}
+	return broken
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"shared/mocks"
+	"shared/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// verifyingTask returns a link verification task with the given subtasks
+func verifyingTask(jobID string, subTasks map[string]models.SubTask) models.Task {
+	return models.Task{JobID: jobID, Type: models.TaskTypeVerifyingLinks, Status: models.TaskStatusCompleted, SubTasks: subTasks}
+}
+
+func TestAPI_HandleGetBrokenLinks_TableDriven(t *testing.T) {
+	now := time.Date(2024, 5, 8, 12, 0, 0, 0, time.UTC)
+	lastWeek := now.Add(-7 * 24 * time.Hour)
+	completedAt := now.Add(-time.Hour)
+	deletedAt := now
+
+	jobs := []*models.Job{
+		{ID: "job-4", URL: "https://example.com/blog", CreatedAt: now.Add(-2 * time.Hour), CompletedAt: &completedAt, Result: &models.AnalyzeResult{}},
+		{ID: "job-3", URL: "https://WWW.other.org/", CreatedAt: now.Add(-3 * time.Hour), Result: &models.AnalyzeResult{}},
+		{ID: "job-2", URL: "https://example.com/", CreatedAt: now.Add(-4 * time.Hour), Result: &models.AnalyzeResult{}},
+		{ID: "job-deleted", URL: "https://example.com/old", CreatedAt: now.Add(-5 * time.Hour), Result: &models.AnalyzeResult{}, DeletedAt: &deletedAt},
+	}
+	tasks := map[string][]models.Task{
+		"job-4": {verifyingTask("job-4", map[string]models.SubTask{
+			"10":      {Type: models.SubTaskTypeValidatingLink, Status: models.TaskStatusFailed, URL: "https://example.com/gone", Description: "HTTP 404: Not Found"},
+			"2":       {Type: models.SubTaskTypeValidatingLink, Status: models.TaskStatusFailed, URL: "https://partner.net/", Description: "HTTP 503: Service Unavailable"},
+			"3":       {Type: models.SubTaskTypeValidatingLink, Status: models.TaskStatusCompleted, URL: "https://example.com/ok", Description: "HTTP 200: OK"},
+			"image-1": {Type: models.SubTaskTypeValidatingImage, Status: models.TaskStatusFailed, URL: "https://example.com/logo.png"},
+		})},
+		"job-2": {
+			{JobID: "job-2", Type: models.TaskTypeExtracting, Status: models.TaskStatusCompleted},
+			verifyingTask("job-2", map[string]models.SubTask{
+				"1": {Type: models.SubTaskTypeValidatingLink, Status: models.TaskStatusFailed, URL: "https://example.com/gone", Description: "HTTP 410: Gone"},
+				"4": {Type: models.SubTaskTypeValidatingLink, Status: models.TaskStatusSkipped, URL: "https://example.com/logout", Description: "Excluded by pattern"},
+			}),
+		},
+	}
+
+	testCases := []struct {
+		name            string
+		query           string
+		setupMocks      func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface)
+		expectedStatus  int
+		expectedLinks   []BrokenLink
+		expectedScanned int
+		expectedCursor  string
+	}{
+		{
+			name:  "MergesLinksAcrossJobs",
+			query: "?host=example.com",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJobsByStatus(gomock.Any(), completedJobStatuses, "", int64(brokenLinksPageSize)).Return(jobs, "job-deleted", nil)
+				taskRepo.EXPECT().GetTasksByJobIds(gomock.Any(), []string{"job-4", "job-2"}).Return(tasks, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedLinks: []BrokenLink{
+				{
+					URL:         "https://partner.net/",
+					StatusCode:  503,
+					Description: "HTTP 503: Service Unavailable",
+					Pages:       []string{"https://example.com/blog"},
+					JobIDs:      []string{"job-4"},
+					LastSeenAt:  completedAt,
+				},
+				{
+					URL:         "https://example.com/gone",
+					StatusCode:  404,
+					Description: "HTTP 404: Not Found",
+					Pages:       []string{"https://example.com/blog", "https://example.com/"},
+					JobIDs:      []string{"job-4", "job-2"},
+					LastSeenAt:  completedAt,
+				},
+			},
+			expectedScanned: 2,
+			expectedCursor:  "job-deleted",
+		},
+		{
+			name:  "HostIgnoresCase",
+			query: "?host=www.other.org&limit=3&cursor=job-5",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJobsByStatus(gomock.Any(), completedJobStatuses, "job-5", int64(3)).Return(jobs[:3], "job-2", nil)
+				taskRepo.EXPECT().GetTasksByJobIds(gomock.Any(), []string{"job-3"}).Return(map[string][]models.Task{}, nil)
+			},
+			expectedStatus:  http.StatusOK,
+			expectedLinks:   []BrokenLink{},
+			expectedScanned: 1,
+			expectedCursor:  "job-2",
+		},
+		{
+			name:  "SinceStopsPaging",
+			query: "?since=" + now.Add(-150*time.Minute).Format(time.RFC3339),
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJobsByStatus(gomock.Any(), completedJobStatuses, "", gomock.Any()).Return(jobs, "job-deleted", nil)
+				taskRepo.EXPECT().GetTasksByJobIds(gomock.Any(), []string{"job-4"}).Return(map[string][]models.Task{"job-4": tasks["job-4"]}, nil)
+			},
+			expectedStatus:  http.StatusOK,
+			expectedScanned: 1,
+		},
+		{
+			name:  "NoMatchingJobs",
+			query: "?since=" + lastWeek.Format(time.RFC3339) + "&host=unknown.example",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJobsByStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(jobs, "", nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedLinks:  []BrokenLink{},
+		},
+		{
+			name:           "LimitTooLarge",
+			query:          "?limit=501",
+			setupMocks:     func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "InvalidSince",
+			query:          "?since=last-week",
+			setupMocks:     func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:  "TasksError",
+			query: "",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJobsByStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(jobs, "", nil)
+				taskRepo.EXPECT().GetTasksByJobIds(gomock.Any(), gomock.Any()).Return(nil, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:  "QueryError",
+			query: "",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJobsByStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, "", errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, mockTaskRepo, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+
+			tc.setupMocks(mockJobRepo, mockTaskRepo)
+
+			req, err := makeRequest("GET", "/admin/broken-links"+tc.query, nil)
+			assert.NoError(t, err, "Failed to create request")
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("GET", "/admin/broken-links", api.handleGetBrokenLinks)
+			router.Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var resp BrokenLinksResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			if tc.expectedLinks != nil {
+				assert.Equal(t, tc.expectedLinks, resp.Links)
+			}
+			assert.Equal(t, tc.expectedScanned, resp.JobsScanned)
+			assert.Equal(t, tc.expectedCursor, resp.NextCursor)
+		})
+	}
+}
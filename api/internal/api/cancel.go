@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"shared/audit"
+	"shared/messagebus"
+	"shared/middleware"
+	"shared/models"
+	"shared/repository"
+	"strings"
+
+	"github.com/yousuf64/shift"
+)
+
+// cancelAllPageSize is the number of jobs read per page while cancelling all jobs
+const cancelAllPageSize = 100
+
+// activeJobStatuses are the statuses a job can be cancelled from
+var activeJobStatuses = []models.JobStatus{models.JobStatusPending, models.JobStatusRunning}
+
+// CancelJobResponse is the response body for the cancel job endpoint
+type CancelJobResponse struct {
+	JobID     string `json:"job_id"`
+	Cancelled bool   `json:"cancelled"`
+}
+
+// CancelAllResponse is the response body for the cancel all endpoint
+type CancelAllResponse struct {
+	Cancelled int `json:"cancelled"`
+}
+
+// handleCancelJob handles the cancel job endpoint
+func (a *API) handleCancelJob(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+	ctx := r.Context()
+	jobID := route.Params.Get("job_id")
+
+	if strings.TrimSpace(jobID) == "" {
+		return errors.New("job_id is required")
+	}
+
+	job, err := a.jobRepo.GetJob(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, repository.ErrJobNotFound) {
+			middleware.WriteError(w, r, http.StatusNotFound, "Job not found")
+			return nil
+		}
+		return errors.Join(err, errors.New("failed to get job"))
+	}
+
+	cancelled, err := a.cancelJob(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	if !cancelled {
+		middleware.WriteError(w, r, http.StatusConflict, "Job has already finished")
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(CancelJobResponse{JobID: jobID, Cancelled: true})
+}
+
+// handleCancelAll handles the cancel all endpoint, cancelling every pending and running job
+func (a *API) handleCancelAll(w http.ResponseWriter, r *http.Request, _ shift.Route) error {
+	ctx := r.Context()
+
+	count := 0
+	cursor := ""
+	for {
+		jobs, next, err := a.jobRepo.GetJobsByStatus(ctx, activeJobStatuses, cursor, cancelAllPageSize)
+		if err != nil {
+			return errors.Join(err, errors.New("failed to get active jobs"))
+		}
+
+		for _, job := range jobs {
+			cancelled, err := a.cancelJob(ctx, job)
+			if err != nil {
+				return err
+			}
+			if cancelled {
+				count++
+			}
+		}
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	a.log.Info("Cancelled all active jobs", slog.Int("count", count))
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(CancelAllResponse{Cancelled: count})
+}
+
+// cancelJob cancels a job and publishes the status change.
+// It reports false when the job had already reached a terminal status.
+func (a *API) cancelJob(ctx context.Context, job *models.Job) (bool, error) {
+	cancelled, err := a.jobRepo.CancelJob(ctx, job.ID)
+	if err != nil {
+		return false, errors.Join(err, errors.New("failed to cancel job"))
+	}
+
+	if !cancelled {
+		return false, nil
+	}
+	a.audit.Record(ctx, audit.Record{
+		Event:  audit.EventJobCancelled,
+		JobID:  job.ID,
+		Status: string(models.JobStatusCancelled),
+	})
+
+	progress, _ := models.TerminalProgress(models.JobStatusCancelled)
+	if err := a.mb.PublishJobUpdate(ctx, messagebus.JobUpdateMessage{
+		Type:     messagebus.JobUpdateMessageType,
+		JobID:    job.ID,
+		Status:   string(models.JobStatusCancelled),
+		URL:      job.URL,
+		Progress: &progress,
+	}); err != nil {
+		return true, errors.Join(err, errors.New("failed to publish job update"))
+	}
+
+	a.log.Info("Job cancelled", slog.String("jobId", job.ID))
+	return true, nil
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"shared/messagebus"
+	"shared/mocks"
+	"shared/models"
+	"shared/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAPI_HandleCancelJob_TableDriven(t *testing.T) {
+	testCases := []struct {
+		name           string
+		jobID          string
+		setupMocks     func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface, *mocks.MockMessageBusInterface)
+		expectedStatus int
+	}{
+		{
+			name:  "CancelRunningJob",
+			jobID: "job-1",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(&models.Job{ID: "job-1", URL: "https://example.com", Status: models.JobStatusRunning}, nil)
+				jobRepo.EXPECT().CancelJob(gomock.Any(), "job-1").Return(true, nil)
+				progress := 0.0
+				mb.EXPECT().PublishJobUpdate(gomock.Any(), messagebus.JobUpdateMessage{
+					Type:     messagebus.JobUpdateMessageType,
+					JobID:    "job-1",
+					Status:   string(models.JobStatusCancelled),
+					URL:      "https://example.com",
+					Progress: &progress,
+				}).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:  "AlreadyFinished",
+			jobID: "job-2",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-2").Return(&models.Job{ID: "job-2", Status: models.JobStatusCompleted}, nil)
+				jobRepo.EXPECT().CancelJob(gomock.Any(), "job-2").Return(false, nil)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:  "JobNotFound",
+			jobID: "missing",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "missing").Return(nil, repository.ErrJobNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:  "DatabaseError",
+			jobID: "job-3",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-3").Return(&models.Job{ID: "job-3", Status: models.JobStatusPending}, nil)
+				jobRepo.EXPECT().CancelJob(gomock.Any(), "job-3").Return(false, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, mockTaskRepo, mockMessageBus, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+
+			tc.setupMocks(mockJobRepo, mockTaskRepo, mockMessageBus)
+
+			req, err := makeRequest("POST", "/jobs/"+tc.jobID+"/cancel", nil)
+			assert.NoError(t, err, "Failed to create request")
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("POST", "/jobs/:job_id/cancel", api.handleCancelJob)
+			router.Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func TestAPI_HandleCancelAll_TableDriven(t *testing.T) {
+	testCases := []struct {
+		name           string
+		setupMocks     func(*mocks.MockJobRepositoryInterface, *mocks.MockMessageBusInterface)
+		expectedStatus int
+		expectedCount  int
+	}{
+		{
+			name: "CancelsAcrossPages",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				gomock.InOrder(
+					jobRepo.EXPECT().GetJobsByStatus(gomock.Any(), activeJobStatuses, "", int64(cancelAllPageSize)).
+						Return([]*models.Job{{ID: "job-3"}, {ID: "job-2"}}, "job-2", nil),
+					jobRepo.EXPECT().GetJobsByStatus(gomock.Any(), activeJobStatuses, "job-2", int64(cancelAllPageSize)).
+						Return([]*models.Job{{ID: "job-1"}}, "", nil),
+				)
+				jobRepo.EXPECT().CancelJob(gomock.Any(), "job-3").Return(true, nil)
+				// Finished between the query and the cancel, so it is not counted
+				jobRepo.EXPECT().CancelJob(gomock.Any(), "job-2").Return(false, nil)
+				jobRepo.EXPECT().CancelJob(gomock.Any(), "job-1").Return(true, nil)
+				mb.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+			},
+			expectedStatus: http.StatusOK,
+			expectedCount:  2,
+		},
+		{
+			name: "NothingToCancel",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				jobRepo.EXPECT().GetJobsByStatus(gomock.Any(), gomock.Any(), "", gomock.Any()).Return([]*models.Job{}, "", nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedCount:  0,
+		},
+		{
+			name: "QueryError",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				jobRepo.EXPECT().GetJobsByStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, "", errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, _, mockMessageBus, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+
+			tc.setupMocks(mockJobRepo, mockMessageBus)
+
+			req, err := makeRequest("POST", "/admin/cancel-all", nil)
+			assert.NoError(t, err, "Failed to create request")
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("POST", "/admin/cancel-all", api.handleCancelAll)
+			router.Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedStatus == http.StatusOK {
+				var resp CancelAllResponse
+				assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+				assert.Equal(t, tc.expectedCount, resp.Cancelled)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"shared/messagebus"
+	"shared/middleware"
+	"shared/models"
+	"shared/repository"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/yousuf64/shift"
+)
+
+const (
+	defaultMaxEventStreams = 100
+	// eventHeartbeatInterval is how often a quiet event stream writes a heartbeat line, so proxies keep it open
+	eventHeartbeatInterval = 15 * time.Second
+	// eventWriteTimeout is the slack writes get beyond the heartbeat interval, a client that stops reading
+	// is dropped once it is spent
+	eventWriteTimeout = 10 * time.Second
+	// eventBufferSize is the number of updates held for a stream while its client is being written to
+	eventBufferSize = 64
+)
+
+// ndjsonContentType is the content type of the event streams, one JSON document per line
+const ndjsonContentType = "application/x-ndjson"
+
+// heartbeatEventType is the type of the lines written to keep a quiet event stream open
+const heartbeatEventType = "heartbeat"
+
+// HeartbeatEvent is the line written to a quiet event stream every heartbeat interval
+type HeartbeatEvent struct {
+	Type string    `json:"type"`
+	At   time.Time `json:"at"`
+}
+
+// WithMaxEventStreams sets how many job event streams are served at once, further ones are refused with a 503
+func WithMaxEventStreams(n int) Option {
+	return func(a *API) {
+		a.maxEventStreams = n
+	}
+}
+
+// handleJobEvents handles the job events endpoint, streaming the job, task and subtask updates of a job as
+// NDJSON until the job finishes or the client goes away. A job that already finished gets its final update only.
+// Once the stream started its status is sent, so failures past that point end the stream rather than return an error.
+func (a *API) handleJobEvents(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+	ctx := r.Context()
+	jobID := route.Params.Get("job_id")
+
+	if strings.TrimSpace(jobID) == "" {
+		return errors.New("job_id is required")
+	}
+
+	if a.eventStreams.Add(1) > int32(a.maxEventStreams) {
+		a.eventStreams.Add(-1)
+		middleware.WriteError(w, r, http.StatusServiceUnavailable, "Too many event streams")
+		return nil
+	}
+	defer a.eventStreams.Add(-1)
+
+	// Subscribed before the job is read, so an update made in between is not missed
+	events := make(chan []byte, eventBufferSize)
+	stop := make(chan struct{})
+	subs, err := a.subscribeToJobEvents(jobID, events, stop)
+	defer func() {
+		close(stop)
+		for _, sub := range subs {
+			_ = sub.Unsubscribe()
+		}
+	}()
+	if err != nil {
+		return errors.Join(err, errors.New("failed to subscribe to job updates"))
+	}
+
+	job, err := a.jobRepo.GetJob(ctx, jobID)
+	if errors.Is(err, repository.ErrJobNotFound) {
+		middleware.WriteError(w, r, http.StatusNotFound, "Job not found")
+		return nil
+	}
+	if err != nil {
+		return errors.Join(err, errors.New("failed to get job"))
+	}
+	if rejectDeletedJob(w, r, job) {
+		return nil
+	}
+
+	stream := newEventStream(w, a.eventHeartbeatInterval+eventWriteTimeout)
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if job.Status.IsTerminal() {
+		if err := stream.writeJSON(finalJobUpdate(job)); err != nil {
+			a.logStreamEnd(jobID, err)
+		}
+		return nil
+	}
+	if err := stream.flush(); err != nil {
+		a.logStreamEnd(jobID, err)
+		return nil
+	}
+
+	a.streamJobEvents(ctx, jobID, stream, events)
+	return nil
+}
+
+// streamJobEvents writes the updates to the stream until a terminal job update, the end of ctx or the server draining
+func (a *API) streamJobEvents(ctx context.Context, jobID string, stream *eventStream, events <-chan []byte) {
+	heartbeat := time.NewTicker(a.eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-heartbeat.C:
+			if a.draining.Load() {
+				return
+			}
+			if err := stream.writeJSON(HeartbeatEvent{Type: heartbeatEventType, At: now.UTC()}); err != nil {
+				a.logStreamEnd(jobID, err)
+				return
+			}
+		case data := <-events:
+			if err := stream.writeLine(data); err != nil {
+				a.logStreamEnd(jobID, err)
+				return
+			}
+			if isTerminalJobUpdate(data) {
+				return
+			}
+		}
+	}
+}
+
+// subscribeToJobEvents subscribes to the job, task and subtask updates, passing those of the job to events until
+// stop is closed. The subscriptions made are returned even when one of them failed.
+func (a *API) subscribeToJobEvents(jobID string, events chan<- []byte, stop <-chan struct{}) ([]*nats.Subscription, error) {
+	handler := func(_ context.Context, m *nats.Msg) {
+		var update struct {
+			JobID string `json:"job_id"`
+		}
+		if err := json.Unmarshal(m.Data, &update); err != nil || update.JobID != jobID {
+			return
+		}
+		// Waiting here only holds up this stream's own subscriptions
+		select {
+		case events <- m.Data:
+		case <-stop:
+		}
+	}
+
+	var subs []*nats.Subscription
+	for _, subscribe := range []func(func(context.Context, *nats.Msg)) (*nats.Subscription, error){
+		a.mb.SubscribeToJobUpdate,
+		a.mb.SubscribeToTaskStatusUpdate,
+		a.mb.SubscribeToSubTaskUpdate,
+	} {
+		sub, err := subscribe(handler)
+		if err != nil {
+			return subs, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+// isTerminalJobUpdate reports whether an update is a job update to a final status, which ends the stream
+func isTerminalJobUpdate(data []byte) bool {
+	var update messagebus.JobUpdateMessage
+	if err := json.Unmarshal(data, &update); err != nil {
+		return false
+	}
+	return update.Type == messagebus.JobUpdateMessageType && models.JobStatus(update.Status).IsTerminal()
+}
+
+// finalJobUpdate returns the update a finished job was last published with, as far as it is stored
+func finalJobUpdate(job *models.Job) messagebus.JobUpdateMessage {
+	progress, _ := models.TerminalProgress(job.Status)
+	return messagebus.JobUpdateMessage{
+		Type:            messagebus.JobUpdateMessageType,
+		JobID:           job.ID,
+		Status:          string(job.Status),
+		Result:          job.Result,
+		URL:             job.URL,
+		Progress:        &progress,
+		FailureCode:     job.FailureCode,
+		FailureReason:   job.FailureReason,
+		FetchStatusCode: job.FetchStatusCode,
+	}
+}
+
+func (a *API) logStreamEnd(jobID string, err error) {
+	a.log.Debug("Job event stream ended by a failed write",
+		slog.String("jobId", jobID),
+		slog.Any("error", err))
+}
+
+// eventStream writes NDJSON lines to a client, flushing each one
+type eventStream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+	// writeTimeout is how long a write may take from the previous one
+	writeTimeout time.Duration
+}
+
+func newEventStream(w http.ResponseWriter, writeTimeout time.Duration) *eventStream {
+	return &eventStream{w: w, rc: http.NewResponseController(w), writeTimeout: writeTimeout}
+}
+
+func (s *eventStream) writeJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.writeLine(data)
+}
+
+// writeLine writes data, a single line of JSON, and flushes it
+func (s *eventStream) writeLine(data []byte) error {
+	if _, err := s.w.Write(data); err != nil {
+		return err
+	}
+	if _, err := s.w.Write([]byte{'\n'}); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+// flush sends the lines written so far. The write deadline is pushed out every time, as the stream outlives
+// the server's write timeout.
+func (s *eventStream) flush() error {
+	// Not every writer supports deadlines (e.g. recorders in tests), the server's write timeout applies then
+	_ = s.rc.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+	return s.rc.Flush()
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"shared/messagebus"
+	"shared/models"
+	"shared/repository"
+	"strconv"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// eventStreamServer serves the job events endpoint of api over a real connection, with updates carried by an
+// embedded NATS server
+func eventStreamServer(t *testing.T, api *API, port int) (*httptest.Server, *messagebus.MessageBus, *nats.Conn) {
+	t.Helper()
+
+	opts := natsserver.DefaultTestOptions
+	opts.Port = port
+	natsServer := natsserver.RunServer(&opts)
+	t.Cleanup(natsServer.Shutdown)
+
+	nc, err := nats.Connect("nats://127.0.0.1:" + strconv.Itoa(opts.Port))
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+	bus := messagebus.New(nc, nil)
+	api.mb = bus
+
+	server := httptest.NewServer(setupRouter("GET", "/jobs/:job_id/events", api.handleJobEvents).Serve())
+	t.Cleanup(server.Close)
+	return server, bus, nc
+}
+
+// readEvent reads the next line of an event stream as a generic JSON object
+func readEvent(t *testing.T, lines *bufio.Scanner) map[string]any {
+	t.Helper()
+
+	require.True(t, lines.Scan(), "expected another event")
+	var event map[string]any
+	require.NoError(t, json.Unmarshal(lines.Bytes(), &event), "every line is a JSON document")
+	return event
+}
+
+func TestAPI_HandleJobEvents_Integration(t *testing.T) {
+	api, mockJobRepo, _, _, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+	server, bus, nc := eventStreamServer(t, api, 8437)
+
+	mockJobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(&models.Job{ID: "job-1", Status: models.JobStatusRunning}, nil)
+
+	resp, err := http.Get(server.URL + "/jobs/job-1/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, ndjsonContentType, resp.Header.Get("Content-Type"))
+
+	ctx := context.Background()
+	require.NoError(t, bus.PublishTaskStatusUpdate(ctx, messagebus.TaskStatusUpdateMessage{JobID: "job-1", TaskType: "extracting", Status: "completed"}))
+	require.NoError(t, bus.PublishJobUpdate(ctx, messagebus.JobUpdateMessage{JobID: "job-2", Status: "completed"}))
+	require.NoError(t, bus.PublishSubTaskUpdate(ctx, messagebus.SubTaskUpdateMessage{JobID: "job-1", TaskType: "verifying_links", Key: "1"}))
+
+	// Updates of different types travel on separate subscriptions, so they may be written in any order
+	lines := bufio.NewScanner(resp.Body)
+	var types []string
+	for range 2 {
+		event := readEvent(t, lines)
+		assert.Equal(t, "job-1", event["job_id"], "updates of other jobs are left out")
+		types = append(types, event["type"].(string))
+	}
+	assert.ElementsMatch(t, []string{string(messagebus.TaskStatusUpdateMessageType), string(messagebus.SubTaskUpdateMessageType)}, types)
+
+	require.NoError(t, bus.PublishJobUpdate(ctx, messagebus.JobUpdateMessage{JobID: "job-1", Status: "completed"}))
+	event := readEvent(t, lines)
+	assert.Equal(t, string(messagebus.JobUpdateMessageType), event["type"])
+	assert.Equal(t, "completed", event["status"])
+
+	assert.False(t, lines.Scan(), "the stream ends once the job finished")
+	require.NoError(t, lines.Err())
+	assert.Eventually(t, func() bool { return api.eventStreams.Load() == 0 && nc.NumSubscriptions() == 0 },
+		time.Second, 10*time.Millisecond, "the stream's subscriptions are removed")
+}
+
+func TestAPI_HandleJobEvents_Heartbeat(t *testing.T) {
+	api, mockJobRepo, _, _, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+	api.eventHeartbeatInterval = 20 * time.Millisecond
+	server, _, nc := eventStreamServer(t, api, 8438)
+
+	mockJobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(&models.Job{ID: "job-1", Status: models.JobStatusPending}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/jobs/job-1/events", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	lines := bufio.NewScanner(resp.Body)
+	for range 2 {
+		assert.Equal(t, heartbeatEventType, readEvent(t, lines)["type"])
+	}
+
+	// The client going away ends the stream along with its subscriptions
+	cancel()
+	assert.Eventually(t, func() bool { return api.eventStreams.Load() == 0 && nc.NumSubscriptions() == 0 },
+		time.Second, 10*time.Millisecond)
+}
+
+func TestAPI_HandleJobEvents_TableDriven(t *testing.T) {
+	deletedAt := time.Now()
+
+	testCases := []struct {
+		name           string
+		job            *models.Job
+		err            error
+		maxStreams     int
+		expectedStatus int
+		expectedEvent  *messagebus.JobUpdateMessage
+	}{
+		{
+			name:           "FinishedJob",
+			job:            &models.Job{ID: "job-1", URL: "https://example.com", Status: models.JobStatusFailed, FailureCode: models.FailureCodeFetch, FailureReason: "HTTP 503"},
+			maxStreams:     1,
+			expectedStatus: http.StatusOK,
+			expectedEvent: &messagebus.JobUpdateMessage{
+				Type:          messagebus.JobUpdateMessageType,
+				JobID:         "job-1",
+				Status:        string(models.JobStatusFailed),
+				URL:           "https://example.com",
+				FailureCode:   models.FailureCodeFetch,
+				FailureReason: "HTTP 503",
+			},
+		},
+		{name: "JobNotFound", err: repository.ErrJobNotFound, maxStreams: 1, expectedStatus: http.StatusNotFound},
+		{name: "DeletedJob", job: &models.Job{ID: "job-1", Status: models.JobStatusCompleted, DeletedAt: &deletedAt}, maxStreams: 1, expectedStatus: http.StatusGone},
+		{name: "TooManyStreams", maxStreams: 0, expectedStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, _, mockMessageBus, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+			WithMaxEventStreams(tc.maxStreams)(api)
+
+			if tc.maxStreams > 0 {
+				mockMessageBus.EXPECT().SubscribeToJobUpdate(gomock.Any()).Return(&nats.Subscription{}, nil)
+				mockMessageBus.EXPECT().SubscribeToTaskStatusUpdate(gomock.Any()).Return(&nats.Subscription{}, nil)
+				mockMessageBus.EXPECT().SubscribeToSubTaskUpdate(gomock.Any()).Return(&nats.Subscription{}, nil)
+				mockJobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(tc.job, tc.err)
+			}
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("GET", "/jobs/:job_id/events", api.handleJobEvents)
+			router.Serve().ServeHTTP(rr, httptest.NewRequest("GET", "/jobs/job-1/events", nil))
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedEvent != nil {
+				var event messagebus.JobUpdateMessage
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &event))
+				require.NotNil(t, event.Progress)
+				event.Progress = nil
+				assert.Equal(t, *tc.expectedEvent, event)
+			}
+			assert.Zero(t, api.eventStreams.Load())
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"shared/middleware"
+	"shared/models"
+	"shared/repository"
+	"strconv"
+	"strings"
+
+	"github.com/yousuf64/shift"
+)
+
+const (
+	exportFormatCSV  = "csv"
+	exportFormatJSON = "json"
+)
+
+// exportCSVHeader is the header row of the CSV export
+var exportCSVHeader = []string{"url", "type", "status", "status_code", "description"}
+
+// ExportResponse is the response body for the JSON export
+type ExportResponse struct {
+	JobID string       `json:"job_id"`
+	URL   string       `json:"url"`
+	Links []ExportLink `json:"links"`
+}
+
+// ExportLink is a single link of the export along with its verification outcome
+type ExportLink struct {
+	URL         string            `json:"url"`
+	Type        string            `json:"type"`
+	Status      models.TaskStatus `json:"status"`
+	StatusCode  int               `json:"status_code,omitempty"`
+	Description string            `json:"description"`
+}
+
+// handleExportJob handles the export job endpoint
+func (a *API) handleExportJob(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+	ctx := r.Context()
+	jobID := route.Params.Get("job_id")
+
+	if strings.TrimSpace(jobID) == "" {
+		return errors.New("job_id is required")
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exportFormatJSON
+	}
+	if format != exportFormatCSV && format != exportFormatJSON {
+		middleware.WriteError(w, r, http.StatusBadRequest, "Unsupported format, expected csv or json")
+		return nil
+	}
+
+	job, err := a.jobRepo.GetJob(ctx, jobID)
+	if errors.Is(err, repository.ErrJobNotFound) {
+		middleware.WriteError(w, r, http.StatusNotFound, "Job not found")
+		return nil
+	}
+	if err != nil {
+		return errors.Join(err, errors.New("failed to get job"))
+	}
+
+	if job.Result == nil {
+		middleware.WriteError(w, r, http.StatusConflict, "Job has no result yet")
+		return nil
+	}
+
+	tasks, err := a.taskRepo.GetTasksByJobId(ctx, jobID)
+	if err != nil {
+		return errors.Join(err, errors.New("failed to get tasks"))
+	}
+
+	links := buildExportLinks(job, tasks)
+	filename := fmt.Sprintf("job-%s-links.%s", job.ID, format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if format == exportFormatCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		return writeExportCSV(w, links)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(ExportResponse{JobID: job.ID, URL: job.URL, Links: links})
+}
+
+// buildExportLinks pairs every link of the result with its verification subtask.
+// Subtasks are keyed by the link's 1-based position, links without one are left pending.
+func buildExportLinks(job *models.Job, tasks []models.Task) []ExportLink {
+	var subTasks map[string]models.SubTask
+	for _, task := range tasks {
+		if task.Type == models.TaskTypeVerifyingLinks {
+			subTasks = task.SubTasks
+			break
+		}
+	}
+
+	links := make([]ExportLink, 0, len(job.Result.Links))
+	for i, link := range job.Result.Links {
+		row := ExportLink{
+			URL:    link,
+			Type:   linkType(job, i),
+			Status: models.TaskStatusPending,
+		}
+		if subTask, ok := subTasks[strconv.Itoa(i+1)]; ok {
+			row.Status = subTask.Status
+			row.StatusCode = parseStatusCode(subTask.Description)
+			row.Description = subTask.Description
+		}
+		links = append(links, row)
+	}
+	return links
+}
+
+// writeExportCSV streams the links as CSV rows
+func writeExportCSV(w http.ResponseWriter, links []ExportLink) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportCSVHeader); err != nil {
+		return errors.Join(err, errors.New("failed to write csv header"))
+	}
+
+	for _, link := range links {
+		statusCode := ""
+		if link.StatusCode != 0 {
+			statusCode = strconv.Itoa(link.StatusCode)
+		}
+		if err := cw.Write([]string{link.URL, link.Type, string(link.Status), statusCode, link.Description}); err != nil {
+			return errors.Join(err, errors.New("failed to write csv row"))
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// linkType reports whether the i-th link of the result is internal or external, as the analyzer classified it
+// when the result explains its links, and by the same rule against the job's URL otherwise
+func linkType(job *models.Job, i int) string {
+	var external bool
+	if classifications := job.Result.LinkClassifications; len(classifications) == len(job.Result.Links) {
+		external = classifications[i].External
+	} else {
+		external, _ = models.ClassifyLink(job.Result.Links[i], job.URL)
+	}
+
+	if external {
+		return "external"
+	}
+	return "internal"
+}
+
+// parseStatusCode extracts the HTTP status code from a verification description such as "HTTP 404: Not Found"
+func parseStatusCode(description string) int {
+	var code int
+	if _, err := fmt.Sscanf(description, "HTTP %d:", &code); err != nil {
+		return 0
+	}
+	return code
+}
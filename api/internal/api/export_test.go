@@ -0,0 +1,226 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"shared/mocks"
+	"shared/models"
+	"shared/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func exportTestJob() *models.Job {
+	return &models.Job{
+		ID:     "job-1",
+		URL:    "https://example.com",
+		Status: models.JobStatusCompleted,
+		Result: &models.AnalyzeResult{
+			Links: []string{
+				"https://example.com/about",
+				"https://other.org/",
+				"https://example.com/contact",
+			},
+		},
+	}
+}
+
+func exportTestTasks() []models.Task {
+	return []models.Task{
+		{JobID: "job-1", Type: models.TaskTypeExtracting, Status: models.TaskStatusCompleted},
+		{
+			JobID:  "job-1",
+			Type:   models.TaskTypeVerifyingLinks,
+			Status: models.TaskStatusCompleted,
+			SubTasks: map[string]models.SubTask{
+				"1": {Type: models.SubTaskTypeValidatingLink, Status: models.TaskStatusCompleted, URL: "https://example.com/about", Description: "HTTP 200: OK"},
+				"2": {Type: models.SubTaskTypeValidatingLink, Status: models.TaskStatusFailed, URL: "https://other.org/", Description: "Connection timeout"},
+			},
+		},
+	}
+}
+
+func TestAPI_HandleExportJob_TableDriven(t *testing.T) {
+	testCases := []struct {
+		name                string
+		query               string
+		setupMocks          func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface)
+		expectedStatus      int
+		expectedContentType string
+		expectedFilename    string
+	}{
+		{
+			name: "DefaultsToJSON",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(exportTestJob(), nil)
+				taskRepo.EXPECT().GetTasksByJobId(gomock.Any(), "job-1").Return(exportTestTasks(), nil)
+			},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedFilename:    `attachment; filename="job-job-1-links.json"`,
+		},
+		{
+			name:  "CSV",
+			query: "?format=csv",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(exportTestJob(), nil)
+				taskRepo.EXPECT().GetTasksByJobId(gomock.Any(), "job-1").Return(exportTestTasks(), nil)
+			},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedFilename:    `attachment; filename="job-job-1-links.csv"`,
+		},
+		{
+			name:           "UnsupportedFormat",
+			query:          "?format=xml",
+			setupMocks:     func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "JobNotFound",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(nil, repository.ErrJobNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "NoResultYet",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(&models.Job{ID: "job-1", Status: models.JobStatusPending}, nil)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name: "TaskRepositoryError",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(exportTestJob(), nil)
+				taskRepo.EXPECT().GetTasksByJobId(gomock.Any(), "job-1").Return(nil, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, mockTaskRepo, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+
+			tc.setupMocks(mockJobRepo, mockTaskRepo)
+
+			req, err := makeRequest("GET", "/jobs/job-1/export"+tc.query, nil)
+			assert.NoError(t, err, "Failed to create request")
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("GET", "/jobs/:job_id/export", api.handleExportJob)
+			router.Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedStatus == http.StatusOK {
+				assert.Equal(t, tc.expectedContentType, rr.Header().Get("Content-Type"))
+				assert.Equal(t, tc.expectedFilename, rr.Header().Get("Content-Disposition"))
+			}
+		})
+	}
+}
+
+func TestAPI_HandleExportJob_CSVRows(t *testing.T) {
+	api, mockJobRepo, mockTaskRepo, _, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+
+	mockJobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(exportTestJob(), nil)
+	mockTaskRepo.EXPECT().GetTasksByJobId(gomock.Any(), "job-1").Return(exportTestTasks(), nil)
+
+	req, err := makeRequest("GET", "/jobs/job-1/export?format=csv", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router := setupRouter("GET", "/jobs/:job_id/export", api.handleExportJob)
+	router.Serve().ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rows, err := csv.NewReader(rr.Body).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"url", "type", "status", "status_code", "description"},
+		{"https://example.com/about", "internal", "completed", "200", "HTTP 200: OK"},
+		{"https://other.org/", "external", "failed", "", "Connection timeout"},
+		{"https://example.com/contact", "internal", "pending", "", ""},
+	}, rows)
+}
+
+func TestAPI_HandleExportJob_JSONBody(t *testing.T) {
+	api, mockJobRepo, mockTaskRepo, _, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+
+	mockJobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(exportTestJob(), nil)
+	mockTaskRepo.EXPECT().GetTasksByJobId(gomock.Any(), "job-1").Return(exportTestTasks(), nil)
+
+	req, err := makeRequest("GET", "/jobs/job-1/export?format=json", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router := setupRouter("GET", "/jobs/:job_id/export", api.handleExportJob)
+	router.Serve().ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp ExportResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, "job-1", resp.JobID)
+	require.Len(t, resp.Links, 3)
+	assert.Equal(t, ExportLink{
+		URL:         "https://example.com/about",
+		Type:        "internal",
+		Status:      models.TaskStatusCompleted,
+		StatusCode:  200,
+		Description: "HTTP 200: OK",
+	}, resp.Links[0])
+}
+
+func TestLinkType(t *testing.T) {
+	links := []string{"https://example.com/about", "http://example.com/", "https://example.com:8443/", "https://blog.example.com/"}
+	testCases := []struct {
+		name            string
+		classifications []models.LinkClassification
+		expected        []string
+	}{
+		{
+			name:     "SameOriginRule",
+			expected: []string{"internal", "external", "external", "external"},
+		},
+		{
+			name: "AnalyzerClassifications",
+			classifications: []models.LinkClassification{
+				{URL: links[0], External: true},
+				{URL: links[1]},
+				{URL: links[2]},
+				{URL: links[3], External: true},
+			},
+			expected: []string{"external", "internal", "internal", "external"},
+		},
+		{
+			name:            "IncompleteClassifications",
+			classifications: []models.LinkClassification{{URL: links[0], External: true}},
+			expected:        []string{"internal", "external", "external", "external"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			job := &models.Job{URL: "https://example.com", Result: &models.AnalyzeResult{
+				Links:               links,
+				LinkClassifications: tc.classifications,
+			}}
+			types := make([]string, 0, len(links))
+			for i := range links {
+				types = append(types, linkType(job, i))
+			}
+			assert.Equal(t, tc.expected, types)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"shared/middleware"
+	"shared/models"
+	"shared/repository"
+	"slices"
+	"strings"
+
+	"github.com/yousuf64/shift"
+)
+
+// FindingsResponse is the response body for the findings of a job
+type FindingsResponse struct {
+	JobID    string    `json:"job_id"`
+	Findings []Finding `json:"findings"`
+	// Summary counts the job's findings by severity, before the severity filter is applied
+	Summary map[models.FindingSeverity]int `json:"summary"`
+}
+
+// Finding is a warning of a job's result along with its catalog entry
+type Finding struct {
+	models.FindingDefinition
+	Message string `json:"message"`
+}
+
+// FindingCatalogResponse is the response body for the finding catalog
+type FindingCatalogResponse struct {
+	Findings []models.FindingDefinition `json:"findings"`
+}
+
+// handleGetFindings handles the get findings by job ID endpoint.
+// The severity query parameter keeps the findings of one severity, the summary always counts them all.
+func (a *API) handleGetFindings(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+	ctx := r.Context()
+	jobID := route.Params.Get("job_id")
+
+	if strings.TrimSpace(jobID) == "" {
+		return errors.New("job_id is required")
+	}
+
+	var severity models.FindingSeverity
+	if raw := r.URL.Query().Get("severity"); raw != "" {
+		parsed, err := models.ParseFindingSeverity(raw)
+		if err != nil {
+			middleware.WriteError(w, r, http.StatusBadRequest,
+				fmt.Sprintf("Unsupported severity, expected one of %v", models.FindingSeverities()))
+			return nil
+		}
+		severity = parsed
+	}
+
+	job, err := a.jobRepo.GetJob(ctx, jobID)
+	if errors.Is(err, repository.ErrJobNotFound) {
+		middleware.WriteError(w, r, http.StatusNotFound, "Job not found")
+		return nil
+	}
+	if err != nil {
+		return errors.Join(err, errors.New("failed to get job"))
+	}
+	if rejectDeletedJob(w, r, job) {
+		return nil
+	}
+
+	if job.Result == nil {
+		middleware.WriteError(w, r, http.StatusConflict, "Job has no result yet")
+		return nil
+	}
+
+	findings, summary := buildFindings(job.Result.Warnings, severity)
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(FindingsResponse{JobID: job.ID, Findings: findings, Summary: summary})
+}
+
+// handleGetFindingCatalog handles the finding catalog endpoint, for clients to map codes to their own copy
+func (a *API) handleGetFindingCatalog(w http.ResponseWriter, _ *http.Request, _ shift.Route) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(FindingCatalogResponse{Findings: models.FindingCatalog()})
+}
+
+// buildFindings pairs each warning with its catalog entry, the most severe first, keeping those of severity when it
+// is set. The summary counts every warning by severity. A code missing from the catalog, raised by an analyzer
+// newer than the API, is reported as a warning titled by its code.
+func buildFindings(warnings []models.Warning, severity models.FindingSeverity) ([]Finding, map[models.FindingSeverity]int) {
+	summary := make(map[models.FindingSeverity]int)
+	for _, s := range models.FindingSeverities() {
+		summary[s] = 0
+	}
+
+	findings := make([]Finding, 0, len(warnings))
+	for _, warning := range warnings {
+		definition, ok := models.LookupFinding(warning.Code)
+		if !ok {
+			definition = models.FindingDefinition{Code: warning.Code, Severity: models.FindingSeverityWarning, Title: warning.Code}
+		}
+
+		summary[definition.Severity]++
+		if severity != "" && definition.Severity != severity {
+			continue
+		}
+		findings = append(findings, Finding{FindingDefinition: definition, Message: warning.Message})
+	}
+
+	slices.SortStableFunc(findings, func(a, b Finding) int {
+		return a.Severity.Rank() - b.Severity.Rank()
+	})
+	return findings, summary
+}
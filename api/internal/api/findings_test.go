@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"shared/mocks"
+	"shared/models"
+	"shared/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func findingsTestJob() *models.Job {
+	return &models.Job{
+		ID:     "job-1",
+		URL:    "https://example.com",
+		Status: models.JobStatusCompleted,
+		Result: &models.AnalyzeResult{
+			Warnings: []models.Warning{
+				{Code: models.WarningSampledVerification, Message: "Only 40% of the links were verified"},
+				{Code: models.WarningCanonicalMismatch, Message: "The canonical URL is https://example.com/home"},
+				{Code: models.WarningInsecureForm, Message: "The form submitting to http://example.com/login is sent unencrypted"},
+				{Code: "newer_code", Message: "Raised by a newer analyzer"},
+			},
+		},
+	}
+}
+
+func TestAPI_HandleGetFindings_TableDriven(t *testing.T) {
+	deletedAt := time.Now()
+
+	testCases := []struct {
+		name           string
+		query          string
+		setupMocks     func(*mocks.MockJobRepositoryInterface)
+		expectedStatus int
+		expectedCodes  []string
+	}{
+		{
+			name: "MostSevereFirst",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(findingsTestJob(), nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedCodes:  []string{models.WarningInsecureForm, models.WarningCanonicalMismatch, "newer_code", models.WarningSampledVerification},
+		},
+		{
+			name:  "FilteredBySeverity",
+			query: "?severity=warning",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(findingsTestJob(), nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedCodes:  []string{models.WarningCanonicalMismatch, "newer_code"},
+		},
+		{
+			name:  "NoFindingsOfSeverity",
+			query: "?severity=error",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				job := findingsTestJob()
+				job.Result.Warnings = job.Result.Warnings[:2]
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(job, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedCodes:  []string{},
+		},
+		{
+			name:           "UnknownSeverity",
+			query:          "?severity=critical",
+			setupMocks:     func(*mocks.MockJobRepositoryInterface) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "JobNotFound",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(nil, repository.ErrJobNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "DeletedJob",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				job := findingsTestJob()
+				job.DeletedAt = &deletedAt
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(job, nil)
+			},
+			expectedStatus: http.StatusGone,
+		},
+		{
+			name: "NoResultYet",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(&models.Job{ID: "job-1", Status: models.JobStatusPending}, nil)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, _, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+
+			tc.setupMocks(mockJobRepo)
+
+			req, err := makeRequest("GET", "/jobs/job-1/findings"+tc.query, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("GET", "/jobs/:job_id/findings", api.handleGetFindings)
+			router.Serve().ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var resp FindingsResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			codes := make([]string, 0, len(resp.Findings))
+			for _, finding := range resp.Findings {
+				codes = append(codes, finding.Code)
+			}
+			assert.Equal(t, tc.expectedCodes, codes)
+		})
+	}
+}
+
+func TestAPI_HandleGetFindings_Body(t *testing.T) {
+	api, mockJobRepo, _, _, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+
+	mockJobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(findingsTestJob(), nil)
+
+	req, err := makeRequest("GET", "/jobs/job-1/findings?severity=error", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router := setupRouter("GET", "/jobs/:job_id/findings", api.handleGetFindings)
+	router.Serve().ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, "job-1", resp["job_id"])
+	// The summary counts every finding, not only those of the filtered severity
+	assert.Equal(t, map[string]any{"error": 1.0, "warning": 2.0, "info": 1.0}, resp["summary"])
+	assert.Equal(t, []any{map[string]any{
+		"code":        "insecure_form",
+		"severity":    "error",
+		"title":       "A form exposes what users enter in it",
+		"docs_anchor": "finding-insecure_form",
+		"message":     "The form submitting to http://example.com/login is sent unencrypted",
+	}}, resp["findings"])
+}
+
+func TestAPI_HandleGetFindingCatalog(t *testing.T) {
+	api, _, _, _, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+
+	req, err := makeRequest("GET", "/findings", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router := setupRouter("GET", "/findings", api.handleGetFindingCatalog)
+	router.Serve().ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp FindingCatalogResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, models.FindingCatalog(), resp.Findings)
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"shared/middleware"
+	"shared/models"
+	"shared/repository"
+	"strings"
+
+	"github.com/yousuf64/shift"
+)
+
+const graphFormatDOT = "dot"
+
+// dotEscaper escapes a string for a double-quoted DOT ID
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// GraphResponse is the response body for the link graph of a job, in adjacency form
+type GraphResponse struct {
+	JobID string      `json:"job_id"`
+	Nodes []GraphNode `json:"nodes"`
+}
+
+// GraphNode is a page of the link graph. Analyzed is set for the pages whose links were extracted,
+// Edges lists their outbound links and is empty for the pages only linked to.
+type GraphNode struct {
+	URL      string      `json:"url"`
+	Analyzed bool        `json:"analyzed"`
+	Edges    []GraphEdge `json:"edges"`
+}
+
+// GraphEdge is a link from a page to a URL along with its verification outcome
+type GraphEdge struct {
+	To         string            `json:"to"`
+	Type       string            `json:"type"`
+	Status     models.TaskStatus `json:"status"`
+	StatusCode int               `json:"status_code,omitempty"`
+}
+
+// handleGetGraph handles the link graph endpoint. The format query parameter selects json (default) or dot,
+// for Graphviz.
+func (a *API) handleGetGraph(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+	ctx := r.Context()
+	jobID := route.Params.Get("job_id")
+
+	if strings.TrimSpace(jobID) == "" {
+		return errors.New("job_id is required")
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exportFormatJSON
+	}
+	if format != exportFormatJSON && format != graphFormatDOT {
+		middleware.WriteError(w, r, http.StatusBadRequest, "Unsupported format, expected json or dot")
+		return nil
+	}
+
+	job, err := a.jobRepo.GetJob(ctx, jobID)
+	if errors.Is(err, repository.ErrJobNotFound) {
+		middleware.WriteError(w, r, http.StatusNotFound, "Job not found")
+		return nil
+	}
+	if err != nil {
+		return errors.Join(err, errors.New("failed to get job"))
+	}
+	if rejectDeletedJob(w, r, job) {
+		return nil
+	}
+
+	if job.Result == nil {
+		middleware.WriteError(w, r, http.StatusConflict, "Job has no result yet")
+		return nil
+	}
+
+	tasks, err := a.taskRepo.GetTasksByJobId(ctx, jobID)
+	if err != nil {
+		return errors.Join(err, errors.New("failed to get tasks"))
+	}
+
+	nodes := buildGraph(job.URL, buildExportLinks(job, tasks))
+	if format == graphFormatDOT {
+		w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+		return writeGraphDOT(w, job.ID, nodes)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(GraphResponse{JobID: job.ID, Nodes: nodes})
+}
+
+// buildGraph builds the link graph of a single analyzed page, a star from the page to each URL it links to.
+// A URL linked more than once gets one edge, with the outcome of its first link.
+func buildGraph(pageURL string, links []ExportLink) []GraphNode {
+	nodes := []GraphNode{{URL: pageURL, Analyzed: true, Edges: []GraphEdge{}}}
+	seen := map[string]bool{pageURL: true}
+	linked := make(map[string]bool, len(links))
+
+	for _, link := range links {
+		if linked[link.URL] {
+			continue
+		}
+		linked[link.URL] = true
+		nodes[0].Edges = append(nodes[0].Edges, GraphEdge{
+			To:         link.URL,
+			Type:       link.Type,
+			Status:     link.Status,
+			StatusCode: link.StatusCode,
+		})
+
+		// A link back to the page is an edge to its own node
+		if !seen[link.URL] {
+			seen[link.URL] = true
+			nodes = append(nodes, GraphNode{URL: link.URL, Edges: []GraphEdge{}})
+		}
+	}
+	return nodes
+}
+
+// writeGraphDOT writes the graph as a Graphviz digraph. Analyzed pages are boxes, external links dashed
+// and links that failed verification red.
+func writeGraphDOT(w io.Writer, jobID string, nodes []GraphNode) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph \"job-%s\" {\n", dotEscaper.Replace(jobID))
+	for _, node := range nodes {
+		if node.Analyzed {
+			fmt.Fprintf(&b, "  \"%s\" [shape=box];\n", dotEscaper.Replace(node.URL))
+		} else {
+			fmt.Fprintf(&b, "  \"%s\";\n", dotEscaper.Replace(node.URL))
+		}
+	}
+	for _, node := range nodes {
+		for _, edge := range node.Edges {
+			attrs := []string{fmt.Sprintf("status=%q", edge.Status)}
+			if edge.Type == "external" {
+				attrs = append(attrs, "style=dashed")
+			}
+			if edge.Status == models.TaskStatusFailed {
+				attrs = append(attrs, "color=red")
+			}
+			fmt.Fprintf(&b, "  \"%s\" -> \"%s\" [%s];\n",
+				dotEscaper.Replace(node.URL), dotEscaper.Replace(edge.To), strings.Join(attrs, ", "))
+		}
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
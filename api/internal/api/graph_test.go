@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"shared/mocks"
+	"shared/models"
+	"shared/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAPI_HandleGetGraph_TableDriven(t *testing.T) {
+	deletedAt := time.Now()
+
+	testCases := []struct {
+		name                string
+		query               string
+		setupMocks          func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface)
+		expectedStatus      int
+		expectedContentType string
+	}{
+		{
+			name: "DefaultsToJSON",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(exportTestJob(), nil)
+				taskRepo.EXPECT().GetTasksByJobId(gomock.Any(), "job-1").Return(exportTestTasks(), nil)
+			},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+		},
+		{
+			name:  "DOT",
+			query: "?format=dot",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(exportTestJob(), nil)
+				taskRepo.EXPECT().GetTasksByJobId(gomock.Any(), "job-1").Return(exportTestTasks(), nil)
+			},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/vnd.graphviz; charset=utf-8",
+		},
+		{
+			name:           "UnsupportedFormat",
+			query:          "?format=csv",
+			setupMocks:     func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "JobNotFound",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(nil, repository.ErrJobNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name: "DeletedJob",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				job := exportTestJob()
+				job.DeletedAt = &deletedAt
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(job, nil)
+			},
+			expectedStatus: http.StatusGone,
+		},
+		{
+			name: "NoResultYet",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(&models.Job{ID: "job-1", Status: models.JobStatusPending}, nil)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, mockTaskRepo, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+
+			tc.setupMocks(mockJobRepo, mockTaskRepo)
+
+			req, err := makeRequest("GET", "/jobs/job-1/graph"+tc.query, nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("GET", "/jobs/:job_id/graph", api.handleGetGraph)
+			router.Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedStatus == http.StatusOK {
+				assert.Equal(t, tc.expectedContentType, rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestAPI_HandleGetGraph_DOTGolden(t *testing.T) {
+	api, mockJobRepo, mockTaskRepo, _, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+
+	mockJobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(exportTestJob(), nil)
+	mockTaskRepo.EXPECT().GetTasksByJobId(gomock.Any(), "job-1").Return(exportTestTasks(), nil)
+
+	req, err := makeRequest("GET", "/jobs/job-1/graph?format=dot", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	setupRouter("GET", "/jobs/:job_id/graph", api.handleGetGraph).Serve().ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	golden, err := os.ReadFile("testdata/graph.dot")
+	require.NoError(t, err)
+	assert.Equal(t, string(golden), rr.Body.String())
+}
+
+func TestBuildGraph(t *testing.T) {
+	links := []ExportLink{
+		{URL: "https://example.com/about", Type: "internal", Status: models.TaskStatusCompleted, StatusCode: 200},
+		{URL: "https://example.com", Type: "internal", Status: models.TaskStatusCompleted, StatusCode: 200},
+		{URL: "https://example.com/about", Type: "internal", Status: models.TaskStatusFailed},
+	}
+
+	nodes := buildGraph("https://example.com", links)
+
+	require.Len(t, nodes, 2, "a link back to the page adds no node")
+	assert.True(t, nodes[0].Analyzed)
+	assert.Equal(t, []GraphEdge{
+		{To: "https://example.com/about", Type: "internal", Status: models.TaskStatusCompleted, StatusCode: 200},
+		{To: "https://example.com", Type: "internal", Status: models.TaskStatusCompleted, StatusCode: 200},
+	}, nodes[0].Edges, "a URL linked twice keeps the outcome of its first link")
+	assert.Equal(t, GraphNode{URL: "https://example.com/about", Edges: []GraphEdge{}}, nodes[1])
+
+	data, err := json.Marshal(nodes[1])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"url": "https://example.com/about", "analyzed": false, "edges": []}`, string(data))
+}
@@ -0,0 +1,404 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	sharedconfig "shared/config"
+	"shared/messagebus"
+	"shared/middleware"
+	"shared/models"
+	"shared/repository"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/yousuf64/shift"
+)
+
+const (
+	minGroupSize        = 2
+	maxGroupSize        = 5
+	maxGroupLabelLength = 100
+)
+
+const defaultSubmitConcurrency = 4
+
+// WithSubmitConcurrency sets how many jobs of a group are submitted at once
+func WithSubmitConcurrency(n int) Option {
+	return func(a *API) {
+		a.submitConcurrency = n
+	}
+}
+
+// AnalyzeGroupRequest is the request body for the analyze group endpoint
+type AnalyzeGroupRequest struct {
+	URLs  []string `json:"urls"`
+	Label string   `json:"label"`
+}
+
+// AnalyzeGroupResponse is the response body for the analyze group endpoint.
+// Jobs holds the members that were queued, Failed the ones that could not be.
+type AnalyzeGroupResponse struct {
+	Group  models.Group        `json:"group"`
+	Jobs   []models.Job        `json:"jobs"`
+	Failed []FailedGroupMember `json:"failed,omitempty"`
+}
+
+// FailedGroupMember is a group member whose job could not be queued
+type FailedGroupMember struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+// failedMemberError is reported for a member that could not be queued, the cause is only logged
+const failedMemberError = "failed to queue the job, please retry"
+
+// GroupResponse is the response body for the get group endpoint
+type GroupResponse struct {
+	Group      models.Group     `json:"group"`
+	Members    []GroupMember    `json:"members"`
+	Comparison *GroupComparison `json:"comparison,omitempty"`
+}
+
+// GroupMember is the state of a single job within a group
+type GroupMember struct {
+	JobID   string           `json:"job_id"`
+	URL     string           `json:"url"`
+	Status  models.JobStatus `json:"status"`
+	Summary *ResultSummary   `json:"summary,omitempty"`
+}
+
+// ResultSummary is a condensed view of an analysis result
+type ResultSummary struct {
+	HtmlVersion       string `json:"html_version"`
+	PageTitle         string `json:"page_title"`
+	InternalLinkCount int    `json:"internal_link_count"`
+	ExternalLinkCount int    `json:"external_link_count"`
+	InaccessibleLinks int    `json:"inaccessible_links"`
+	HasLoginForm      bool   `json:"has_login_form"`
+	// FailedTasks lists the tasks that failed in a job that still completed
+	FailedTasks []models.TaskType `json:"failed_tasks,omitempty"`
+}
+
+// GroupComparison lines up the results of all group members, one row per metric
+type GroupComparison struct {
+	Metrics []ComparisonMetric `json:"metrics"`
+}
+
+// ComparisonMetric holds the value of a metric for each member, keyed by job ID
+type ComparisonMetric struct {
+	Name   string         `json:"name"`
+	Values map[string]any `json:"values"`
+}
+
+// comparisonMetrics are the result fields compared across group members
+var comparisonMetrics = []struct {
+	name  string
+	value func(r *models.AnalyzeResult) any
+}{
+	{"html_version", func(r *models.AnalyzeResult) any { return r.HtmlVersion }},
+	{"page_title", func(r *models.AnalyzeResult) any { return r.PageTitle }},
+	{"heading_count", func(r *models.AnalyzeResult) any { return headingCount(r.Headings) }},
+	{"internal_link_count", func(r *models.AnalyzeResult) any { return r.InternalLinkCount }},
+	{"external_link_count", func(r *models.AnalyzeResult) any { return r.ExternalLinkCount }},
+	{"accessible_links", func(r *models.AnalyzeResult) any { return r.AccessibleLinks }},
+	{"inaccessible_links", func(r *models.AnalyzeResult) any { return r.InaccessibleLinks }},
+	{"max_internal_link_depth", func(r *models.AnalyzeResult) any { return r.MaxInternalLinkDepth }},
+	{"has_login_form", func(r *models.AnalyzeResult) any { return r.HasLoginForm }},
+	{"nav_only_page", func(r *models.AnalyzeResult) any { return r.NavOnlyPage }},
+}
+
+// handleAnalyzeGroup handles the analyze group endpoint
+func (a *API) handleAnalyzeGroup(w http.ResponseWriter, r *http.Request, _ shift.Route) error {
+	ctx := r.Context()
+
+	var req AnalyzeGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return errors.Join(err, errors.New("failed to decode request"))
+	}
+
+	urls, err := validateGroupRequest(req, a.self)
+	if err != nil {
+		middleware.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return nil
+	}
+
+	if a.rejectIfOverloaded(w, r) {
+		return nil
+	}
+	if a.rejectIfURLRateLimited(w, r, urls...) {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	group := &models.Group{
+		ID:        generateID(),
+		Label:     strings.TrimSpace(req.Label),
+		Status:    models.GroupStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	jobs := make([]models.Job, 0, len(urls))
+	for _, u := range urls {
+		job := models.Job{
+			ID:        generateID(),
+			URL:       u,
+			Status:    models.JobStatusPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+			GroupID:   group.ID,
+		}
+		jobs = append(jobs, job)
+		group.JobIDs = append(group.JobIDs, job.ID)
+	}
+
+	// The group is stored first so the completion watcher can resolve it for any member update
+	if err := a.groupRepo.CreateGroup(ctx, group); err != nil {
+		return errors.Join(err, errors.New("failed to create group"))
+	}
+
+	resp := AnalyzeGroupResponse{Group: *group, Jobs: make([]models.Job, 0, len(jobs))}
+	for i, err := range a.submitJobs(ctx, jobs) {
+		if err != nil {
+			a.log.Error("Failed to submit group member",
+				slog.String("groupId", group.ID),
+				slog.String("jobId", jobs[i].ID),
+				slog.Any("error", err))
+			resp.Failed = append(resp.Failed, FailedGroupMember{URL: jobs[i].URL, Error: failedMemberError})
+			continue
+		}
+		resp.Jobs = append(resp.Jobs, jobs[i])
+	}
+	if len(resp.Jobs) == 0 {
+		middleware.WriteError(w, r, http.StatusInternalServerError, "Failed to submit the group members")
+		return nil
+	}
+
+	// The group keeps the queued members only, so its view and completion do not wait for the others
+	if len(resp.Failed) > 0 {
+		resp.Group.JobIDs = make([]string, 0, len(resp.Jobs))
+		for _, job := range resp.Jobs {
+			resp.Group.JobIDs = append(resp.Group.JobIDs, job.ID)
+		}
+		if err := a.groupRepo.SetGroupJobs(ctx, group.ID, resp.Group.JobIDs); err != nil {
+			a.log.Error("Failed to leave the failed members out of the group",
+				slog.String("groupId", group.ID),
+				slog.Any("error", err))
+		}
+	}
+
+	a.log.Info("Analysis group published",
+		slog.String("groupId", group.ID),
+		slog.Int("size", len(resp.Jobs)),
+		slog.Int("failed", len(resp.Failed)))
+
+	// Members that were queued run either way, so a partial failure still answers with the group
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// submitJobs submits jobs with at most submitConcurrency at a time, returning the error of each, nil when it was queued.
+// A job that fails is rolled back on its own, the others are still submitted.
+func (a *API) submitJobs(ctx context.Context, jobs []models.Job) []error {
+	errs := make([]error, len(jobs))
+	sem := make(chan struct{}, max(a.submitConcurrency, 1))
+	var wg sync.WaitGroup
+	for i := range jobs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			start := time.Now()
+			errs[i] = a.submitJob(ctx, &jobs[i])
+			if a.metrics != nil {
+				a.metrics.RecordJobCreation(errs[i] == nil, time.Since(start))
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// validateGroupRequest validates the group request and returns the normalized URLs
+func validateGroupRequest(req AnalyzeGroupRequest, self sharedconfig.SelfProtectionConfig) ([]string, error) {
+	if len(req.URLs) < minGroupSize || len(req.URLs) > maxGroupSize {
+		return nil, fmt.Errorf("a group must contain between %d and %d urls", minGroupSize, maxGroupSize)
+	}
+
+	if len(strings.TrimSpace(req.Label)) > maxGroupLabelLength {
+		return nil, fmt.Errorf("label too long (max %d characters)", maxGroupLabelLength)
+	}
+
+	urls := make([]string, 0, len(req.URLs))
+	seen := make(map[string]bool, len(req.URLs))
+	for _, raw := range req.URLs {
+		u, err := validateURL(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url %q: %w", raw, err)
+		}
+		if err := checkOwnHost(u, self); err != nil {
+			return nil, fmt.Errorf("invalid url %q: %w", raw, err)
+		}
+		if seen[u] {
+			return nil, fmt.Errorf("duplicate url %q", u)
+		}
+		seen[u] = true
+		urls = append(urls, u)
+	}
+
+	return urls, nil
+}
+
+// handleGetGroup handles the get group endpoint
+func (a *API) handleGetGroup(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+	ctx := r.Context()
+	groupID := route.Params.Get("group_id")
+
+	if strings.TrimSpace(groupID) == "" {
+		return errors.New("group_id is required")
+	}
+
+	group, err := a.groupRepo.GetGroup(ctx, groupID)
+	if errors.Is(err, repository.ErrGroupNotFound) {
+		middleware.WriteError(w, r, http.StatusNotFound, "Group not found")
+		return nil
+	}
+	if err != nil {
+		return errors.Join(err, errors.New("failed to get group"))
+	}
+
+	// Members purged from the trash are no longer found and are left out
+	jobs, err := a.jobRepo.GetJobsByIDs(ctx, group.JobIDs)
+	if err != nil {
+		return errors.Join(err, errors.New("failed to get group members"))
+	}
+
+	resp := GroupResponse{Group: *group, Members: make([]GroupMember, 0, len(jobs))}
+	for _, job := range jobs {
+		resp.Members = append(resp.Members, GroupMember{
+			JobID:   job.ID,
+			URL:     job.URL,
+			Status:  job.Status,
+			Summary: summarizeResult(job.Result),
+		})
+	}
+
+	if group.Status == models.GroupStatusCompleted {
+		resp.Comparison = compareResults(jobs)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// summarizeResult condenses a result for the group view, nil while the job has no result
+func summarizeResult(result *models.AnalyzeResult) *ResultSummary {
+	if result == nil {
+		return nil
+	}
+
+	return &ResultSummary{
+		HtmlVersion:       result.HtmlVersion,
+		PageTitle:         result.PageTitle,
+		InternalLinkCount: result.InternalLinkCount,
+		ExternalLinkCount: result.ExternalLinkCount,
+		InaccessibleLinks: result.InaccessibleLinks,
+		HasLoginForm:      result.HasLoginForm,
+		FailedTasks:       result.FailedTasks,
+	}
+}
+
+// compareResults builds the side-by-side comparison, members without a result are left out of each row
+func compareResults(jobs []*models.Job) *GroupComparison {
+	comparison := &GroupComparison{Metrics: make([]ComparisonMetric, 0, len(comparisonMetrics))}
+	for _, metric := range comparisonMetrics {
+		values := make(map[string]any, len(jobs))
+		for _, job := range jobs {
+			if job.Result != nil {
+				values[job.ID] = metric.value(job.Result)
+			}
+		}
+		comparison.Metrics = append(comparison.Metrics, ComparisonMetric{Name: metric.name, Values: values})
+	}
+	return comparison
+}
+
+// headingCount returns the total number of headings across all levels
+func headingCount(headings map[string]int) int {
+	total := 0
+	for _, count := range headings {
+		total += count
+	}
+	return total
+}
+
+// WatchGroups subscribes to job updates and marks groups completed once all of their members are terminal
+func (a *API) WatchGroups() (*nats.Subscription, error) {
+	return a.mb.SubscribeToJobUpdate(func(ctx context.Context, m *nats.Msg) {
+		var msg messagebus.JobUpdateMessage
+		if err := json.Unmarshal(m.Data, &msg); err != nil {
+			a.log.Error("Failed to unmarshal job update", slog.Any("error", err))
+			return
+		}
+
+		if err := a.checkGroupCompletion(ctx, msg.JobID, models.JobStatus(msg.Status)); err != nil {
+			a.log.Error("Failed to check group completion",
+				slog.String("jobId", msg.JobID),
+				slog.Any("error", err))
+		}
+	})
+}
+
+// checkGroupCompletion marks the job's group completed when the update leaves every member terminal
+func (a *API) checkGroupCompletion(ctx context.Context, jobID string, status models.JobStatus) error {
+	if !status.IsTerminal() {
+		return nil
+	}
+
+	job, err := a.jobRepo.GetJob(ctx, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to get job: %w", err)
+	}
+	if job.GroupID == "" {
+		return nil
+	}
+
+	group, err := a.groupRepo.GetGroup(ctx, job.GroupID)
+	if err != nil {
+		return fmt.Errorf("failed to get group: %w", err)
+	}
+	if group.Status == models.GroupStatusCompleted {
+		return nil
+	}
+
+	// A member purged from the trash is no longer found, it will not finish and is not waited for
+	otherIDs := slices.DeleteFunc(slices.Clone(group.JobIDs), func(id string) bool { return id == jobID })
+	members, err := a.jobRepo.GetJobsByIDs(ctx, otherIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get group members: %w", err)
+	}
+	for _, member := range members {
+		if !member.Status.IsTerminal() {
+			return nil
+		}
+	}
+
+	if err := a.groupRepo.MarkGroupCompleted(ctx, group.ID); err != nil {
+		return fmt.Errorf("failed to mark group completed: %w", err)
+	}
+
+	a.log.Info("Analysis group completed", slog.String("groupId", group.ID))
+	return nil
+}
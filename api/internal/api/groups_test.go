@@ -0,0 +1,360 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"shared/messagebus"
+	"shared/mocks"
+	"shared/models"
+	"shared/repository"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+// setupMockGroupAPI creates an API instance with a mocked group repository on top of the default mocks
+func setupMockGroupAPI(t *testing.T) (*API, *mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface, *mocks.MockGroupRepositoryInterface, *mocks.MockMessageBusInterface) {
+	api, mockJobRepo, mockTaskRepo, mockMessageBus, ctrl := setupMockAPI(t)
+	mockGroupRepo := mocks.NewMockGroupRepositoryInterface(ctrl)
+	api.groupRepo = mockGroupRepo
+	return api, mockJobRepo, mockTaskRepo, mockGroupRepo, mockMessageBus
+}
+
+func TestAPI_HandleAnalyzeGroup_TableDriven(t *testing.T) {
+	testCases := []struct {
+		name           string
+		body           any
+		setupMocks     func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface, *mocks.MockGroupRepositoryInterface, *mocks.MockMessageBusInterface)
+		expectedStatus int
+		expectedJobs   int
+		expectedFailed int
+		description    string
+	}{
+		{
+			name: "SuccessfulGroup",
+			body: AnalyzeGroupRequest{URLs: []string{"https://example.com", "competitor.com"}, Label: "Q3 comparison"},
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, groupRepo *mocks.MockGroupRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				groupRepo.EXPECT().CreateGroup(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, g *models.Group) error {
+					assert.Len(t, g.JobIDs, 2)
+					assert.Equal(t, models.GroupStatusPending, g.Status)
+					return nil
+				})
+				jobRepo.EXPECT().CreateJob(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, j *models.Job) error {
+					assert.NotEmpty(t, j.GroupID)
+					return nil
+				}).Times(2)
+				taskRepo.EXPECT().CreateTasks(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+				mb.EXPECT().PublishAnalyzeMessage(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedJobs:   2,
+			description:    "Create a group record and one job per URL",
+		},
+		{
+			name: "TooFewURLs",
+			body: AnalyzeGroupRequest{URLs: []string{"https://example.com"}},
+			setupMocks: func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface, *mocks.MockGroupRepositoryInterface, *mocks.MockMessageBusInterface) {
+			},
+			expectedStatus: http.StatusBadRequest,
+			description:    "Reject groups below the minimum size",
+		},
+		{
+			name: "TooManyURLs",
+			body: AnalyzeGroupRequest{URLs: []string{
+				"https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com", "https://f.com",
+			}},
+			setupMocks: func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface, *mocks.MockGroupRepositoryInterface, *mocks.MockMessageBusInterface) {
+			},
+			expectedStatus: http.StatusBadRequest,
+			description:    "Reject groups above the maximum size",
+		},
+		{
+			name: "InvalidMemberURL",
+			body: AnalyzeGroupRequest{URLs: []string{"https://example.com", "http://localhost"}},
+			setupMocks: func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface, *mocks.MockGroupRepositoryInterface, *mocks.MockMessageBusInterface) {
+			},
+			expectedStatus: http.StatusBadRequest,
+			description:    "Apply the single URL validation to every member",
+		},
+		{
+			name: "DuplicateURLs",
+			body: AnalyzeGroupRequest{URLs: []string{"example.com", "https://example.com"}},
+			setupMocks: func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface, *mocks.MockGroupRepositoryInterface, *mocks.MockMessageBusInterface) {
+			},
+			expectedStatus: http.StatusBadRequest,
+			description:    "Reject URLs that normalize to the same address",
+		},
+		{
+			name: "MemberFails",
+			body: AnalyzeGroupRequest{URLs: []string{"https://example.com", "https://example.org", "https://example.net"}},
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, groupRepo *mocks.MockGroupRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				groupRepo.EXPECT().CreateGroup(gomock.Any(), gomock.Any()).Return(nil)
+				jobRepo.EXPECT().CreateJob(gomock.Any(), gomock.Any()).Return(nil).Times(3)
+				taskRepo.EXPECT().CreateTasks(gomock.Any(), gomock.Any()).Return(nil).Times(3)
+				var published atomic.Int32
+				mb.EXPECT().PublishAnalyzeMessage(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, messagebus.AnalyzeMessage) error {
+					if published.Add(1) == 1 {
+						return errors.New("nats unavailable")
+					}
+					return nil
+				}).Times(3)
+				jobRepo.EXPECT().DiscardJob(gomock.Any(), gomock.Any()).Return(true, nil)
+				taskRepo.EXPECT().DeleteTasksByJobId(gomock.Any(), gomock.Any()).Return(nil)
+				groupRepo.EXPECT().SetGroupJobs(gomock.Any(), gomock.Any(), gomock.Len(2)).Return(nil)
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedJobs:   2,
+			expectedFailed: 1,
+			description:    "Submit the other members when one cannot be queued, rolling back only that one",
+		},
+		{
+			name: "AllMembersFail",
+			body: AnalyzeGroupRequest{URLs: []string{"https://example.com", "https://example.org"}},
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, groupRepo *mocks.MockGroupRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				groupRepo.EXPECT().CreateGroup(gomock.Any(), gomock.Any()).Return(nil)
+				jobRepo.EXPECT().CreateJob(gomock.Any(), gomock.Any()).Return(errors.New("dynamodb throttled")).Times(2)
+				jobRepo.EXPECT().DiscardJob(gomock.Any(), gomock.Any()).Return(false, nil).Times(2)
+			},
+			expectedStatus: http.StatusInternalServerError,
+			description:    "Fail the request when no member could be queued",
+		},
+		{
+			name: "GroupRepositoryError",
+			body: AnalyzeGroupRequest{URLs: []string{"https://example.com", "https://example.org"}},
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, groupRepo *mocks.MockGroupRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				groupRepo.EXPECT().CreateGroup(gomock.Any(), gomock.Any()).Return(errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			description:    "Do not submit jobs when the group cannot be stored",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, mockTaskRepo, mockGroupRepo, mockMessageBus := setupMockGroupAPI(t)
+			tc.setupMocks(mockJobRepo, mockTaskRepo, mockGroupRepo, mockMessageBus)
+
+			req, err := makeRequest("POST", "/analyze/group", tc.body)
+			assert.NoError(t, err, "Failed to create request")
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("POST", "/analyze/group", api.handleAnalyzeGroup)
+			router.Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code, tc.description)
+			assert.NotContains(t, rr.Body.String(), "nats unavailable")
+			assert.NotContains(t, rr.Body.String(), "dynamodb throttled")
+			if tc.expectedStatus == http.StatusAccepted {
+				var resp AnalyzeGroupResponse
+				assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+				assert.Len(t, resp.Jobs, tc.expectedJobs)
+				assert.Len(t, resp.Failed, tc.expectedFailed)
+				assert.Len(t, resp.Group.JobIDs, tc.expectedJobs, "the group keeps the queued members only")
+				for _, job := range resp.Jobs {
+					assert.Contains(t, resp.Group.JobIDs, job.ID)
+				}
+				for _, failed := range resp.Failed {
+					assert.NotEmpty(t, failed.URL)
+					assert.Equal(t, failedMemberError, failed.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestAPI_SubmitJobs_BoundsConcurrency(t *testing.T) {
+	api, jobRepo, taskRepo, _, mb := setupMockGroupAPI(t)
+	WithSubmitConcurrency(2)(api)
+
+	var running, peak atomic.Int32
+	jobRepo.EXPECT().CreateJob(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, *models.Job) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}).Times(5)
+	taskRepo.EXPECT().CreateTasks(gomock.Any(), gomock.Any()).Return(nil).Times(5)
+	mb.EXPECT().PublishAnalyzeMessage(gomock.Any(), gomock.Any()).Return(nil).Times(5)
+
+	jobs := make([]models.Job, 5)
+	for i := range jobs {
+		jobs[i] = models.Job{ID: generateID(), Status: models.JobStatusPending}
+	}
+	for _, err := range api.submitJobs(context.Background(), jobs) {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), peak.Load())
+}
+
+func TestAPI_HandleGetGroup_TableDriven(t *testing.T) {
+	result := &models.AnalyzeResult{HtmlVersion: "HTML5", PageTitle: "Example", Headings: map[string]int{"h1": 1, "h2": 3}, InternalLinkCount: 4}
+	jobs := map[string]*models.Job{
+		"job-1": {ID: "job-1", URL: "https://example.com", Status: models.JobStatusCompleted, Result: result, GroupID: "group-1"},
+		"job-2": {ID: "job-2", URL: "https://example.org", Status: models.JobStatusFailed, GroupID: "group-1"},
+		"job-3": {ID: "job-3", URL: "https://example.net", Status: models.JobStatusRunning, GroupID: "group-2"},
+	}
+
+	testCases := []struct {
+		name               string
+		groupID            string
+		group              *models.Group
+		groupErr           error
+		expectedStatus     int
+		expectedMembers    int
+		expectedComparison bool
+	}{
+		{
+			name:               "CompletedGroup",
+			groupID:            "group-1",
+			group:              &models.Group{ID: "group-1", JobIDs: []string{"job-1", "job-2"}, Status: models.GroupStatusCompleted},
+			expectedStatus:     http.StatusOK,
+			expectedMembers:    2,
+			expectedComparison: true,
+		},
+		{
+			name:            "PendingGroup",
+			groupID:         "group-2",
+			group:           &models.Group{ID: "group-2", JobIDs: []string{"job-3"}, Status: models.GroupStatusPending},
+			expectedStatus:  http.StatusOK,
+			expectedMembers: 1,
+		},
+		{
+			name:            "PurgedMember",
+			groupID:         "group-2",
+			group:           &models.Group{ID: "group-2", JobIDs: []string{"job-3", "job-purged"}, Status: models.GroupStatusPending},
+			expectedStatus:  http.StatusOK,
+			expectedMembers: 1,
+		},
+		{
+			name:           "GroupNotFound",
+			groupID:        "missing",
+			groupErr:       repository.ErrGroupNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "DatabaseError",
+			groupID:        "group-1",
+			groupErr:       errors.New("database error"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, _, mockGroupRepo, _ := setupMockGroupAPI(t)
+			mockGroupRepo.EXPECT().GetGroup(gomock.Any(), tc.groupID).Return(tc.group, tc.groupErr)
+			if tc.group != nil {
+				members := make([]*models.Job, 0, len(tc.group.JobIDs))
+				for _, id := range tc.group.JobIDs {
+					if job, ok := jobs[id]; ok {
+						members = append(members, job)
+					}
+				}
+				mockJobRepo.EXPECT().GetJobsByIDs(gomock.Any(), tc.group.JobIDs).Return(members, nil)
+			}
+
+			req, err := makeRequest("GET", "/groups/"+tc.groupID, nil)
+			assert.NoError(t, err, "Failed to create request")
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("GET", "/groups/:group_id", api.handleGetGroup)
+			router.Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var resp GroupResponse
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Len(t, resp.Members, tc.expectedMembers)
+			if !tc.expectedComparison {
+				assert.Nil(t, resp.Comparison)
+				return
+			}
+
+			assert.NotNil(t, resp.Members[0].Summary)
+			assert.Nil(t, resp.Members[1].Summary, "failed members have no summary")
+			metrics := make(map[string]map[string]any)
+			for _, m := range resp.Comparison.Metrics {
+				metrics[m.Name] = m.Values
+			}
+			assert.Equal(t, "HTML5", metrics["html_version"]["job-1"])
+			assert.Equal(t, float64(4), metrics["heading_count"]["job-1"])
+			assert.NotContains(t, metrics["html_version"], "job-2")
+		})
+	}
+}
+
+func TestAPI_CheckGroupCompletion_TableDriven(t *testing.T) {
+	group := &models.Group{ID: "group-1", JobIDs: []string{"job-1", "job-2"}, Status: models.GroupStatusPending}
+
+	testCases := []struct {
+		name         string
+		status       models.JobStatus
+		job          *models.Job
+		group        *models.Group
+		otherStatus  models.JobStatus
+		expectMarked bool
+	}{
+		{
+			name:   "NonTerminalUpdate",
+			status: models.JobStatusRunning,
+		},
+		{
+			name:   "UngroupedJob",
+			status: models.JobStatusCompleted,
+			job:    &models.Job{ID: "job-1", Status: models.JobStatusCompleted},
+		},
+		{
+			name:        "OtherMemberRunning",
+			status:      models.JobStatusCompleted,
+			job:         &models.Job{ID: "job-1", Status: models.JobStatusCompleted, GroupID: "group-1"},
+			group:       group,
+			otherStatus: models.JobStatusRunning,
+		},
+		{
+			name:         "AllMembersTerminal",
+			status:       models.JobStatusCompleted,
+			job:          &models.Job{ID: "job-1", Status: models.JobStatusCompleted, GroupID: "group-1"},
+			group:        group,
+			otherStatus:  models.JobStatusFailed,
+			expectMarked: true,
+		},
+		{
+			name:   "GroupAlreadyCompleted",
+			status: models.JobStatusFailed,
+			job:    &models.Job{ID: "job-1", Status: models.JobStatusFailed, GroupID: "group-1"},
+			group:  &models.Group{ID: "group-1", JobIDs: []string{"job-1", "job-2"}, Status: models.GroupStatusCompleted},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, _, mockGroupRepo, _ := setupMockGroupAPI(t)
+			if tc.job != nil {
+				mockJobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(tc.job, nil)
+			}
+			if tc.group != nil {
+				mockGroupRepo.EXPECT().GetGroup(gomock.Any(), "group-1").Return(tc.group, nil)
+			}
+			if tc.otherStatus != "" {
+				mockJobRepo.EXPECT().GetJobsByIDs(gomock.Any(), []string{"job-2"}).Return([]*models.Job{{ID: "job-2", Status: tc.otherStatus}}, nil)
+			}
+			if tc.expectMarked {
+				mockGroupRepo.EXPECT().MarkGroupCompleted(gomock.Any(), "group-1").Return(nil)
+			}
+
+			err := api.checkGroupCompletion(context.Background(), "job-1", tc.status)
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -1,12 +1,19 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
+	"shared/audit"
+	"shared/log"
 	"shared/messagebus"
+	"shared/middleware"
 	"shared/models"
+	"shared/repository"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -33,14 +40,43 @@ func (a *API) handleAnalyze(w http.ResponseWriter, r *http.Request, _ shift.Rout
 	// Validate and normalize the URL
 	validatedURL, err := validateURL(req.URL)
 	if err != nil {
-		http.Error(w, "Invalid URL, please check the URL and try again.", http.StatusBadRequest)
+		middleware.WriteError(w, r, http.StatusBadRequest, "Invalid URL, please check the URL and try again.")
+		return nil
+	}
+	if err := checkOwnHost(validatedURL, a.self); err != nil {
+		middleware.WriteError(w, r, http.StatusBadRequest, "The URL points to this service, its own pages cannot be analyzed.")
+		return nil
+	}
+
+	tasks, err := validateTaskSelection(req.Tasks)
+	if err != nil {
+		middleware.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return nil
+	}
+
+	verifyScope, err := models.ParseLinkScope(strings.TrimSpace(req.VerifyScope))
+	if err != nil {
+		middleware.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return nil
+	}
+
+	headerProfile, err := models.ParseHeaderProfile(strings.TrimSpace(req.HeaderProfile))
+	if err != nil {
+		middleware.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return nil
+	}
+
+	if a.rejectIfOverloaded(w, r) {
+		return nil
+	}
+	if a.rejectIfURLRateLimited(w, r, validatedURL) {
 		return nil
 	}
 
 	jobID := generateID()
 	a.log.Info("Creating new analysis job",
 		slog.String("jobId", jobID),
-		slog.String("url", validatedURL))
+		log.URL("url", validatedURL))
 
 	job := &models.Job{
 		ID:        jobID,
@@ -48,48 +84,172 @@ func (a *API) handleAnalyze(w http.ResponseWriter, r *http.Request, _ shift.Rout
 		Status:    models.JobStatusPending,
 		CreatedAt: time.Now().UTC(),
 		UpdatedAt: time.Now().UTC(),
+		Tasks:     tasks,
+
+		VerifyScope:   verifyScope,
+		HeaderProfile: headerProfile,
+	}
+
+	if err := a.submitJob(ctx, job); err != nil {
+		return err
 	}
 
+	a.log.Info("Analysis request published",
+		slog.String("jobId", jobID),
+		log.URL("url", validatedURL),
+		slog.Duration("duration", time.Since(start)))
+
+	success = true
+	w.WriteHeader(http.StatusAccepted)
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(AnalyzeResponse{
+		Job:                        *job,
+		SkippedTasks:               job.SkippedTasks(),
+		EstimatedStartDelaySeconds: a.startDelayHint(),
+	})
+}
+
+// rollbackTimeout bounds undoing a job that could not be queued, which runs even when the request was cancelled
+const rollbackTimeout = 5 * time.Second
+
+// submitJob persists a new job with its default tasks and queues it for analysis.
+// When any step fails, including the request being cancelled by a shutdown, the job is rolled back
+// so no pending job is left behind that the analyzer was never asked to run.
+func (a *API) submitJob(ctx context.Context, job *models.Job) (err error) {
+	if !a.beginSubmission() {
+		a.releaseURLRate(job.URL)
+		return errShuttingDown
+	}
+	defer a.submissions.Done()
+
+	// A create that failed may still have been written, the rollback tells the two apart.
+	// The job no longer counts against its URL either way, a job that was not queued should not hold up the next.
+	defer func() {
+		if err != nil {
+			a.rollbackJob(ctx, job)
+			a.releaseURLRate(job.URL)
+		}
+	}()
+
 	if err := a.jobRepo.CreateJob(ctx, job); err != nil {
 		return errors.Join(err, errors.New("failed to create job"))
 	}
+	a.audit.Record(ctx, audit.Record{
+		Event:  audit.EventJobCreated,
+		JobID:  job.ID,
+		URL:    job.URL,
+		Status: string(job.Status),
+	})
 
-	defaultTasks := getDefaultTasks(jobID)
+	defaultTasks := getDefaultTasks(job)
 	if err := a.taskRepo.CreateTasks(ctx, defaultTasks...); err != nil {
 		return errors.Join(err, errors.New("failed to create tasks"))
 	}
 
+	// Publishing ignores cancellation, so check for it first rather than queue a job whose request was abandoned
+	if err := ctx.Err(); err != nil {
+		return errors.Join(err, errors.New("request cancelled before the job was queued"))
+	}
 	if err := a.mb.PublishAnalyzeMessage(ctx, messagebus.AnalyzeMessage{
-		Type:  messagebus.AnalyzeMessageType,
-		JobId: jobID,
+		Type:      messagebus.AnalyzeMessageType,
+		JobId:     job.ID,
+		Source:    audit.SourceFromContext(ctx),
+		AttemptID: generateID(),
 	}); err != nil {
 		return errors.Join(err, errors.New("failed to publish analyze message"))
 	}
 
-	a.log.Info("Analysis request published",
-		slog.String("jobId", jobID),
-		slog.String("url", validatedURL),
-		slog.Duration("duration", time.Since(start)))
+	return nil
+}
 
-	success = true
-	w.WriteHeader(http.StatusAccepted)
-	w.Header().Set("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(AnalyzeResponse{Job: *job})
+// rollbackJob undoes a job that could not be queued, removing the job and then its tasks.
+// The job is only removed while still pending, a job the analyzer picked up keeps its tasks.
+// It runs apart from ctx, which may well be why the submission failed.
+func (a *API) rollbackJob(ctx context.Context, job *models.Job) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+
+	discarded, err := a.jobRepo.DiscardJob(ctx, job.ID)
+	if err != nil {
+		a.log.Error("Failed to roll back job, it is left pending",
+			slog.String("jobId", job.ID),
+			slog.Any("error", err))
+		return
+	}
+	if !discarded {
+		return
+	}
+
+	if err := a.taskRepo.DeleteTasksByJobId(ctx, job.ID); err != nil {
+		a.log.Warn("Failed to delete tasks of rolled back job",
+			slog.String("jobId", job.ID),
+			slog.Any("error", err))
+	}
+	a.audit.Record(ctx, audit.Record{Event: audit.EventJobDiscarded, JobID: job.ID, URL: job.URL, Status: string(job.Status)})
+	a.log.Info("Rolled back job that could not be queued", slog.String("jobId", job.ID))
 }
 
-// handleGetJobs handles the get jobs endpoint
+// handleGetJobs handles the get jobs endpoint.
+// Deleted jobs are left out unless an admin asks for them with include_deleted=true.
 func (a *API) handleGetJobs(w http.ResponseWriter, r *http.Request, route shift.Route) error {
 	ctx := r.Context()
 
+	includeDeleted := false
+	if raw := r.URL.Query().Get("include_deleted"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			middleware.WriteError(w, r, http.StatusBadRequest, "invalid include_deleted")
+			return nil
+		}
+		includeDeleted = parsed
+	}
+	if includeDeleted && !middleware.IsAdminRequest(r, a.adminToken) {
+		middleware.WriteError(w, r, http.StatusForbidden, "include_deleted requires the admin token")
+		return nil
+	}
+
 	jobs, err := a.jobRepo.GetAllJobs(ctx)
 	if err != nil {
 		return errors.Join(err, errors.New("failed to get jobs"))
 	}
 
+	if !includeDeleted {
+		jobs = slices.DeleteFunc(jobs, (*models.Job).IsDeleted)
+	}
+
+	// The status history is only served on the single job endpoint to keep the list small
+	for _, job := range jobs {
+		job.StatusHistory = nil
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(jobs)
 }
 
+// handleGetJob handles the get job endpoint
+func (a *API) handleGetJob(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+	ctx := r.Context()
+	jobID := route.Params.Get("job_id")
+
+	if strings.TrimSpace(jobID) == "" {
+		return errors.New("job_id is required")
+	}
+
+	job, err := a.getJob(ctx, jobID)
+	if errors.Is(err, repository.ErrJobNotFound) {
+		middleware.WriteError(w, r, http.StatusNotFound, "Job not found")
+		return nil
+	}
+	if err != nil {
+		return errors.Join(err, errors.New("failed to get job"))
+	}
+
+	// The version changes with every update of the job, so it identifies this representation
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(job.Version, 10)))
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(job)
+}
+
 // handleGetTasksByJobID handles the get tasks by job ID endpoint
 func (a *API) handleGetTasksByJobID(w http.ResponseWriter, r *http.Request, route shift.Route) error {
 	ctx := r.Context()
@@ -99,11 +259,35 @@ func (a *API) handleGetTasksByJobID(w http.ResponseWriter, r *http.Request, rout
 		return errors.New("job_id is required")
 	}
 
+	job, err := a.jobRepo.GetJob(ctx, jobID)
+	if errors.Is(err, repository.ErrJobNotFound) {
+		middleware.WriteError(w, r, http.StatusNotFound, "Job not found")
+		return nil
+	}
+	if err != nil {
+		return errors.Join(err, errors.New("failed to get job"))
+	}
+	if rejectDeletedJob(w, r, job) {
+		return nil
+	}
+
 	tasks, err := a.taskRepo.GetTasksByJobId(ctx, jobID)
 	if err != nil {
 		return errors.Join(err, errors.New("failed to get tasks"))
 	}
 
+	// Stored tasks of an unregistered type have no meaning to clients, so they are left out
+	known := make([]models.Task, 0, len(tasks))
+	for _, task := range tasks {
+		if !models.IsValidTaskType(task.Type) {
+			a.log.Warn("Ignoring task with unknown type",
+				slog.String("jobId", jobID),
+				slog.String("taskType", string(task.Type)))
+			continue
+		}
+		known = append(known, task)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(tasks)
+	return json.NewEncoder(w).Encode(known)
 }
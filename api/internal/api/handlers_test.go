@@ -1,20 +1,27 @@
 package api
 
 import (
+	"api/internal/config"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"shared/audit"
+	sharedconfig "shared/config"
+	sharedlog "shared/log"
 	"shared/middleware"
 	"shared/mocks"
 	"shared/models"
+	"shared/repository"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/yousuf64/shift"
 	"go.uber.org/mock/gomock"
 )
@@ -46,6 +53,15 @@ func setupMockAPI(t *testing.T) (*API, *mocks.MockJobRepositoryInterface, *mocks
 		mb:       mockMessageBus,
 		metrics:  nil,
 		log:      slog.New(slog.DiscardHandler),
+
+		restoreWindow:       defaultRestoreWindow,
+		loads:               make(map[string]loadReport),
+		loadStaleAfter:      defaultLoadStaleAfter,
+		heartbeatStaleAfter: defaultHeartbeatStaleAfter,
+		submitConcurrency:   defaultSubmitConcurrency,
+
+		maxEventStreams:        defaultMaxEventStreams,
+		eventHeartbeatInterval: eventHeartbeatInterval,
 	}
 
 	return api, mockJobRepo, mockTaskRepo, mockMessageBus, ctrl
@@ -379,6 +395,7 @@ func TestAPI_HandleAnalyze_TableDriven(t *testing.T) {
 			},
 			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, mb *mocks.MockMessageBusInterface) {
 				jobRepo.EXPECT().CreateJob(gomock.Any(), gomock.Any()).Return(errors.New("database error"))
+				jobRepo.EXPECT().DiscardJob(gomock.Any(), gomock.Any()).Return(false, nil)
 			},
 			expectedError: true,
 			description:   "Handle database errors during job creation",
@@ -393,6 +410,8 @@ func TestAPI_HandleAnalyze_TableDriven(t *testing.T) {
 			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, mb *mocks.MockMessageBusInterface) {
 				jobRepo.EXPECT().CreateJob(gomock.Any(), gomock.Any()).Return(nil)
 				taskRepo.EXPECT().CreateTasks(gomock.Any(), gomock.Any()).Return(errors.New("task creation failed"))
+				jobRepo.EXPECT().DiscardJob(gomock.Any(), gomock.Any()).Return(true, nil)
+				taskRepo.EXPECT().DeleteTasksByJobId(gomock.Any(), gomock.Any()).Return(nil)
 			},
 			expectedError: true,
 			description:   "Handle task creation errors",
@@ -408,6 +427,8 @@ func TestAPI_HandleAnalyze_TableDriven(t *testing.T) {
 				jobRepo.EXPECT().CreateJob(gomock.Any(), gomock.Any()).Return(nil)
 				taskRepo.EXPECT().CreateTasks(gomock.Any(), gomock.Any()).Return(nil)
 				mb.EXPECT().PublishAnalyzeMessage(gomock.Any(), gomock.Any()).Return(errors.New("message bus error"))
+				jobRepo.EXPECT().DiscardJob(gomock.Any(), gomock.Any()).Return(true, nil)
+				taskRepo.EXPECT().DeleteTasksByJobId(gomock.Any(), gomock.Any()).Return(nil)
 			},
 			expectedError: true,
 			description:   "Handle message bus publishing errors",
@@ -446,6 +467,275 @@ func TestAPI_HandleAnalyze_TableDriven(t *testing.T) {
 	}
 }
 
+func TestValidateTaskSelection(t *testing.T) {
+	testCases := []struct {
+		name        string
+		tasks       []string
+		expected    []models.TaskType
+		expectedErr bool
+	}{
+		{name: "NoSelection"},
+		{
+			name:  "AllTasks",
+			tasks: []string{"verifying_links", "analyzing", "identifying_version", "extracting"},
+		},
+		{
+			name:     "ExtractingAlwaysIncluded",
+			tasks:    []string{"identifying_version"},
+			expected: []models.TaskType{models.TaskTypeExtracting, models.TaskTypeIdentifyingVersion},
+		},
+		{
+			name:     "RunOrder",
+			tasks:    []string{"analyzing", "extracting"},
+			expected: []models.TaskType{models.TaskTypeExtracting, models.TaskTypeAnalyzing},
+		},
+		{name: "UnknownTask", tasks: []string{"screenshot"}, expectedErr: true},
+		{name: "LinksWithoutAnalyzing", tasks: []string{"verifying_links"}, expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			selected, err := validateTaskSelection(tc.tasks)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, selected)
+		})
+	}
+}
+
+func TestAPI_HandleAnalyze_TaskSelection(t *testing.T) {
+	api, mockJobRepo, mockTaskRepo, mockMessageBus, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+
+	var createdJob *models.Job
+	mockJobRepo.EXPECT().CreateJob(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, job *models.Job) error {
+		createdJob = job
+		return nil
+	})
+	var createdTasks []*models.Task
+	mockTaskRepo.EXPECT().CreateTasks(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, tasks ...*models.Task) error {
+		createdTasks = tasks
+		return nil
+	})
+	mockMessageBus.EXPECT().PublishAnalyzeMessage(gomock.Any(), gomock.Any()).Return(nil)
+
+	req, err := makeRequest("POST", "/analyze", AnalyzeRequest{URL: "https://example.com", Tasks: []string{"identifying_version"}})
+	assert.NoError(t, err, "Failed to create request")
+
+	rr := httptest.NewRecorder()
+	router := setupRouter("POST", "/analyze", api.handleAnalyze)
+	router.Serve().ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+
+	var resp AnalyzeResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, []models.TaskType{models.TaskTypeAnalyzing, models.TaskTypeVerifyingLinks}, resp.SkippedTasks)
+
+	if assert.NotNil(t, createdJob) {
+		assert.Equal(t, []models.TaskType{models.TaskTypeExtracting, models.TaskTypeIdentifyingVersion}, createdJob.Tasks)
+	}
+
+	statuses := make(map[models.TaskType]models.TaskStatus)
+	for _, task := range createdTasks {
+		statuses[task.Type] = task.Status
+	}
+	assert.Equal(t, map[models.TaskType]models.TaskStatus{
+		models.TaskTypeExtracting:         models.TaskStatusPending,
+		models.TaskTypeIdentifyingVersion: models.TaskStatusPending,
+		models.TaskTypeAnalyzing:          models.TaskStatusSkipped,
+		models.TaskTypeVerifyingLinks:     models.TaskStatusSkipped,
+	}, statuses)
+}
+
+func TestAPI_HandleAnalyze_RedactsLoggedURL(t *testing.T) {
+	defer sharedlog.SetRedactURLs(sharedlog.RedactsURLs())
+	sharedlog.SetRedactURLs(true)
+
+	api, mockJobRepo, mockTaskRepo, mockMessageBus, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+
+	var logs bytes.Buffer
+	api.log = slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	api.audit = audit.NewLogger(&logs, "api")
+
+	var createdJob *models.Job
+	mockJobRepo.EXPECT().CreateJob(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, job *models.Job) error {
+		createdJob = job
+		return nil
+	})
+	mockTaskRepo.EXPECT().CreateTasks(gomock.Any(), gomock.Any()).Return(nil)
+	mockMessageBus.EXPECT().PublishAnalyzeMessage(gomock.Any(), gomock.Any()).Return(nil)
+
+	req, err := makeRequest("POST", "/analyze", AnalyzeRequest{URL: "https://example.com/report?token=s3cret&page=2"})
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	router := setupRouter("POST", "/analyze", api.handleAnalyze)
+	router.Serve().ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	// The job keeps the URL as submitted, only its logging is redacted
+	require.NotNil(t, createdJob)
+	assert.Contains(t, createdJob.URL, "token=s3cret")
+	assert.Contains(t, logs.String(), "https://example.com/report?token=\u0026page=")
+	assert.NotContains(t, logs.String(), "s3cret")
+}
+
+func TestAPI_HandleAnalyze_OwnHost(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		body     any
+		handler  func(api *API) shift.HandlerFunc
+		expected string
+	}{
+		{
+			name:     "Analyze",
+			path:     "/analyze",
+			body:     AnalyzeRequest{URL: "https://analyzer.example.com/jobs"},
+			handler:  func(api *API) shift.HandlerFunc { return api.handleAnalyze },
+			expected: "its own pages cannot be analyzed",
+		},
+		{
+			name:     "AnalyzeGroup",
+			path:     "/analyze/group",
+			body:     AnalyzeGroupRequest{URLs: []string{"https://example.org", "https://Analyzer.Example.com/"}},
+			handler:  func(api *API) shift.HandlerFunc { return api.handleAnalyzeGroup },
+			expected: errOwnHost.Error(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// No job is created, the mocks expect no call
+			api, _, _, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+			WithSelfProtection(sharedconfig.SelfProtectionConfig{OwnHosts: []string{"analyzer.example.com"}})(api)
+
+			req, err := makeRequest("POST", tc.path, tc.body)
+			require.NoError(t, err)
+			rr := httptest.NewRecorder()
+			setupRouter("POST", tc.path, tc.handler(api)).Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Contains(t, rr.Body.String(), tc.expected)
+		})
+	}
+}
+
+func TestAPI_HandleAnalyze_InvalidTaskSelection(t *testing.T) {
+	api, _, _, _, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+
+	req, err := makeRequest("POST", "/analyze", AnalyzeRequest{URL: "https://example.com", Tasks: []string{"screenshot"}})
+	assert.NoError(t, err, "Failed to create request")
+
+	rr := httptest.NewRecorder()
+	router := setupRouter("POST", "/analyze", api.handleAnalyze)
+	router.Serve().ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "unknown task")
+}
+
+func TestAPI_HandleAnalyze_VerifyScope(t *testing.T) {
+	testCases := []struct {
+		name          string
+		scope         string
+		expectedCode  int
+		expectedScope models.LinkScope
+	}{
+		{name: "Default", expectedCode: http.StatusAccepted},
+		{name: "Internal", scope: "internal", expectedCode: http.StatusAccepted, expectedScope: models.LinkScopeInternal},
+		{name: "External", scope: " external ", expectedCode: http.StatusAccepted, expectedScope: models.LinkScopeExternal},
+		{name: "Invalid", scope: "outbound", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, mockTaskRepo, mockMessageBus, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+
+			var createdJob *models.Job
+			if tc.expectedCode == http.StatusAccepted {
+				mockJobRepo.EXPECT().CreateJob(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, job *models.Job) error {
+					createdJob = job
+					return nil
+				})
+				mockTaskRepo.EXPECT().CreateTasks(gomock.Any(), gomock.Any()).Return(nil)
+				mockMessageBus.EXPECT().PublishAnalyzeMessage(gomock.Any(), gomock.Any()).Return(nil)
+			}
+
+			req, err := makeRequest("POST", "/analyze", AnalyzeRequest{URL: "https://example.com", VerifyScope: tc.scope})
+			assert.NoError(t, err, "Failed to create request")
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("POST", "/analyze", api.handleAnalyze)
+			router.Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedCode, rr.Code)
+			if tc.expectedCode != http.StatusAccepted {
+				assert.Contains(t, rr.Body.String(), "invalid link scope")
+				return
+			}
+			if assert.NotNil(t, createdJob) {
+				assert.Equal(t, tc.expectedScope, createdJob.VerifyScope)
+			}
+		})
+	}
+}
+
+func TestAPI_HandleAnalyze_HeaderProfile(t *testing.T) {
+	testCases := []struct {
+		name            string
+		profile         string
+		expectedCode    int
+		expectedProfile models.HeaderProfile
+	}{
+		{name: "Default", expectedCode: http.StatusAccepted},
+		{name: "Browser", profile: "browser", expectedCode: http.StatusAccepted, expectedProfile: models.HeaderProfileBrowser},
+		{name: "Fallback", profile: " fallback ", expectedCode: http.StatusAccepted, expectedProfile: models.HeaderProfileFallback},
+		{name: "Invalid", profile: "crawler", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, mockTaskRepo, mockMessageBus, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+
+			var createdJob *models.Job
+			if tc.expectedCode == http.StatusAccepted {
+				mockJobRepo.EXPECT().CreateJob(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, job *models.Job) error {
+					createdJob = job
+					return nil
+				})
+				mockTaskRepo.EXPECT().CreateTasks(gomock.Any(), gomock.Any()).Return(nil)
+				mockMessageBus.EXPECT().PublishAnalyzeMessage(gomock.Any(), gomock.Any()).Return(nil)
+			}
+
+			req, err := makeRequest("POST", "/analyze", AnalyzeRequest{URL: "https://example.com", HeaderProfile: tc.profile})
+			assert.NoError(t, err, "Failed to create request")
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("POST", "/analyze", api.handleAnalyze)
+			router.Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedCode, rr.Code)
+			if tc.expectedCode != http.StatusAccepted {
+				assert.Contains(t, rr.Body.String(), "invalid header profile")
+				return
+			}
+			if assert.NotNil(t, createdJob) {
+				assert.Equal(t, tc.expectedProfile, createdJob.HeaderProfile)
+			}
+		})
+	}
+}
+
 func TestAPI_HandleGetJobs_TableDriven(t *testing.T) {
 	testJobs := []*models.Job{
 		{
@@ -454,6 +744,10 @@ func TestAPI_HandleGetJobs_TableDriven(t *testing.T) {
 			Status:    models.JobStatusCompleted,
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
+			StatusHistory: []models.StatusChange{
+				{Status: models.JobStatusPending, At: time.Now()},
+				{Status: models.JobStatusCompleted, At: time.Now()},
+			},
 		},
 		{
 			ID:        "job-2",
@@ -530,6 +824,93 @@ func TestAPI_HandleGetJobs_TableDriven(t *testing.T) {
 					var responseJobs []*models.Job
 					err := json.Unmarshal(rr.Body.Bytes(), &responseJobs)
 					assert.NoError(t, err, "Response should be valid JSON")
+					assert.NotContains(t, rr.Body.String(), "status_history", "List should not include status history")
+				}
+			}
+		})
+	}
+}
+
+func TestAPI_HandleGetJob_TableDriven(t *testing.T) {
+	testJob := &models.Job{
+		ID:      "job-1",
+		URL:     "https://example.com",
+		Status:  models.JobStatusRunning,
+		Version: 2,
+		StatusHistory: []models.StatusChange{
+			{Status: models.JobStatusPending, At: time.Now()},
+			{Status: models.JobStatusRunning, At: time.Now()},
+		},
+		Result: &models.AnalyzeResult{
+			PageTitle:         "Example",
+			InternalLinkCount: 3,
+			PartialResult:     true,
+		},
+	}
+
+	testCases := []handlerTestCase{
+		{
+			name:   "SuccessfulGetJob",
+			method: "GET",
+			path:   "/jobs/job-1",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(testJob, nil)
+			},
+			expectedStatus: http.StatusOK,
+			description:    "Retrieve a job with its status history",
+		},
+		{
+			name:   "JobNotFound",
+			method: "GET",
+			path:   "/jobs/missing",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "missing").Return(nil, repository.ErrJobNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			description:    "Return 404 for an unknown job",
+		},
+		{
+			name:   "DatabaseError",
+			method: "GET",
+			path:   "/jobs/job-1",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(nil, errors.New("database error"))
+			},
+			expectedError: true,
+			description:   "Handle database errors when fetching the job",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, mockTaskRepo, mockMessageBus, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+
+			tc.setupMocks(mockJobRepo, mockTaskRepo, mockMessageBus)
+
+			req, err := makeRequest(tc.method, tc.path, tc.body)
+			assert.NoError(t, err, "Failed to create request")
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("GET", "/jobs/:job_id", api.handleGetJob)
+			router.Serve().ServeHTTP(rr, req)
+
+			if tc.expectedError {
+				assert.True(t, rr.Code >= 400, "Expected error status code, got %d", rr.Code)
+				return
+			}
+
+			assert.Equal(t, tc.expectedStatus, rr.Code, "Status code mismatch")
+			if tc.expectedStatus == http.StatusOK {
+				var job models.Job
+				assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &job), "Response should be valid JSON")
+				assert.Len(t, job.StatusHistory, 2)
+				assert.Equal(t, `"2"`, rr.Header().Get("ETag"))
+				// The result analyzed so far is returned while the job is running
+				if assert.NotNil(t, job.Result) {
+					assert.True(t, job.Result.PartialResult)
+					assert.Equal(t, "Example", job.Result.PageTitle)
+					assert.Equal(t, 3, job.Result.InternalLinkCount)
 				}
 			}
 		})
@@ -556,12 +937,14 @@ func TestAPI_HandleGetTasksByJobID_TableDriven(t *testing.T) {
 		setupMocks     func(*mocks.MockJobRepositoryInterface, *mocks.MockTaskRepositoryInterface, *mocks.MockMessageBusInterface)
 		expectedStatus int
 		expectedError  bool
+		expectedTypes  []models.TaskType
 		description    string
 	}{
 		{
 			name:  "SuccessfulGetTasks",
 			jobID: "job-1",
 			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(&models.Job{ID: "job-1"}, nil)
 				taskRepo.EXPECT().GetTasksByJobId(gomock.Any(), "job-1").Return(testTasks, nil)
 			},
 			expectedStatus: http.StatusOK,
@@ -572,21 +955,57 @@ func TestAPI_HandleGetTasksByJobID_TableDriven(t *testing.T) {
 			name:  "EmptyTasksList",
 			jobID: "job-2",
 			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-2").Return(&models.Job{ID: "job-2"}, nil)
 				taskRepo.EXPECT().GetTasksByJobId(gomock.Any(), "job-2").Return([]models.Task{}, nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedError:  false,
 			description:    "Handle empty tasks list",
 		},
+		{
+			name:  "UnknownTaskTypeIgnored",
+			jobID: "job-4",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-4").Return(&models.Job{ID: "job-4"}, nil)
+				taskRepo.EXPECT().GetTasksByJobId(gomock.Any(), "job-4").Return(append(testTasks, models.Task{
+					JobID:  "job-4",
+					Type:   "verifying_lnks",
+					Status: models.TaskStatusPending,
+				}), nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedTypes:  []models.TaskType{models.TaskTypeExtracting, models.TaskTypeAnalyzing},
+			description:    "Leave out stored tasks of an unknown type",
+		},
 		{
 			name:  "DatabaseError",
 			jobID: "job-3",
 			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-3").Return(&models.Job{ID: "job-3"}, nil)
 				taskRepo.EXPECT().GetTasksByJobId(gomock.Any(), "job-3").Return(nil, errors.New("database error"))
 			},
 			expectedError: true,
 			description:   "Handle database errors when fetching tasks",
 		},
+		{
+			name:  "JobNotFound",
+			jobID: "missing",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "missing").Return(nil, repository.ErrJobNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			description:    "Return 404 for an unknown job",
+		},
+		{
+			name:  "DeletedJob",
+			jobID: "job-5",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface, taskRepo *mocks.MockTaskRepositoryInterface, mb *mocks.MockMessageBusInterface) {
+				deletedAt := time.Now()
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-5").Return(&models.Job{ID: "job-5", DeletedAt: &deletedAt}, nil)
+			},
+			expectedStatus: http.StatusGone,
+			description:    "Reject task queries of a job in the trash",
+		},
 		{
 			name:  "MissingJobID",
 			jobID: "", // Empty job ID to test validation
@@ -639,8 +1058,137 @@ func TestAPI_HandleGetTasksByJobID_TableDriven(t *testing.T) {
 					var responseTasks []models.Task
 					err := json.Unmarshal(rr.Body.Bytes(), &responseTasks)
 					assert.NoError(t, err, "Response should be valid JSON")
+					if tc.expectedTypes != nil {
+						types := make([]models.TaskType, 0, len(responseTasks))
+						for _, task := range responseTasks {
+							types = append(types, task.Type)
+						}
+						assert.Equal(t, tc.expectedTypes, types)
+					}
 				}
 			}
 		})
 	}
 }
+
+func TestAPI_HandleDebugConfig_TableDriven(t *testing.T) {
+	cfg := &config.Config{
+		Admin:    sharedconfig.AdminConfig{Token: "admin-secret"},
+		HTTP:     sharedconfig.HTTPServerConfig{Addr: ":8080", WriteTimeout: 15 * time.Second},
+		DynamoDB: sharedconfig.DynamoDBConfig{Region: "us-east-1", AccessKeyID: "key-id", SecretAccessKey: "secret-key"},
+	}
+
+	testCases := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+	}{
+		{
+			name:           "MissingToken",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "InvalidToken",
+			authorization:  "Bearer wrong",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "ValidToken",
+			authorization:  "Bearer admin-secret",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := makeRequest("GET", "/debug/config", nil)
+			assert.NoError(t, err, "Failed to create request")
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("GET", "/debug/config", middleware.AdminAuthMiddleware(cfg.Admin.Token)(middleware.ConfigHandler(cfg.Redacted())))
+			router.Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code, "Status code mismatch")
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var body config.Config
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body), "Response should be valid JSON")
+			assert.Equal(t, "us-east-1", body.DynamoDB.Region)
+			assert.Equal(t, 15*time.Second, body.HTTP.WriteTimeout)
+			assert.NotContains(t, rr.Body.String(), "admin-secret")
+			assert.NotContains(t, rr.Body.String(), "key-id")
+			assert.NotContains(t, rr.Body.String(), "secret-key")
+		})
+	}
+
+	// Redaction must not leak back into the loaded configuration
+	assert.Equal(t, "secret-key", cfg.DynamoDB.SecretAccessKey)
+}
+
+func TestAPI_HandleLogLevel_TableDriven(t *testing.T) {
+	testCases := []struct {
+		name           string
+		authorization  string
+		body           string
+		expectedStatus int
+		expectedLevel  slog.Level
+	}{
+		{
+			name:           "MissingToken",
+			body:           `{"level":"debug"}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedLevel:  slog.LevelInfo,
+		},
+		{
+			name:           "UnknownLevel",
+			authorization:  "Bearer admin-secret",
+			body:           `{"level":"verbose"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedLevel:  slog.LevelInfo,
+		},
+		{
+			name:           "InvalidBody",
+			authorization:  "Bearer admin-secret",
+			body:           `level=debug`,
+			expectedStatus: http.StatusBadRequest,
+			expectedLevel:  slog.LevelInfo,
+		},
+		{
+			name:           "Debug",
+			authorization:  "Bearer admin-secret",
+			body:           `{"level":"debug"}`,
+			expectedStatus: http.StatusOK,
+			expectedLevel:  slog.LevelDebug,
+		},
+	}
+
+	defer sharedlog.SetLevel(sharedlog.Level())
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sharedlog.SetLevel(slog.LevelInfo)
+
+			req, err := http.NewRequest(http.MethodPost, "/admin/loglevel", strings.NewReader(tc.body))
+			assert.NoError(t, err, "Failed to create request")
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+
+			rr := httptest.NewRecorder()
+			handler := middleware.AdminAuthMiddleware("admin-secret")(middleware.LogLevelHandler(slog.New(slog.DiscardHandler)))
+			setupRouter(http.MethodPost, "/admin/loglevel", handler).Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code, "Status code mismatch")
+			assert.Equal(t, tc.expectedLevel, sharedlog.Level())
+			if tc.expectedStatus == http.StatusOK {
+				var body middleware.LogLevelResponse
+				assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body), "Response should be valid JSON")
+				assert.Equal(t, middleware.LogLevelResponse{Level: "DEBUG", Previous: "INFO"}, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"container/list"
+	"context"
+	"shared/models"
+	"sync"
+	"time"
+)
+
+// jobCache keeps recently read finished jobs, keyed by job ID, so polling clients are served without a database read.
+// A finished job only changes when it is deleted, restored or purged, which this replica evicts it on; another
+// replica serves its copy until the TTL passes. The least recently read job is dropped once the cache is full.
+type jobCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	maxJobs int
+	order   *list.List
+	byJob   map[string]*list.Element
+	now     func() time.Time
+}
+
+// cachedJob is a finished job, kept until expires. The job is shared by every read and must not be modified.
+type cachedJob struct {
+	job     *models.Job
+	expires time.Time
+}
+
+func newJobCache(ttl time.Duration, maxJobs int) *jobCache {
+	return &jobCache{
+		ttl:     ttl,
+		maxJobs: maxJobs,
+		order:   list.New(),
+		byJob:   make(map[string]*list.Element),
+		now:     time.Now,
+	}
+}
+
+// WithJobCache keeps up to maxJobs finished jobs for ttl, serving GET /jobs/:job_id from memory.
+// A zero ttl or maxJobs disables the cache.
+func WithJobCache(ttl time.Duration, maxJobs int) Option {
+	return func(a *API) {
+		a.jobCache = nil
+		if ttl > 0 && maxJobs > 0 {
+			a.jobCache = newJobCache(ttl, maxJobs)
+		}
+	}
+}
+
+// get returns a cached job, nil when it is not cached or has expired
+func (c *jobCache) get(jobID string) *models.Job {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.byJob[jobID]
+	if !ok {
+		return nil
+	}
+	entry := el.Value.(*cachedJob)
+	if !c.now().Before(entry.expires) {
+		c.remove(el)
+		return nil
+	}
+	c.order.MoveToBack(el)
+	return entry.job
+}
+
+// add caches a job if it is finished and not deleted, the only jobs that no longer change on their own
+func (c *jobCache) add(job *models.Job) {
+	if !job.Status.IsTerminal() || job.IsDeleted() {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.byJob[job.ID]; ok {
+		c.remove(el)
+	}
+	c.byJob[job.ID] = c.order.PushBack(&cachedJob{job: job, expires: c.now().Add(c.ttl)})
+	for c.order.Len() > c.maxJobs {
+		c.remove(c.order.Front())
+	}
+}
+
+// evict drops a job from the cache
+func (c *jobCache) evict(jobID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.byJob[jobID]; ok {
+		c.remove(el)
+	}
+}
+
+func (c *jobCache) remove(el *list.Element) {
+	delete(c.byJob, el.Value.(*cachedJob).job.ID)
+	c.order.Remove(el)
+}
+
+// getJob reads a job for GET /jobs/:job_id, from the job cache when it holds the job
+func (a *API) getJob(ctx context.Context, jobID string) (*models.Job, error) {
+	if a.jobCache == nil {
+		return a.jobRepo.GetJob(ctx, jobID)
+	}
+
+	if job := a.jobCache.get(jobID); job != nil {
+		a.recordJobCacheLookup(true)
+		return job, nil
+	}
+	a.recordJobCacheLookup(false)
+
+	job, err := a.jobRepo.GetJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	a.jobCache.add(job)
+	return job, nil
+}
+
+// evictCachedJob drops a job that changed from the job cache
+func (a *API) evictCachedJob(jobID string) {
+	if a.jobCache != nil {
+		a.jobCache.evict(jobID)
+	}
+}
+
+func (a *API) recordJobCacheLookup(hit bool) {
+	if a.metrics != nil {
+		a.metrics.RecordJobCacheLookup(hit)
+	}
+}
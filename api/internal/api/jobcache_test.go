@@ -0,0 +1,99 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"shared/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestJobCache(t *testing.T) {
+	now := time.Now()
+	cache := newJobCache(time.Hour, 2)
+	cache.now = func() time.Time { return now }
+
+	completed := func(id string) *models.Job {
+		return &models.Job{ID: id, Status: models.JobStatusCompleted}
+	}
+
+	cache.add(completed("job-1"))
+	cache.add(completed("job-2"))
+	require.NotNil(t, cache.get("job-1"))
+	cache.add(completed("job-3"))
+	assert.Nil(t, cache.get("job-2"), "the least recently read job is dropped once the cache is full")
+	assert.NotNil(t, cache.get("job-1"))
+	assert.NotNil(t, cache.get("job-3"))
+
+	cache.evict("job-1")
+	assert.Nil(t, cache.get("job-1"))
+
+	now = now.Add(time.Hour)
+	assert.Nil(t, cache.get("job-3"), "an expired job is read again")
+	assert.Zero(t, cache.order.Len())
+	assert.Empty(t, cache.byJob)
+
+	deletedAt := now
+	cache.add(&models.Job{ID: "running", Status: models.JobStatusRunning})
+	cache.add(&models.Job{ID: "deleted", Status: models.JobStatusFailed, DeletedAt: &deletedAt})
+	assert.Nil(t, cache.get("running"), "a job still in progress is not cached")
+	assert.Nil(t, cache.get("deleted"), "a deleted job is not cached")
+}
+
+func TestAPI_HandleGetJob_Cached(t *testing.T) {
+	testCases := []struct {
+		name        string
+		status      models.JobStatus
+		expectedGet int
+	}{
+		{name: "Completed", status: models.JobStatusCompleted, expectedGet: 1},
+		{name: "Failed", status: models.JobStatusFailed, expectedGet: 1},
+		{name: "Running", status: models.JobStatusRunning, expectedGet: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, jobRepo, _, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+			WithJobCache(time.Minute, 10)(api)
+
+			job := &models.Job{ID: "job-1", Status: tc.status, Version: 3}
+			jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(job, nil).Times(tc.expectedGet)
+
+			router := setupRouter("GET", "/jobs/:job_id", api.handleGetJob)
+			for range 2 {
+				req, err := makeRequest("GET", "/jobs/job-1", nil)
+				require.NoError(t, err)
+				w := httptest.NewRecorder()
+				router.Serve().ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestAPI_HandleDeleteJob_EvictsCachedJob(t *testing.T) {
+	api, jobRepo, _, _, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+	WithJobCache(time.Minute, 10)(api)
+
+	job := &models.Job{ID: "job-1", Status: models.JobStatusCompleted}
+	api.jobCache.add(job)
+	jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(job, nil)
+	jobRepo.EXPECT().DeleteJob(gomock.Any(), "job-1", gomock.Any()).Return(true, nil)
+
+	router := setupRouter("DELETE", "/jobs/:job_id", api.handleDeleteJob)
+	req, err := makeRequest("DELETE", "/jobs/job-1", nil)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.Serve().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Nil(t, api.jobCache.get("job-1"), "a deleted job is read again so it shows as deleted")
+}
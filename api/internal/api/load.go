@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"shared/messagebus"
+	"shared/middleware"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/yousuf64/shift"
+)
+
+// defaultLoadStaleAfter is how long an analyzer load report is used once received
+const defaultLoadStaleAfter = time.Minute
+
+// loadReport is the latest load reported by one analyzer replica along with when it was received
+type loadReport struct {
+	msg        messagebus.AnalyzerLoadMessage
+	receivedAt time.Time
+}
+
+const (
+	// minRetryAfter keeps clients turned away by an overloaded analyzer from retrying right away
+	minRetryAfter = 5 * time.Second
+	// maxRetryAfter caps the wait suggested to clients, the estimate is too rough to trust beyond it
+	maxRetryAfter = 5 * time.Minute
+)
+
+// SystemLoad is the response body for the system load endpoint, summed over the analyzer replicas
+type SystemLoad struct {
+	// Available is false when no analyzer has reported its load recently, the other fields are then empty
+	Available bool `json:"available"`
+	// Instances lists the replicas whose reports are counted, sorted
+	Instances  []string `json:"instances,omitempty"`
+	QueueDepth int      `json:"queue_depth"`
+	InFlight   int      `json:"in_flight"`
+	// AvgWaitSeconds and AvgDurationSeconds are rolling averages of how long jobs wait to start and then run,
+	// averaged over the replicas
+	AvgWaitSeconds     float64 `json:"avg_wait_seconds"`
+	AvgDurationSeconds float64 `json:"avg_duration_seconds"`
+	// EstimatedStartDelaySeconds is a rough guess of how long a job submitted now waits before it starts
+	EstimatedStartDelaySeconds float64    `json:"estimated_start_delay_seconds"`
+	UpdatedAt                  *time.Time `json:"updated_at,omitempty"`
+}
+
+// WithLoadStaleAfter sets how long an analyzer load report is trusted, after which the load is reported unavailable
+func WithLoadStaleAfter(d time.Duration) Option {
+	return func(a *API) {
+		a.loadStaleAfter = d
+	}
+}
+
+// WithMaxQueueDepth sets the analyzer queue depth at which new jobs are refused with a 503, 0 accepts every job
+func WithMaxQueueDepth(depth int) Option {
+	return func(a *API) {
+		a.maxQueueDepth = depth
+	}
+}
+
+// WatchAnalyzerLoad keeps the latest load reported by each analyzer replica, for the start delay hints
+func (a *API) WatchAnalyzerLoad() (*nats.Subscription, error) {
+	return a.mb.SubscribeToAnalyzerLoad(func(ctx context.Context, m *nats.Msg) {
+		var msg messagebus.AnalyzerLoadMessage
+		if err := json.Unmarshal(m.Data, &msg); err != nil {
+			a.log.Error("Failed to unmarshal analyzer load", slog.Any("error", err))
+			return
+		}
+
+		a.recordLoad(msg, time.Now())
+	})
+}
+
+// recordLoad stores an analyzer load report received at receivedAt, replacing the previous one of its replica.
+// Replicas that stopped reporting are forgotten here, so the reports do not pile up as replicas come and go.
+func (a *API) recordLoad(msg messagebus.AnalyzerLoadMessage, receivedAt time.Time) {
+	a.loadMu.Lock()
+	defer a.loadMu.Unlock()
+
+	for id, report := range a.loads {
+		if receivedAt.Sub(report.receivedAt) > a.loadStaleAfter {
+			delete(a.loads, id)
+		}
+	}
+	a.loads[msg.InstanceID] = loadReport{msg: msg, receivedAt: receivedAt}
+}
+
+// systemLoad sums the load of the analyzer replicas that reported within the stale period, each report expiring
+// on its own. It is unavailable when none did. Staleness is judged by the time of receipt,
+// so clock skew with the analyzers does not matter.
+func (a *API) systemLoad(now time.Time) SystemLoad {
+	a.loadMu.RLock()
+	defer a.loadMu.RUnlock()
+
+	var load SystemLoad
+	var updatedAt time.Time
+	for id, report := range a.loads {
+		if now.Sub(report.receivedAt) > a.loadStaleAfter {
+			continue
+		}
+		load.Instances = append(load.Instances, id)
+		load.QueueDepth += report.msg.QueueDepth
+		load.InFlight += report.msg.InFlight
+		load.AvgWaitSeconds += report.msg.AvgWaitSeconds
+		load.AvgDurationSeconds += report.msg.AvgDurationSeconds
+		if report.receivedAt.After(updatedAt) {
+			updatedAt = report.receivedAt
+		}
+	}
+	if len(load.Instances) == 0 {
+		return SystemLoad{}
+	}
+
+	replicas := float64(len(load.Instances))
+	slices.Sort(load.Instances)
+	load.Available = true
+	load.AvgWaitSeconds /= replicas
+	load.AvgDurationSeconds /= replicas
+	// Naive on purpose: every queued job is expected to take as long as the recent ones did,
+	// with the replicas working through the queue side by side
+	load.EstimatedStartDelaySeconds = math.Round(float64(load.QueueDepth) * load.AvgDurationSeconds / replicas)
+	updatedAt = updatedAt.UTC()
+	load.UpdatedAt = &updatedAt
+	return load
+}
+
+// startDelayHint returns the estimated start delay of a job submitted now, nil when the load is unknown
+func (a *API) startDelayHint() *float64 {
+	load := a.systemLoad(time.Now())
+	if !load.Available {
+		return nil
+	}
+	return &load.EstimatedStartDelaySeconds
+}
+
+// overloaded reports whether the backlog of the analyzers has reached the maximum queue depth, and when to retry.
+// The wait is the time the replicas need to bring the queue back under the maximum, as naive as the start delay.
+// Jobs are accepted when no analyzer reported recently, a missing report says nothing about the backlog.
+func (a *API) overloaded(now time.Time) (time.Duration, bool) {
+	if a.maxQueueDepth <= 0 {
+		return 0, false
+	}
+	load := a.systemLoad(now)
+	if !load.Available || load.QueueDepth < a.maxQueueDepth {
+		return 0, false
+	}
+
+	excess := load.QueueDepth - a.maxQueueDepth + 1
+	retryAfter := time.Duration(float64(excess) * load.AvgDurationSeconds / float64(len(load.Instances)) * float64(time.Second))
+	return min(max(retryAfter, minRetryAfter), maxRetryAfter), true
+}
+
+// rejectIfOverloaded answers 503 with a Retry-After header when the analyzer is overloaded, reporting whether it did
+func (a *API) rejectIfOverloaded(w http.ResponseWriter, r *http.Request) bool {
+	retryAfter, overloaded := a.overloaded(time.Now())
+	if !overloaded {
+		return false
+	}
+
+	a.log.Warn("Refusing job, the analyzer is overloaded",
+		slog.Duration("retryAfter", retryAfter),
+		slog.String("path", r.URL.Path))
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	middleware.WriteError(w, r, http.StatusServiceUnavailable, "The analyzer is overloaded, please retry later")
+	return true
+}
+
+// handleGetSystemLoad handles the system load endpoint
+func (a *API) handleGetSystemLoad(w http.ResponseWriter, r *http.Request, _ shift.Route) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(a.systemLoad(time.Now()))
+}
@@ -0,0 +1,297 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"shared/messagebus"
+	"strconv"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yousuf64/shift"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAPI_SystemLoad(t *testing.T) {
+	now := time.Now()
+	report := messagebus.AnalyzerLoadMessage{
+		InstanceID:         "analyzer-1",
+		QueueDepth:         4,
+		InFlight:           1,
+		AvgWaitSeconds:     12.5,
+		AvgDurationSeconds: 7.6,
+	}
+
+	testCases := []struct {
+		name              string
+		receivedAgo       time.Duration
+		noReport          bool
+		expectedAvailable bool
+		expectedDelay     float64
+	}{
+		{
+			name:     "NoReport",
+			noReport: true,
+		},
+		{
+			name:              "FreshReport",
+			receivedAgo:       10 * time.Second,
+			expectedAvailable: true,
+			expectedDelay:     30,
+		},
+		{
+			name:        "StaleReport",
+			receivedAgo: 2 * defaultLoadStaleAfter,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, _, _, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+
+			if !tc.noReport {
+				api.recordLoad(report, now.Add(-tc.receivedAgo))
+			}
+
+			load := api.systemLoad(now)
+			assert.Equal(t, tc.expectedAvailable, load.Available)
+			assert.Equal(t, tc.expectedDelay, load.EstimatedStartDelaySeconds)
+			if tc.expectedAvailable {
+				assert.Equal(t, []string{"analyzer-1"}, load.Instances)
+				assert.Equal(t, 4, load.QueueDepth)
+				assert.Equal(t, 1, load.InFlight)
+				assert.Equal(t, 12.5, load.AvgWaitSeconds)
+			} else {
+				assert.Equal(t, SystemLoad{}, load)
+			}
+		})
+	}
+}
+
+func TestAPI_SystemLoad_Replicas(t *testing.T) {
+	now := time.Now()
+	api, _, _, _, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+	api.maxQueueDepth = 10
+
+	api.recordLoad(messagebus.AnalyzerLoadMessage{InstanceID: "analyzer-2", QueueDepth: 6, InFlight: 2, AvgWaitSeconds: 20, AvgDurationSeconds: 12}, now.Add(-20*time.Second))
+	api.recordLoad(messagebus.AnalyzerLoadMessage{InstanceID: "analyzer-1", QueueDepth: 8, InFlight: 1, AvgWaitSeconds: 10, AvgDurationSeconds: 8}, now.Add(-10*time.Second))
+
+	// Both replicas count, whichever reported last
+	load := api.systemLoad(now)
+	assert.True(t, load.Available)
+	assert.Equal(t, []string{"analyzer-1", "analyzer-2"}, load.Instances)
+	assert.Equal(t, 14, load.QueueDepth)
+	assert.Equal(t, 3, load.InFlight)
+	assert.Equal(t, 15.0, load.AvgWaitSeconds)
+	assert.Equal(t, 10.0, load.AvgDurationSeconds)
+	assert.Equal(t, 70.0, load.EstimatedStartDelaySeconds, "the two replicas work through the queue side by side")
+	if assert.NotNil(t, load.UpdatedAt) {
+		assert.True(t, load.UpdatedAt.Equal(now.Add(-10*time.Second)))
+	}
+
+	retryAfter, overloaded := api.overloaded(now)
+	assert.True(t, overloaded, "neither replica alone reached the maximum, together they did")
+	assert.Equal(t, 25*time.Second, retryAfter)
+
+	// A newer report of a replica replaces its previous one
+	api.recordLoad(messagebus.AnalyzerLoadMessage{InstanceID: "analyzer-1", QueueDepth: 1, AvgDurationSeconds: 8}, now.Add(-5*time.Second))
+	assert.Equal(t, 7, api.systemLoad(now).QueueDepth)
+
+	// Each report expires on its own
+	load = api.systemLoad(now.Add(defaultLoadStaleAfter - 10*time.Second))
+	assert.Equal(t, []string{"analyzer-1"}, load.Instances)
+	assert.Equal(t, 1, load.QueueDepth)
+	_, overloaded = api.overloaded(now.Add(defaultLoadStaleAfter - 10*time.Second))
+	assert.False(t, overloaded)
+}
+
+func TestAPI_AnalyzerLoad_Integration(t *testing.T) {
+	opts := natsserver.DefaultTestOptions
+	opts.Port = 8434
+	server := natsserver.RunServer(&opts)
+	defer server.Shutdown()
+
+	nc, err := nats.Connect("nats://127.0.0.1:" + strconv.Itoa(opts.Port))
+	require.NoError(t, err)
+	defer nc.Close()
+	bus := messagebus.New(nc, nil)
+
+	api, mockJobRepo, mockTaskRepo, _, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+	api.mb = bus
+
+	sub, err := api.WatchAnalyzerLoad()
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+	require.NoError(t, nc.Flush())
+
+	getLoad := func() SystemLoad {
+		rr := httptest.NewRecorder()
+		setupRouter("GET", "/system/load", api.handleGetSystemLoad).Serve().ServeHTTP(rr, httptest.NewRequest("GET", "/system/load", nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var load SystemLoad
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &load))
+		return load
+	}
+	assert.False(t, getLoad().Available, "No analyzer has reported yet")
+
+	require.NoError(t, bus.PublishAnalyzerLoad(context.Background(), messagebus.AnalyzerLoadMessage{
+		InstanceID:         "analyzer-1",
+		QueueDepth:         3,
+		InFlight:           1,
+		AvgDurationSeconds: 40,
+		SentAt:             time.Now().UTC(),
+	}))
+
+	var load SystemLoad
+	require.Eventually(t, func() bool {
+		load = getLoad()
+		return load.Available
+	}, 2*time.Second, 10*time.Millisecond, "The load report should reach the API")
+	assert.Equal(t, 3, load.QueueDepth)
+	assert.Equal(t, 120.0, load.EstimatedStartDelaySeconds)
+	assert.NotNil(t, load.UpdatedAt)
+
+	// New jobs are answered with the hint
+	mockJobRepo.EXPECT().CreateJob(gomock.Any(), gomock.Any()).Return(nil)
+	mockTaskRepo.EXPECT().CreateTasks(gomock.Any(), gomock.Any()).Return(nil)
+
+	req, err := makeRequest("POST", "/analyze", AnalyzeRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	setupRouter("POST", "/analyze", api.handleAnalyze).Serve().ServeHTTP(rr, req)
+	require.Equal(t, http.StatusAccepted, rr.Code)
+
+	var resp AnalyzeResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	if assert.NotNil(t, resp.EstimatedStartDelaySeconds) {
+		assert.Equal(t, 120.0, *resp.EstimatedStartDelaySeconds)
+	}
+}
+
+func TestAPI_Overloaded(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name               string
+		maxQueueDepth      int
+		report             *messagebus.AnalyzerLoadMessage
+		receivedAgo        time.Duration
+		expectedOverloaded bool
+		expectedRetryAfter time.Duration
+	}{
+		{
+			name:          "Disabled",
+			maxQueueDepth: 0,
+			report:        &messagebus.AnalyzerLoadMessage{QueueDepth: 1000, AvgDurationSeconds: 10},
+		},
+		{
+			name:          "NoReport",
+			maxQueueDepth: 10,
+		},
+		{
+			name:          "StaleReport",
+			maxQueueDepth: 10,
+			report:        &messagebus.AnalyzerLoadMessage{QueueDepth: 50, AvgDurationSeconds: 10},
+			receivedAgo:   2 * defaultLoadStaleAfter,
+		},
+		{
+			name:          "BelowThreshold",
+			maxQueueDepth: 10,
+			report:        &messagebus.AnalyzerLoadMessage{QueueDepth: 9, AvgDurationSeconds: 10},
+		},
+		{
+			name:               "AtThreshold",
+			maxQueueDepth:      10,
+			report:             &messagebus.AnalyzerLoadMessage{QueueDepth: 10, AvgDurationSeconds: 8},
+			expectedOverloaded: true,
+			expectedRetryAfter: 8 * time.Second,
+		},
+		{
+			name:               "AboveThreshold",
+			maxQueueDepth:      10,
+			report:             &messagebus.AnalyzerLoadMessage{QueueDepth: 14, AvgDurationSeconds: 8},
+			expectedOverloaded: true,
+			expectedRetryAfter: 40 * time.Second,
+		},
+		{
+			name:               "MinRetryAfter",
+			maxQueueDepth:      10,
+			report:             &messagebus.AnalyzerLoadMessage{QueueDepth: 10, AvgDurationSeconds: 0.5},
+			expectedOverloaded: true,
+			expectedRetryAfter: minRetryAfter,
+		},
+		{
+			name:               "MaxRetryAfter",
+			maxQueueDepth:      10,
+			report:             &messagebus.AnalyzerLoadMessage{QueueDepth: 500, AvgDurationSeconds: 30},
+			expectedOverloaded: true,
+			expectedRetryAfter: maxRetryAfter,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, _, _, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+			api.maxQueueDepth = tc.maxQueueDepth
+
+			if tc.report != nil {
+				api.recordLoad(*tc.report, now.Add(-tc.receivedAgo))
+			}
+
+			retryAfter, overloaded := api.overloaded(now)
+			assert.Equal(t, tc.expectedOverloaded, overloaded)
+			assert.Equal(t, tc.expectedRetryAfter, retryAfter)
+		})
+	}
+}
+
+func TestAPI_HandleAnalyze_Overloaded(t *testing.T) {
+	testCases := []struct {
+		name    string
+		path    string
+		body    any
+		handler func(api *API) shift.HandlerFunc
+	}{
+		{
+			name:    "Analyze",
+			path:    "/analyze",
+			body:    AnalyzeRequest{URL: "https://example.com"},
+			handler: func(api *API) shift.HandlerFunc { return api.handleAnalyze },
+		},
+		{
+			name:    "AnalyzeGroup",
+			path:    "/analyze/group",
+			body:    AnalyzeGroupRequest{URLs: []string{"https://example.com", "https://example.org"}},
+			handler: func(api *API) shift.HandlerFunc { return api.handleAnalyzeGroup },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// No job is created, the mocks expect no call
+			api, _, _, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+			api.maxQueueDepth = 20
+			api.recordLoad(messagebus.AnalyzerLoadMessage{QueueDepth: 25, AvgDurationSeconds: 4.5}, time.Now())
+
+			req, err := makeRequest("POST", tc.path, tc.body)
+			require.NoError(t, err)
+			rr := httptest.NewRecorder()
+			setupRouter("POST", tc.path, tc.handler(api)).Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+			assert.Equal(t, "27", rr.Header().Get("Retry-After"))
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"shared/audit"
+	"shared/middleware"
+	"time"
+
+	"github.com/yousuf64/shift"
+)
+
+// writeDeadlineGrace leaves room to write the timeout response after a route timeout fires
+const writeDeadlineGrace = time.Second
+
+// routeKey returns the key used to look up per-route settings, e.g. "GET /jobs/:job_id/tasks"
+func routeKey(r *http.Request, route shift.Route) string {
+	return r.Method + " " + route.Path
+}
+
+// auditSourceMiddleware attributes the audit records of a request to its request ID and peer address
+func auditSourceMiddleware(next shift.HandlerFunc) shift.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+		ctx := audit.WithSource(r.Context(), audit.Source{
+			RequestID:    middleware.RequestIDFromContext(r.Context()),
+			SourceIP:     remoteIP(r),
+			ForwardedFor: r.Header.Get("X-Forwarded-For"),
+		})
+		return next(w, r.WithContext(ctx), route)
+	}
+}
+
+// remoteIP returns the IP address of the peer that sent the request
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// slowRequestMiddleware records handler durations by route template
+// and logs a warning for requests slower than the threshold
+func (a *API) slowRequestMiddleware(threshold time.Duration) func(shift.HandlerFunc) shift.HandlerFunc {
+	return func(next shift.HandlerFunc) shift.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+			start := time.Now()
+			err := next(w, r, route)
+			duration := time.Since(start)
+
+			if a.metrics != nil {
+				a.metrics.RecordHandlerDuration(r.Method, route.Path, duration)
+			}
+
+			// Event streams last as long as the job they follow
+			streaming := w.Header().Get("Content-Type") == ndjsonContentType
+			if threshold > 0 && duration > threshold && !streaming {
+				a.log.Warn("Slow request",
+					slog.String("method", r.Method),
+					slog.String("route", route.Path),
+					slog.Duration("duration", duration),
+					slog.String("request_id", middleware.RequestIDFromContext(r.Context())))
+			}
+
+			return err
+		}
+	}
+}
+
+// routeTimeoutMiddleware bounds each handler by the timeout configured for its route.
+// Routes allowed to outlive the server write timeout have their write deadline extended.
+func routeTimeoutMiddleware(timeouts map[string]time.Duration, writeTimeout time.Duration) func(shift.HandlerFunc) shift.HandlerFunc {
+	return func(next shift.HandlerFunc) shift.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+			timeout, ok := timeouts[routeKey(r, route)]
+			if !ok || timeout <= 0 {
+				return next(w, r, route)
+			}
+
+			if writeTimeout > 0 && timeout > writeTimeout {
+				// Not every writer supports deadlines (e.g. recorders in tests), the server default applies then
+				_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + writeDeadlineGrace))
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			err := next(w, r.WithContext(ctx), route)
+			if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return middleware.NewHTTPError(http.StatusServiceUnavailable, "request timed out", err)
+			}
+			return err
+		}
+	}
+}
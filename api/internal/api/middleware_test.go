@@ -0,0 +1,280 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"shared/audit"
+	"shared/messagebus"
+	"shared/middleware"
+	"shared/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yousuf64/shift"
+	"go.uber.org/mock/gomock"
+)
+
+// slowHandler blocks for delay or until the request context is done
+func slowHandler(delay time.Duration) shift.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+		select {
+		case <-time.After(delay):
+			w.WriteHeader(http.StatusOK)
+			return nil
+		case <-r.Context().Done():
+			return r.Context().Err()
+		}
+	}
+}
+
+func TestAPI_SlowRequestMiddleware(t *testing.T) {
+	testCases := []struct {
+		name        string
+		delay       time.Duration
+		threshold   time.Duration
+		expectedLog bool
+	}{
+		{
+			name:        "SlowRequestLogged",
+			delay:       50 * time.Millisecond,
+			threshold:   10 * time.Millisecond,
+			expectedLog: true,
+		},
+		{
+			name:        "FastRequestNotLogged",
+			delay:       0,
+			threshold:   time.Second,
+			expectedLog: false,
+		},
+		{
+			name:        "DisabledThreshold",
+			delay:       20 * time.Millisecond,
+			threshold:   0,
+			expectedLog: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			api := &API{log: slog.New(slog.NewJSONHandler(&buf, nil))}
+
+			router := shift.New()
+			router.Use(middleware.RequestIDMiddleware)
+			router.Use(api.slowRequestMiddleware(tc.threshold))
+			router.GET("/jobs/:job_id/tasks", slowHandler(tc.delay))
+
+			req := httptest.NewRequest(http.MethodGet, "/jobs/123/tasks", nil)
+			req.Header.Set(middleware.RequestIDHeader, "req-123")
+			rr := httptest.NewRecorder()
+
+			router.Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			if !tc.expectedLog {
+				assert.Empty(t, buf.String())
+				return
+			}
+
+			var entry map[string]any
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+			assert.Equal(t, "WARN", entry["level"])
+			assert.Equal(t, "Slow request", entry["msg"])
+			assert.Equal(t, "/jobs/:job_id/tasks", entry["route"])
+			assert.Equal(t, "req-123", entry["request_id"])
+			assert.NotEmpty(t, entry["duration"])
+		})
+	}
+}
+
+func TestAPI_RouteTimeoutMiddleware(t *testing.T) {
+	timeouts := map[string]time.Duration{
+		"GET /slow": 20 * time.Millisecond,
+	}
+
+	testCases := []struct {
+		name           string
+		path           string
+		delay          time.Duration
+		expectedStatus int
+	}{
+		{
+			name:           "TimeoutExceeded",
+			path:           "/slow",
+			delay:          time.Second,
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:           "WithinTimeout",
+			path:           "/slow",
+			delay:          0,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "RouteWithoutTimeout",
+			path:           "/unbounded",
+			delay:          50 * time.Millisecond,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := shift.New()
+			router.Use(middleware.ErrorMiddleware(slog.New(slog.DiscardHandler)))
+			router.Use(routeTimeoutMiddleware(timeouts, 15*time.Second))
+			router.GET(tc.path, slowHandler(tc.delay))
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			rr := httptest.NewRecorder()
+
+			start := time.Now()
+			router.Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Less(t, time.Since(start), 500*time.Millisecond, "handler should not outlive its route timeout")
+		})
+	}
+}
+
+func TestAPI_ErrorResponseFormat(t *testing.T) {
+	testCases := []struct {
+		name            string
+		accept          string
+		path            string
+		expectedStatus  int
+		expectedProblem bool
+		expectedDetail  string
+	}{
+		{name: "Envelope", path: "/jobs/missing", expectedStatus: http.StatusNotFound, expectedDetail: "Job not found"},
+		{name: "JSONRequested", accept: "application/json", path: "/jobs/missing", expectedStatus: http.StatusNotFound, expectedDetail: "Job not found"},
+		{
+			name:            "ProblemRequested",
+			accept:          "application/json, application/problem+json",
+			path:            "/jobs/missing",
+			expectedStatus:  http.StatusNotFound,
+			expectedProblem: true,
+			expectedDetail:  "Job not found",
+		},
+		{name: "ProblemRefused", accept: "application/problem+json;q=0", path: "/jobs/missing", expectedStatus: http.StatusNotFound, expectedDetail: "Job not found"},
+		{
+			// Errors returned by handlers are formatted by the error middleware
+			name:            "HandlerError",
+			accept:          "application/problem+json",
+			path:            "/jobs/broken",
+			expectedStatus:  http.StatusInternalServerError,
+			expectedProblem: true,
+			expectedDetail:  "database error\nfailed to get job",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, _, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+
+			mockJobRepo.EXPECT().GetJob(gomock.Any(), "missing").Return(nil, repository.ErrJobNotFound).AnyTimes()
+			mockJobRepo.EXPECT().GetJob(gomock.Any(), "broken").Return(nil, errors.New("database error")).AnyTimes()
+
+			req, err := makeRequest("GET", tc.path, nil)
+			require.NoError(t, err)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("GET", "/jobs/:job_id", api.handleGetJob)
+			router.Serve().ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, "Accept", rr.Header().Get("Vary"))
+
+			if tc.expectedProblem {
+				assert.Equal(t, middleware.ProblemContentType, rr.Header().Get("Content-Type"))
+				var problem middleware.Problem
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+				assert.Equal(t, middleware.Problem{
+					Type:     "about:blank",
+					Title:    http.StatusText(tc.expectedStatus),
+					Status:   tc.expectedStatus,
+					Detail:   tc.expectedDetail,
+					Instance: tc.path,
+				}, problem)
+				return
+			}
+
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			var envelope middleware.ErrorResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &envelope))
+			assert.Equal(t, middleware.ErrorResponse{Error: tc.expectedDetail, Status: tc.expectedStatus}, envelope)
+		})
+	}
+}
+
+func TestAPI_HandleAnalyze_AuditRecord(t *testing.T) {
+	api, mockJobRepo, mockTaskRepo, mockMessageBus, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+
+	var buf bytes.Buffer
+	api.audit = audit.NewLogger(&buf, "api")
+
+	mockJobRepo.EXPECT().CreateJob(gomock.Any(), gomock.Any()).Return(nil)
+	mockTaskRepo.EXPECT().CreateTasks(gomock.Any(), gomock.Any()).Return(nil)
+	var published messagebus.AnalyzeMessage
+	mockMessageBus.EXPECT().PublishAnalyzeMessage(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, m messagebus.AnalyzeMessage) error {
+			published = m
+			return nil
+		})
+
+	router := shift.New()
+	router.Use(middleware.RequestIDMiddleware)
+	router.Use(auditSourceMiddleware)
+	router.POST("/analyze", api.handleAnalyze)
+
+	req, err := makeRequest("POST", "/analyze", AnalyzeRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+	req.RemoteAddr = "203.0.113.7:51234"
+	req.Header.Set(middleware.RequestIDHeader, "req-1")
+	req.Header.Set("X-Forwarded-For", "198.51.100.2")
+
+	rr := httptest.NewRecorder()
+	router.Serve().ServeHTTP(rr, req)
+	require.Equal(t, http.StatusAccepted, rr.Code)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, string(audit.EventJobCreated), record["event"])
+	assert.Equal(t, published.JobId, record["jobId"])
+	assert.Equal(t, "https://example.com", record["url"])
+	assert.Equal(t, "req-1", record["requestId"])
+	assert.Equal(t, "203.0.113.7", record["sourceIp"])
+	assert.Equal(t, "198.51.100.2", record["forwardedFor"])
+
+	// The analyzer audits the job's status changes against the same request
+	assert.Equal(t, audit.Source{RequestID: "req-1", SourceIP: "203.0.113.7", ForwardedFor: "198.51.100.2"}, published.Source)
+	assert.NotEmpty(t, published.AttemptID)
+}
+
+func TestAPI_ErrorMiddleware_NotFound(t *testing.T) {
+	// A missing item that a handler did not answer for itself is the client's error, not the server's
+	router := setupRouter("GET", "/groups/:group_id", func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+		return errors.Join(repository.ErrGroupNotFound, errors.New("failed to get group"))
+	})
+
+	req, err := makeRequest("GET", "/groups/missing", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	router.Serve().ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), http.StatusText(http.StatusNotFound))
+	assert.NotContains(t, rr.Body.String(), "failed to get group")
+}
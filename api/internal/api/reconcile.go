@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"shared/audit"
+	"shared/messagebus"
+	"shared/models"
+	"shared/repository"
+	"time"
+)
+
+const defaultHeartbeatStaleAfter = 5 * time.Minute
+
+// reconcilePageSize is the number of running jobs read per page while looking for stuck ones
+const reconcilePageSize = 100
+
+// stuckJobReason is the failure reason of the jobs failed by the reconciler
+const stuckJobReason = "The analysis stopped responding"
+
+// WithHeartbeatStaleAfter sets how long a running job may go without a heartbeat before the reconciler fails it
+func WithHeartbeatStaleAfter(d time.Duration) Option {
+	return func(a *API) {
+		a.heartbeatStaleAfter = d
+	}
+}
+
+// RunStuckJobReconciler fails the running jobs whose analysis stopped sending heartbeats every interval,
+// until ctx is done
+func (a *API) RunStuckJobReconciler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := a.reconcileStuckJobs(ctx, time.Now()); err != nil {
+				a.log.Error("Failed to reconcile stuck jobs", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// reconcileStuckJobs fails the running jobs that showed no sign of life for heartbeatStaleAfter by now.
+// A job is only failed while it is still as it was read, so one that sent a heartbeat meanwhile is left running.
+// It returns the number of jobs failed.
+func (a *API) reconcileStuckJobs(ctx context.Context, now time.Time) (int, error) {
+	cutoff := now.Add(-a.heartbeatStaleAfter)
+
+	count := 0
+	cursor := ""
+	for {
+		jobs, next, err := a.jobRepo.GetJobsByStatus(ctx, []models.JobStatus{models.JobStatusRunning}, cursor, reconcilePageSize)
+		if err != nil {
+			return count, errors.Join(err, errors.New("failed to get running jobs"))
+		}
+
+		for _, job := range jobs {
+			if !lastSignOfLife(job).Before(cutoff) {
+				continue
+			}
+
+			err := a.jobRepo.UpdateJobStatus(ctx, job.ID, models.JobStatusFailed,
+				repository.IfVersion(job.Version),
+				repository.IfHeartbeat(job.LastHeartbeatAt),
+				repository.WithFailure(models.FailureCodeInternal, stuckJobReason))
+			if errors.Is(err, repository.ErrVersionConflict) || errors.Is(err, repository.ErrJobAlive) {
+				continue
+			}
+			if err != nil {
+				return count, errors.Join(err, errors.New("failed to fail stuck job"))
+			}
+
+			a.failStuckJob(ctx, job, now)
+			count++
+		}
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	if count > 0 {
+		a.log.Info("Failed stuck jobs", slog.Int("count", count))
+	}
+	return count, nil
+}
+
+// failStuckJob records and publishes the failure of a stuck job, once it was stored, and fails its unfinished tasks
+func (a *API) failStuckJob(ctx context.Context, job *models.Job, now time.Time) {
+	a.audit.Record(ctx, audit.Record{Event: audit.EventJobFailed, JobID: job.ID, URL: job.URL, Status: string(models.JobStatusFailed)})
+	a.log.Warn("Failed stuck job",
+		slog.String("jobId", job.ID),
+		slog.Float64("silentSeconds", now.Sub(lastSignOfLife(job)).Seconds()))
+
+	progress, _ := models.TerminalProgress(models.JobStatusFailed)
+	err := a.mb.PublishJobUpdate(ctx, messagebus.JobUpdateMessage{
+		Type:          messagebus.JobUpdateMessageType,
+		JobID:         job.ID,
+		Status:        string(models.JobStatusFailed),
+		URL:           job.URL,
+		Progress:      &progress,
+		FailureCode:   models.FailureCodeInternal,
+		FailureReason: stuckJobReason,
+	})
+	if err != nil {
+		a.log.Warn("Failed to publish stuck job update",
+			slog.String("jobId", job.ID),
+			slog.Any("error", err))
+	}
+
+	a.failStuckTasks(ctx, job.ID)
+}
+
+// failStuckTasks marks the tasks of a stuck job that had not finished failed, as its analysis will not finish them
+func (a *API) failStuckTasks(ctx context.Context, jobID string) {
+	tasks, err := a.taskRepo.GetTasksByJobId(ctx, jobID)
+	if err != nil {
+		a.log.Warn("Failed to get tasks of stuck job", slog.String("jobId", jobID), slog.Any("error", err))
+		return
+	}
+
+	for _, task := range tasks {
+		if task.Status.IsTerminal() {
+			continue
+		}
+
+		if err := a.taskRepo.UpdateTaskStatus(ctx, jobID, task.Type, models.TaskStatusFailed); err != nil {
+			a.log.Warn("Failed to fail task of stuck job",
+				slog.String("jobId", jobID),
+				slog.String("taskType", string(task.Type)),
+				slog.Any("error", err))
+			continue
+		}
+		err := a.mb.PublishTaskStatusUpdate(ctx, messagebus.TaskStatusUpdateMessage{
+			Type:     messagebus.TaskStatusUpdateMessageType,
+			JobID:    jobID,
+			TaskType: string(task.Type),
+			Status:   string(models.TaskStatusFailed),
+		})
+		if err != nil {
+			a.log.Warn("Failed to publish stuck task update",
+				slog.String("jobId", jobID),
+				slog.String("taskType", string(task.Type)),
+				slog.Any("error", err))
+		}
+	}
+}
+
+// lastSignOfLife returns when a running job was last known to make progress: its latest heartbeat or update.
+// Jobs started by analyzers that send no heartbeats are judged on their updates alone.
+func lastSignOfLife(job *models.Job) time.Time {
+	if job.LastHeartbeatAt != nil && job.LastHeartbeatAt.After(job.UpdatedAt) {
+		return *job.LastHeartbeatAt
+	}
+	return job.UpdatedAt
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"errors"
+	"shared/messagebus"
+	"shared/models"
+	"shared/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAPI_ReconcileStuckJobs(t *testing.T) {
+	api, mockJobRepo, mockTaskRepo, mockMessageBus, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2024, 5, 8, 12, 0, 0, 0, time.UTC)
+	stale := now.Add(-defaultHeartbeatStaleAfter - time.Second)
+	recent := now.Add(-time.Minute)
+	running := []models.JobStatus{models.JobStatusRunning}
+
+	gomock.InOrder(
+		mockJobRepo.EXPECT().GetJobsByStatus(gomock.Any(), running, "", int64(reconcilePageSize)).Return([]*models.Job{
+			// Slow but alive, its heartbeat is recent though it has not been updated for a while
+			{ID: "job-4", UpdatedAt: stale, LastHeartbeatAt: &recent},
+			// Stuck, its analysis stopped sending heartbeats
+			{ID: "job-3", URL: "https://example.com", UpdatedAt: stale, LastHeartbeatAt: &stale, Version: 2},
+		}, "job-3", nil),
+		mockJobRepo.EXPECT().GetJobsByStatus(gomock.Any(), running, "job-3", int64(reconcilePageSize)).Return([]*models.Job{
+			// Started by an analyzer without heartbeats, judged on its updates
+			{ID: "job-2", UpdatedAt: recent},
+			// Sent a heartbeat between the query and the update
+			{ID: "job-1", UpdatedAt: stale, LastHeartbeatAt: &stale},
+		}, "", nil),
+	)
+	mockJobRepo.EXPECT().UpdateJobStatus(gomock.Any(), "job-3", models.JobStatusFailed, gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	mockJobRepo.EXPECT().UpdateJobStatus(gomock.Any(), "job-1", models.JobStatusFailed, gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(repository.ErrJobAlive)
+
+	var published messagebus.JobUpdateMessage
+	mockMessageBus.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ any, msg messagebus.JobUpdateMessage) error {
+			published = msg
+			return nil
+		})
+
+	// The tasks left unfinished are failed along with the job
+	mockTaskRepo.EXPECT().GetTasksByJobId(gomock.Any(), "job-3").Return([]models.Task{
+		{JobID: "job-3", Type: models.TaskTypeExtracting, Status: models.TaskStatusCompleted},
+		{JobID: "job-3", Type: models.TaskTypeVerifyingLinks, Status: models.TaskStatusRunning},
+		{JobID: "job-3", Type: models.TaskTypeIdentifyingVersion, Status: models.TaskStatusPending},
+	}, nil)
+	mockTaskRepo.EXPECT().UpdateTaskStatus(gomock.Any(), "job-3", models.TaskTypeVerifyingLinks, models.TaskStatusFailed).Return(nil)
+	mockTaskRepo.EXPECT().UpdateTaskStatus(gomock.Any(), "job-3", models.TaskTypeIdentifyingVersion, models.TaskStatusFailed).Return(nil)
+	var taskUpdates []string
+	mockMessageBus.EXPECT().PublishTaskStatusUpdate(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ any, msg messagebus.TaskStatusUpdateMessage) error {
+			assert.Equal(t, string(models.TaskStatusFailed), msg.Status)
+			taskUpdates = append(taskUpdates, msg.TaskType)
+			return nil
+		}).Times(2)
+
+	count, err := api.reconcileStuckJobs(t.Context(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []string{string(models.TaskTypeVerifyingLinks), string(models.TaskTypeIdentifyingVersion)}, taskUpdates)
+	assert.Equal(t, "job-3", published.JobID)
+	assert.Equal(t, string(models.JobStatusFailed), published.Status)
+	assert.Equal(t, models.FailureCodeInternal, published.FailureCode)
+	require.NotNil(t, published.Progress)
+	assert.Zero(t, *published.Progress)
+}
+
+func TestAPI_ReconcileStuckJobs_UpdateError(t *testing.T) {
+	api, mockJobRepo, _, _, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+	mockJobRepo.EXPECT().GetJobsByStatus(gomock.Any(), gomock.Any(), "", gomock.Any()).
+		Return([]*models.Job{{ID: "job-1", UpdatedAt: now.Add(-time.Hour)}}, "", nil)
+	mockJobRepo.EXPECT().UpdateJobStatus(gomock.Any(), "job-1", models.JobStatusFailed, gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(errors.New("database error"))
+
+	count, err := api.reconcileStuckJobs(t.Context(), now)
+	assert.Error(t, err)
+	assert.Zero(t, count)
+}
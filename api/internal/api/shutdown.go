@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"shared/middleware"
+
+	"github.com/yousuf64/shift"
+)
+
+// errShuttingDown is returned for a job submitted once shutdown waits for the submissions in progress
+var errShuttingDown = errors.New("server is shutting down")
+
+// Drain starts failing readiness while the server keeps serving, so load balancers stop sending requests
+// before the listener closes
+func (a *API) Drain() {
+	if a.draining.CompareAndSwap(false, true) {
+		a.log.Info("API server draining, readiness is now failing")
+	}
+}
+
+// handleReady handles the readiness probe, which fails once the server is draining
+func (a *API) handleReady(w http.ResponseWriter, r *http.Request, _ shift.Route) error {
+	if a.draining.Load() {
+		middleware.WriteError(w, r, http.StatusServiceUnavailable, "Shutting down")
+		return nil
+	}
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write([]byte("OK"))
+	return err
+}
+
+// Shutdown gracefully shuts down the server, waiting for the requests in flight until ctx is done.
+// Requests still running then are cancelled, and the jobs they were submitting are rolled back before it returns.
+func (a *API) Shutdown(ctx context.Context) error {
+	a.log.Info("Shutting down API server")
+	a.Drain()
+
+	var err error
+	if a.srv != nil {
+		if err = a.srv.Shutdown(ctx); err != nil {
+			a.log.Warn("Requests still running at the shutdown deadline, cancelling them", slog.Any("error", err))
+			a.cancelRequests()
+		}
+	}
+
+	a.submissionsMu.Lock()
+	a.submissionsClosed = true
+	a.submissionsMu.Unlock()
+	a.submissions.Wait()
+	return err
+}
+
+// beginSubmission counts a job submission in, unless shutdown already waits for them.
+// Counting in under the lock keeps it from racing with that wait.
+func (a *API) beginSubmission() bool {
+	a.submissionsMu.Lock()
+	defer a.submissionsMu.Unlock()
+
+	if a.submissionsClosed {
+		return false
+	}
+	a.submissions.Add(1)
+	return true
+}
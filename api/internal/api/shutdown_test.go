@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"shared/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// serveAPI serves the API on a free local port, returning its base URL
+func serveAPI(t *testing.T, a *API) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	a.srv = a.newServer(context.Background(), nil)
+	go a.srv.Serve(ln)
+	t.Cleanup(func() { a.srv.Close() })
+	return "http://" + ln.Addr().String()
+}
+
+// postAnalyze sends an analyze request in the background, the returned channel yields its status or 0 on error
+func postAnalyze(baseURL string) <-chan int {
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Post(baseURL+"/analyze", "application/json", strings.NewReader(`{"url":"https://example.com"}`))
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+	return status
+}
+
+func TestAPI_Readiness(t *testing.T) {
+	api, _, _, _, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+	baseURL := serveAPI(t, api)
+
+	resp, err := http.Get(baseURL + "/readyz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	api.Drain()
+
+	// The server keeps serving while draining, only readiness fails
+	resp, err = http.Get(baseURL + "/readyz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestAPI_Shutdown_SlowHandlerFinishes(t *testing.T) {
+	api, jobRepo, taskRepo, mb, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+	baseURL := serveAPI(t, api)
+
+	entered := make(chan struct{})
+	jobRepo.EXPECT().CreateJob(gomock.Any(), gomock.Any()).Return(nil)
+	taskRepo.EXPECT().CreateTasks(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, ...*models.Task) error {
+		close(entered)
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	})
+	mb.EXPECT().PublishAnalyzeMessage(gomock.Any(), gomock.Any()).Return(nil)
+
+	status := postAnalyze(baseURL)
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, api.Shutdown(ctx))
+	assert.Equal(t, http.StatusAccepted, <-status, "a request in flight should complete during shutdown")
+}
+
+func TestAPI_Shutdown_SlowHandlerRolledBack(t *testing.T) {
+	api, jobRepo, taskRepo, _, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+	baseURL := serveAPI(t, api)
+
+	// The job store stands in for the jobs table, the test checks nothing is left pending in it
+	pending := make(map[string]bool)
+	entered := make(chan struct{})
+	jobRepo.EXPECT().CreateJob(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, job *models.Job) error {
+		pending[job.ID] = true
+		return nil
+	})
+	taskRepo.EXPECT().CreateTasks(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, _ ...*models.Task) error {
+		close(entered)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	jobRepo.EXPECT().DiscardJob(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, id string) (bool, error) {
+		assert.NoError(t, ctx.Err(), "the rollback should not run under the cancelled request context")
+		discarded := pending[id]
+		delete(pending, id)
+		return discarded, nil
+	})
+	taskRepo.EXPECT().DeleteTasksByJobId(gomock.Any(), gomock.Any()).Return(nil)
+
+	status := postAnalyze(baseURL)
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, api.Shutdown(ctx), context.DeadlineExceeded)
+
+	// Shutdown returns once the submission was rolled back
+	assert.Empty(t, pending, "no pending job should be left behind")
+	assert.Equal(t, http.StatusInternalServerError, <-status)
+}
+
+func TestAPI_Shutdown_RejectsLateSubmission(t *testing.T) {
+	api, _, _, _, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+
+	require.NoError(t, api.Shutdown(context.Background()))
+
+	// Nothing is written for a job submitted once shutdown waited for the submissions, the mocks expect no call
+	err := api.submitJob(context.Background(), &models.Job{ID: "job-1", URL: "https://example.com"})
+	assert.ErrorIs(t, err, errShuttingDown)
+}
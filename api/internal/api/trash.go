@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"shared/audit"
+	"shared/middleware"
+	"shared/models"
+	"shared/repository"
+	"strings"
+	"time"
+
+	"github.com/yousuf64/shift"
+)
+
+// defaultRestoreWindow is how long a deleted job can be restored when no window is configured
+const defaultRestoreWindow = 7 * 24 * time.Hour
+
+// purgePageSize is the number of deleted jobs read per page while purging
+const purgePageSize = 100
+
+// DeleteJobResponse is the response body for the delete job endpoint
+type DeleteJobResponse struct {
+	JobID     string    `json:"job_id"`
+	DeletedAt time.Time `json:"deleted_at"`
+	// RestorableUntil is when the restore window closes and the job becomes due for purging
+	RestorableUntil time.Time `json:"restorable_until"`
+}
+
+// RestoreJobResponse is the response body for the restore job endpoint
+type RestoreJobResponse struct {
+	JobID    string `json:"job_id"`
+	Restored bool   `json:"restored"`
+}
+
+// handleDeleteJob handles the delete job endpoint, moving the job to the trash rather than removing it
+func (a *API) handleDeleteJob(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+	ctx := r.Context()
+	jobID := route.Params.Get("job_id")
+
+	if strings.TrimSpace(jobID) == "" {
+		return errors.New("job_id is required")
+	}
+
+	job, err := a.jobRepo.GetJob(ctx, jobID)
+	if errors.Is(err, repository.ErrJobNotFound) {
+		middleware.WriteError(w, r, http.StatusNotFound, "Job not found")
+		return nil
+	}
+	if err != nil {
+		return errors.Join(err, errors.New("failed to get job"))
+	}
+
+	if job.IsDeleted() {
+		middleware.WriteError(w, r, http.StatusGone, "Job has been deleted")
+		return nil
+	}
+
+	deletedAt := time.Now().UTC()
+	deleted, err := a.jobRepo.DeleteJob(ctx, jobID, deletedAt)
+	if err != nil {
+		return errors.Join(err, errors.New("failed to delete job"))
+	}
+	if !deleted {
+		middleware.WriteError(w, r, http.StatusGone, "Job has been deleted")
+		return nil
+	}
+
+	a.evictCachedJob(jobID)
+	a.audit.Record(ctx, audit.Record{Event: audit.EventJobDeleted, JobID: jobID, Status: string(job.Status)})
+	a.log.Info("Job deleted", slog.String("jobId", jobID))
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(DeleteJobResponse{
+		JobID:           jobID,
+		DeletedAt:       deletedAt,
+		RestorableUntil: deletedAt.Add(a.restoreWindow),
+	})
+}
+
+// handleRestoreJob handles the restore job endpoint, taking a deleted job out of the trash within the restore window
+func (a *API) handleRestoreJob(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+	ctx := r.Context()
+	jobID := route.Params.Get("job_id")
+
+	if strings.TrimSpace(jobID) == "" {
+		return errors.New("job_id is required")
+	}
+
+	job, err := a.jobRepo.GetJob(ctx, jobID)
+	if errors.Is(err, repository.ErrJobNotFound) {
+		middleware.WriteError(w, r, http.StatusNotFound, "Job not found")
+		return nil
+	}
+	if err != nil {
+		return errors.Join(err, errors.New("failed to get job"))
+	}
+
+	if !job.IsDeleted() {
+		middleware.WriteError(w, r, http.StatusConflict, "Job is not deleted")
+		return nil
+	}
+	if !time.Now().Before(job.DeletedAt.Add(a.restoreWindow)) {
+		middleware.WriteError(w, r, http.StatusGone, "Restore window has passed")
+		return nil
+	}
+
+	restored, err := a.jobRepo.RestoreJob(ctx, jobID, *job.DeletedAt)
+	if err != nil {
+		return errors.Join(err, errors.New("failed to restore job"))
+	}
+	if !restored {
+		// Restored or purged since it was read
+		middleware.WriteError(w, r, http.StatusConflict, "Job is not deleted")
+		return nil
+	}
+
+	a.evictCachedJob(jobID)
+	a.audit.Record(ctx, audit.Record{Event: audit.EventJobRestored, JobID: jobID, Status: string(job.Status)})
+	a.log.Info("Job restored", slog.String("jobId", jobID))
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(RestoreJobResponse{JobID: jobID, Restored: true})
+}
+
+// rejectDeletedJob answers 410 for a job in the trash, reporting whether it did
+func rejectDeletedJob(w http.ResponseWriter, r *http.Request, job *models.Job) bool {
+	if !job.IsDeleted() {
+		return false
+	}
+	middleware.WriteError(w, r, http.StatusGone, "Job has been deleted")
+	return true
+}
+
+// RunPurgeSweeper purges the deleted jobs past their restore window every interval, until ctx is done
+func (a *API) RunPurgeSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := a.purgeExpiredJobs(ctx, time.Now()); err != nil {
+				a.log.Error("Failed to purge deleted jobs", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// purgeExpiredJobs permanently removes the deleted jobs whose restore window had passed by now, along with their tasks.
+// It returns the number of jobs purged.
+func (a *API) purgeExpiredJobs(ctx context.Context, now time.Time) (int, error) {
+	cutoff := now.Add(-a.restoreWindow)
+
+	count := 0
+	cursor := ""
+	for {
+		jobs, next, err := a.jobRepo.GetDeletedJobs(ctx, cursor, purgePageSize)
+		if err != nil {
+			return count, errors.Join(err, errors.New("failed to get deleted jobs"))
+		}
+
+		for _, job := range jobs {
+			if job.DeletedAt == nil || job.DeletedAt.After(cutoff) {
+				continue
+			}
+
+			purged, err := a.jobRepo.PurgeJob(ctx, job.ID, *job.DeletedAt)
+			if err != nil {
+				return count, errors.Join(err, errors.New("failed to purge job"))
+			}
+			if !purged {
+				continue
+			}
+			a.evictCachedJob(job.ID)
+
+			// The job goes first, a failure here leaves orphaned tasks rather than a job missing its tasks
+			if err := a.taskRepo.DeleteTasksByJobId(ctx, job.ID); err != nil {
+				a.log.Warn("Failed to delete tasks of purged job",
+					slog.String("jobId", job.ID),
+					slog.Any("error", err))
+			}
+			a.audit.Record(ctx, audit.Record{Event: audit.EventJobPurged, JobID: job.ID, Status: string(job.Status)})
+			count++
+		}
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	if count > 0 {
+		a.log.Info("Purged deleted jobs", slog.Int("count", count))
+	}
+	return count, nil
+}
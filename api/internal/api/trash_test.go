@@ -0,0 +1,259 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"shared/mocks"
+	"shared/models"
+	"shared/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAPI_HandleDeleteJob_TableDriven(t *testing.T) {
+	deletedAt := time.Now().Add(-time.Hour)
+
+	testCases := []struct {
+		name           string
+		jobID          string
+		setupMocks     func(*mocks.MockJobRepositoryInterface)
+		expectedStatus int
+	}{
+		{
+			name:  "DeleteJob",
+			jobID: "job-1",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(&models.Job{ID: "job-1", Status: models.JobStatusCompleted}, nil)
+				jobRepo.EXPECT().DeleteJob(gomock.Any(), "job-1", gomock.Any()).Return(true, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:  "AlreadyDeleted",
+			jobID: "job-2",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-2").Return(&models.Job{ID: "job-2", DeletedAt: &deletedAt}, nil)
+			},
+			expectedStatus: http.StatusGone,
+		},
+		{
+			name:  "DeletedConcurrently",
+			jobID: "job-3",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-3").Return(&models.Job{ID: "job-3"}, nil)
+				jobRepo.EXPECT().DeleteJob(gomock.Any(), "job-3", gomock.Any()).Return(false, nil)
+			},
+			expectedStatus: http.StatusGone,
+		},
+		{
+			name:  "JobNotFound",
+			jobID: "missing",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "missing").Return(nil, repository.ErrJobNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:  "DatabaseError",
+			jobID: "job-4",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-4").Return(&models.Job{ID: "job-4"}, nil)
+				jobRepo.EXPECT().DeleteJob(gomock.Any(), "job-4", gomock.Any()).Return(false, errors.New("database error"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, _, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+
+			tc.setupMocks(mockJobRepo)
+
+			req, err := makeRequest("DELETE", "/jobs/"+tc.jobID, nil)
+			assert.NoError(t, err, "Failed to create request")
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("DELETE", "/jobs/:job_id", api.handleDeleteJob)
+			router.Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedStatus == http.StatusOK {
+				var resp DeleteJobResponse
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+				assert.Equal(t, tc.jobID, resp.JobID)
+				assert.Equal(t, defaultRestoreWindow, resp.RestorableUntil.Sub(resp.DeletedAt))
+			}
+		})
+	}
+}
+
+func TestAPI_HandleRestoreJob_TableDriven(t *testing.T) {
+	recent := time.Now().Add(-time.Hour)
+	expired := time.Now().Add(-defaultRestoreWindow - time.Minute)
+
+	testCases := []struct {
+		name           string
+		jobID          string
+		setupMocks     func(*mocks.MockJobRepositoryInterface)
+		expectedStatus int
+	}{
+		{
+			name:  "RestoreWithinWindow",
+			jobID: "job-1",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-1").Return(&models.Job{ID: "job-1", DeletedAt: &recent}, nil)
+				jobRepo.EXPECT().RestoreJob(gomock.Any(), "job-1", recent).Return(true, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:  "WindowPassed",
+			jobID: "job-2",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-2").Return(&models.Job{ID: "job-2", DeletedAt: &expired}, nil)
+			},
+			expectedStatus: http.StatusGone,
+		},
+		{
+			name:  "NotDeleted",
+			jobID: "job-3",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-3").Return(&models.Job{ID: "job-3"}, nil)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:  "RestoredConcurrently",
+			jobID: "job-4",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "job-4").Return(&models.Job{ID: "job-4", DeletedAt: &recent}, nil)
+				jobRepo.EXPECT().RestoreJob(gomock.Any(), "job-4", recent).Return(false, nil)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:  "JobNotFound",
+			jobID: "missing",
+			setupMocks: func(jobRepo *mocks.MockJobRepositoryInterface) {
+				jobRepo.EXPECT().GetJob(gomock.Any(), "missing").Return(nil, repository.ErrJobNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, _, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+
+			tc.setupMocks(mockJobRepo)
+
+			req, err := makeRequest("POST", "/jobs/"+tc.jobID+"/restore", nil)
+			assert.NoError(t, err, "Failed to create request")
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("POST", "/jobs/:job_id/restore", api.handleRestoreJob)
+			router.Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func TestAPI_HandleGetJobs_Trash(t *testing.T) {
+	deletedAt := time.Now()
+	jobs := func() []*models.Job {
+		return []*models.Job{{ID: "job-2"}, {ID: "job-1", DeletedAt: &deletedAt}}
+	}
+
+	testCases := []struct {
+		name           string
+		query          string
+		authorization  string
+		expectedStatus int
+		expectedIDs    []string
+	}{
+		{name: "DeletedLeftOut", expectedStatus: http.StatusOK, expectedIDs: []string{"job-2"}},
+		{
+			name:           "AdminIncludesDeleted",
+			query:          "?include_deleted=true",
+			authorization:  "Bearer admin-token",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"job-2", "job-1"},
+		},
+		{name: "IncludeDeletedRequiresAdmin", query: "?include_deleted=true", expectedStatus: http.StatusForbidden},
+		{
+			name:           "WrongToken",
+			query:          "?include_deleted=true",
+			authorization:  "Bearer guess",
+			expectedStatus: http.StatusForbidden,
+		},
+		{name: "InvalidValue", query: "?include_deleted=maybe", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			api, mockJobRepo, _, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+			WithAdminToken("admin-token")(api)
+
+			if tc.expectedStatus == http.StatusOK {
+				mockJobRepo.EXPECT().GetAllJobs(gomock.Any()).Return(jobs(), nil)
+			}
+
+			req, err := makeRequest("GET", "/jobs"+tc.query, nil)
+			assert.NoError(t, err, "Failed to create request")
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+
+			rr := httptest.NewRecorder()
+			router := setupRouter("GET", "/jobs", api.handleGetJobs)
+			router.Serve().ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedStatus, rr.Code)
+			if tc.expectedStatus == http.StatusOK {
+				var listed []models.Job
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &listed))
+				ids := make([]string, 0, len(listed))
+				for _, job := range listed {
+					ids = append(ids, job.ID)
+				}
+				assert.Equal(t, tc.expectedIDs, ids)
+			}
+		})
+	}
+}
+
+func TestAPI_PurgeExpiredJobs(t *testing.T) {
+	api, mockJobRepo, mockTaskRepo, _, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+	expired := now.Add(-defaultRestoreWindow - time.Minute)
+	alsoExpired := now.Add(-defaultRestoreWindow - time.Hour)
+	recent := now.Add(-time.Hour)
+
+	gomock.InOrder(
+		mockJobRepo.EXPECT().GetDeletedJobs(gomock.Any(), "", int64(purgePageSize)).
+			Return([]*models.Job{{ID: "job-3", DeletedAt: &recent}, {ID: "job-2", DeletedAt: &expired}}, "job-2", nil),
+		mockJobRepo.EXPECT().GetDeletedJobs(gomock.Any(), "job-2", int64(purgePageSize)).
+			Return([]*models.Job{{ID: "job-1", DeletedAt: &alsoExpired}}, "", nil),
+	)
+	mockJobRepo.EXPECT().PurgeJob(gomock.Any(), "job-2", expired).Return(true, nil)
+	// Restored between the query and the purge, so its tasks are kept
+	mockJobRepo.EXPECT().PurgeJob(gomock.Any(), "job-1", alsoExpired).Return(false, nil)
+	mockTaskRepo.EXPECT().DeleteTasksByJobId(gomock.Any(), "job-2").Return(nil)
+
+	count, err := api.purgeExpiredJobs(t.Context(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
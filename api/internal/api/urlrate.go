@@ -0,0 +1,169 @@
+package api
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"shared/log"
+	"shared/middleware"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// urlRateLimiter caps the jobs created for the same URL within a sliding window, damping feedback loops where
+// jobs lead to more jobs for the same pages. The jobs are counted in memory, so each replica caps its own.
+type urlRateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	// created holds the creation times of the jobs of each URL within the window, oldest first
+	created   map[string][]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newURLRateLimiter(limit int, window time.Duration) *urlRateLimiter {
+	return &urlRateLimiter{
+		limit:   limit,
+		window:  window,
+		created: make(map[string][]time.Time),
+		now:     time.Now,
+	}
+}
+
+// WithURLRateLimit caps the jobs created for the same URL to limit per window. A zero limit or window lifts the cap.
+func WithURLRateLimit(limit int, window time.Duration) Option {
+	return func(a *API) {
+		a.urlRate = nil
+		if limit > 0 && window > 0 {
+			a.urlRate = newURLRateLimiter(limit, window)
+		}
+	}
+}
+
+// reserve counts a job for each of urls when none of them has reached the limit. Otherwise nothing is counted,
+// and it returns the first URL at the limit along with how long until it gets a job again.
+func (l *urlRateLimiter) reserve(urls ...string) (string, time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	keys := make([]string, len(urls))
+	pending := make(map[string]int, len(urls))
+	for i, u := range urls {
+		keys[i] = urlRateKey(u)
+		times := l.recent(keys[i], now)
+		pending[keys[i]]++
+		if len(times)+pending[keys[i]] > l.limit {
+			retryAfter := l.window
+			if len(times) > 0 {
+				retryAfter = times[0].Add(l.window).Sub(now)
+			}
+			return u, retryAfter, false
+		}
+	}
+
+	for _, key := range keys {
+		l.created[key] = append(l.created[key], now)
+	}
+	return "", 0, true
+}
+
+// release uncounts the latest job of url, one that was counted but never queued
+func (l *urlRateLimiter) release(url string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := urlRateKey(url)
+	times := l.created[key]
+	switch len(times) {
+	case 0:
+	case 1:
+		delete(l.created, key)
+	default:
+		l.created[key] = times[:len(times)-1]
+	}
+}
+
+// recent drops the jobs of key that left the window and returns the others
+func (l *urlRateLimiter) recent(key string, now time.Time) []time.Time {
+	times := l.created[key]
+	i := 0
+	for i < len(times) && !now.Before(times[i].Add(l.window)) {
+		i++
+	}
+	if i == len(times) {
+		delete(l.created, key)
+		return nil
+	}
+	l.created[key] = times[i:]
+	return times[i:]
+}
+
+// sweep forgets the URLs without a job in the window, at most once per window
+func (l *urlRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	l.lastSweep = now
+	for key := range l.created {
+		l.recent(key, now)
+	}
+}
+
+// urlRateKey normalizes a URL for counting its jobs: the scheme and host in lower case, without the default port,
+// the fragment or a trailing slash. URLs that do not parse are counted as they are.
+func urlRateKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		u.Host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	} else {
+		u.Host = host
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = strings.TrimSuffix(u.RawPath, "/")
+	return u.String()
+}
+
+// rejectIfURLRateLimited answers 429 with a Retry-After header when one of the URLs got too many jobs lately,
+// reporting whether it did. Otherwise a job is counted for each of them, until it is rolled back.
+func (a *API) rejectIfURLRateLimited(w http.ResponseWriter, r *http.Request, urls ...string) bool {
+	if a.urlRate == nil {
+		return false
+	}
+
+	limited, retryAfter, ok := a.urlRate.reserve(urls...)
+	if ok {
+		return false
+	}
+
+	a.log.Warn("Refusing job, too many jobs for the URL",
+		log.URL("url", limited),
+		slog.Duration("retryAfter", retryAfter))
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	middleware.WriteError(w, r, http.StatusTooManyRequests, "Too many jobs for "+limited+" lately, please retry later")
+	return true
+}
+
+// releaseURLRate uncounts a job that was rolled back, so a failed submission does not use up a slot of its URL
+func (a *API) releaseURLRate(url string) {
+	if a.urlRate != nil {
+		a.urlRate.release(url)
+	}
+}
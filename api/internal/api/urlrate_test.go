@@ -0,0 +1,168 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yousuf64/shift"
+	"go.uber.org/mock/gomock"
+)
+
+func TestURLRateLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := newURLRateLimiter(2, time.Hour)
+	limiter.now = func() time.Time { return now }
+
+	_, _, ok := limiter.reserve("https://example.com")
+	require.True(t, ok)
+	now = now.Add(10 * time.Minute)
+	_, _, ok = limiter.reserve("https://EXAMPLE.com:443/")
+	require.True(t, ok, "the second job of the URL is under the limit")
+
+	limited, retryAfter, ok := limiter.reserve("https://example.com#top")
+	assert.False(t, ok)
+	assert.Equal(t, "https://example.com#top", limited)
+	assert.Equal(t, 50*time.Minute, retryAfter, "a slot frees up once the oldest job leaves the window")
+
+	_, _, ok = limiter.reserve("https://example.org", "https://example.com")
+	assert.False(t, ok, "a group with a URL at the limit is refused")
+	_, _, ok = limiter.reserve("https://example.org")
+	assert.True(t, ok, "nothing is counted for a refused group")
+	_, _, ok = limiter.reserve("https://example.org", "https://example.org/")
+	assert.False(t, ok, "the URLs of a group count against each other")
+
+	now = now.Add(50 * time.Minute)
+	_, _, ok = limiter.reserve("https://example.com")
+	assert.True(t, ok)
+
+	now = now.Add(2 * time.Hour)
+	limiter.reserve("https://example.net")
+	assert.Len(t, limiter.created, 1, "URLs without a job in the window are forgotten")
+}
+
+func TestURLRateKey(t *testing.T) {
+	testCases := []struct {
+		url      string
+		expected string
+	}{
+		{url: "https://example.com", expected: "https://example.com"},
+		{url: "HTTPS://Example.COM/", expected: "https://example.com"},
+		{url: "https://example.com:443/docs/#intro", expected: "https://example.com/docs"},
+		{url: "http://example.com:80/docs?page=2", expected: "http://example.com/docs?page=2"},
+		{url: "https://example.com:8443/Docs", expected: "https://example.com:8443/Docs"},
+		{url: "https://[2001:DB8::1]:443/", expected: "https://[2001:db8::1]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			assert.Equal(t, tc.expected, urlRateKey(tc.url))
+		})
+	}
+}
+
+func TestAPI_HandleAnalyze_URLRateLimited(t *testing.T) {
+	testCases := []struct {
+		name    string
+		path    string
+		body    any
+		handler func(api *API) shift.HandlerFunc
+	}{
+		{
+			name:    "Analyze",
+			path:    "/analyze",
+			body:    AnalyzeRequest{URL: "example.com"},
+			handler: func(api *API) shift.HandlerFunc { return api.handleAnalyze },
+		},
+		{
+			name:    "AnalyzeGroup",
+			path:    "/analyze/group",
+			body:    AnalyzeGroupRequest{URLs: []string{"https://example.org", "https://example.com/"}},
+			handler: func(api *API) shift.HandlerFunc { return api.handleAnalyzeGroup },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// No job is created, the mocks expect no call
+			api, _, _, _, ctrl := setupMockAPI(t)
+			defer ctrl.Finish()
+			WithURLRateLimit(1, time.Hour)(api)
+			_, _, ok := api.urlRate.reserve("https://example.com")
+			require.True(t, ok)
+
+			req, err := makeRequest("POST", tc.path, tc.body)
+			require.NoError(t, err)
+			rr := httptest.NewRecorder()
+			setupRouter("POST", tc.path, tc.handler(api)).Serve().ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+			assert.Equal(t, "3600", rr.Header().Get("Retry-After"))
+		})
+	}
+}
+
+func TestAPI_HandleAnalyze_URLRateLimit_CountsAcceptedJobs(t *testing.T) {
+	api, jobRepo, taskRepo, mb, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+	WithURLRateLimit(2, time.Hour)(api)
+
+	jobRepo.EXPECT().CreateJob(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	taskRepo.EXPECT().CreateTasks(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	mb.EXPECT().PublishAnalyzeMessage(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+	expected := []int{http.StatusAccepted, http.StatusAccepted, http.StatusTooManyRequests}
+	for i, status := range expected {
+		req, err := makeRequest("POST", "/analyze", AnalyzeRequest{URL: "https://example.com"})
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		setupRouter("POST", "/analyze", api.handleAnalyze).Serve().ServeHTTP(rr, req)
+		assert.Equal(t, status, rr.Code, "request %d", i+1)
+	}
+}
+
+func TestAPI_HandleAnalyze_URLRateLimit_ReleasesFailedJobs(t *testing.T) {
+	api, jobRepo, taskRepo, mb, ctrl := setupMockAPI(t)
+	defer ctrl.Finish()
+	WithURLRateLimit(1, time.Hour)(api)
+
+	// The first two submissions cannot be queued and are rolled back, the third is
+	jobRepo.EXPECT().CreateJob(gomock.Any(), gomock.Any()).Return(nil).Times(3)
+	taskRepo.EXPECT().CreateTasks(gomock.Any(), gomock.Any()).Return(nil).Times(3)
+	gomock.InOrder(
+		mb.EXPECT().PublishAnalyzeMessage(gomock.Any(), gomock.Any()).Return(errors.New("nats unavailable")).Times(2),
+		mb.EXPECT().PublishAnalyzeMessage(gomock.Any(), gomock.Any()).Return(nil),
+	)
+	jobRepo.EXPECT().DiscardJob(gomock.Any(), gomock.Any()).Return(true, nil).Times(2)
+	taskRepo.EXPECT().DeleteTasksByJobId(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+	expected := []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusAccepted, http.StatusTooManyRequests}
+	for i, status := range expected {
+		req, err := makeRequest("POST", "/analyze", AnalyzeRequest{URL: "https://example.com"})
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		setupRouter("POST", "/analyze", api.handleAnalyze).Serve().ServeHTTP(rr, req)
+		assert.Equal(t, status, rr.Code, "request %d", i+1)
+	}
+}
+
+func TestURLRateLimiter_Release(t *testing.T) {
+	l := newURLRateLimiter(2, time.Hour)
+
+	_, _, ok := l.reserve("https://example.com", "https://example.org")
+	require.True(t, ok)
+	l.release("https://example.com/")
+	l.release("https://example.net")
+
+	_, _, ok = l.reserve("https://example.com", "https://example.com")
+	assert.True(t, ok, "the released job no longer counts")
+	_, _, ok = l.reserve("https://example.org")
+	assert.True(t, ok)
+	limited, _, ok := l.reserve("https://example.org")
+	assert.False(t, ok)
+	assert.Equal(t, "https://example.org", limited)
+}
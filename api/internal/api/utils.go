@@ -24,12 +24,16 @@ func generateID() string {
 	return ulid.MustNew(ts, e).String()
 }
 
-// getDefaultTasks returns the default tasks for a job
-func getDefaultTasks(jobID string) []*models.Task {
-	return []*models.Task{
-		{JobID: jobID, Type: models.TaskTypeExtracting, Status: models.TaskStatusPending, SubTasks: make(map[string]models.SubTask)},
-		{JobID: jobID, Type: models.TaskTypeIdentifyingVersion, Status: models.TaskStatusPending, SubTasks: make(map[string]models.SubTask)},
-		{JobID: jobID, Type: models.TaskTypeAnalyzing, Status: models.TaskStatusPending, SubTasks: make(map[string]models.SubTask)},
-		{JobID: jobID, Type: models.TaskTypeVerifyingLinks, Status: models.TaskStatusPending, SubTasks: make(map[string]models.SubTask)},
+// getDefaultTasks returns the tasks for a job, the ones not selected for it are created as skipped
+func getDefaultTasks(job *models.Job) []*models.Task {
+	taskTypes := models.TaskTypes()
+	tasks := make([]*models.Task, 0, len(taskTypes))
+	for _, taskType := range taskTypes {
+		status := models.TaskStatusPending
+		if !job.RunsTask(taskType) {
+			status = models.TaskStatusSkipped
+		}
+		tasks = append(tasks, &models.Task{JobID: job.ID, Type: taskType, Status: status, SubTasks: make(map[string]models.SubTask)})
 	}
+	return tasks
 }
@@ -6,11 +6,35 @@ import (
 	"net"
 	"net/url"
 	"regexp"
+	sharedconfig "shared/config"
+	"shared/models"
 	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// URL and hostname length limits, per RFC 1035 for the hostname and its labels
+const (
+	maxURLLength      = 2048
+	maxHostnameLength = 253
+	maxLabelLength    = 63
 )
 
-// validHostnameRegex is a regular expression to validate hostnames
-var validHostnameRegex = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?\.)*[a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?$`)
+// validLabelRegex matches a hostname label in its ASCII form: letters, digits and inner hyphens
+var validLabelRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// numericLabelRegex matches an all-numeric label, which a top-level domain must not be
+var numericLabelRegex = regexp.MustCompile(`^[0-9]+$`)
+
+// errOwnHost is returned for URLs on one of the platform's own hosts
+var errOwnHost = errors.New("the url points to this service, its own pages cannot be analyzed")
+
+// WithSelfProtection sets the platform's own hosts, which jobs are refused for
+func WithSelfProtection(cfg sharedconfig.SelfProtectionConfig) Option {
+	return func(a *API) {
+		a.self = cfg
+	}
+}
 
 // validateURL validates the URL
 func validateURL(rawURL string) (string, error) {
@@ -20,8 +44,8 @@ func validateURL(rawURL string) (string, error) {
 
 	rawURL = strings.TrimSpace(rawURL)
 
-	if len(rawURL) > 2048 {
-		return "", errors.New("url too long (max 2048 characters)")
+	if len(rawURL) > maxURLLength {
+		return "", fmt.Errorf("url too long (max %d characters)", maxURLLength)
 	}
 
 	if !strings.Contains(rawURL, "://") {
@@ -47,10 +71,20 @@ func validateURL(rawURL string) (string, error) {
 		return "", errors.New("invalid hostname")
 	}
 
-	if err := validateHostname(hostname); err != nil {
+	asciiHostname, err := validateHostname(hostname)
+	if err != nil {
 		return "", fmt.Errorf("invalid hostname: %w", err)
 	}
 
+	// Hostnames are submitted in their ASCII form, punycode for internationalized ones, so equal hosts compare equal
+	if asciiHostname != hostname {
+		if port := u.Port(); port != "" {
+			u.Host = net.JoinHostPort(asciiHostname, port)
+		} else {
+			u.Host = asciiHostname
+		}
+	}
+
 	if strings.Contains(u.Path, "..") {
 		return "", errors.New("path traversal patterns are not allowed")
 	}
@@ -58,27 +92,63 @@ func validateURL(rawURL string) (string, error) {
 	return u.String(), nil
 }
 
-// validateHostname validates the hostname
-func validateHostname(hostname string) error {
-	if isLocalhost(hostname) {
-		return errors.New("localhost and loopback addresses are not allowed")
+// checkOwnHost returns errOwnHost when the URL, as returned by validateURL, is on one of the own hosts.
+// A job for the platform's own pages finds links back into it, each one a candidate for yet another job.
+func checkOwnHost(validatedURL string, self sharedconfig.SelfProtectionConfig) error {
+	u, err := url.Parse(validatedURL)
+	if err != nil {
+		return fmt.Errorf("invalid url format: %w", err)
+	}
+	if self.IsOwnHost(u.Host) {
+		return errOwnHost
+	}
+	return nil
+}
+
+// validateHostname validates the hostname and returns its lowercase ASCII form.
+// Internationalized names are converted to punycode and a single trailing dot is dropped before validating.
+func validateHostname(hostname string) (string, error) {
+	if net.ParseIP(hostname) != nil {
+		if isLocalhost(hostname) {
+			return "", errors.New("localhost and loopback addresses are not allowed")
+		}
+		if isPrivateIP(hostname) {
+			return "", errors.New("private IP addresses are not allowed")
+		}
+		return hostname, nil
 	}
 
-	if isPrivateIP(hostname) {
-		return errors.New("private IP addresses are not allowed")
+	ascii, err := idna.Lookup.ToASCII(strings.TrimSuffix(hostname, "."))
+	if err != nil {
+		return "", fmt.Errorf("invalid hostname format: %w", err)
 	}
 
-	if !validHostnameRegex.MatchString(hostname) {
-		if net.ParseIP(hostname) == nil {
-			return errors.New("invalid hostname or IP address format")
+	if isLocalhost(ascii) {
+		return "", errors.New("localhost and loopback addresses are not allowed")
+	}
+
+	if len(ascii) > maxHostnameLength {
+		return "", fmt.Errorf("hostname too long (max %d characters)", maxHostnameLength)
+	}
+
+	labels := strings.Split(ascii, ".")
+	for _, label := range labels {
+		if label == "" {
+			return "", errors.New("hostname has an empty label")
+		}
+		if len(label) > maxLabelLength {
+			return "", fmt.Errorf("hostname label too long (max %d characters)", maxLabelLength)
+		}
+		if !validLabelRegex.MatchString(label) {
+			return "", fmt.Errorf("invalid hostname label %q", label)
 		}
 	}
 
-	if len(hostname) > 253 {
-		return errors.New("hostname too long (max 253 characters)")
+	if numericLabelRegex.MatchString(labels[len(labels)-1]) {
+		return "", errors.New("top-level domain must not be all-numeric")
 	}
 
-	return nil
+	return ascii, nil
 }
 
 // isLocalhost checks if the hostname is a localhost address
@@ -116,3 +186,38 @@ func isPrivateIP(hostname string) bool {
 	}
 	return false
 }
+
+// validateTaskSelection validates the requested tasks and returns them in the order they run.
+// Extracting is always selected, as every other task works on the parsed page.
+// It returns nil when no tasks or all of them are requested, so the job runs every task.
+func validateTaskSelection(tasks []string) ([]models.TaskType, error) {
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+
+	requested := map[models.TaskType]bool{models.TaskTypeExtracting: true}
+	for _, task := range tasks {
+		taskType := models.TaskType(strings.TrimSpace(task))
+		if !models.IsValidTaskType(taskType) {
+			return nil, fmt.Errorf("unknown task %q", task)
+		}
+		requested[taskType] = true
+	}
+
+	if requested[models.TaskTypeVerifyingLinks] && !requested[models.TaskTypeAnalyzing] {
+		return nil, fmt.Errorf("task %q requires %q", models.TaskTypeVerifyingLinks, models.TaskTypeAnalyzing)
+	}
+
+	taskTypes := models.TaskTypes()
+	if len(requested) == len(taskTypes) {
+		return nil, nil
+	}
+
+	selected := make([]models.TaskType, 0, len(requested))
+	for _, taskType := range taskTypes {
+		if requested[taskType] {
+			selected = append(selected, taskType)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	sharedconfig "shared/config"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateURL_Hostnames(t *testing.T) {
+	testCases := []struct {
+		name     string
+		url      string
+		expected string
+		wantErr  string
+	}{
+		{name: "Plain", url: "https://example.com/path", expected: "https://example.com/path"},
+		{name: "Lowercased", url: "https://WWW.Example.COM", expected: "https://www.example.com"},
+		{name: "TrailingDot", url: "https://example.com./a", expected: "https://example.com/a"},
+		{name: "IDN", url: "https://münchen.de/stadt", expected: "https://xn--mnchen-3ya.de/stadt"},
+		{name: "IDNWithPort", url: "http://bücher.example:8080", expected: "http://xn--bcher-kva.example:8080"},
+		{name: "Punycode", url: "https://xn--mnchen-3ya.de", expected: "https://xn--mnchen-3ya.de"},
+		{name: "JapaneseIDN", url: "https://例え.テスト", expected: "https://xn--r8jz45g.xn--zckzah"},
+		{name: "PublicIP", url: "http://93.184.215.14", expected: "http://93.184.215.14"},
+		{name: "MaxLengthLabel", url: "https://" + strings.Repeat("a", maxLabelLength) + ".com", expected: "https://" + strings.Repeat("a", maxLabelLength) + ".com"},
+
+		{name: "LabelTooLong", url: "https://" + strings.Repeat("a", maxLabelLength+1) + ".com", wantErr: "label too long"},
+		{name: "HostnameTooLong", url: "https://" + strings.Repeat(strings.Repeat("a", 60)+".", 5) + "com", wantErr: "hostname too long"},
+		{name: "LeadingHyphen", url: "https://-example.com", wantErr: "invalid hostname"},
+		{name: "TrailingHyphen", url: "https://example-.com", wantErr: "invalid hostname"},
+		{name: "EmptyLabel", url: "https://a..example.com", wantErr: "empty label"},
+		{name: "DoubleTrailingDot", url: "https://example.com..", wantErr: "empty label"},
+		{name: "NumericTLD", url: "https://example.123", wantErr: "all-numeric"},
+		{name: "InvalidIPv4", url: "https://999.1.1.1", wantErr: "all-numeric"},
+		{name: "Underscore", url: "https://my_host.example.com", wantErr: "invalid hostname"},
+		{name: "InvalidPunycode", url: "https://xn--zz.com", wantErr: "invalid hostname"},
+		{name: "LocalhostTrailingDot", url: "https://LOCALHOST.", wantErr: "localhost"},
+		{name: "PrivateIPv6", url: "https://[fd00::1]", wantErr: "private IP"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := validateURL(tc.url)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, u)
+		})
+	}
+}
+
+func TestCheckOwnHost(t *testing.T) {
+	self := sharedconfig.SelfProtectionConfig{OwnHosts: []string{"analyzer.example.com", "API.example.com"}}
+
+	testCases := []struct {
+		url string
+		own bool
+	}{
+		{url: "https://analyzer.example.com/jobs/42", own: true},
+		{url: "https://api.example.com:8443/analyze", own: true},
+		{url: "http://api.example.com", own: true},
+		{url: "https://example.com", own: false},
+		{url: "https://docs.analyzer.example.com", own: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			validated, err := validateURL(tc.url)
+			require.NoError(t, err)
+			err = checkOwnHost(validated, self)
+			if tc.own {
+				assert.ErrorIs(t, err, errOwnHost)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	assert.NoError(t, checkOwnHost("https://analyzer.example.com", sharedconfig.SelfProtectionConfig{}), "no host is refused by default")
+}
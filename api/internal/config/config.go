@@ -2,26 +2,196 @@ package config
 
 import (
 	"shared/config"
+	"strings"
+	"time"
 )
 
 // Config holds all configuration for the API service
 type Config struct {
-	Service  config.ServiceConfig
-	HTTP     config.HTTPServerConfig
-	Metrics  config.MetricsConfig
-	Tracing  config.TracingConfig
-	DynamoDB config.DynamoDBConfig
-	NATS     config.NATSConfig
+	Service   config.ServiceConfig
+	Admin     config.AdminConfig
+	Audit     config.AuditConfig
+	HTTP      config.HTTPServerConfig
+	Timeouts  TimeoutConfig
+	Trash     TrashConfig
+	Load      LoadConfig
+	Submit    SubmitConfig
+	URLRate   URLRateConfig
+	Self      config.SelfProtectionConfig
+	Events    EventsConfig
+	Reconcile ReconcileConfig
+	JobCache  JobCacheConfig
+	Shutdown  ShutdownConfig
+	Metrics   config.MetricsConfig
+	Tracing   config.TracingConfig
+	DynamoDB  config.DynamoDBConfig
+	NATS      config.NATSConfig
+}
+
+// TimeoutConfig holds per-route request handling limits for the API service
+type TimeoutConfig struct {
+	// Routes maps "METHOD /route/template" to the maximum handler duration for that route.
+	// Routes without an entry are only bounded by the server write timeout.
+	Routes map[string]time.Duration
+	// SlowRequestThreshold is the handler duration above which a request is logged as slow
+	SlowRequestThreshold time.Duration
+}
+
+// TrashConfig holds settings for deleted jobs, which stay restorable for a while before they are purged
+type TrashConfig struct {
+	// RestoreWindow is how long a deleted job can be restored, after which the sweeper purges it
+	RestoreWindow time.Duration
+	// PurgeInterval is how often the sweeper looks for jobs past their restore window
+	PurgeInterval time.Duration
+}
+
+// LoadConfig holds settings for the analyzer load reports behind the start delay hints
+type LoadConfig struct {
+	// StaleAfter is how long a load report is used, after which the load is reported unavailable
+	StaleAfter time.Duration
+	// MaxQueueDepth is the analyzer queue depth at which new jobs are refused with a 503, 0 accepts every job
+	MaxQueueDepth int
+}
+
+// SubmitConfig holds settings for creating and queueing the jobs of a request analyzing several URLs
+type SubmitConfig struct {
+	// Concurrency is how many of the jobs are submitted at once
+	Concurrency int
+}
+
+// URLRateConfig caps the jobs created for the same URL, damping feedback loops of jobs submitting one another
+type URLRateConfig struct {
+	// Limit is how many jobs a URL gets per window, zero lifts the cap. Each replica counts its own jobs.
+	Limit int
+	// Window is the period the jobs of a URL are counted over
+	Window time.Duration
+}
+
+// EventsConfig holds settings for the NDJSON streams of job events
+type EventsConfig struct {
+	// MaxStreams is how many streams are served at once, each holds its own message bus subscriptions
+	MaxStreams int
+}
+
+// ReconcileConfig holds settings for the reconciler failing the running jobs whose analysis died
+type ReconcileConfig struct {
+	// Interval is how often the reconciler looks for stuck jobs, zero disables it
+	Interval time.Duration
+	// HeartbeatStaleAfter is how long a running job may go without a heartbeat or update before it is failed.
+	// It should span a few of the analyzer's JOB_HEARTBEAT_INTERVAL.
+	HeartbeatStaleAfter time.Duration
+}
+
+// JobCacheConfig holds settings for the in-memory cache of finished jobs served by GET /jobs/:job_id
+type JobCacheConfig struct {
+	// Size is the number of finished jobs kept, zero disables the cache
+	Size int
+	// TTL is how long a job is served from the cache. Jobs deleted or restored through another replica
+	// are served as they were for up to this long.
+	TTL time.Duration
+}
+
+// ShutdownConfig holds settings for stopping the API without dropping requests, such as during a rolling update
+type ShutdownConfig struct {
+	// PreStopDelay is how long the API keeps serving with readiness failing, so load balancers stop routing to it
+	PreStopDelay time.Duration
+	// Timeout is how long the requests in flight get to finish once the server stops accepting new ones
+	Timeout time.Duration
 }
 
 // Load loads the configuration for the API service
 func Load() *Config {
 	return &Config{
-		Service:  config.NewServiceConfig("api"),
-		HTTP:     config.NewHTTPServerConfig(":8080"),
+		Service: config.NewServiceConfig("api"),
+		Admin:   config.NewAdminConfig(),
+		Audit:   config.NewAuditConfig(),
+		HTTP:    config.NewHTTPServerConfig(":8080"),
+		Timeouts: TimeoutConfig{
+			Routes:               config.GetDurationMapEnv("HTTP_ROUTE_TIMEOUTS", map[string]time.Duration{}),
+			SlowRequestThreshold: config.GetDurationEnv("HTTP_SLOW_REQUEST_THRESHOLD", 2*time.Second),
+		},
+		Trash: TrashConfig{
+			RestoreWindow: config.GetDurationEnv("JOB_RESTORE_WINDOW", 7*24*time.Hour),
+			PurgeInterval: config.GetDurationEnv("JOB_PURGE_INTERVAL", time.Hour),
+		},
+		Load: LoadConfig{
+			StaleAfter:    config.GetDurationEnv("ANALYZER_LOAD_STALE_AFTER", time.Minute),
+			MaxQueueDepth: config.GetIntEnv("ANALYZE_MAX_QUEUE_DEPTH", 0),
+		},
+		Submit: SubmitConfig{
+			Concurrency: config.GetIntEnv("ANALYZE_SUBMIT_CONCURRENCY", 4),
+		},
+		URLRate: URLRateConfig{
+			Limit:  config.GetIntEnv("ANALYZE_URL_RATE_LIMIT", 10),
+			Window: config.GetDurationEnv("ANALYZE_URL_RATE_WINDOW", time.Hour),
+		},
+		Self: config.NewSelfProtectionConfig(),
+		Events: EventsConfig{
+			MaxStreams: config.GetIntEnv("JOB_EVENTS_MAX_STREAMS", 100),
+		},
+		Reconcile: ReconcileConfig{
+			Interval:            config.GetDurationEnv("JOB_RECONCILE_INTERVAL", time.Minute),
+			HeartbeatStaleAfter: config.GetDurationEnv("JOB_HEARTBEAT_STALE_AFTER", 5*time.Minute),
+		},
+		JobCache: JobCacheConfig{
+			Size: config.GetIntEnv("JOB_CACHE_SIZE", 1000),
+			TTL:  config.GetDurationEnv("JOB_CACHE_TTL", time.Minute),
+		},
+		Shutdown: ShutdownConfig{
+			PreStopDelay: config.GetDurationEnv("SHUTDOWN_PRESTOP_DELAY", 5*time.Second),
+			Timeout:      config.GetDurationEnv("SHUTDOWN_TIMEOUT", 10*time.Second),
+		},
 		Metrics:  config.NewMetricsConfig("9090"),
 		Tracing:  config.NewTracingConfig("api"),
 		DynamoDB: config.NewDynamoDBConfig(),
 		NATS:     config.NewNATSConfig(),
 	}
 }
+
+// Redacted returns a copy of the configuration with secrets masked, safe to expose to operators
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.Admin = c.Admin.Redacted()
+	redacted.DynamoDB = c.DynamoDB.Redacted()
+	redacted.NATS = c.NATS.Redacted()
+	return &redacted
+}
+
+// Validate checks the configuration, returning a *config.ValidationError listing every problem found
+func (c *Config) Validate() error {
+	v := config.NewValidator()
+	c.Service.Check(v)
+	c.HTTP.Check(v)
+	c.Metrics.Check(v)
+	c.Tracing.Check(v)
+	c.DynamoDB.Check(v)
+	c.NATS.Check(v)
+	c.Self.Check(v)
+
+	for route, timeout := range c.Timeouts.Routes {
+		method, path, ok := strings.Cut(route, " ")
+		v.Check(ok && method != "" && strings.HasPrefix(path, "/"),
+			"HTTP_ROUTE_TIMEOUTS keys must be a method and a route such as GET /jobs, got %q", route)
+		v.Duration("HTTP_ROUTE_TIMEOUTS "+route, timeout)
+	}
+	v.Duration("HTTP_SLOW_REQUEST_THRESHOLD", c.Timeouts.SlowRequestThreshold)
+
+	v.Positive("JOB_RESTORE_WINDOW", c.Trash.RestoreWindow)
+	v.Duration("JOB_PURGE_INTERVAL", c.Trash.PurgeInterval)
+	v.Duration("ANALYZER_LOAD_STALE_AFTER", c.Load.StaleAfter)
+	v.Check(c.Load.MaxQueueDepth >= 0, "ANALYZE_MAX_QUEUE_DEPTH must be zero or positive, got %d", c.Load.MaxQueueDepth)
+	v.Check(c.Events.MaxStreams >= 0, "JOB_EVENTS_MAX_STREAMS must be zero or positive, got %d", c.Events.MaxStreams)
+	v.Check(c.Submit.Concurrency > 0, "ANALYZE_SUBMIT_CONCURRENCY must be positive, got %d", c.Submit.Concurrency)
+	v.Check(c.URLRate.Limit >= 0, "ANALYZE_URL_RATE_LIMIT must be zero or positive, got %d", c.URLRate.Limit)
+	if c.URLRate.Limit > 0 {
+		v.Duration("ANALYZE_URL_RATE_WINDOW", c.URLRate.Window)
+	}
+	v.OptionalDuration("JOB_RECONCILE_INTERVAL", c.Reconcile.Interval)
+	v.Duration("JOB_HEARTBEAT_STALE_AFTER", c.Reconcile.HeartbeatStaleAfter)
+	v.Check(c.JobCache.Size >= 0, "JOB_CACHE_SIZE must be zero or positive, got %d", c.JobCache.Size)
+	v.Duration("JOB_CACHE_TTL", c.JobCache.TTL)
+	v.OptionalDuration("SHUTDOWN_PRESTOP_DELAY", c.Shutdown.PreStopDelay)
+	v.Duration("SHUTDOWN_TIMEOUT", c.Shutdown.Timeout)
+
+	return v.Err()
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"errors"
+	sharedconfig "shared/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		name             string
+		env              map[string]string
+		modify           func(cfg *Config)
+		expectedProblems []string
+	}{
+		{
+			name: "Defaults",
+		},
+		{
+			name:             "MalformedEnv",
+			env:              map[string]string{"JOB_PURGE_INTERVAL": "1d"},
+			expectedProblems: []string{`JOB_PURGE_INTERVAL must be a duration such as 30s, got "1d"`},
+		},
+		{
+			name:             "HTTPAddr",
+			env:              map[string]string{"HTTP_ADDR": "8080"},
+			expectedProblems: []string{`HTTP_ADDR must be a host and port such as :8080, got "8080"`},
+		},
+		{
+			name:             "ZipkinEndpoint",
+			env:              map[string]string{"ZIPKIN_ENDPOINT": "zipkin:9411"},
+			expectedProblems: []string{`ZIPKIN_ENDPOINT must be a http or https URL, got "zipkin:9411"`},
+		},
+		{
+			name:             "DynamoDBEndpoint",
+			env:              map[string]string{"DYNAMODB_ENDPOINT": "dynamodb"},
+			expectedProblems: []string{`DYNAMODB_ENDPOINT must be a http or https URL, got "dynamodb"`},
+		},
+		{
+			name: "RouteTimeouts",
+			env:  map[string]string{"HTTP_ROUTE_TIMEOUTS": "GET /jobs=5s,/analyze=10s,POST /analyze=0s"},
+			expectedProblems: []string{
+				`HTTP_ROUTE_TIMEOUTS keys must be a method and a route such as GET /jobs, got "/analyze"`,
+				"HTTP_ROUTE_TIMEOUTS POST /analyze must be positive and at most 24h0m0s, got 0s",
+			},
+		},
+		{
+			name:             "MalformedRouteTimeouts",
+			env:              map[string]string{"HTTP_ROUTE_TIMEOUTS": "GET /jobs"},
+			expectedProblems: []string{`HTTP_ROUTE_TIMEOUTS must list key=duration pairs, got "GET /jobs"`},
+		},
+		{
+			name:             "MaxQueueDepth",
+			env:              map[string]string{"ANALYZE_MAX_QUEUE_DEPTH": "-1"},
+			expectedProblems: []string{"ANALYZE_MAX_QUEUE_DEPTH must be zero or positive, got -1"},
+		},
+		{
+			name:             "SubmitConcurrency",
+			env:              map[string]string{"ANALYZE_SUBMIT_CONCURRENCY": "0"},
+			expectedProblems: []string{"ANALYZE_SUBMIT_CONCURRENCY must be positive, got 0"},
+		},
+		{
+			name:             "URLRate",
+			env:              map[string]string{"ANALYZE_URL_RATE_LIMIT": "-1", "ANALYZE_URL_RATE_WINDOW": "0s"},
+			expectedProblems: []string{"ANALYZE_URL_RATE_LIMIT must be zero or positive, got -1"},
+		},
+		{
+			name:             "URLRateWindow",
+			env:              map[string]string{"ANALYZE_URL_RATE_WINDOW": "0s"},
+			expectedProblems: []string{"ANALYZE_URL_RATE_WINDOW must be positive and at most 24h0m0s, got 0s"},
+		},
+		{
+			name:             "PublicURLs",
+			env:              map[string]string{"PUBLIC_URLS": "analyzer.example.com"},
+			expectedProblems: []string{`PUBLIC_URLS must be a http or https URL, got "analyzer.example.com"`},
+		},
+		{
+			name:             "EventsMaxStreams",
+			env:              map[string]string{"JOB_EVENTS_MAX_STREAMS": "-1"},
+			expectedProblems: []string{"JOB_EVENTS_MAX_STREAMS must be zero or positive, got -1"},
+		},
+		{
+			name:             "RestoreWindow",
+			modify:           func(cfg *Config) { cfg.Trash.RestoreWindow = 0 },
+			expectedProblems: []string{"JOB_RESTORE_WINDOW must be positive, got 0s"},
+		},
+		{
+			name:             "PurgeInterval",
+			modify:           func(cfg *Config) { cfg.Trash.PurgeInterval = 0 },
+			expectedProblems: []string{"JOB_PURGE_INTERVAL must be positive and at most 24h0m0s, got 0s"},
+		},
+		{
+			name:             "LoadStaleAfter",
+			modify:           func(cfg *Config) { cfg.Load.StaleAfter = -time.Second },
+			expectedProblems: []string{"ANALYZER_LOAD_STALE_AFTER must be positive and at most 24h0m0s, got -1s"},
+		},
+		{
+			name:             "Reconcile",
+			env:              map[string]string{"JOB_RECONCILE_INTERVAL": "0s", "JOB_HEARTBEAT_STALE_AFTER": "0s"},
+			expectedProblems: []string{"JOB_HEARTBEAT_STALE_AFTER must be positive and at most 24h0m0s, got 0s"},
+		},
+		{
+			name:             "JobCache",
+			env:              map[string]string{"JOB_CACHE_SIZE": "-1", "JOB_CACHE_TTL": "0s"},
+			expectedProblems: []string{"JOB_CACHE_SIZE must be zero or positive, got -1", "JOB_CACHE_TTL must be positive and at most 24h0m0s, got 0s"},
+		},
+		{
+			name:             "Shutdown",
+			env:              map[string]string{"SHUTDOWN_PRESTOP_DELAY": "0s", "SHUTDOWN_TIMEOUT": "0s"},
+			expectedProblems: []string{"SHUTDOWN_TIMEOUT must be positive and at most 24h0m0s, got 0s"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+			cfg := Load()
+			if tc.modify != nil {
+				tc.modify(cfg)
+			}
+
+			err := cfg.Validate()
+			if len(tc.expectedProblems) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var invalid *sharedconfig.ValidationError
+			require.True(t, errors.As(err, &invalid))
+			assert.ElementsMatch(t, tc.expectedProblems, invalid.Problems)
+		})
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "admin-secret")
+	t.Setenv("DYNAMODB_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("NATS_URL", "nats://api-token@nats:4222")
+	cfg := Load()
+
+	redacted := cfg.Redacted()
+	assert.Equal(t, "[REDACTED]", redacted.Admin.Token)
+	assert.Equal(t, "[REDACTED]", redacted.DynamoDB.AccessKeyID)
+	assert.Equal(t, "nats://xxxxx@nats:4222", redacted.NATS.URL)
+	assert.Equal(t, "nats://api-token@nats:4222", cfg.NATS.URL, "the configuration itself keeps its secrets")
+}
@@ -0,0 +1,146 @@
+package webctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shared/repository"
+	"time"
+)
+
+// defaultRestoreSample is the number of items compared when verifying a restored table
+const defaultRestoreSample = 100
+
+// backupRepository returns the backup repository, or an error when none was configured
+func (c *CLI) backupRepository() (repository.BackupRepositoryInterface, error) {
+	if c.backups == nil {
+		return nil, errors.New("no backup repository configured")
+	}
+	return c.backups, nil
+}
+
+// createBackups backs up the tables before a risky deploy, all of them unless one is named
+func (c *CLI) createBackups(ctx context.Context, args []string) error {
+	f := newFlags("db backup")
+	label := f.String("label", "", "label included in the backup names, such as pre-deploy")
+	table := f.String("table", "", "table backed up, all tables by default")
+	if _, err := f.parse(args); err != nil {
+		return err
+	}
+	if *label == "" {
+		return fmt.Errorf("%w: db backup requires --label", ErrUsage)
+	}
+	backups, err := c.backupRepository()
+	if err != nil {
+		return err
+	}
+
+	tables := repository.TableNames()
+	if *table != "" {
+		tables = []string{*table}
+	}
+
+	created := make([]repository.Backup, 0, len(tables))
+	for _, name := range tables {
+		backup, err := backups.CreateBackup(ctx, name, *label)
+		if errors.Is(err, repository.ErrUnknownTable) || errors.Is(err, repository.ErrInvalidBackupLabel) {
+			return fmt.Errorf("%w: %v", ErrUsage, err)
+		}
+		if err != nil {
+			// The backups already started are kept, they are listed for the retry to skip
+			if len(created) > 0 {
+				c.warnf("backed up %d of %d tables before failing", len(created), len(tables))
+				_ = c.printBackups(created, *f.json)
+			}
+			return fmt.Errorf("failed to back up %s: %w", name, err)
+		}
+		created = append(created, *backup)
+	}
+
+	return c.printBackups(created, *f.json)
+}
+
+// listBackups lists the on-demand backups of the tables, newest first
+func (c *CLI) listBackups(ctx context.Context, args []string) error {
+	f := newFlags("db backups")
+	table := f.String("table", "", "table whose backups are listed, all tables by default")
+	if _, err := f.parse(args); err != nil {
+		return err
+	}
+	backups, err := c.backupRepository()
+	if err != nil {
+		return err
+	}
+
+	list, err := backups.ListBackups(ctx, *table)
+	if errors.Is(err, repository.ErrUnknownTable) {
+		return fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+	return c.printBackups(list, *f.json)
+}
+
+// verifyRestore compares a table restored from a backup with the table it was backed up from,
+// failing when they differ
+func (c *CLI) verifyRestore(ctx context.Context, args []string) error {
+	f := newFlags("db verify-restore")
+	sample := f.Int("sample", defaultRestoreSample, "number of items compared, zero to only compare the counts")
+	positional, err := f.parse(args, "source_table", "restored_table")
+	if err != nil {
+		return err
+	}
+	if *sample < 0 {
+		return fmt.Errorf("%w: --sample cannot be negative", ErrUsage)
+	}
+	backups, err := c.backupRepository()
+	if err != nil {
+		return err
+	}
+
+	report, err := backups.VerifyRestore(ctx, positional[0], positional[1], *sample)
+	if err != nil {
+		return fmt.Errorf("failed to verify the restore: %w", err)
+	}
+
+	if *f.json {
+		if err := c.writeJSON(report); err != nil {
+			return err
+		}
+	} else {
+		tw := c.newTable()
+		fmt.Fprintf(tw, "SOURCE\t%s\t%d items\n", report.SourceTable, report.SourceItems)
+		fmt.Fprintf(tw, "RESTORED\t%s\t%d items\n", report.RestoredTable, report.RestoredItems)
+		fmt.Fprintf(tw, "SAMPLED\t%d\n", report.Sampled)
+		for _, key := range report.Missing {
+			fmt.Fprintf(tw, "MISSING\t%s\n", key)
+		}
+		for _, key := range report.Mismatched {
+			fmt.Fprintf(tw, "MISMATCHED\t%s\n", key)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if !report.Matches() {
+		return fmt.Errorf("%s does not match %s", report.RestoredTable, report.SourceTable)
+	}
+	return nil
+}
+
+// printBackups prints backups as a table or as JSON
+func (c *CLI) printBackups(backups []repository.Backup, asJSON bool) error {
+	if asJSON {
+		return c.writeJSON(backups)
+	}
+
+	tw := c.newTable()
+	fmt.Fprintln(tw, "TABLE\tNAME\tSTATUS\tCREATED\tSIZE")
+	for _, backup := range backups {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\n",
+			backup.Table, backup.Name, backup.Status, backup.CreatedAt.Format(time.RFC3339), backup.SizeBytes)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,316 @@
+package webctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shared/audit"
+	"shared/messagebus"
+	"shared/models"
+	"shared/repository"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// listPageSize is the number of jobs read per page while listing jobs
+const listPageSize = 100
+
+// jobSummary is a job as listed, without its result
+type jobSummary struct {
+	ID        string           `json:"id"`
+	URL       string           `json:"url"`
+	Status    models.JobStatus `json:"status"`
+	Progress  float64          `json:"progress"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// jobDetails is a job as shown, with its tasks and the statuses it went through
+type jobDetails struct {
+	Job      *models.Job           `json:"job"`
+	Tasks    []models.Task         `json:"tasks"`
+	Timeline []models.StatusChange `json:"timeline"`
+}
+
+// changeResult reports a change made to a job or one of its tasks
+type changeResult struct {
+	JobID    string          `json:"job_id"`
+	TaskType models.TaskType `json:"task_type,omitempty"`
+	Status   string          `json:"status"`
+	// Published reports whether the change was announced on the message bus
+	Published bool `json:"published"`
+}
+
+// listJobs lists the jobs in the given statuses, newest first, optionally only those created a while ago
+func (c *CLI) listJobs(ctx context.Context, args []string) error {
+	f := newFlags("jobs list")
+	statusList := f.String("status", "", "comma-separated statuses to list, all by default")
+	olderThan := f.Duration("older-than", 0, "only list jobs created at least this long ago")
+	limit := f.Int("limit", 50, "maximum number of jobs listed")
+	if _, err := f.parse(args); err != nil {
+		return err
+	}
+
+	statuses, err := parseStatuses(*statusList)
+	if err != nil {
+		return err
+	}
+	if *olderThan < 0 || *limit <= 0 {
+		return fmt.Errorf("%w: --older-than cannot be negative and --limit must be positive", ErrUsage)
+	}
+
+	cutoff := c.now().Add(-*olderThan)
+	jobs := make([]jobSummary, 0)
+	cursor := ""
+	for len(jobs) < *limit {
+		page, next, err := c.jobs.GetJobsByStatus(ctx, statuses, cursor, listPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to list jobs: %w", err)
+		}
+
+		for _, job := range page {
+			if job.CreatedAt.After(cutoff) || len(jobs) == *limit {
+				continue
+			}
+			jobs = append(jobs, jobSummary{
+				ID:        job.ID,
+				URL:       job.URL,
+				Status:    job.Status,
+				Progress:  job.Progress,
+				CreatedAt: job.CreatedAt,
+				UpdatedAt: job.UpdatedAt,
+			})
+		}
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	if *f.json {
+		return c.writeJSON(jobs)
+	}
+
+	tw := c.newTable()
+	fmt.Fprintln(tw, "ID\tSTATUS\tAGE\tPROGRESS\tURL")
+	for _, job := range jobs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.0f%%\t%s\n",
+			job.ID, job.Status, c.now().Sub(job.CreatedAt).Round(time.Second), job.Progress, job.URL)
+	}
+	return tw.Flush()
+}
+
+// parseStatuses validates a comma-separated list of statuses, every status when the list is empty
+func parseStatuses(list string) ([]models.JobStatus, error) {
+	if strings.TrimSpace(list) == "" {
+		return models.JobStatuses(), nil
+	}
+
+	var statuses []models.JobStatus
+	for _, name := range strings.Split(list, ",") {
+		status, err := models.ParseJobStatus(strings.TrimSpace(name))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUsage, err)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// showJob prints a job, its tasks and its timeline
+func (c *CLI) showJob(ctx context.Context, args []string) error {
+	f := newFlags("jobs show")
+	positional, err := f.parse(args, "job_id")
+	if err != nil {
+		return err
+	}
+
+	job, err := c.getJob(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	tasks, err := c.tasks.GetTasksByJobId(ctx, job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get tasks: %w", err)
+	}
+
+	if *f.json {
+		return c.writeJSON(jobDetails{Job: job, Tasks: tasks, Timeline: job.StatusHistory})
+	}
+
+	tw := c.newTable()
+	fmt.Fprintf(tw, "ID\t%s\n", job.ID)
+	fmt.Fprintf(tw, "URL\t%s\n", job.URL)
+	fmt.Fprintf(tw, "STATUS\t%s\n", describeStatus(job))
+	fmt.Fprintf(tw, "PROGRESS\t%.0f%%\n", job.Progress)
+	fmt.Fprintf(tw, "CREATED\t%s\n", job.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(tw, "UPDATED\t%s\n", job.UpdatedAt.Format(time.RFC3339))
+	fmt.Fprintf(tw, "VERSION\t%d\n", job.Version)
+	if job.IsDeleted() {
+		fmt.Fprintf(tw, "DELETED\t%s\n", job.DeletedAt.Format(time.RFC3339))
+	}
+
+	fmt.Fprintln(tw, "\nTASK\tSTATUS\tSUBTASKS DONE")
+	for _, task := range tasks {
+		done := 0
+		for _, subtask := range task.SubTasks {
+			if subtask.Status.IsTerminal() {
+				done++
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d/%d\n", task.Type, task.Status, done, len(task.SubTasks))
+	}
+
+	fmt.Fprintln(tw, "\nSTATUS\tAT")
+	for _, change := range job.StatusHistory {
+		fmt.Fprintf(tw, "%s\t%s\n", change.Status, change.At.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+// describeStatus returns the status of a job, with why it failed
+func describeStatus(job *models.Job) string {
+	if job.Status != models.JobStatusFailed || job.FailureCode == "" {
+		return string(job.Status)
+	}
+	return fmt.Sprintf("%s (%s: %s)", job.Status, job.FailureCode, job.FailureReason)
+}
+
+// failJob fails a job that is stuck, such as one whose analyzer died with it
+func (c *CLI) failJob(ctx context.Context, args []string) error {
+	f := newFlags("jobs fail")
+	reason := f.String("reason", "", "why the job is failed, shown to users")
+	yes := f.Bool("yes", false, "confirm the change")
+	positional, err := f.parse(args, "job_id")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(*reason) == "" {
+		return fmt.Errorf("%w: jobs fail requires --reason", ErrUsage)
+	}
+	if err := confirm("jobs fail", *yes); err != nil {
+		return err
+	}
+
+	job, err := c.activeJob(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	// The job is only failed as it was read, a job the analyzer finished meanwhile keeps its result
+	status := models.JobStatusFailed
+	err = c.jobs.UpdateJobStatus(ctx, job.ID, status,
+		repository.IfVersion(job.Version), repository.WithFailure(models.FailureCodeInternal, *reason))
+	if errors.Is(err, repository.ErrVersionConflict) {
+		return fmt.Errorf("job %s was updated meanwhile, check it again", job.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fail job: %w", err)
+	}
+	c.audit.Record(ctx, audit.Record{
+		Event:  audit.EventJobFailed,
+		JobID:  job.ID,
+		URL:    job.URL,
+		Status: string(status),
+	})
+
+	progress, _ := models.TerminalProgress(status)
+	published := c.publish(job.ID, func(bus messagebus.MessageBusInterface) error {
+		return bus.PublishJobUpdate(ctx, messagebus.JobUpdateMessage{
+			Type:          messagebus.JobUpdateMessageType,
+			JobID:         job.ID,
+			Status:        string(status),
+			URL:           job.URL,
+			Progress:      &progress,
+			FailureCode:   models.FailureCodeInternal,
+			FailureReason: *reason,
+		})
+	})
+
+	return c.printChange(changeResult{JobID: job.ID, Status: string(status), Published: published}, *f.json)
+}
+
+// republishJob queues a pending or running job for analysis again, for when its analyze message was lost
+func (c *CLI) republishJob(ctx context.Context, args []string) error {
+	f := newFlags("jobs republish")
+	positional, err := f.parse(args, "job_id")
+	if err != nil {
+		return err
+	}
+
+	job, err := c.activeJob(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	bus, err := c.messageBus()
+	if err != nil {
+		return err
+	}
+
+	// A new attempt takes the job over, an analysis still running the lost one can no longer finish it
+	if err := bus.PublishAnalyzeMessage(ctx, messagebus.AnalyzeMessage{
+		Type:      messagebus.AnalyzeMessageType,
+		JobId:     job.ID,
+		Source:    audit.SourceFromContext(ctx),
+		AttemptID: ulid.Make().String(),
+	}); err != nil {
+		return fmt.Errorf("failed to publish analyze message: %w", err)
+	}
+
+	return c.printChange(changeResult{JobID: job.ID, Status: string(job.Status), Published: true}, *f.json)
+}
+
+// getJob reads a job
+func (c *CLI) getJob(ctx context.Context, id string) (*models.Job, error) {
+	job, err := c.jobs.GetJob(ctx, id)
+	if errors.Is(err, repository.ErrJobNotFound) {
+		return nil, fmt.Errorf("job %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	return job, nil
+}
+
+// activeJob reads a job that has not finished yet
+func (c *CLI) activeJob(ctx context.Context, id string) (*models.Job, error) {
+	job, err := c.getJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status.IsTerminal() {
+		return nil, fmt.Errorf("job %s has already finished as %s", job.ID, job.Status)
+	}
+	return job, nil
+}
+
+// publish announces a change made to a job, so the services and the connected clients learn about it.
+// The change is made already, so a failure is only warned about and reported false.
+func (c *CLI) publish(jobID string, send func(bus messagebus.MessageBusInterface) error) bool {
+	bus, err := c.messageBus()
+	if err == nil {
+		err = send(bus)
+	}
+	if err != nil {
+		c.warnf("the change to job %s was not published: %v", jobID, err)
+		return false
+	}
+	return true
+}
+
+// printChange prints the change made by a command
+func (c *CLI) printChange(change changeResult, asJSON bool) error {
+	if asJSON {
+		return c.writeJSON(change)
+	}
+
+	target := "Job " + change.JobID
+	if change.TaskType != "" {
+		target = fmt.Sprintf("Task %s of job %s", change.TaskType, change.JobID)
+	}
+	_, err := fmt.Fprintf(c.out, "%s: %s\n", target, change.Status)
+	return err
+}
@@ -0,0 +1,55 @@
+package webctl
+
+import (
+	"context"
+	"fmt"
+	"shared/messagebus"
+	"shared/models"
+	"slices"
+)
+
+// resetTask moves a task of a job back to pending, so it runs again when the job is next analyzed
+func (c *CLI) resetTask(ctx context.Context, args []string) error {
+	f := newFlags("tasks reset")
+	yes := f.Bool("yes", false, "confirm the change")
+	positional, err := f.parse(args, "job_id", "task_type")
+	if err != nil {
+		return err
+	}
+
+	taskType := models.TaskType(positional[1])
+	if !models.IsValidTaskType(taskType) {
+		return fmt.Errorf("%w: unknown task type %q, expected one of %v", ErrUsage, taskType, models.TaskTypes())
+	}
+	if err := confirm("tasks reset", *yes); err != nil {
+		return err
+	}
+
+	job, err := c.getJob(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	tasks, err := c.tasks.GetTasksByJobId(ctx, job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get tasks: %w", err)
+	}
+	if !slices.ContainsFunc(tasks, func(task models.Task) bool { return task.Type == taskType }) {
+		return fmt.Errorf("job %s has no %s task", job.ID, taskType)
+	}
+
+	status := models.TaskStatusPending
+	if err := c.tasks.UpdateTaskStatus(ctx, job.ID, taskType, status); err != nil {
+		return fmt.Errorf("failed to reset task: %w", err)
+	}
+
+	published := c.publish(job.ID, func(bus messagebus.MessageBusInterface) error {
+		return bus.PublishTaskStatusUpdate(ctx, messagebus.TaskStatusUpdateMessage{
+			Type:     messagebus.TaskStatusUpdateMessageType,
+			JobID:    job.ID,
+			TaskType: string(taskType),
+			Status:   string(status),
+		})
+	})
+
+	return c.printChange(changeResult{JobID: job.ID, TaskType: taskType, Status: string(status), Published: published}, *f.json)
+}
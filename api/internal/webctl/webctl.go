@@ -0,0 +1,223 @@
+// Package webctl implements webctl, the operations CLI that inspects and repairs jobs and backs up their tables.
+// It works on the job and task tables through the repositories rather than through the API, so it keeps working
+// while the API is down.
+package webctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"shared/audit"
+	"shared/messagebus"
+	"shared/repository"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ErrUsage is wrapped by the errors of commands invoked with wrong arguments
+var ErrUsage = errors.New("usage")
+
+// Usage describes the commands
+const Usage = `Usage: webctl <command> [flags]
+
+Commands:
+  jobs list [--status running,pending] [--older-than 1h] [--limit 50]
+  jobs show <job_id>
+  jobs fail <job_id> --reason <reason> --yes
+  jobs republish <job_id>
+  tasks reset <job_id> <task_type> --yes
+  db backup --label <label> [--table web-analyzer-jobs]
+  db backups [--table web-analyzer-jobs]
+  db verify-restore <source_table> <restored_table> [--sample 100]
+
+Every command accepts --json to print JSON instead of tables.
+`
+
+// BusConnector connects to the message bus, for the commands that publish
+type BusConnector func() (messagebus.MessageBusInterface, error)
+
+// CLI runs the webctl commands
+type CLI struct {
+	jobs    repository.JobRepositoryInterface
+	tasks   repository.TaskRepositoryInterface
+	backups repository.BackupRepositoryInterface
+	connect BusConnector
+	bus     messagebus.MessageBusInterface
+	audit   *audit.Logger
+	out     io.Writer
+	errOut  io.Writer
+	now     func() time.Time
+}
+
+// Option configures the CLI
+type Option func(*CLI)
+
+// WithBusConnector sets how the message bus is connected to, on first use.
+// Without it, republishing fails and other changes are not published.
+func WithBusConnector(connect BusConnector) Option {
+	return func(c *CLI) {
+		c.connect = connect
+	}
+}
+
+// WithBackups sets the repository the db commands back up and check the tables with.
+// Without it, the db commands fail.
+func WithBackups(backups repository.BackupRepositoryInterface) Option {
+	return func(c *CLI) {
+		c.backups = backups
+	}
+}
+
+// WithAuditLogger sets the audit logger recording the job transitions made
+func WithAuditLogger(l *audit.Logger) Option {
+	return func(c *CLI) {
+		c.audit = l
+	}
+}
+
+// WithOutput sets where results and warnings are written, stdout and stderr by default
+func WithOutput(out, errOut io.Writer) Option {
+	return func(c *CLI) {
+		c.out = out
+		c.errOut = errOut
+	}
+}
+
+// WithClock sets the clock the job ages are measured with, mainly for tests
+func WithClock(now func() time.Time) Option {
+	return func(c *CLI) {
+		c.now = now
+	}
+}
+
+// New creates a CLI working on the given repositories
+func New(jobs repository.JobRepositoryInterface, tasks repository.TaskRepositoryInterface, opts ...Option) *CLI {
+	c := &CLI{
+		jobs:   jobs,
+		tasks:  tasks,
+		out:    os.Stdout,
+		errOut: os.Stderr,
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Run runs the command named by the first arguments
+func (c *CLI) Run(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("%w: missing command", ErrUsage)
+	}
+
+	command, args := args[0]+" "+args[1], args[2:]
+	switch command {
+	case "jobs list":
+		return c.listJobs(ctx, args)
+	case "jobs show":
+		return c.showJob(ctx, args)
+	case "jobs fail":
+		return c.failJob(ctx, args)
+	case "jobs republish":
+		return c.republishJob(ctx, args)
+	case "tasks reset":
+		return c.resetTask(ctx, args)
+	case "db backup":
+		return c.createBackups(ctx, args)
+	case "db backups":
+		return c.listBackups(ctx, args)
+	case "db verify-restore":
+		return c.verifyRestore(ctx, args)
+	default:
+		return fmt.Errorf("%w: unknown command %q", ErrUsage, command)
+	}
+}
+
+// messageBus returns the message bus, connecting to it on first use
+func (c *CLI) messageBus() (messagebus.MessageBusInterface, error) {
+	if c.bus != nil {
+		return c.bus, nil
+	}
+	if c.connect == nil {
+		return nil, errors.New("no message bus configured")
+	}
+
+	bus, err := c.connect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the message bus: %w", err)
+	}
+	c.bus = bus
+	return bus, nil
+}
+
+// warnf writes a warning about a change that was only partly made
+func (c *CLI) warnf(format string, args ...any) {
+	fmt.Fprintf(c.errOut, "warning: "+format+"\n", args...)
+}
+
+// flags is the flag set of a command, with the --json flag every command accepts
+type flags struct {
+	*flag.FlagSet
+	json *bool
+}
+
+func newFlags(command string) flags {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return flags{FlagSet: fs, json: fs.Bool("json", false, "print JSON")}
+}
+
+// parse parses the flags wherever they are among the arguments and returns the other arguments,
+// which must number want
+func (f flags) parse(args []string, want ...string) ([]string, error) {
+	var positional []string
+	for {
+		if err := f.Parse(args); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrUsage, f.Name(), err)
+		}
+		if f.NArg() == 0 {
+			break
+		}
+		positional = append(positional, f.Arg(0))
+		args = f.Args()[1:]
+	}
+
+	if len(positional) != len(want) {
+		return nil, fmt.Errorf("%w: %s expects %s", ErrUsage, f.Name(), wantedArgs(want))
+	}
+	return positional, nil
+}
+
+// wantedArgs describes the positional arguments of a command
+func wantedArgs(want []string) string {
+	if len(want) == 0 {
+		return "no arguments"
+	}
+	return "<" + strings.Join(want, "> <") + ">"
+}
+
+// confirm refuses a command that changes state unless --yes was given
+func confirm(command string, yes bool) error {
+	if !yes {
+		return fmt.Errorf("%w: %s changes the job, confirm with --yes", ErrUsage, command)
+	}
+	return nil
+}
+
+// writeJSON prints v as indented JSON
+func (c *CLI) writeJSON(v any) error {
+	enc := json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// newTable returns a writer aligning tab-separated columns, to be flushed once the rows are written
+func (c *CLI) newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+}
@@ -0,0 +1,380 @@
+package webctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"shared/audit"
+	"shared/messagebus"
+	"shared/mocks"
+	"shared/models"
+	"shared/repository"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+var testNow = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// testCLI is a CLI over in-memory repositories holding a few jobs, with its outputs captured
+type testCLI struct {
+	*CLI
+	jobs   *repository.MemoryJobRepository
+	tasks  *repository.MemoryTaskRepository
+	bus    *mocks.MockMessageBusInterface
+	out    *bytes.Buffer
+	errOut *bytes.Buffer
+	audit  *bytes.Buffer
+}
+
+func newTestCLI(t *testing.T) *testCLI {
+	t.Helper()
+	ctx := context.Background()
+
+	tc := &testCLI{
+		jobs:   repository.NewMemoryJobRepository(),
+		tasks:  repository.NewMemoryTaskRepository(),
+		bus:    mocks.NewMockMessageBusInterface(gomock.NewController(t)),
+		out:    &bytes.Buffer{},
+		errOut: &bytes.Buffer{},
+		audit:  &bytes.Buffer{},
+	}
+	jobs := []*models.Job{
+		{ID: "job-1", URL: "https://example.com/old", Status: models.JobStatusRunning, CreatedAt: testNow.Add(-3 * time.Hour), Progress: 40},
+		{ID: "job-2", URL: "https://example.com/done", Status: models.JobStatusCompleted, CreatedAt: testNow.Add(-2 * time.Hour), Progress: 100},
+		{ID: "job-3", URL: "https://example.com/new", Status: models.JobStatusRunning, CreatedAt: testNow.Add(-10 * time.Minute)},
+		{ID: "job-4", URL: "https://example.com/queued", Status: models.JobStatusPending, CreatedAt: testNow.Add(-5 * time.Minute)},
+	}
+	for _, job := range jobs {
+		require.NoError(t, tc.jobs.CreateJob(ctx, job))
+		for _, taskType := range models.TaskTypes() {
+			require.NoError(t, tc.tasks.CreateTasks(ctx, &models.Task{JobID: job.ID, Type: taskType, Status: models.TaskStatusCompleted}))
+		}
+	}
+
+	tc.CLI = New(tc.jobs, tc.tasks,
+		WithBusConnector(func() (messagebus.MessageBusInterface, error) { return tc.bus, nil }),
+		WithAuditLogger(audit.NewLogger(tc.audit, "webctl")),
+		WithOutput(tc.out, tc.errOut),
+		WithClock(func() time.Time { return testNow }))
+	return tc
+}
+
+func TestCLI_JobsList(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{name: "All", args: nil, expected: []string{"job-4", "job-3", "job-2", "job-1"}},
+		{name: "Status", args: []string{"--status", "running"}, expected: []string{"job-3", "job-1"}},
+		{name: "Statuses", args: []string{"--status", "pending, completed"}, expected: []string{"job-4", "job-2"}},
+		{name: "OlderThan", args: []string{"--status", "running", "--older-than", "1h"}, expected: []string{"job-1"}},
+		{name: "Limit", args: []string{"--limit", "1"}, expected: []string{"job-4"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := newTestCLI(t)
+
+			require.NoError(t, cli.Run(context.Background(), append([]string{"jobs", "list", "--json"}, tc.args...)))
+
+			var jobs []jobSummary
+			require.NoError(t, json.Unmarshal(cli.out.Bytes(), &jobs))
+			ids := make([]string, 0, len(jobs))
+			for _, job := range jobs {
+				ids = append(ids, job.ID)
+			}
+			assert.Equal(t, tc.expected, ids)
+		})
+	}
+}
+
+func TestCLI_JobsList_Table(t *testing.T) {
+	cli := newTestCLI(t)
+
+	require.NoError(t, cli.Run(context.Background(), []string{"jobs", "list", "--older-than", "1h"}))
+
+	lines := strings.Split(strings.TrimSpace(cli.out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Regexp(t, `^ID\s+STATUS\s+AGE\s+PROGRESS\s+URL$`, lines[0])
+	assert.Regexp(t, `^job-2\s+completed\s+2h0m0s\s+100%\s+https://example.com/done$`, lines[1])
+	assert.Regexp(t, `^job-1\s+running\s+3h0m0s\s+40%\s+https://example.com/old$`, lines[2])
+}
+
+func TestCLI_JobsShow(t *testing.T) {
+	cli := newTestCLI(t)
+
+	require.NoError(t, cli.Run(context.Background(), []string{"jobs", "show", "job-1", "--json"}))
+
+	var details jobDetails
+	require.NoError(t, json.Unmarshal(cli.out.Bytes(), &details))
+	assert.Equal(t, "job-1", details.Job.ID)
+	assert.Len(t, details.Tasks, len(models.TaskTypes()))
+	require.Len(t, details.Timeline, 1)
+	assert.Equal(t, models.JobStatusRunning, details.Timeline[0].Status)
+
+	cli.out.Reset()
+	require.NoError(t, cli.Run(context.Background(), []string{"jobs", "show", "job-1"}))
+	assert.Contains(t, cli.out.String(), "https://example.com/old")
+	assert.Regexp(t, `verifying_links\s+completed\s+0/0`, cli.out.String())
+
+	err := cli.Run(context.Background(), []string{"jobs", "show", "missing"})
+	assert.EqualError(t, err, "job missing not found")
+}
+
+func TestCLI_JobsFail(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Fails", func(t *testing.T) {
+		cli := newTestCLI(t)
+		cli.bus.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, m messagebus.JobUpdateMessage) error {
+			assert.Equal(t, "job-1", m.JobID)
+			assert.Equal(t, string(models.JobStatusFailed), m.Status)
+			assert.Equal(t, "analyzer lost it", m.FailureReason)
+			return nil
+		})
+
+		// Flags may follow the job ID
+		require.NoError(t, cli.Run(ctx, []string{"jobs", "fail", "job-1", "--reason", "analyzer lost it", "--yes"}))
+
+		job, err := cli.jobs.GetJob(ctx, "job-1")
+		require.NoError(t, err)
+		assert.Equal(t, models.JobStatusFailed, job.Status)
+		assert.Equal(t, models.FailureCodeInternal, job.FailureCode)
+		assert.Equal(t, "analyzer lost it", job.FailureReason)
+		assert.Equal(t, "Job job-1: failed\n", cli.out.String())
+		assert.Contains(t, cli.audit.String(), `"event":"job.failed"`)
+	})
+
+	t.Run("PublishFails", func(t *testing.T) {
+		cli := newTestCLI(t)
+		cli.bus.EXPECT().PublishJobUpdate(gomock.Any(), gomock.Any()).Return(errors.New("nats down"))
+
+		require.NoError(t, cli.Run(ctx, []string{"jobs", "fail", "--json", "--yes", "--reason", "stuck", "job-1"}))
+
+		var change changeResult
+		require.NoError(t, json.Unmarshal(cli.out.Bytes(), &change))
+		assert.False(t, change.Published)
+		assert.Contains(t, cli.errOut.String(), "nats down")
+	})
+
+	t.Run("Finished", func(t *testing.T) {
+		cli := newTestCLI(t)
+
+		err := cli.Run(ctx, []string{"jobs", "fail", "job-2", "--reason", "stuck", "--yes"})
+		assert.EqualError(t, err, "job job-2 has already finished as completed")
+	})
+
+	usage := map[string][]string{
+		"NotConfirmed": {"jobs", "fail", "job-1", "--reason", "stuck"},
+		"NoReason":     {"jobs", "fail", "job-1", "--yes"},
+		"NoJob":        {"jobs", "fail", "--reason", "stuck", "--yes"},
+		"UnknownFlag":  {"jobs", "fail", "job-1", "--reason", "stuck", "--yes", "--force"},
+	}
+	for name, args := range usage {
+		t.Run(name, func(t *testing.T) {
+			cli := newTestCLI(t)
+
+			assert.ErrorIs(t, cli.Run(ctx, args), ErrUsage)
+
+			job, err := cli.jobs.GetJob(ctx, "job-1")
+			require.NoError(t, err)
+			assert.Equal(t, models.JobStatusRunning, job.Status)
+		})
+	}
+}
+
+func TestCLI_JobsRepublish(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Republishes", func(t *testing.T) {
+		cli := newTestCLI(t)
+		var published messagebus.AnalyzeMessage
+		cli.bus.EXPECT().PublishAnalyzeMessage(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, m messagebus.AnalyzeMessage) error {
+				published = m
+				return nil
+			})
+
+		require.NoError(t, cli.Run(audit.WithSource(ctx, audit.Source{RequestID: "webctl-1"}), []string{"jobs", "republish", "job-4"}))
+		assert.Equal(t, "Job job-4: pending\n", cli.out.String())
+		assert.Equal(t, messagebus.AnalyzeMessageType, published.Type)
+		assert.Equal(t, "job-4", published.JobId)
+		assert.Equal(t, audit.Source{RequestID: "webctl-1"}, published.Source)
+		assert.NotEmpty(t, published.AttemptID, "a republished job runs under a new attempt")
+	})
+
+	t.Run("Finished", func(t *testing.T) {
+		cli := newTestCLI(t)
+
+		assert.EqualError(t, cli.Run(ctx, []string{"jobs", "republish", "job-2"}), "job job-2 has already finished as completed")
+	})
+
+	t.Run("NoBus", func(t *testing.T) {
+		cli := newTestCLI(t)
+		cli.connect = func() (messagebus.MessageBusInterface, error) { return nil, errors.New("connection refused") }
+
+		err := cli.Run(ctx, []string{"jobs", "republish", "job-4"})
+		assert.ErrorContains(t, err, "connection refused")
+	})
+}
+
+func TestCLI_TasksReset(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Resets", func(t *testing.T) {
+		cli := newTestCLI(t)
+		cli.bus.EXPECT().PublishTaskStatusUpdate(gomock.Any(), messagebus.TaskStatusUpdateMessage{
+			Type:     messagebus.TaskStatusUpdateMessageType,
+			JobID:    "job-1",
+			TaskType: string(models.TaskTypeVerifyingLinks),
+			Status:   string(models.TaskStatusPending),
+		}).Return(nil)
+
+		require.NoError(t, cli.Run(ctx, []string{"tasks", "reset", "job-1", "verifying_links", "--yes"}))
+
+		tasks, err := cli.tasks.GetTasksByJobId(ctx, "job-1")
+		require.NoError(t, err)
+		for _, task := range tasks {
+			expected := models.TaskStatusCompleted
+			if task.Type == models.TaskTypeVerifyingLinks {
+				expected = models.TaskStatusPending
+			}
+			assert.Equal(t, expected, task.Status, task.Type)
+		}
+		assert.Equal(t, "Task verifying_links of job job-1: pending\n", cli.out.String())
+	})
+
+	t.Run("UnknownTaskType", func(t *testing.T) {
+		cli := newTestCLI(t)
+
+		err := cli.Run(ctx, []string{"tasks", "reset", "job-1", "crawling", "--yes"})
+		assert.ErrorIs(t, err, ErrUsage)
+		assert.ErrorContains(t, err, `unknown task type "crawling"`)
+	})
+
+	t.Run("NoTask", func(t *testing.T) {
+		cli := newTestCLI(t)
+		require.NoError(t, cli.tasks.DeleteTasksByJobId(ctx, "job-1"))
+
+		err := cli.Run(ctx, []string{"tasks", "reset", "job-1", "extracting", "--yes"})
+		assert.EqualError(t, err, "job job-1 has no extracting task")
+	})
+
+	t.Run("NotConfirmed", func(t *testing.T) {
+		cli := newTestCLI(t)
+
+		assert.ErrorIs(t, cli.Run(ctx, []string{"tasks", "reset", "job-1", "extracting"}), ErrUsage)
+	})
+}
+
+func TestCLI_DBBackup(t *testing.T) {
+	ctx := context.Background()
+	backup := func(table string) *repository.Backup {
+		return &repository.Backup{Table: table, Name: table + "-pre-deploy-20240501T120000Z", Status: "CREATING", CreatedAt: testNow}
+	}
+
+	t.Run("AllTables", func(t *testing.T) {
+		cli := newTestCLI(t)
+		backups := mocks.NewMockBackupRepositoryInterface(gomock.NewController(t))
+		for _, table := range repository.TableNames() {
+			backups.EXPECT().CreateBackup(gomock.Any(), table, "pre-deploy").Return(backup(table), nil)
+		}
+		WithBackups(backups)(cli.CLI)
+
+		require.NoError(t, cli.Run(ctx, []string{"db", "backup", "--label", "pre-deploy", "--json"}))
+		var created []repository.Backup
+		require.NoError(t, json.Unmarshal(cli.out.Bytes(), &created))
+		assert.Len(t, created, len(repository.TableNames()))
+	})
+
+	t.Run("PartlyFailed", func(t *testing.T) {
+		cli := newTestCLI(t)
+		backups := mocks.NewMockBackupRepositoryInterface(gomock.NewController(t))
+		backups.EXPECT().CreateBackup(gomock.Any(), repository.JobsTableName, "pre-deploy").Return(backup(repository.JobsTableName), nil)
+		backups.EXPECT().CreateBackup(gomock.Any(), repository.TasksTableName, "pre-deploy").Return(nil, errors.New("LimitExceededException"))
+		WithBackups(backups)(cli.CLI)
+
+		err := cli.Run(ctx, []string{"db", "backup", "--label", "pre-deploy"})
+		assert.ErrorContains(t, err, "failed to back up "+repository.TasksTableName)
+		assert.Contains(t, cli.errOut.String(), "backed up 1 of 3 tables")
+		assert.Contains(t, cli.out.String(), repository.JobsTableName+"-pre-deploy-20240501T120000Z")
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		cli := newTestCLI(t)
+		backups := mocks.NewMockBackupRepositoryInterface(gomock.NewController(t))
+		backups.EXPECT().CreateBackup(gomock.Any(), repository.GroupsTableName, "pre-deploy").Return(nil, repository.ErrBackupsUnsupported)
+		WithBackups(backups)(cli.CLI)
+
+		err := cli.Run(ctx, []string{"db", "backup", "--label", "pre-deploy", "--table", repository.GroupsTableName})
+		assert.ErrorIs(t, err, repository.ErrBackupsUnsupported)
+	})
+
+	t.Run("NoLabel", func(t *testing.T) {
+		cli := newTestCLI(t)
+
+		assert.ErrorIs(t, cli.Run(ctx, []string{"db", "backup"}), ErrUsage)
+	})
+
+	t.Run("NotConfigured", func(t *testing.T) {
+		cli := newTestCLI(t)
+
+		assert.EqualError(t, cli.Run(ctx, []string{"db", "backups"}), "no backup repository configured")
+	})
+}
+
+func TestCLI_DBVerifyRestore(t *testing.T) {
+	ctx := context.Background()
+	const restored = repository.JobsTableName + "-restored"
+
+	t.Run("Matches", func(t *testing.T) {
+		cli := newTestCLI(t)
+		backups := mocks.NewMockBackupRepositoryInterface(gomock.NewController(t))
+		backups.EXPECT().VerifyRestore(gomock.Any(), repository.JobsTableName, restored, 25).Return(&repository.RestoreReport{
+			SourceTable: repository.JobsTableName, RestoredTable: restored, SourceItems: 4, RestoredItems: 4, Sampled: 4,
+		}, nil)
+		WithBackups(backups)(cli.CLI)
+
+		require.NoError(t, cli.Run(ctx, []string{"db", "verify-restore", repository.JobsTableName, restored, "--sample", "25"}))
+		assert.Contains(t, cli.out.String(), "SAMPLED   4")
+	})
+
+	t.Run("Differs", func(t *testing.T) {
+		cli := newTestCLI(t)
+		backups := mocks.NewMockBackupRepositoryInterface(gomock.NewController(t))
+		backups.EXPECT().VerifyRestore(gomock.Any(), repository.JobsTableName, restored, defaultRestoreSample).Return(&repository.RestoreReport{
+			SourceTable: repository.JobsTableName, RestoredTable: restored, SourceItems: 4, RestoredItems: 3, Sampled: 4,
+			Missing: []string{"partition_key=1000, id=job-3"},
+		}, nil)
+		WithBackups(backups)(cli.CLI)
+
+		err := cli.Run(ctx, []string{"db", "verify-restore", repository.JobsTableName, restored})
+		assert.EqualError(t, err, restored+" does not match "+repository.JobsTableName)
+		assert.Contains(t, cli.out.String(), "MISSING   partition_key=1000, id=job-3")
+	})
+}
+
+func TestCLI_Run_Usage(t *testing.T) {
+	invalid := map[string][]string{
+		"NoCommand":       nil,
+		"UnknownCommand":  {"jobs", "delete", "job-1"},
+		"UnknownStatus":   {"jobs", "list", "--status", "stuck"},
+		"NegativeAge":     {"jobs", "list", "--older-than", "-1h"},
+		"ExtraArgument":   {"jobs", "show", "job-1", "job-2"},
+		"NoRestoredTable": {"db", "verify-restore", "web-analyzer-jobs"},
+	}
+	for name, args := range invalid {
+		t.Run(name, func(t *testing.T) {
+			cli := newTestCLI(t)
+
+			assert.ErrorIs(t, cli.Run(context.Background(), args), ErrUsage)
+		})
+	}
+}
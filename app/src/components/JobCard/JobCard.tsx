@@ -55,6 +55,20 @@ export function JobCard({ job, isNew = false }: JobCardProps) {
           {job.result && (
             <div className="pb-4">
               <h4 className="font-semibold mb-3 text-gray-800">Analysis Summary</h4>
+              {job.result.partial_result && job.status === 'running' && (
+                <p className="text-sm text-gray-500 mb-3">Verifying links, accessibility counts are not final yet.</p>
+              )}
+              {job.result.likely_client_side_rendered && (
+                <div className="text-sm text-amber-800 bg-amber-50 border border-amber-200 rounded p-3 mb-3">
+                  {job.result.warnings?.find(w => w.code === 'client_side_rendered')?.message ??
+                    'This page appears to be rendered by JavaScript, so the results may be incomplete.'}
+                </div>
+              )}
+              {job.result.warnings?.filter(w => w.code === 'task_failed').map(w => (
+                <div key={w.message} className="text-sm text-red-800 bg-red-50 border border-red-200 rounded p-3 mb-3">
+                  {w.message}
+                </div>
+              ))}
               <PageTitleCard title={job.result.page_title} />
               <div className="grid grid-cols-1 sm:grid-cols-2 gap-4">
                 <StatCard
@@ -1,6 +1,7 @@
 import { useEffect, useState } from 'react';
 import { ApiService } from '../../services/api';
 import { webSocketService } from '../../services/ws';
+import type { TaskProgress } from '../../services/ws';
 import type { Task, TaskType } from '../../types';
 import { StatusIcon } from './StatusIcon';
 import { SubTaskItem } from './SubTaskItem';
@@ -44,7 +45,7 @@ const formatTaskType = (taskType: TaskType): string => {
   }
 };
 
-const TaskItem = ({ task }: { task: Task }) => {
+const TaskItem = ({ task, tallies }: { task: Task; tallies?: TaskProgress }) => {
   const [expanded, setExpanded] = useState(true);
   const subtasks = task.subtasks ? Object.values(task.subtasks) : [];
   const completedSubtasks = subtasks.filter(st => st.status === 'completed' || st.status === 'failed').length;
@@ -63,6 +64,11 @@ const TaskItem = ({ task }: { task: Task }) => {
         </div>
         <div className="flex-grow">
           <p className="font-medium text-sm text-gray-800">{formatTaskType(task.type)}</p>
+          {tallies && task.status === 'running' && (
+            <p className="text-xs text-gray-500 mt-1">
+              {tallies.accessible ?? 0} accessible, {tallies.inaccessible ?? 0} inaccessible ({tallies.completed} / {tallies.total})
+            </p>
+          )}
           {hasSubtasks && (
             <div>
               <div className="flex justify-between items-center text-xs text-gray-500 mt-1">
@@ -126,6 +132,7 @@ export function TaskCard({ jobId, jobStatus }: TaskCardProps) {
     loading: false,
     error: null,
   });
+  const [tallies, setTallies] = useState<Partial<Record<TaskType, TaskProgress>>>({});
 
   useEffect(() => {
     setState(prev => ({ ...prev, loading: true }));
@@ -146,8 +153,11 @@ export function TaskCard({ jobId, jobStatus }: TaskCardProps) {
     // Set up subscriptions for active jobs
     webSocketService.subscribeToGroup(jobId);
 
-    const unsubscribeTask = webSocketService.subscribeToTaskUpdates((updatedJobId, taskType, status) => {
+    const unsubscribeTask = webSocketService.subscribeToTaskUpdates((updatedJobId, taskType, status, progress) => {
       if (updatedJobId === jobId) {
+        if (progress) {
+          setTallies(prev => ({ ...prev, [taskType]: progress }));
+        }
         setState(prev => {
           if (!prev.tasks) return prev;
 
@@ -212,7 +222,7 @@ export function TaskCard({ jobId, jobStatus }: TaskCardProps) {
   return (
     <div className="space-y-2">
       {state.tasks.map(task => (
-        <TaskItem key={task.type} task={task} />
+        <TaskItem key={task.type} task={task} tallies={tallies[task.type]} />
       ))}
     </div>
   );
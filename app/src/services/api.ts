@@ -1,4 +1,12 @@
-import type { Job, AnalyzeRequest, AnalyzeResponse, Task } from '../types';
+import type {
+  Job,
+  AnalyzeRequest,
+  AnalyzeResponse,
+  Task,
+  FindingSeverity,
+  FindingsResponse,
+  FindingCatalogResponse,
+} from '../types';
 
 const BASE_URL = 'http://localhost:8080';
 
@@ -16,7 +24,8 @@ export class ApiService {
 
     if (!response.ok) {
       if (response.status === 400) {
-        throw new Error(await response.text());
+        const error: { error: string } = await response.json();
+        throw new Error(error.error);
       }
       throw new Error(`Failed to create analyze job: ${response.statusText}`);
     }
@@ -43,4 +52,25 @@ export class ApiService {
 
     return response.json();
   }
-} 
\ No newline at end of file
+
+  static async getFindings(jobId: string, severity?: FindingSeverity): Promise<FindingsResponse> {
+    const query = severity ? `?severity=${severity}` : '';
+    const response = await fetch(`${BASE_URL}/jobs/${jobId}/findings${query}`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch findings: ${response.statusText}`);
+    }
+
+    return response.json();
+  }
+
+  static async getFindingCatalog(): Promise<FindingCatalogResponse> {
+    const response = await fetch(`${BASE_URL}/findings`);
+
+    if (!response.ok) {
+      throw new Error(`Failed to fetch finding catalog: ${response.statusText}`);
+    }
+
+    return response.json();
+  }
+}
@@ -4,7 +4,25 @@ interface JobUpdateMessage {
   type: 'job.update';
   job_id: string;
   status: JobStatus;
+  url?: string;
   result?: AnalyzeResult;
+  progress?: number;
+}
+
+interface JobLifecycleMessage {
+  type: 'job.lifecycle';
+  job_id: string;
+  status: JobStatus;
+  url?: string;
+  progress?: number;
+  timestamp: string;
+}
+
+export interface TaskProgress {
+  completed: number;
+  total: number;
+  accessible?: number;
+  inaccessible?: number;
 }
 
 interface TaskStatusUpdateMessage {
@@ -12,6 +30,7 @@ interface TaskStatusUpdateMessage {
   job_id: string;
   task_type: TaskType;
   status: TaskStatus;
+  progress?: TaskProgress;
 }
 
 interface SubTaskUpdateMessage {
@@ -22,10 +41,22 @@ interface SubTaskUpdateMessage {
   subtask: SubTask;
 }
 
-type WebSocketMessage = JobUpdateMessage | TaskStatusUpdateMessage | SubTaskUpdateMessage;
+interface HelloAckMessage {
+  type: 'hello.ack';
+  instance_id: string;
+  connection_id: string;
+}
+
+interface SubscribeRejectedMessage {
+  type: 'subscribe.rejected';
+  group: string;
+  reason: 'job_deleted';
+}
+
+type WebSocketMessage = JobUpdateMessage | JobLifecycleMessage | TaskStatusUpdateMessage | SubTaskUpdateMessage | HelloAckMessage | SubscribeRejectedMessage;
 
 type JobUpdateCallback = (jobId: string, status: JobStatus, result?: AnalyzeResult) => void;
-type TaskUpdateCallback = (jobId: string, taskType: TaskType, status: TaskStatus) => void;
+type TaskUpdateCallback = (jobId: string, taskType: TaskType, status: TaskStatus, progress?: TaskProgress) => void;
 type SubTaskUpdateCallback = (jobId: string, taskType: TaskType, key: string, subtask: SubTask) => void;
 
 const WS_URL = 'ws://localhost:8081/ws';
@@ -49,6 +80,7 @@ class WebSocketService {
     this.ws.onopen = () => {
       this.isConnecting = false;
       console.log("WebSocket connection established.");
+      this.ws?.send(JSON.stringify({ action: 'hello' }));
       
       // Re-subscribe to all groups after reconnection
       this.subscribedGroups.forEach(group => {
@@ -68,7 +100,7 @@ class WebSocketService {
             break;
           case 'task.status_update':
             this.taskUpdateCallbacks.forEach(callback =>
-              callback(message.job_id, message.task_type, message.status)
+              callback(message.job_id, message.task_type, message.status, message.progress)
             );
             break;
           case 'task.subtask_update':
@@ -76,6 +108,19 @@ class WebSocketService {
               callback(message.job_id, message.task_type, message.key, message.subtask)
             );
             break;
+          case 'job.lifecycle':
+            // Only sent when subscribed to the jobs.lifecycle group, in place of the job updates
+            this.jobUpdateCallbacks.forEach(callback => callback(message.job_id, message.status));
+            break;
+          case 'hello.ack':
+            // The connection ID lets support look the connection up when updates go missing
+            console.log('Connected to notifications instance:', message.instance_id, 'connection:', message.connection_id);
+            break;
+          case 'subscribe.rejected':
+            // Not resubscribed on reconnect, the job is gone
+            this.subscribedGroups.delete(message.group);
+            console.warn('Subscription rejected:', message.group, message.reason);
+            break;
           default:
             console.warn('Unknown message type:', message);
         }
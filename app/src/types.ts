@@ -6,9 +6,22 @@ export interface Job {
   updated_at: Date;
   started_at?: Date;
   completed_at?: Date;
+  tasks?: TaskType[];
+  verify_scope?: LinkScope;
+  header_profile?: HeaderProfile;
+  progress: number;
+  version: number;
+  deleted_at?: Date;
+  failure_code?: FailureCode;
+  failure_reason?: string;
+  fetch_status_code?: number;
+  attempt_id?: string;
+  last_heartbeat_at?: Date;
   result?: AnalyzeResult;
 }
 
+export type FailureCode = 'fetch_failed' | 'parse_failed' | 'repository_error' | 'internal_error';
+
 export type JobStatus = 'pending' | 'running' | 'completed' | 'failed' | 'cancelled';
 
 export interface AnalyzeResult {
@@ -21,14 +34,123 @@ export interface AnalyzeResult {
   accessible_links: number;
   inaccessible_links: number;
   has_login_form: boolean;
+  insecure_forms?: InsecureForm[];
+  asset_links?: Record<string, number>;
+  link_classifications?: LinkClassification[];
+  original_links?: string[];
+  collapsed_links?: number;
+  trackers_detected?: string[];
+  tracker_count?: number;
+  canonical_url?: string;
+  canonical_mismatch?: boolean;
+  og_url?: string;
+  canonical_consistency?: {
+    matches: boolean;
+    reason?: 'missing_canonical' | 'different_host' | 'insecure_canonical' | 'different_url' | 'og_url_mismatch';
+  };
+  is_amp?: boolean;
+  pagination?: Pagination;
+  no_index?: boolean;
+  no_follow?: boolean;
+  partial_result?: boolean;
+  sampled_verification?: boolean;
+  sample_fraction?: number;
+  estimated_accessible_links?: number;
+  estimated_inaccessible_links?: number;
+  skipped_tasks?: TaskType[];
+  failed_tasks?: TaskType[];
+  links_omitted?: boolean;
+  links_truncated?: boolean;
+  likely_client_side_rendered?: boolean;
+  warnings?: ResultWarning[];
+  fetch_timing?: FetchTiming;
+}
+
+export interface Pagination {
+  next_url?: string;
+  prev_url?: string;
+  pages?: PaginationPage[];
+  stop_reason?: 'max_pages' | 'loop' | 'other_host' | 'fetch_failed';
+}
+
+export interface PaginationPage {
+  url: string;
+  headings: Record<string, number>;
+  link_offset: number;
+  link_count: number;
+}
+
+export interface FetchTiming {
+  dns_seconds: number;
+  connect_seconds: number;
+  tls_handshake_seconds: number;
+  ttfb_seconds: number;
+  download_seconds: number;
+  total_seconds: number;
+}
+
+export interface InsecureForm {
+  action: string;
+  reason: 'http_action' | 'password_get';
+}
+
+export interface LinkClassification {
+  url: string;
+  external: boolean;
+  reason: LinkClassificationReason;
+}
+
+export type LinkClassificationReason =
+  | 'same_origin'
+  | 'no_base_url'
+  | 'unparsable_url'
+  | 'different_scheme'
+  | 'different_port'
+  | 'subdomain'
+  | 'different_host';
+
+export interface ResultWarning {
+  code: string;
+  message: string;
+}
+
+export type FindingSeverity = 'error' | 'warning' | 'info';
+
+export interface FindingDefinition {
+  code: string;
+  severity: FindingSeverity;
+  title: string;
+  docs_anchor: string;
+}
+
+export interface Finding extends FindingDefinition {
+  message: string;
 }
 
+export interface FindingsResponse {
+  job_id: string;
+  findings: Finding[];
+  summary: Record<FindingSeverity, number>;
+}
+
+export interface FindingCatalogResponse {
+  findings: FindingDefinition[];
+}
+
+export type LinkScope = 'all' | 'internal' | 'external';
+
+export type HeaderProfile = 'default' | 'browser' | 'fallback';
+
 export interface AnalyzeRequest {
   url: string;
+  tasks?: TaskType[];
+  verify_scope?: LinkScope;
+  header_profile?: HeaderProfile;
 }
 
 export interface AnalyzeResponse {
   job: Job;
+  skipped_tasks?: TaskType[];
 }
 
 export interface Task {
@@ -56,6 +178,19 @@ export interface SubTask {
   status: TaskStatus;
   url: string;
   description: string;
+  content_type?: string;
+  content_length?: number;
+  error_code?: LinkErrorCode;
 }
 
+export type LinkErrorCode =
+  | 'dns_not_found'
+  | 'connection_refused'
+  | 'connection_reset'
+  | 'timeout'
+  | 'tls_certificate_invalid'
+  | 'tls_handshake_failure'
+  | 'too_many_redirects'
+  | 'request_failed';
+
 export type SubTaskType = 'validating_link'; 
\ No newline at end of file
@@ -0,0 +1,105 @@
+// Package examples shows how to use the web analyzer from Go with nothing but net/http and a websocket client.
+// Each example is a runnable program under cmd/ and an Example test run against an in-process server,
+// so the examples are compiled and executed with the rest of the tests.
+package examples
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrJobFailed is returned when a job ends failed or cancelled rather than completed
+var ErrJobFailed = errors.New("job did not complete")
+
+// Job is the part of a job the examples read
+type Job struct {
+	ID       string  `json:"id"`
+	URL      string  `json:"url"`
+	Status   string  `json:"status"`
+	Progress float64 `json:"progress"`
+	Result   *Result `json:"result,omitempty"`
+}
+
+// Result is the part of an analysis result the examples read
+type Result struct {
+	HTMLVersion       string         `json:"html_version"`
+	PageTitle         string         `json:"page_title"`
+	Headings          map[string]int `json:"headings"`
+	InternalLinkCount int            `json:"internal_link_count"`
+	ExternalLinkCount int            `json:"external_link_count"`
+	AccessibleLinks   int            `json:"accessible_links"`
+	InaccessibleLinks int            `json:"inaccessible_links"`
+	HasLoginForm      bool           `json:"has_login_form"`
+}
+
+// finished reports whether a job status is final
+func finished(status string) bool {
+	return status == "completed" || status == "failed" || status == "cancelled"
+}
+
+// Submit queues the analysis of pageURL and returns the pending job
+func Submit(ctx context.Context, apiURL, pageURL string) (*Job, error) {
+	body, err := json.Marshal(map[string]string{"url": pageURL})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL+"/analyze", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var submitted struct {
+		Job Job `json:"job"`
+	}
+	if err := do(req, http.StatusAccepted, &submitted); err != nil {
+		return nil, fmt.Errorf("submitting %s: %w", pageURL, err)
+	}
+	return &submitted.Job, nil
+}
+
+// GetJob reads the current state of a job
+func GetJob(ctx context.Context, apiURL, jobID string) (*Job, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL+"/jobs/"+url.PathEscape(jobID), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var job Job
+	if err := do(req, http.StatusOK, &job); err != nil {
+		return nil, fmt.Errorf("getting job %s: %w", jobID, err)
+	}
+	return &job, nil
+}
+
+// do sends the request and decodes the JSON response, turning any other status than want into an error
+func do(req *http.Request, want int, v any) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != want {
+		return responseError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// responseError reads the error the API answered with, {"error": "...", "status": 404}
+func responseError(resp *http.Response) error {
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || strings.TrimSpace(apiErr.Error) == "" {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	return fmt.Errorf("%s: %s", resp.Status, apiErr.Error)
+}
@@ -0,0 +1,35 @@
+// Command export_links writes the links of a finished job and their verification outcome to stdout as CSV.
+//
+//	go run ./cmd/export_links -job 01H8X8Z8Z8Z8Z8Z8Z8Z8Z8Z8Z8 > links.csv
+package main
+
+import (
+	"context"
+	"examples"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+)
+
+func main() {
+	apiURL := flag.String("api", "http://localhost:8080", "base URL of the API service")
+	jobID := flag.String("job", "", "ID of the finished job to export")
+	flag.Parse()
+
+	if *jobID == "" {
+		fmt.Fprintln(os.Stderr, "-job is required")
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := examples.ExportLinks(ctx, *apiURL, *jobID, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
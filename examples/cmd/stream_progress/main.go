@@ -0,0 +1,42 @@
+// Command stream_progress analyzes a page and prints the job's progress as the notifications service pushes it.
+//
+//	go run ./cmd/stream_progress -url https://example.com
+package main
+
+import (
+	"context"
+	"examples"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+)
+
+func main() {
+	apiURL := flag.String("api", "http://localhost:8080", "base URL of the API service")
+	wsURL := flag.String("ws", "ws://localhost:8081/ws", "websocket endpoint of the notifications service")
+	pageURL := flag.String("url", "https://example.com", "page to analyze")
+	timeout := flag.Duration("timeout", 2*time.Minute, "how long to wait for the job to finish")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	final, err := examples.StreamProgress(ctx, *apiURL, *wsURL, *pageURL, func(update examples.Update) {
+		switch update.Type {
+		case "job.update":
+			fmt.Printf("job %s: %.0f%%\n", update.Status, update.Progress)
+		case "task.status_update":
+			fmt.Printf("  %s: %s\n", update.TaskType, update.Status)
+		}
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("done: %q\n", final.Result.PageTitle)
+}
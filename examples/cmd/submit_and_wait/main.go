@@ -0,0 +1,39 @@
+// Command submit_and_wait analyzes a page and prints a summary of its result once the job finishes.
+//
+//	go run ./cmd/submit_and_wait -url https://example.com
+package main
+
+import (
+	"context"
+	"examples"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+)
+
+func main() {
+	apiURL := flag.String("api", "http://localhost:8080", "base URL of the API service")
+	pageURL := flag.String("url", "https://example.com", "page to analyze")
+	timeout := flag.Duration("timeout", 2*time.Minute, "how long to wait for the job to finish")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	job, err := examples.SubmitAndWait(ctx, *apiURL, *pageURL, time.Second)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	result := job.Result
+	fmt.Printf("%s %s: %q (%s)\n", job.ID, job.Status, result.PageTitle, result.HTMLVersion)
+	fmt.Printf("headings: %v\n", result.Headings)
+	fmt.Printf("links: %d internal, %d external, %d inaccessible\n",
+		result.InternalLinkCount, result.ExternalLinkCount, result.InaccessibleLinks)
+	fmt.Printf("login form: %t\n", result.HasLoginForm)
+}
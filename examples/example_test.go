@@ -0,0 +1,146 @@
+package examples_test
+
+import (
+	"context"
+	"errors"
+	"examples"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+func Example_submitAndWait() {
+	server := newFakeAnalyzer()
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	job, err := examples.SubmitAndWait(ctx, server.URL, "https://example.com", 10*time.Millisecond)
+	if err != nil {
+		fmt.Println("error:", err)
+		return
+	}
+
+	result := job.Result
+	fmt.Printf("%s %s: %q (%s)\n", job.ID, job.Status, result.PageTitle, result.HTMLVersion)
+	fmt.Printf("links: %d internal, %d external, %d inaccessible\n",
+		result.InternalLinkCount, result.ExternalLinkCount, result.InaccessibleLinks)
+	// Output:
+	// job-1 completed: "Example Domain" (HTML5)
+	// links: 3 internal, 1 external, 1 inaccessible
+}
+
+func Example_failedJob() {
+	server := newFakeAnalyzer()
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	job, err := examples.SubmitAndWait(ctx, server.URL, "https://unreachable.example.com", 10*time.Millisecond)
+	if errors.Is(err, examples.ErrJobFailed) {
+		fmt.Printf("%s ended %s\n", job.ID, job.Status)
+		return
+	}
+	fmt.Println("unexpected:", err)
+	// Output:
+	// job-1 ended failed
+}
+
+func Example_waitTimeout() {
+	server := newFakeAnalyzer()
+	defer server.Close()
+
+	// The job never finishes, so stop waiting after a while
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	job, err := examples.SubmitAndWait(ctx, server.URL, "https://slow.example.com", 10*time.Millisecond)
+	if errors.Is(err, context.DeadlineExceeded) {
+		fmt.Printf("gave up on %s while it was %s at %.0f%%\n", job.ID, job.Status, job.Progress)
+		return
+	}
+	fmt.Println("unexpected:", err)
+	// Output:
+	// gave up on job-1 while it was running at 40%
+}
+
+func Example_streamProgress() {
+	server := newFakeAnalyzer()
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	final, err := examples.StreamProgress(ctx, server.URL, server.WebSocketURL(), "https://example.com",
+		func(update examples.Update) {
+			switch update.Type {
+			case "job.update":
+				fmt.Printf("job %s: %.0f%%\n", update.Status, update.Progress)
+			case "task.status_update":
+				fmt.Printf("  %s: %s\n", update.TaskType, update.Status)
+			}
+		})
+	if err != nil {
+		fmt.Println("error:", err)
+		return
+	}
+
+	fmt.Printf("done: %q\n", final.Result.PageTitle)
+	// Output:
+	// job running: 40%
+	//   extracting: completed
+	//   analyzing: completed
+	//   verifying_links: running
+	// job completed: 100%
+	// done: "Example Domain"
+}
+
+func Example_streamProgressTimeout() {
+	server := newFakeAnalyzer()
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := examples.StreamProgress(ctx, server.URL, server.WebSocketURL(), "https://slow.example.com",
+		func(examples.Update) {})
+	fmt.Println("timed out:", errors.Is(err, context.DeadlineExceeded))
+	// Output:
+	// timed out: true
+}
+
+func Example_exportLinks() {
+	server := newFakeAnalyzer()
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	job, err := examples.SubmitAndWait(ctx, server.URL, "https://example.com", 10*time.Millisecond)
+	if err != nil {
+		fmt.Println("error:", err)
+		return
+	}
+	if err := examples.ExportLinks(ctx, server.URL, job.ID, os.Stdout); err != nil {
+		fmt.Println("error:", err)
+		return
+	}
+
+	// A job that has not finished has nothing to export yet
+	pending, err := examples.Submit(ctx, server.URL, "https://example.com")
+	if err != nil {
+		fmt.Println("error:", err)
+		return
+	}
+	fmt.Println(examples.ExportLinks(ctx, server.URL, pending.ID, io.Discard))
+	// Output:
+	// url,type,status,status_code,description
+	// https://example.com/about,internal,completed,200,HTTP 200: OK
+	// https://example.com/blog,internal,completed,200,HTTP 200: OK
+	// https://example.com/contact,internal,completed,200,HTTP 200: OK
+	// https://www.iana.org/domains,external,failed,404,HTTP 404: Not Found
+	// exporting job job-2: 409 Conflict: Job has no result yet
+}
@@ -0,0 +1,33 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// ExportLinks writes the links of a finished job to w as CSV, one row per link with its verification outcome:
+// url, type, status, status_code and description.
+func ExportLinks(ctx context.Context, apiURL, jobID string, w io.Writer) error {
+	endpoint := apiURL + "/jobs/" + url.PathEscape(jobID) + "/export?format=csv"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("exporting job %s: %w", jobID, err)
+	}
+	defer resp.Body.Close()
+
+	// A job that is still running has no links to export yet and is answered with 409 Conflict
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("exporting job %s: %w", jobID, responseError(resp))
+	}
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
@@ -0,0 +1,228 @@
+package examples_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"shared/messagebus"
+	"shared/middleware"
+	"shared/models"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Pages the fake analyzer knows how each job for them ends
+const (
+	completingPage = "https://example.com"
+	failingPage    = "https://unreachable.example.com"
+	stuckPage      = "https://slow.example.com"
+)
+
+// exampleResult is the result of every completed job
+var exampleResult = models.AnalyzeResult{
+	HtmlVersion:       "HTML5",
+	PageTitle:         "Example Domain",
+	Headings:          map[string]int{"h1": 1, "h2": 2},
+	Links:             []string{"https://example.com/about", "https://example.com/blog", "https://example.com/contact", "https://www.iana.org/domains"},
+	InternalLinkCount: 3,
+	ExternalLinkCount: 1,
+	AccessibleLinks:   3,
+	InaccessibleLinks: 1,
+}
+
+// fakeAnalyzer serves the API routes and the notifications websocket the examples use.
+// Responses are built from the shared models and messages, so a change to their shape breaks the examples.
+// Jobs advance each time they are read, or all at once over the websocket.
+type fakeAnalyzer struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	jobs map[string]*models.Job
+}
+
+func newFakeAnalyzer() *fakeAnalyzer {
+	f := &fakeAnalyzer{jobs: make(map[string]*models.Job)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /analyze", f.handleAnalyze)
+	mux.HandleFunc("GET /jobs/{id}", f.handleGetJob)
+	mux.HandleFunc("GET /jobs/{id}/export", f.handleExport)
+	mux.HandleFunc("GET /ws", f.handleWebSocket)
+	f.Server = httptest.NewServer(mux)
+	return f
+}
+
+// WebSocketURL returns the address of the notifications websocket
+func (f *fakeAnalyzer) WebSocketURL() string {
+	return "ws" + strings.TrimPrefix(f.URL, "http") + "/ws"
+}
+
+func (f *fakeAnalyzer) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == "" {
+		middleware.WriteError(w, r, http.StatusBadRequest, "Invalid URL, please check the URL and try again.")
+		return
+	}
+
+	f.mu.Lock()
+	job := &models.Job{
+		ID:        "job-" + strconv.Itoa(len(f.jobs)+1),
+		URL:       req.URL,
+		Status:    models.JobStatusPending,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	f.jobs[job.ID] = job
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(struct {
+		Job models.Job `json:"job"`
+	}{Job: *job})
+}
+
+func (f *fakeAnalyzer) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	job, ok := f.jobs[r.PathValue("id")]
+	if ok {
+		f.advance(job)
+	}
+	var snapshot models.Job
+	if ok {
+		snapshot = *job
+	}
+	f.mu.Unlock()
+
+	if !ok {
+		middleware.WriteError(w, r, http.StatusNotFound, "Job not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(snapshot)
+}
+
+// advance moves a job one step towards how it ends for its page, callers hold f.mu
+func (f *fakeAnalyzer) advance(job *models.Job) {
+	switch {
+	case job.Status.IsTerminal():
+	case job.URL == failingPage:
+		job.Status = models.JobStatusFailed
+	case job.URL == stuckPage || job.Status == models.JobStatusPending:
+		job.Status, job.Progress = models.JobStatusRunning, 40
+	default:
+		result := exampleResult
+		job.Status, job.Progress, job.Result = models.JobStatusCompleted, 100, &result
+	}
+}
+
+func (f *fakeAnalyzer) handleExport(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	job, ok := f.jobs[r.PathValue("id")]
+	var result *models.AnalyzeResult
+	if ok {
+		result = job.Result
+	}
+	f.mu.Unlock()
+
+	switch {
+	case !ok:
+		middleware.WriteError(w, r, http.StatusNotFound, "Job not found")
+		return
+	case result == nil:
+		middleware.WriteError(w, r, http.StatusConflict, "Job has no result yet")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	out := csv.NewWriter(w)
+	_ = out.Write([]string{"url", "type", "status", "status_code", "description"})
+	for i, link := range result.Links {
+		status, code := models.TaskStatusCompleted, http.StatusOK
+		if i == len(result.Links)-1 {
+			status, code = models.TaskStatusFailed, http.StatusNotFound
+		}
+		linkType := "internal"
+		if !strings.HasPrefix(link, completingPage) {
+			linkType = "external"
+		}
+		_ = out.Write([]string{link, linkType, string(status), strconv.Itoa(code), "HTTP " + strconv.Itoa(code) + ": " + http.StatusText(code)})
+	}
+	out.Flush()
+}
+
+// handleWebSocket waits for a subscription and plays the subscribed job through to its end
+func (f *fakeAnalyzer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Upgrade(w, r, nil, 1024, 1024)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var sub struct {
+		Action string `json:"action"`
+		Group  string `json:"group"`
+	}
+	if err := conn.ReadJSON(&sub); err != nil || sub.Action != "subscribe" {
+		return
+	}
+
+	// Job updates are broadcast, so clients also see other jobs
+	messages := []any{jobUpdate("job-0", models.JobStatusRunning, 10, nil)}
+
+	f.mu.Lock()
+	job, ok := f.jobs[sub.Group]
+	for ok && !job.Status.IsTerminal() {
+		f.advance(job)
+		messages = append(messages, jobUpdate(job.ID, job.Status, job.Progress, job.Result))
+		if job.Status == models.JobStatusRunning {
+			messages = append(messages,
+				taskUpdate(job.ID, models.TaskTypeExtracting, models.TaskStatusCompleted),
+				taskUpdate(job.ID, models.TaskTypeAnalyzing, models.TaskStatusCompleted),
+				taskUpdate(job.ID, models.TaskTypeVerifyingLinks, models.TaskStatusRunning))
+		}
+		if job.URL == stuckPage {
+			break
+		}
+	}
+	f.mu.Unlock()
+
+	for _, msg := range messages {
+		if err := conn.WriteJSON(msg); err != nil {
+			return
+		}
+	}
+
+	// Hold the connection open until the client leaves
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func jobUpdate(jobID string, status models.JobStatus, progress float64, result *models.AnalyzeResult) messagebus.JobUpdateMessage {
+	return messagebus.JobUpdateMessage{
+		Type:     messagebus.JobUpdateMessageType,
+		JobID:    jobID,
+		Status:   string(status),
+		Result:   result,
+		Progress: &progress,
+	}
+}
+
+func taskUpdate(jobID string, taskType models.TaskType, status models.TaskStatus) messagebus.TaskStatusUpdateMessage {
+	return messagebus.TaskStatusUpdateMessage{
+		Type:     messagebus.TaskStatusUpdateMessageType,
+		JobID:    jobID,
+		TaskType: string(taskType),
+		Status:   string(status),
+	}
+}
@@ -0,0 +1,82 @@
+package examples
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// Update is a job or task update pushed by the notifications service
+type Update struct {
+	// Type is job.update, task.status_update or task.subtask_update
+	Type     string `json:"type"`
+	JobID    string `json:"job_id"`
+	TaskType string `json:"task_type,omitempty"`
+	Status   string `json:"status"`
+	// Progress is the job's progress in percent, only set on job.update
+	Progress float64 `json:"-"`
+	Result   *Result `json:"result,omitempty"`
+}
+
+// StreamProgress submits pageURL for analysis and passes every update of the job to onUpdate until it finishes.
+// It returns the final job.update, with ErrJobFailed when the job did not complete.
+func StreamProgress(ctx context.Context, apiURL, wsURL, pageURL string, onUpdate func(Update)) (*Update, error) {
+	// Connect before submitting: job updates are sent to every client, so the job cannot finish unseen
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", wsURL, err)
+	}
+	defer conn.Close()
+
+	// Closing the connection unblocks the read below once ctx is done
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	job, err := Submit(ctx, apiURL, pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Task updates are only sent to the clients subscribed to the job
+	if err := conn.WriteJSON(map[string]string{"action": "subscribe", "group": job.ID}); err != nil {
+		return nil, fmt.Errorf("subscribing to job %s: %w", job.ID, err)
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("waiting for job %s: %w", job.ID, ctx.Err())
+			}
+			return nil, fmt.Errorf("reading updates of job %s: %w", job.ID, err)
+		}
+
+		var update Update
+		if err := json.Unmarshal(data, &update); err != nil {
+			return nil, fmt.Errorf("decoding update: %w", err)
+		}
+		if update.JobID != job.ID {
+			continue // replies such as hello.ack, or another job's update
+		}
+		if update.Type == "job.update" {
+			var progress struct {
+				Progress float64 `json:"progress"`
+			}
+			if err := json.Unmarshal(data, &progress); err != nil {
+				return nil, fmt.Errorf("decoding job progress: %w", err)
+			}
+			update.Progress = progress.Progress
+		}
+
+		onUpdate(update)
+
+		if update.Type == "job.update" && finished(update.Status) {
+			if update.Status != "completed" {
+				return &update, fmt.Errorf("%w: job %s %s", ErrJobFailed, job.ID, update.Status)
+			}
+			return &update, nil
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SubmitAndWait submits pageURL for analysis and polls the job every interval until it finishes.
+// A job that fails or is cancelled returns ErrJobFailed along with the job,
+// and giving up when ctx is done returns its error with the job as last seen.
+func SubmitAndWait(ctx context.Context, apiURL, pageURL string, interval time.Duration) (*Job, error) {
+	job, err := Submit(ctx, apiURL, pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for !finished(job.Status) {
+		select {
+		case <-ctx.Done():
+			return job, fmt.Errorf("waiting for job %s: %w", job.ID, ctx.Err())
+		case <-ticker.C:
+		}
+
+		latest, err := GetJob(ctx, apiURL, job.ID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return job, fmt.Errorf("waiting for job %s: %w", job.ID, ctx.Err())
+			}
+			return job, err
+		}
+		job = latest
+	}
+
+	if job.Status != "completed" {
+		return job, fmt.Errorf("%w: job %s %s", ErrJobFailed, job.ID, job.Status)
+	}
+	return job, nil
+}
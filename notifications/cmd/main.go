@@ -8,9 +8,11 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	sharedconfig "shared/config"
 	"shared/log"
 	"shared/messagebus"
 	"shared/metrics"
+	"shared/repository"
 	"shared/tracing"
 	"syscall"
 	"time"
@@ -26,7 +28,23 @@ func main() {
 
 	// Setup logging
 	logger := log.SetupFromEnv(cfg.Service.Name)
-	logger.Info("Starting notifications service")
+
+	// The effective configuration is printed before it is checked, so the values behind the problems show
+	printConfig := sharedconfig.PrintRequested(os.Args[1:])
+	if printConfig {
+		if err := sharedconfig.Print(os.Stdout, cfg.Redacted()); err != nil {
+			logger.Error("Failed to print configuration", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		sharedconfig.LogProblems(logger, err)
+		os.Exit(1)
+	}
+	if printConfig {
+		return
+	}
+	logger.Info("Starting notifications service", slog.String("instanceId", cfg.Service.InstanceID))
 
 	// Setup tracing
 	otelShutdown, err := tracing.SetupOTelSDK(ctx, cfg.Tracing)
@@ -50,13 +68,19 @@ func main() {
 		deps.MessageBus,
 		notifications.WithLogger(logger),
 		notifications.WithConfig(cfg),
+		notifications.WithMetrics(deps.Metrics),
+		notifications.WithWebhooks(deps.Webhooks),
 	)
+	// Slow consumer reports arrive on the connection, they need the service's subscriptions and metrics
+	deps.NC.SetErrorHandler(notificationService.HandleAsyncError)
 
 	// Create and start server
 	srv := notifications.NewServer(
 		notificationService,
 		notifications.WithServerConfig(&cfg.HTTP),
 		notifications.WithServerLogger(logger),
+		notifications.WithAdminToken(cfg.Admin.Token),
+		notifications.WithDebugConfig(cfg.Redacted()),
 	)
 
 	// Start server in goroutine
@@ -91,11 +115,13 @@ type dependencies struct {
 	MessageBus *messagebus.MessageBus
 	Metrics    *metrics.NotificationsMetrics
 	NC         *nats.Conn
+	// Webhooks is nil when no webhook is configured
+	Webhooks *notifications.WebhookDispatcher
 }
 
 func initializeDependencies(cfg *config.Config, logger *slog.Logger) (*dependencies, func(), error) {
 	// Initialize metrics
-	m := metrics.NewNotificationsMetrics()
+	m := metrics.NewNotificationsMetrics(cfg.Service.InstanceID)
 	m.MustRegisterNotifications()
 	m.SetServiceInfo(cfg.Service.Version, runtime.Version())
 
@@ -109,12 +135,24 @@ func initializeDependencies(cfg *config.Config, logger *slog.Logger) (*dependenc
 	}
 
 	// Create message bus
-	mb := messagebus.New(nc, m)
+	mb := messagebus.New(nc, m, messagebus.WithSlowHandlerThreshold(cfg.NATS.SlowHandlerThreshold))
+
+	// Create job repository, used to refuse subscriptions to deleted jobs
+	jobRepo, err := repository.NewJobRepository(cfg.DynamoDB, repository.WithJobMetrics(m))
+	if err != nil {
+		nc.Close()
+		return nil, nil, err
+	}
 
 	// Create WebSocket hub
 	hub := notifications.NewHub(
 		notifications.WithHubMetrics(m),
 		notifications.WithHubLogger(logger),
+		notifications.WithHubInstanceID(cfg.Service.InstanceID),
+		notifications.WithHubMessageValidation(cfg.Contract.ValidateMessages),
+		notifications.WithHubJobLookup(jobRepo),
+		notifications.WithHubTerminalEvents(cfg.Terminal.TTL, cfg.Terminal.MaxJobs),
+		notifications.WithHubBroadcastHistory(cfg.History.Size),
 	)
 
 	deps := &dependencies{
@@ -123,6 +161,22 @@ func initializeDependencies(cfg *config.Config, logger *slog.Logger) (*dependenc
 		Metrics:    m,
 		NC:         nc,
 	}
+	var deadLetters *os.File
+	if len(cfg.Webhook.URLs) > 0 {
+		opts := []notifications.WebhookOption{
+			notifications.WithWebhookLogger(logger),
+			notifications.WithWebhookMetrics(m),
+		}
+		if cfg.Webhook.DeadLetterFile != "" {
+			deadLetters, err = os.OpenFile(cfg.Webhook.DeadLetterFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+			if err != nil {
+				nc.Close()
+				return nil, nil, err
+			}
+			opts = append(opts, notifications.WithWebhookDeadLetters(deadLetters))
+		}
+		deps.Webhooks = notifications.NewWebhookDispatcher(cfg.Webhook, opts...)
+	}
 
 	cleanup := func() {
 		logger.Info("Cleaning up dependencies")
@@ -139,6 +193,16 @@ func initializeDependencies(cfg *config.Config, logger *slog.Logger) (*dependenc
 
 		// Close WebSocket hub
 		hub.Close()
+
+		// Abandon the webhook deliveries still being retried
+		if deps.Webhooks != nil {
+			deps.Webhooks.Close()
+		}
+		if deadLetters != nil {
+			if err := deadLetters.Close(); err != nil {
+				logger.Error("Failed to close webhook dead-letter file", slog.Any("error", err))
+			}
+		}
 	}
 
 	return deps, cleanup, nil
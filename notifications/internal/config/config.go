@@ -1,27 +1,202 @@
 package config
 
 import (
+	"net/url"
 	"shared/config"
+	"slices"
+	"strings"
+	"time"
 )
 
 // Config is the configuration for the notifications service
 type Config struct {
 	Service   config.ServiceConfig
+	Admin     config.AdminConfig
 	HTTP      config.HTTPServerConfig
 	WebSocket config.WebSocketConfig
 	Metrics   config.MetricsConfig
 	Tracing   config.TracingConfig
 	NATS      config.NATSConfig
+	DynamoDB  config.DynamoDBConfig
+	Presence  PresenceConfig
+	Contract  ContractConfig
+	Terminal  TerminalEventsConfig
+	History   BroadcastHistoryConfig
+	Pending   PendingLimitsConfig
+	Webhook   WebhookConfig
+}
+
+// PresenceConfig holds settings for the heartbeat replicas exchange to report cluster status
+type PresenceConfig struct {
+	// Interval is how often the replica publishes its connection counts
+	Interval time.Duration
+}
+
+// ContractConfig holds settings for checking outgoing websocket messages against their schemas
+type ContractConfig struct {
+	// ValidateMessages logs and counts messages that break their schema, for dev and staging as it costs a decode per message
+	ValidateMessages bool
+}
+
+// TerminalEventsConfig holds settings for keeping final job updates for clients that subscribe after the job finished
+type TerminalEventsConfig struct {
+	// TTL is how long the final update of a job is kept, zero keeps none
+	TTL time.Duration
+	// MaxJobs caps the number of jobs whose final update is kept, the oldest are dropped first
+	MaxJobs int
+}
+
+// BroadcastHistoryConfig holds settings for the record of recent broadcasts served on GET /debug/broadcasts
+type BroadcastHistoryConfig struct {
+	// Size is how many broadcasts are kept, the oldest are dropped first. Zero keeps none.
+	Size int
+}
+
+// PendingLimitsConfig holds how many messages each NATS subscription buffers while its handler is busy.
+// Past either limit NATS drops the messages of the subscription, so they never reach the clients.
+type PendingLimitsConfig struct {
+	// Messages is the most messages buffered per subscription
+	Messages int
+	// Bytes is the most message bytes buffered per subscription
+	Bytes int
+}
+
+// WebhookEventJobFinal selects the final update of each job, the job.update whose status is terminal
+const WebhookEventJobFinal = "job.final"
+
+// WebhookEvents are the updates a webhook can be posted: the final job updates or every message of a type
+var WebhookEvents = []string{WebhookEventJobFinal, "job.update", "task.status_update", "task.subtask_update"}
+
+// WebhookConfig holds the endpoints job and task updates are posted to, and how deliveries are retried
+type WebhookConfig struct {
+	// URLs are the endpoints posted to, none turns webhooks off
+	URLs []string
+	// Events selects the updates posted, out of WebhookEvents
+	Events []string
+	// Secret is the key of the HMAC-SHA256 signature sent with each delivery, for receivers to check it came from us
+	Secret string
+	// Timeout bounds each delivery attempt
+	Timeout time.Duration
+	// MaxAttempts is how many times a delivery is tried. RetryBackoff is the wait before the first retry,
+	// doubled before each one after it.
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	// Workers is how many deliveries are made at once. The deliveries of a job to an endpoint always go to the
+	// same worker, one after the other, so they arrive in order.
+	Workers int
+	// QueueSize is how many deliveries each worker holds waiting, those dispatched past it are dropped
+	QueueSize int
+	// DeadLetterFile is appended a JSON line per delivery that failed for good, none leaves them to the log
+	DeadLetterFile string
+}
+
+// Redacted returns a copy of the webhook configuration with the secret and the credentials of the URLs masked
+func (c WebhookConfig) Redacted() WebhookConfig {
+	c.Secret = config.Redact(c.Secret)
+	urls := make([]string, len(c.URLs))
+	for i, raw := range c.URLs {
+		urls[i] = RedactURL(raw)
+	}
+	c.URLs = urls
+	return c
+}
+
+// RedactURL masks the password of a webhook URL, which may carry the receiver's credentials
+func RedactURL(raw string) string {
+	if u, err := url.Parse(raw); err == nil {
+		return u.Redacted()
+	}
+	return raw
 }
 
 // Load loads the configuration for the notifications service
 func Load() *Config {
 	return &Config{
 		Service:   config.NewServiceConfig("notifications"),
+		Admin:     config.NewAdminConfig(),
 		HTTP:      config.NewHTTPServerConfig(":8081"),
 		WebSocket: config.NewWebSocketConfig(),
 		Metrics:   config.NewMetricsConfig("9092"),
 		Tracing:   config.NewTracingConfig("notifications"),
 		NATS:      config.NewNATSConfig(),
+		DynamoDB:  config.NewDynamoDBConfig(),
+		Presence: PresenceConfig{
+			Interval: config.GetDurationEnv("PRESENCE_INTERVAL", 15*time.Second),
+		},
+		Contract: ContractConfig{
+			ValidateMessages: config.GetBoolEnv("WS_VALIDATE_MESSAGES", false),
+		},
+		Terminal: TerminalEventsConfig{
+			TTL:     config.GetDurationEnv("WS_TERMINAL_EVENT_TTL", time.Hour),
+			MaxJobs: config.GetIntEnv("WS_TERMINAL_EVENT_MAX_JOBS", 10000),
+		},
+		History: BroadcastHistoryConfig{
+			Size: config.GetIntEnv("WS_BROADCAST_HISTORY_SIZE", 200),
+		},
+		// Twice the messages and four times the bytes NATS buffers by default, bursts of subtask updates are large
+		Pending: PendingLimitsConfig{
+			Messages: config.GetIntEnv("NATS_PENDING_MSGS_LIMIT", 1024*1024),
+			Bytes:    config.GetIntEnv("NATS_PENDING_BYTES_LIMIT", 256*1024*1024),
+		},
+		Webhook: WebhookConfig{
+			URLs:           config.GetStringSliceEnv("WEBHOOK_URLS", nil),
+			Events:         config.GetStringSliceEnv("WEBHOOK_EVENTS", []string{WebhookEventJobFinal}),
+			Secret:         config.GetEnv("WEBHOOK_SECRET", ""),
+			Timeout:        config.GetDurationEnv("WEBHOOK_TIMEOUT", 10*time.Second),
+			MaxAttempts:    config.GetIntEnv("WEBHOOK_MAX_ATTEMPTS", 5),
+			RetryBackoff:   config.GetDurationEnv("WEBHOOK_RETRY_BACKOFF", time.Second),
+			Workers:        config.GetIntEnv("WEBHOOK_WORKERS", 8),
+			QueueSize:      config.GetIntEnv("WEBHOOK_QUEUE_SIZE", 1000),
+			DeadLetterFile: config.GetEnv("WEBHOOK_DEAD_LETTER_FILE", ""),
+		},
+	}
+}
+
+// Redacted returns a copy of the configuration with secrets masked, safe to expose to operators
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.Admin = c.Admin.Redacted()
+	redacted.DynamoDB = c.DynamoDB.Redacted()
+	redacted.NATS = c.NATS.Redacted()
+	redacted.Webhook = c.Webhook.Redacted()
+	return &redacted
+}
+
+// Validate checks the configuration, returning a *config.ValidationError listing every problem found
+func (c *Config) Validate() error {
+	v := config.NewValidator()
+	c.Service.Check(v)
+	c.HTTP.Check(v)
+	c.WebSocket.Check(v)
+	c.Metrics.Check(v)
+	c.Tracing.Check(v)
+	c.DynamoDB.Check(v)
+	c.NATS.Check(v)
+
+	v.Duration("PRESENCE_INTERVAL", c.Presence.Interval)
+	v.OptionalDuration("WS_TERMINAL_EVENT_TTL", c.Terminal.TTL)
+	if c.Terminal.TTL > 0 {
+		v.Check(c.Terminal.MaxJobs > 0, "WS_TERMINAL_EVENT_MAX_JOBS must be positive while final updates are kept, got %d", c.Terminal.MaxJobs)
 	}
+	v.Check(c.History.Size >= 0, "WS_BROADCAST_HISTORY_SIZE must not be negative, got %d", c.History.Size)
+	v.Check(c.Pending.Messages > 0, "NATS_PENDING_MSGS_LIMIT must be positive, got %d", c.Pending.Messages)
+	v.Check(c.Pending.Bytes > 0, "NATS_PENDING_BYTES_LIMIT must be positive, got %d", c.Pending.Bytes)
+
+	if len(c.Webhook.URLs) > 0 {
+		for _, raw := range c.Webhook.URLs {
+			v.URL("WEBHOOK_URLS", raw, "http", "https")
+		}
+		v.Check(len(c.Webhook.Events) > 0, "WEBHOOK_EVENTS must not be empty while WEBHOOK_URLS is set")
+		for _, event := range c.Webhook.Events {
+			v.Check(slices.Contains(WebhookEvents, event), "WEBHOOK_EVENTS must only list %s, got %q", strings.Join(WebhookEvents, ", "), event)
+		}
+		v.Check(c.Webhook.Secret != "", "WEBHOOK_SECRET must be set while WEBHOOK_URLS is, receivers cannot check unsigned deliveries")
+		v.Duration("WEBHOOK_TIMEOUT", c.Webhook.Timeout)
+		v.Check(c.Webhook.MaxAttempts > 0, "WEBHOOK_MAX_ATTEMPTS must be positive, got %d", c.Webhook.MaxAttempts)
+		v.Duration("WEBHOOK_RETRY_BACKOFF", c.Webhook.RetryBackoff)
+		v.Check(c.Webhook.Workers > 0, "WEBHOOK_WORKERS must be positive, got %d", c.Webhook.Workers)
+		v.Check(c.Webhook.QueueSize > 0, "WEBHOOK_QUEUE_SIZE must be positive, got %d", c.Webhook.QueueSize)
+	}
+
+	return v.Err()
 }
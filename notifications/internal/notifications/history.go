@@ -0,0 +1,150 @@
+package notifications
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// BroadcastRecord describes a message the hub broadcast, without its payload.
+// Group is empty for the messages broadcast to every client.
+type BroadcastRecord struct {
+	Time         time.Time `json:"time"`
+	Group        string    `json:"group"`
+	MessageType  string    `json:"message_type"`
+	PayloadBytes int       `json:"payload_bytes"`
+	// Total is the number of connections the message was written to, Delivered and Failed how those writes went
+	Total     int `json:"total"`
+	Delivered int `json:"delivered"`
+	Failed    int `json:"failed"`
+}
+
+// broadcastHistory keeps the last broadcasts of the hub in a ring, for answering "I never got the update for job X"
+type broadcastHistory struct {
+	mu      sync.Mutex
+	records []BroadcastRecord
+	// next is where the following record goes, the oldest record once the ring is full
+	next int
+}
+
+func newBroadcastHistory(size int) *broadcastHistory {
+	return &broadcastHistory{records: make([]BroadcastRecord, 0, size)}
+}
+
+// add records a broadcast, replacing the oldest one once the ring is full
+func (b *broadcastHistory) add(record BroadcastRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.records) < cap(b.records) {
+		b.records = append(b.records, record)
+		b.next = len(b.records) % cap(b.records)
+		return
+	}
+	b.records[b.next] = record
+	b.next = (b.next + 1) % len(b.records)
+}
+
+// list returns the recorded broadcasts newest first, only those to group unless it is empty
+func (b *broadcastHistory) list(group string) []BroadcastRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(b.records)
+	records := make([]BroadcastRecord, 0, n)
+	for i := range n {
+		record := b.records[((b.next-1-i)%n+n)%n]
+		if group == "" || record.Group == group {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// WithHubBroadcastHistory keeps the last size broadcasts of the hub for GET /debug/broadcasts. Zero keeps none.
+func WithHubBroadcastHistory(size int) HubOption {
+	return func(h *Hub) {
+		if size > 0 {
+			h.history = newBroadcastHistory(size)
+		}
+	}
+}
+
+// RecentBroadcasts returns the kept broadcasts newest first, only those to group unless it is empty
+func (h *Hub) RecentBroadcasts(group string) []BroadcastRecord {
+	if h.history == nil {
+		return []BroadcastRecord{}
+	}
+	return h.history.list(group)
+}
+
+// recordBroadcast keeps a broadcast in the history, its message type read from data when msg does not tell
+func (h *Hub) recordBroadcast(record BroadcastRecord, data []byte) {
+	if h.history == nil {
+		return
+	}
+
+	if record.MessageType == "unknown" {
+		var typed struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(data, &typed) == nil && typed.Type != "" {
+			record.MessageType = typed.Type
+		}
+	}
+	h.history.add(record)
+}
+
+// ConnectionInfo describes a connection of the hub for debugging
+type ConnectionInfo struct {
+	ID          string            `json:"id"`
+	ConnectedAt time.Time         `json:"connected_at"`
+	Groups      []ConnectionGroup `json:"groups"`
+	// LastBroadcastAt is when the connection was last written a message broadcast to every client
+	LastBroadcastAt *time.Time `json:"last_broadcast_at,omitempty"`
+}
+
+// ConnectionGroup is a group a connection is subscribed to, LastDeliveryAt is unset until it got a message of it
+type ConnectionGroup struct {
+	Group          string     `json:"group"`
+	LastDeliveryAt *time.Time `json:"last_delivery_at,omitempty"`
+}
+
+// DescribeConnection returns the description of the connection with the given ID, false when the hub has none
+func (h *Hub) DescribeConnection(id string) (ConnectionInfo, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for conn := range h.connections {
+		if conn.id == id {
+			return conn.info(), true
+		}
+	}
+	return ConnectionInfo{}, false
+}
+
+// recordDelivery notes that a message of group, empty for the broadcasts to every client, was written at t
+func (c *Connection) recordDelivery(group string, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastDelivery[group] = t
+}
+
+// info describes the connection, its groups in the order they were subscribed to
+func (c *Connection) info() ConnectionInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	info := ConnectionInfo{ID: c.id, ConnectedAt: c.start, Groups: make([]ConnectionGroup, 0, len(c.groups))}
+	for _, group := range c.groups {
+		entry := ConnectionGroup{Group: group}
+		if t, ok := c.lastDelivery[group]; ok {
+			entry.LastDeliveryAt = &t
+		}
+		info.Groups = append(info.Groups, entry)
+	}
+	if t, ok := c.lastDelivery[""]; ok {
+		info.LastBroadcastAt = &t
+	}
+	return info
+}
@@ -0,0 +1,185 @@
+package notifications
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"shared/messagebus"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroadcastHistory_Wraps(t *testing.T) {
+	history := newBroadcastHistory(3)
+	assert.Empty(t, history.list(""))
+
+	groups := func(records []BroadcastRecord) []string {
+		names := make([]string, len(records))
+		for i, record := range records {
+			names[i] = record.Group
+		}
+		return names
+	}
+
+	for i := 1; i <= 3; i++ {
+		history.add(BroadcastRecord{Group: "job-" + strconv.Itoa(i)})
+	}
+	assert.Equal(t, []string{"job-3", "job-2", "job-1"}, groups(history.list("")), "a full ring should list newest first")
+
+	history.add(BroadcastRecord{Group: "job-4"})
+	assert.Equal(t, []string{"job-4", "job-3", "job-2"}, groups(history.list("")), "the oldest record should be replaced")
+
+	// Wrapping past the end of the ring more than once keeps the order
+	for i := 5; i <= 9; i++ {
+		history.add(BroadcastRecord{Group: "job-" + strconv.Itoa(i)})
+	}
+	assert.Equal(t, []string{"job-9", "job-8", "job-7"}, groups(history.list("")))
+	assert.Equal(t, []string{"job-8"}, groups(history.list("job-8")))
+	assert.Empty(t, history.list("job-1"))
+}
+
+func TestHub_RecordsBroadcasts(t *testing.T) {
+	hub := NewHub(WithHubLogger(slog.New(slog.DiscardHandler)), WithHubBroadcastHistory(10))
+	wsServer := setupWs(hub)
+	defer wsServer.Close()
+
+	subscriber := dialHub(t, wsServer)
+	require.NoError(t, subscriber.WriteJSON(SubscriptionMessage{Action: "subscribe", Group: "job-1"}))
+	hello(t, subscriber)
+
+	update := messagebus.TaskStatusUpdateMessage{Type: messagebus.TaskStatusUpdateMessageType, JobID: "job-1", TaskType: "extracting", Status: "running"}
+	hub.BroadcastToGroup(update, "job-1")
+	hub.BroadcastToGroup(update, "job-2")
+
+	records := hub.RecentBroadcasts("")
+	require.Len(t, records, 2)
+	data, err := json.Marshal(update)
+	require.NoError(t, err)
+	assert.Equal(t, "job-2", records[0].Group)
+	assert.Equal(t, 0, records[0].Total, "a broadcast nobody is subscribed to should still be recorded")
+	assert.Equal(t, BroadcastRecord{
+		Time:         records[1].Time,
+		Group:        "job-1",
+		MessageType:  string(messagebus.TaskStatusUpdateMessageType),
+		PayloadBytes: len(data),
+		Total:        1,
+		Delivered:    1,
+	}, records[1])
+
+	assert.Empty(t, NewHub().RecentBroadcasts(""), "a hub without history should keep nothing")
+}
+
+func TestServer_DebugBroadcasts(t *testing.T) {
+	hub := NewHub(WithHubLogger(slog.New(slog.DiscardHandler)), WithHubBroadcastHistory(10))
+	srv := NewServer(NewNotificationService(hub, nil), WithAdminToken("secret"), WithServerLogger(slog.New(slog.DiscardHandler)))
+	router := srv.newRouter().Serve()
+
+	hub.BroadcastToGroup(map[string]any{"type": "task.status_update"}, "job-1")
+	hub.BroadcastToGroup(map[string]any{"type": "task.status_update"}, "job-2")
+	hub.Broadcast(map[string]any{"type": "job.update"})
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get("/debug/broadcasts", "").Code)
+
+	testCases := []struct {
+		name           string
+		path           string
+		expectedGroups []string
+	}{
+		{name: "All", path: "/debug/broadcasts", expectedGroups: []string{"", "job-2", "job-1"}},
+		{name: "Group", path: "/debug/broadcasts?group=job-1", expectedGroups: []string{"job-1"}},
+		{name: "UnknownGroup", path: "/debug/broadcasts?group=job-3", expectedGroups: []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := get(tc.path, "secret")
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			var records []BroadcastRecord
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &records))
+			groups := make([]string, 0, len(records))
+			for _, record := range records {
+				groups = append(groups, record.Group)
+			}
+			assert.Equal(t, tc.expectedGroups, groups)
+		})
+	}
+}
+
+func TestServer_DebugConnection(t *testing.T) {
+	hub := NewHub(WithHubLogger(slog.New(slog.DiscardHandler)))
+	wsServer := setupWs(hub)
+	defer wsServer.Close()
+	srv := NewServer(NewNotificationService(hub, nil), WithAdminToken("secret"), WithServerLogger(slog.New(slog.DiscardHandler)))
+	router := srv.newRouter().Serve()
+
+	conn := dialHub(t, wsServer)
+	require.NoError(t, conn.WriteJSON(SubscriptionMessage{Action: "subscribe", Group: "job-1"}))
+	require.NoError(t, conn.WriteJSON(SubscriptionMessage{Action: "subscribe", Group: "job-2"}))
+	ack := hello(t, conn)
+	require.NotEmpty(t, ack.ConnectionID)
+
+	hub.BroadcastToGroup(map[string]any{"type": "task.status_update"}, "job-1")
+	var received map[string]any
+	require.NoError(t, conn.ReadJSON(&received))
+
+	get := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/debug/connections/"+id, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get(ack.ConnectionID)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var info ConnectionInfo
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &info))
+	assert.Equal(t, ack.ConnectionID, info.ID)
+	require.Len(t, info.Groups, 2)
+	assert.Equal(t, "job-1", info.Groups[0].Group)
+	assert.NotNil(t, info.Groups[0].LastDeliveryAt)
+	assert.Equal(t, "job-2", info.Groups[1].Group)
+	assert.Nil(t, info.Groups[1].LastDeliveryAt, "a group without broadcasts should have no delivery")
+	assert.Nil(t, info.LastBroadcastAt)
+
+	assert.Equal(t, http.StatusNotFound, get("01HZZZZZZZZZZZZZZZZZZZZZZZ").Code)
+}
+
+// dialHub connects a client to the hub served by wsServer
+func dialHub(t *testing.T, wsServer *httptest.Server) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(wsServer.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	return conn
+}
+
+// hello sends a hello and returns its ack. Messages are handled in order, so the requests sent before are done.
+func hello(t *testing.T, conn *websocket.Conn) HelloAckMessage {
+	t.Helper()
+
+	require.NoError(t, conn.WriteJSON(SubscriptionMessage{Action: "hello"}))
+	var ack HelloAckMessage
+	require.NoError(t, conn.ReadJSON(&ack))
+	return ack
+}
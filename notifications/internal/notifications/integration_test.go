@@ -6,6 +6,9 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"notifications/internal/config"
+	sharedconfig "shared/config"
+	"shared/contract"
 	"shared/messagebus"
 	"shared/models"
 	"strconv"
@@ -37,10 +40,10 @@ func setupWs(hub *Hub) *httptest.Server {
 	return wsServer
 }
 
-func setupIntegration(t *testing.T) (*messagebus.MessageBus, string, func()) {
+func setupIntegration(t *testing.T, opts ...HubOption) (*messagebus.MessageBus, string, func()) {
 	nc, server := setupNats(t, 8400)
 
-	hub := NewHub(WithHubLogger(slog.New(slog.DiscardHandler)))
+	hub := NewHub(append([]HubOption{WithHubLogger(slog.New(slog.DiscardHandler))}, opts...)...)
 	wsServer := setupWs(hub)
 	mb := messagebus.New(nc, nil)
 
@@ -265,7 +268,7 @@ func TestNotificationService_ConcurrentClients_Integration(t *testing.T) {
 	groupMsg := messagebus.TaskStatusUpdateMessage{
 		Type:     messagebus.TaskStatusUpdateMessageType,
 		JobID:    "concurrent-test-job",
-		TaskType: "processing",
+		TaskType: "identifying_version",
 		Status:   "running",
 	}
 
@@ -341,7 +344,7 @@ func TestNotificationService_SubTaskUpdate_Integration(t *testing.T) {
 	subTaskMsg := messagebus.SubTaskUpdateMessage{
 		Type:     messagebus.SubTaskUpdateMessageType,
 		JobID:    "subtask-job-789",
-		TaskType: "link_validation",
+		TaskType: "verifying_links",
 		Key:      "link-integration-test",
 		SubTask: models.SubTask{
 			Type:        models.SubTaskTypeValidatingLink,
@@ -405,7 +408,7 @@ func TestNotificationService_SubscriptionLifecycle_Integration(t *testing.T) {
 	taskMsg1 := messagebus.TaskStatusUpdateMessage{
 		Type:     messagebus.TaskStatusUpdateMessageType,
 		JobID:    "lifecycle-job-456",
-		TaskType: "phase1_test",
+		TaskType: "extracting",
 		Status:   "running",
 	}
 
@@ -441,7 +444,7 @@ func TestNotificationService_SubscriptionLifecycle_Integration(t *testing.T) {
 	taskMsg2 := messagebus.TaskStatusUpdateMessage{
 		Type:     messagebus.TaskStatusUpdateMessageType,
 		JobID:    "lifecycle-job-456",
-		TaskType: "phase2_test",
+		TaskType: "identifying_version",
 		Status:   "completed",
 	}
 
@@ -468,7 +471,7 @@ func TestNotificationService_SubscriptionLifecycle_Integration(t *testing.T) {
 	taskMsg3 := messagebus.TaskStatusUpdateMessage{
 		Type:     messagebus.TaskStatusUpdateMessageType,
 		JobID:    "lifecycle-job-456",
-		TaskType: "phase3_test",
+		TaskType: "analyzing",
 		Status:   "restarted",
 	}
 
@@ -518,7 +521,7 @@ func TestNotificationService_UnsubscribeGroup_Integration(t *testing.T) {
 	taskMsg := messagebus.TaskStatusUpdateMessage{
 		Type:     messagebus.TaskStatusUpdateMessageType,
 		JobID:    "unsubscribe-test-job",
-		TaskType: "test_task",
+		TaskType: "extracting",
 		Status:   "running",
 	}
 
@@ -553,7 +556,7 @@ func TestNotificationService_UnsubscribeGroup_Integration(t *testing.T) {
 	taskMsg2 := messagebus.TaskStatusUpdateMessage{
 		Type:     messagebus.TaskStatusUpdateMessageType,
 		JobID:    "unsubscribe-test-job",
-		TaskType: "test_task_2",
+		TaskType: "analyzing",
 		Status:   "completed",
 	}
 
@@ -567,3 +570,364 @@ func TestNotificationService_UnsubscribeGroup_Integration(t *testing.T) {
 	_, _, err = client.ReadMessage()
 	assert.Error(t, err, "Client should not receive message after unsubscribing")
 }
+
+// replica is a notifications service with its own hub, websocket endpoint and NATS connection
+type replica struct {
+	svc   *NotificationService
+	wsURL string
+}
+
+func startReplica(t *testing.T, natsURL, instanceID string) *replica {
+	nc, err := nats.Connect(natsURL)
+	require.NoError(t, err, "Should connect to NATS")
+
+	log := slog.New(slog.DiscardHandler)
+	hub := NewHub(WithHubLogger(log), WithHubInstanceID(instanceID))
+	wsServer := setupWs(hub)
+
+	cfg := &config.Config{
+		Service:  sharedconfig.ServiceConfig{InstanceID: instanceID},
+		Presence: config.PresenceConfig{Interval: 50 * time.Millisecond},
+	}
+	svc := NewNotificationService(hub, messagebus.New(nc, nil), WithLogger(log), WithConfig(cfg))
+	require.NoError(t, svc.Start(context.Background()))
+
+	t.Cleanup(func() {
+		svc.Stop()
+		nc.Close()
+		wsServer.Close()
+	})
+
+	return &replica{svc: svc, wsURL: "ws" + strings.TrimPrefix(wsServer.URL, "http")}
+}
+
+// dial connects a client to the replica and waits until the hub has registered it
+func (r *replica) dial(t *testing.T) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(r.wsURL, nil)
+	require.NoError(t, err, "Should connect WebSocket client")
+	t.Cleanup(func() { conn.Close() })
+
+	// The hello is answered by the read loop, which starts once the connection is in the hub
+	require.NoError(t, conn.WriteJSON(SubscriptionMessage{Action: "hello"}))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var ack HelloAckMessage
+	require.NoError(t, conn.ReadJSON(&ack))
+	return conn
+}
+
+func TestNotificationService_HelloAck_Integration(t *testing.T) {
+	_, server := setupNats(t, 8401)
+	defer server.Shutdown()
+
+	r := startReplica(t, "nats://127.0.0.1:8401", "replica-a")
+
+	conn, _, err := websocket.DefaultDialer.Dial(r.wsURL, nil)
+	require.NoError(t, err, "Should connect WebSocket client")
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteJSON(SubscriptionMessage{Action: "hello"}))
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var ack HelloAckMessage
+	require.NoError(t, conn.ReadJSON(&ack), "Should receive hello ack")
+	assert.Equal(t, HelloAckMessageType, ack.Type)
+	assert.Equal(t, "replica-a", ack.InstanceID)
+	assert.NotEmpty(t, ack.ConnectionID)
+}
+
+func TestNotificationService_ClusterStatus_Integration(t *testing.T) {
+	_, server := setupNats(t, 8402)
+	defer server.Shutdown()
+
+	a := startReplica(t, "nats://127.0.0.1:8402", "replica-a")
+	b := startReplica(t, "nats://127.0.0.1:8402", "replica-b")
+
+	a.dial(t)
+	a.dial(t)
+	subscriber := b.dial(t)
+	require.NoError(t, subscriber.WriteJSON(SubscriptionMessage{Action: "subscribe", Group: "cluster-job"}))
+
+	// Both replicas converge on the same view once a heartbeat with the new counts has gone out
+	expected := []InstanceStatus{
+		{InstanceID: "replica-a", Connections: 2, Groups: 0},
+		{InstanceID: "replica-b", Connections: 1, Groups: 1},
+	}
+	for _, r := range []*replica{a, b} {
+		assert.EventuallyWithT(t, func(c *assert.CollectT) {
+			status := r.svc.ClusterStatus()
+			assert.Equal(c, 3, status.TotalConnections)
+
+			counts := make([]InstanceStatus, 0, len(status.Instances))
+			for _, instance := range status.Instances {
+				assert.False(c, instance.Stale)
+				counts = append(counts, InstanceStatus{
+					InstanceID:  instance.InstanceID,
+					Connections: instance.Connections,
+					Groups:      instance.Groups,
+				})
+			}
+			assert.Equal(c, expected, counts)
+		}, 2*time.Second, 25*time.Millisecond)
+	}
+
+	assert.Equal(t, "replica-b", b.svc.ClusterStatus().InstanceID)
+}
+
+func TestNotificationService_DropsUnknownTaskType_Integration(t *testing.T) {
+	mb, wsURL, shutdown := setupIntegration(t)
+	defer shutdown()
+
+	time.Sleep(200 * time.Millisecond)
+
+	client, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err, "Should connect WebSocket client")
+	defer client.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, client.WriteJSON(SubscriptionMessage{Action: "subscribe", Group: "unknown-type-job"}))
+	time.Sleep(200 * time.Millisecond)
+
+	// Messages are delivered in order, so the first one received shows whether the unknown types were dropped
+	require.NoError(t, mb.PublishTaskStatusUpdate(context.Background(), messagebus.TaskStatusUpdateMessage{
+		JobID:    "unknown-type-job",
+		TaskType: "verifying_lnks",
+		Status:   "running",
+	}))
+	require.NoError(t, mb.PublishSubTaskUpdate(context.Background(), messagebus.SubTaskUpdateMessage{
+		JobID:    "unknown-type-job",
+		TaskType: "screenshot",
+		Key:      "1",
+		SubTask:  models.SubTask{Type: models.SubTaskTypeValidatingLink, Status: models.TaskStatusRunning},
+	}))
+	require.NoError(t, mb.PublishTaskStatusUpdate(context.Background(), messagebus.TaskStatusUpdateMessage{
+		JobID:    "unknown-type-job",
+		TaskType: "verifying_links",
+		Status:   "running",
+	}))
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var received messagebus.TaskStatusUpdateMessage
+	require.NoError(t, client.ReadJSON(&received), "Client should receive the valid task update")
+	assert.Equal(t, messagebus.TaskStatusUpdateMessageType, received.Type)
+	assert.Equal(t, "verifying_links", received.TaskType)
+
+	client.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	_, _, err = client.ReadMessage()
+	assert.Error(t, err, "Client should receive nothing else")
+}
+
+func TestNotificationService_AnalyzerLoad_Integration(t *testing.T) {
+	mb, wsURL, shutdown := setupIntegration(t)
+	defer shutdown()
+
+	time.Sleep(200 * time.Millisecond)
+
+	subscribed, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err, "Should connect subscribed client")
+	defer subscribed.Close()
+
+	other, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err, "Should connect other client")
+	defer other.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	msgData, err := json.Marshal(SubscriptionMessage{Action: "subscribe", Group: SystemGroup})
+	require.NoError(t, err, "Should marshal subscription message")
+	require.NoError(t, subscribed.WriteMessage(websocket.TextMessage, msgData), "Should subscribe to the system group")
+
+	time.Sleep(100 * time.Millisecond)
+
+	loadMsg := messagebus.AnalyzerLoadMessage{
+		InstanceID:         "analyzer-1",
+		QueueDepth:         7,
+		InFlight:           1,
+		AvgWaitSeconds:     20,
+		AvgDurationSeconds: 9.5,
+		SentAt:             time.Now().UTC(),
+	}
+	require.NoError(t, mb.PublishAnalyzerLoad(context.Background(), loadMsg), "Should publish analyzer load")
+
+	subscribed.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, data, err := subscribed.ReadMessage()
+	require.NoError(t, err, "System group subscriber should receive the analyzer load")
+
+	var received messagebus.AnalyzerLoadMessage
+	require.NoError(t, json.Unmarshal(data, &received), "Should unmarshal analyzer load")
+	assert.Equal(t, messagebus.AnalyzerLoadMessageType, received.Type)
+	assert.Equal(t, loadMsg.InstanceID, received.InstanceID)
+	assert.Equal(t, loadMsg.QueueDepth, received.QueueDepth)
+	assert.Equal(t, loadMsg.AvgDurationSeconds, received.AvgDurationSeconds)
+
+	// The load is not broadcast to every client
+	other.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+	_, _, err = other.ReadMessage()
+	assert.Error(t, err, "Client outside the system group should not receive the analyzer load")
+}
+
+func TestNotificationService_TerminalEventReplay_Integration(t *testing.T) {
+	mb, wsURL, shutdown := setupIntegration(t, WithHubTerminalEvents(time.Hour, 100))
+	defer shutdown()
+
+	time.Sleep(200 * time.Millisecond)
+
+	// The job finishes while no client is connected
+	completed := messagebus.JobUpdateMessage{
+		Type:          messagebus.JobUpdateMessageType,
+		JobID:         "finished-job",
+		Status:        string(models.JobStatusFailed),
+		FailureCode:   models.FailureCodeFetch,
+		FailureReason: "The page could not be fetched: 503 Service Unavailable",
+	}
+	require.NoError(t, mb.PublishJobUpdate(context.Background(), completed), "Should publish the final update")
+	require.NoError(t, mb.PublishJobUpdate(context.Background(), messagebus.JobUpdateMessage{
+		Type:   messagebus.JobUpdateMessageType,
+		JobID:  "running-job",
+		Status: string(models.JobStatusRunning),
+	}), "Should publish the running update")
+	require.NoError(t, mb.PublishJobUpdate(context.Background(), messagebus.JobUpdateMessage{
+		Type:   messagebus.JobUpdateMessageType,
+		JobID:  "linked-job",
+		Status: string(models.JobStatusCompleted),
+		Result: &models.AnalyzeResult{
+			Links:             []string{"https://example.com/a", "https://example.com/b"},
+			InternalLinkCount: 2,
+		},
+	}), "Should publish the completed update")
+
+	time.Sleep(200 * time.Millisecond)
+
+	client, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err, "Should connect WebSocket client")
+	defer client.Close()
+
+	require.NoError(t, client.WriteJSON(SubscriptionMessage{Action: "subscribe", Group: "finished-job"}))
+	client.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var received messagebus.JobUpdateMessage
+	require.NoError(t, client.ReadJSON(&received), "Late subscriber should receive the final update")
+	assert.Equal(t, completed, received)
+
+	// The link lists are left out of a kept update, the counts stay
+	require.NoError(t, client.WriteJSON(SubscriptionMessage{Action: "subscribe", Group: "linked-job"}))
+	client.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var linked messagebus.JobUpdateMessage
+	require.NoError(t, client.ReadJSON(&linked), "Late subscriber should receive the final update")
+	require.NotNil(t, linked.Result)
+	assert.Empty(t, linked.Result.Links)
+	assert.True(t, linked.Result.LinksOmitted)
+	assert.Equal(t, 2, linked.Result.InternalLinkCount)
+
+	// Only final updates are kept
+	require.NoError(t, client.WriteJSON(SubscriptionMessage{Action: "subscribe", Group: "running-job"}))
+	client.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	_, _, err = client.ReadMessage()
+	assert.Error(t, err, "A running job should have nothing to replay")
+}
+
+func TestNotificationService_JobLifecycle_Integration(t *testing.T) {
+	mb, wsURL, shutdown := setupIntegration(t)
+	defer shutdown()
+
+	time.Sleep(200 * time.Millisecond)
+
+	lifecycle, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err, "Should connect lifecycle client")
+	defer lifecycle.Close()
+
+	full, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err, "Should connect full client")
+	defer full.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, lifecycle.WriteJSON(SubscriptionMessage{Action: "subscribe", Group: LifecycleGroup}))
+	time.Sleep(100 * time.Millisecond)
+
+	progress := 100.0
+	update := messagebus.JobUpdateMessage{
+		Type:     messagebus.JobUpdateMessageType,
+		JobID:    "lifecycle-job",
+		Status:   string(models.JobStatusCompleted),
+		URL:      "https://example.com",
+		Progress: &progress,
+		Result: &models.AnalyzeResult{
+			PageTitle: "Lifecycle Test Page",
+			Links:     []string{"https://example.com/a"},
+		},
+	}
+	published := time.Now()
+	require.NoError(t, mb.PublishJobUpdate(context.Background(), update), "Should publish job update")
+
+	// The full client gets the update as published, result included
+	full.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var received messagebus.JobUpdateMessage
+	require.NoError(t, full.ReadJSON(&received), "Full client should receive the job update")
+	assert.Equal(t, update, received)
+
+	lifecycle.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, data, err := lifecycle.ReadMessage()
+	require.NoError(t, err, "Lifecycle client should receive the lifecycle message")
+	assert.NoError(t, contract.Validate(data), "Lifecycle message should match its schema")
+
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(data, &fields))
+	assert.NotContains(t, fields, "result")
+
+	var slim JobLifecycleMessage
+	require.NoError(t, json.Unmarshal(data, &slim))
+	assert.Equal(t, JobLifecycleMessageType, slim.Type)
+	assert.Equal(t, update.JobID, slim.JobID)
+	assert.Equal(t, update.Status, slim.Status)
+	assert.Equal(t, update.URL, slim.URL)
+	assert.Equal(t, &progress, slim.Progress)
+	assert.WithinDuration(t, published, slim.Timestamp, time.Second)
+
+	// The lifecycle client is not sent the full update on top
+	lifecycle.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	_, _, err = lifecycle.ReadMessage()
+	assert.Error(t, err, "Lifecycle client should only receive the lifecycle message")
+}
+
+func TestNotificationService_JobLifecycle_SubscribedJob_Integration(t *testing.T) {
+	mb, wsURL, shutdown := setupIntegration(t)
+	defer shutdown()
+
+	time.Sleep(200 * time.Millisecond)
+
+	client, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err, "Should connect client")
+	defer client.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, client.WriteJSON(SubscriptionMessage{Action: "subscribe", Group: LifecycleGroup}))
+	require.NoError(t, client.WriteJSON(SubscriptionMessage{Action: "subscribe", Group: "watched-job"}))
+	time.Sleep(100 * time.Millisecond)
+
+	for _, jobID := range []string{"other-job", "watched-job"} {
+		require.NoError(t, mb.PublishJobUpdate(context.Background(), messagebus.JobUpdateMessage{
+			Type:   messagebus.JobUpdateMessageType,
+			JobID:  jobID,
+			Status: string(models.JobStatusRunning),
+		}), "Should publish job update")
+	}
+
+	// Both lifecycle messages arrive, the full update only for the job the client subscribed to
+	types := make(map[string][]string)
+	for range 3 {
+		client.SetReadDeadline(time.Now().Add(3 * time.Second))
+		var received struct {
+			Type  string `json:"type"`
+			JobID string `json:"job_id"`
+		}
+		require.NoError(t, client.ReadJSON(&received), "Client should receive the update")
+		types[received.JobID] = append(types[received.JobID], received.Type)
+	}
+	assert.ElementsMatch(t, []string{JobLifecycleMessageType}, types["other-job"])
+	assert.ElementsMatch(t, []string{JobLifecycleMessageType, string(messagebus.JobUpdateMessageType)}, types["watched-job"])
+
+	client.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	_, _, err = client.ReadMessage()
+	assert.Error(t, err, "The full update of the other job should not be sent")
+}
@@ -0,0 +1,53 @@
+package notifications
+
+import (
+	"shared/messagebus"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// LifecycleGroup is the group of the clients following the status of every job without its results, such as list
+// views. Its subscribers get a JobLifecycleMessage for each job update instead of the full job.update broadcast.
+const LifecycleGroup = "jobs.lifecycle"
+
+// JobLifecycleMessageType is the type of the messages sent to the LifecycleGroup
+const JobLifecycleMessageType = "job.lifecycle"
+
+// JobLifecycleMessage is a job update stripped down to what a job list renders
+type JobLifecycleMessage struct {
+	Type     string   `json:"type"`
+	JobID    string   `json:"job_id"`
+	Status   string   `json:"status"`
+	URL      string   `json:"url,omitempty"`
+	Progress *float64 `json:"progress,omitempty"`
+	// Timestamp is when the update was published, or received when the publisher did not say
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// newJobLifecycleMessage derives the lifecycle message of a job update.
+// The update is broadcast to the other clients as it is, so nothing it points to is shared.
+func newJobLifecycleMessage(m messagebus.JobUpdateMessage, timestamp time.Time) JobLifecycleMessage {
+	lifecycle := JobLifecycleMessage{
+		Type:      JobLifecycleMessageType,
+		JobID:     m.JobID,
+		Status:    m.Status,
+		URL:       m.URL,
+		Timestamp: timestamp.UTC(),
+	}
+	if m.Progress != nil {
+		progress := *m.Progress
+		lifecycle.Progress = &progress
+	}
+	return lifecycle
+}
+
+// publishedAt returns when a message was published, falling back to now without a valid timestamp header
+func publishedAt(msg *nats.Msg, now time.Time) time.Time {
+	if msg.Header != nil {
+		if t, err := time.Parse(time.RFC3339Nano, msg.Header.Get(messagebus.PublishedAtHeader)); err == nil {
+			return t
+		}
+	}
+	return now
+}
@@ -0,0 +1,87 @@
+package notifications
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/nats-io/nats.go"
+)
+
+// droppedReasonSlowConsumer labels the messages NATS dropped because a subscription fell behind
+const droppedReasonSlowConsumer = "slow_consumer"
+
+// The consumers of the messages, which the dropped ones are counted by
+const (
+	consumerWebSocket = "websocket"
+	consumerWebhook   = "webhook"
+)
+
+// applyPendingLimits raises how many messages each subscription buffers while its handler is busy broadcasting,
+// so a burst of updates is absorbed instead of NATS dropping them
+func (s *NotificationService) applyPendingLimits() {
+	if s.cfg == nil {
+		return
+	}
+
+	for _, sub := range s.subs {
+		if err := sub.SetPendingLimits(s.cfg.Pending.Messages, s.cfg.Pending.Bytes); err != nil {
+			s.log.Error("Failed to set pending limits",
+				slog.String("subject", sub.Subject),
+				slog.Any("error", err))
+		}
+	}
+}
+
+// HandleAsyncError handles the errors NATS reports outside of a call, set with nats.Conn.SetErrorHandler.
+// A slow consumer means NATS is dropping messages of a subscription, updates the clients will never see.
+// NATS reports it once each time a subscription falls behind, the messages dropped afterwards are counted
+// by recordDroppedMessages.
+func (s *NotificationService) HandleAsyncError(_ *nats.Conn, sub *nats.Subscription, err error) {
+	if sub == nil || !errors.Is(err, nats.ErrSlowConsumer) {
+		s.log.Error("NATS error", slog.Any("error", err))
+		return
+	}
+
+	pending, _, _ := sub.Pending()
+	s.log.Warn("NATS subscription is a slow consumer, its messages are being dropped",
+		slog.String("subject", sub.Subject),
+		slog.Int("pending", pending))
+	if s.metrics != nil {
+		s.metrics.RecordSlowConsumer(sub.Subject)
+	}
+	s.recordDroppedMessages(sub)
+}
+
+// recordDroppedMessages counts the messages NATS dropped for a subscription since they were last counted
+func (s *NotificationService) recordDroppedMessages(sub *nats.Subscription) {
+	dropped, err := sub.Dropped()
+	if err != nil {
+		// The subscription was closed, nothing more can be dropped
+		return
+	}
+
+	s.droppedMu.Lock()
+	count := dropped - s.dropped[sub]
+	s.dropped[sub] = dropped
+	s.droppedMu.Unlock()
+
+	if count <= 0 {
+		return
+	}
+	s.log.Warn("NATS dropped messages of a slow subscription",
+		slog.String("subject", sub.Subject),
+		slog.Int("dropped", count))
+	if s.metrics != nil {
+		// Subjects are named after the message type they carry
+		s.metrics.RecordDroppedMessages(subscriptionConsumer(sub), sub.Subject, droppedReasonSlowConsumer, count)
+	}
+}
+
+// subscriptionConsumer returns what a subscription feeds: the webhooks for those in the webhook queue group,
+// the WebSocket clients otherwise
+func subscriptionConsumer(sub *nats.Subscription) string {
+	if sub.Queue == webhookQueue {
+		return consumerWebhook
+	}
+	return consumerWebSocket
+}
@@ -0,0 +1,113 @@
+package notifications
+
+import (
+	"context"
+	"log/slog"
+	"notifications/internal/config"
+	"shared/messagebus"
+	"shared/metrics"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// counterValues gathers a counter, keyed by the value of one of its labels
+func counterValues(t *testing.T, counter *prometheus.CounterVec, label string) map[string]float64 {
+	t.Helper()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(counter)
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, l := range metric.GetLabel() {
+				if l.GetName() == label {
+					values[l.GetValue()] += metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return values
+}
+
+func TestNotificationService_PendingLimits_Integration(t *testing.T) {
+	nc, server := setupNats(t, 8435)
+	defer server.Shutdown()
+	defer nc.Close()
+
+	cfg := config.Load()
+	cfg.Pending = config.PendingLimitsConfig{Messages: 2048, Bytes: 4 * 1024 * 1024}
+	svc := NewNotificationService(
+		NewHub(WithHubLogger(slog.New(slog.DiscardHandler))),
+		messagebus.New(nc, nil),
+		WithLogger(slog.New(slog.DiscardHandler)),
+		WithConfig(cfg),
+	)
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop()
+
+	require.NotEmpty(t, svc.subs)
+	for _, sub := range svc.subs {
+		msgs, bytes, err := sub.PendingLimits()
+		require.NoError(t, err)
+		assert.Equal(t, 2048, msgs, sub.Subject)
+		assert.Equal(t, 4*1024*1024, bytes, sub.Subject)
+	}
+}
+
+func TestNotificationService_SlowConsumer_Integration(t *testing.T) {
+	nc, server := setupNats(t, 8436)
+	defer server.Shutdown()
+	defer nc.Close()
+
+	m := metrics.NewNotificationsMetrics("test")
+	svc := NewNotificationService(
+		NewHub(WithHubLogger(slog.New(slog.DiscardHandler))),
+		messagebus.New(nc, nil),
+		WithLogger(slog.New(slog.DiscardHandler)),
+		WithMetrics(m),
+	)
+	nc.SetErrorHandler(svc.HandleAsyncError)
+
+	// A handler stuck broadcasting leaves the messages to pile up past the limit
+	release := make(chan struct{})
+	sub, err := nc.Subscribe(string(messagebus.JobUpdateMessageType), func(*nats.Msg) { <-release })
+	require.NoError(t, err)
+	require.NoError(t, sub.SetPendingLimits(2, 1024*1024))
+	svc.subs = append(svc.subs, sub)
+
+	for range 10 {
+		require.NoError(t, nc.Publish(string(messagebus.JobUpdateMessageType), []byte(`{}`)))
+	}
+	require.NoError(t, nc.Flush())
+
+	require.Eventually(t, func() bool {
+		return counterValues(t, m.NATSSlowConsumerEventsTotal, "subject")["job.update"] == 1
+	}, 2*time.Second, 10*time.Millisecond, "the slow consumer should be reported")
+
+	close(release)
+	svc.recordDroppedMessages(sub)
+
+	dropped, err := sub.Dropped()
+	require.NoError(t, err)
+	assert.Positive(t, dropped)
+	assert.Equal(t, map[string]float64{"job.update": float64(dropped)},
+		counterValues(t, m.MessagesDroppedTotal, metrics.LabelMessageType), "every dropped message should be counted once")
+	assert.Equal(t, map[string]float64{consumerWebSocket: float64(dropped)}, counterValues(t, m.MessagesDroppedTotal, "consumer"))
+
+	// Counting again adds only the messages dropped since
+	svc.recordDroppedMessages(sub)
+	assert.Equal(t, float64(dropped), counterValues(t, m.MessagesDroppedTotal, metrics.LabelMessageType)["job.update"])
+}
+
+func TestSubscriptionConsumer(t *testing.T) {
+	assert.Equal(t, consumerWebSocket, subscriptionConsumer(&nats.Subscription{Subject: "job.update"}))
+	assert.Equal(t, consumerWebhook, subscriptionConsumer(&nats.Subscription{Subject: "job.update", Queue: webhookQueue}))
+}
@@ -2,6 +2,7 @@ package notifications
 
 import (
 	"context"
+	"encoding/json"
 	"log/slog"
 	"net"
 	"net/http"
@@ -19,6 +20,8 @@ type Server struct {
 	notificationSvc *NotificationService
 	log             *slog.Logger
 	cfg             *config.HTTPServerConfig
+	adminToken      string
+	debugConfig     any
 }
 
 // ServerOption configures the Server
@@ -46,6 +49,16 @@ func WithServerConfig(cfg *config.HTTPServerConfig) ServerOption {
 	return func(s *Server) { s.cfg = cfg }
 }
 
+// WithAdminToken sets the bearer token required by admin endpoints
+func WithAdminToken(token string) ServerOption {
+	return func(s *Server) { s.adminToken = token }
+}
+
+// WithDebugConfig exposes the given (redacted) configuration on /debug/config
+func WithDebugConfig(cfg any) ServerOption {
+	return func(s *Server) { s.debugConfig = cfg }
+}
+
 // WithServerLogger sets the logger for the server
 func WithServerLogger(log *slog.Logger) ServerOption {
 	return func(s *Server) { s.log = log }
@@ -58,16 +71,6 @@ func (s *Server) Start(ctx context.Context) error {
 		return err
 	}
 
-	// Setup router with middleware
-	router := shift.New()
-	router.Use(tracing.OtelMiddleware)
-	router.Use(middleware.CORSMiddleware)
-	router.Use(middleware.ErrorMiddleware(s.log))
-
-	// Register routes
-	router.OPTIONS("/*wildcard", middleware.OptionsHandler)
-	router.GET("/ws", s.handleWebSocket)
-
 	// Configure server
 	addr := ":8081"
 	if s.cfg != nil && s.cfg.Addr != "" {
@@ -76,7 +79,7 @@ func (s *Server) Start(ctx context.Context) error {
 
 	s.srv = &http.Server{
 		Addr:         addr,
-		Handler:      router.Serve(),
+		Handler:      s.newRouter().Serve(),
 		BaseContext:  func(_ net.Listener) context.Context { return ctx },
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
@@ -87,6 +90,26 @@ func (s *Server) Start(ctx context.Context) error {
 	return s.srv.ListenAndServe()
 }
 
+// newRouter sets up the routes of the server with their middleware
+func (s *Server) newRouter() *shift.Router {
+	router := shift.New()
+	router.Use(tracing.OtelMiddleware)
+	router.Use(middleware.CORSMiddleware)
+	router.Use(middleware.ErrorMiddleware(s.log))
+
+	adminAuth := middleware.AdminAuthMiddleware(s.adminToken)
+	router.OPTIONS("/*wildcard", middleware.OptionsHandler)
+	router.GET("/ws", s.handleWebSocket)
+	router.GET("/cluster/status", s.handleClusterStatus)
+	if s.debugConfig != nil {
+		router.GET("/debug/config", adminAuth(middleware.ConfigHandler(s.debugConfig)))
+	}
+	router.GET("/debug/broadcasts", adminAuth(s.handleBroadcasts))
+	router.GET("/debug/connections/:connection_id", adminAuth(s.handleConnection))
+	router.POST("/admin/loglevel", adminAuth(middleware.LogLevelHandler(s.log)))
+	return router
+}
+
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.log.Info("Shutting down HTTP server")
@@ -108,3 +131,29 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request, route s
 	wsHandler.HandleWebSocket(w, r)
 	return nil
 }
+
+// handleClusterStatus reports the last known connection counts of all notifications replicas
+func (s *Server) handleClusterStatus(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(s.notificationSvc.ClusterStatus())
+}
+
+// handleBroadcasts lists the last broadcasts of this replica newest first, only those to the group query parameter
+// when it is set
+func (s *Server) handleBroadcasts(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(s.notificationSvc.hub.RecentBroadcasts(r.URL.Query().Get("group")))
+}
+
+// handleConnection describes a connection of this replica, with its groups and when each last got a message.
+// Connections of other replicas are not found.
+func (s *Server) handleConnection(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+	info, ok := s.notificationSvc.hub.DescribeConnection(route.Params.Get("connection_id"))
+	if !ok {
+		middleware.WriteError(w, r, http.StatusNotFound, "connection not found on this instance")
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(info)
+}
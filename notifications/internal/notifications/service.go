@@ -5,18 +5,61 @@ import (
 	"encoding/json"
 	"log/slog"
 	"notifications/internal/config"
+	"shared/log"
 	"shared/messagebus"
+	"shared/metrics"
+	"shared/models"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/nats-io/nats.go"
 )
 
+const (
+	defaultPresenceInterval = 15 * time.Second
+	// presenceStaleAfter is the number of missed heartbeats after which a replica is reported stale
+	presenceStaleAfter = 3
+	// presenceForgetAfter is the number of missed heartbeats after which a replica is dropped from the cluster status
+	presenceForgetAfter = 20
+)
+
 // NotificationService handles WebSocket notifications and NATS message subscriptions
 type NotificationService struct {
-	hub  *Hub
-	mb   messagebus.MessageBusInterface
-	cfg  *config.Config
-	log  *slog.Logger
-	subs []*nats.Subscription
+	hub     *Hub
+	mb      messagebus.MessageBusInterface
+	cfg     *config.Config
+	log     *slog.Logger
+	metrics *metrics.NotificationsMetrics
+	subs    []*nats.Subscription
+
+	// webhooks receive the job and task updates selected by their events, nil when none are configured
+	webhooks *WebhookDispatcher
+
+	// dropped is the number of messages NATS dropped for each subscription when last counted
+	dropped   map[*nats.Subscription]int
+	droppedMu sync.Mutex
+
+	presence     map[string]InstanceStatus
+	presenceMu   sync.Mutex
+	stopPresence chan struct{}
+	presenceDone chan struct{}
+}
+
+// ClusterStatus is the last known load of every notifications replica
+type ClusterStatus struct {
+	InstanceID       string           `json:"instance_id"`
+	Instances        []InstanceStatus `json:"instances"`
+	TotalConnections int              `json:"total_connections"`
+}
+
+// InstanceStatus is the load a replica reported in its latest heartbeat
+type InstanceStatus struct {
+	InstanceID  string    `json:"instance_id"`
+	Connections int       `json:"connections"`
+	Groups      int       `json:"groups"`
+	LastSeen    time.Time `json:"last_seen"`
+	Stale       bool      `json:"stale"`
 }
 
 // Option configures the NotificationService
@@ -33,6 +76,10 @@ func NewNotificationService(
 		mb:   mb,
 		log:  slog.Default(),
 		subs: make([]*nats.Subscription, 0),
+
+		dropped: make(map[*nats.Subscription]int),
+
+		presence: make(map[string]InstanceStatus),
 	}
 
 	for _, opt := range opts {
@@ -52,6 +99,11 @@ func WithConfig(cfg *config.Config) Option {
 	return func(s *NotificationService) { s.cfg = cfg }
 }
 
+// WithMetrics sets the metrics collector
+func WithMetrics(m *metrics.NotificationsMetrics) Option {
+	return func(s *NotificationService) { s.metrics = m }
+}
+
 // Start initializes all NATS subscriptions for the notification service
 func (s *NotificationService) Start(ctx context.Context) error {
 	s.log.Info("Starting notification service subscriptions")
@@ -68,7 +120,26 @@ func (s *NotificationService) Start(ctx context.Context) error {
 		return err
 	}
 
-	s.log.Info("All NATS subscriptions established", slog.Int("count", len(s.subs)))
+	if err := s.setupPresenceSubscription(); err != nil {
+		return err
+	}
+
+	if err := s.setupAnalyzerLoadSubscription(); err != nil {
+		return err
+	}
+
+	if s.webhooks != nil {
+		if err := s.setupWebhookSubscriptions(); err != nil {
+			return err
+		}
+	}
+
+	s.applyPendingLimits()
+	s.startPresenceHeartbeat()
+
+	s.log.Info("All NATS subscriptions established",
+		slog.String("instanceId", s.instanceID()),
+		slog.Int("count", len(s.subs)))
 	return nil
 }
 
@@ -76,6 +147,12 @@ func (s *NotificationService) Start(ctx context.Context) error {
 func (s *NotificationService) Stop() {
 	s.log.Info("Stopping notification service", slog.Int("subscriptions", len(s.subs)))
 
+	if s.stopPresence != nil {
+		close(s.stopPresence)
+		<-s.presenceDone
+		s.stopPresence = nil
+	}
+
 	for _, sub := range s.subs {
 		if err := sub.Unsubscribe(); err != nil {
 			s.log.Error("Failed to unsubscribe", slog.Any("error", err))
@@ -83,6 +160,10 @@ func (s *NotificationService) Stop() {
 	}
 
 	s.subs = s.subs[:0] // Clear slice
+
+	s.droppedMu.Lock()
+	clear(s.dropped)
+	s.droppedMu.Unlock()
 }
 
 // GetWebSocketHandler returns the WebSocket handler for HTTP routing
@@ -100,7 +181,11 @@ func (s *NotificationService) setupJobUpdateSubscription() error {
 		}
 
 		s.log.Info("Broadcasting job update", slog.String("jobId", m.JobID))
-		s.hub.Broadcast(m)
+		s.hub.BroadcastJobUpdate(m, m.JobID)
+		s.hub.BroadcastToGroup(newJobLifecycleMessage(m, publishedAt(msg, time.Now())), LifecycleGroup)
+		if models.JobStatus(m.Status).IsTerminal() {
+			s.hub.BufferTerminalEvent(m.JobID, withoutLinks(m))
+		}
 	})
 
 	if err != nil {
@@ -112,6 +197,16 @@ func (s *NotificationService) setupJobUpdateSubscription() error {
 	return nil
 }
 
+// withoutLinks returns the job update without the link lists of its result, which the job holds in full.
+// The terminal event buffer keeps thousands of updates, and a page with thousands of links makes one large.
+func withoutLinks(m messagebus.JobUpdateMessage) messagebus.JobUpdateMessage {
+	if m.Result != nil {
+		result := m.Result.WithoutLinks()
+		m.Result = &result
+	}
+	return m
+}
+
 // setupTaskStatusSubscription subscribes to task status messages and broadcasts to job groups
 func (s *NotificationService) setupTaskStatusSubscription() error {
 	sub, err := s.mb.SubscribeToTaskStatusUpdate(func(ctx context.Context, msg *nats.Msg) {
@@ -120,6 +215,9 @@ func (s *NotificationService) setupTaskStatusSubscription() error {
 			s.log.Error("Failed to unmarshal task update", slog.Any("error", err))
 			return
 		}
+		if !s.isKnownTaskType(m.Type, m.JobID, m.TaskType) {
+			return
+		}
 
 		s.log.Info("Broadcasting task status update", slog.String("jobId", m.JobID))
 		s.hub.BroadcastToGroup(m, m.JobID)
@@ -142,12 +240,15 @@ func (s *NotificationService) setupSubTaskSubscription() error {
 			s.log.Error("Failed to unmarshal subtask update", slog.Any("error", err))
 			return
 		}
+		if !s.isKnownTaskType(m.Type, m.JobID, m.TaskType) {
+			return
+		}
 
 		s.log.Info("Broadcasting subtask update",
 			slog.String("jobId", m.JobID),
 			slog.String("key", m.Key),
 			slog.String("status", string(m.SubTask.Status)),
-			slog.String("url", m.SubTask.URL),
+			log.URL("url", m.SubTask.URL),
 			slog.String("description", m.SubTask.Description))
 
 		s.hub.BroadcastToGroup(m, m.JobID)
@@ -161,3 +262,179 @@ func (s *NotificationService) setupSubTaskSubscription() error {
 	s.subs = append(s.subs, sub)
 	return nil
 }
+
+// setupAnalyzerLoadSubscription subscribes to the analyzer load reports and forwards them to the system group
+func (s *NotificationService) setupAnalyzerLoadSubscription() error {
+	sub, err := s.mb.SubscribeToAnalyzerLoad(func(ctx context.Context, msg *nats.Msg) {
+		var m messagebus.AnalyzerLoadMessage
+		if err := json.Unmarshal(msg.Data, &m); err != nil {
+			s.log.Error("Failed to unmarshal analyzer load", slog.Any("error", err))
+			return
+		}
+
+		s.log.Debug("Broadcasting analyzer load",
+			slog.String("instanceId", m.InstanceID),
+			slog.Int("queueDepth", m.QueueDepth))
+		s.hub.BroadcastToGroup(m, SystemGroup)
+	})
+
+	if err != nil {
+		s.log.Error("Failed to subscribe to analyzer load", slog.Any("error", err))
+		return err
+	}
+
+	s.subs = append(s.subs, sub)
+	return nil
+}
+
+// isKnownTaskType reports whether the task type of a message is registered.
+// Messages with an unknown type are dropped, so clients never render a task they know nothing about.
+func (s *NotificationService) isKnownTaskType(messageType messagebus.MessageType, jobID, taskType string) bool {
+	if models.IsValidTaskType(models.TaskType(taskType)) {
+		return true
+	}
+
+	s.log.Warn("Dropping message with unknown task type",
+		slog.String("type", string(messageType)),
+		slog.String("jobId", jobID),
+		slog.String("taskType", taskType))
+	if s.metrics != nil {
+		s.metrics.RecordDroppedMessage(consumerWebSocket, string(messageType), "unknown_task_type")
+	}
+	return false
+}
+
+// instanceID returns the ID of this replica
+func (s *NotificationService) instanceID() string {
+	if s.cfg != nil && s.cfg.Service.InstanceID != "" {
+		return s.cfg.Service.InstanceID
+	}
+	return s.hub.instanceID
+}
+
+// presenceInterval returns how often this replica publishes its heartbeat
+func (s *NotificationService) presenceInterval() time.Duration {
+	if s.cfg != nil && s.cfg.Presence.Interval > 0 {
+		return s.cfg.Presence.Interval
+	}
+	return defaultPresenceInterval
+}
+
+// setupPresenceSubscription subscribes to the heartbeats of all replicas, this one included
+func (s *NotificationService) setupPresenceSubscription() error {
+	sub, err := s.mb.SubscribeToPresence(func(ctx context.Context, msg *nats.Msg) {
+		var m messagebus.PresenceMessage
+		if err := json.Unmarshal(msg.Data, &m); err != nil {
+			s.log.Error("Failed to unmarshal presence message", slog.Any("error", err))
+			return
+		}
+
+		// Staleness is judged by the time of receipt, so clock skew between replicas does not matter
+		s.recordPresence(InstanceStatus{
+			InstanceID:  m.InstanceID,
+			Connections: m.Connections,
+			Groups:      m.Groups,
+			LastSeen:    time.Now(),
+		})
+	})
+
+	if err != nil {
+		s.log.Error("Failed to subscribe to presence messages", slog.Any("error", err))
+		return err
+	}
+
+	s.subs = append(s.subs, sub)
+	return nil
+}
+
+// startPresenceHeartbeat publishes the hub's load right away and then on every presence interval until Stop
+func (s *NotificationService) startPresenceHeartbeat() {
+	s.stopPresence = make(chan struct{})
+	s.presenceDone = make(chan struct{})
+
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+
+		ticker := time.NewTicker(s.presenceInterval())
+		defer ticker.Stop()
+
+		for {
+			s.publishPresence()
+			// NATS reports a slow consumer once, the messages it keeps dropping are counted as they go
+			for _, sub := range s.subs {
+				s.recordDroppedMessages(sub)
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}(s.stopPresence, s.presenceDone)
+}
+
+// publishPresence publishes the current load of this replica
+func (s *NotificationService) publishPresence() {
+	stats := s.hub.Stats()
+	err := s.mb.PublishPresence(context.Background(), messagebus.PresenceMessage{
+		InstanceID:  s.instanceID(),
+		Connections: stats.Connections,
+		Groups:      stats.Groups,
+		SentAt:      time.Now().UTC(),
+	})
+	if err != nil {
+		s.log.Error("Failed to publish presence", slog.Any("error", err))
+	}
+}
+
+// recordPresence stores the latest heartbeat of a replica and forgets replicas that stopped reporting long ago
+func (s *NotificationService) recordPresence(status InstanceStatus) {
+	forgetBefore := time.Now().Add(-presenceForgetAfter * s.presenceInterval())
+
+	s.presenceMu.Lock()
+	defer s.presenceMu.Unlock()
+
+	s.presence[status.InstanceID] = status
+	for id, known := range s.presence {
+		if known.LastSeen.Before(forgetBefore) {
+			delete(s.presence, id)
+		}
+	}
+}
+
+// ClusterStatus returns the last known load of every replica, using the live counts for this one
+func (s *NotificationService) ClusterStatus() ClusterStatus {
+	now := time.Now()
+	staleBefore := now.Add(-presenceStaleAfter * s.presenceInterval())
+	self := s.instanceID()
+	stats := s.hub.Stats()
+
+	s.presenceMu.Lock()
+	instances := make([]InstanceStatus, 0, len(s.presence)+1)
+	for id, status := range s.presence {
+		if id != self {
+			instances = append(instances, status)
+		}
+	}
+	s.presenceMu.Unlock()
+
+	instances = append(instances, InstanceStatus{
+		InstanceID:  self,
+		Connections: stats.Connections,
+		Groups:      stats.Groups,
+		LastSeen:    now,
+	})
+	sort.Slice(instances, func(i, k int) bool {
+		return instances[i].InstanceID < instances[k].InstanceID
+	})
+
+	status := ClusterStatus{InstanceID: self, Instances: instances}
+	for i := range status.Instances {
+		status.Instances[i].Stale = status.Instances[i].LastSeen.Before(staleBefore)
+		if !status.Instances[i].Stale {
+			status.TotalConnections += status.Instances[i].Connections
+		}
+	}
+	return status
+}
@@ -0,0 +1,135 @@
+package notifications
+
+import (
+	"container/list"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// terminalEvents keeps the final job update of recently finished jobs, keyed by job ID, so a client that missed it,
+// because it was not connected or its write failed, gets it once it subscribes to the job.
+// All updates are kept for the same TTL, so the list holds them in the order they expire.
+type terminalEvents struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	maxJobs int
+	order   *list.List
+	byJob   map[string]*list.Element
+	now     func() time.Time
+}
+
+// terminalEvent is the encoded final update of a job, kept until expires
+type terminalEvent struct {
+	jobID   string
+	data    []byte
+	expires time.Time
+}
+
+func newTerminalEvents(ttl time.Duration, maxJobs int) *terminalEvents {
+	return &terminalEvents{
+		ttl:     ttl,
+		maxJobs: maxJobs,
+		order:   list.New(),
+		byJob:   make(map[string]*list.Element),
+		now:     time.Now,
+	}
+}
+
+// store keeps data as the final update of a job, replacing an earlier one.
+// It returns the number of updates dropped because they expired and because the buffer was full.
+func (b *terminalEvents) store(jobID string, data []byte) (expired, evicted int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if el, ok := b.byJob[jobID]; ok {
+		b.order.Remove(el)
+	}
+	b.byJob[jobID] = b.order.PushBack(&terminalEvent{jobID: jobID, data: data, expires: now.Add(b.ttl)})
+
+	for el := b.order.Front(); el != nil && !now.Before(el.Value.(*terminalEvent).expires); el = b.order.Front() {
+		b.remove(el)
+		expired++
+	}
+	for b.order.Len() > b.maxJobs {
+		b.remove(b.order.Front())
+		evicted++
+	}
+	return expired, evicted
+}
+
+// get returns the final update of a job, nil when there is none or it has expired
+func (b *terminalEvents) get(jobID string) []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	el, ok := b.byJob[jobID]
+	if !ok {
+		return nil
+	}
+	event := el.Value.(*terminalEvent)
+	if !b.now().Before(event.expires) {
+		return nil
+	}
+	return event.data
+}
+
+func (b *terminalEvents) remove(el *list.Element) {
+	delete(b.byJob, el.Value.(*terminalEvent).jobID)
+	b.order.Remove(el)
+}
+
+// WithHubTerminalEvents keeps the final update of each job for ttl and sends it to clients subscribing to the job later.
+// At most maxJobs updates are kept, the oldest are dropped first. A zero ttl keeps none.
+func WithHubTerminalEvents(ttl time.Duration, maxJobs int) HubOption {
+	return func(h *Hub) {
+		if ttl > 0 && maxJobs > 0 {
+			h.terminal = newTerminalEvents(ttl, maxJobs)
+		}
+	}
+}
+
+// BufferTerminalEvent keeps the final update of a job for the clients that subscribe to it after it was broadcast
+func (h *Hub) BufferTerminalEvent(jobID string, msg any) {
+	if h.terminal == nil {
+		return
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		h.log.Error("Failed to marshal message", slog.Any("error", err))
+		return
+	}
+
+	expired, evicted := h.terminal.store(jobID, data)
+	h.recordTerminalEvents("buffered", 1)
+	h.recordTerminalEvents("expired", expired)
+	h.recordTerminalEvents("evicted", evicted)
+}
+
+// replayTerminalEvent sends the kept final update of a job to a connection that just subscribed to it.
+// A client that already received the update gets it again, applying a final status twice is harmless.
+func (h *Hub) replayTerminalEvent(conn *Connection, jobID string) {
+	if h.terminal == nil {
+		return
+	}
+
+	data := h.terminal.get(jobID)
+	if data == nil {
+		return
+	}
+
+	if err := conn.WriteMessage(data); err != nil {
+		h.log.Error("Failed to replay final job update", slog.String("jobId", jobID), slog.Any("error", err))
+		return
+	}
+	h.recordTerminalEvents("replayed", 1)
+}
+
+func (h *Hub) recordTerminalEvents(outcome string, count int) {
+	if h.metrics != nil && count > 0 {
+		h.metrics.RecordTerminalEvents(outcome, count)
+	}
+}
@@ -0,0 +1,34 @@
+package notifications
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTerminalEvents(t *testing.T) {
+	now := time.Now()
+	events := newTerminalEvents(time.Hour, 2)
+	events.now = func() time.Time { return now }
+
+	expired, evicted := events.store("job-1", []byte("first"))
+	assert.Zero(t, expired)
+	assert.Zero(t, evicted)
+	events.store("job-1", []byte("second"))
+	assert.Equal(t, []byte("second"), events.get("job-1"), "a later update replaces the earlier one")
+
+	now = now.Add(30 * time.Minute)
+	events.store("job-2", []byte("job-2"))
+	_, evicted = events.store("job-3", []byte("job-3"))
+	assert.Equal(t, 1, evicted, "the oldest update is dropped once the buffer is full")
+	assert.Nil(t, events.get("job-1"))
+	assert.Equal(t, []byte("job-2"), events.get("job-2"))
+
+	now = now.Add(time.Hour)
+	assert.Nil(t, events.get("job-2"), "an expired update is not replayed")
+	expired, _ = events.store("job-4", []byte("job-4"))
+	assert.Equal(t, 2, expired)
+	assert.Equal(t, 1, events.order.Len())
+	assert.Len(t, events.byJob, 1)
+}
@@ -0,0 +1,409 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"net/http"
+	"notifications/internal/config"
+	"shared/log"
+	"shared/messagebus"
+	"shared/metrics"
+	"shared/models"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// SignatureHeader carries the signature of a webhook delivery, sha256= followed by the hex HMAC-SHA256
+	// of the request body keyed with the webhook secret
+	SignatureHeader = "X-Signature"
+	// webhookQueue is the queue group of the webhook subscriptions, so only one replica delivers each update
+	webhookQueue = "notifications-webhooks"
+	// maxWebhookBackoff caps the wait between two delivery attempts
+	maxWebhookBackoff = 5 * time.Minute
+)
+
+// WebhookDispatcher posts job and task updates to the configured webhooks, signed with the shared secret.
+// Deliveries are queued to a fixed pool of workers and retried with exponential backoff while the receiver is
+// unavailable. The deliveries of a job to an endpoint share a worker, so a retry holds back the ones after it
+// rather than letting them overtake it. Deliveries dropped on a full queue, those that fail for good and those
+// abandoned by Close are appended to the dead-letter log, when there is one.
+type WebhookDispatcher struct {
+	urls        []string
+	events      []string
+	secret      []byte
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+	log         *slog.Logger
+	metrics     *metrics.NotificationsMetrics
+
+	// deadLetters receives a DeadLetter JSON line per delivery that failed for good, nil when there is no log
+	deadLetters   io.Writer
+	deadLettersMu sync.Mutex
+
+	// queues holds the deliveries waiting for each worker
+	queues []chan webhookDelivery
+	// closed is set by Close, after which deliveries are no longer queued
+	closed   bool
+	closedMu sync.RWMutex
+
+	// ctx is cancelled by Close, ending the workers and the deliveries in progress
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+	// wg counts the deliveries queued and not made or abandoned yet
+	wg sync.WaitGroup
+}
+
+// webhookDelivery is an update waiting to be posted to a webhook
+type webhookDelivery struct {
+	url         string
+	messageType messagebus.MessageType
+	jobID       string
+	body        []byte
+}
+
+// DeadLetter is a delivery that failed for good, as written to the dead-letter log
+type DeadLetter struct {
+	FailedAt    time.Time              `json:"failed_at"`
+	URL         string                 `json:"url"`
+	MessageType messagebus.MessageType `json:"message_type"`
+	JobID       string                 `json:"job_id"`
+	Attempts    int                    `json:"attempts"`
+	Error       string                 `json:"error"`
+	// Payload is the update as it was posted
+	Payload json.RawMessage `json:"payload"`
+}
+
+// WebhookOption configures the WebhookDispatcher
+type WebhookOption func(*WebhookDispatcher)
+
+// NewWebhookDispatcher creates a dispatcher posting to the webhooks of the configuration
+func NewWebhookDispatcher(cfg config.WebhookConfig, opts ...WebhookOption) *WebhookDispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &WebhookDispatcher{
+		urls:        cfg.URLs,
+		events:      cfg.Events,
+		secret:      []byte(cfg.Secret),
+		client:      &http.Client{Timeout: cfg.Timeout},
+		maxAttempts: max(cfg.MaxAttempts, 1),
+		backoff:     cfg.RetryBackoff,
+		log:         slog.Default(),
+		queues:      make([]chan webhookDelivery, max(cfg.Workers, 1)),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	for i := range d.queues {
+		d.queues[i] = make(chan webhookDelivery, max(cfg.QueueSize, 1))
+		d.workers.Add(1)
+		go d.work(d.queues[i])
+	}
+
+	return d
+}
+
+// WithWebhookClient sets the HTTP client deliveries are made with
+func WithWebhookClient(client *http.Client) WebhookOption {
+	return func(d *WebhookDispatcher) { d.client = client }
+}
+
+// WithWebhookLogger sets the logger
+func WithWebhookLogger(log *slog.Logger) WebhookOption {
+	return func(d *WebhookDispatcher) { d.log = log }
+}
+
+// WithWebhookMetrics sets the metrics collector
+func WithWebhookMetrics(m *metrics.NotificationsMetrics) WebhookOption {
+	return func(d *WebhookDispatcher) { d.metrics = m }
+}
+
+// WithWebhookDeadLetters appends each delivery that failed for good to w, as a line of DeadLetter JSON
+func WithWebhookDeadLetters(w io.Writer) WebhookOption {
+	return func(d *WebhookDispatcher) { d.deadLetters = w }
+}
+
+// WithWebhooks posts the updates selected by the dispatcher's events to its webhooks
+func WithWebhooks(d *WebhookDispatcher) Option {
+	return func(s *NotificationService) { s.webhooks = d }
+}
+
+// SignWebhookPayload returns the signature of a webhook body as sent in the SignatureHeader
+func SignWebhookPayload(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatch queues body, an update of the job, for every webhook. A delivery whose queue is full is dropped,
+// and one dispatched after Close is abandoned.
+func (d *WebhookDispatcher) Dispatch(messageType messagebus.MessageType, jobID string, body []byte) {
+	d.closedMu.RLock()
+	defer d.closedMu.RUnlock()
+
+	for _, url := range d.urls {
+		delivery := webhookDelivery{url: url, messageType: messageType, jobID: jobID, body: body}
+		if d.closed {
+			d.abandon(delivery, 0)
+			continue
+		}
+
+		d.wg.Add(1)
+		select {
+		case d.queues[d.queueOf(url, jobID)] <- delivery:
+		default:
+			d.wg.Done()
+			d.drop(delivery)
+		}
+	}
+}
+
+// Close stops the workers and waits for the deliveries in progress to return. The deliveries still queued or
+// being retried are abandoned to the dead-letter log.
+func (d *WebhookDispatcher) Close() {
+	d.closedMu.Lock()
+	d.closed = true
+	d.closedMu.Unlock()
+
+	d.cancel()
+	d.workers.Wait()
+
+	// No worker takes deliveries anymore and none are queued after closed was set
+	for _, queue := range d.queues {
+		for len(queue) > 0 {
+			d.abandon(<-queue, 0)
+			d.wg.Done()
+		}
+	}
+	d.wg.Wait()
+}
+
+// queueOf picks the queue of the deliveries of a job to a webhook, the same one for all of them
+func (d *WebhookDispatcher) queueOf(url, jobID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(url))
+	h.Write([]byte{0})
+	h.Write([]byte(jobID))
+	return int(h.Sum32() % uint32(len(d.queues)))
+}
+
+// work makes the deliveries of a queue one after the other until the dispatcher is closed
+func (d *WebhookDispatcher) work(queue <-chan webhookDelivery) {
+	defer d.workers.Done()
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case delivery := <-queue:
+			// Both cases may be ready, a delivery taken after Close is not attempted
+			if d.ctx.Err() != nil {
+				d.abandon(delivery, 0)
+			} else {
+				d.deliver(delivery)
+			}
+			d.wg.Done()
+		}
+	}
+}
+
+// drop gives up on a delivery that found its queue full, as one that failed for good
+func (d *WebhookDispatcher) drop(delivery webhookDelivery) {
+	if d.metrics != nil {
+		d.metrics.RecordWebhookDropped(string(delivery.messageType))
+	}
+	d.log.Warn("Dropping webhook delivery, the delivery queue is full",
+		slog.String("type", string(delivery.messageType)),
+		slog.String("jobId", delivery.jobID),
+		log.URL("url", delivery.url))
+	d.writeDeadLetter(delivery, 0, "webhook delivery queue is full")
+}
+
+// abandon gives up on a delivery because the dispatcher is closed, after the attempts already made
+func (d *WebhookDispatcher) abandon(delivery webhookDelivery, attempts int) {
+	d.log.Warn("Abandoning webhook delivery, the dispatcher is closed",
+		slog.String("type", string(delivery.messageType)),
+		slog.String("jobId", delivery.jobID),
+		log.URL("url", delivery.url),
+		slog.Int("attempts", attempts))
+	d.writeDeadLetter(delivery, attempts, "webhook dispatcher closed")
+}
+
+// deliver posts the update to the webhook until it is accepted, the attempts run out, it is refused for good
+// or the dispatcher is closed
+func (d *WebhookDispatcher) deliver(delivery webhookDelivery) {
+	backoff := d.backoff
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		retry, err := d.post(delivery.url, delivery.body)
+		if err == nil {
+			d.recordAttempt(delivery.messageType, "delivered", time.Since(start))
+			return
+		}
+		if d.ctx.Err() != nil {
+			d.abandon(delivery, attempt)
+			return
+		}
+		if !retry || attempt >= d.maxAttempts {
+			d.recordAttempt(delivery.messageType, "failed", time.Since(start))
+			d.log.Error("Failed to deliver update to webhook",
+				slog.String("type", string(delivery.messageType)),
+				slog.String("jobId", delivery.jobID),
+				log.URL("url", delivery.url),
+				slog.Int("attempts", attempt),
+				slog.Any("error", err))
+			d.writeDeadLetter(delivery, attempt, err.Error())
+			return
+		}
+
+		d.recordAttempt(delivery.messageType, "retried", time.Since(start))
+		d.log.Warn("Webhook delivery failed, retrying",
+			slog.String("type", string(delivery.messageType)),
+			slog.String("jobId", delivery.jobID),
+			log.URL("url", delivery.url),
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", backoff),
+			slog.Any("error", err))
+
+		select {
+		case <-d.ctx.Done():
+			d.abandon(delivery, attempt)
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxWebhookBackoff)
+	}
+}
+
+// post makes a single delivery attempt. Network errors, 5xx and 429 responses are worth retrying,
+// other responses tell the receiver will not take the update.
+func (d *WebhookDispatcher) post(url string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, SignWebhookPayload(d.secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	// Drained so the connection is reused for the next delivery
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("webhook responded with HTTP %d", resp.StatusCode)
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+}
+
+// writeDeadLetter appends a delivery that failed for good to the dead-letter log, one JSON line each
+func (d *WebhookDispatcher) writeDeadLetter(delivery webhookDelivery, attempts int, reason string) {
+	if d.deadLetters == nil {
+		return
+	}
+
+	letter := DeadLetter{
+		FailedAt:    time.Now().UTC(),
+		URL:         config.RedactURL(delivery.url),
+		MessageType: delivery.messageType,
+		JobID:       delivery.jobID,
+		Attempts:    attempts,
+		Error:       reason,
+		Payload:     delivery.body,
+	}
+	line, err := json.Marshal(letter)
+	if err != nil {
+		d.log.Error("Failed to marshal dead letter", slog.String("jobId", letter.JobID), slog.Any("error", err))
+		return
+	}
+
+	d.deadLettersMu.Lock()
+	defer d.deadLettersMu.Unlock()
+	if _, err := d.deadLetters.Write(append(line, '\n')); err != nil {
+		d.log.Error("Failed to write dead letter", slog.String("jobId", letter.JobID), slog.Any("error", err))
+	}
+}
+
+func (d *WebhookDispatcher) recordAttempt(messageType messagebus.MessageType, outcome string, duration time.Duration) {
+	if d.metrics != nil {
+		d.metrics.RecordWebhookAttempt(string(messageType), outcome, duration)
+	}
+}
+
+// setupWebhookSubscriptions subscribes in the webhook queue group to the updates selected by the dispatcher's
+// events, so only one replica posts each of them. job.final takes the final job updates out of job.update,
+// which it is superseded by when both are selected.
+func (s *NotificationService) setupWebhookSubscriptions() error {
+	events := s.webhooks.events
+	allJobUpdates := slices.Contains(events, string(messagebus.JobUpdateMessageType))
+
+	if allJobUpdates || slices.Contains(events, config.WebhookEventJobFinal) {
+		if err := s.setupWebhookSubscription(messagebus.JobUpdateMessageType, !allJobUpdates, s.mb.QueueSubscribeToJobUpdate); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(events, string(messagebus.TaskStatusUpdateMessageType)) {
+		if err := s.setupWebhookSubscription(messagebus.TaskStatusUpdateMessageType, false, s.mb.QueueSubscribeToTaskStatusUpdate); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(events, string(messagebus.SubTaskUpdateMessageType)) {
+		if err := s.setupWebhookSubscription(messagebus.SubTaskUpdateMessageType, false, s.mb.QueueSubscribeToSubTaskUpdate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setupWebhookSubscription subscribes to the updates of a message type with subscribe, passing each one to the
+// webhooks as it was published. finalOnly keeps the job updates with a terminal status only.
+func (s *NotificationService) setupWebhookSubscription(
+	messageType messagebus.MessageType,
+	finalOnly bool,
+	subscribe func(queue string, handler func(ctx context.Context, m *nats.Msg)) (*nats.Subscription, error),
+) error {
+	sub, err := subscribe(webhookQueue, func(ctx context.Context, msg *nats.Msg) {
+		// Every update carries its job, job updates their status as well
+		var m struct {
+			JobID  string `json:"job_id"`
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal(msg.Data, &m); err != nil {
+			s.log.Error("Failed to unmarshal update for webhooks", slog.String("type", string(messageType)), slog.Any("error", err))
+			return
+		}
+		if finalOnly && !models.JobStatus(m.Status).IsTerminal() {
+			return
+		}
+
+		s.log.Debug("Dispatching update to webhooks", slog.String("type", string(messageType)), slog.String("jobId", m.JobID))
+		s.webhooks.Dispatch(messageType, m.JobID, msg.Data)
+	})
+
+	if err != nil {
+		s.log.Error("Failed to subscribe to updates for webhooks", slog.String("type", string(messageType)), slog.Any("error", err))
+		return err
+	}
+
+	s.subs = append(s.subs, sub)
+	return nil
+}
@@ -0,0 +1,352 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"notifications/internal/config"
+	"shared/messagebus"
+	"shared/metrics"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookReceiver records the deliveries it gets, answering them with the statuses in turn and 200 once they run out
+type webhookReceiver struct {
+	mu         sync.Mutex
+	statuses   []int
+	bodies     [][]byte
+	signatures []string
+	attempts   atomic.Int32
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.attempts.Add(1)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bodies = append(r.bodies, body)
+	r.signatures = append(r.signatures, req.Header.Get(SignatureHeader))
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+// deliveries returns the bodies and signatures received so far
+func (r *webhookReceiver) deliveries() ([][]byte, []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.bodies, r.signatures
+}
+
+func newTestWebhookDispatcher(t *testing.T, events []string, urls ...string) (*WebhookDispatcher, *metrics.NotificationsMetrics) {
+	t.Helper()
+
+	m := metrics.NewNotificationsMetrics("test")
+	d := NewWebhookDispatcher(
+		config.WebhookConfig{URLs: urls, Events: events, Secret: "secret", Timeout: time.Second, MaxAttempts: 3, RetryBackoff: time.Millisecond, Workers: 2, QueueSize: 10},
+		WithWebhookLogger(slog.New(slog.DiscardHandler)),
+		WithWebhookMetrics(m),
+	)
+	t.Cleanup(d.Close)
+	return d, m
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	// The HMAC-SHA256 test vector of RFC 4231, test case 2
+	assert.Equal(t, "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		SignWebhookPayload([]byte("Jefe"), []byte("what do ya want for nothing?")))
+}
+
+func TestWebhookDispatcher_Dispatch(t *testing.T) {
+	testCases := []struct {
+		name             string
+		statuses         []int
+		expectedAttempts int32
+		expectedOutcomes map[string]float64
+	}{
+		{name: "Delivered", expectedAttempts: 1, expectedOutcomes: map[string]float64{"delivered": 1}},
+		{
+			name:             "RetriedUntilDelivered",
+			statuses:         []int{http.StatusServiceUnavailable, http.StatusTooManyRequests},
+			expectedAttempts: 3,
+			expectedOutcomes: map[string]float64{"retried": 2, "delivered": 1},
+		},
+		{
+			name:             "AttemptsRunOut",
+			statuses:         []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway},
+			expectedAttempts: 3,
+			expectedOutcomes: map[string]float64{"retried": 2, "failed": 1},
+		},
+		{
+			name:             "RefusedForGood",
+			statuses:         []int{http.StatusBadRequest},
+			expectedAttempts: 1,
+			expectedOutcomes: map[string]float64{"failed": 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := &webhookReceiver{statuses: tc.statuses}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+			d, m := newTestWebhookDispatcher(t, nil, server.URL)
+
+			body := []byte(`{"type":"job.update","job_id":"job-1","status":"completed"}`)
+			d.Dispatch(messagebus.JobUpdateMessageType, "job-1", body)
+			d.wg.Wait()
+
+			assert.Equal(t, tc.expectedAttempts, receiver.attempts.Load())
+			assert.Equal(t, tc.expectedOutcomes, counterValues(t, m.WebhookDeliveryAttemptsTotal, "outcome"))
+			bodies, signatures := receiver.deliveries()
+			for i := range bodies {
+				assert.Equal(t, body, bodies[i], "the update is posted as it was published")
+				assert.Equal(t, SignWebhookPayload([]byte("secret"), body), signatures[i])
+			}
+		})
+	}
+}
+
+func TestWebhookDispatcher_DeadLetters(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusGone}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	var deadLetters bytes.Buffer
+	d := NewWebhookDispatcher(
+		config.WebhookConfig{URLs: []string{server.URL}, Secret: "secret", Timeout: time.Second, MaxAttempts: 3, RetryBackoff: time.Millisecond},
+		WithWebhookLogger(slog.New(slog.DiscardHandler)),
+		WithWebhookDeadLetters(&deadLetters),
+	)
+	defer d.Close()
+
+	body := []byte(`{"type":"task.status_update","job_id":"job-1","task_type":"extracting","status":"completed"}`)
+	d.Dispatch(messagebus.TaskStatusUpdateMessageType, "job-1", body)
+	d.wg.Wait()
+	d.Dispatch(messagebus.TaskStatusUpdateMessageType, "job-2", body)
+	d.wg.Wait()
+
+	lines := bytes.Split(bytes.TrimSpace(deadLetters.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2, "a dead letter is written once the attempts run out and once the update is refused for good")
+	for i, expected := range []struct {
+		jobID    string
+		attempts int
+		err      string
+	}{
+		{jobID: "job-1", attempts: 3, err: "webhook responded with HTTP 503"},
+		{jobID: "job-2", attempts: 1, err: "webhook responded with HTTP 410"},
+	} {
+		var letter DeadLetter
+		require.NoError(t, json.Unmarshal(lines[i], &letter))
+		assert.Equal(t, server.URL, letter.URL)
+		assert.Equal(t, messagebus.TaskStatusUpdateMessageType, letter.MessageType)
+		assert.Equal(t, expected.jobID, letter.JobID)
+		assert.Equal(t, expected.attempts, letter.Attempts)
+		assert.Equal(t, expected.err, letter.Error)
+		assert.JSONEq(t, string(body), string(letter.Payload))
+		assert.False(t, letter.FailedAt.IsZero())
+	}
+}
+
+func TestWebhookDispatcher_CloseAbandonsRetries(t *testing.T) {
+	receiver := &webhookReceiver{statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	var deadLetters bytes.Buffer
+	d := NewWebhookDispatcher(
+		config.WebhookConfig{URLs: []string{server.URL}, Secret: "secret", Timeout: time.Second, MaxAttempts: 5, RetryBackoff: time.Hour, Workers: 1, QueueSize: 10},
+		WithWebhookLogger(slog.New(slog.DiscardHandler)),
+		WithWebhookDeadLetters(&deadLetters),
+	)
+	d.Dispatch(messagebus.JobUpdateMessageType, "job-1", []byte(`{"key":"1"}`))
+	require.Eventually(t, func() bool { return receiver.attempts.Load() == 1 }, time.Second, 5*time.Millisecond)
+	// Queued behind the delivery being retried
+	d.Dispatch(messagebus.JobUpdateMessageType, "job-1", []byte(`{"key":"2"}`))
+
+	closed := make(chan struct{})
+	go func() {
+		d.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close should not wait for the backoff of a pending retry")
+	}
+	d.Dispatch(messagebus.JobUpdateMessageType, "job-1", []byte(`{"key":"3"}`))
+	assert.Equal(t, int32(1), receiver.attempts.Load(), "nothing is posted once closed")
+
+	lines := bytes.Split(bytes.TrimSpace(deadLetters.Bytes()), []byte("\n"))
+	require.Len(t, lines, 3, "every abandoned delivery is written to the dead-letter log")
+	for i, expected := range []struct {
+		payload  string
+		attempts int
+	}{
+		{payload: `{"key":"1"}`, attempts: 1},
+		{payload: `{"key":"2"}`},
+		{payload: `{"key":"3"}`},
+	} {
+		var letter DeadLetter
+		require.NoError(t, json.Unmarshal(lines[i], &letter))
+		assert.Equal(t, "webhook dispatcher closed", letter.Error)
+		assert.Equal(t, expected.attempts, letter.Attempts)
+		assert.JSONEq(t, expected.payload, string(letter.Payload))
+	}
+}
+
+func TestWebhookDispatcher_QueueFull(t *testing.T) {
+	// The receiver holds the first delivery until released, so the worker cannot take the next ones
+	release := make(chan struct{})
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if received.Add(1) == 1 {
+			<-release
+		}
+	}))
+	defer server.Close()
+
+	var deadLetters bytes.Buffer
+	m := metrics.NewNotificationsMetrics("test")
+	d := NewWebhookDispatcher(
+		config.WebhookConfig{URLs: []string{server.URL}, Secret: "secret", Timeout: 5 * time.Second, MaxAttempts: 1, Workers: 1, QueueSize: 2},
+		WithWebhookLogger(slog.New(slog.DiscardHandler)),
+		WithWebhookMetrics(m),
+		WithWebhookDeadLetters(&deadLetters),
+	)
+	defer d.Close()
+
+	d.Dispatch(messagebus.SubTaskUpdateMessageType, "job-1", []byte(`{"key":"1"}`))
+	require.Eventually(t, func() bool { return received.Load() == 1 }, time.Second, 5*time.Millisecond)
+	for i := 2; i <= 5; i++ {
+		d.Dispatch(messagebus.SubTaskUpdateMessageType, "job-1", []byte(`{"key":"`+strconv.Itoa(i)+`"}`))
+	}
+	close(release)
+	d.wg.Wait()
+
+	assert.Equal(t, int32(3), received.Load(), "the queue holds two deliveries behind the one in progress")
+	assert.Equal(t, map[string]float64{"task.subtask_update": 2},
+		counterValues(t, m.WebhookDeliveriesDroppedTotal, metrics.LabelMessageType))
+
+	lines := bytes.Split(bytes.TrimSpace(deadLetters.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2, "a dropped delivery is written to the dead-letter log")
+	var letter DeadLetter
+	require.NoError(t, json.Unmarshal(lines[0], &letter))
+	assert.Equal(t, "webhook delivery queue is full", letter.Error)
+	assert.Equal(t, 0, letter.Attempts)
+	assert.JSONEq(t, `{"key":"4"}`, string(letter.Payload))
+}
+
+func TestWebhookDispatcher_OrdersDeliveriesOfAJob(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	var failed atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		// The first delivery is retried once, the ones after it must wait for it
+		if string(body) == `{"key":"0"}` && failed.CompareAndSwap(false, true) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		keys = append(keys, string(body))
+	}))
+	defer server.Close()
+	d, _ := newTestWebhookDispatcher(t, nil, server.URL)
+
+	var expected []string
+	for i := range 10 {
+		body := `{"key":"` + strconv.Itoa(i) + `"}`
+		expected = append(expected, body)
+		d.Dispatch(messagebus.SubTaskUpdateMessageType, "job-1", []byte(body))
+	}
+	d.wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, expected, keys)
+}
+
+func TestNotificationService_Webhooks_Integration(t *testing.T) {
+	nc, server := setupNats(t, 8439)
+	defer server.Shutdown()
+	defer nc.Close()
+
+	receiver := &webhookReceiver{}
+	hookServer := httptest.NewServer(receiver)
+	defer hookServer.Close()
+	d, _ := newTestWebhookDispatcher(t, []string{config.WebhookEventJobFinal}, hookServer.URL)
+
+	// Two replicas share the queue group, so each final update is delivered once
+	bus := messagebus.New(nc, nil)
+	for range 2 {
+		svc := NewNotificationService(
+			NewHub(WithHubLogger(slog.New(slog.DiscardHandler))),
+			bus,
+			WithLogger(slog.New(slog.DiscardHandler)),
+			WithWebhooks(d),
+		)
+		require.NoError(t, svc.Start(context.Background()))
+		defer svc.Stop()
+	}
+
+	ctx := context.Background()
+	require.NoError(t, bus.PublishJobUpdate(ctx, messagebus.JobUpdateMessage{JobID: "job-1", Status: "running"}))
+	require.NoError(t, bus.PublishJobUpdate(ctx, messagebus.JobUpdateMessage{JobID: "job-1", Status: "completed"}))
+	require.NoError(t, nc.Flush())
+
+	require.Eventually(t, func() bool { return receiver.attempts.Load() >= 1 }, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), receiver.attempts.Load(), "only the final update is delivered, by one replica")
+	bodies, _ := receiver.deliveries()
+	assert.Contains(t, string(bodies[0]), `"status":"completed"`)
+}
+
+func TestNotificationService_WebhookEvents_Integration(t *testing.T) {
+	nc, server := setupNats(t, 8444)
+	defer server.Shutdown()
+	defer nc.Close()
+
+	receiver := &webhookReceiver{}
+	hookServer := httptest.NewServer(receiver)
+	defer hookServer.Close()
+	d, m := newTestWebhookDispatcher(t, []string{config.WebhookEventJobFinal, "job.update", "task.status_update"}, hookServer.URL)
+
+	bus := messagebus.New(nc, nil)
+	svc := NewNotificationService(
+		NewHub(WithHubLogger(slog.New(slog.DiscardHandler))),
+		bus,
+		WithLogger(slog.New(slog.DiscardHandler)),
+		WithWebhooks(d),
+	)
+	require.NoError(t, svc.Start(context.Background()))
+	defer svc.Stop()
+
+	ctx := context.Background()
+	require.NoError(t, bus.PublishJobUpdate(ctx, messagebus.JobUpdateMessage{JobID: "job-1", Status: "running"}))
+	require.NoError(t, bus.PublishTaskStatusUpdate(ctx, messagebus.TaskStatusUpdateMessage{JobID: "job-1", TaskType: "extracting", Status: "completed"}))
+	require.NoError(t, bus.PublishSubTaskUpdate(ctx, messagebus.SubTaskUpdateMessage{JobID: "job-1", TaskType: "verifying_links", Key: "1"}))
+	require.NoError(t, bus.PublishJobUpdate(ctx, messagebus.JobUpdateMessage{JobID: "job-1", Status: "completed"}))
+	require.NoError(t, nc.Flush())
+
+	require.Eventually(t, func() bool { return receiver.attempts.Load() >= 3 }, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(3), receiver.attempts.Load(), "job.update supersedes job.final and subtask updates are not selected")
+	assert.Equal(t, map[string]float64{"job.update": 2, "task.status_update": 1},
+		counterValues(t, m.WebhookDeliveryAttemptsTotal, metrics.LabelMessageType))
+}
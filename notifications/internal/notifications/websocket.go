@@ -1,15 +1,21 @@
 package notifications
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
+	"shared/contract"
 	"shared/metrics"
+	"shared/models"
+	"shared/repository"
 	"slices"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/oklog/ulid/v2"
 )
 
 var upgrader = websocket.Upgrader{
@@ -20,10 +26,57 @@ var upgrader = websocket.Upgrader{
 
 // Hub manages WebSocket connections and message broadcasting
 type Hub struct {
-	connections map[*Connection]bool
-	mu          sync.RWMutex
-	metrics     *metrics.NotificationsMetrics
-	log         *slog.Logger
+	connections      map[*Connection]bool
+	groupSubscribers map[string]int
+	mu               sync.RWMutex
+	metrics          *metrics.NotificationsMetrics
+	log              *slog.Logger
+	instanceID       string
+	validateMessages bool
+	jobs             JobLookup
+	terminal         *terminalEvents
+	history          *broadcastHistory
+}
+
+// JobLookup reads jobs, the hub uses it to refuse subscriptions to deleted jobs
+type JobLookup interface {
+	GetJob(ctx context.Context, id string) (*models.Job, error)
+}
+
+// SystemGroup is the group of the messages about the whole system rather than a job, such as the analyzer load
+const SystemGroup = "system"
+
+// subscriptionLookupTimeout bounds the job lookup made for a subscription request
+const subscriptionLookupTimeout = 2 * time.Second
+
+// HubStats is a snapshot of the load on a hub
+type HubStats struct {
+	Connections int
+	Groups      int
+}
+
+// HelloAckMessageType is the type of the reply to a client's hello
+const HelloAckMessageType = "hello.ack"
+
+// HelloAckMessage tells a client which replica its connection is served by, and the ID of the connection
+// for users to report when updates go missing
+type HelloAckMessage struct {
+	Type         string `json:"type"`
+	InstanceID   string `json:"instance_id"`
+	ConnectionID string `json:"connection_id"`
+}
+
+// SubscribeRejectedMessageType is the type of the reply to a subscription the hub refused
+const SubscribeRejectedMessageType = "subscribe.rejected"
+
+// RejectReasonJobDeleted is the reason given for a subscription to a job in the trash
+const RejectReasonJobDeleted = "job_deleted"
+
+// SubscribeRejectedMessage tells a client its subscription to a group was refused, and why
+type SubscribeRejectedMessage struct {
+	Type   string `json:"type"`
+	Group  string `json:"group"`
+	Reason string `json:"reason"`
 }
 
 // HubOption configures the Hub
@@ -32,8 +85,9 @@ type HubOption func(*Hub)
 // NewHub creates a new WebSocket hub with optional configurations
 func NewHub(opts ...HubOption) *Hub {
 	h := &Hub{
-		connections: make(map[*Connection]bool),
-		log:         slog.Default(),
+		connections:      make(map[*Connection]bool),
+		groupSubscribers: make(map[string]int),
+		log:              slog.Default(),
 	}
 
 	for _, opt := range opts {
@@ -48,11 +102,52 @@ func WithHubMetrics(m *metrics.NotificationsMetrics) HubOption {
 	return func(h *Hub) { h.metrics = m }
 }
 
+// WithHubInstanceID sets the ID of the replica the hub runs on
+func WithHubInstanceID(id string) HubOption {
+	return func(h *Hub) { h.instanceID = id }
+}
+
 // WithHubLogger sets the logger for the hub
 func WithHubLogger(log *slog.Logger) HubOption {
 	return func(h *Hub) { h.log = log }
 }
 
+// WithHubMessageValidation checks every outgoing message against its schema in the contract package.
+// Violations are logged and counted but the message is still sent, it is meant for dev and staging.
+func WithHubMessageValidation(enabled bool) HubOption {
+	return func(h *Hub) { h.validateMessages = enabled }
+}
+
+// WithHubJobLookup makes the hub look up the job of each subscription and refuse the ones to deleted jobs
+func WithHubJobLookup(jobs JobLookup) HubOption {
+	return func(h *Hub) { h.jobs = jobs }
+}
+
+// rejectSubscription returns why a subscription to a group is refused, or an empty reason to accept it.
+// Groups other than SystemGroup and LifecycleGroup are job IDs, and a job that cannot be read does not block
+// its subscription.
+func (h *Hub) rejectSubscription(group string) string {
+	if h.jobs == nil || group == SystemGroup || group == LifecycleGroup {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), subscriptionLookupTimeout)
+	defer cancel()
+
+	job, err := h.jobs.GetJob(ctx, group)
+	if err != nil {
+		if !errors.Is(err, repository.ErrJobNotFound) {
+			h.log.Warn("Failed to look up job of subscription", slog.String("group", group), slog.Any("error", err))
+		}
+		return ""
+	}
+
+	if job.IsDeleted() {
+		return RejectReasonJobDeleted
+	}
+	return ""
+}
+
 // AddConnection adds a new WebSocket connection to the hub
 func (h *Hub) AddConnection(conn *Connection) {
 	h.mu.Lock()
@@ -65,27 +160,62 @@ func (h *Hub) AddConnection(conn *Connection) {
 		h.metrics.SetActiveWebSocketConnections(count)
 	}
 
-	h.log.Info("New WebSocket connection established", slog.Int("total", count))
+	h.log.Info("New WebSocket connection established",
+		slog.String("instanceId", h.instanceID),
+		slog.String("connectionId", conn.id),
+		slog.Int("total", count))
 }
 
 // RemoveConnection removes a WebSocket connection from the hub
 func (h *Hub) RemoveConnection(conn *Connection) {
 	h.mu.Lock()
+	_, exists := h.connections[conn]
 	delete(h.connections, conn)
 	count := len(h.connections)
 	h.mu.Unlock()
 
+	// A connection can be removed both by a failed broadcast and by its read loop,
+	// only the first removal releases its subscriptions
+	if exists {
+		for _, group := range conn.detachGroups() {
+			h.adjustGroupSubscribers(group, -1)
+		}
+	}
+
 	if h.metrics != nil {
 		d := time.Since(conn.start).Seconds()
 		h.metrics.RecordWebSocketConnectionDuration(d)
 		h.metrics.SetActiveWebSocketConnections(count)
 	}
 
-	h.log.Info("WebSocket connection closed", slog.Int("total", count))
+	h.log.Info("WebSocket connection closed",
+		slog.String("instanceId", h.instanceID),
+		slog.String("connectionId", conn.id),
+		slog.Int("total", count))
+}
+
+// Stats returns the current number of connections and of groups with subscribers
+func (h *Hub) Stats() HubStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return HubStats{Connections: len(h.connections), Groups: len(h.groupSubscribers)}
 }
 
 // BroadcastToGroup sends a message to all connections subscribed to a specific group
 func (h *Hub) BroadcastToGroup(msg any, group string) {
+	h.broadcast(msg, group, "")
+}
+
+// BroadcastJobUpdate sends an update of a job to every connection. Lifecycle subscribers get the slim job stream
+// in its place, unless they also subscribed to the job itself.
+func (h *Hub) BroadcastJobUpdate(msg any, jobID string) {
+	h.broadcast(msg, "", jobID)
+}
+
+// broadcast sends a message to the connections subscribed to group, or to every connection when group is empty.
+// A message about jobID skips the lifecycle subscribers not subscribed to that job.
+func (h *Hub) broadcast(msg any, group, jobID string) {
 	start := time.Now()
 
 	data, err := json.Marshal(msg)
@@ -95,6 +225,7 @@ func (h *Hub) BroadcastToGroup(msg any, group string) {
 	}
 
 	msgType := h.extractMessageType(msg)
+	h.checkContract(data)
 
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -107,6 +238,9 @@ func (h *Hub) BroadcastToGroup(msg any, group string) {
 		if group != "" && !conn.HasGroup(group) {
 			continue
 		}
+		if jobID != "" && conn.HasGroup(LifecycleGroup) && !conn.HasGroup(jobID) {
+			continue
+		}
 
 		totalCount++
 		if err := conn.WriteMessage(data); err != nil {
@@ -122,6 +256,7 @@ func (h *Hub) BroadcastToGroup(msg any, group string) {
 			}(conn)
 		} else {
 			successCount++
+			conn.recordDelivery(group, start)
 		}
 	}
 
@@ -129,6 +264,41 @@ func (h *Hub) BroadcastToGroup(msg any, group string) {
 		d := time.Since(start).Seconds()
 		h.metrics.RecordWebSocketMessage(msgType, successCount == totalCount, d)
 	}
+
+	h.recordBroadcast(BroadcastRecord{
+		Time:         start,
+		Group:        group,
+		MessageType:  msgType,
+		PayloadBytes: len(data),
+		Total:        totalCount,
+		Delivered:    successCount,
+		Failed:       totalCount - successCount,
+	}, data)
+}
+
+// checkContract reports an outgoing message that does not match its schema, when message validation is enabled
+func (h *Hub) checkContract(data []byte) {
+	if !h.validateMessages {
+		return
+	}
+
+	err := contract.Validate(data)
+	if err == nil {
+		return
+	}
+
+	messageType := "unknown"
+	var violation *contract.ViolationError
+	if errors.As(err, &violation) {
+		messageType = violation.MessageType
+	}
+
+	h.log.Warn("Outgoing message violates the contract",
+		slog.String("messageType", messageType),
+		slog.Any("error", err))
+	if h.metrics != nil {
+		h.metrics.RecordContractViolation(messageType)
+	}
 }
 
 // Broadcast sends a message to all connected clients
@@ -143,17 +313,45 @@ func (h *Hub) RecordGroupSubscription(action, group string) {
 	}
 }
 
+// adjustGroupSubscribers applies delta to the number of connections subscribed to a group
+// and updates the active subscriptions gauge
+func (h *Hub) adjustGroupSubscribers(group string, delta int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	count := max(h.groupSubscribers[group]+delta, 0)
+	if count == 0 {
+		delete(h.groupSubscribers, group)
+	} else {
+		h.groupSubscribers[group] = count
+	}
+
+	if h.metrics != nil {
+		h.metrics.SetActiveGroupSubscriptions(group, float64(count))
+		h.metrics.SetActiveGroups(len(h.groupSubscribers))
+	}
+}
+
 // Close shuts down the hub and closes all connections
 func (h *Hub) Close() {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	for conn := range h.connections {
+		conn.detachGroups()
 		conn.Close()
 	}
 
+	if h.metrics != nil {
+		for group := range h.groupSubscribers {
+			h.metrics.SetActiveGroupSubscriptions(group, 0)
+		}
+		h.metrics.SetActiveGroups(0)
+	}
+
 	h.connections = make(map[*Connection]bool)
-	h.log.Info("WebSocket hub closed")
+	h.groupSubscribers = make(map[string]int)
+	h.log.Info("WebSocket hub closed", slog.String("instanceId", h.instanceID))
 }
 
 // extractMessageType extracts the message type for metrics
@@ -170,15 +368,22 @@ func (h *Hub) extractMessageType(msg any) string {
 
 // Connection represents a WebSocket connection with group subscriptions
 type Connection struct {
-	conn   *websocket.Conn
-	groups []string
-	mu     sync.RWMutex
-	hub    *Hub
-	log    *slog.Logger
-	start  time.Time
+	// id is a ULID identifying the connection in the hello ack and the debug endpoints
+	id      string
+	conn    *websocket.Conn
+	groups  []string
+	removed bool
+	mu      sync.RWMutex
+	writeMu sync.Mutex
+	hub     *Hub
+	log     *slog.Logger
+	start   time.Time
+	// lastDelivery is when a message of each group was last written, keyed by group, guarded by mu.
+	// The empty group holds the messages broadcast to every client.
+	lastDelivery map[string]time.Time
 }
 
-// SubscriptionMessage represents a subscription/unsubscription request
+// SubscriptionMessage represents a request from the client: subscribe, unsubscribe or hello
 type SubscriptionMessage struct {
 	Action string `json:"action"`
 	Group  string `json:"group"`
@@ -187,33 +392,60 @@ type SubscriptionMessage struct {
 // NewConnection creates a new WebSocket connection wrapper
 func NewConnection(conn *websocket.Conn, hub *Hub, log *slog.Logger) *Connection {
 	return &Connection{
-		conn:   conn,
-		groups: make([]string, 0),
-		hub:    hub,
-		log:    log,
-		start:  time.Now(),
+		id:           ulid.Make().String(),
+		conn:         conn,
+		groups:       make([]string, 0),
+		hub:          hub,
+		log:          log,
+		start:        time.Now(),
+		lastDelivery: make(map[string]time.Time),
 	}
 }
 
 // AddGroup adds the connection to a subscription group
 func (c *Connection) AddGroup(group string) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	if !slices.Contains(c.groups, group) {
+	added := !c.removed && !slices.Contains(c.groups, group)
+	if added {
 		c.groups = append(c.groups, group)
 	}
+	c.mu.Unlock()
+
+	// The hub is updated after releasing the connection lock, as the hub locks connections while removing them
+	if added && c.hub != nil {
+		c.hub.adjustGroupSubscribers(group, 1)
+	}
 }
 
 // RemoveGroup removes the connection from a subscription group
 func (c *Connection) RemoveGroup(group string) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	removed := false
 	for i, g := range c.groups {
 		if g == group {
 			c.groups = append(c.groups[:i], c.groups[i+1:]...)
+			delete(c.lastDelivery, group)
+			removed = true
 			break
 		}
 	}
+	c.mu.Unlock()
+
+	if removed && c.hub != nil {
+		c.hub.adjustGroupSubscribers(group, -1)
+	}
+}
+
+// detachGroups clears the subscriptions of a connection leaving the hub and returns them.
+// Later subscription requests on the connection are ignored.
+func (c *Connection) detachGroups() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	groups := c.groups
+	c.groups = nil
+	c.removed = true
+	return groups
 }
 
 // HasGroup checks if the connection is subscribed to a group
@@ -223,8 +455,11 @@ func (c *Connection) HasGroup(group string) bool {
 	return slices.Contains(c.groups, group)
 }
 
-// WriteMessage sends a message to the WebSocket connection
+// WriteMessage sends a message to the WebSocket connection.
+// Broadcasts and replies to the client are written from different goroutines, so writes are serialized.
 func (c *Connection) WriteMessage(msg []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
 	return c.conn.WriteMessage(websocket.TextMessage, msg)
 }
 
@@ -265,14 +500,38 @@ func (c *Connection) handleSubscriptionMessage(data []byte) {
 
 	switch sub.Action {
 	case "subscribe":
+		if reason := c.hub.rejectSubscription(sub.Group); reason != "" {
+			c.log.Info("Rejected subscription for group", slog.String("group", sub.Group), slog.String("reason", reason))
+			c.sendReply(SubscribeRejectedMessage{Type: SubscribeRejectedMessageType, Group: sub.Group, Reason: reason})
+			return
+		}
 		c.AddGroup(sub.Group)
 		c.hub.RecordGroupSubscription("subscribe", sub.Group)
 		c.log.Info("Added subscription for group", slog.String("group", sub.Group))
+		c.hub.replayTerminalEvent(c, sub.Group)
 
 	case "unsubscribe":
 		c.RemoveGroup(sub.Group)
 		c.hub.RecordGroupSubscription("unsubscribe", sub.Group)
 		c.log.Info("Removed subscription for group", slog.String("group", sub.Group))
+
+	case "hello":
+		// The hello ack tells a client which replica its connection is served by, and its connection ID
+		c.sendReply(HelloAckMessage{Type: HelloAckMessageType, InstanceID: c.hub.instanceID, ConnectionID: c.id})
+	}
+}
+
+// sendReply writes a message answering a request of the client
+func (c *Connection) sendReply(msg any) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		c.log.Error("Failed to marshal reply", slog.Any("error", err))
+		return
+	}
+	c.hub.checkContract(data)
+
+	if err := c.WriteMessage(data); err != nil {
+		c.log.Error("Failed to write reply", slog.Any("error", err))
 	}
 }
 
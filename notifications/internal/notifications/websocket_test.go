@@ -0,0 +1,178 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"shared/messagebus"
+	"shared/metrics"
+	"shared/models"
+	"shared/repository"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// activeGroupSubscriptions gathers the per-group subscriptions gauge, keyed by group
+func activeGroupSubscriptions(t *testing.T, m *metrics.NotificationsMetrics) map[string]float64 {
+	t.Helper()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(m.WebSocketSubscriptionsActive)
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "group" {
+					values[label.GetValue()] = metric.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	return values
+}
+
+func TestHub_GroupSubscriptionGauge(t *testing.T) {
+	m := metrics.NewNotificationsMetrics("test")
+	log := slog.New(slog.DiscardHandler)
+	hub := NewHub(WithHubMetrics(m), WithHubLogger(log))
+
+	first := NewConnection(nil, hub, log)
+	second := NewConnection(nil, hub, log)
+	hub.AddConnection(first)
+	hub.AddConnection(second)
+
+	first.AddGroup("job-1")
+	first.AddGroup("job-1") // duplicate subscriptions are not counted twice
+	first.AddGroup("job-2")
+	second.AddGroup("job-1")
+	assert.Equal(t, map[string]float64{"job-1": 2, "job-2": 1}, activeGroupSubscriptions(t, m))
+
+	second.RemoveGroup("job-1")
+	second.RemoveGroup("job-1") // unsubscribing twice is a no-op
+	assert.Equal(t, map[string]float64{"job-1": 1, "job-2": 1}, activeGroupSubscriptions(t, m))
+
+	// Removing a connection releases all of its groups, only once
+	hub.RemoveConnection(first)
+	hub.RemoveConnection(first)
+	assert.Empty(t, activeGroupSubscriptions(t, m))
+	assert.Empty(t, hub.groupSubscribers)
+
+	// Subscriptions arriving after removal are ignored
+	first.AddGroup("job-3")
+	assert.Empty(t, activeGroupSubscriptions(t, m))
+}
+
+// contractViolations gathers the contract violations counter, keyed by message type
+func contractViolations(t *testing.T, m *metrics.NotificationsMetrics) map[string]float64 {
+	t.Helper()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(m.WebSocketContractViolationsTotal)
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == metrics.LabelMessageType {
+					values[label.GetValue()] = metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return values
+}
+
+func TestHub_MessageValidation(t *testing.T) {
+	valid := messagebus.JobUpdateMessage{Type: messagebus.JobUpdateMessageType, JobID: "job-1", Status: "running"}
+	invalid := map[string]any{"type": messagebus.JobUpdateMessageType, "jobId": "job-1", "status": "running"}
+	unknown := map[string]any{"type": "job.deleted"}
+
+	t.Run("Disabled", func(t *testing.T) {
+		m := metrics.NewNotificationsMetrics("test")
+		hub := NewHub(WithHubMetrics(m), WithHubLogger(slog.New(slog.DiscardHandler)))
+
+		hub.Broadcast(invalid)
+		assert.Empty(t, contractViolations(t, m))
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		m := metrics.NewNotificationsMetrics("test")
+		var logs bytes.Buffer
+		hub := NewHub(
+			WithHubMetrics(m),
+			WithHubLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+			WithHubMessageValidation(true),
+		)
+
+		hub.Broadcast(valid)
+		assert.Empty(t, contractViolations(t, m))
+
+		hub.BroadcastToGroup(invalid, "job-1")
+		hub.Broadcast(unknown)
+		assert.Equal(t, map[string]float64{"job.update": 1, "unknown": 1}, contractViolations(t, m))
+		assert.Contains(t, logs.String(), `missing required property \"job_id\"`)
+	})
+}
+
+// fakeJobLookup serves jobs from a map, missing IDs are not found
+type fakeJobLookup map[string]*models.Job
+
+func (f fakeJobLookup) GetJob(_ context.Context, id string) (*models.Job, error) {
+	job, ok := f[id]
+	if !ok {
+		return nil, repository.ErrJobNotFound
+	}
+	return job, nil
+}
+
+func TestHub_RejectsSubscriptionToDeletedJob(t *testing.T) {
+	deletedAt := time.Now()
+	hub := NewHub(
+		WithHubLogger(slog.New(slog.DiscardHandler)),
+		WithHubInstanceID("replica-1"),
+		WithHubMessageValidation(true),
+		WithHubJobLookup(fakeJobLookup{
+			"job-live":    {ID: "job-live"},
+			"job-deleted": {ID: "job-deleted", DeletedAt: &deletedAt},
+		}),
+	)
+	wsServer := setupWs(hub)
+	defer wsServer.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(wsServer.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+
+	require.NoError(t, conn.WriteJSON(SubscriptionMessage{Action: "subscribe", Group: "job-deleted"}))
+	var rejected SubscribeRejectedMessage
+	require.NoError(t, conn.ReadJSON(&rejected))
+	assert.Equal(t, SubscribeRejectedMessage{
+		Type:   SubscribeRejectedMessageType,
+		Group:  "job-deleted",
+		Reason: RejectReasonJobDeleted,
+	}, rejected)
+
+	// Jobs that cannot be found are not refused, they may not have been written yet
+	require.NoError(t, conn.WriteJSON(SubscriptionMessage{Action: "subscribe", Group: "job-live"}))
+	require.NoError(t, conn.WriteJSON(SubscriptionMessage{Action: "subscribe", Group: "job-unknown"}))
+
+	// Messages are handled in order, so the hello ack follows both subscriptions
+	require.NoError(t, conn.WriteJSON(SubscriptionMessage{Action: "hello"}))
+	var ack HelloAckMessage
+	require.NoError(t, conn.ReadJSON(&ack))
+	assert.Equal(t, HelloAckMessageType, ack.Type)
+
+	assert.Equal(t, 2, hub.Stats().Groups)
+}
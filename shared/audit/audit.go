@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"shared/log"
+)
+
+// Event names the job lifecycle transition an audit record is written for
+type Event string
+
+const (
+	EventJobCreated       Event = "job.created"
+	EventJobStatusChanged Event = "job.status_changed"
+	EventJobCompleted     Event = "job.completed"
+	EventJobFailed        Event = "job.failed"
+	EventJobCancelled     Event = "job.cancelled"
+	EventJobDeleted       Event = "job.deleted"
+	EventJobRestored      Event = "job.restored"
+	EventJobPurged        Event = "job.purged"
+	EventJobDiscarded     Event = "job.discarded"
+)
+
+// Source identifies the request that caused an audited transition
+type Source struct {
+	RequestID string `json:"request_id,omitempty"`
+	// SourceIP is the address of the peer that sent the request
+	SourceIP string `json:"source_ip,omitempty"`
+	// ForwardedFor is the X-Forwarded-For header of the request, as sent by the client or proxies
+	ForwardedFor string `json:"forwarded_for,omitempty"`
+}
+
+type sourceKey struct{}
+
+// WithSource returns a copy of ctx carrying the source of the audited transitions made with it
+func WithSource(ctx context.Context, source Source) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFromContext returns the source stored in the context, if any
+func SourceFromContext(ctx context.Context) Source {
+	source, _ := ctx.Value(sourceKey{}).(Source)
+	return source
+}
+
+// Record is a single audited transition of a job
+type Record struct {
+	Event  Event
+	JobID  string
+	URL    string
+	Status string
+}
+
+// Logger writes audit records as JSON lines through a handler of its own,
+// so they are kept regardless of the service log level and can be sent to a separate sink.
+// A nil Logger discards every record.
+type Logger struct {
+	log    *slog.Logger
+	closer io.Closer
+}
+
+// NewLogger creates a Logger writing to w
+func NewLogger(w io.Writer, serviceName string) *Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelInfo}).WithAttrs([]slog.Attr{
+		slog.String("logType", "audit"),
+		slog.String("service", serviceName),
+	})
+	return &Logger{log: slog.New(handler)}
+}
+
+// Open creates a Logger appending to the file at path, created if missing, or writing to stdout when path is empty
+func Open(path, serviceName string) (*Logger, error) {
+	if path == "" {
+		return NewLogger(os.Stdout, serviceName), nil
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	l := NewLogger(f, serviceName)
+	l.closer = f
+	return l, nil
+}
+
+// Record writes an audit record, attributed to the source stored in ctx
+func (l *Logger) Record(ctx context.Context, r Record) {
+	if l == nil {
+		return
+	}
+
+	source := SourceFromContext(ctx)
+	attrs := []slog.Attr{
+		slog.String("event", string(r.Event)),
+		slog.String("jobId", r.JobID),
+		slog.String("status", r.Status),
+		slog.String("requestId", source.RequestID),
+		slog.String("sourceIp", source.SourceIP),
+	}
+	if r.URL != "" {
+		attrs = append(attrs, log.URL("url", r.URL))
+	}
+	if source.ForwardedFor != "" {
+		attrs = append(attrs, slog.String("forwardedFor", source.ForwardedFor))
+	}
+
+	// The caller's context is not passed on, a cancelled request must not lose its audit record
+	l.log.LogAttrs(context.Background(), slog.LevelInfo, "audit", attrs...)
+}
+
+// Close closes the file the Logger appends to, if it opened one
+func (l *Logger) Close() error {
+	if l == nil || l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
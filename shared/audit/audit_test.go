@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger_Record(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(&buf, "api")
+
+	ctx := WithSource(context.Background(), Source{RequestID: "req-1", SourceIP: "203.0.113.7", ForwardedFor: "198.51.100.2"})
+	l.Record(ctx, Record{Event: EventJobCreated, JobID: "job-1", URL: "https://example.com", Status: "pending"})
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "audit", record["logType"])
+	assert.Equal(t, "api", record["service"])
+	assert.Equal(t, string(EventJobCreated), record["event"])
+	assert.Equal(t, "job-1", record["jobId"])
+	assert.Equal(t, "https://example.com", record["url"])
+	assert.Equal(t, "pending", record["status"])
+	assert.Equal(t, "req-1", record["requestId"])
+	assert.Equal(t, "203.0.113.7", record["sourceIp"])
+	assert.Equal(t, "198.51.100.2", record["forwardedFor"])
+	assert.NotEmpty(t, record["time"])
+}
+
+func TestLogger_RecordWithoutSource(t *testing.T) {
+	var buf bytes.Buffer
+	NewLogger(&buf, "analyzer").Record(context.Background(), Record{Event: EventJobFailed, JobID: "job-1", Status: "failed"})
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "", record["requestId"])
+	assert.NotContains(t, record, "url")
+	assert.NotContains(t, record, "forwardedFor")
+}
+
+func TestLogger_NilDiscards(t *testing.T) {
+	var l *Logger
+	assert.NotPanics(t, func() {
+		l.Record(context.Background(), Record{Event: EventJobCreated, JobID: "job-1"})
+	})
+	assert.NoError(t, l.Close())
+}
+
+func TestOpen_AppendsToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	for _, jobID := range []string{"job-1", "job-2"} {
+		l, err := Open(path, "api")
+		require.NoError(t, err)
+		l.Record(context.Background(), Record{Event: EventJobCreated, JobID: jobID, Status: "pending"})
+		require.NoError(t, l.Close())
+	}
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var jobIDs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		jobIDs = append(jobIDs, record["jobId"].(string))
+	}
+	assert.Equal(t, []string{"job-1", "job-2"}, jobIDs, "reopening the log must not truncate earlier records")
+}
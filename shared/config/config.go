@@ -1,8 +1,15 @@
 package config
 
 import (
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -12,6 +19,8 @@ import (
 type ServiceConfig struct {
 	Name    string
 	Version string
+	// InstanceID identifies the replica, defaulting to the hostname
+	InstanceID string
 }
 
 // MetricsConfig holds metrics server configuration
@@ -22,6 +31,8 @@ type MetricsConfig struct {
 // NATSConfig holds NATS connection configuration
 type NATSConfig struct {
 	URL string
+	// SlowHandlerThreshold is the message handling duration above which a warning is logged
+	SlowHandlerThreshold time.Duration
 }
 
 // TracingConfig holds tracing configuration
@@ -38,17 +49,50 @@ type DynamoDBConfig struct {
 	Endpoint        string
 	AccessKeyID     string
 	SecretAccessKey string
+	// MaxResultSize is the estimated size in bytes above which stored analysis results are trimmed
+	MaxResultSize int
+	// WaitForTables makes startup wait for the tables to be active after seeding them
+	WaitForTables bool
+	// TableWaitTimeout bounds the seeding of the tables, waiting included
+	TableWaitTimeout time.Duration
+	// ResultAttributes copies a few result fields to top-level attributes of the job item, with an index
+	// over them, so jobs can be queried by their results
+	ResultAttributes bool
+}
+
+// AdminConfig holds configuration for administrative endpoints
+type AdminConfig struct {
+	// Token is the bearer token required by admin endpoints, which are disabled when empty
+	Token string
 }
 
 // HTTPServerConfig holds HTTP server configuration
 type HTTPServerConfig struct {
-	Addr string
+	Addr         string
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// BasePath prefixes every route, e.g. "/api" when served behind a reverse proxy under that path
+	BasePath string
 }
 
 // HTTPClientConfig holds HTTP client configuration
 type HTTPClientConfig struct {
 	Timeout       time.Duration
 	MaxConcurrent int
+	// AllowedPorts lists the explicit ports outbound links may use.
+	// Links without a port use the scheme default and are always allowed.
+	AllowedPorts []int
+	// MinTLSVersion is the oldest TLS version outbound connections accept, such as 1.2
+	MinTLSVersion string
+	// CipherSuites restricts the cipher suites offered up to TLS 1.2, Go's defaults when empty
+	CipherSuites []string
+}
+
+// AuditConfig holds audit log configuration
+type AuditConfig struct {
+	// Path is the file audit records are appended to, stdout when empty
+	Path string
 }
 
 // WebSocketConfig holds WebSocket configuration
@@ -58,8 +102,97 @@ type WebSocketConfig struct {
 	WriteTimeout   int // seconds
 }
 
+// SelfProtectionConfig holds the hosts the platform itself is served from. Jobs are not accepted for them and
+// links to them are not verified, so the analyzer cannot be pointed at its own pages and feed itself jobs.
+type SelfProtectionConfig struct {
+	// PublicURLs are the URLs the platform is reached at, such as those of the UI and the API
+	PublicURLs []string
+	// OwnHosts are the hostnames of the platform, the hosts of PublicURLs by default
+	OwnHosts []string
+}
+
+// IsOwnHost reports whether host, with or without a port, is one of the own hosts.
+// Case and a trailing dot are ignored.
+func (c SelfProtectionConfig) IsOwnHost(host string) bool {
+	host = canonicalHost(host)
+	if host == "" {
+		return false
+	}
+	for _, own := range c.OwnHosts {
+		if canonicalHost(own) == host {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalHost returns host without its port, in lower case and without a trailing dot
+func canonicalHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// redactedValue replaces secrets in redacted configurations
+const redactedValue = "[REDACTED]"
+
+// Redact masks a secret value, keeping empty values empty so unset secrets remain visible
+func Redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// Redacted returns a copy of the DynamoDB configuration with credentials masked
+func (c DynamoDBConfig) Redacted() DynamoDBConfig {
+	c.AccessKeyID = Redact(c.AccessKeyID)
+	c.SecretAccessKey = Redact(c.SecretAccessKey)
+	return c
+}
+
+// Redacted returns a copy of the admin configuration with the token masked
+func (c AdminConfig) Redacted() AdminConfig {
+	c.Token = Redact(c.Token)
+	return c
+}
+
 // Common environment variable parsing functions
 
+// malformedEnv holds, by key, the environment values the last read could not parse and replaced with the default
+var (
+	malformedEnvMu sync.Mutex
+	malformedEnv   = make(map[string]string)
+)
+
+// setMalformed records or, with an empty problem, clears the problem of the value last read from key
+func setMalformed(key, problem string) {
+	malformedEnvMu.Lock()
+	defer malformedEnvMu.Unlock()
+
+	if problem == "" {
+		delete(malformedEnv, key)
+	} else {
+		malformedEnv[key] = problem
+	}
+}
+
+// MalformedEnv returns the problems of the environment values that could not be parsed, sorted by key.
+// The getters fall back to their default for these, so a typo would otherwise go unnoticed.
+func MalformedEnv() []string {
+	malformedEnvMu.Lock()
+	defer malformedEnvMu.Unlock()
+
+	keys := slices.Sorted(maps.Keys(malformedEnv))
+	problems := make([]string, 0, len(keys))
+	for _, key := range keys {
+		problems = append(problems, malformedEnv[key])
+	}
+	return problems
+}
+
 // GetEnv gets an environment variable with a default value
 func GetEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -70,32 +203,131 @@ func GetEnv(key, defaultValue string) string {
 
 // GetIntEnv gets an integer environment variable with a default value
 func GetIntEnv(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
+	value := os.Getenv(key)
+	if value == "" {
+		setMalformed(key, "")
+		return defaultValue
 	}
-	return defaultValue
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		setMalformed(key, fmt.Sprintf("%s must be an integer, got %q", key, value))
+		return defaultValue
+	}
+	setMalformed(key, "")
+	return intValue
 }
 
 // GetDurationEnv gets a duration environment variable with a default value
 func GetDurationEnv(key string, defaultValue time.Duration) time.Duration {
-	if value := os.Getenv(key); value != "" {
-		if duration, err := time.ParseDuration(value); err == nil {
-			return duration
-		}
+	value := os.Getenv(key)
+	if value == "" {
+		setMalformed(key, "")
+		return defaultValue
 	}
-	return defaultValue
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		setMalformed(key, fmt.Sprintf("%s must be a duration such as 30s, got %q", key, value))
+		return defaultValue
+	}
+	setMalformed(key, "")
+	return duration
+}
+
+// GetFloatEnv gets a float environment variable with a default value
+func GetFloatEnv(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		setMalformed(key, "")
+		return defaultValue
+	}
+	floatValue, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		setMalformed(key, fmt.Sprintf("%s must be a number, got %q", key, value))
+		return defaultValue
+	}
+	setMalformed(key, "")
+	return floatValue
 }
 
 // GetBoolEnv gets a boolean environment variable with a default value
 func GetBoolEnv(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		if boolValue, err := strconv.ParseBool(value); err == nil {
-			return boolValue
+	value := os.Getenv(key)
+	if value == "" {
+		setMalformed(key, "")
+		return defaultValue
+	}
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		setMalformed(key, fmt.Sprintf("%s must be true or false, got %q", key, value))
+		return defaultValue
+	}
+	setMalformed(key, "")
+	return boolValue
+}
+
+// GetStringSliceEnv gets a comma-separated list environment variable with a default value.
+// Items are trimmed and empty items are dropped.
+func GetStringSliceEnv(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
 		}
 	}
-	return defaultValue
+	return result
+}
+
+// GetIntSliceEnv gets a comma-separated list of integers from an environment variable with a default value.
+// Items that are not integers are dropped and reported by MalformedEnv.
+func GetIntSliceEnv(key string, defaultValue []int) []int {
+	setMalformed(key, "")
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var result []int
+	for _, item := range strings.Split(value, ",") {
+		intValue, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			setMalformed(key, fmt.Sprintf("%s must list integers, got %q", key, value))
+			continue
+		}
+		result = append(result, intValue)
+	}
+	return result
+}
+
+// GetDurationMapEnv gets a map of durations from an environment variable with a default value.
+// The value is a comma-separated list of key=duration pairs, e.g. "GET /jobs=5s,POST /analyze=10s".
+// Malformed pairs are ignored and reported by MalformedEnv.
+func GetDurationMapEnv(key string, defaultValue map[string]time.Duration) map[string]time.Duration {
+	setMalformed(key, "")
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	result := make(map[string]time.Duration)
+	for _, pair := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			setMalformed(key, fmt.Sprintf("%s must list key=duration pairs, got %q", key, value))
+			continue
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			setMalformed(key, fmt.Sprintf("%s must list key=duration pairs, got %q", key, value))
+			continue
+		}
+		result[strings.TrimSpace(k)] = duration
+	}
+	return result
 }
 
 // Common configuration builders
@@ -105,9 +337,20 @@ func NewServiceConfig(serviceName string) ServiceConfig {
 	return ServiceConfig{
 		Name:    GetEnv("SERVICE_NAME", serviceName),
 		Version: GetEnv("SERVICE_VERSION", "1.0.0"),
+
+		InstanceID: GetEnv("SERVICE_INSTANCE_ID", hostname()),
 	}
 }
 
+// hostname returns the host name, or "unknown" when it cannot be determined
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "unknown"
+	}
+	return name
+}
+
 // NewMetricsConfig creates a MetricsConfig with common defaults
 func NewMetricsConfig(defaultPort string) MetricsConfig {
 	return MetricsConfig{
@@ -118,7 +361,8 @@ func NewMetricsConfig(defaultPort string) MetricsConfig {
 // NewNATSConfig creates a NATSConfig with common defaults
 func NewNATSConfig() NATSConfig {
 	return NATSConfig{
-		URL: GetEnv("NATS_URL", "nats://localhost:4222"),
+		URL:                  GetEnv("NATS_URL", "nats://localhost:4222"),
+		SlowHandlerThreshold: GetDurationEnv("NATS_SLOW_HANDLER_THRESHOLD", time.Second),
 	}
 }
 
@@ -132,10 +376,21 @@ func NewTracingConfig(serviceName string) TracingConfig {
 	}
 }
 
+// NewAdminConfig creates an AdminConfig from the environment
+func NewAdminConfig() AdminConfig {
+	return AdminConfig{
+		Token: GetEnv("ADMIN_TOKEN", ""),
+	}
+}
+
 // NewHTTPServerConfig creates an HTTPServerConfig with common defaults
 func NewHTTPServerConfig(defaultAddr string) HTTPServerConfig {
 	return HTTPServerConfig{
-		Addr: GetEnv("HTTP_ADDR", defaultAddr),
+		Addr:         GetEnv("HTTP_ADDR", defaultAddr),
+		ReadTimeout:  GetDurationEnv("HTTP_READ_TIMEOUT", 15*time.Second),
+		WriteTimeout: GetDurationEnv("HTTP_WRITE_TIMEOUT", 15*time.Second),
+		IdleTimeout:  GetDurationEnv("HTTP_IDLE_TIMEOUT", 60*time.Second),
+		BasePath:     GetEnv("HTTP_BASE_PATH", ""),
 	}
 }
 
@@ -144,6 +399,16 @@ func NewHTTPClientConfig() HTTPClientConfig {
 	return HTTPClientConfig{
 		Timeout:       GetDurationEnv("HTTP_CLIENT_TIMEOUT", 20*time.Second),
 		MaxConcurrent: GetIntEnv("HTTP_MAX_CONCURRENT", 10),
+		AllowedPorts:  GetIntSliceEnv("HTTP_ALLOWED_PORTS", []int{80, 443}),
+		MinTLSVersion: GetEnv("HTTP_MIN_TLS_VERSION", "1.2"),
+		CipherSuites:  GetStringSliceEnv("HTTP_TLS_CIPHER_SUITES", nil),
+	}
+}
+
+// NewAuditConfig creates an AuditConfig from the environment
+func NewAuditConfig() AuditConfig {
+	return AuditConfig{
+		Path: GetEnv("AUDIT_LOG_PATH", ""),
 	}
 }
 
@@ -156,12 +421,32 @@ func NewWebSocketConfig() WebSocketConfig {
 	}
 }
 
+// NewSelfProtectionConfig creates a SelfProtectionConfig from the environment
+func NewSelfProtectionConfig() SelfProtectionConfig {
+	publicURLs := GetStringSliceEnv("PUBLIC_URLS", nil)
+	var hosts []string
+	for _, raw := range publicURLs {
+		if u, err := url.Parse(raw); err == nil && u.Hostname() != "" {
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+
+	return SelfProtectionConfig{
+		PublicURLs: publicURLs,
+		OwnHosts:   GetStringSliceEnv("OWN_HOSTS", hosts),
+	}
+}
+
 // NewDynamoDBConfig creates a DynamoDBConfig with common defaults
 func NewDynamoDBConfig() DynamoDBConfig {
 	return DynamoDBConfig{
-		Region:          GetEnv("DYNAMODB_REGION", "us-east-1"),
-		Endpoint:        GetEnv("DYNAMODB_ENDPOINT", "http://localhost:8000"),
-		AccessKeyID:     GetEnv("DYNAMODB_ACCESS_KEY_ID", "DUMMYIDEXAMPLE"),
-		SecretAccessKey: GetEnv("DYNAMODB_SECRET_ACCESS_KEY", "DUMMYIDEXAMPLE"),
+		Region:           GetEnv("DYNAMODB_REGION", "us-east-1"),
+		Endpoint:         GetEnv("DYNAMODB_ENDPOINT", "http://localhost:8000"),
+		AccessKeyID:      GetEnv("DYNAMODB_ACCESS_KEY_ID", "DUMMYIDEXAMPLE"),
+		SecretAccessKey:  GetEnv("DYNAMODB_SECRET_ACCESS_KEY", "DUMMYIDEXAMPLE"),
+		MaxResultSize:    GetIntEnv("DYNAMODB_MAX_RESULT_SIZE", 350*1024),
+		WaitForTables:    GetBoolEnv("DYNAMODB_WAIT_FOR_TABLES", true),
+		TableWaitTimeout: GetDurationEnv("DYNAMODB_TABLE_WAIT_TIMEOUT", 30*time.Second),
+		ResultAttributes: GetBoolEnv("DYNAMODB_RESULT_ATTRIBUTES", false),
 	}
 }
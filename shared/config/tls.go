@@ -0,0 +1,59 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// tlsVersions maps the TLS versions accepted by HTTP_MIN_TLS_VERSION to their crypto/tls values
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion parses a TLS version written as 1.0, 1.1, 1.2 or 1.3
+func ParseTLSVersion(name string) (uint16, error) {
+	version, ok := tlsVersions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q, expected 1.0, 1.1, 1.2 or 1.3", name)
+	}
+	return version, nil
+}
+
+// ParseCipherSuites parses cipher suites by their IANA names, such as TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256.
+// Suites Go considers insecure are accepted too, for legacy sites that offer nothing else.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// TLSConfig returns the TLS settings of outbound connections. The cipher suites only restrict TLS 1.2 and
+// older, TLS 1.3 suites are not configurable. Without suites, Go's defaults apply.
+func (c HTTPClientConfig) TLSConfig() (*tls.Config, error) {
+	version, err := ParseTLSVersion(c.MinTLSVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{MinVersion: version}
+	if len(c.CipherSuites) > 0 {
+		if cfg.CipherSuites, err = ParseCipherSuites(c.CipherSuites); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
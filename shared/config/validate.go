@@ -0,0 +1,225 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxTimeout bounds the timeouts and intervals of the configurations, longer ones are taken for a unit mistake
+const maxTimeout = 24 * time.Hour
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Validator collects the problems of a configuration, so they are all reported at once instead of one per start.
+// Problems name the environment variable the value comes from.
+type Validator struct {
+	problems []string
+}
+
+// NewValidator creates a validator holding the environment values that could not be parsed
+func NewValidator() *Validator {
+	return &Validator{problems: MalformedEnv()}
+}
+
+// Addf records a problem
+func (v *Validator) Addf(format string, args ...any) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+// Check records a problem unless ok
+func (v *Validator) Check(ok bool, format string, args ...any) {
+	if !ok {
+		v.Addf(format, args...)
+	}
+}
+
+// Err returns a *ValidationError listing the problems recorded, nil when there are none
+func (v *Validator) Err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// Duration records a problem unless d is positive and no longer than maxTimeout
+func (v *Validator) Duration(key string, d time.Duration) {
+	v.Check(d > 0 && d <= maxTimeout, "%s must be positive and at most %s, got %s", key, maxTimeout, d)
+}
+
+// Positive records a problem unless d is positive, for retention periods that may well span days
+func (v *Validator) Positive(key string, d time.Duration) {
+	v.Check(d > 0, "%s must be positive, got %s", key, d)
+}
+
+// OptionalDuration is Duration allowing zero, which turns the option off
+func (v *Validator) OptionalDuration(key string, d time.Duration) {
+	v.Check(d >= 0 && d <= maxTimeout, "%s must be zero or positive and at most %s, got %s", key, maxTimeout, d)
+}
+
+// Port records a problem unless port is a number between 1 and 65535
+func (v *Validator) Port(key, port string) {
+	p, err := strconv.Atoi(port)
+	v.Check(err == nil && validPort(p), "%s must be a port between 1 and 65535, got %q", key, port)
+}
+
+// Addr records a problem unless addr is a host and port to listen on, such as ":8080"
+func (v *Validator) Addr(key, addr string) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		v.Addf("%s must be a host and port such as :8080, got %q", key, addr)
+		return
+	}
+	v.Port(key, port)
+}
+
+// URL records a problem unless raw is an absolute URL with one of the schemes
+func (v *Validator) URL(key, raw string, schemes ...string) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || !slices.Contains(schemes, u.Scheme) {
+		v.Addf("%s must be a %s URL, got %q", key, strings.Join(schemes, " or "), redactURL(raw))
+	}
+}
+
+func validPort(port int) bool {
+	return port >= 1 && port <= 65535
+}
+
+// Check records the problems of the service configuration
+func (c ServiceConfig) Check(v *Validator) {
+	v.Check(c.Name != "", "SERVICE_NAME must not be empty")
+}
+
+// Check records the problems of the metrics configuration
+func (c MetricsConfig) Check(v *Validator) {
+	v.Port("METRICS_PORT", c.Port)
+}
+
+// Check records the problems of the NATS configuration, the URL may list several servers separated by commas
+func (c NATSConfig) Check(v *Validator) {
+	for _, server := range strings.Split(c.URL, ",") {
+		v.URL("NATS_URL", strings.TrimSpace(server), "nats", "tls", "ws", "wss")
+	}
+	v.Duration("NATS_SLOW_HANDLER_THRESHOLD", c.SlowHandlerThreshold)
+}
+
+// Check records the problems of the self-protection configuration, own hosts are names without a scheme or path
+func (c SelfProtectionConfig) Check(v *Validator) {
+	for _, raw := range c.PublicURLs {
+		v.URL("PUBLIC_URLS", raw, "http", "https")
+	}
+	for _, host := range c.OwnHosts {
+		v.Check(!strings.ContainsAny(host, "/?#@"), "OWN_HOSTS must list hostnames, got %q", host)
+	}
+}
+
+// Check records the problems of the tracing configuration
+func (c TracingConfig) Check(v *Validator) {
+	v.URL("ZIPKIN_ENDPOINT", c.ZipkinEndpoint, "http", "https")
+}
+
+// Check records the problems of the DynamoDB configuration, an empty endpoint uses the AWS one of the region
+func (c DynamoDBConfig) Check(v *Validator) {
+	v.Check(c.Region != "", "DYNAMODB_REGION must not be empty")
+	if c.Endpoint != "" {
+		v.URL("DYNAMODB_ENDPOINT", c.Endpoint, "http", "https")
+	}
+	v.Check(c.MaxResultSize > 0, "DYNAMODB_MAX_RESULT_SIZE must be positive, got %d", c.MaxResultSize)
+	if c.WaitForTables {
+		v.Duration("DYNAMODB_TABLE_WAIT_TIMEOUT", c.TableWaitTimeout)
+	}
+}
+
+// Check records the problems of the HTTP server configuration
+func (c HTTPServerConfig) Check(v *Validator) {
+	v.Addr("HTTP_ADDR", c.Addr)
+	v.Duration("HTTP_READ_TIMEOUT", c.ReadTimeout)
+	v.Duration("HTTP_WRITE_TIMEOUT", c.WriteTimeout)
+	v.Duration("HTTP_IDLE_TIMEOUT", c.IdleTimeout)
+}
+
+// Check records the problems of the HTTP client configuration
+func (c HTTPClientConfig) Check(v *Validator) {
+	v.Duration("HTTP_CLIENT_TIMEOUT", c.Timeout)
+	v.Check(c.MaxConcurrent > 0, "HTTP_MAX_CONCURRENT must be positive, got %d", c.MaxConcurrent)
+	for _, port := range c.AllowedPorts {
+		v.Check(validPort(port), "HTTP_ALLOWED_PORTS must list ports between 1 and 65535, got %d", port)
+	}
+	if _, err := ParseTLSVersion(c.MinTLSVersion); err != nil {
+		v.Addf("HTTP_MIN_TLS_VERSION must be 1.0, 1.1, 1.2 or 1.3, got %q", c.MinTLSVersion)
+	}
+	if _, err := ParseCipherSuites(c.CipherSuites); err != nil {
+		v.Addf("HTTP_TLS_CIPHER_SUITES must list cipher suites by name: %s", err)
+	}
+}
+
+// Check records the problems of the WebSocket configuration
+func (c WebSocketConfig) Check(v *Validator) {
+	v.Check(c.MaxConnections > 0, "WS_MAX_CONNECTIONS must be positive, got %d", c.MaxConnections)
+	v.Check(c.ReadTimeout > 0, "WS_READ_TIMEOUT must be a positive number of seconds, got %d", c.ReadTimeout)
+	v.Check(c.WriteTimeout > 0, "WS_WRITE_TIMEOUT must be a positive number of seconds, got %d", c.WriteTimeout)
+}
+
+// Redacted returns a copy of the NATS configuration with the passwords in its URL masked
+func (c NATSConfig) Redacted() NATSConfig {
+	c.URL = redactURL(c.URL)
+	return c
+}
+
+// redactURL masks the credentials of a URL, or of each URL of a comma-separated list.
+// A user without a password is masked too, as NATS takes a bare token in its place.
+func redactURL(raw string) string {
+	servers := strings.Split(raw, ",")
+	for i, server := range servers {
+		u, err := url.Parse(strings.TrimSpace(server))
+		if err != nil || u.User == nil {
+			continue
+		}
+		if _, ok := u.User.Password(); !ok {
+			u.User = url.User("xxxxx")
+		}
+		servers[i] = u.Redacted()
+	}
+	return strings.Join(servers, ",")
+}
+
+// PrintRequested reports whether the effective configuration should be printed instead of starting the service,
+// asked for with the --print-config argument or CONFIG_PRINT=true
+func PrintRequested(args []string) bool {
+	return slices.Contains(args, "--print-config") || slices.Contains(args, "-print-config") ||
+		GetBoolEnv("CONFIG_PRINT", false)
+}
+
+// Print writes a configuration as indented JSON, callers pass it redacted
+func Print(w io.Writer, cfg any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(cfg)
+}
+
+// LogProblems logs every problem of a *ValidationError on its own line, other errors as they are
+func LogProblems(log *slog.Logger, err error) {
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		log.Error("Invalid configuration", slog.Any("error", err))
+		return
+	}
+	for _, problem := range invalid.Problems {
+		log.Error("Invalid configuration", slog.String("problem", problem))
+	}
+}
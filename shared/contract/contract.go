@@ -0,0 +1,107 @@
+// Package contract holds the JSON schemas of the messages sent to websocket clients.
+// The schema files under schemas are the contract between the analyzer, the notifications service and the browser,
+// a change to a message shape must be made to its schema as well.
+package contract
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// MaxMessageSize is the largest message that may be sent to a client.
+// It is the default NATS max payload, which every message relayed from the bus already had to fit.
+const MaxMessageSize = 1024 * 1024
+
+// ErrUnknownMessageType is returned for a message whose type has no schema
+var ErrUnknownMessageType = errors.New("unknown message type")
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// schemas holds the schema of every client-visible message type, keyed by type
+var schemas = mustLoadSchemas()
+
+// ViolationError lists the ways a message breaks the schema of its type
+type ViolationError struct {
+	MessageType string
+	Violations  []string
+}
+
+func (e *ViolationError) Error() string {
+	return fmt.Sprintf("%s message violates its schema: %s", e.MessageType, strings.Join(e.Violations, "; "))
+}
+
+// MessageTypes returns the message types that have a schema, sorted
+func MessageTypes() []string {
+	types := make([]string, 0, len(schemas))
+	for messageType := range schemas {
+		types = append(types, messageType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// SchemaFor returns the schema of a message type, nil when it has none
+func SchemaFor(messageType string) *Schema {
+	return schemas[messageType]
+}
+
+// Validate checks an encoded message against the schema named by its "type" property.
+// A message that does not match is reported with a *ViolationError.
+func Validate(data []byte) error {
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("invalid message: %w", err)
+	}
+
+	schema, ok := schemas[envelope.Type]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownMessageType, envelope.Type)
+	}
+
+	var violations []string
+	if len(data) > MaxMessageSize {
+		violations = append(violations, fmt.Sprintf("message is %d bytes, more than the %d allowed", len(data), MaxMessageSize))
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid message: %w", err)
+	}
+	violations = schema.validate("$", value, violations)
+
+	if len(violations) > 0 {
+		return &ViolationError{MessageType: envelope.Type, Violations: violations}
+	}
+	return nil
+}
+
+// mustLoadSchemas parses the embedded schema files, each named after its message type
+func mustLoadSchemas() map[string]*Schema {
+	entries, err := schemaFiles.ReadDir("schemas")
+	if err != nil {
+		panic("contract: " + err.Error())
+	}
+
+	loaded := make(map[string]*Schema, len(entries))
+	for _, entry := range entries {
+		data, err := schemaFiles.ReadFile(path.Join("schemas", entry.Name()))
+		if err != nil {
+			panic("contract: " + err.Error())
+		}
+
+		var schema Schema
+		if err := json.Unmarshal(data, &schema); err != nil {
+			panic(fmt.Sprintf("contract: invalid schema %s: %v", entry.Name(), err))
+		}
+		loaded[strings.TrimSuffix(entry.Name(), ".json")] = &schema
+	}
+	return loaded
+}
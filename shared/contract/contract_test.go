@@ -0,0 +1,330 @@
+package contract
+
+import (
+	"encoding/json"
+	"reflect"
+	"shared/messagebus"
+	"shared/models"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func marshal(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return data
+}
+
+func TestValidate_RepresentativeMessages(t *testing.T) {
+	progress := 42.5
+	assetLength := int64(1258291)
+
+	testCases := map[string]any{
+		"JobUpdateStatusOnly": messagebus.JobUpdateMessage{
+			Type:   messagebus.JobUpdateMessageType,
+			JobID:  "job-1",
+			Status: string(models.JobStatusRunning),
+		},
+		"JobUpdateProgress": messagebus.JobUpdateMessage{
+			Type:     messagebus.JobUpdateMessageType,
+			JobID:    "job-1",
+			Status:   string(models.JobStatusRunning),
+			URL:      "https://example.com",
+			Progress: &progress,
+		},
+		"JobUpdateFailure": messagebus.JobUpdateMessage{
+			Type:            messagebus.JobUpdateMessageType,
+			JobID:           "job-1",
+			Status:          string(models.JobStatusFailed),
+			FailureCode:     models.FailureCodeFetch,
+			FailureReason:   "The page could not be fetched: 404 Not Found",
+			FetchStatusCode: 404,
+		},
+		"JobUpdateEmptyResult": messagebus.JobUpdateMessage{
+			Type:   messagebus.JobUpdateMessageType,
+			JobID:  "job-1",
+			Status: string(models.JobStatusFailed),
+			Result: &models.AnalyzeResult{PartialResult: true},
+		},
+		"JobUpdateFullResult": messagebus.JobUpdateMessage{
+			Type:   messagebus.JobUpdateMessageType,
+			JobID:  "job-1",
+			Status: string(models.JobStatusCompleted),
+			Result: &models.AnalyzeResult{
+				HtmlVersion:                "HTML5",
+				PageTitle:                  "Example",
+				Headings:                   map[string]int{"h1": 1, "h2": 3},
+				Links:                      []string{"https://example.com/a"},
+				InternalLinkCount:          1,
+				AccessibleLinks:            1,
+				HasLoginForm:               true,
+				InsecureForms:              []models.InsecureForm{{Action: "http://example.com/login", Reason: models.InsecureFormHTTPAction}},
+				ImageCount:                 2,
+				AccessibleImages:           2,
+				InternalLinkDepthHistogram: map[string]int{"1": 1},
+				MaxInternalLinkDepth:       1,
+				ResponseHeaders:            map[string]string{"Server": "nginx"},
+				LikelyClientSideRendered:   true,
+				Warnings:                   []models.Warning{{Code: models.WarningClientSideRendered, Message: "shell"}},
+				ContentEncoding:            "gzip",
+				TransferredBytes:           2048,
+				ContentBytes:               16384,
+				RedirectChain:              []string{"http://example.com", "https://example.com/"},
+				FetchTiming: &models.FetchTiming{
+					DNSSeconds:             0.012,
+					ConnectSeconds:         0.031,
+					TLSHandshakeSeconds:    0.045,
+					TimeToFirstByteSeconds: 0.21,
+					DownloadSeconds:        0.08,
+					TotalSeconds:           0.29,
+				},
+				SkippedTasks:   []models.TaskType{models.TaskTypeVerifyingLinks},
+				Truncated:      true,
+				LinksTruncated: true,
+				AssetLinks:     map[string]int{"application/pdf": 2},
+				LinkClassifications: []models.LinkClassification{
+					{URL: "https://example.com/a", Reason: models.LinkReasonSameOrigin},
+				},
+				OriginalLinks:     []string{"https://example.com/a?utm_source=news"},
+				CollapsedLinks:    1,
+				TrackersDetected:  []string{"Google Analytics"},
+				TrackerCount:      1,
+				CanonicalURL:      "https://example.com/other",
+				CanonicalMismatch: true,
+				OpenGraphURL:      "https://example.com/other",
+				CanonicalConsistency: &models.CanonicalConsistency{
+					Reason: models.CanonicalReasonDifferentURL,
+				},
+				IsAMP: true,
+				Pagination: &models.Pagination{
+					NextURL: "https://example.com/docs/3",
+					PrevURL: "https://example.com/docs/1",
+					Pages: []models.PaginationPage{
+						{URL: "https://example.com/docs/2", Headings: map[string]int{"h1": 1}, LinkCount: 1},
+						{URL: "https://example.com/docs/3", Headings: map[string]int{"h2": 3}, LinkOffset: 1},
+					},
+					StopReason: models.PaginationStopMaxPages,
+				},
+				NoIndex:  true,
+				NoFollow: true,
+			},
+			Progress: &progress,
+		},
+		"JobUpdateSampledResult": messagebus.JobUpdateMessage{
+			Type:   messagebus.JobUpdateMessageType,
+			JobID:  "job-1",
+			Status: string(models.JobStatusCompleted),
+			Result: &models.AnalyzeResult{
+				AccessibleLinks:            40,
+				InaccessibleLinks:          2,
+				UnverifiedLinks:            150,
+				SampledVerification:        true,
+				SampleFraction:             0.25,
+				EstimatedAccessibleLinks:   180,
+				EstimatedInaccessibleLinks: 12,
+				Warnings:                   []models.Warning{{Code: models.WarningSampledVerification, Message: "sampled"}},
+				PartialResult:              true,
+			},
+			Progress: &progress,
+		},
+		"TaskStatus": messagebus.TaskStatusUpdateMessage{
+			Type:     messagebus.TaskStatusUpdateMessageType,
+			JobID:    "job-1",
+			TaskType: string(models.TaskTypeAnalyzing),
+			Status:   string(models.TaskStatusSkipped),
+		},
+		"TaskStatusProgress": messagebus.TaskStatusUpdateMessage{
+			Type:     messagebus.TaskStatusUpdateMessageType,
+			JobID:    "job-1",
+			TaskType: string(models.TaskTypeVerifyingLinks),
+			Status:   string(models.TaskStatusRunning),
+			Progress: &messagebus.TaskProgress{Completed: 120, Total: 500, Accessible: 97, Inaccessible: 18},
+		},
+		"SubTask": messagebus.SubTaskUpdateMessage{
+			Type:     messagebus.SubTaskUpdateMessageType,
+			JobID:    "job-1",
+			TaskType: string(models.TaskTypeVerifyingLinks),
+			Key:      "3",
+			SubTask: models.SubTask{
+				Type:        models.SubTaskTypeValidatingImage,
+				Status:      models.TaskStatusFailed,
+				URL:         "https://example.com/logo.png",
+				Description: "HTTP 404",
+			},
+		},
+		"SubTaskAsset": messagebus.SubTaskUpdateMessage{
+			Type:     messagebus.SubTaskUpdateMessageType,
+			JobID:    "job-1",
+			TaskType: string(models.TaskTypeVerifyingLinks),
+			Key:      "4",
+			SubTask: models.SubTask{
+				Type:          models.SubTaskTypeValidatingLink,
+				Status:        models.TaskStatusCompleted,
+				URL:           "https://example.com/report.pdf",
+				Description:   "HTTP 200 (application/pdf, 1.2 MiB)",
+				ContentType:   "application/pdf",
+				ContentLength: &assetLength,
+			},
+		},
+		"SubTaskConnectionError": messagebus.SubTaskUpdateMessage{
+			Type:     messagebus.SubTaskUpdateMessageType,
+			JobID:    "job-1",
+			TaskType: string(models.TaskTypeVerifyingLinks),
+			Key:      "5",
+			SubTask: models.SubTask{
+				Type:        models.SubTaskTypeValidatingLink,
+				Status:      models.TaskStatusFailed,
+				URL:         "https://unreachable.example.com",
+				Description: "Connection refused",
+				ErrorCode:   models.LinkErrorConnectionRefused,
+			},
+		},
+		"AnalyzerLoad": messagebus.AnalyzerLoadMessage{
+			Type:               messagebus.AnalyzerLoadMessageType,
+			InstanceID:         "analyzer-1",
+			QueueDepth:         12,
+			InFlight:           1,
+			AvgWaitSeconds:     3.5,
+			AvgDurationSeconds: 8.25,
+			SentAt:             time.Now().UTC(),
+		},
+		// Sent by the notifications service, whose message types cannot be imported here
+		"JobLifecycle": map[string]any{
+			"type":      "job.lifecycle",
+			"job_id":    "job-1",
+			"status":    string(models.JobStatusRunning),
+			"url":       "https://example.com",
+			"progress":  progress,
+			"timestamp": time.Now().UTC(),
+		},
+	}
+
+	for name, msg := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, Validate(marshal(t, msg)))
+		})
+	}
+}
+
+func TestValidate_Violations(t *testing.T) {
+	testCases := []struct {
+		name      string
+		message   string
+		violation string
+	}{
+		{
+			name:      "RenamedField",
+			message:   `{"type":"job.update","jobId":"job-1","status":"running"}`,
+			violation: `missing required property "job_id"`,
+		},
+		{
+			name:      "UnexpectedField",
+			message:   `{"type":"job.update","job_id":"job-1","status":"running","eta":3}`,
+			violation: "$.eta: unexpected property",
+		},
+		{
+			name:      "UnknownStatus",
+			message:   `{"type":"job.update","job_id":"job-1","status":"done"}`,
+			violation: "$.status: done is not one of",
+		},
+		{
+			name:      "ProgressOutOfRange",
+			message:   `{"type":"job.update","job_id":"job-1","status":"running","progress":120}`,
+			violation: "$.progress: 120 is greater than 100",
+		},
+		{
+			name:      "WrongType",
+			message:   `{"type":"task.status_update","job_id":"job-1","task_type":"analyzing","status":"running","progress":{"completed":"1","total":2}}`,
+			violation: "$.progress.completed: expected [integer], got string",
+		},
+		{
+			name:      "NestedField",
+			message:   `{"type":"task.subtask_update","job_id":"job-1","task_type":"verifying_links","key":"1","subtask":{"type":"validating_link","status":"running","url":"https://example.com"}}`,
+			violation: `$.subtask: missing required property "description"`,
+		},
+		{
+			name:      "TooLarge",
+			message:   `{"type":"hello.ack","instance_id":"` + strings.Repeat("x", MaxMessageSize) + `"}`,
+			violation: "more than the 1048576 allowed",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate([]byte(tc.message))
+
+			var violation *ViolationError
+			require.ErrorAs(t, err, &violation)
+			assert.Contains(t, strings.Join(violation.Violations, "\n"), tc.violation)
+		})
+	}
+
+	assert.ErrorIs(t, Validate([]byte(`{"type":"job.deleted"}`)), ErrUnknownMessageType)
+	assert.Error(t, Validate([]byte(`not json`)))
+}
+
+// jsonFields returns the JSON property names of a struct type
+func jsonFields(structType reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := range structType.NumField() {
+		field := structType.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// assertPropertiesMatch checks that a schema declares exactly the JSON fields of a struct, recursing into nested structs
+func assertPropertiesMatch(t *testing.T, path string, schema *Schema, structType reflect.Type) {
+	fields := jsonFields(structType)
+
+	var names, properties []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	for name := range schema.Properties {
+		properties = append(properties, name)
+	}
+	sort.Strings(names)
+	sort.Strings(properties)
+	assert.Equal(t, names, properties, "%s: schema properties must match the struct's JSON fields", path)
+
+	for name, fieldType := range fields {
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if property := schema.Properties[name]; property != nil && fieldType.Kind() == reflect.Struct {
+			assertPropertiesMatch(t, path+"."+name, property, fieldType)
+		}
+	}
+}
+
+func TestSchemas_MatchMessageStructs(t *testing.T) {
+	messages := map[messagebus.MessageType]any{
+		messagebus.JobUpdateMessageType:        messagebus.JobUpdateMessage{},
+		messagebus.TaskStatusUpdateMessageType: messagebus.TaskStatusUpdateMessage{},
+		messagebus.SubTaskUpdateMessageType:    messagebus.SubTaskUpdateMessage{},
+		messagebus.AnalyzerLoadMessageType:     messagebus.AnalyzerLoadMessage{},
+	}
+
+	for messageType, msg := range messages {
+		schema := SchemaFor(string(messageType))
+		if assert.NotNil(t, schema, "no schema for %s", messageType) {
+			assertPropertiesMatch(t, string(messageType), schema, reflect.TypeOf(msg))
+		}
+	}
+
+	assert.Equal(t, []string{"analyzer.load", "hello.ack", "job.lifecycle", "job.update", "subscribe.rejected", "task.status_update", "task.subtask_update"}, MessageTypes())
+}
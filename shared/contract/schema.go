@@ -0,0 +1,152 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+)
+
+// Schema is the subset of JSON Schema the contract files use:
+// type, enum, properties, required, additionalProperties, items, minimum and maximum
+type Schema struct {
+	Type                 schemaTypes        `json:"type"`
+	Enum                 []any              `json:"enum"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *additional        `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+}
+
+// schemaTypes is the "type" keyword, given as a single type or a list of types
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("type must be a string or a list of strings: %w", err)
+	}
+	*t = list
+	return nil
+}
+
+// additional is the "additionalProperties" keyword, either a boolean or a schema for the extra values
+type additional struct {
+	allowed bool
+	schema  *Schema
+}
+
+func (a *additional) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.allowed); err == nil {
+		return nil
+	}
+
+	a.allowed = true
+	a.schema = &Schema{}
+	return json.Unmarshal(data, a.schema)
+}
+
+// validate appends a violation for every way value, decoded from JSON, does not match the schema
+func (s *Schema) validate(path string, value any, violations []string) []string {
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(t string) bool { return hasType(value, t) }) {
+		return append(violations, fmt.Sprintf("%s: expected %v, got %s", path, []string(s.Type), typeOf(value)))
+	}
+
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(v any) bool { return reflect.DeepEqual(v, value) }) {
+		violations = append(violations, fmt.Sprintf("%s: %v is not one of %v", path, value, s.Enum))
+	}
+
+	switch v := value.(type) {
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			violations = append(violations, fmt.Sprintf("%s: %v is less than %v", path, v, *s.Minimum))
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			violations = append(violations, fmt.Sprintf("%s: %v is greater than %v", path, v, *s.Maximum))
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				violations = s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, violations)
+			}
+		}
+	case map[string]any:
+		violations = s.validateObject(path, v, violations)
+	}
+
+	return violations
+}
+
+// validateObject checks the required, declared and additional properties of an object
+func (s *Schema) validateObject(path string, object map[string]any, violations []string) []string {
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			violations = append(violations, fmt.Sprintf("%s: missing required property %q", path, name))
+		}
+	}
+
+	// Sorted, so violations are reported in a stable order
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		propertyPath := path + "." + name
+		if property, ok := s.Properties[name]; ok {
+			violations = property.validate(propertyPath, object[name], violations)
+			continue
+		}
+
+		switch {
+		case s.AdditionalProperties == nil:
+		case !s.AdditionalProperties.allowed:
+			violations = append(violations, fmt.Sprintf("%s: unexpected property", propertyPath))
+		case s.AdditionalProperties.schema != nil:
+			violations = s.AdditionalProperties.schema.validate(propertyPath, object[name], violations)
+		}
+	}
+
+	return violations
+}
+
+// hasType reports whether a value decoded from JSON is of the JSON Schema type
+func hasType(value any, schemaType string) bool {
+	switch v := value.(type) {
+	case nil:
+		return schemaType == "null"
+	case bool:
+		return schemaType == "boolean"
+	case string:
+		return schemaType == "string"
+	case float64:
+		return schemaType == "number" || (schemaType == "integer" && v == math.Trunc(v))
+	case []any:
+		return schemaType == "array"
+	case map[string]any:
+		return schemaType == "object"
+	default:
+		return false
+	}
+}
+
+// typeOf names the JSON type of a decoded value
+func typeOf(value any) string {
+	for _, t := range []string{"null", "boolean", "string", "integer", "number", "array", "object"} {
+		if hasType(value, t) {
+			return t
+		}
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -1,6 +1,7 @@
 package log
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
 	"strings"
@@ -8,21 +9,29 @@ import (
 
 const (
 	EnvLogLevel     = "LOG_LEVEL"
+	EnvLogFormat    = "LOG_FORMAT"
 	DefaultLogLevel = slog.LevelInfo
 )
 
+// level is the level of the loggers built by Setup, so it can be changed while the service runs
+var level = new(slog.LevelVar)
+
 type Opts struct {
 	ServiceName string
 	Level       slog.Level
 	AddSource   bool
 	JSON        bool
+	// RedactURLs strips query values from the URLs logged through URL and Error
+	RedactURLs bool
 }
 
 func Setup(o Opts) *slog.Logger {
 	var handler slog.Handler
 
+	level.Set(o.Level)
+	SetRedactURLs(o.RedactURLs)
 	opts := &slog.HandlerOptions{
-		Level:     o.Level,
+		Level:     level,
 		AddSource: o.AddSource,
 	}
 
@@ -46,26 +55,47 @@ func SetupFromEnv(serviceName string) *slog.Logger {
 		ServiceName: serviceName,
 		Level:       GetLogLevelFromEnv(),
 		AddSource:   GetLogLevelFromEnv() <= slog.LevelDebug, // When debug, add source file/line info
-		JSON:        true,
+		JSON:        !strings.EqualFold(os.Getenv(EnvLogFormat), "text"),
+		RedactURLs:  GetRedactURLsFromEnv(),
 	})
 }
 
-func GetLogLevelFromEnv() slog.Level {
-	levelStr := os.Getenv(EnvLogLevel)
-	if levelStr == "" {
-		return DefaultLogLevel
-	}
+// Level returns the active level of the loggers built by Setup
+func Level() slog.Level {
+	return level.Level()
+}
+
+// SetLevel changes the level of the loggers built by Setup, taking effect on their next record.
+// Whether source info is added stays as decided at startup.
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
 
-	switch strings.ToUpper(levelStr) {
+// ParseLevel parses debug, info, warn or error, in any case
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
 	case "DEBUG":
-		return slog.LevelDebug
+		return slog.LevelDebug, nil
 	case "INFO":
-		return slog.LevelInfo
+		return slog.LevelInfo, nil
 	case "WARN":
-		return slog.LevelWarn
+		return slog.LevelWarn, nil
 	case "ERROR":
-		return slog.LevelError
+		return slog.LevelError, nil
 	default:
+		return DefaultLogLevel, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", s)
+	}
+}
+
+func GetLogLevelFromEnv() slog.Level {
+	levelStr := os.Getenv(EnvLogLevel)
+	if levelStr == "" {
+		return DefaultLogLevel
+	}
+
+	l, err := ParseLevel(levelStr)
+	if err != nil {
 		return DefaultLogLevel
 	}
+	return l
 }
@@ -0,0 +1,88 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	EnvRedactURLs = "LOG_REDACT_URLS"
+	// MaxPayloadBytes is how much of a message payload is logged, such as of one that could not be unmarshalled
+	MaxPayloadBytes = 512
+)
+
+// redactURLs is set when the URLs logged through URL and Error have their query values stripped
+var redactURLs atomic.Bool
+
+// SetRedactURLs turns the redaction of logged URLs on or off
+func SetRedactURLs(redact bool) {
+	redactURLs.Store(redact)
+}
+
+// RedactsURLs reports whether logged URLs are redacted
+func RedactsURLs() bool {
+	return redactURLs.Load()
+}
+
+// GetRedactURLsFromEnv reads LOG_REDACT_URLS, which defaults to on in production
+// and off elsewhere, where full URLs help debugging
+func GetRedactURLsFromEnv() bool {
+	if redact, err := strconv.ParseBool(os.Getenv(EnvRedactURLs)); err == nil {
+		return redact
+	}
+	return strings.EqualFold(os.Getenv("DEPLOYMENT_ENVIRONMENT"), "production")
+}
+
+// RedactURL strips the values of a URL's query parameters, which may carry tokens, keeping their names.
+// A password in the URL is masked and the fragment dropped. A URL that cannot be parsed loses all past the path.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		path, _, _ := strings.Cut(raw, "?")
+		path, _, _ = strings.Cut(path, "#")
+		return path
+	}
+
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		for i, param := range params {
+			name, _, _ := strings.Cut(param, "=")
+			params[i] = name + "="
+		}
+		u.RawQuery = strings.Join(params, "&")
+	}
+	u.Fragment, u.RawFragment = "", ""
+	return u.Redacted()
+}
+
+// Truncate shortens data to its first n bytes, noting how long it was
+func Truncate(data string, n int) string {
+	if len(data) <= n {
+		return data
+	}
+	return fmt.Sprintf("%s... (%d bytes)", data[:n], len(data))
+}
+
+// URL returns an attribute for a URL, redacted when logged URLs are
+func URL(key, raw string) slog.Attr {
+	if RedactsURLs() {
+		raw = RedactURL(raw)
+	}
+	return slog.String(key, raw)
+}
+
+// Error returns the error attribute of err. When logged URLs are redacted, the URL of a failed request
+// that the error quotes is redacted in its message.
+func Error(err error) slog.Attr {
+	var urlErr *url.Error
+	if err == nil || !RedactsURLs() || !errors.As(err, &urlErr) {
+		return slog.Any("error", err)
+	}
+	return slog.String("error", strings.ReplaceAll(err.Error(), urlErr.URL, RedactURL(urlErr.URL)))
+}
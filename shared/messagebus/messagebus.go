@@ -4,11 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"shared/audit"
 	"shared/models"
 	"shared/tracing"
 	"time"
 
 	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 //go:generate mockgen -destination=../mocks/mock_messagebus.go -package=mocks . MessageBusInterface
@@ -20,8 +22,15 @@ type MessageBusInterface interface {
 	PublishSubTaskUpdate(ctx context.Context, m SubTaskUpdateMessage) error
 	SubscribeToAnalyzeMessage(handler func(ctx context.Context, m *nats.Msg)) (*nats.Subscription, error)
 	SubscribeToJobUpdate(handler func(ctx context.Context, m *nats.Msg)) (*nats.Subscription, error)
+	QueueSubscribeToJobUpdate(queue string, handler func(ctx context.Context, m *nats.Msg)) (*nats.Subscription, error)
 	SubscribeToTaskStatusUpdate(handler func(ctx context.Context, m *nats.Msg)) (*nats.Subscription, error)
+	QueueSubscribeToTaskStatusUpdate(queue string, handler func(ctx context.Context, m *nats.Msg)) (*nats.Subscription, error)
 	SubscribeToSubTaskUpdate(handler func(ctx context.Context, m *nats.Msg)) (*nats.Subscription, error)
+	QueueSubscribeToSubTaskUpdate(queue string, handler func(ctx context.Context, m *nats.Msg)) (*nats.Subscription, error)
+	PublishPresence(ctx context.Context, m PresenceMessage) error
+	SubscribeToPresence(handler func(ctx context.Context, m *nats.Msg)) (*nats.Subscription, error)
+	PublishAnalyzerLoad(ctx context.Context, m AnalyzerLoadMessage) error
+	SubscribeToAnalyzerLoad(handler func(ctx context.Context, m *nats.Msg)) (*nats.Subscription, error)
 }
 
 type MessageType string
@@ -31,11 +40,18 @@ const (
 	JobUpdateMessageType        MessageType = "job.update"
 	TaskStatusUpdateMessageType MessageType = "task.status_update"
 	SubTaskUpdateMessageType    MessageType = "task.subtask_update"
+	PresenceMessageType         MessageType = "notifications.presence"
+	AnalyzerLoadMessageType     MessageType = "analyzer.load"
 )
 
 type AnalyzeMessage struct {
 	Type  MessageType `json:"type"`
 	JobId string      `json:"job_id"`
+	// Source identifies the request that submitted the job, for the audit records of its transitions
+	Source audit.Source `json:"source"`
+	// AttemptID identifies this request to analyze the job, each publish of the job gets a new one.
+	// The analysis stores it on the job when it starts and only finishes the job while it is still the job's attempt.
+	AttemptID string `json:"attempt_id,omitempty"`
 }
 
 type JobUpdateMessage struct {
@@ -43,13 +59,32 @@ type JobUpdateMessage struct {
 	JobID  string                `json:"job_id"`
 	Status string                `json:"status"`
 	Result *models.AnalyzeResult `json:"result,omitempty"`
+	// URL is the page the job analyzes
+	URL string `json:"url,omitempty"`
+	// Progress is the share of the job done in percent, set on progress updates and once the job finishes
+	Progress *float64 `json:"progress,omitempty"`
+	// FailureCode and FailureReason tell why the job failed, set on the failed update
+	FailureCode   models.FailureCode `json:"failure_code,omitempty"`
+	FailureReason string             `json:"failure_reason,omitempty"`
+	// FetchStatusCode is the HTTP status the page was served with, set on the completed and failed updates
+	FetchStatusCode int `json:"fetch_status_code,omitempty"`
 }
 
 type TaskStatusUpdateMessage struct {
-	Type     MessageType `json:"type"`
-	JobID    string      `json:"job_id"`
-	TaskType string      `json:"task_type"`
-	Status   string      `json:"status"`
+	Type     MessageType   `json:"type"`
+	JobID    string        `json:"job_id"`
+	TaskType string        `json:"task_type"`
+	Status   string        `json:"status"`
+	Progress *TaskProgress `json:"progress,omitempty"`
+}
+
+// TaskProgress is the number of finished subtasks of a running task.
+// Accessible and Inaccessible are the running tallies of the links verified so far, set for verifying_links.
+type TaskProgress struct {
+	Completed    int `json:"completed"`
+	Total        int `json:"total"`
+	Accessible   int `json:"accessible,omitempty"`
+	Inaccessible int `json:"inaccessible,omitempty"`
 }
 
 type SubTaskUpdateMessage struct {
@@ -60,21 +95,65 @@ type SubTaskUpdateMessage struct {
 	SubTask  models.SubTask `json:"subtask"`
 }
 
+// PresenceMessage is the heartbeat a notifications replica publishes with its current load
+type PresenceMessage struct {
+	Type        MessageType `json:"type"`
+	InstanceID  string      `json:"instance_id"`
+	Connections int         `json:"connections"`
+	Groups      int         `json:"groups"`
+	SentAt      time.Time   `json:"sent_at"`
+}
+
+// AnalyzerLoadMessage is the heartbeat an analyzer replica publishes with its backlog
+type AnalyzerLoadMessage struct {
+	Type       MessageType `json:"type"`
+	InstanceID string      `json:"instance_id"`
+	// QueueDepth is the number of analyze messages received but not started yet
+	QueueDepth int `json:"queue_depth"`
+	// InFlight is the number of jobs being analyzed
+	InFlight int `json:"in_flight"`
+	// AvgWaitSeconds is the rolling average time from submission to the start of an analysis
+	AvgWaitSeconds float64 `json:"avg_wait_seconds"`
+	// AvgDurationSeconds is the rolling average time an analysis takes
+	AvgDurationSeconds float64   `json:"avg_duration_seconds"`
+	SentAt             time.Time `json:"sent_at"`
+}
+
+// PublishedAtHeader carries the publish timestamp (RFC 3339, nanosecond precision) of a message
+const PublishedAtHeader = "Published-At"
+
 // MessageBus provides a NATS message bus for publishing and subscribing to messages
 type MessageBus struct {
-	nc      *nats.Conn
-	metrics MetricsCollector
+	nc                   *nats.Conn
+	metrics              MetricsCollector
+	slowHandlerThreshold time.Duration
+}
+
+// Option configures the MessageBus
+type Option func(*MessageBus)
+
+// WithSlowHandlerThreshold sets the handling duration above which a warning is logged, 0 disables it
+func WithSlowHandlerThreshold(threshold time.Duration) Option {
+	return func(b *MessageBus) {
+		b.slowHandlerThreshold = threshold
+	}
 }
 
 // New creates a new message bus
-func New(nc *nats.Conn, metrics MetricsCollector) *MessageBus {
+func New(nc *nats.Conn, metrics MetricsCollector, opts ...Option) *MessageBus {
 	if metrics == nil {
 		metrics = NoOpMetricsCollector{}
 	}
-	return &MessageBus{
+	b := &MessageBus{
 		nc:      nc,
 		metrics: metrics,
 	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
 }
 
 func (b *MessageBus) PublishAnalyzeMessage(ctx context.Context, m AnalyzeMessage) (err error) {
@@ -156,6 +235,46 @@ func (b *MessageBus) PublishSubTaskUpdate(ctx context.Context, m SubTaskUpdateMe
 	return err
 }
 
+// PublishPresence publishes a notifications replica heartbeat to NATS
+func (b *MessageBus) PublishPresence(ctx context.Context, m PresenceMessage) (err error) {
+	defer func() {
+		b.metrics.RecordNATSPublish(string(PresenceMessageType), err == nil)
+	}()
+
+	m.Type = PresenceMessageType
+	data, err := json.Marshal(m)
+	if err != nil {
+		log.Printf("Failed to marshal presence message: %v", err)
+		return err
+	}
+
+	err = b.publishMsg(ctx, data, PresenceMessageType)
+	if err != nil {
+		log.Printf("Failed to publish presence message: %v", err)
+	}
+	return err
+}
+
+// PublishAnalyzerLoad publishes an analyzer replica heartbeat to NATS
+func (b *MessageBus) PublishAnalyzerLoad(ctx context.Context, m AnalyzerLoadMessage) (err error) {
+	defer func() {
+		b.metrics.RecordNATSPublish(string(AnalyzerLoadMessageType), err == nil)
+	}()
+
+	m.Type = AnalyzerLoadMessageType
+	data, err := json.Marshal(m)
+	if err != nil {
+		log.Printf("Failed to marshal analyzer load message: %v", err)
+		return err
+	}
+
+	err = b.publishMsg(ctx, data, AnalyzerLoadMessageType)
+	if err != nil {
+		log.Printf("Failed to publish analyzer load message: %v", err)
+	}
+	return err
+}
+
 // publishMsg publishes a message to NATS with trace context in headers
 func (b *MessageBus) publishMsg(ctx context.Context, data []byte, messageType MessageType) (err error) {
 	ctx, span := tracing.CreateNATSPublishSpan(ctx, string(messageType))
@@ -168,6 +287,7 @@ func (b *MessageBus) publishMsg(ctx context.Context, data []byte, messageType Me
 	}
 
 	tracing.InjectNATSHeaders(ctx, msg)
+	msg.Header.Set(PublishedAtHeader, time.Now().UTC().Format(time.RFC3339Nano))
 
 	err = b.nc.PublishMsg(msg)
 	if err != nil {
@@ -188,18 +308,49 @@ func (b *MessageBus) SubscribeToJobUpdate(handler func(ctx context.Context, m *n
 	return b.nc.Subscribe(string(JobUpdateMessageType), h)
 }
 
+// QueueSubscribeToJobUpdate subscribes to the job update message as a member of the queue group,
+// each update is handled by one member of the group only
+func (b *MessageBus) QueueSubscribeToJobUpdate(queue string, handler func(ctx context.Context, m *nats.Msg)) (*nats.Subscription, error) {
+	h := b.wrapHandler(JobUpdateMessageType, handler)
+	return b.nc.QueueSubscribe(string(JobUpdateMessageType), queue, h)
+}
+
 // SubscribeToTaskStatusUpdate subscribes to the task status update message
 func (b *MessageBus) SubscribeToTaskStatusUpdate(handler func(ctx context.Context, m *nats.Msg)) (*nats.Subscription, error) {
 	h := b.wrapHandler(TaskStatusUpdateMessageType, handler)
 	return b.nc.Subscribe(string(TaskStatusUpdateMessageType), h)
 }
 
+// QueueSubscribeToTaskStatusUpdate subscribes to the task status update message as a member of the queue group
+func (b *MessageBus) QueueSubscribeToTaskStatusUpdate(queue string, handler func(ctx context.Context, m *nats.Msg)) (*nats.Subscription, error) {
+	h := b.wrapHandler(TaskStatusUpdateMessageType, handler)
+	return b.nc.QueueSubscribe(string(TaskStatusUpdateMessageType), queue, h)
+}
+
 // SubscribeToSubTaskUpdate subscribes to the subtask update message
 func (b *MessageBus) SubscribeToSubTaskUpdate(handler func(ctx context.Context, m *nats.Msg)) (*nats.Subscription, error) {
 	h := b.wrapHandler(SubTaskUpdateMessageType, handler)
 	return b.nc.Subscribe(string(SubTaskUpdateMessageType), h)
 }
 
+// QueueSubscribeToSubTaskUpdate subscribes to the subtask update message as a member of the queue group
+func (b *MessageBus) QueueSubscribeToSubTaskUpdate(queue string, handler func(ctx context.Context, m *nats.Msg)) (*nats.Subscription, error) {
+	h := b.wrapHandler(SubTaskUpdateMessageType, handler)
+	return b.nc.QueueSubscribe(string(SubTaskUpdateMessageType), queue, h)
+}
+
+// SubscribeToPresence subscribes to the notifications replica heartbeats
+func (b *MessageBus) SubscribeToPresence(handler func(ctx context.Context, m *nats.Msg)) (*nats.Subscription, error) {
+	h := b.wrapHandler(PresenceMessageType, handler)
+	return b.nc.Subscribe(string(PresenceMessageType), h)
+}
+
+// SubscribeToAnalyzerLoad subscribes to the analyzer replica heartbeats
+func (b *MessageBus) SubscribeToAnalyzerLoad(handler func(ctx context.Context, m *nats.Msg)) (*nats.Subscription, error) {
+	h := b.wrapHandler(AnalyzerLoadMessageType, handler)
+	return b.nc.Subscribe(string(AnalyzerLoadMessageType), h)
+}
+
 // wrapHandler wraps the original handler to automatically inject trace context and record receive metrics
 func (b *MessageBus) wrapHandler(messageType MessageType, handler func(ctx context.Context, m *nats.Msg)) nats.MsgHandler {
 	return func(m *nats.Msg) {
@@ -208,17 +359,45 @@ func (b *MessageBus) wrapHandler(messageType MessageType, handler func(ctx conte
 		defer span.End()
 
 		start := time.Now()
+
+		age, hasAge := messageAge(m, start)
+		if hasAge {
+			b.metrics.RecordNATSMessageAge(string(messageType), age)
+			span.SetAttributes(attribute.Float64("messagebus.message_age_seconds", age.Seconds()))
+		}
+
 		defer func() {
+			duration := time.Since(start)
+			if b.slowHandlerThreshold > 0 && duration > b.slowHandlerThreshold {
+				log.Printf("Slow handler for %s: took %s (message age %s)", m.Subject, duration, age)
+			}
+
 			if r := recover(); r != nil {
 				// If handler panics, record as error
-				b.metrics.RecordNATSReceive(string(messageType), time.Since(start), false)
+				b.metrics.RecordNATSReceive(string(messageType), duration, false)
 				panic(r)
 			} else {
 				// Record successful processing
-				b.metrics.RecordNATSReceive(string(messageType), time.Since(start), true)
+				b.metrics.RecordNATSReceive(string(messageType), duration, true)
 			}
 		}()
 
 		handler(ctx, m)
 	}
 }
+
+// messageAge returns the time elapsed since the message was published,
+// or false when the publisher did not set the timestamp header
+func messageAge(m *nats.Msg, now time.Time) (time.Duration, bool) {
+	if m.Header == nil {
+		return 0, false
+	}
+
+	publishedAt, err := time.Parse(time.RFC3339Nano, m.Header.Get(PublishedAtHeader))
+	if err != nil {
+		return 0, false
+	}
+
+	// Clock skew between hosts can make the age negative
+	return max(now.Sub(publishedAt), 0), true
+}
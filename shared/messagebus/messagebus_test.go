@@ -0,0 +1,121 @@
+package messagebus
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingMetrics captures message age observations
+type recordingMetrics struct {
+	NoOpMetricsCollector
+
+	mu   sync.Mutex
+	ages map[string][]time.Duration
+}
+
+func (r *recordingMetrics) RecordNATSMessageAge(messageType string, age time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ages[messageType] = append(r.ages[messageType], age)
+}
+
+func (r *recordingMetrics) observations(messageType string) []time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]time.Duration(nil), r.ages[messageType]...)
+}
+
+func setupBus(t *testing.T, port int) (*MessageBus, *recordingMetrics) {
+	opts := natsserver.DefaultTestOptions
+	opts.Port = port
+	server := natsserver.RunServer(&opts)
+	t.Cleanup(server.Shutdown)
+
+	nc, err := nats.Connect("nats://127.0.0.1:" + strconv.Itoa(port))
+	require.NoError(t, err, "Should connect to NATS")
+	t.Cleanup(nc.Close)
+
+	metrics := &recordingMetrics{ages: make(map[string][]time.Duration)}
+	return New(nc, metrics, WithSlowHandlerThreshold(time.Second)), metrics
+}
+
+func TestMessageBus_MessageAge(t *testing.T) {
+	bus, metrics := setupBus(t, 8410)
+
+	received := make(chan *nats.Msg, 1)
+	sub, err := bus.SubscribeToJobUpdate(func(ctx context.Context, m *nats.Msg) {
+		received <- m
+	})
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	before := time.Now()
+	require.NoError(t, bus.PublishJobUpdate(context.Background(), JobUpdateMessage{JobID: "job-1", Status: "running"}))
+
+	var msg *nats.Msg
+	select {
+	case msg = <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for job update")
+	}
+
+	publishedAt, err := time.Parse(time.RFC3339Nano, msg.Header.Get(PublishedAtHeader))
+	require.NoError(t, err, "Published-At header should round trip")
+	assert.WithinDuration(t, before, publishedAt, time.Second)
+
+	// The observation is recorded before the handler runs
+	ages := metrics.observations(string(JobUpdateMessageType))
+	require.Len(t, ages, 1)
+	assert.GreaterOrEqual(t, ages[0], time.Duration(0))
+	assert.Less(t, ages[0], time.Second)
+}
+
+func TestMessageAge(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		name        string
+		header      nats.Header
+		expectedAge time.Duration
+		expectedOK  bool
+	}{
+		{
+			name:       "MissingHeaders",
+			header:     nil,
+			expectedOK: false,
+		},
+		{
+			name:       "MalformedTimestamp",
+			header:     nats.Header{PublishedAtHeader: []string{"yesterday"}},
+			expectedOK: false,
+		},
+		{
+			name:        "ValidTimestamp",
+			header:      nats.Header{PublishedAtHeader: []string{now.Add(-3 * time.Second).Format(time.RFC3339Nano)}},
+			expectedAge: 3 * time.Second,
+			expectedOK:  true,
+		},
+		{
+			name:        "ClockSkew",
+			header:      nats.Header{PublishedAtHeader: []string{now.Add(time.Second).Format(time.RFC3339Nano)}},
+			expectedAge: 0,
+			expectedOK:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			age, ok := messageAge(&nats.Msg{Header: tc.header}, now)
+			assert.Equal(t, tc.expectedOK, ok)
+			assert.Equal(t, tc.expectedAge, age)
+		})
+	}
+}
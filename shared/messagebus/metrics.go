@@ -5,6 +5,7 @@ import "time"
 type MetricsCollector interface {
 	RecordNATSPublish(messageType string, success bool)
 	RecordNATSReceive(messageType string, duration time.Duration, success bool)
+	RecordNATSMessageAge(messageType string, age time.Duration)
 }
 
 type NoOpMetricsCollector struct{}
@@ -12,3 +13,4 @@ type NoOpMetricsCollector struct{}
 func (n NoOpMetricsCollector) RecordNATSPublish(messageType string, success bool) {}
 func (n NoOpMetricsCollector) RecordNATSReceive(messageType string, duration time.Duration, success bool) {
 }
+func (n NoOpMetricsCollector) RecordNATSMessageAge(messageType string, age time.Duration) {}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 
@@ -14,11 +15,20 @@ const (
 // AnalyzerMetricsInterface is an interface for analyzer metrics
 type AnalyzerMetricsInterface interface {
 	MustRegisterAnalyzer()
-	RecordAnalysisJob(success bool, duration float64)
+	RecordAnalysisJob(ctx context.Context, success bool, duration float64)
 	RecordAnalysisTask(taskType string, success bool, duration float64)
-	RecordLinkVerification(success bool, duration float64)
+	RecordLinkVerification(ctx context.Context, success bool, duration float64)
+	RecordLinkVerificationError(code string)
 	RecordHTTPClientRequest(statusCode int, duration float64, method, requestType string)
+	RecordContentFetchAttempt(attempt int, outcome string)
+	RecordContentFetchSize(encoding string, transferred, decoded int64)
+	RecordContentFetchPhase(phase string, duration float64)
 	SetConcurrentLinkVerifications(count int)
+	RecordSuppressedSubTaskEvent(granularity string)
+	RecordInvalidTaskType()
+	RecordHostRateLimitWait(requestType string, wait float64)
+	RecordDNSCacheLookup(outcome string)
+	RecordStaleJobWrite(write string)
 }
 
 // NoOpAnalyzerMetrics is a no-op implementation of AnalyzerMetricsInterface
@@ -29,17 +39,29 @@ func NewNoOpAnalyzerMetrics() AnalyzerMetricsInterface {
 	return &NoOpAnalyzerMetrics{}
 }
 
-func (n *NoOpAnalyzerMetrics) MustRegisterAnalyzer()                       {}
-func (n *NoOpAnalyzerMetrics) SetServiceInfo(version, goVersion string)    {}
-func (n *NoOpAnalyzerMetrics) StartMetricsServer(port string) *http.Server { return nil }
-func (n *NoOpAnalyzerMetrics) RecordAnalysisJob(success bool, duration float64) {
+func (n *NoOpAnalyzerMetrics) MustRegisterAnalyzer()                    {}
+func (n *NoOpAnalyzerMetrics) SetServiceInfo(version, goVersion string) {}
+func (n *NoOpAnalyzerMetrics) StartMetricsServer(port string, routes ...Route) *http.Server {
+	return nil
+}
+func (n *NoOpAnalyzerMetrics) RecordAnalysisJob(ctx context.Context, success bool, duration float64) {
 }
 func (n *NoOpAnalyzerMetrics) RecordAnalysisTask(taskType string, success bool, duration float64) {}
-func (n *NoOpAnalyzerMetrics) RecordLinkVerification(success bool, duration float64) {
+func (n *NoOpAnalyzerMetrics) RecordLinkVerification(ctx context.Context, success bool, duration float64) {
 }
+func (n *NoOpAnalyzerMetrics) RecordLinkVerificationError(code string) {}
 func (n *NoOpAnalyzerMetrics) RecordHTTPClientRequest(statusCode int, duration float64, method, requestType string) {
 }
-func (n *NoOpAnalyzerMetrics) SetConcurrentLinkVerifications(count int) {}
+func (n *NoOpAnalyzerMetrics) RecordContentFetchAttempt(attempt int, outcome string) {}
+func (n *NoOpAnalyzerMetrics) RecordContentFetchSize(encoding string, transferred, decoded int64) {
+}
+func (n *NoOpAnalyzerMetrics) RecordContentFetchPhase(phase string, duration float64)   {}
+func (n *NoOpAnalyzerMetrics) SetConcurrentLinkVerifications(count int)                 {}
+func (n *NoOpAnalyzerMetrics) RecordSuppressedSubTaskEvent(granularity string)          {}
+func (n *NoOpAnalyzerMetrics) RecordInvalidTaskType()                                   {}
+func (n *NoOpAnalyzerMetrics) RecordHostRateLimitWait(requestType string, wait float64) {}
+func (n *NoOpAnalyzerMetrics) RecordDNSCacheLookup(outcome string)                      {}
+func (n *NoOpAnalyzerMetrics) RecordStaleJobWrite(write string)                         {}
 
 type AnalyzerMetrics struct {
 	*ServiceMetrics
@@ -52,9 +74,25 @@ type AnalyzerMetrics struct {
 	LinksVerifiedTotal          *prometheus.CounterVec
 	LinkVerificationDuration    *prometheus.HistogramVec
 	ConcurrentLinkVerifications prometheus.Gauge
+	LinkVerificationErrorsTotal *prometheus.CounterVec
 
 	HTTPClientRequestsTotal   *prometheus.CounterVec
 	HTTPClientRequestDuration *prometheus.HistogramVec
+
+	ContentFetchAttemptsTotal    *prometheus.CounterVec
+	ContentFetchTransferredBytes *prometheus.HistogramVec
+	ContentFetchDecodedBytes     *prometheus.HistogramVec
+	ContentFetchPhaseDuration    *prometheus.HistogramVec
+
+	SuppressedSubTaskEventsTotal *prometheus.CounterVec
+	InvalidTaskTypesTotal        prometheus.Counter
+
+	HostRateLimitedRequestsTotal *prometheus.CounterVec
+	HostRateLimitWaitDuration    *prometheus.HistogramVec
+
+	DNSCacheLookupsTotal *prometheus.CounterVec
+
+	StaleJobWritesTotal *prometheus.CounterVec
 }
 
 // NewAnalyzerMetrics creates a new analyzer metrics
@@ -121,6 +159,15 @@ func NewAnalyzerMetrics() *AnalyzerMetrics {
 			[]string{"outcome"},
 		),
 
+		LinkVerificationErrorsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "link_verification_errors_total",
+				Help:        "Total number of links that got no response when verified, by error code",
+				ConstLabels: prometheus.Labels{LabelService: analyzerServiceName},
+			},
+			[]string{"code"},
+		),
+
 		ConcurrentLinkVerifications: prometheus.NewGauge(
 			prometheus.GaugeOpts{
 				Name:        "concurrent_link_verifications",
@@ -147,6 +194,99 @@ func NewAnalyzerMetrics() *AnalyzerMetrics {
 			},
 			[]string{LabelMethod, LabelRequestType},
 		),
+
+		ContentFetchAttemptsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "content_fetch_attempts_total",
+				Help:        "Total number of page content fetch attempts",
+				ConstLabels: prometheus.Labels{LabelService: analyzerServiceName},
+			},
+			[]string{"attempt", "outcome"},
+		),
+
+		ContentFetchTransferredBytes: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "content_fetch_transferred_bytes",
+				Help:        "Size of fetched page bodies as received, before decoding",
+				Buckets:     prometheus.ExponentialBuckets(1024, 4, 10),
+				ConstLabels: prometheus.Labels{LabelService: analyzerServiceName},
+			},
+			[]string{"encoding"},
+		),
+
+		ContentFetchDecodedBytes: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "content_fetch_decoded_bytes",
+				Help:        "Size of fetched page bodies once decoded",
+				Buckets:     prometheus.ExponentialBuckets(1024, 4, 10),
+				ConstLabels: prometheus.Labels{LabelService: analyzerServiceName},
+			},
+			[]string{"encoding"},
+		),
+
+		ContentFetchPhaseDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "content_fetch_phase_seconds",
+				Help:        "Time spent in each phase of successful page content fetches in seconds",
+				Buckets:     prometheus.DefBuckets,
+				ConstLabels: prometheus.Labels{LabelService: analyzerServiceName},
+			},
+			[]string{"phase"},
+		),
+
+		SuppressedSubTaskEventsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "subtask_events_suppressed_total",
+				Help:        "Total number of subtask update messages not published due to the event granularity",
+				ConstLabels: prometheus.Labels{LabelService: analyzerServiceName},
+			},
+			[]string{"granularity"},
+		),
+
+		InvalidTaskTypesTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name:        "invalid_task_types_total",
+				Help:        "Total number of task updates refused because the task type is not registered",
+				ConstLabels: prometheus.Labels{LabelService: analyzerServiceName},
+			},
+		),
+
+		HostRateLimitedRequestsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "host_rate_limited_requests_total",
+				Help:        "Total number of outbound HTTP requests delayed by the per-host rate limit",
+				ConstLabels: prometheus.Labels{LabelService: analyzerServiceName},
+			},
+			[]string{LabelRequestType},
+		),
+
+		HostRateLimitWaitDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "host_rate_limit_wait_seconds",
+				Help:        "Time delayed requests waited for the per-host rate limit in seconds",
+				Buckets:     prometheus.DefBuckets,
+				ConstLabels: prometheus.Labels{LabelService: analyzerServiceName},
+			},
+			[]string{LabelRequestType},
+		),
+
+		DNSCacheLookupsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "dns_cache_lookups_total",
+				Help:        "Total number of host lookups of outbound connections by whether the DNS cache answered them",
+				ConstLabels: prometheus.Labels{LabelService: analyzerServiceName},
+			},
+			[]string{"outcome"},
+		),
+
+		StaleJobWritesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "stale_job_writes_total",
+				Help:        "Total number of job writes dropped because the job finished or was started again by another analysis",
+				ConstLabels: prometheus.Labels{LabelService: analyzerServiceName},
+			},
+			[]string{"write"},
+		),
 	}
 
 	return analyzerMetrics
@@ -164,20 +304,31 @@ func (m *AnalyzerMetrics) MustRegisterAnalyzer() {
 		m.LinksVerifiedTotal,
 		m.LinkVerificationDuration,
 		m.ConcurrentLinkVerifications,
+		m.LinkVerificationErrorsTotal,
 		m.HTTPClientRequestsTotal,
 		m.HTTPClientRequestDuration,
+		m.ContentFetchAttemptsTotal,
+		m.ContentFetchTransferredBytes,
+		m.ContentFetchDecodedBytes,
+		m.ContentFetchPhaseDuration,
+		m.SuppressedSubTaskEventsTotal,
+		m.InvalidTaskTypesTotal,
+		m.HostRateLimitedRequestsTotal,
+		m.HostRateLimitWaitDuration,
+		m.DNSCacheLookupsTotal,
+		m.StaleJobWritesTotal,
 	)
 }
 
-// RecordAnalysisJob records the analysis job metrics
-func (m *AnalyzerMetrics) RecordAnalysisJob(success bool, duration float64) {
+// RecordAnalysisJob records the analysis job metrics, with the trace of ctx as exemplar
+func (m *AnalyzerMetrics) RecordAnalysisJob(ctx context.Context, success bool, duration float64) {
 	status := "success"
 	if !success {
 		status = "error"
 	}
 
 	m.AnalysisJobsProcessedTotal.WithLabelValues(status).Inc()
-	m.AnalysisDuration.WithLabelValues().Observe(duration)
+	observeWithTraceExemplar(ctx, m.AnalysisDuration.WithLabelValues(), duration)
 }
 
 // RecordAnalysisTask records the analysis task metrics
@@ -190,15 +341,20 @@ func (m *AnalyzerMetrics) RecordAnalysisTask(taskType string, success bool, dura
 	m.AnalysisTaskDuration.WithLabelValues(taskType).Observe(duration)
 }
 
-// RecordLinkVerification records the link verification metrics
-func (m *AnalyzerMetrics) RecordLinkVerification(success bool, duration float64) {
+// RecordLinkVerification records the link verification metrics, with the trace of ctx as exemplar
+func (m *AnalyzerMetrics) RecordLinkVerification(ctx context.Context, success bool, duration float64) {
 	outcome := "success"
 	if !success {
 		outcome = "failed"
 	}
 
 	m.LinksVerifiedTotal.WithLabelValues(outcome).Inc()
-	m.LinkVerificationDuration.WithLabelValues(outcome).Observe(duration)
+	observeWithTraceExemplar(ctx, m.LinkVerificationDuration.WithLabelValues(outcome), duration)
+}
+
+// RecordLinkVerificationError records a link that got no response when verified, code classifies the failure
+func (m *AnalyzerMetrics) RecordLinkVerificationError(code string) {
+	m.LinkVerificationErrorsTotal.WithLabelValues(code).Inc()
 }
 
 // RecordHTTPClientRequest records the HTTP client request metrics
@@ -207,7 +363,49 @@ func (m *AnalyzerMetrics) RecordHTTPClientRequest(status int, duration float64,
 	m.HTTPClientRequestDuration.WithLabelValues(method, requestType).Observe(duration)
 }
 
+// RecordContentFetchAttempt records a page content fetch attempt and its outcome
+func (m *AnalyzerMetrics) RecordContentFetchAttempt(attempt int, outcome string) {
+	m.ContentFetchAttemptsTotal.WithLabelValues(strconv.Itoa(attempt), outcome).Inc()
+}
+
+// RecordContentFetchSize records the size of a fetched page body as received and once decoded, by content encoding
+func (m *AnalyzerMetrics) RecordContentFetchSize(encoding string, transferred, decoded int64) {
+	m.ContentFetchTransferredBytes.WithLabelValues(encoding).Observe(float64(transferred))
+	m.ContentFetchDecodedBytes.WithLabelValues(encoding).Observe(float64(decoded))
+}
+
+// RecordContentFetchPhase records the time spent in a phase of a page content fetch, such as dns or ttfb
+func (m *AnalyzerMetrics) RecordContentFetchPhase(phase string, duration float64) {
+	m.ContentFetchPhaseDuration.WithLabelValues(phase).Observe(duration)
+}
+
 // SetConcurrentLinkVerifications sets the concurrent link verifications metrics
 func (m *AnalyzerMetrics) SetConcurrentLinkVerifications(count int) {
 	m.ConcurrentLinkVerifications.Set(float64(count))
 }
+
+// RecordSuppressedSubTaskEvent records a subtask update message skipped under the given granularity
+func (m *AnalyzerMetrics) RecordSuppressedSubTaskEvent(granularity string) {
+	m.SuppressedSubTaskEventsTotal.WithLabelValues(granularity).Inc()
+}
+
+// RecordInvalidTaskType records a task update refused for an unregistered task type
+func (m *AnalyzerMetrics) RecordInvalidTaskType() {
+	m.InvalidTaskTypesTotal.Inc()
+}
+
+// RecordHostRateLimitWait records an outbound request delayed by the per-host rate limit and how long it waited
+func (m *AnalyzerMetrics) RecordHostRateLimitWait(requestType string, wait float64) {
+	m.HostRateLimitedRequestsTotal.WithLabelValues(requestType).Inc()
+	m.HostRateLimitWaitDuration.WithLabelValues(requestType).Observe(wait)
+}
+
+// RecordDNSCacheLookup records a host lookup of an outbound connection, outcome is hit or miss
+func (m *AnalyzerMetrics) RecordDNSCacheLookup(outcome string) {
+	m.DNSCacheLookupsTotal.WithLabelValues(outcome).Inc()
+}
+
+// RecordStaleJobWrite records a job write of a superseded analysis attempt that was dropped, write names the update
+func (m *AnalyzerMetrics) RecordStaleJobWrite(write string) {
+	m.StaleJobWritesTotal.WithLabelValues(write).Inc()
+}
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanContext returns a context carrying a remote span with the given sampling decision
+func spanContext(t *testing.T, sampled bool) (context.Context, trace.TraceID) {
+	t.Helper()
+
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+
+	var flags trace.TraceFlags
+	if sampled {
+		flags = trace.FlagsSampled
+	}
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: flags})
+	return trace.ContextWithSpanContext(context.Background(), sc), traceID
+}
+
+// bucketExemplarTraceIDs gathers the collector and returns the trace_id of every exemplar on its histogram buckets
+func bucketExemplarTraceIDs(t *testing.T, c prometheus.Collector) []string {
+	t.Helper()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	var ids []string
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, bucket := range metric.GetHistogram().GetBucket() {
+				for _, label := range bucket.GetExemplar().GetLabel() {
+					if label.GetName() == "trace_id" {
+						ids = append(ids, label.GetValue())
+					}
+				}
+			}
+		}
+	}
+	return ids
+}
+
+func TestAnalyzerMetrics_Exemplars(t *testing.T) {
+	testCases := []struct {
+		name            string
+		ctx             func(t *testing.T) (context.Context, string)
+		expectExemplars bool
+	}{
+		{
+			name: "SampledSpan",
+			ctx: func(t *testing.T) (context.Context, string) {
+				ctx, traceID := spanContext(t, true)
+				return ctx, traceID.String()
+			},
+			expectExemplars: true,
+		},
+		{
+			name: "UnsampledSpan",
+			ctx: func(t *testing.T) (context.Context, string) {
+				ctx, _ := spanContext(t, false)
+				return ctx, ""
+			},
+		},
+		{
+			name: "NoSpan",
+			ctx: func(t *testing.T) (context.Context, string) {
+				return context.Background(), ""
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewAnalyzerMetrics()
+			ctx, traceID := tc.ctx(t)
+
+			m.RecordAnalysisJob(ctx, true, 0.3)
+			m.RecordLinkVerification(ctx, false, 0.05)
+
+			jobExemplars := bucketExemplarTraceIDs(t, m.AnalysisDuration)
+			linkExemplars := bucketExemplarTraceIDs(t, m.LinkVerificationDuration)
+			if tc.expectExemplars {
+				assert.Equal(t, []string{traceID}, jobExemplars)
+				assert.Equal(t, []string{traceID}, linkExemplars)
+			} else {
+				assert.Empty(t, jobExemplars)
+				assert.Empty(t, linkExemplars)
+			}
+		})
+	}
+}
+
+func TestMetricsHandler_NegotiatesOpenMetrics(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	rr := httptest.NewRecorder()
+
+	newMetricsHandler().ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Header().Get("Content-Type"), "application/openmetrics-text")
+}
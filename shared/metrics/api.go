@@ -16,6 +16,9 @@ type APIMetrics struct {
 
 	JobsCreatedTotal    *prometheus.CounterVec
 	JobCreationDuration *prometheus.HistogramVec
+	HandlerDuration     *prometheus.HistogramVec
+
+	JobCacheLookupsTotal *prometheus.CounterVec
 }
 
 // NewAPIMetrics creates a new API metrics
@@ -43,6 +46,25 @@ func NewAPIMetrics() *APIMetrics {
 			},
 			[]string{},
 		),
+
+		HandlerDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "http_handler_duration_seconds",
+				Help:        "HTTP handler duration in seconds by route template",
+				Buckets:     []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+				ConstLabels: prometheus.Labels{LabelService: apiServiceName},
+			},
+			[]string{LabelMethod, LabelRoute},
+		),
+
+		JobCacheLookupsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "job_cache_lookups_total",
+				Help:        "Total number of job reads by whether the job cache answered them",
+				ConstLabels: prometheus.Labels{LabelService: apiServiceName},
+			},
+			[]string{"outcome"},
+		),
 	}
 
 	return apiMetrics
@@ -55,6 +77,8 @@ func (m *APIMetrics) MustRegisterAPI() {
 	prometheus.MustRegister(
 		m.JobsCreatedTotal,
 		m.JobCreationDuration,
+		m.HandlerDuration,
+		m.JobCacheLookupsTotal,
 	)
 }
 
@@ -67,3 +91,17 @@ func (m *APIMetrics) RecordJobCreation(success bool, duration time.Duration) {
 	m.JobsCreatedTotal.WithLabelValues(status).Inc()
 	m.JobCreationDuration.WithLabelValues().Observe(duration.Seconds())
 }
+
+// RecordHandlerDuration records the handler duration for a route template
+func (m *APIMetrics) RecordHandlerDuration(method, route string, duration time.Duration) {
+	m.HandlerDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// RecordJobCacheLookup records a job read, hit when the job cache answered it
+func (m *APIMetrics) RecordJobCacheLookup(hit bool) {
+	outcome := "hit"
+	if !hit {
+		outcome = "miss"
+	}
+	m.JobCacheLookupsTotal.WithLabelValues(outcome).Inc()
+}
@@ -1,15 +1,19 @@
 package metrics
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"shared/middleware"
+	"shared/repository"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/yousuf64/shift"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Labels for metrics
@@ -24,6 +28,9 @@ const (
 	LabelTaskType    = "task_type"
 	LabelMessageType = "message_type"
 	LabelRequestType = "request_type"
+	LabelRoute       = "route"
+	LabelTruncated   = "truncated"
+	LabelInstanceID  = "instance_id"
 )
 
 // ServiceMetrics is a struct for service metrics
@@ -41,22 +48,29 @@ type ServiceMetrics struct {
 	NATSMessagesPublished *prometheus.CounterVec
 	NATSMessagesReceived  *prometheus.CounterVec
 	NATSMessageDuration   *prometheus.HistogramVec
+	NATSMessageAge        *prometheus.HistogramVec
 
 	// Database
 	DatabaseOperationsTotal   *prometheus.CounterVec
 	DatabaseOperationDuration *prometheus.HistogramVec
+	DatabaseResultSize        *prometheus.HistogramVec
 
 	uptimeTicker *time.Ticker
 }
 
 // NewServiceMetrics creates a new service metrics
 func NewServiceMetrics(serviceName string) *ServiceMetrics {
+	return newServiceMetrics(prometheus.Labels{LabelService: serviceName})
+}
+
+// newServiceMetrics creates the service metrics with the given constant labels
+func newServiceMetrics(constLabels prometheus.Labels) *ServiceMetrics {
 	metrics := &ServiceMetrics{
 		HTTPRequestsTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name:        "http_requests_total",
 				Help:        "Total number of HTTP requests",
-				ConstLabels: prometheus.Labels{LabelService: serviceName},
+				ConstLabels: constLabels,
 			},
 			[]string{LabelMethod, LabelEndpoint, LabelStatus},
 		),
@@ -66,7 +80,7 @@ func NewServiceMetrics(serviceName string) *ServiceMetrics {
 				Name:        "http_request_duration_seconds",
 				Help:        "HTTP request duration in seconds",
 				Buckets:     prometheus.DefBuckets,
-				ConstLabels: prometheus.Labels{LabelService: serviceName},
+				ConstLabels: constLabels,
 			},
 			[]string{LabelMethod, LabelEndpoint},
 		),
@@ -75,7 +89,7 @@ func NewServiceMetrics(serviceName string) *ServiceMetrics {
 			prometheus.GaugeOpts{
 				Name:        "http_requests_in_flight",
 				Help:        "Current number of HTTP requests being served",
-				ConstLabels: prometheus.Labels{LabelService: serviceName},
+				ConstLabels: constLabels,
 			},
 			[]string{LabelMethod, LabelEndpoint},
 		),
@@ -84,7 +98,7 @@ func NewServiceMetrics(serviceName string) *ServiceMetrics {
 			prometheus.GaugeOpts{
 				Name:        "service_uptime_seconds",
 				Help:        "Service uptime in seconds",
-				ConstLabels: prometheus.Labels{LabelService: serviceName},
+				ConstLabels: constLabels,
 			},
 		),
 
@@ -92,7 +106,7 @@ func NewServiceMetrics(serviceName string) *ServiceMetrics {
 			prometheus.GaugeOpts{
 				Name:        "service_info",
 				Help:        "Service information",
-				ConstLabels: prometheus.Labels{LabelService: serviceName},
+				ConstLabels: constLabels,
 			},
 			[]string{"version", "go_version"},
 		),
@@ -101,7 +115,7 @@ func NewServiceMetrics(serviceName string) *ServiceMetrics {
 			prometheus.CounterOpts{
 				Name:        "nats_messages_published_total",
 				Help:        "Total number of NATS messages published",
-				ConstLabels: prometheus.Labels{LabelService: serviceName},
+				ConstLabels: constLabels,
 			},
 			[]string{LabelMessageType, LabelStatus},
 		),
@@ -110,7 +124,7 @@ func NewServiceMetrics(serviceName string) *ServiceMetrics {
 			prometheus.CounterOpts{
 				Name:        "nats_messages_received_total",
 				Help:        "Total number of NATS messages received",
-				ConstLabels: prometheus.Labels{LabelService: serviceName},
+				ConstLabels: constLabels,
 			},
 			[]string{LabelMessageType, LabelStatus},
 		),
@@ -120,7 +134,17 @@ func NewServiceMetrics(serviceName string) *ServiceMetrics {
 				Name:        "nats_message_processing_duration_seconds",
 				Help:        "NATS message processing duration in seconds",
 				Buckets:     prometheus.DefBuckets,
-				ConstLabels: prometheus.Labels{LabelService: serviceName},
+				ConstLabels: constLabels,
+			},
+			[]string{LabelMessageType},
+		),
+
+		NATSMessageAge: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "nats_message_age_seconds",
+				Help:        "Time between publishing and consuming a NATS message in seconds",
+				Buckets:     []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+				ConstLabels: constLabels,
 			},
 			[]string{LabelMessageType},
 		),
@@ -129,7 +153,7 @@ func NewServiceMetrics(serviceName string) *ServiceMetrics {
 			prometheus.CounterOpts{
 				Name:        "database_operations_total",
 				Help:        "Total number of database operations",
-				ConstLabels: prometheus.Labels{LabelService: serviceName},
+				ConstLabels: constLabels,
 			},
 			[]string{LabelOperation, LabelTable, LabelStatus},
 		),
@@ -139,10 +163,20 @@ func NewServiceMetrics(serviceName string) *ServiceMetrics {
 				Name:        "database_operation_duration_seconds",
 				Help:        "Database operation duration in seconds",
 				Buckets:     prometheus.DefBuckets,
-				ConstLabels: prometheus.Labels{LabelService: serviceName},
+				ConstLabels: constLabels,
 			},
 			[]string{LabelOperation, LabelTable},
 		),
+
+		DatabaseResultSize: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "database_result_size_bytes",
+				Help:        "Estimated size of analysis results written to the database in bytes",
+				Buckets:     prometheus.ExponentialBuckets(1024, 2, 10), // 1KB to 512KB
+				ConstLabels: constLabels,
+			},
+			[]string{LabelTable, LabelTruncated},
+		),
 	}
 
 	return metrics
@@ -159,8 +193,10 @@ func (m *ServiceMetrics) MustRegister() {
 		m.NATSMessagesPublished,
 		m.NATSMessagesReceived,
 		m.NATSMessageDuration,
+		m.NATSMessageAge,
 		m.DatabaseOperationsTotal,
 		m.DatabaseOperationDuration,
+		m.DatabaseResultSize,
 	)
 }
 
@@ -205,16 +241,35 @@ func (m *ServiceMetrics) RecordNATSReceive(messageType string, duration time.Dur
 	m.NATSMessageDuration.WithLabelValues(messageType).Observe(duration.Seconds())
 }
 
-// RecordDatabaseOperation records the metrics for database operations
+// RecordNATSMessageAge records how long a NATS message took from publish to consume
+func (m *ServiceMetrics) RecordNATSMessageAge(messageType string, age time.Duration) {
+	m.NATSMessageAge.WithLabelValues(messageType).Observe(age.Seconds())
+}
+
+// RecordDatabaseOperation records the metrics for database operations.
+// A read for an item that does not exist is counted with the not_found status rather than as an error.
 func (m *ServiceMetrics) RecordDatabaseOperation(operation, table string, start time.Time, err error) {
-	status := "success"
-	if err != nil {
-		status = "error"
-	}
-	m.DatabaseOperationsTotal.WithLabelValues(operation, table, status).Inc()
+	m.DatabaseOperationsTotal.WithLabelValues(operation, table, databaseOperationStatus(err)).Inc()
 	m.DatabaseOperationDuration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
 }
 
+// databaseOperationStatus returns the status label of a database operation that ended with err
+func databaseOperationStatus(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, repository.ErrNotFound):
+		return "not_found"
+	default:
+		return "error"
+	}
+}
+
+// RecordResultSize records the estimated size of a result written to the database
+func (m *ServiceMetrics) RecordResultSize(table string, bytes int, truncated bool) {
+	m.DatabaseResultSize.WithLabelValues(table, strconv.FormatBool(truncated)).Observe(float64(bytes))
+}
+
 // SetServiceInfo sets the service info metrics
 func (m *ServiceMetrics) SetServiceInfo(version, goVersion string) {
 	m.ServiceInfo.WithLabelValues(version, goVersion).Set(1)
@@ -242,13 +297,50 @@ func (m *ServiceMetrics) stopUptimeTracking() {
 	}
 }
 
-// StartMetricsServer starts the metrics server
-func (m *ServiceMetrics) StartMetricsServer(port string) *http.Server {
+// newMetricsHandler returns the handler for /metrics. It negotiates the OpenMetrics format,
+// the only exposition format that carries exemplars.
+func newMetricsHandler() http.Handler {
+	return promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+	)
+}
+
+// traceExemplar returns the trace ID of the sampled span in ctx as exemplar labels,
+// or nil when there is no sampled span
+func traceExemplar(ctx context.Context) prometheus.Labels {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() || !sc.IsSampled() {
+		return nil
+	}
+	return prometheus.Labels{"trace_id": sc.TraceID().String()}
+}
+
+// observeWithTraceExemplar observes value, attaching the trace of ctx as exemplar when there is one
+func observeWithTraceExemplar(ctx context.Context, observer prometheus.Observer, value float64) {
+	exemplar := traceExemplar(ctx)
+	if eo, ok := observer.(prometheus.ExemplarObserver); ok && exemplar != nil {
+		eo.ObserveWithExemplar(value, exemplar)
+		return
+	}
+	observer.Observe(value)
+}
+
+// Route is an additional route served by the metrics server
+type Route struct {
+	Method  string
+	Path    string
+	Handler shift.HandlerFunc
+}
+
+// StartMetricsServer starts the metrics server with any additional routes
+func (m *ServiceMetrics) StartMetricsServer(port string, routes ...Route) *http.Server {
 	router := shift.New()
 	router.Use(middleware.CORSMiddleware)
 
+	metricsHandler := newMetricsHandler()
 	router.GET("/metrics", func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
-		promhttp.Handler().ServeHTTP(w, r)
+		metricsHandler.ServeHTTP(w, r)
 		return nil
 	})
 
@@ -258,6 +350,10 @@ func (m *ServiceMetrics) StartMetricsServer(port string) *http.Server {
 		return nil
 	})
 
+	for _, rt := range routes {
+		router.Map([]string{rt.Method}, rt.Path, rt.Handler)
+	}
+
 	// Handle OPTIONS for CORS preflight
 	router.OPTIONS("/*wildcard", middleware.OptionsHandler)
 
@@ -291,3 +387,8 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap returns the underlying [http.ResponseWriter] for [http.ResponseController]
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
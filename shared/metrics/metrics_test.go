@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"shared/repository"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceMetrics_RecordDatabaseOperation(t *testing.T) {
+	testCases := []struct {
+		name           string
+		err            error
+		expectedStatus string
+	}{
+		{name: "Success", expectedStatus: "success"},
+		{name: "JobNotFound", err: repository.ErrJobNotFound, expectedStatus: "not_found"},
+		{name: "WrappedNotFound", err: fmt.Errorf("failed to get group: %w", repository.ErrGroupNotFound), expectedStatus: "not_found"},
+		{name: "Error", err: errors.New("throttled"), expectedStatus: "error"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewServiceMetrics("test")
+			m.RecordDatabaseOperation("get_job", repository.JobsTableName, time.Now(), tc.err)
+
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(m.DatabaseOperationsTotal)
+			families, err := reg.Gather()
+			require.NoError(t, err)
+			require.Len(t, families, 1)
+			require.Len(t, families[0].GetMetric(), 1)
+
+			labels := make(map[string]string)
+			for _, label := range families[0].GetMetric()[0].GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			assert.Equal(t, tc.expectedStatus, labels[LabelStatus])
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -20,11 +22,26 @@ type NotificationsMetrics struct {
 
 	WebSocketSubscriptionsTotal  *prometheus.CounterVec
 	WebSocketSubscriptionsActive *prometheus.GaugeVec
+	WebSocketGroupsActive        prometheus.Gauge
+
+	MessagesDroppedTotal *prometheus.CounterVec
+
+	WebSocketContractViolationsTotal *prometheus.CounterVec
+
+	TerminalEventsTotal *prometheus.CounterVec
+
+	NATSSlowConsumerEventsTotal *prometheus.CounterVec
+
+	WebhookDeliveryAttemptsTotal  *prometheus.CounterVec
+	WebhookDeliveryDuration       *prometheus.HistogramVec
+	WebhookDeliveriesDroppedTotal *prometheus.CounterVec
 }
 
-// NewNotificationsMetrics creates a new notifications metrics
-func NewNotificationsMetrics() *NotificationsMetrics {
-	baseMetrics := NewServiceMetrics(notificationsServiceName)
+// NewNotificationsMetrics creates a new notifications metrics.
+// Every series carries the instance ID, so replicas behind a load balancer can be told apart and summed without double counting.
+func NewNotificationsMetrics(instanceID string) *NotificationsMetrics {
+	labels := prometheus.Labels{LabelService: notificationsServiceName, LabelInstanceID: instanceID}
+	baseMetrics := newServiceMetrics(labels)
 
 	notificationsMetrics := &NotificationsMetrics{
 		ServiceMetrics: baseMetrics,
@@ -33,7 +50,7 @@ func NewNotificationsMetrics() *NotificationsMetrics {
 			prometheus.GaugeOpts{
 				Name:        "websocket_connections_active",
 				Help:        "Current number of active WebSocket connections",
-				ConstLabels: prometheus.Labels{LabelService: notificationsServiceName},
+				ConstLabels: labels,
 			},
 		),
 
@@ -41,7 +58,7 @@ func NewNotificationsMetrics() *NotificationsMetrics {
 			prometheus.CounterOpts{
 				Name:        "websocket_connections_total",
 				Help:        "Total number of WebSocket connections established",
-				ConstLabels: prometheus.Labels{LabelService: notificationsServiceName},
+				ConstLabels: labels,
 			},
 			[]string{LabelStatus},
 		),
@@ -50,7 +67,7 @@ func NewNotificationsMetrics() *NotificationsMetrics {
 			prometheus.CounterOpts{
 				Name:        "websocket_messages_sent_total",
 				Help:        "Total number of WebSocket messages sent",
-				ConstLabels: prometheus.Labels{LabelService: notificationsServiceName},
+				ConstLabels: labels,
 			},
 			[]string{LabelMessageType, LabelStatus},
 		),
@@ -60,7 +77,7 @@ func NewNotificationsMetrics() *NotificationsMetrics {
 				Name:        "websocket_message_broadcast_duration_seconds",
 				Help:        "WebSocket message broadcast duration in seconds",
 				Buckets:     prometheus.DefBuckets,
-				ConstLabels: prometheus.Labels{LabelService: notificationsServiceName},
+				ConstLabels: labels,
 			},
 			[]string{LabelMessageType},
 		),
@@ -70,7 +87,7 @@ func NewNotificationsMetrics() *NotificationsMetrics {
 				Name:        "websocket_connection_duration_seconds",
 				Help:        "WebSocket connection duration in seconds",
 				Buckets:     prometheus.DefBuckets,
-				ConstLabels: prometheus.Labels{LabelService: notificationsServiceName},
+				ConstLabels: labels,
 			},
 			[]string{},
 		),
@@ -79,7 +96,7 @@ func NewNotificationsMetrics() *NotificationsMetrics {
 			prometheus.CounterOpts{
 				Name:        "websocket_group_subscriptions_total",
 				Help:        "Total number of WebSocket group subscription events",
-				ConstLabels: prometheus.Labels{LabelService: notificationsServiceName},
+				ConstLabels: labels,
 			},
 			[]string{"action", "group"},
 		),
@@ -88,10 +105,82 @@ func NewNotificationsMetrics() *NotificationsMetrics {
 			prometheus.GaugeOpts{
 				Name:        "websocket_group_subscriptions_active",
 				Help:        "Current number of active WebSocket group subscriptions",
-				ConstLabels: prometheus.Labels{LabelService: notificationsServiceName},
+				ConstLabels: labels,
 			},
 			[]string{"group"},
 		),
+
+		WebSocketGroupsActive: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name:        "websocket_groups_active",
+				Help:        "Current number of groups with at least one subscriber on this instance",
+				ConstLabels: labels,
+			},
+		),
+
+		MessagesDroppedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "notifications_messages_dropped_total",
+				Help:        "Total number of message bus messages dropped instead of reaching their consumer, the WebSocket clients or the webhooks",
+				ConstLabels: labels,
+			},
+			[]string{"consumer", LabelMessageType, "reason"},
+		),
+
+		WebSocketContractViolationsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "websocket_contract_violations_total",
+				Help:        "Total number of outgoing WebSocket messages that did not match their schema",
+				ConstLabels: labels,
+			},
+			[]string{LabelMessageType},
+		),
+
+		TerminalEventsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "websocket_terminal_events_total",
+				Help:        "Total number of final job updates kept for late subscribers, by what happened to them",
+				ConstLabels: labels,
+			},
+			[]string{"outcome"},
+		),
+
+		NATSSlowConsumerEventsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "nats_slow_consumer_events_total",
+				Help:        "Total number of times a NATS subscription fell behind and NATS started dropping its messages",
+				ConstLabels: labels,
+			},
+			[]string{"subject"},
+		),
+
+		WebhookDeliveryAttemptsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "webhook_delivery_attempts_total",
+				Help:        "Total number of attempts to deliver an update to a webhook, by message type and outcome",
+				ConstLabels: labels,
+			},
+			[]string{LabelMessageType, "outcome"},
+		),
+
+		WebhookDeliveryDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "webhook_delivery_duration_seconds",
+				Help:        "Duration of each attempt to deliver an update to a webhook in seconds, by message type",
+				Buckets:     prometheus.DefBuckets,
+				ConstLabels: labels,
+			},
+			[]string{LabelMessageType},
+		),
+
+		WebhookDeliveriesDroppedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "webhook_deliveries_dropped_total",
+				Help:        "Total number of webhook deliveries dropped because the delivery queue was full, by message type",
+				ConstLabels: labels,
+			},
+			[]string{LabelMessageType},
+		),
 	}
 
 	return notificationsMetrics
@@ -109,6 +198,14 @@ func (m *NotificationsMetrics) MustRegisterNotifications() {
 		m.WebSocketConnectionDuration,
 		m.WebSocketSubscriptionsTotal,
 		m.WebSocketSubscriptionsActive,
+		m.WebSocketGroupsActive,
+		m.MessagesDroppedTotal,
+		m.WebSocketContractViolationsTotal,
+		m.TerminalEventsTotal,
+		m.NATSSlowConsumerEventsTotal,
+		m.WebhookDeliveryAttemptsTotal,
+		m.WebhookDeliveryDuration,
+		m.WebhookDeliveriesDroppedTotal,
 	)
 }
 
@@ -146,7 +243,55 @@ func (m *NotificationsMetrics) RecordGroupSubscription(action, group string) {
 	m.WebSocketSubscriptionsTotal.WithLabelValues(action, group).Inc()
 }
 
-// SetActiveGroupSubscriptions sets the active WebSocket group subscriptions metrics
+// SetActiveGroupSubscriptions sets the active WebSocket group subscriptions metrics.
+// A count of zero removes the group's series, as groups are short-lived job IDs.
 func (m *NotificationsMetrics) SetActiveGroupSubscriptions(group string, count float64) {
+	if count <= 0 {
+		m.WebSocketSubscriptionsActive.DeleteLabelValues(group)
+		return
+	}
 	m.WebSocketSubscriptionsActive.WithLabelValues(group).Set(count)
 }
+
+// SetActiveGroups sets the number of groups with subscribers on this instance
+func (m *NotificationsMetrics) SetActiveGroups(count int) {
+	m.WebSocketGroupsActive.Set(float64(count))
+}
+
+// RecordDroppedMessage records a message bus message that did not reach its consumer, with the reason it was dropped
+func (m *NotificationsMetrics) RecordDroppedMessage(consumer, messageType, reason string) {
+	m.RecordDroppedMessages(consumer, messageType, reason, 1)
+}
+
+// RecordDroppedMessages records count message bus messages that did not reach their consumer, such as websocket or
+// webhook, with the reason they were dropped
+func (m *NotificationsMetrics) RecordDroppedMessages(consumer, messageType, reason string, count int) {
+	m.MessagesDroppedTotal.WithLabelValues(consumer, messageType, reason).Add(float64(count))
+}
+
+// RecordSlowConsumer records a NATS subscription falling behind, after which NATS drops its messages
+func (m *NotificationsMetrics) RecordSlowConsumer(subject string) {
+	m.NATSSlowConsumerEventsTotal.WithLabelValues(subject).Inc()
+}
+
+// RecordContractViolation records an outgoing message that did not match the schema of its type
+func (m *NotificationsMetrics) RecordContractViolation(messageType string) {
+	m.WebSocketContractViolationsTotal.WithLabelValues(messageType).Inc()
+}
+
+// RecordTerminalEvents records final job updates kept for late subscribers: buffered, replayed, expired or evicted
+func (m *NotificationsMetrics) RecordTerminalEvents(outcome string, count int) {
+	m.TerminalEventsTotal.WithLabelValues(outcome).Add(float64(count))
+}
+
+// RecordWebhookAttempt records an attempt to deliver an update to a webhook, delivered, retried or failed,
+// along with how long it took
+func (m *NotificationsMetrics) RecordWebhookAttempt(messageType, outcome string, duration time.Duration) {
+	m.WebhookDeliveryAttemptsTotal.WithLabelValues(messageType, outcome).Inc()
+	m.WebhookDeliveryDuration.WithLabelValues(messageType).Observe(duration.Seconds())
+}
+
+// RecordWebhookDropped records a webhook delivery dropped because the delivery queue was full
+func (m *NotificationsMetrics) RecordWebhookDropped(messageType string) {
+	m.WebhookDeliveriesDroppedTotal.WithLabelValues(messageType).Inc()
+}
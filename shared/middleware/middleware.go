@@ -1,40 +1,223 @@
 package middleware
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
+	"shared/log"
+	"shared/repository"
+	"strings"
 
 	"github.com/yousuf64/shift"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// CORSMiddleware handles CORS requests with default settings
+// RequestIDHeader is the header used to propagate request IDs
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// HTTPError is an error that carries the HTTP status code to respond with
+type HTTPError struct {
+	Status  int
+	Message string
+	Err     error
+}
+
+// NewHTTPError creates a new HTTPError
+func NewHTTPError(status int, message string, err error) *HTTPError {
+	return &HTTPError{Status: status, Message: message, Err: err}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// corsAllowedHeaders are the request headers allowed when a preflight does not list the ones it needs
+const corsAllowedHeaders = "Content-Type, Authorization, " + RequestIDHeader
+
+// CORSMiddleware handles CORS requests with default settings.
+// A preflight is allowed the headers it requests, so clients can send headers the services start accepting
+// without the list here being updated.
 func CORSMiddleware(next shift.HandlerFunc) shift.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		// The allowed headers differ by request, caches must not hand one preflight's answer to another
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			w.Header().Set("Access-Control-Allow-Headers", requested)
+		} else {
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+		}
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition")
 		w.Header().Set("Access-Control-Max-Age", "86400")
 		return next(w, r, route)
 	}
 }
 
-// ErrorMiddleware handles errors with structured logging
+// RequestIDMiddleware assigns a request ID to every request.
+// An incoming X-Request-ID header is reused, otherwise the trace ID or a random ID is used.
+// The ID is echoed in the response and available via [RequestIDFromContext].
+func RequestIDMiddleware(next shift.HandlerFunc) shift.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" {
+			id = newRequestID(r.Context())
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		return next(w, r.WithContext(ctx), route)
+	}
+}
+
+// RequestIDFromContext returns the request ID stored in the context, if any
+func RequestIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// newRequestID returns the current trace ID, falling back to a random ID
+func newRequestID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ErrorMiddleware handles errors with structured logging.
+// Errors wrapping an [HTTPError] are answered with its status code and message, those matching
+// [repository.ErrNotFound] with a 404 and others with a 500,
+// in the format [WriteError] negotiates.
 func ErrorMiddleware(logger *slog.Logger) func(shift.HandlerFunc) shift.HandlerFunc {
 	return func(next shift.HandlerFunc) shift.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
 			err := next(w, r, route)
 			if err != nil {
-				logger.Error("Request error",
+				status, message := http.StatusInternalServerError, err.Error()
+				var httpErr *HTTPError
+				if errors.As(err, &httpErr) {
+					status, message = httpErr.Status, httpErr.Message
+				} else if errors.Is(err, repository.ErrNotFound) {
+					status, message = http.StatusNotFound, http.StatusText(http.StatusNotFound)
+				}
+
+				// Errors of the client's making are no cause for alarm
+				level := slog.LevelError
+				if status < http.StatusInternalServerError {
+					level = slog.LevelInfo
+				}
+				logger.Log(r.Context(), level, "Request error",
 					slog.String("method", r.Method),
 					slog.String("path", r.URL.Path),
+					slog.String("request_id", RequestIDFromContext(r.Context())),
+					slog.Int("status", status),
 					slog.Any("error", err))
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+
+				WriteError(w, r, status, message)
 			}
 			return err
 		}
 	}
 }
 
+// AdminAuthMiddleware restricts a handler to requests carrying the admin bearer token.
+// Admin endpoints respond with 404 when no token is configured.
+func AdminAuthMiddleware(token string) func(shift.HandlerFunc) shift.HandlerFunc {
+	return func(next shift.HandlerFunc) shift.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+			if token == "" {
+				http.NotFound(w, r)
+				return nil
+			}
+
+			if !IsAdminRequest(r, token) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				WriteError(w, r, http.StatusUnauthorized, "unauthorized")
+				return nil
+			}
+
+			return next(w, r, route)
+		}
+	}
+}
+
+// IsAdminRequest reports whether the request carries the admin bearer token, never when no token is configured
+func IsAdminRequest(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+// ConfigHandler serves the given configuration as JSON.
+// Callers are responsible for passing a redacted configuration.
+func ConfigHandler(cfg any) shift.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(cfg)
+	}
+}
+
+// LogLevelRequest is the request body of the log level endpoint
+type LogLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// LogLevelResponse reports the log level now active and the one it replaced
+type LogLevelResponse struct {
+	Level    string `json:"level"`
+	Previous string `json:"previous"`
+}
+
+// LogLevelHandler changes the level of the service's loggers until the next change or restart,
+// so debug logs can be turned on for a while without redeploying.
+func LogLevelHandler(logger *slog.Logger) shift.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, route shift.Route) error {
+		var req LogLevelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteError(w, r, http.StatusBadRequest, "invalid request body")
+			return nil
+		}
+		level, err := log.ParseLevel(req.Level)
+		if err != nil {
+			WriteError(w, r, http.StatusBadRequest, err.Error())
+			return nil
+		}
+
+		previous := log.Level()
+		log.SetLevel(level)
+		// Logged as a warning, so the change shows up unless only errors are logged
+		logger.Warn("Log level changed",
+			slog.String("level", level.String()),
+			slog.String("previous", previous.String()),
+			slog.String("request_id", RequestIDFromContext(r.Context())))
+
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(LogLevelResponse{Level: level.String(), Previous: previous.String()})
+	}
+}
+
 // OptionsHandler handles OPTIONS requests for CORS preflight
 // This can be used as a route handler for "/*wildcard" OPTIONS routes
 func OptionsHandler(w http.ResponseWriter, r *http.Request, route shift.Route) error {
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object, answered to clients that accept ProblemContentType
+type Problem struct {
+	// Type is a URI identifying the kind of problem, about:blank when the status code says it all
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request that failed
+	Instance string `json:"instance,omitempty"`
+}
+
+// ErrorResponse is the JSON envelope errors are answered with unless the client asks for problem details
+type ErrorResponse struct {
+	Error  string `json:"error"`
+	Status int    `json:"status"`
+}
+
+// WriteError answers the request with an error, as problem details when its Accept header allows them
+// and in the ErrorResponse envelope otherwise
+func WriteError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	h := w.Header()
+	// Any length set for the body the handler meant to write no longer applies
+	h.Del("Content-Length")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Add("Vary", "Accept")
+
+	var body any = ErrorResponse{Error: message, Status: status}
+	if AcceptsProblem(r) {
+		h.Set("Content-Type", ProblemContentType)
+		body = Problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(status),
+			Status:   status,
+			Detail:   message,
+			Instance: r.URL.Path,
+		}
+	} else {
+		h.Set("Content-Type", "application/json")
+	}
+
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// AcceptsProblem reports whether the request's Accept header lists ProblemContentType with a non-zero quality
+func AcceptsProblem(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil || mediaType != ProblemContentType {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if quality, err := strconv.ParseFloat(q, 64); err != nil || quality <= 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
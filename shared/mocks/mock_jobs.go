@@ -13,6 +13,8 @@ import (
 	context "context"
 	reflect "reflect"
 	models "shared/models"
+	repository "shared/repository"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -41,6 +43,21 @@ func (m *MockJobRepositoryInterface) EXPECT() *MockJobRepositoryInterfaceMockRec
 	return m.recorder
 }
 
+// CancelJob mocks base method.
+func (m *MockJobRepositoryInterface) CancelJob(ctx context.Context, id string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelJob", ctx, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CancelJob indicates an expected call of CancelJob.
+func (mr *MockJobRepositoryInterfaceMockRecorder) CancelJob(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelJob", reflect.TypeOf((*MockJobRepositoryInterface)(nil).CancelJob), ctx, id)
+}
+
 // CreateJob mocks base method.
 func (m *MockJobRepositoryInterface) CreateJob(ctx context.Context, job *models.Job) error {
 	m.ctrl.T.Helper()
@@ -55,6 +72,36 @@ func (mr *MockJobRepositoryInterfaceMockRecorder) CreateJob(ctx, job any) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateJob", reflect.TypeOf((*MockJobRepositoryInterface)(nil).CreateJob), ctx, job)
 }
 
+// DeleteJob mocks base method.
+func (m *MockJobRepositoryInterface) DeleteJob(ctx context.Context, id string, deletedAt time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteJob", ctx, id, deletedAt)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteJob indicates an expected call of DeleteJob.
+func (mr *MockJobRepositoryInterfaceMockRecorder) DeleteJob(ctx, id, deletedAt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteJob", reflect.TypeOf((*MockJobRepositoryInterface)(nil).DeleteJob), ctx, id, deletedAt)
+}
+
+// DiscardJob mocks base method.
+func (m *MockJobRepositoryInterface) DiscardJob(ctx context.Context, id string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DiscardJob", ctx, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DiscardJob indicates an expected call of DiscardJob.
+func (mr *MockJobRepositoryInterfaceMockRecorder) DiscardJob(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DiscardJob", reflect.TypeOf((*MockJobRepositoryInterface)(nil).DiscardJob), ctx, id)
+}
+
 // GetAllJobs mocks base method.
 func (m *MockJobRepositoryInterface) GetAllJobs(ctx context.Context) ([]*models.Job, error) {
 	m.ctrl.T.Helper()
@@ -70,6 +117,22 @@ func (mr *MockJobRepositoryInterfaceMockRecorder) GetAllJobs(ctx any) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllJobs", reflect.TypeOf((*MockJobRepositoryInterface)(nil).GetAllJobs), ctx)
 }
 
+// GetDeletedJobs mocks base method.
+func (m *MockJobRepositoryInterface) GetDeletedJobs(ctx context.Context, cursor string, limit int64) ([]*models.Job, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeletedJobs", ctx, cursor, limit)
+	ret0, _ := ret[0].([]*models.Job)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetDeletedJobs indicates an expected call of GetDeletedJobs.
+func (mr *MockJobRepositoryInterfaceMockRecorder) GetDeletedJobs(ctx, cursor, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeletedJobs", reflect.TypeOf((*MockJobRepositoryInterface)(nil).GetDeletedJobs), ctx, cursor, limit)
+}
+
 // GetJob mocks base method.
 func (m *MockJobRepositoryInterface) GetJob(ctx context.Context, id string) (*models.Job, error) {
 	m.ctrl.T.Helper()
@@ -85,30 +148,145 @@ func (mr *MockJobRepositoryInterfaceMockRecorder) GetJob(ctx, id any) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJob", reflect.TypeOf((*MockJobRepositoryInterface)(nil).GetJob), ctx, id)
 }
 
+// GetJobsByIDs mocks base method.
+func (m *MockJobRepositoryInterface) GetJobsByIDs(ctx context.Context, ids []string) ([]*models.Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetJobsByIDs", ctx, ids)
+	ret0, _ := ret[0].([]*models.Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetJobsByIDs indicates an expected call of GetJobsByIDs.
+func (mr *MockJobRepositoryInterfaceMockRecorder) GetJobsByIDs(ctx, ids any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJobsByIDs", reflect.TypeOf((*MockJobRepositoryInterface)(nil).GetJobsByIDs), ctx, ids)
+}
+
+// GetJobsByStatus mocks base method.
+func (m *MockJobRepositoryInterface) GetJobsByStatus(ctx context.Context, statuses []models.JobStatus, cursor string, limit int64) ([]*models.Job, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetJobsByStatus", ctx, statuses, cursor, limit)
+	ret0, _ := ret[0].([]*models.Job)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetJobsByStatus indicates an expected call of GetJobsByStatus.
+func (mr *MockJobRepositoryInterfaceMockRecorder) GetJobsByStatus(ctx, statuses, cursor, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJobsByStatus", reflect.TypeOf((*MockJobRepositoryInterface)(nil).GetJobsByStatus), ctx, statuses, cursor, limit)
+}
+
+// HeartbeatJob mocks base method.
+func (m *MockJobRepositoryInterface) HeartbeatJob(ctx context.Context, id, attemptID string, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HeartbeatJob", ctx, id, attemptID, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// HeartbeatJob indicates an expected call of HeartbeatJob.
+func (mr *MockJobRepositoryInterfaceMockRecorder) HeartbeatJob(ctx, id, attemptID, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HeartbeatJob", reflect.TypeOf((*MockJobRepositoryInterface)(nil).HeartbeatJob), ctx, id, attemptID, at)
+}
+
+// PurgeJob mocks base method.
+func (m *MockJobRepositoryInterface) PurgeJob(ctx context.Context, id string, deletedAt time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeJob", ctx, id, deletedAt)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeJob indicates an expected call of PurgeJob.
+func (mr *MockJobRepositoryInterfaceMockRecorder) PurgeJob(ctx, id, deletedAt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeJob", reflect.TypeOf((*MockJobRepositoryInterface)(nil).PurgeJob), ctx, id, deletedAt)
+}
+
+// QueryJobsByResult mocks base method.
+func (m *MockJobRepositoryInterface) QueryJobsByResult(ctx context.Context, query repository.ResultQuery, cursor string, limit int64) ([]*models.Job, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryJobsByResult", ctx, query, cursor, limit)
+	ret0, _ := ret[0].([]*models.Job)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// QueryJobsByResult indicates an expected call of QueryJobsByResult.
+func (mr *MockJobRepositoryInterfaceMockRecorder) QueryJobsByResult(ctx, query, cursor, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryJobsByResult", reflect.TypeOf((*MockJobRepositoryInterface)(nil).QueryJobsByResult), ctx, query, cursor, limit)
+}
+
+// RestoreJob mocks base method.
+func (m *MockJobRepositoryInterface) RestoreJob(ctx context.Context, id string, deletedAt time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreJob", ctx, id, deletedAt)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreJob indicates an expected call of RestoreJob.
+func (mr *MockJobRepositoryInterfaceMockRecorder) RestoreJob(ctx, id, deletedAt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreJob", reflect.TypeOf((*MockJobRepositoryInterface)(nil).RestoreJob), ctx, id, deletedAt)
+}
+
 // UpdateJob mocks base method.
-func (m *MockJobRepositoryInterface) UpdateJob(ctx context.Context, id string, status *models.JobStatus, result *models.AnalyzeResult) error {
+func (m *MockJobRepositoryInterface) UpdateJob(ctx context.Context, id string, status *models.JobStatus, result *models.AnalyzeResult, opts ...repository.UpdateOption) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateJob", ctx, id, status, result)
+	varargs := []any{ctx, id, status, result}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpdateJob", varargs...)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateJob indicates an expected call of UpdateJob.
-func (mr *MockJobRepositoryInterfaceMockRecorder) UpdateJob(ctx, id, status, result any) *gomock.Call {
+func (mr *MockJobRepositoryInterfaceMockRecorder) UpdateJob(ctx, id, status, result any, opts ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, id, status, result}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateJob", reflect.TypeOf((*MockJobRepositoryInterface)(nil).UpdateJob), varargs...)
+}
+
+// UpdateJobProgress mocks base method.
+func (m *MockJobRepositoryInterface) UpdateJobProgress(ctx context.Context, id string, progress float64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateJobProgress", ctx, id, progress)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateJobProgress indicates an expected call of UpdateJobProgress.
+func (mr *MockJobRepositoryInterfaceMockRecorder) UpdateJobProgress(ctx, id, progress any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateJob", reflect.TypeOf((*MockJobRepositoryInterface)(nil).UpdateJob), ctx, id, status, result)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateJobProgress", reflect.TypeOf((*MockJobRepositoryInterface)(nil).UpdateJobProgress), ctx, id, progress)
 }
 
 // UpdateJobStatus mocks base method.
-func (m *MockJobRepositoryInterface) UpdateJobStatus(ctx context.Context, id string, status models.JobStatus) error {
+func (m *MockJobRepositoryInterface) UpdateJobStatus(ctx context.Context, id string, status models.JobStatus, opts ...repository.UpdateOption) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateJobStatus", ctx, id, status)
+	varargs := []any{ctx, id, status}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpdateJobStatus", varargs...)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateJobStatus indicates an expected call of UpdateJobStatus.
-func (mr *MockJobRepositoryInterfaceMockRecorder) UpdateJobStatus(ctx, id, status any) *gomock.Call {
+func (mr *MockJobRepositoryInterfaceMockRecorder) UpdateJobStatus(ctx, id, status any, opts ...any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateJobStatus", reflect.TypeOf((*MockJobRepositoryInterface)(nil).UpdateJobStatus), ctx, id, status)
+	varargs := append([]any{ctx, id, status}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateJobStatus", reflect.TypeOf((*MockJobRepositoryInterface)(nil).UpdateJobStatus), varargs...)
 }
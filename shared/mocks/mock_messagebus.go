@@ -56,6 +56,20 @@ func (mr *MockMessageBusInterfaceMockRecorder) PublishAnalyzeMessage(ctx, m any)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishAnalyzeMessage", reflect.TypeOf((*MockMessageBusInterface)(nil).PublishAnalyzeMessage), ctx, m)
 }
 
+// PublishAnalyzerLoad mocks base method.
+func (m_2 *MockMessageBusInterface) PublishAnalyzerLoad(ctx context.Context, m messagebus.AnalyzerLoadMessage) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "PublishAnalyzerLoad", ctx, m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PublishAnalyzerLoad indicates an expected call of PublishAnalyzerLoad.
+func (mr *MockMessageBusInterfaceMockRecorder) PublishAnalyzerLoad(ctx, m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishAnalyzerLoad", reflect.TypeOf((*MockMessageBusInterface)(nil).PublishAnalyzerLoad), ctx, m)
+}
+
 // PublishJobUpdate mocks base method.
 func (m_2 *MockMessageBusInterface) PublishJobUpdate(ctx context.Context, m messagebus.JobUpdateMessage) error {
 	m_2.ctrl.T.Helper()
@@ -70,6 +84,20 @@ func (mr *MockMessageBusInterfaceMockRecorder) PublishJobUpdate(ctx, m any) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishJobUpdate", reflect.TypeOf((*MockMessageBusInterface)(nil).PublishJobUpdate), ctx, m)
 }
 
+// PublishPresence mocks base method.
+func (m_2 *MockMessageBusInterface) PublishPresence(ctx context.Context, m messagebus.PresenceMessage) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "PublishPresence", ctx, m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PublishPresence indicates an expected call of PublishPresence.
+func (mr *MockMessageBusInterfaceMockRecorder) PublishPresence(ctx, m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishPresence", reflect.TypeOf((*MockMessageBusInterface)(nil).PublishPresence), ctx, m)
+}
+
 // PublishSubTaskUpdate mocks base method.
 func (m_2 *MockMessageBusInterface) PublishSubTaskUpdate(ctx context.Context, m messagebus.SubTaskUpdateMessage) error {
 	m_2.ctrl.T.Helper()
@@ -98,6 +126,51 @@ func (mr *MockMessageBusInterfaceMockRecorder) PublishTaskStatusUpdate(ctx, m an
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublishTaskStatusUpdate", reflect.TypeOf((*MockMessageBusInterface)(nil).PublishTaskStatusUpdate), ctx, m)
 }
 
+// QueueSubscribeToJobUpdate mocks base method.
+func (m *MockMessageBusInterface) QueueSubscribeToJobUpdate(queue string, handler func(context.Context, *nats.Msg)) (*nats.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueueSubscribeToJobUpdate", queue, handler)
+	ret0, _ := ret[0].(*nats.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueueSubscribeToJobUpdate indicates an expected call of QueueSubscribeToJobUpdate.
+func (mr *MockMessageBusInterfaceMockRecorder) QueueSubscribeToJobUpdate(queue, handler any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueueSubscribeToJobUpdate", reflect.TypeOf((*MockMessageBusInterface)(nil).QueueSubscribeToJobUpdate), queue, handler)
+}
+
+// QueueSubscribeToSubTaskUpdate mocks base method.
+func (m *MockMessageBusInterface) QueueSubscribeToSubTaskUpdate(queue string, handler func(context.Context, *nats.Msg)) (*nats.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueueSubscribeToSubTaskUpdate", queue, handler)
+	ret0, _ := ret[0].(*nats.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueueSubscribeToSubTaskUpdate indicates an expected call of QueueSubscribeToSubTaskUpdate.
+func (mr *MockMessageBusInterfaceMockRecorder) QueueSubscribeToSubTaskUpdate(queue, handler any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueueSubscribeToSubTaskUpdate", reflect.TypeOf((*MockMessageBusInterface)(nil).QueueSubscribeToSubTaskUpdate), queue, handler)
+}
+
+// QueueSubscribeToTaskStatusUpdate mocks base method.
+func (m *MockMessageBusInterface) QueueSubscribeToTaskStatusUpdate(queue string, handler func(context.Context, *nats.Msg)) (*nats.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueueSubscribeToTaskStatusUpdate", queue, handler)
+	ret0, _ := ret[0].(*nats.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueueSubscribeToTaskStatusUpdate indicates an expected call of QueueSubscribeToTaskStatusUpdate.
+func (mr *MockMessageBusInterfaceMockRecorder) QueueSubscribeToTaskStatusUpdate(queue, handler any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueueSubscribeToTaskStatusUpdate", reflect.TypeOf((*MockMessageBusInterface)(nil).QueueSubscribeToTaskStatusUpdate), queue, handler)
+}
+
 // SubscribeToAnalyzeMessage mocks base method.
 func (m *MockMessageBusInterface) SubscribeToAnalyzeMessage(handler func(context.Context, *nats.Msg)) (*nats.Subscription, error) {
 	m.ctrl.T.Helper()
@@ -113,6 +186,21 @@ func (mr *MockMessageBusInterfaceMockRecorder) SubscribeToAnalyzeMessage(handler
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeToAnalyzeMessage", reflect.TypeOf((*MockMessageBusInterface)(nil).SubscribeToAnalyzeMessage), handler)
 }
 
+// SubscribeToAnalyzerLoad mocks base method.
+func (m *MockMessageBusInterface) SubscribeToAnalyzerLoad(handler func(context.Context, *nats.Msg)) (*nats.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeToAnalyzerLoad", handler)
+	ret0, _ := ret[0].(*nats.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubscribeToAnalyzerLoad indicates an expected call of SubscribeToAnalyzerLoad.
+func (mr *MockMessageBusInterfaceMockRecorder) SubscribeToAnalyzerLoad(handler any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeToAnalyzerLoad", reflect.TypeOf((*MockMessageBusInterface)(nil).SubscribeToAnalyzerLoad), handler)
+}
+
 // SubscribeToJobUpdate mocks base method.
 func (m *MockMessageBusInterface) SubscribeToJobUpdate(handler func(context.Context, *nats.Msg)) (*nats.Subscription, error) {
 	m.ctrl.T.Helper()
@@ -128,6 +216,21 @@ func (mr *MockMessageBusInterfaceMockRecorder) SubscribeToJobUpdate(handler any)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeToJobUpdate", reflect.TypeOf((*MockMessageBusInterface)(nil).SubscribeToJobUpdate), handler)
 }
 
+// SubscribeToPresence mocks base method.
+func (m *MockMessageBusInterface) SubscribeToPresence(handler func(context.Context, *nats.Msg)) (*nats.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeToPresence", handler)
+	ret0, _ := ret[0].(*nats.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubscribeToPresence indicates an expected call of SubscribeToPresence.
+func (mr *MockMessageBusInterfaceMockRecorder) SubscribeToPresence(handler any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeToPresence", reflect.TypeOf((*MockMessageBusInterface)(nil).SubscribeToPresence), handler)
+}
+
 // SubscribeToSubTaskUpdate mocks base method.
 func (m *MockMessageBusInterface) SubscribeToSubTaskUpdate(handler func(context.Context, *nats.Msg)) (*nats.Subscription, error) {
 	m.ctrl.T.Helper()
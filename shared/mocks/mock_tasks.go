@@ -74,6 +74,20 @@ func (mr *MockTaskRepositoryInterfaceMockRecorder) CreateTasks(ctx any, tasks ..
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTasks", reflect.TypeOf((*MockTaskRepositoryInterface)(nil).CreateTasks), varargs...)
 }
 
+// DeleteTasksByJobId mocks base method.
+func (m *MockTaskRepositoryInterface) DeleteTasksByJobId(ctx context.Context, jobId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTasksByJobId", ctx, jobId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTasksByJobId indicates an expected call of DeleteTasksByJobId.
+func (mr *MockTaskRepositoryInterfaceMockRecorder) DeleteTasksByJobId(ctx, jobId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTasksByJobId", reflect.TypeOf((*MockTaskRepositoryInterface)(nil).DeleteTasksByJobId), ctx, jobId)
+}
+
 // GetTasksByJobId mocks base method.
 func (m *MockTaskRepositoryInterface) GetTasksByJobId(ctx context.Context, jobId string) ([]models.Task, error) {
 	m.ctrl.T.Helper()
@@ -89,6 +103,21 @@ func (mr *MockTaskRepositoryInterfaceMockRecorder) GetTasksByJobId(ctx, jobId an
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTasksByJobId", reflect.TypeOf((*MockTaskRepositoryInterface)(nil).GetTasksByJobId), ctx, jobId)
 }
 
+// GetTasksByJobIds mocks base method.
+func (m *MockTaskRepositoryInterface) GetTasksByJobIds(ctx context.Context, jobIds []string) (map[string][]models.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTasksByJobIds", ctx, jobIds)
+	ret0, _ := ret[0].(map[string][]models.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTasksByJobIds indicates an expected call of GetTasksByJobIds.
+func (mr *MockTaskRepositoryInterfaceMockRecorder) GetTasksByJobIds(ctx, jobIds any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTasksByJobIds", reflect.TypeOf((*MockTaskRepositoryInterface)(nil).GetTasksByJobIds), ctx, jobIds)
+}
+
 // UpdateSubTaskByKey mocks base method.
 func (m *MockTaskRepositoryInterface) UpdateSubTaskByKey(ctx context.Context, jobId string, taskType models.TaskType, key string, subtask models.SubTask) error {
 	m.ctrl.T.Helper()
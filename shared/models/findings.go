@@ -0,0 +1,136 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Warning codes, each one has an entry in the finding catalog below
+const (
+	// WarningClientSideRendered is raised for pages that appear to be rendered by JavaScript
+	WarningClientSideRendered = "client_side_rendered"
+	// WarningTaskFailed is raised for each task that failed while the rest of the analysis completed
+	WarningTaskFailed = "task_failed"
+	// WarningSampledVerification is raised when only a sample of the links was verified to stay within the time budget
+	WarningSampledVerification = "sampled_verification"
+	// WarningCanonicalMismatch is raised when the page's canonical URL is another URL than the one it was served from
+	WarningCanonicalMismatch = "canonical_mismatch"
+	// WarningInsecureForm is raised for each form exposing what users enter in it, see InsecureForm
+	WarningInsecureForm = "insecure_form"
+	// WarningErrorPage is raised when the analyzed page was served with a status other than 2xx, such as a 404 page
+	WarningErrorPage = "error_page"
+	// WarningAMPRequirementMissing is raised for each markup an AMP page requires but lacks
+	WarningAMPRequirementMissing = "amp_requirement_missing"
+)
+
+// FindingSeverity ranks how much a finding matters
+type FindingSeverity string
+
+const (
+	// FindingSeverityError is a problem with the page or its result that needs fixing
+	FindingSeverityError FindingSeverity = "error"
+	// FindingSeverityWarning is something likely wrong, or that makes the result less reliable
+	FindingSeverityWarning FindingSeverity = "warning"
+	// FindingSeverityInfo tells how the page was analyzed, rather than what is wrong with it
+	FindingSeverityInfo FindingSeverity = "info"
+)
+
+// findingSeverities lists every finding severity, the most severe first
+var findingSeverities = []FindingSeverity{
+	FindingSeverityError,
+	FindingSeverityWarning,
+	FindingSeverityInfo,
+}
+
+// FindingSeverities returns every finding severity, the most severe first
+func FindingSeverities() []FindingSeverity {
+	return slices.Clone(findingSeverities)
+}
+
+// ParseFindingSeverity validates a finding severity name
+func ParseFindingSeverity(value string) (FindingSeverity, error) {
+	severity := FindingSeverity(value)
+	if !slices.Contains(findingSeverities, severity) {
+		return "", fmt.Errorf("invalid finding severity %q, expected one of %v", value, findingSeverities)
+	}
+	return severity, nil
+}
+
+// Rank orders severities from the most severe, at 0. Unknown severities rank last.
+func (s FindingSeverity) Rank() int {
+	if i := slices.Index(findingSeverities, s); i >= 0 {
+		return i
+	}
+	return len(findingSeverities)
+}
+
+// FindingDefinition is the catalog entry of a warning code, what clients show for it
+type FindingDefinition struct {
+	Code     string          `json:"code"`
+	Severity FindingSeverity `json:"severity"`
+	// Title names the finding in a few words, the warning's message tells the details of each occurrence
+	Title string `json:"title"`
+	// DocsAnchor is the anchor of the finding in the finding catalog of the README
+	DocsAnchor string `json:"docs_anchor"`
+}
+
+// findingCatalog describes every warning code. A new code needs its constant above and an entry here,
+// the analyzer's tests fail on a warning raised with a code missing from the catalog.
+var findingCatalog = []FindingDefinition{
+	{
+		Code:       WarningTaskFailed,
+		Severity:   FindingSeverityError,
+		Title:      "An analysis task failed",
+		DocsAnchor: "finding-task_failed",
+	},
+	{
+		Code:       WarningInsecureForm,
+		Severity:   FindingSeverityError,
+		Title:      "A form exposes what users enter in it",
+		DocsAnchor: "finding-insecure_form",
+	},
+	{
+		Code:       WarningErrorPage,
+		Severity:   FindingSeverityError,
+		Title:      "The page was served with an error status",
+		DocsAnchor: "finding-error_page",
+	},
+	{
+		Code:       WarningClientSideRendered,
+		Severity:   FindingSeverityWarning,
+		Title:      "The page is rendered by JavaScript",
+		DocsAnchor: "finding-client_side_rendered",
+	},
+	{
+		Code:       WarningCanonicalMismatch,
+		Severity:   FindingSeverityWarning,
+		Title:      "The canonical URL names another page",
+		DocsAnchor: "finding-canonical_mismatch",
+	},
+	{
+		Code:       WarningAMPRequirementMissing,
+		Severity:   FindingSeverityWarning,
+		Title:      "The AMP page lacks required markup",
+		DocsAnchor: "finding-amp_requirement_missing",
+	},
+	{
+		Code:       WarningSampledVerification,
+		Severity:   FindingSeverityInfo,
+		Title:      "Only a sample of the links was verified",
+		DocsAnchor: "finding-sampled_verification",
+	},
+}
+
+// FindingCatalog returns the catalog entry of every warning code, the most severe first
+func FindingCatalog() []FindingDefinition {
+	return slices.Clone(findingCatalog)
+}
+
+// LookupFinding returns the catalog entry of a warning code
+func LookupFinding(code string) (FindingDefinition, bool) {
+	i := slices.IndexFunc(findingCatalog, func(d FindingDefinition) bool { return d.Code == code })
+	if i < 0 {
+		return FindingDefinition{}, false
+	}
+	return findingCatalog[i], true
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindingCatalog(t *testing.T) {
+	codes := make(map[string]bool)
+	anchors := make(map[string]bool)
+	lastRank := 0
+	for _, definition := range FindingCatalog() {
+		assert.NotEmpty(t, definition.Code)
+		assert.False(t, codes[definition.Code], "duplicate code %s", definition.Code)
+		codes[definition.Code] = true
+
+		_, err := ParseFindingSeverity(string(definition.Severity))
+		assert.NoError(t, err, "code %s", definition.Code)
+		assert.GreaterOrEqual(t, definition.Severity.Rank(), lastRank, "code %s is listed after a less severe one", definition.Code)
+		lastRank = definition.Severity.Rank()
+
+		assert.NotEmpty(t, definition.Title, "code %s", definition.Code)
+		assert.NotEmpty(t, definition.DocsAnchor, "code %s", definition.Code)
+		assert.False(t, anchors[definition.DocsAnchor], "duplicate docs anchor %s", definition.DocsAnchor)
+		anchors[definition.DocsAnchor] = true
+
+		found, ok := LookupFinding(definition.Code)
+		assert.True(t, ok)
+		assert.Equal(t, definition, found)
+	}
+
+	_, ok := LookupFinding("unknown")
+	assert.False(t, ok)
+}
+
+func TestParseFindingSeverity(t *testing.T) {
+	severity, err := ParseFindingSeverity("warning")
+	assert.NoError(t, err)
+	assert.Equal(t, FindingSeverityWarning, severity)
+
+	_, err = ParseFindingSeverity("critical")
+	assert.Error(t, err)
+	_, err = ParseFindingSeverity("")
+	assert.Error(t, err)
+}
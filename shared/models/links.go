@@ -0,0 +1,45 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ClassifyLink reports whether the link is external to the page at pageURL, along with the rule that decided it.
+// Only a link with the page's scheme and host is internal, subdomains and other ports included are external.
+func ClassifyLink(link, pageURL string) (bool, LinkClassificationReason) {
+	// If no page URL is set, assume external
+	if pageURL == "" {
+		return true, LinkReasonNoBaseURL
+	}
+
+	target, err := url.Parse(link)
+	if err != nil {
+		return true, LinkReasonUnparsable
+	}
+	page, err := url.Parse(pageURL)
+	if err != nil {
+		return true, LinkReasonUnparsable
+	}
+
+	// Same scheme and host: internal
+	if target.Scheme == page.Scheme && target.Host == page.Host {
+		return false, LinkReasonSameOrigin
+	}
+
+	// Different schemes: external
+	if target.Scheme != page.Scheme {
+		return true, LinkReasonDifferentScheme
+	}
+
+	// The rest differ by host, told apart only to explain the classification
+	targetHost, pageHost := target.Hostname(), page.Hostname()
+	switch {
+	case targetHost == pageHost:
+		return true, LinkReasonDifferentPort
+	case strings.HasSuffix(targetHost, "."+pageHost):
+		return true, LinkReasonSubdomain
+	default:
+		return true, LinkReasonDifferentHost
+	}
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"fmt"
+	"slices"
 	"time"
 )
 
@@ -14,6 +16,234 @@ type Job struct {
 	StartedAt   *time.Time     `json:"started_at"`
 	CompletedAt *time.Time     `json:"completed_at"`
 	Result      *AnalyzeResult `json:"result"`
+	GroupID     string         `json:"group_id,omitempty"`
+	// Tasks lists the analysis tasks selected for the job, all of them run when empty
+	Tasks []TaskType `json:"tasks,omitempty"`
+	// Progress is the share of the job done, in percent
+	Progress float64 `json:"progress"`
+	// VerifyScope limits link verification to internal or external links, the analyzer's configured scope applies when empty
+	VerifyScope LinkScope `json:"verify_scope,omitempty"`
+	// HeaderProfile selects the request headers links are verified with, the analyzer's configured profile applies when empty
+	HeaderProfile HeaderProfile `json:"header_profile,omitempty"`
+	// Version counts the updates made to the job, a conditional update only applies at the version it expects
+	Version int64 `json:"version"`
+	// DeletedAt is set while the job is in the trash, it can be restored until the restore window has passed
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// FailureCode and FailureReason tell why a failed job failed, as a stable code and a message for users
+	FailureCode   FailureCode `json:"failure_code,omitempty"`
+	FailureReason string      `json:"failure_reason,omitempty"`
+	// FetchStatusCode is the HTTP status the page was served with, such as 403 for a page that refused the fetch.
+	// It is left out until the page was fetched, and when no response came back.
+	FetchStatusCode int `json:"fetch_status_code,omitempty"`
+	// AttemptID identifies the analysis that last started the job, only that analysis may finish it
+	AttemptID string `json:"attempt_id,omitempty"`
+	// LastHeartbeatAt is when the analysis running the job last reported it is still alive
+	LastHeartbeatAt *time.Time `json:"last_heartbeat_at,omitempty"`
+
+	StatusHistory []StatusChange `json:"status_history,omitempty"`
+}
+
+// IsDeleted reports whether the job has been moved to the trash
+func (j *Job) IsDeleted() bool {
+	return j.DeletedAt != nil
+}
+
+// RunsTask reports whether the task is selected for the job
+func (j *Job) RunsTask(taskType TaskType) bool {
+	return len(j.Tasks) == 0 || slices.Contains(j.Tasks, taskType)
+}
+
+// SkippedTasks returns the tasks not selected for the job, in the order they would run
+func (j *Job) SkippedTasks() []TaskType {
+	var skipped []TaskType
+	for _, taskType := range taskTypes {
+		if !j.RunsTask(taskType) {
+			skipped = append(skipped, taskType)
+		}
+	}
+	return skipped
+}
+
+// Warning flags something about the analyzed page that makes its result less reliable, or that is misconfigured
+type Warning struct {
+	// Code identifies the kind of warning, for clients to react to. Every code has an entry in the finding catalog.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// InsecureForm is a form on the page that exposes what users enter in it
+type InsecureForm struct {
+	// Action is the URL the form submits to, resolved against the page
+	Action string             `json:"action"`
+	Reason InsecureFormReason `json:"reason"`
+}
+
+// InsecureFormReason tells how a form exposes what users enter in it
+type InsecureFormReason string
+
+const (
+	// InsecureFormHTTPAction is a form on an HTTPS page submitting to an http:// URL, sending its fields unencrypted
+	InsecureFormHTTPAction InsecureFormReason = "http_action"
+	// InsecureFormPasswordGET is a form with a password field submitting with GET, putting the password in the URL
+	InsecureFormPasswordGET InsecureFormReason = "password_get"
+)
+
+// LinkRedirect is a link that was redirected to another URL
+type LinkRedirect struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// LinkClassification explains why a link was counted as internal or external
+type LinkClassification struct {
+	URL      string                   `json:"url"`
+	External bool                     `json:"external"`
+	Reason   LinkClassificationReason `json:"reason"`
+}
+
+// LinkClassificationReason names the rule a link was classified by
+type LinkClassificationReason string
+
+const (
+	// LinkReasonSameOrigin is the only internal reason: the link has the page's scheme and host
+	LinkReasonSameOrigin      LinkClassificationReason = "same_origin"
+	LinkReasonNoBaseURL       LinkClassificationReason = "no_base_url"
+	LinkReasonUnparsable      LinkClassificationReason = "unparsable_url"
+	LinkReasonDifferentScheme LinkClassificationReason = "different_scheme"
+	LinkReasonDifferentPort   LinkClassificationReason = "different_port"
+	// LinkReasonSubdomain is a host under the page's own, such as blog.example.com linked from example.com
+	LinkReasonSubdomain     LinkClassificationReason = "subdomain"
+	LinkReasonDifferentHost LinkClassificationReason = "different_host"
+)
+
+// CanonicalConsistency tells whether the page's canonical URL and og:url both name the URL it was served from.
+// Reason is the first inconsistency found, empty when they match.
+type CanonicalConsistency struct {
+	Matches bool                         `json:"matches"`
+	Reason  CanonicalInconsistencyReason `json:"reason,omitempty"`
+}
+
+// Pagination is the rel="next" chain a page is part of. NextURL and PrevURL are the first next and previous
+// pages it links to, resolved against the page. Pages is only filled when the analyzer follows the chain:
+// it lists the analyzed page then each next page merged into the result, in chain order.
+type Pagination struct {
+	NextURL string           `json:"next_url,omitempty"`
+	PrevURL string           `json:"prev_url,omitempty"`
+	Pages   []PaginationPage `json:"pages,omitempty"`
+	// StopReason tells why the chain was not followed to its end
+	StopReason PaginationStopReason `json:"stop_reason,omitempty"`
+}
+
+// PaginationPage is a page of a followed chain. Its links are Links[LinkOffset:LinkOffset+LinkCount] of the result,
+// those past the end of Links were dropped when the result or its links were truncated.
+type PaginationPage struct {
+	URL        string         `json:"url"`
+	Headings   map[string]int `json:"headings"`
+	LinkOffset int            `json:"link_offset"`
+	LinkCount  int            `json:"link_count"`
+}
+
+// PaginationStopReason names why following a rel="next" chain stopped before a page without a next one
+type PaginationStopReason string
+
+const (
+	PaginationStopMaxPages PaginationStopReason = "max_pages"
+	// PaginationStopLoop is a next page already in the chain, such as a page naming itself as next
+	PaginationStopLoop PaginationStopReason = "loop"
+	// PaginationStopOtherHost is a next page on another host than the analyzed page, which is never followed
+	PaginationStopOtherHost   PaginationStopReason = "other_host"
+	PaginationStopFetchFailed PaginationStopReason = "fetch_failed"
+)
+
+// FetchTiming is the time spent in each phase of a page fetch, in seconds.
+// DNSSeconds, ConnectSeconds and TLSHandshakeSeconds are zero when a kept-alive connection was reused,
+// DNSSeconds also when the host was answered by the DNS cache and TLSHandshakeSeconds when the page was served over http.
+// TimeToFirstByteSeconds and TotalSeconds are measured from when the request was sent, through any redirects,
+// DownloadSeconds from the first byte of the response to the end of its body.
+type FetchTiming struct {
+	DNSSeconds             float64 `json:"dns_seconds"`
+	ConnectSeconds         float64 `json:"connect_seconds"`
+	TLSHandshakeSeconds    float64 `json:"tls_handshake_seconds"`
+	TimeToFirstByteSeconds float64 `json:"ttfb_seconds"`
+	DownloadSeconds        float64 `json:"download_seconds"`
+	TotalSeconds           float64 `json:"total_seconds"`
+}
+
+// CanonicalInconsistencyReason names the way a page's canonical URL or og:url disagrees with the page's URL
+type CanonicalInconsistencyReason string
+
+const (
+	// CanonicalReasonMissing is a page without a canonical link, leaving search engines to pick its URL
+	CanonicalReasonMissing CanonicalInconsistencyReason = "missing_canonical"
+	// CanonicalReasonDifferentHost is a canonical URL on another domain than the page
+	CanonicalReasonDifferentHost CanonicalInconsistencyReason = "different_host"
+	// CanonicalReasonInsecure is an http canonical URL on a page served over https
+	CanonicalReasonInsecure CanonicalInconsistencyReason = "insecure_canonical"
+	// CanonicalReasonDifferentURL is a canonical URL naming another page on the same host
+	CanonicalReasonDifferentURL CanonicalInconsistencyReason = "different_url"
+	// CanonicalReasonOpenGraphMismatch is an og:url other than the page's URL, while the canonical URL matches
+	CanonicalReasonOpenGraphMismatch CanonicalInconsistencyReason = "og_url_mismatch"
+)
+
+// LinkScope selects which of a page's links are verified
+type LinkScope string
+
+const (
+	LinkScopeAll      LinkScope = "all"
+	LinkScopeInternal LinkScope = "internal"
+	LinkScopeExternal LinkScope = "external"
+)
+
+// ParseLinkScope validates a link scope, an empty value is returned unchanged
+func ParseLinkScope(value string) (LinkScope, error) {
+	switch scope := LinkScope(value); scope {
+	case "", LinkScopeAll, LinkScopeInternal, LinkScopeExternal:
+		return scope, nil
+	default:
+		return "", fmt.Errorf("invalid link scope %q, expected %s, %s or %s",
+			value, LinkScopeAll, LinkScopeInternal, LinkScopeExternal)
+	}
+}
+
+// Includes reports whether an internal or external link is in scope, an empty scope includes every link
+func (s LinkScope) Includes(external bool) bool {
+	switch s {
+	case LinkScopeInternal:
+		return !external
+	case LinkScopeExternal:
+		return external
+	default:
+		return true
+	}
+}
+
+// HeaderProfile selects the request headers links are verified with
+type HeaderProfile string
+
+const (
+	// HeaderProfileDefault sends the HTTP client's own headers, identifying the analyzer as a program
+	HeaderProfileDefault HeaderProfile = "default"
+	// HeaderProfileBrowser sends the headers of a desktop browser, for sites that block other clients
+	HeaderProfileBrowser HeaderProfile = "browser"
+	// HeaderProfileFallback sends the default headers, then the browser's for a link answered with 403 or 429
+	HeaderProfileFallback HeaderProfile = "fallback"
+)
+
+// ParseHeaderProfile validates a header profile, an empty value is returned unchanged
+func ParseHeaderProfile(value string) (HeaderProfile, error) {
+	switch profile := HeaderProfile(value); profile {
+	case "", HeaderProfileDefault, HeaderProfileBrowser, HeaderProfileFallback:
+		return profile, nil
+	default:
+		return "", fmt.Errorf("invalid header profile %q, expected %s, %s or %s",
+			value, HeaderProfileDefault, HeaderProfileBrowser, HeaderProfileFallback)
+	}
+}
+
+// StatusChange records when a job entered a status
+type StatusChange struct {
+	Status JobStatus `json:"status"`
+	At     time.Time `json:"at"`
 }
 
 // JobStatus represents the overall status of a job
@@ -27,6 +257,67 @@ const (
 	JobStatusCancelled JobStatus = "cancelled"
 )
 
+// jobStatuses lists every job status, in the order a job goes through them
+var jobStatuses = []JobStatus{
+	JobStatusPending,
+	JobStatusRunning,
+	JobStatusCompleted,
+	JobStatusFailed,
+	JobStatusCancelled,
+}
+
+// JobStatuses returns every job status
+func JobStatuses() []JobStatus {
+	return slices.Clone(jobStatuses)
+}
+
+// ParseJobStatus validates a job status name
+func ParseJobStatus(value string) (JobStatus, error) {
+	status := JobStatus(value)
+	if !slices.Contains(jobStatuses, status) {
+		return "", fmt.Errorf("invalid job status %q, expected one of %v", value, jobStatuses)
+	}
+	return status, nil
+}
+
+// IsTerminal reports whether the job has reached a final status
+func (s JobStatus) IsTerminal() bool {
+	return s == JobStatusCompleted || s == JobStatusFailed || s == JobStatusCancelled
+}
+
+// FailureCode classifies why a job failed
+type FailureCode string
+
+const (
+	// FailureCodeFetch means the page could not be fetched, the site was unreachable or refused it
+	FailureCodeFetch FailureCode = "fetch_failed"
+	// FailureCodeParse means the fetched page could not be parsed as HTML
+	FailureCodeParse FailureCode = "parse_failed"
+	// FailureCodeRepository means the job could not be read or updated in storage
+	FailureCodeRepository FailureCode = "repository_error"
+	// FailureCodeInternal means the analysis failed for an unexpected reason, such as a panic
+	FailureCodeInternal FailureCode = "internal_error"
+)
+
+// Group represents a set of jobs submitted together for side-by-side comparison
+type Group struct {
+	ID          string      `json:"id"`
+	Label       string      `json:"label"`
+	JobIDs      []string    `json:"job_ids"`
+	Status      GroupStatus `json:"status"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	CompletedAt *time.Time  `json:"completed_at"`
+}
+
+// GroupStatus represents the overall status of a group
+type GroupStatus string
+
+const (
+	GroupStatusPending   GroupStatus = "pending"
+	GroupStatusCompleted GroupStatus = "completed"
+)
+
 // Task represents an individual task within a job
 type Task struct {
 	JobID    string             `json:"job_id"`
@@ -45,6 +336,36 @@ const (
 	TaskTypeVerifyingLinks     TaskType = "verifying_links"
 )
 
+// taskTypes is the registry of known task types, in the order they run
+var taskTypes = []TaskType{
+	TaskTypeExtracting,
+	TaskTypeIdentifyingVersion,
+	TaskTypeAnalyzing,
+	TaskTypeVerifyingLinks,
+}
+
+// RegisterTaskType adds a task type to the registry, to run after the ones already registered.
+// Every job gets a task of each registered type, and only registered types are accepted in messages and the API.
+// The weight is the task's share of the job progress, relative to the weights of the other task types.
+// It is meant to be called from an init function and panics on an empty or duplicate type.
+func RegisterTaskType(taskType TaskType, weight float64) {
+	if taskType == "" || IsValidTaskType(taskType) {
+		panic("models: invalid or duplicate task type " + string(taskType))
+	}
+	taskTypes = append(taskTypes, taskType)
+	taskWeights[taskType] = weight
+}
+
+// TaskTypes returns the registered task types in the order they run
+func TaskTypes() []TaskType {
+	return slices.Clone(taskTypes)
+}
+
+// IsValidTaskType reports whether the task type is registered
+func IsValidTaskType(taskType TaskType) bool {
+	return slices.Contains(taskTypes, taskType)
+}
+
 // TaskStatus represents the status of a task
 type TaskStatus string
 
@@ -56,19 +377,45 @@ const (
 	TaskStatusSkipped   TaskStatus = "skipped"
 )
 
+// IsTerminal reports whether the task or subtask has reached a final status
+func (s TaskStatus) IsTerminal() bool {
+	return s == TaskStatusCompleted || s == TaskStatusFailed || s == TaskStatusSkipped
+}
+
 // SubTask represents a subtask within a task
 type SubTask struct {
 	Type        SubTaskType `json:"type"`
 	Status      TaskStatus  `json:"status"`
 	URL         string      `json:"url"`
 	Description string      `json:"description"`
+	// ContentType is the media type of a verified link that is not a web page, such as a PDF or a zip.
+	// ContentLength is its size in bytes, nil when the server did not report it.
+	ContentType   string `json:"content_type,omitempty"`
+	ContentLength *int64 `json:"content_length,omitempty"`
+	// ErrorCode classifies why a link could not be requested, it is empty when a response came back
+	ErrorCode LinkErrorCode `json:"error_code,omitempty"`
 }
 
 // SubTaskType represents the type of a subtask
 type SubTaskType string
 
 const (
-	SubTaskTypeValidatingLink SubTaskType = "validating_link"
+	SubTaskTypeValidatingLink  SubTaskType = "validating_link"
+	SubTaskTypeValidatingImage SubTaskType = "validating_image"
+)
+
+// LinkErrorCode is a stable name for the failure of a link request that got no response
+type LinkErrorCode string
+
+const (
+	LinkErrorDNSNotFound       LinkErrorCode = "dns_not_found"
+	LinkErrorConnectionRefused LinkErrorCode = "connection_refused"
+	LinkErrorConnectionReset   LinkErrorCode = "connection_reset"
+	LinkErrorTimeout           LinkErrorCode = "timeout"
+	LinkErrorTLSCertificate    LinkErrorCode = "tls_certificate_invalid"
+	LinkErrorTLSHandshake      LinkErrorCode = "tls_handshake_failure"
+	LinkErrorTooManyRedirects  LinkErrorCode = "too_many_redirects"
+	LinkErrorRequestFailed     LinkErrorCode = "request_failed"
 )
 
 // AnalyzeResult represents the result of an analysis
@@ -82,4 +429,118 @@ type AnalyzeResult struct {
 	AccessibleLinks   int            `json:"accessible_links"`
 	InaccessibleLinks int            `json:"inaccessible_links"`
 	HasLoginForm      bool           `json:"has_login_form"`
+	ExcludedLinks     int            `json:"excluded_links"`
+	// InsecureForms lists the forms exposing what users enter in it, a form may be listed once per reason.
+	// Each one comes with an insecure_form warning.
+	InsecureForms []InsecureForm `json:"insecure_forms,omitempty"`
+	// OutOfScopeLinks counts the links skipped because they fall outside the job's verify scope
+	OutOfScopeLinks int `json:"out_of_scope_links"`
+	// UnverifiedLinks counts the links left unverified because the analysis ended before reaching them,
+	// or because they were left out of the sample verified when the time budget ran short
+	UnverifiedLinks int `json:"unverified_links"`
+	// SampledVerification is set when the time budget ran short and only a sample of the links left was verified.
+	// SampleFraction is the share of those links in the sample. The estimates extrapolate the accessibility found
+	// in the sample of internal and external links to the links left out, on top of the links actually verified.
+	SampledVerification        bool    `json:"sampled_verification,omitempty"`
+	SampleFraction             float64 `json:"sample_fraction,omitempty"`
+	EstimatedAccessibleLinks   int     `json:"estimated_accessible_links,omitempty"`
+	EstimatedInaccessibleLinks int     `json:"estimated_inaccessible_links,omitempty"`
+	// RedundantRedirectLinks lists the links to the page's own site redirecting to another variant of them the page
+	// also links to, differing only by a trailing slash or a www prefix. RedundantRedirectCount is their number.
+	RedundantRedirectLinks []LinkRedirect `json:"redundant_redirect_links,omitempty"`
+	RedundantRedirectCount int            `json:"redundant_redirect_count"`
+	// BrokenAnchors lists the in-page fragment links, such as #pricing, whose ID no element on the page has.
+	// It is only filled when the analyzer checks anchors, BrokenAnchorCount is their number.
+	BrokenAnchors     []string `json:"broken_anchors,omitempty"`
+	BrokenAnchorCount int      `json:"broken_anchor_count"`
+	// AssetLinks counts the accessible links that are not web pages by media type, such as application/pdf
+	AssetLinks map[string]int `json:"asset_links,omitempty"`
+	// LinkClassifications explains for each link why it was counted as internal or external, in the order of Links.
+	// It is only filled when the analyzer explains link classification.
+	LinkClassifications []LinkClassification `json:"link_classifications,omitempty"`
+	// OriginalLinks lists the links as the page wrote them, in the order of Links, when the analyzer strips tracking
+	// query parameters from links. CollapsedLinks counts the links left out of Links and the counts because,
+	// once stripped, they were the same URL as an earlier link.
+	OriginalLinks  []string `json:"original_links,omitempty"`
+	CollapsedLinks int      `json:"collapsed_links,omitempty"`
+	// TrackersDetected names the third-party trackers and analytics the page's scripts load, such as Google Analytics.
+	// TrackerCount is their number.
+	TrackersDetected []string `json:"trackers_detected,omitempty"`
+	TrackerCount     int      `json:"tracker_count"`
+	// CanonicalURL is the page's rel="canonical" URL and OpenGraphURL its og:url, both resolved against the URL
+	// the page was served from. CanonicalMismatch is set when the canonical URL is another URL, ignoring case
+	// in the host, default ports, trailing slashes and fragments.
+	CanonicalURL         string                `json:"canonical_url,omitempty"`
+	CanonicalMismatch    bool                  `json:"canonical_mismatch,omitempty"`
+	OpenGraphURL         string                `json:"og_url,omitempty"`
+	CanonicalConsistency *CanonicalConsistency `json:"canonical_consistency,omitempty"`
+	// IsAMP is set for AMP pages, marked by the ⚡ or amp attribute of their <html> element
+	IsAMP bool `json:"is_amp,omitempty"`
+	// Pagination is set when the page links to a next or previous page with rel="next" or rel="prev".
+	// When the analyzer follows the chain, the headings and links of the next pages are merged into the result.
+	Pagination *Pagination `json:"pagination,omitempty"`
+	// NoIndex and NoFollow are set when the page asks not to be indexed, or its links not to be followed,
+	// in a <meta name="robots"> or its X-Robots-Tag header. The none directive sets both.
+	NoIndex  bool `json:"no_index,omitempty"`
+	NoFollow bool `json:"no_follow,omitempty"`
+
+	ImageCount         int `json:"image_count"`
+	AccessibleImages   int `json:"accessible_images"`
+	InaccessibleImages int `json:"inaccessible_images"`
+	UnverifiedImages   int `json:"unverified_images"`
+
+	InternalLinkDepthHistogram map[string]int `json:"internal_link_depth_histogram"`
+	MaxInternalLinkDepth       int            `json:"max_internal_link_depth"`
+	NavOnlyPage                bool           `json:"nav_only_page"`
+
+	// LikelyClientSideRendered is set when the served HTML looks like a shell for JavaScript to render into,
+	// the counts then describe the shell rather than the page users see
+	LikelyClientSideRendered bool `json:"likely_client_side_rendered"`
+	// Warnings explain why parts of the result may be unreliable
+	Warnings []Warning `json:"warnings,omitempty"`
+
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+
+	// ContentEncoding is the content coding the page was served with, empty when it was not encoded.
+	// TransferredBytes is the size of the page body as received and ContentBytes its size once decoded.
+	ContentEncoding  string `json:"content_encoding,omitempty"`
+	TransferredBytes int64  `json:"transferred_bytes,omitempty"`
+	ContentBytes     int64  `json:"content_bytes,omitempty"`
+	// RedirectChain lists the URLs the page was reached through, from the submitted URL to the analyzed one.
+	// It is empty when the page was not redirected.
+	RedirectChain []string `json:"redirect_chain,omitempty"`
+	// FetchTiming breaks down the time taken by the successful fetch of the page
+	FetchTiming *FetchTiming `json:"fetch_timing,omitempty"`
+
+	// PartialResult is set while the job is running and when link verification did not finish,
+	// so link accessibility counts are incomplete. A running job stores it once the page is analyzed.
+	PartialResult bool `json:"partial_result"`
+
+	// SkippedTasks lists the tasks that were not selected for the job, their fields are left empty
+	SkippedTasks []TaskType `json:"skipped_tasks,omitempty"`
+	// FailedTasks lists the tasks that failed while the others completed, their fields may be empty or incomplete.
+	// Each one comes with a task_failed warning giving the reason.
+	FailedTasks []TaskType `json:"failed_tasks,omitempty"`
+
+	// Truncated is set when the stored result was trimmed to fit the database item size limit,
+	// so Links and ResponseHeaders may be incomplete and LinkClassifications missing while the counts remain accurate
+	Truncated bool `json:"truncated,omitempty"`
+	// LinksTruncated is set when the page had more links than the analyzer stores, so Links and the lists in its
+	// order, BrokenAnchors, RedundantRedirectLinks and InsecureForms hold at most that many entries while the counts
+	// cover every link
+	LinksTruncated bool `json:"links_truncated,omitempty"`
+	// LinksOmitted is set on a result broadcast without its link lists, the job holds them in full.
+	// It is never stored.
+	LinksOmitted bool `json:"links_omitted,omitempty"`
+}
+
+// WithoutLinks returns a copy of the result without Links, RedundantRedirectLinks, LinkClassifications and
+// OriginalLinks, keeping their counts
+func (r AnalyzeResult) WithoutLinks() AnalyzeResult {
+	r.Links = nil
+	r.RedundantRedirectLinks = nil
+	r.LinkClassifications = nil
+	r.OriginalLinks = nil
+	r.LinksOmitted = true
+	return r
 }
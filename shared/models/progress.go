@@ -0,0 +1,73 @@
+package models
+
+import "math"
+
+// Progress weights of the built-in task types, in percent of the whole job
+const (
+	ExtractingWeight         = 10.0
+	IdentifyingVersionWeight = 5.0
+	AnalyzingWeight          = 25.0
+	VerifyingLinksWeight     = 60.0
+)
+
+// taskWeights holds the progress weight of every registered task type
+var taskWeights = map[TaskType]float64{
+	TaskTypeExtracting:         ExtractingWeight,
+	TaskTypeIdentifyingVersion: IdentifyingVersionWeight,
+	TaskTypeAnalyzing:          AnalyzingWeight,
+	TaskTypeVerifyingLinks:     VerifyingLinksWeight,
+}
+
+// TaskState is the state of a single task the job progress is computed from
+type TaskState struct {
+	Type   TaskType
+	Status TaskStatus
+	// Completed and Total count the finished and all subtasks of a running task, when it reports them
+	Completed int
+	Total     int
+}
+
+// TerminalProgress returns the progress a job is set to once it reaches a terminal status:
+// 100 when completed, 0 when failed or cancelled. It reports false for the other statuses.
+func TerminalProgress(status JobStatus) (float64, bool) {
+	switch status {
+	case JobStatusCompleted:
+		return 100, true
+	case JobStatusFailed, JobStatusCancelled:
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// JobProgress returns the share of a job done in percent, rounded to one decimal.
+// Completed and skipped tasks count for their full weight, a running task for the share of its subtasks finished.
+func JobProgress(status JobStatus, tasks []TaskState) float64 {
+	if progress, ok := TerminalProgress(status); ok {
+		return progress
+	}
+	if status == JobStatusPending {
+		return 0
+	}
+
+	var total float64
+	for _, weight := range taskWeights {
+		total += weight
+	}
+	if total <= 0 {
+		return 0
+	}
+
+	var done float64
+	for _, task := range tasks {
+		weight := taskWeights[task.Type]
+		switch {
+		case task.Status == TaskStatusCompleted || task.Status == TaskStatusSkipped:
+			done += weight
+		case task.Status == TaskStatusRunning && task.Total > 0:
+			done += weight * float64(min(task.Completed, task.Total)) / float64(task.Total)
+		}
+	}
+
+	return math.Round(done/total*1000) / 10
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobProgress(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   JobStatus
+		tasks    []TaskState
+		expected float64
+	}{
+		{
+			name:     "Pending",
+			status:   JobStatusPending,
+			expected: 0,
+		},
+		{
+			name:   "ExtractingRunning",
+			status: JobStatusRunning,
+			tasks: []TaskState{
+				{Type: TaskTypeExtracting, Status: TaskStatusRunning},
+				{Type: TaskTypeIdentifyingVersion, Status: TaskStatusPending},
+			},
+			expected: 0,
+		},
+		{
+			name:   "ExtractedAndIdentified",
+			status: JobStatusRunning,
+			tasks: []TaskState{
+				{Type: TaskTypeExtracting, Status: TaskStatusCompleted},
+				{Type: TaskTypeIdentifyingVersion, Status: TaskStatusCompleted},
+				{Type: TaskTypeAnalyzing, Status: TaskStatusRunning},
+			},
+			expected: 15,
+		},
+		{
+			name:   "VerifyingHalfway",
+			status: JobStatusRunning,
+			tasks: []TaskState{
+				{Type: TaskTypeExtracting, Status: TaskStatusCompleted},
+				{Type: TaskTypeIdentifyingVersion, Status: TaskStatusCompleted},
+				{Type: TaskTypeAnalyzing, Status: TaskStatusCompleted},
+				{Type: TaskTypeVerifyingLinks, Status: TaskStatusRunning, Completed: 5, Total: 10},
+			},
+			expected: 70,
+		},
+		{
+			name:   "VerifyingRoundsToOneDecimal",
+			status: JobStatusRunning,
+			tasks: []TaskState{
+				{Type: TaskTypeExtracting, Status: TaskStatusCompleted},
+				{Type: TaskTypeIdentifyingVersion, Status: TaskStatusCompleted},
+				{Type: TaskTypeAnalyzing, Status: TaskStatusCompleted},
+				{Type: TaskTypeVerifyingLinks, Status: TaskStatusRunning, Completed: 1, Total: 7},
+			},
+			expected: 48.6,
+		},
+		{
+			name:   "SkippedTasksCountAsDone",
+			status: JobStatusRunning,
+			tasks: []TaskState{
+				{Type: TaskTypeExtracting, Status: TaskStatusCompleted},
+				{Type: TaskTypeIdentifyingVersion, Status: TaskStatusRunning},
+				{Type: TaskTypeAnalyzing, Status: TaskStatusSkipped},
+				{Type: TaskTypeVerifyingLinks, Status: TaskStatusSkipped},
+			},
+			expected: 95,
+		},
+		{
+			name:   "FailedTaskCountsNothing",
+			status: JobStatusRunning,
+			tasks: []TaskState{
+				{Type: TaskTypeExtracting, Status: TaskStatusCompleted},
+				{Type: TaskTypeIdentifyingVersion, Status: TaskStatusFailed},
+			},
+			expected: 10,
+		},
+		{
+			name:   "CompletedForcesFull",
+			status: JobStatusCompleted,
+			tasks: []TaskState{
+				{Type: TaskTypeExtracting, Status: TaskStatusCompleted},
+			},
+			expected: 100,
+		},
+		{
+			name:   "FailedForcesZero",
+			status: JobStatusFailed,
+			tasks: []TaskState{
+				{Type: TaskTypeExtracting, Status: TaskStatusCompleted},
+				{Type: TaskTypeIdentifyingVersion, Status: TaskStatusCompleted},
+				{Type: TaskTypeAnalyzing, Status: TaskStatusCompleted},
+				{Type: TaskTypeVerifyingLinks, Status: TaskStatusFailed, Completed: 9, Total: 10},
+			},
+			expected: 0,
+		},
+		{
+			name:     "CancelledForcesZero",
+			status:   JobStatusCancelled,
+			tasks:    []TaskState{{Type: TaskTypeExtracting, Status: TaskStatusCompleted}},
+			expected: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, JobProgress(tc.status, tc.tasks))
+		})
+	}
+}
+
+func TestTaskWeights_SumToFullJob(t *testing.T) {
+	var total float64
+	for _, taskType := range TaskTypes() {
+		total += taskWeights[taskType]
+	}
+	assert.Equal(t, 100.0, total)
+}
@@ -0,0 +1,344 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"shared/config"
+	"shared/tracing"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+//go:generate mockgen -destination=../mocks/mock_backups.go -package=mocks . BackupRepositoryInterface
+
+// ErrBackupsUnsupported is returned when the database has no backup API, as with DynamoDB Local
+var ErrBackupsUnsupported = errors.New("backups are not supported by this database")
+
+// ErrInvalidBackupLabel is returned for a backup label that cannot be part of a backup name
+var ErrInvalidBackupLabel = errors.New("backup labels are 1 to 64 letters, digits, dots, dashes or underscores")
+
+// ErrUnknownTable is returned for a table that is not one of the platform's tables
+var ErrUnknownTable = errors.New("unknown table")
+
+// unknownOperationCode is the error code of a request for an API the endpoint does not implement
+const unknownOperationCode = "UnknownOperationException"
+
+// backupLabelPattern matches the labels allowed in backup names, which DynamoDB restricts to these characters
+var backupLabelPattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+// TableNames returns the names of the platform's tables, in the order they are backed up
+func TableNames() []string {
+	return []string{JobsTableName, TasksTableName, GroupsTableName}
+}
+
+// Backup is an on-demand backup of a table
+type Backup struct {
+	ARN       string    `json:"arn"`
+	Name      string    `json:"name"`
+	Table     string    `json:"table"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	// SizeBytes is the size of the backup, zero until DynamoDB has measured it
+	SizeBytes int64 `json:"size_bytes"`
+}
+
+// RestoreReport compares a table restored from a backup with the table it was backed up from
+type RestoreReport struct {
+	SourceTable   string `json:"source_table"`
+	RestoredTable string `json:"restored_table"`
+	SourceItems   int64  `json:"source_items"`
+	RestoredItems int64  `json:"restored_items"`
+	// Sampled is the number of source items looked up in the restored table. Missing and Mismatched are the keys of
+	// those it lacks and of those it holds with other attributes.
+	Sampled    int      `json:"sampled"`
+	Missing    []string `json:"missing,omitempty"`
+	Mismatched []string `json:"mismatched,omitempty"`
+}
+
+// Matches reports whether the restored table holds as many items as the source and every sampled item unchanged.
+// Items written to the source since the backup make the counts differ, so compare against a quiet table.
+func (r *RestoreReport) Matches() bool {
+	return r.SourceItems == r.RestoredItems && len(r.Missing) == 0 && len(r.Mismatched) == 0
+}
+
+type BackupRepositoryInterface interface {
+	CreateBackup(ctx context.Context, tableName, label string) (*Backup, error)
+	ListBackups(ctx context.Context, tableName string) ([]Backup, error)
+	VerifyRestore(ctx context.Context, sourceTable, restoredTable string, sampleSize int) (*RestoreReport, error)
+}
+
+// BackupOption is a function that configures the BackupRepository
+type BackupOption func(*BackupRepository)
+
+// WithBackupMetrics sets the metrics collector
+func WithBackupMetrics(mc MetricsCollector) BackupOption {
+	return func(b *BackupRepository) {
+		b.mc = mc
+	}
+}
+
+// WithBackupDynamoDBClient overrides the DynamoDB client, mainly for tests
+func WithBackupDynamoDBClient(ddb dynamodbiface.DynamoDBAPI) BackupOption {
+	return func(b *BackupRepository) {
+		b.ddb = ddb
+	}
+}
+
+// WithBackupClock sets the clock backup names are stamped with, mainly for tests
+func WithBackupClock(now func() time.Time) BackupOption {
+	return func(b *BackupRepository) {
+		b.now = now
+	}
+}
+
+// BackupRepository triggers and lists on-demand backups of the tables, and checks the tables restored from them.
+// Restoring is left to the AWS console or CLI, as it creates a new table that the services must be pointed at.
+type BackupRepository struct {
+	ddb dynamodbiface.DynamoDBAPI
+	mc  MetricsCollector
+	now func() time.Time
+}
+
+// NewBackupRepository creates a new backup repository
+func NewBackupRepository(cfg config.DynamoDBConfig, opts ...BackupOption) (*BackupRepository, error) {
+	ddb, err := NewDynamoDBClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := &BackupRepository{ddb: ddb, mc: NoOpMetricsCollector{}, now: time.Now}
+	for _, opt := range opts {
+		opt(repo)
+	}
+
+	return repo, nil
+}
+
+// CreateBackup starts an on-demand backup of one of the platform's tables. The backup is named after the table,
+// the label and the time, so backups taken with the same label before each deploy stay apart.
+func (b *BackupRepository) CreateBackup(ctx context.Context, tableName, label string) (backup *Backup, err error) {
+	if !slices.Contains(TableNames(), tableName) {
+		return nil, fmt.Errorf("%w %q", ErrUnknownTable, tableName)
+	}
+	if !backupLabelPattern.MatchString(label) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidBackupLabel, label)
+	}
+
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "create_backup", tableName)
+
+	defer func() {
+		b.mc.RecordDatabaseOperation("create_backup", tableName, start, err)
+		span.Close(err)
+	}()
+
+	output, err := b.ddb.CreateBackupWithContext(ctx, &dynamodb.CreateBackupInput{
+		TableName:  aws.String(tableName),
+		BackupName: aws.String(tableName + "-" + label + "-" + b.now().UTC().Format("20060102T150405Z")),
+	})
+	if isAWSError(err, unknownOperationCode) {
+		return nil, ErrBackupsUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	details := output.BackupDetails
+	return &Backup{
+		ARN:       aws.StringValue(details.BackupArn),
+		Name:      aws.StringValue(details.BackupName),
+		Table:     tableName,
+		Status:    aws.StringValue(details.BackupStatus),
+		CreatedAt: aws.TimeValue(details.BackupCreationDateTime),
+		SizeBytes: aws.Int64Value(details.BackupSizeBytes),
+	}, nil
+}
+
+// ListBackups lists the on-demand backups of one of the platform's tables, or of all of them when tableName is
+// empty, newest first
+func (b *BackupRepository) ListBackups(ctx context.Context, tableName string) (backups []Backup, err error) {
+	if tableName != "" && !slices.Contains(TableNames(), tableName) {
+		return nil, fmt.Errorf("%w %q", ErrUnknownTable, tableName)
+	}
+
+	// Listing every table's backups is recorded against all of them
+	table := tableName
+	if table == "" {
+		table = "all"
+	}
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "list_backups", table)
+
+	defer func() {
+		b.mc.RecordDatabaseOperation("list_backups", table, start, err)
+		span.Close(err)
+	}()
+
+	input := &dynamodb.ListBackupsInput{BackupType: aws.String(dynamodb.BackupTypeFilterUser)}
+	if tableName != "" {
+		input.TableName = aws.String(tableName)
+	}
+
+	backups = make([]Backup, 0)
+	for {
+		output, err := b.ddb.ListBackupsWithContext(ctx, input)
+		if isAWSError(err, unknownOperationCode) {
+			return nil, ErrBackupsUnsupported
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, summary := range output.BackupSummaries {
+			if !slices.Contains(TableNames(), aws.StringValue(summary.TableName)) {
+				continue
+			}
+			backups = append(backups, Backup{
+				ARN:       aws.StringValue(summary.BackupArn),
+				Name:      aws.StringValue(summary.BackupName),
+				Table:     aws.StringValue(summary.TableName),
+				Status:    aws.StringValue(summary.BackupStatus),
+				CreatedAt: aws.TimeValue(summary.BackupCreationDateTime),
+				SizeBytes: aws.Int64Value(summary.BackupSizeBytes),
+			})
+		}
+
+		if output.LastEvaluatedBackupArn == nil {
+			break
+		}
+		input.ExclusiveStartBackupArn = output.LastEvaluatedBackupArn
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// VerifyRestore compares a table restored from a backup with the table it was backed up from. Both tables are
+// counted in full, and the first sampleSize items of the source are looked up by key in the restored table.
+// Both scans read every item, so run it off-peak on large tables.
+func (b *BackupRepository) VerifyRestore(ctx context.Context, sourceTable, restoredTable string, sampleSize int) (report *RestoreReport, err error) {
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "verify_restore", restoredTable)
+
+	defer func() {
+		b.mc.RecordDatabaseOperation("verify_restore", restoredTable, start, err)
+		span.Close(err)
+	}()
+
+	keys, err := b.keyAttributes(ctx, sourceTable)
+	if err != nil {
+		return nil, err
+	}
+
+	report = &RestoreReport{SourceTable: sourceTable, RestoredTable: restoredTable}
+	if report.SourceItems, err = b.countItems(ctx, sourceTable); err != nil {
+		return nil, err
+	}
+	if report.RestoredItems, err = b.countItems(ctx, restoredTable); err != nil {
+		return nil, err
+	}
+	if sampleSize <= 0 {
+		return report, nil
+	}
+
+	sample, err := b.ddb.ScanWithContext(ctx, &dynamodb.ScanInput{
+		TableName: aws.String(sourceTable),
+		Limit:     aws.Int64(int64(sampleSize)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample %s: %w", sourceTable, err)
+	}
+
+	for _, item := range sample.Items {
+		key := make(map[string]*dynamodb.AttributeValue, len(keys))
+		for _, name := range keys {
+			key[name] = item[name]
+		}
+
+		restored, err := b.ddb.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(restoredTable),
+			Key:            key,
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read a sampled item from %s: %w", restoredTable, err)
+		}
+
+		report.Sampled++
+		switch {
+		case restored.Item == nil:
+			report.Missing = append(report.Missing, describeKey(keys, key))
+		case !reflect.DeepEqual(item, restored.Item):
+			report.Mismatched = append(report.Mismatched, describeKey(keys, key))
+		}
+	}
+
+	return report, nil
+}
+
+// keyAttributes returns the names of the key attributes of a table, the partition key first
+func (b *BackupRepository) keyAttributes(ctx context.Context, tableName string) ([]string, error) {
+	output, err := b.ddb.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe %s: %w", tableName, err)
+	}
+
+	var keys []string
+	for _, element := range output.Table.KeySchema {
+		if aws.StringValue(element.KeyType) == dynamodb.KeyTypeHash {
+			keys = append([]string{aws.StringValue(element.AttributeName)}, keys...)
+		} else {
+			keys = append(keys, aws.StringValue(element.AttributeName))
+		}
+	}
+	return keys, nil
+}
+
+// countItems counts the items of a table with a full scan, as the item count DynamoDB reports lags by hours
+func (b *BackupRepository) countItems(ctx context.Context, tableName string) (int64, error) {
+	var count int64
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(tableName),
+		Select:    aws.String(dynamodb.SelectCount),
+	}
+	for {
+		output, err := b.ddb.ScanWithContext(ctx, input)
+		if err != nil {
+			return 0, fmt.Errorf("failed to count the items of %s: %w", tableName, err)
+		}
+		count += aws.Int64Value(output.Count)
+
+		if len(output.LastEvaluatedKey) == 0 {
+			return count, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// describeKey formats the key of an item as name=value pairs, in the order of keys
+func describeKey(keys []string, key map[string]*dynamodb.AttributeValue) string {
+	parts := make([]string, 0, len(keys))
+	for _, name := range keys {
+		value := key[name]
+		switch {
+		case value == nil:
+			parts = append(parts, name+"=?")
+		case value.S != nil:
+			parts = append(parts, name+"="+*value.S)
+		case value.N != nil:
+			parts = append(parts, name+"="+*value.N)
+		default:
+			parts = append(parts, name+"="+value.String())
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,300 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"shared/config"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackupTables is an in-memory stand-in for the backup calls and for tables keyed by partition_key and id.
+// Scans return pageSize items per page, and with unsupported every backup call fails as on DynamoDB Local.
+type fakeBackupTables struct {
+	dynamodbiface.DynamoDBAPI
+	tables      map[string][]map[string]*dynamodb.AttributeValue
+	backups     []*dynamodb.BackupSummary
+	pageSize    int
+	unsupported bool
+}
+
+func newFakeBackupTables() *fakeBackupTables {
+	return &fakeBackupTables{tables: make(map[string][]map[string]*dynamodb.AttributeValue), pageSize: 2}
+}
+
+// put adds an item with the given ID and URL to a table
+func (f *fakeBackupTables) put(table, id, url string) {
+	f.tables[table] = append(f.tables[table], map[string]*dynamodb.AttributeValue{
+		"partition_key": {S: aws.String("1000")},
+		"id":            {S: aws.String(id)},
+		"url":           {S: aws.String(url)},
+	})
+}
+
+func (f *fakeBackupTables) CreateBackupWithContext(_ aws.Context, input *dynamodb.CreateBackupInput, _ ...request.Option) (*dynamodb.CreateBackupOutput, error) {
+	if f.unsupported {
+		return nil, awserr.New(unknownOperationCode, "An unknown operation was requested.", nil)
+	}
+	created := time.Date(2024, 5, 1, 12, 0, len(f.backups), 0, time.UTC)
+	arn := aws.String("arn:backup/" + strconv.Itoa(len(f.backups)))
+	f.backups = append(f.backups, &dynamodb.BackupSummary{
+		BackupArn:              arn,
+		BackupName:             input.BackupName,
+		TableName:              input.TableName,
+		BackupStatus:           aws.String(dynamodb.BackupStatusCreating),
+		BackupCreationDateTime: aws.Time(created),
+	})
+	return &dynamodb.CreateBackupOutput{BackupDetails: &dynamodb.BackupDetails{
+		BackupArn:              arn,
+		BackupName:             input.BackupName,
+		BackupStatus:           aws.String(dynamodb.BackupStatusCreating),
+		BackupCreationDateTime: aws.Time(created),
+	}}, nil
+}
+
+// ListBackups returns one backup per page, oldest first
+func (f *fakeBackupTables) ListBackupsWithContext(_ aws.Context, input *dynamodb.ListBackupsInput, _ ...request.Option) (*dynamodb.ListBackupsOutput, error) {
+	if f.unsupported {
+		return nil, awserr.New(unknownOperationCode, "An unknown operation was requested.", nil)
+	}
+
+	output := &dynamodb.ListBackupsOutput{}
+	started := input.ExclusiveStartBackupArn == nil
+	for _, backup := range f.backups {
+		if !started {
+			started = *backup.BackupArn == *input.ExclusiveStartBackupArn
+			continue
+		}
+		if input.TableName != nil && *backup.TableName != *input.TableName {
+			continue
+		}
+		if len(output.BackupSummaries) == 1 {
+			output.LastEvaluatedBackupArn = output.BackupSummaries[0].BackupArn
+			break
+		}
+		output.BackupSummaries = append(output.BackupSummaries, backup)
+	}
+	return output, nil
+}
+
+func (f *fakeBackupTables) DescribeTableWithContext(_ aws.Context, input *dynamodb.DescribeTableInput, _ ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	if _, ok := f.tables[*input.TableName]; !ok {
+		return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "table not found", nil)
+	}
+	return &dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{
+		TableName: input.TableName,
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("id"), KeyType: aws.String(dynamodb.KeyTypeRange)},
+			{AttributeName: aws.String("partition_key"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+		},
+	}}, nil
+}
+
+func (f *fakeBackupTables) ScanWithContext(_ aws.Context, input *dynamodb.ScanInput, _ ...request.Option) (*dynamodb.ScanOutput, error) {
+	items := f.tables[*input.TableName]
+	if input.ExclusiveStartKey != nil {
+		for i, item := range items {
+			if *item["id"].S == *input.ExclusiveStartKey["id"].S {
+				items = items[i+1:]
+				break
+			}
+		}
+	}
+
+	size := f.pageSize
+	if input.Limit != nil {
+		size = int(*input.Limit)
+	}
+	output := &dynamodb.ScanOutput{}
+	if len(items) > size {
+		items = items[:size]
+		output.LastEvaluatedKey = items[size-1]
+	}
+	output.Count = aws.Int64(int64(len(items)))
+	if aws.StringValue(input.Select) != dynamodb.SelectCount {
+		output.Items = items
+	}
+	return output, nil
+}
+
+func (f *fakeBackupTables) GetItemWithContext(_ aws.Context, input *dynamodb.GetItemInput, _ ...request.Option) (*dynamodb.GetItemOutput, error) {
+	for _, item := range f.tables[*input.TableName] {
+		if *item["id"].S == *input.Key["id"].S && *item["partition_key"].S == *input.Key["partition_key"].S {
+			return &dynamodb.GetItemOutput{Item: item}, nil
+		}
+	}
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func newTestBackupRepository(tables *fakeBackupTables) *BackupRepository {
+	return &BackupRepository{
+		ddb: tables,
+		mc:  NoOpMetricsCollector{},
+		now: func() time.Time { return time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC) },
+	}
+}
+
+func TestBackupRepository_CreateBackup(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Created", func(t *testing.T) {
+		repo := newTestBackupRepository(newFakeBackupTables())
+
+		backup, err := repo.CreateBackup(ctx, JobsTableName, "pre-deploy")
+		require.NoError(t, err)
+		assert.Equal(t, JobsTableName+"-pre-deploy-20240501T123000Z", backup.Name)
+		assert.Equal(t, JobsTableName, backup.Table)
+		assert.Equal(t, dynamodb.BackupStatusCreating, backup.Status)
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		tables := newFakeBackupTables()
+		tables.unsupported = true
+
+		_, err := newTestBackupRepository(tables).CreateBackup(ctx, JobsTableName, "pre-deploy")
+		assert.ErrorIs(t, err, ErrBackupsUnsupported)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		repo := newTestBackupRepository(newFakeBackupTables())
+
+		_, err := repo.CreateBackup(ctx, "web-analyzer-users", "pre-deploy")
+		assert.ErrorIs(t, err, ErrUnknownTable)
+		_, err = repo.CreateBackup(ctx, JobsTableName, "before sharding")
+		assert.ErrorIs(t, err, ErrInvalidBackupLabel)
+		_, err = repo.CreateBackup(ctx, JobsTableName, "")
+		assert.ErrorIs(t, err, ErrInvalidBackupLabel)
+	})
+}
+
+func TestBackupRepository_ListBackups(t *testing.T) {
+	ctx := context.Background()
+	tables := newFakeBackupTables()
+	repo := newTestBackupRepository(tables)
+	for _, table := range []string{JobsTableName, TasksTableName, JobsTableName} {
+		_, err := repo.CreateBackup(ctx, table, "pre-deploy")
+		require.NoError(t, err)
+	}
+	// Backups of other tables in the account are left out
+	tables.backups = append(tables.backups, &dynamodb.BackupSummary{
+		BackupArn: aws.String("arn:backup/other"),
+		TableName: aws.String("billing"),
+	})
+
+	backups, err := repo.ListBackups(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, backups, 3)
+	assert.Equal(t, []string{JobsTableName, TasksTableName, JobsTableName},
+		[]string{backups[0].Table, backups[1].Table, backups[2].Table}, "newest first")
+	assert.True(t, backups[0].CreatedAt.After(backups[2].CreatedAt))
+
+	backups, err = repo.ListBackups(ctx, TasksTableName)
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.Equal(t, TasksTableName, backups[0].Table)
+
+	tables.unsupported = true
+	_, err = repo.ListBackups(ctx, JobsTableName)
+	assert.ErrorIs(t, err, ErrBackupsUnsupported)
+}
+
+func TestBackupRepository_VerifyRestore(t *testing.T) {
+	const restored = JobsTableName + "-restored"
+
+	testCases := []struct {
+		name       string
+		restore    func(tables *fakeBackupTables)
+		sampleSize int
+		expected   RestoreReport
+	}{
+		{
+			name: "Identical",
+			restore: func(tables *fakeBackupTables) {
+				tables.tables[restored] = append(tables.tables[restored], tables.tables[JobsTableName]...)
+			},
+			sampleSize: 10,
+			expected:   RestoreReport{SourceItems: 5, RestoredItems: 5, Sampled: 5},
+		},
+		{
+			name: "MissingAndChanged",
+			restore: func(tables *fakeBackupTables) {
+				tables.put(restored, "job-1", "https://example.com/1")
+				tables.put(restored, "job-2", "https://example.com/changed")
+				tables.put(restored, "job-4", "https://example.com/4")
+				tables.put(restored, "job-5", "https://example.com/5")
+			},
+			sampleSize: 3,
+			expected: RestoreReport{
+				SourceItems:   5,
+				RestoredItems: 4,
+				Sampled:       3,
+				Missing:       []string{"partition_key=1000, id=job-3"},
+				Mismatched:    []string{"partition_key=1000, id=job-2"},
+			},
+		},
+		{
+			name:     "CountsOnly",
+			restore:  func(tables *fakeBackupTables) { tables.put(restored, "job-1", "https://example.com/1") },
+			expected: RestoreReport{SourceItems: 5, RestoredItems: 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tables := newFakeBackupTables()
+			for _, id := range []string{"1", "2", "3", "4", "5"} {
+				tables.put(JobsTableName, "job-"+id, "https://example.com/"+id)
+			}
+			tc.restore(tables)
+
+			report, err := newTestBackupRepository(tables).VerifyRestore(context.Background(), JobsTableName, restored, tc.sampleSize)
+			require.NoError(t, err)
+
+			tc.expected.SourceTable = JobsTableName
+			tc.expected.RestoredTable = restored
+			assert.Equal(t, &tc.expected, report)
+			assert.Equal(t, tc.name == "Identical", report.Matches())
+		})
+	}
+
+	_, err := newTestBackupRepository(newFakeBackupTables()).VerifyRestore(context.Background(), JobsTableName, restored, 10)
+	assert.True(t, isAWSError(err, dynamodb.ErrCodeResourceNotFoundException), "a source table that does not exist fails the check")
+}
+
+// TestBackupRepository_DynamoDBLocal runs against the DynamoDB Local at DYNAMODB_LOCAL_ENDPOINT, when set.
+// DynamoDB Local has no backup API, which is reported rather than failed.
+func TestBackupRepository_DynamoDBLocal(t *testing.T) {
+	endpoint := os.Getenv("DYNAMODB_LOCAL_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_LOCAL_ENDPOINT is not set")
+	}
+
+	cfg := config.DynamoDBConfig{Region: "us-east-1", Endpoint: endpoint, AccessKeyID: "local", SecretAccessKey: "local"}
+	client, err := NewDynamoDBClient(cfg)
+	require.NoError(t, err)
+	require.NoError(t, SeedTables(client, config.DynamoDBConfig{WaitForTables: true, TableWaitTimeout: time.Minute}, NoOpMetricsCollector{}))
+	repo, err := NewBackupRepository(cfg)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	report, err := repo.VerifyRestore(ctx, JobsTableName, JobsTableName, 10)
+	require.NoError(t, err)
+	assert.True(t, report.Matches(), "a table matches itself")
+
+	backup, err := repo.CreateBackup(ctx, JobsTableName, "local-test")
+	if errors.Is(err, ErrBackupsUnsupported) {
+		t.Skip("backups are not supported by this endpoint")
+	}
+	require.NoError(t, err)
+	assert.Equal(t, JobsTableName, backup.Table)
+}
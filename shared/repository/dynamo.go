@@ -1,17 +1,27 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"math"
 	"shared/config"
-	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
+// ErrNotFound is matched by the errors of reads for items that do not exist, such as ErrJobNotFound.
+// A missing item is the caller's problem rather than the database's, so it is not counted as a failed operation.
+var ErrNotFound = errors.New("not found")
+
 // NewDynamoDBClient creates a new DynamoDB client
 func NewDynamoDBClient(cfg config.DynamoDBConfig) (*dynamodb.DynamoDB, error) {
 	sess, err := session.NewSession(&aws.Config{
@@ -32,34 +42,92 @@ func NewDynamoDBClient(cfg config.DynamoDBConfig) (*dynamodb.DynamoDB, error) {
 	return client, nil
 }
 
-// SeedTables seeds the DynamoDB tables
-func SeedTables(client *dynamodb.DynamoDB, cfg config.DynamoDBConfig, mc MetricsCollector) error {
-	err := createJobsTableIfNotExists(client, JobsTableName, mc)
-	if err != nil {
-		return err
+// tableWaitDelay is how often a table being created is polled until it is active
+const tableWaitDelay = time.Second
+
+// SeedTables creates the DynamoDB tables that do not exist yet.
+// Services starting together against a fresh database race to create the same tables: losing the race is not an
+// error, and with cfg.WaitForTables the tables are awaited until active, up to cfg.TableWaitTimeout, so the service
+// does not start using a table still being created.
+func SeedTables(client dynamodbiface.DynamoDBAPI, cfg config.DynamoDBConfig, mc MetricsCollector) error {
+	ctx := context.Background()
+	if cfg.WaitForTables && cfg.TableWaitTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.TableWaitTimeout)
+		defer cancel()
 	}
 
-	err = createTasksTableIfNotExists(client, TasksTableName, mc)
-	if err != nil {
-		return err
+	for _, input := range []*dynamodb.CreateTableInput{
+		jobsTableInput(JobsTableName, cfg.ResultAttributes),
+		tasksTableInput(TasksTableName),
+		groupsTableInput(GroupsTableName),
+	} {
+		if err := createTableIfNotExists(ctx, client, input, cfg.WaitForTables, mc); err != nil {
+			return fmt.Errorf("failed to seed table %s: %w", *input.TableName, err)
+		}
 	}
 
 	return nil
 }
 
-// createJobsTableIfNotExists creates the jobs table if it doesn't exist
-func createJobsTableIfNotExists(client *dynamodb.DynamoDB, tableName string, mc MetricsCollector) error {
-	// Check if table exists
-	_, err := client.DescribeTable(&dynamodb.DescribeTableInput{
+// createTableIfNotExists creates a table unless it exists, then waits for it to be active when wait is set
+func createTableIfNotExists(ctx context.Context, client dynamodbiface.DynamoDBAPI, input *dynamodb.CreateTableInput,
+	wait bool, mc MetricsCollector) error {
+	tableName := *input.TableName
+
+	output, err := client.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
 		TableName: aws.String(tableName),
 	})
-	if err == nil {
-		return nil // Table already exists
+	switch {
+	case err == nil:
+		if aws.StringValue(output.Table.TableStatus) == dynamodb.TableStatusActive {
+			return nil
+		}
+		// Another service has just created it
+	case isAWSError(err, dynamodb.ErrCodeResourceNotFoundException):
+		if err := createTable(ctx, client, input, mc); err != nil {
+			return err
+		}
+	default:
+		return err
 	}
 
+	if !wait {
+		return nil
+	}
+	return client.WaitUntilTableExistsWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)},
+		request.WithWaiterDelay(request.ConstantWaiterDelay(tableWaitDelay)),
+		// The context deadline bounds the wait
+		request.WithWaiterMaxAttempts(math.MaxInt32))
+}
+
+// createTable creates a table, treating its creation by another service in the meantime as done
+func createTable(ctx context.Context, client dynamodbiface.DynamoDBAPI, input *dynamodb.CreateTableInput, mc MetricsCollector) (err error) {
 	start := time.Now()
-	defer mc.RecordDatabaseOperation("create", tableName, start, nil)
+	defer func() { mc.RecordDatabaseOperation("create", *input.TableName, start, err) }()
 
+	_, err = client.CreateTableWithContext(ctx, input)
+	if isAWSError(err, dynamodb.ErrCodeResourceInUseException) {
+		slog.Info("DynamoDB table created concurrently", "table", *input.TableName)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Created DynamoDB table", "table", *input.TableName)
+	return nil
+}
+
+// isAWSError reports whether err is an AWS error with the given code
+func isAWSError(err error, code string) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == code
+}
+
+// jobsTableInput describes the jobs table, keyed by a partition key and the job ID.
+// withResultIndex adds the index over the result attributes, see ResultIndexName.
+func jobsTableInput(tableName string, withResultIndex bool) *dynamodb.CreateTableInput {
 	input := &dynamodb.CreateTableInput{
 		TableName: aws.String(tableName),
 		KeySchema: []*dynamodb.KeySchemaElement{
@@ -84,33 +152,36 @@ func createJobsTableIfNotExists(client *dynamodb.DynamoDB, tableName string, mc
 		},
 		BillingMode: aws.String("PAY_PER_REQUEST"),
 	}
-
-	_, err = client.CreateTable(input)
-	if err != nil {
-		if strings.Contains(err.Error(), "Cannot create preexisting table") {
-			return nil
-		}
-		return err
+	if !withResultIndex {
+		return input
 	}
 
-	slog.Info("Created DynamoDB jobs table", "table", tableName)
-	return nil
-}
-
-// createTasksTableIfNotExists creates the tasks table if it doesn't exist
-func createTasksTableIfNotExists(client *dynamodb.DynamoDB, tableName string, mc MetricsCollector) error {
-	// Check if table exists
-	_, err := client.DescribeTable(&dynamodb.DescribeTableInput{
-		TableName: aws.String(tableName),
+	input.AttributeDefinitions = append(input.AttributeDefinitions, &dynamodb.AttributeDefinition{
+		AttributeName: aws.String("external_link_count"),
+		AttributeType: aws.String("N"),
 	})
-	if err == nil {
-		return nil // Table already exists
+	input.GlobalSecondaryIndexes = []*dynamodb.GlobalSecondaryIndex{
+		{
+			IndexName: aws.String(ResultIndexName),
+			KeySchema: []*dynamodb.KeySchemaElement{
+				{
+					AttributeName: aws.String("partition_key"),
+					KeyType:       aws.String("HASH"),
+				},
+				{
+					AttributeName: aws.String("external_link_count"),
+					KeyType:       aws.String("RANGE"),
+				},
+			},
+			Projection: &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+		},
 	}
+	return input
+}
 
-	start := time.Now()
-	defer mc.RecordDatabaseOperation("create", tableName, start, nil)
-
-	input := &dynamodb.CreateTableInput{
+// tasksTableInput describes the tasks table, keyed by the job ID and the task type
+func tasksTableInput(tableName string) *dynamodb.CreateTableInput {
+	return &dynamodb.CreateTableInput{
 		TableName: aws.String(tableName),
 		KeySchema: []*dynamodb.KeySchemaElement{
 			{
@@ -134,15 +205,62 @@ func createTasksTableIfNotExists(client *dynamodb.DynamoDB, tableName string, mc
 		},
 		BillingMode: aws.String("PAY_PER_REQUEST"),
 	}
+}
 
-	_, err = client.CreateTable(input)
-	if err != nil {
-		if strings.Contains(err.Error(), "Cannot create preexisting table") {
+// groupsTableInput describes the groups table, keyed by the group ID
+func groupsTableInput(tableName string) *dynamodb.CreateTableInput {
+	return &dynamodb.CreateTableInput{
+		TableName: aws.String(tableName),
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String("id"),
+				KeyType:       aws.String("HASH"),
+			},
+		},
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String("id"),
+				AttributeType: aws.String("S"),
+			},
+		},
+		BillingMode: aws.String("PAY_PER_REQUEST"),
+	}
+}
+
+// batchGetItems reads up to maxBatchGetKeys items of a table, handing each one found to read.
+// Keys DynamoDB leaves unprocessed are retried with an exponential backoff from delay, up to maxBatchGetAttempts
+// requests. noun names the items in the error returned when some are still left.
+func batchGetItems(ctx context.Context, ddb dynamodbiface.DynamoDBAPI, table, noun string, keys []map[string]*dynamodb.AttributeValue,
+	delay time.Duration, read func(item map[string]*dynamodb.AttributeValue) error) error {
+	requestItems := map[string]*dynamodb.KeysAndAttributes{
+		table: {Keys: keys},
+	}
+	for attempt := 1; ; attempt++ {
+		output, err := ddb.BatchGetItemWithContext(ctx, &dynamodb.BatchGetItemInput{RequestItems: requestItems})
+		if err != nil {
+			return err
+		}
+
+		for _, item := range output.Responses[table] {
+			if err := read(item); err != nil {
+				return err
+			}
+		}
+
+		unprocessed := output.UnprocessedKeys[table]
+		if unprocessed == nil || len(unprocessed.Keys) == 0 {
 			return nil
 		}
-		return err
-	}
+		if attempt == maxBatchGetAttempts {
+			return fmt.Errorf("%d %s left unprocessed after %d attempts", len(unprocessed.Keys), noun, attempt)
+		}
 
-	slog.Info("Created DynamoDB tasks table", "table", tableName)
-	return nil
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay *= 2
+		requestItems = map[string]*dynamodb.KeysAndAttributes{table: unprocessed}
+	}
 }
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"shared/config"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTableAdmin is an in-memory stand-in for the table management calls made at startup.
+// Tables missing from statuses do not exist; those in racing are created by another service just before us.
+type fakeTableAdmin struct {
+	dynamodbiface.DynamoDBAPI
+	statuses    map[string]string
+	racing      map[string]bool
+	describeErr error
+	created     []string
+	awaited     []string
+}
+
+func newFakeTableAdmin() *fakeTableAdmin {
+	return &fakeTableAdmin{statuses: make(map[string]string), racing: make(map[string]bool)}
+}
+
+func (f *fakeTableAdmin) DescribeTableWithContext(_ aws.Context, input *dynamodb.DescribeTableInput, _ ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	if f.describeErr != nil {
+		return nil, f.describeErr
+	}
+	status, ok := f.statuses[*input.TableName]
+	if !ok {
+		return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "Cannot do operations on a non-existent table", nil)
+	}
+	return &dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{
+		TableName:   input.TableName,
+		TableStatus: aws.String(status),
+	}}, nil
+}
+
+func (f *fakeTableAdmin) CreateTableWithContext(_ aws.Context, input *dynamodb.CreateTableInput, _ ...request.Option) (*dynamodb.CreateTableOutput, error) {
+	if f.racing[*input.TableName] {
+		f.statuses[*input.TableName] = dynamodb.TableStatusCreating
+		return nil, awserr.New(dynamodb.ErrCodeResourceInUseException, "Table already exists: "+*input.TableName, nil)
+	}
+	f.created = append(f.created, *input.TableName)
+	f.statuses[*input.TableName] = dynamodb.TableStatusCreating
+	return &dynamodb.CreateTableOutput{}, nil
+}
+
+func (f *fakeTableAdmin) WaitUntilTableExistsWithContext(ctx aws.Context, input *dynamodb.DescribeTableInput, _ ...request.WaiterOption) error {
+	f.awaited = append(f.awaited, *input.TableName)
+	f.statuses[*input.TableName] = dynamodb.TableStatusActive
+	return ctx.Err()
+}
+
+func TestSeedTables(t *testing.T) {
+	allTables := []string{JobsTableName, TasksTableName, GroupsTableName}
+	waiting := config.DynamoDBConfig{WaitForTables: true, TableWaitTimeout: time.Minute}
+
+	t.Run("FreshDatabase", func(t *testing.T) {
+		admin := newFakeTableAdmin()
+
+		require.NoError(t, SeedTables(admin, waiting, NoOpMetricsCollector{}))
+		assert.Equal(t, allTables, admin.created)
+		assert.Equal(t, allTables, admin.awaited)
+	})
+
+	t.Run("ExistingTables", func(t *testing.T) {
+		admin := newFakeTableAdmin()
+		for _, table := range allTables {
+			admin.statuses[table] = dynamodb.TableStatusActive
+		}
+
+		require.NoError(t, SeedTables(admin, waiting, NoOpMetricsCollector{}))
+		assert.Empty(t, admin.created)
+		assert.Empty(t, admin.awaited)
+	})
+
+	t.Run("LostCreateRace", func(t *testing.T) {
+		admin := newFakeTableAdmin()
+		admin.racing[JobsTableName] = true
+
+		require.NoError(t, SeedTables(admin, waiting, NoOpMetricsCollector{}))
+		assert.Equal(t, []string{TasksTableName, GroupsTableName}, admin.created)
+		assert.Equal(t, allTables, admin.awaited)
+	})
+
+	t.Run("TableBeingCreated", func(t *testing.T) {
+		admin := newFakeTableAdmin()
+		for _, table := range allTables {
+			admin.statuses[table] = dynamodb.TableStatusActive
+		}
+		admin.statuses[TasksTableName] = dynamodb.TableStatusCreating
+
+		require.NoError(t, SeedTables(admin, waiting, NoOpMetricsCollector{}))
+		assert.Empty(t, admin.created)
+		assert.Equal(t, []string{TasksTableName}, admin.awaited)
+	})
+
+	t.Run("NoWait", func(t *testing.T) {
+		admin := newFakeTableAdmin()
+		admin.racing[GroupsTableName] = true
+
+		require.NoError(t, SeedTables(admin, config.DynamoDBConfig{}, NoOpMetricsCollector{}))
+		assert.Equal(t, []string{JobsTableName, TasksTableName}, admin.created)
+		assert.Empty(t, admin.awaited)
+	})
+
+	t.Run("DescribeError", func(t *testing.T) {
+		admin := newFakeTableAdmin()
+		admin.describeErr = errors.New("connection refused")
+
+		err := SeedTables(admin, waiting, NoOpMetricsCollector{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), JobsTableName)
+		assert.Empty(t, admin.created)
+	})
+
+	t.Run("ResultIndex", func(t *testing.T) {
+		assert.Empty(t, jobsTableInput(JobsTableName, false).GlobalSecondaryIndexes)
+
+		input := jobsTableInput(JobsTableName, true)
+		require.Len(t, input.GlobalSecondaryIndexes, 1)
+		assert.Equal(t, ResultIndexName, *input.GlobalSecondaryIndexes[0].IndexName)
+		assert.Contains(t, input.AttributeDefinitions, &dynamodb.AttributeDefinition{
+			AttributeName: aws.String("external_link_count"),
+			AttributeType: aws.String(dynamodb.ScalarAttributeTypeN),
+		})
+	})
+
+	t.Run("WaitTimeout", func(t *testing.T) {
+		admin := newFakeTableAdmin()
+
+		err := SeedTables(admin, config.DynamoDBConfig{WaitForTables: true, TableWaitTimeout: time.Nanosecond}, NoOpMetricsCollector{})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
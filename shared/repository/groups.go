@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shared/config"
+	"shared/models"
+	"shared/tracing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+//go:generate mockgen -destination=../mocks/mock_groups.go -package=mocks . GroupRepositoryInterface
+
+const GroupsTableName = "web-analyzer-groups"
+
+// ErrGroupNotFound is returned when a group does not exist
+var ErrGroupNotFound = fmt.Errorf("group %w", ErrNotFound)
+
+type GroupRepositoryInterface interface {
+	CreateGroup(ctx context.Context, group *models.Group) error
+	GetGroup(ctx context.Context, id string) (*models.Group, error)
+	MarkGroupCompleted(ctx context.Context, id string) error
+	SetGroupJobs(ctx context.Context, id string, jobIDs []string) error
+}
+
+// GroupOption is a function that configures the GroupRepository
+type GroupOption func(*GroupRepository)
+
+// WithGroupMetrics sets the metrics collector
+func WithGroupMetrics(mc MetricsCollector) GroupOption {
+	return func(g *GroupRepository) {
+		g.mc = mc
+	}
+}
+
+// WithGroupDynamoDBClient overrides the DynamoDB client, mainly for tests
+func WithGroupDynamoDBClient(ddb dynamodbiface.DynamoDBAPI) GroupOption {
+	return func(g *GroupRepository) {
+		g.ddb = ddb
+	}
+}
+
+// GroupRepository is a struct for group repository
+type GroupRepository struct {
+	ddb dynamodbiface.DynamoDBAPI
+	mc  MetricsCollector
+}
+
+// NewGroupRepository creates a new group repository
+func NewGroupRepository(cfg config.DynamoDBConfig, opts ...GroupOption) (*GroupRepository, error) {
+	ddb, err := NewDynamoDBClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := &GroupRepository{ddb: ddb, mc: NoOpMetricsCollector{}}
+	for _, opt := range opts {
+		opt(repo)
+	}
+
+	return repo, nil
+}
+
+// CreateGroup creates a new group
+func (g *GroupRepository) CreateGroup(ctx context.Context, group *models.Group) (err error) {
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "create_group", GroupsTableName)
+
+	defer func() {
+		g.mc.RecordDatabaseOperation("create_group", GroupsTableName, start, err)
+		span.Close(err)
+	}()
+
+	entity := &GroupEntity{}
+	entity.FromModel(group)
+
+	item, err := dynamodbattribute.MarshalMap(entity)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(GroupsTableName),
+		Item:      item,
+	}
+
+	_, err = g.ddb.PutItemWithContext(ctx, input)
+	return err
+}
+
+// GetGroup queries a group by ID
+func (g *GroupRepository) GetGroup(ctx context.Context, id string) (group *models.Group, err error) {
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "get_group", GroupsTableName)
+
+	defer func() {
+		g.mc.RecordDatabaseOperation("get_group", GroupsTableName, start, err)
+		span.Close(err)
+	}()
+
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(GroupsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+		},
+	}
+
+	result, err := g.ddb.GetItemWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Item == nil {
+		return nil, ErrGroupNotFound
+	}
+
+	var entity GroupEntity
+	err = dynamodbattribute.UnmarshalMap(result.Item, &entity)
+	if err != nil {
+		return nil, err
+	}
+
+	return entity.ToModel(), nil
+}
+
+// MarkGroupCompleted marks a group as completed. Marking an already completed group is a no-op.
+func (g *GroupRepository) MarkGroupCompleted(ctx context.Context, id string) (err error) {
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "mark_group_completed", GroupsTableName)
+
+	defer func() {
+		g.mc.RecordDatabaseOperation("mark_group_completed", GroupsTableName, start, err)
+		span.Close(err)
+	}()
+
+	now := aws.String(time.Now().UTC().Format(time.RFC3339))
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(GroupsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		UpdateExpression:    aws.String("SET #status = :completed, updated_at = :now, completed_at = :now"),
+		ConditionExpression: aws.String("attribute_exists(id) AND #status <> :completed"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":completed": {
+				S: aws.String(string(models.GroupStatusCompleted)),
+			},
+			":now": {
+				S: now,
+			},
+		},
+	}
+
+	_, err = g.ddb.UpdateItemWithContext(ctx, input)
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		// Another watcher got there first, or the group is gone
+		return nil
+	}
+	return err
+}
+
+// SetGroupJobs replaces the members of a group, such as to leave out the jobs that could not be submitted
+func (g *GroupRepository) SetGroupJobs(ctx context.Context, id string, jobIDs []string) (err error) {
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "set_group_jobs", GroupsTableName)
+
+	defer func() {
+		g.mc.RecordDatabaseOperation("set_group_jobs", GroupsTableName, start, err)
+		span.Close(err)
+	}()
+
+	ids, err := dynamodbattribute.Marshal(jobIDs)
+	if err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(GroupsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		UpdateExpression:    aws.String("SET job_ids = :job_ids, updated_at = :now"),
+		ConditionExpression: aws.String("attribute_exists(id)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":job_ids": ids,
+			":now": {
+				S: aws.String(time.Now().UTC().Format(time.RFC3339)),
+			},
+		},
+	}
+
+	_, err = g.ddb.UpdateItemWithContext(ctx, input)
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return ErrGroupNotFound
+	}
+	return err
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"shared/models"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGroupsTable is an in-memory stand-in for the groups table, keyed by id
+type fakeGroupsTable struct {
+	dynamodbiface.DynamoDBAPI
+	items   map[string]map[string]*dynamodb.AttributeValue
+	updates int
+}
+
+func newFakeGroupsTable() *fakeGroupsTable {
+	return &fakeGroupsTable{items: make(map[string]map[string]*dynamodb.AttributeValue)}
+}
+
+func (f *fakeGroupsTable) PutItemWithContext(_ aws.Context, input *dynamodb.PutItemInput, _ ...request.Option) (*dynamodb.PutItemOutput, error) {
+	f.items[*input.Item["id"].S] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeGroupsTable) GetItemWithContext(_ aws.Context, input *dynamodb.GetItemInput, _ ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: f.items[*input.Key["id"].S]}, nil
+}
+
+// UpdateItem applies the completion or members update, honouring the repository's condition expression
+func (f *fakeGroupsTable) UpdateItemWithContext(_ aws.Context, input *dynamodb.UpdateItemInput, _ ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	item, ok := f.items[*input.Key["id"].S]
+	if jobIDs, members := input.ExpressionAttributeValues[":job_ids"]; members {
+		if !ok {
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+		}
+		f.updates++
+		item["job_ids"] = jobIDs
+		item["updated_at"] = input.ExpressionAttributeValues[":now"]
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	completed := input.ExpressionAttributeValues[":completed"]
+	if !ok || *item["status"].S == *completed.S {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+
+	f.updates++
+	item["status"] = completed
+	item["updated_at"] = input.ExpressionAttributeValues[":now"]
+	item["completed_at"] = input.ExpressionAttributeValues[":now"]
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func newTestGroupRepository(table *fakeGroupsTable) *GroupRepository {
+	repo := &GroupRepository{mc: NoOpMetricsCollector{}}
+	WithGroupDynamoDBClient(table)(repo)
+	return repo
+}
+
+func TestGroupRepository_CreateAndGet(t *testing.T) {
+	repo := newTestGroupRepository(newFakeGroupsTable())
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	group := &models.Group{
+		ID:        "group-1",
+		Label:     "Competitors",
+		JobIDs:    []string{"job-1", "job-2"},
+		Status:    models.GroupStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	require.NoError(t, repo.CreateGroup(ctx, group))
+
+	got, err := repo.GetGroup(ctx, "group-1")
+	require.NoError(t, err)
+	assert.Equal(t, group, got)
+}
+
+func TestGroupRepository_GetGroup_NotFound(t *testing.T) {
+	repo := newTestGroupRepository(newFakeGroupsTable())
+
+	_, err := repo.GetGroup(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrGroupNotFound)
+}
+
+func TestGroupRepository_MarkGroupCompleted(t *testing.T) {
+	table := newFakeGroupsTable()
+	repo := newTestGroupRepository(table)
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateGroup(ctx, &models.Group{
+		ID:     "group-1",
+		JobIDs: []string{"job-1", "job-2"},
+		Status: models.GroupStatusPending,
+	}))
+
+	require.NoError(t, repo.MarkGroupCompleted(ctx, "group-1"))
+	got, err := repo.GetGroup(ctx, "group-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.GroupStatusCompleted, got.Status)
+	assert.NotNil(t, got.CompletedAt)
+
+	// A second watcher racing on the same group must not fail or rewrite the completion time
+	assert.NoError(t, repo.MarkGroupCompleted(ctx, "group-1"))
+	assert.Equal(t, 1, table.updates)
+}
+
+func TestGroupRepository_SetGroupJobs(t *testing.T) {
+	repo := newTestGroupRepository(newFakeGroupsTable())
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateGroup(ctx, &models.Group{
+		ID:     "group-1",
+		JobIDs: []string{"job-1", "job-2", "job-3"},
+		Status: models.GroupStatusPending,
+	}))
+
+	require.NoError(t, repo.SetGroupJobs(ctx, "group-1", []string{"job-1", "job-3"}))
+	got, err := repo.GetGroup(ctx, "group-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job-1", "job-3"}, got.JobIDs)
+	assert.Equal(t, models.GroupStatusPending, got.Status)
+
+	assert.ErrorIs(t, repo.SetGroupJobs(ctx, "missing", []string{"job-1"}), ErrGroupNotFound)
+}
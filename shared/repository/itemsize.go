@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// dynamoDBItemSizeLimit is the maximum size of a single DynamoDB item
+const dynamoDBItemSizeLimit = 400 * 1024
+
+// defaultMaxResultSize is used when no threshold is configured.
+// It leaves room under the item limit for the remaining job attributes.
+const defaultMaxResultSize = 350 * 1024
+
+// ErrResultTooLarge is returned when a result exceeds the size threshold with nothing left to trim
+var ErrResultTooLarge = errors.New("analysis result too large")
+
+// EstimatedSize estimates the size of the result once marshalled into a DynamoDB attribute
+func (e *AnalyzeResultEntity) EstimatedSize() (int, error) {
+	attr, err := dynamodbattribute.Marshal(e)
+	if err != nil {
+		return 0, err
+	}
+	return attributeSize(attr), nil
+}
+
+// attributeSize estimates the stored size of an attribute value following the DynamoDB sizing rules,
+// numbers are counted by their string length which overestimates them slightly
+func attributeSize(av *dynamodb.AttributeValue) int {
+	switch {
+	case av == nil:
+		return 0
+	case av.S != nil:
+		return len(*av.S)
+	case av.N != nil:
+		return len(*av.N)
+	case av.B != nil:
+		return len(av.B)
+	case av.BOOL != nil, av.NULL != nil:
+		return 1
+	case av.L != nil:
+		size := 3
+		for _, v := range av.L {
+			size += 1 + attributeSize(v)
+		}
+		return size
+	case av.M != nil:
+		size := 3
+		for k, v := range av.M {
+			size += 1 + len(k) + attributeSize(v)
+		}
+		return size
+	}
+
+	size := 3
+	for _, s := range av.SS {
+		size += len(*s)
+	}
+	for _, n := range av.NS {
+		size += len(*n)
+	}
+	for _, b := range av.BS {
+		size += len(b)
+	}
+	return size
+}
+
+// itemSize estimates the stored size of an item, attribute names included
+func itemSize(item map[string]*dynamodb.AttributeValue) int {
+	size := 0
+	for name, v := range item {
+		size += len(name) + attributeSize(v)
+	}
+	return size
+}
+
+// marshalResult marshals the result for an update, trimming it when its estimated size exceeds the threshold
+func (j *JobRepository) marshalResult(entity *AnalyzeResultEntity) (*dynamodb.AttributeValue, error) {
+	limit := j.maxResultSize
+	if limit <= 0 {
+		limit = defaultMaxResultSize
+	}
+
+	for {
+		attr, err := dynamodbattribute.Marshal(entity)
+		if err != nil {
+			return nil, err
+		}
+		if len(entity.Headings) == 0 {
+			attr.M["headings"] = &dynamodb.AttributeValue{
+				M: make(map[string]*dynamodb.AttributeValue),
+			}
+		}
+		if len(entity.Links) == 0 {
+			attr.M["links"] = &dynamodb.AttributeValue{
+				L: []*dynamodb.AttributeValue{},
+			}
+		}
+
+		size := attributeSize(attr)
+		if size <= limit {
+			j.mc.RecordResultSize(JobsTableName, size, entity.Truncated)
+			return attr, nil
+		}
+
+		if !trimResult(entity, attr, size-limit) {
+			j.mc.RecordResultSize(JobsTableName, size, entity.Truncated)
+			return nil, fmt.Errorf("%w: estimated %d bytes exceeds %d", ErrResultTooLarge, size, limit)
+		}
+	}
+}
+
+// trimResult shrinks the largest trimmable field of the result by at least excess bytes where it can,
+// using attr, its marshalled form, to size the fields. It reports false when there is nothing left to trim.
+// The link classifications are a diagnostic aid, so they go first and whole, followed by the original links
+// which only differ from the links by their tracking parameters.
+func trimResult(entity *AnalyzeResultEntity, attr *dynamodb.AttributeValue, excess int) bool {
+	linksSize := attributeSize(attr.M["links"])
+	headersSize := attributeSize(attr.M["response_headers"])
+
+	switch {
+	case len(entity.LinkClassifications) > 0:
+		entity.LinkClassifications = nil
+	case len(entity.OriginalLinks) > 0:
+		entity.OriginalLinks = nil
+	case len(entity.Links) > 0 && linksSize >= headersSize:
+		// Drop links from the end, counting each element's overhead as the estimate does
+		removed, n := 0, len(entity.Links)
+		for n > 0 && removed < excess {
+			n--
+			removed += 1 + len(entity.Links[n])
+		}
+		entity.Links = entity.Links[:n]
+	case len(entity.ResponseHeaders) > 0:
+		entity.ResponseHeaders = nil
+	default:
+		return false
+	}
+
+	entity.Truncated = true
+	return true
+}
@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"shared/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resultSizeRecorder records the result sizes reported by the repository
+type resultSizeRecorder struct {
+	NoOpMetricsCollector
+	sizes     []int
+	truncated []bool
+}
+
+func (r *resultSizeRecorder) RecordResultSize(table string, bytes int, truncated bool) {
+	r.sizes = append(r.sizes, bytes)
+	r.truncated = append(r.truncated, truncated)
+}
+
+// maximalResult builds a result shaped like a link-heavy page, well past the item size limit
+func maximalResult() *models.AnalyzeResult {
+	links := make([]string, 0, 6000)
+	for i := 0; i < cap(links); i++ {
+		links = append(links, fmt.Sprintf("https://www.example.com/category/%d/products/%s?ref=nav&utm_source=site", i, strings.Repeat("item-", 15)))
+	}
+
+	headers := make(map[string]string, 40)
+	for i := 0; i < 40; i++ {
+		headers[fmt.Sprintf("X-Header-%d", i)] = strings.Repeat("v", 1024)
+	}
+
+	return &models.AnalyzeResult{
+		HtmlVersion:       "HTML5",
+		PageTitle:         strings.Repeat("Title ", 100),
+		Headings:          map[string]int{"h1": 1, "h2": 40, "h3": 200, "h4": 80, "h5": 10, "h6": 2},
+		Links:             links,
+		InternalLinkCount: 5000,
+		ExternalLinkCount: 1000,
+		AccessibleLinks:   5800,
+		InaccessibleLinks: 200,
+		ResponseHeaders:   headers,
+
+		InternalLinkDepthHistogram: map[string]int{"1": 100, "2": 900, "3": 4000},
+		MaxInternalLinkDepth:       3,
+	}
+}
+
+func TestAttributeSize(t *testing.T) {
+	attr, err := dynamodbattribute.Marshal(map[string]any{
+		"name":  "abc",
+		"count": 42,
+		"ok":    true,
+		"list":  []string{"a", "bc"},
+	})
+	require.NoError(t, err)
+
+	// map: 3 + (1+4+3) + (1+5+2) + (1+2+1) + (1+4+list(3 + (1+1) + (1+2)))
+	assert.Equal(t, 3+8+8+4+5+8, attributeSize(attr))
+}
+
+func TestAnalyzeResultEntity_EstimatedSize(t *testing.T) {
+	small := &AnalyzeResultEntity{}
+	small.FromModel(&models.AnalyzeResult{PageTitle: "Example", Links: []string{"https://example.com"}})
+	smallSize, err := small.EstimatedSize()
+	require.NoError(t, err)
+
+	large := &AnalyzeResultEntity{}
+	large.FromModel(maximalResult())
+	largeSize, err := large.EstimatedSize()
+	require.NoError(t, err)
+
+	assert.Less(t, smallSize, 1024)
+	assert.Greater(t, largeSize, dynamoDBItemSizeLimit, "the maximal result must exceed the item limit to exercise the guard")
+}
+
+func TestJobRepository_UpdateJob_ResultSizeContract(t *testing.T) {
+	table := newFakeJobsTable()
+	recorder := &resultSizeRecorder{}
+	repo := newTestJobRepository(table)
+	WithJobMetrics(recorder)(repo)
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{
+		ID:        "job-1",
+		URL:       "https://www.example.com/" + strings.Repeat("path/", 200),
+		Status:    models.JobStatusRunning,
+		CreatedAt: time.Now().UTC(),
+	}))
+	for i := 0; i < maxStatusHistory; i++ {
+		require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusRunning))
+	}
+
+	result := maximalResult()
+	completed := models.JobStatusCompleted
+	require.NoError(t, repo.UpdateJob(ctx, "job-1", &completed, result))
+
+	item := table.items["job-1"]
+	assert.LessOrEqual(t, itemSize(item), dynamoDBItemSizeLimit, "the stored item must fit in a DynamoDB item")
+	assert.LessOrEqual(t, attributeSize(item["result"]), defaultMaxResultSize)
+
+	job, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	assert.True(t, job.Result.Truncated)
+	assert.NotEmpty(t, job.Result.Links, "links are trimmed, not dropped")
+	assert.Less(t, len(job.Result.Links), len(result.Links))
+	assert.Equal(t, result.Links[:len(job.Result.Links)], job.Result.Links, "the leading links are kept in order")
+	assert.Equal(t, result.InternalLinkCount, job.Result.InternalLinkCount, "counts are not affected by trimming")
+	assert.Len(t, result.Links, 6000, "the caller's result must not be modified")
+
+	require.Len(t, recorder.sizes, 1)
+	assert.LessOrEqual(t, recorder.sizes[0], defaultMaxResultSize)
+	assert.True(t, recorder.truncated[0])
+}
+
+func TestJobRepository_UpdateJob_ResultWithinThreshold(t *testing.T) {
+	table := newFakeJobsTable()
+	recorder := &resultSizeRecorder{}
+	repo := newTestJobRepository(table)
+	WithJobMetrics(recorder)(repo)
+	ctx := context.Background()
+
+	result := &models.AnalyzeResult{PageTitle: "Example", Links: []string{"https://example.com/a", "https://example.com/b"}}
+	require.NoError(t, repo.UpdateJob(ctx, "job-1", nil, result))
+
+	stored := table.items["job-1"]["result"]
+	assert.Len(t, stored.M["links"].L, 2)
+	assert.False(t, *stored.M["truncated"].BOOL)
+	assert.Equal(t, []bool{false}, recorder.truncated)
+}
+
+func TestJobRepository_UpdateJob_ResultTooLarge(t *testing.T) {
+	repo := newTestJobRepository(newFakeJobsTable())
+	WithMaxResultSize(512)(repo)
+
+	// Nothing trimmable is left once the links are gone, the title alone exceeds the threshold
+	result := &models.AnalyzeResult{PageTitle: strings.Repeat("t", 1024), Links: []string{"https://example.com"}}
+	err := repo.UpdateJob(context.Background(), "job-1", nil, result)
+	assert.ErrorIs(t, err, ErrResultTooLarge)
+}
+
+func TestTrimResult_PrefersLargestField(t *testing.T) {
+	entity := &AnalyzeResultEntity{
+		Links:           []string{"https://example.com/a"},
+		ResponseHeaders: map[string]string{"Content-Security-Policy": strings.Repeat("x", 2048)},
+	}
+	attr, err := dynamodbattribute.Marshal(entity)
+	require.NoError(t, err)
+
+	assert.True(t, trimResult(entity, attr, 100))
+	assert.Nil(t, entity.ResponseHeaders, "the headers are the largest field")
+	assert.Len(t, entity.Links, 1)
+	assert.True(t, entity.Truncated)
+
+	// Link classifications go before anything else, however small they are
+	explained := &AnalyzeResultEntity{
+		Links:               []string{strings.Repeat("x", 2048)},
+		LinkClassifications: []LinkClassificationEntity{{URL: "https://example.com/a", Reason: "same_origin"}},
+	}
+	attr, err = dynamodbattribute.Marshal(explained)
+	require.NoError(t, err)
+	assert.True(t, trimResult(explained, attr, 100))
+	assert.Nil(t, explained.LinkClassifications)
+	assert.Len(t, explained.Links, 1)
+
+	// The original links go next, as the links hold them without their tracking parameters
+	stripped := &AnalyzeResultEntity{
+		Links:         []string{strings.Repeat("x", 2048)},
+		OriginalLinks: []string{"https://example.com/a?utm_source=x"},
+	}
+	attr, err = dynamodbattribute.Marshal(stripped)
+	require.NoError(t, err)
+	assert.True(t, trimResult(stripped, attr, 100))
+	assert.Nil(t, stripped.OriginalLinks)
+	assert.Len(t, stripped.Links, 1)
+
+	empty := &AnalyzeResultEntity{}
+	assert.False(t, trimResult(empty, &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{"page_title": {S: aws.String("x")}}}, 1))
+}
+
+func TestAnalyzeResultEntity_RoundTrip(t *testing.T) {
+	result := &models.AnalyzeResult{
+		HtmlVersion:                "HTML5",
+		PageTitle:                  "Example",
+		Headings:                   map[string]int{"h1": 1},
+		Links:                      []string{"https://example.com/a"},
+		InternalLinkCount:          1,
+		ExternalLinkCount:          2,
+		AccessibleLinks:            3,
+		InaccessibleLinks:          4,
+		HasLoginForm:               true,
+		ExcludedLinks:              5,
+		OutOfScopeLinks:            9,
+		InsecureForms:              []models.InsecureForm{{Action: "http://example.com/login", Reason: models.InsecureFormHTTPAction}},
+		ImageCount:                 6,
+		AccessibleImages:           7,
+		InaccessibleImages:         8,
+		InternalLinkDepthHistogram: map[string]int{"1": 1},
+		MaxInternalLinkDepth:       1,
+		NavOnlyPage:                true,
+		LikelyClientSideRendered:   true,
+		Warnings:                   []models.Warning{{Code: models.WarningClientSideRendered, Message: "shell"}},
+		ResponseHeaders:            map[string]string{"server": "nginx"},
+		ContentEncoding:            "gzip",
+		TransferredBytes:           2048,
+		ContentBytes:               16384,
+		FetchTiming:                &models.FetchTiming{DNSSeconds: 0.012, ConnectSeconds: 0.03, TimeToFirstByteSeconds: 0.25, TotalSeconds: 0.4},
+		PartialResult:              true,
+		SkippedTasks:               []models.TaskType{models.TaskTypeVerifyingLinks},
+		Truncated:                  true,
+		LinksTruncated:             true,
+		LinkClassifications: []models.LinkClassification{
+			{URL: "https://example.com/a", Reason: models.LinkReasonSameOrigin},
+			{URL: "https://blog.example.com", External: true, Reason: models.LinkReasonSubdomain},
+		},
+		OriginalLinks:  []string{"https://example.com/a?utm_source=news"},
+		CollapsedLinks: 2,
+		IsAMP:          true,
+		Pagination: &models.Pagination{
+			NextURL: "https://example.com/docs/2",
+			Pages: []models.PaginationPage{
+				{URL: "https://example.com/docs", Headings: map[string]int{"h1": 1}, LinkCount: 1},
+				{URL: "https://example.com/docs/2", Headings: map[string]int{}, LinkOffset: 1},
+			},
+			StopReason: models.PaginationStopMaxPages,
+		},
+		NoIndex:  true,
+		NoFollow: true,
+	}
+
+	var entity AnalyzeResultEntity
+	entity.FromModel(result)
+	attr, err := dynamodbattribute.Marshal(entity)
+	require.NoError(t, err)
+
+	var stored AnalyzeResultEntity
+	require.NoError(t, dynamodbattribute.Unmarshal(attr, &stored))
+	assert.Equal(t, result, stored.ToModel(), "every result field should survive storage")
+}
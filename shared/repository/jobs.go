@@ -3,27 +3,92 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"shared/config"
 	"shared/models"
 	"shared/tracing"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
 //go:generate mockgen -destination=../mocks/mock_jobs.go -package=mocks . JobRepositoryInterface
 
 const JobsTableName = "web-analyzer-jobs"
 
+// maxStatusHistory caps the number of status changes kept on a job
+const maxStatusHistory = 20
+
+// appendStatusChange is the update clause appending :status_change to the job's status history
+const appendStatusChange = "status_history = list_append(if_not_exists(status_history, :empty_history), :status_change)"
+
+// incrementVersion is the update clause counting an update in the job's version.
+// Jobs stored before versioning was introduced start from zero.
+const incrementVersion = "version = if_not_exists(version, :zero) + :one"
+
+const (
+	// maxBatchGetKeys is the most keys DynamoDB accepts in a single BatchGetItem request
+	maxBatchGetKeys = 100
+	// maxBatchGetAttempts bounds the requests made for one batch while DynamoDB leaves keys unprocessed
+	maxBatchGetAttempts = 5
+	// defaultBatchRetryDelay is the wait before the first retry of unprocessed keys, doubled on each retry
+	defaultBatchRetryDelay = 50 * time.Millisecond
+)
+
+// ErrJobNotFound is returned when a job does not exist
+var ErrJobNotFound = fmt.Errorf("job %w", ErrNotFound)
+
+// ErrVersionConflict matches the error of an update made at a stale version, see VersionConflictError
+var ErrVersionConflict = errors.New("job version conflict")
+
+// ErrStaleAttempt is returned when an update made for an analysis attempt finds the job finished,
+// or started again by another attempt, and when an update made before any attempt finds the job started
+var ErrStaleAttempt = errors.New("job attempt is stale")
+
+// ErrJobAlive is returned when an update made for a job that stopped sending heartbeats finds it sent one since,
+// or no longer running
+var ErrJobAlive = errors.New("job is alive")
+
+// VersionConflictError is returned when an update expected a version the job has since moved past.
+// The caller can read the job again and retry the update on the fresh state.
+type VersionConflictError struct {
+	JobID    string
+	Expected int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("job %s was updated after version %d", e.JobID, e.Expected)
+}
+
+// Is makes errors.Is match ErrVersionConflict
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
 type JobRepositoryInterface interface {
 	CreateJob(ctx context.Context, job *models.Job) error
 	GetJob(ctx context.Context, id string) (*models.Job, error)
+	GetJobsByIDs(ctx context.Context, ids []string) ([]*models.Job, error)
 	GetAllJobs(ctx context.Context) ([]*models.Job, error)
-	UpdateJobStatus(ctx context.Context, id string, status models.JobStatus) error
-	UpdateJob(ctx context.Context, id string, status *models.JobStatus, result *models.AnalyzeResult) error
+	UpdateJobStatus(ctx context.Context, id string, status models.JobStatus, opts ...UpdateOption) error
+	UpdateJob(ctx context.Context, id string, status *models.JobStatus, result *models.AnalyzeResult, opts ...UpdateOption) error
+	UpdateJobProgress(ctx context.Context, id string, progress float64) error
+	HeartbeatJob(ctx context.Context, id, attemptID string, at time.Time) error
+	GetJobsByStatus(ctx context.Context, statuses []models.JobStatus, cursor string, limit int64) ([]*models.Job, string, error)
+	CancelJob(ctx context.Context, id string) (bool, error)
+	DeleteJob(ctx context.Context, id string, deletedAt time.Time) (bool, error)
+	RestoreJob(ctx context.Context, id string, deletedAt time.Time) (bool, error)
+	GetDeletedJobs(ctx context.Context, cursor string, limit int64) ([]*models.Job, string, error)
+	PurgeJob(ctx context.Context, id string, deletedAt time.Time) (bool, error)
+	DiscardJob(ctx context.Context, id string) (bool, error)
+	QueryJobsByResult(ctx context.Context, query ResultQuery, cursor string, limit int64) ([]*models.Job, string, error)
 }
 
 // JobOption is a function that configures the JobRepository
@@ -36,10 +101,211 @@ func WithJobMetrics(mc MetricsCollector) JobOption {
 	}
 }
 
+// WithMaxResultSize sets the estimated result size in bytes above which results are trimmed before they are stored
+func WithMaxResultSize(bytes int) JobOption {
+	return func(j *JobRepository) {
+		j.maxResultSize = bytes
+	}
+}
+
+// WithJobDynamoDBClient overrides the DynamoDB client, mainly for tests
+func WithJobDynamoDBClient(ddb dynamodbiface.DynamoDBAPI) JobOption {
+	return func(j *JobRepository) {
+		j.ddb = ddb
+	}
+}
+
+// UpdateOption configures a single job update
+type UpdateOption func(*updateOptions)
+
+type updateOptions struct {
+	expectedVersion *int64
+	expectedAttempt string
+	// expectPending conditions the update on the job not having been started yet
+	expectPending bool
+	// checkHeartbeat conditions the update on expectedHeartbeat, nil expecting the job never sent one
+	checkHeartbeat    bool
+	expectedHeartbeat *time.Time
+	attemptID         string
+	failureCode       models.FailureCode
+	failureReason     string
+	fetchStatusCode   int
+}
+
+// IfVersion applies the update only while the job is at the given version,
+// otherwise the update fails with a *VersionConflictError
+func IfVersion(version int64) UpdateOption {
+	return func(o *updateOptions) {
+		o.expectedVersion = &version
+	}
+}
+
+// IfAttempt applies the update only while the job is running under the given analysis attempt,
+// otherwise the update fails with ErrStaleAttempt
+func IfAttempt(attemptID string) UpdateOption {
+	return func(o *updateOptions) {
+		o.expectedAttempt = attemptID
+	}
+}
+
+// IfPending applies the update only while the job is still pending, so a writer that never started the job
+// cannot overwrite the state of an analysis that did. Otherwise the update fails with ErrStaleAttempt.
+func IfPending() UpdateOption {
+	return func(o *updateOptions) {
+		o.expectPending = true
+	}
+}
+
+// IfHeartbeat applies the update only while the job is running and its last heartbeat is still the one given,
+// nil for a job that never sent one. Otherwise the update fails with ErrJobAlive.
+func IfHeartbeat(lastHeartbeat *time.Time) UpdateOption {
+	return func(o *updateOptions) {
+		o.checkHeartbeat = true
+		o.expectedHeartbeat = lastHeartbeat
+	}
+}
+
+// WithAttempt stores the analysis attempt the job is now run by, along with a status update
+func WithAttempt(attemptID string) UpdateOption {
+	return func(o *updateOptions) {
+		o.attemptID = attemptID
+	}
+}
+
+// WithFailure stores why the job failed along with a status update
+func WithFailure(code models.FailureCode, reason string) UpdateOption {
+	return func(o *updateOptions) {
+		o.failureCode = code
+		o.failureReason = reason
+	}
+}
+
+// WithFetchStatus stores the HTTP status the job's page was fetched with along with the update, zero stores nothing
+func WithFetchStatus(statusCode int) UpdateOption {
+	return func(o *updateOptions) {
+		o.fetchStatusCode = statusCode
+	}
+}
+
+func newUpdateOptions(opts []UpdateOption) updateOptions {
+	var o updateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// addCondition makes the update conditional on the expected version, attempt and heartbeat, if they were given.
+// A job without a version attribute is at version zero.
+func (o updateOptions) addCondition(input *dynamodb.UpdateItemInput) {
+	var conditions []string
+
+	if o.expectedVersion != nil {
+		condition := "version = :expected"
+		if *o.expectedVersion == 0 {
+			condition = "(attribute_not_exists(version) OR version = :expected)"
+		}
+		conditions = append(conditions, condition)
+		input.ExpressionAttributeValues[":expected"] = &dynamodb.AttributeValue{
+			N: aws.String(strconv.FormatInt(*o.expectedVersion, 10)),
+		}
+	}
+
+	if o.expectedAttempt != "" {
+		conditions = append(conditions, "attempt_id = :expected_attempt AND #status = :running")
+		input.ExpressionAttributeValues[":expected_attempt"] = &dynamodb.AttributeValue{S: aws.String(o.expectedAttempt)}
+	}
+
+	if o.expectPending {
+		conditions = append(conditions, "#status = :pending")
+		input.ExpressionAttributeValues[":pending"] = &dynamodb.AttributeValue{S: aws.String(string(models.JobStatusPending))}
+	}
+
+	if o.checkHeartbeat {
+		condition := "#status = :running AND attribute_not_exists(last_heartbeat_at)"
+		if o.expectedHeartbeat != nil {
+			condition = "#status = :running AND last_heartbeat_at = :expected_heartbeat"
+			input.ExpressionAttributeValues[":expected_heartbeat"] = &dynamodb.AttributeValue{S: aws.String(formatHeartbeat(*o.expectedHeartbeat))}
+		}
+		conditions = append(conditions, condition)
+	}
+
+	if o.expectedAttempt != "" || o.checkHeartbeat {
+		input.ExpressionAttributeValues[":running"] = &dynamodb.AttributeValue{S: aws.String(string(models.JobStatusRunning))}
+	}
+	if o.expectedAttempt != "" || o.checkHeartbeat || o.expectPending {
+		if input.ExpressionAttributeNames == nil {
+			input.ExpressionAttributeNames = make(map[string]*string)
+		}
+		input.ExpressionAttributeNames["#status"] = aws.String("status")
+	}
+
+	if len(conditions) > 0 {
+		input.ConditionExpression = aws.String(strings.Join(conditions, " AND "))
+	}
+}
+
+// addAttempt adds the attempt to the values of the update and returns its clause, empty without an attempt
+func (o updateOptions) addAttempt(values map[string]*dynamodb.AttributeValue) string {
+	if o.attemptID == "" {
+		return ""
+	}
+
+	values[":attempt_id"] = &dynamodb.AttributeValue{S: aws.String(o.attemptID)}
+	return "attempt_id = :attempt_id"
+}
+
+// addFailure adds the failure to the values of the update and returns its clause, empty without a failure
+func (o updateOptions) addFailure(values map[string]*dynamodb.AttributeValue) string {
+	if o.failureCode == "" {
+		return ""
+	}
+
+	values[":failure_code"] = &dynamodb.AttributeValue{S: aws.String(string(o.failureCode))}
+	values[":failure_reason"] = &dynamodb.AttributeValue{S: aws.String(o.failureReason)}
+	return "failure_code = :failure_code, failure_reason = :failure_reason"
+}
+
+// addFetchStatus adds the fetch status to the values of the update and returns its clause, empty without one
+func (o updateOptions) addFetchStatus(values map[string]*dynamodb.AttributeValue) string {
+	if o.fetchStatusCode == 0 {
+		return ""
+	}
+
+	values[":fetch_status_code"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(o.fetchStatusCode))}
+	return "fetch_status_code = :fetch_status_code"
+}
+
+// conflict turns the failed condition of a versioned update into a *VersionConflictError,
+// that of an update made for an attempt into ErrStaleAttempt and that of a heartbeat check into ErrJobAlive
+func (o updateOptions) conflict(id string, err error) error {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) || aerr.Code() != dynamodb.ErrCodeConditionalCheckFailedException {
+		return err
+	}
+
+	switch {
+	case o.expectedVersion != nil:
+		return &VersionConflictError{JobID: id, Expected: *o.expectedVersion}
+	case o.expectedAttempt != "":
+		return fmt.Errorf("%w: job %s is no longer running under attempt %s", ErrStaleAttempt, id, o.expectedAttempt)
+	case o.expectPending:
+		return fmt.Errorf("%w: job %s is no longer pending", ErrStaleAttempt, id)
+	case o.checkHeartbeat:
+		return fmt.Errorf("%w: job %s sent a heartbeat or finished meanwhile", ErrJobAlive, id)
+	}
+	return err
+}
+
 // JobRepository is a struct for job repository
 type JobRepository struct {
-	ddb *dynamodb.DynamoDB
-	mc  MetricsCollector
+	ddb           dynamodbiface.DynamoDBAPI
+	mc            MetricsCollector
+	maxResultSize int
+	// resultAttributes is set when the queryable result fields are copied to the job item
+	resultAttributes bool
+	// batchRetryDelay is the wait before the first retry of keys a batch read left unprocessed
+	batchRetryDelay time.Duration
 }
 
 // NewJobRepository creates a new job repository
@@ -49,7 +315,13 @@ func NewJobRepository(cfg config.DynamoDBConfig, opts ...JobOption) (*JobReposit
 		return nil, err
 	}
 
-	repo := &JobRepository{ddb: ddb, mc: NoOpMetricsCollector{}}
+	repo := &JobRepository{
+		ddb:              ddb,
+		mc:               NoOpMetricsCollector{},
+		maxResultSize:    cfg.MaxResultSize,
+		resultAttributes: cfg.ResultAttributes,
+		batchRetryDelay:  defaultBatchRetryDelay,
+	}
 	for _, opt := range opts {
 		opt(repo)
 	}
@@ -60,7 +332,7 @@ func NewJobRepository(cfg config.DynamoDBConfig, opts ...JobOption) (*JobReposit
 // CreateJob creates a new job
 func (j *JobRepository) CreateJob(ctx context.Context, job *models.Job) (err error) {
 	start := time.Now()
-	_, span := tracing.CreateDatabaseSpan(ctx, "create_job", JobsTableName)
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "create_job", JobsTableName)
 
 	defer func() {
 		j.mc.RecordDatabaseOperation("create_job", JobsTableName, start, err)
@@ -70,6 +342,12 @@ func (j *JobRepository) CreateJob(ctx context.Context, job *models.Job) (err err
 	// Convert domain model to entity
 	entity := &JobEntity{}
 	entity.FromModel(job)
+	if j.resultAttributes && job.Result != nil {
+		entity.setResultAttributes(job.Result)
+	}
+	if len(entity.StatusHistory) == 0 {
+		entity.StatusHistory = []StatusChangeEntity{{Status: entity.Status, At: job.CreatedAt}}
+	}
 
 	item, err := dynamodbattribute.MarshalMap(entity)
 	if err != nil {
@@ -81,14 +359,14 @@ func (j *JobRepository) CreateJob(ctx context.Context, job *models.Job) (err err
 		Item:      item,
 	}
 
-	_, err = j.ddb.PutItem(input)
+	_, err = j.ddb.PutItemWithContext(ctx, input)
 	return err
 }
 
 // GetJob queries a job by ID
 func (j *JobRepository) GetJob(ctx context.Context, id string) (job *models.Job, err error) {
 	start := time.Now()
-	_, span := tracing.CreateDatabaseSpan(ctx, "get_job", JobsTableName)
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "get_job", JobsTableName)
 
 	defer func() {
 		j.mc.RecordDatabaseOperation("get_job", JobsTableName, start, err)
@@ -107,14 +385,13 @@ func (j *JobRepository) GetJob(ctx context.Context, id string) (job *models.Job,
 		},
 	}
 
-	result, err := j.ddb.GetItem(input)
+	result, err := j.ddb.GetItemWithContext(ctx, input)
 	if err != nil {
 		return nil, err
 	}
 
 	if result.Item == nil {
-		notFoundErr := errors.New("job not found")
-		return nil, notFoundErr
+		return nil, ErrJobNotFound
 	}
 
 	var entity JobEntity
@@ -126,10 +403,75 @@ func (j *JobRepository) GetJob(ctx context.Context, id string) (job *models.Job,
 	return entity.ToModel(), nil
 }
 
+// GetJobsByIDs reads the jobs with the given IDs in batches, returning them in the order of ids.
+// IDs without a job are left out and repeated IDs are returned once.
+func (j *JobRepository) GetJobsByIDs(ctx context.Context, ids []string) (jobs []*models.Job, err error) {
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "batch_get_jobs", JobsTableName)
+
+	defer func() {
+		j.mc.RecordDatabaseOperation("batch_get_jobs", JobsTableName, start, err)
+		span.Close(err)
+	}()
+
+	ids = uniqueIDs(ids)
+	found := make(map[string]*models.Job, len(ids))
+	for chunk := range slices.Chunk(ids, maxBatchGetKeys) {
+		if err := j.batchGetJobs(ctx, chunk, found); err != nil {
+			return nil, err
+		}
+	}
+
+	jobs = make([]*models.Job, 0, len(found))
+	for _, id := range ids {
+		if job, ok := found[id]; ok {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// batchGetJobs reads up to maxBatchGetKeys jobs into found
+func (j *JobRepository) batchGetJobs(ctx context.Context, ids []string, found map[string]*models.Job) error {
+	keys := make([]map[string]*dynamodb.AttributeValue, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, map[string]*dynamodb.AttributeValue{
+			"partition_key": {
+				S: aws.String("1000"),
+			},
+			"id": {
+				S: aws.String(id),
+			},
+		})
+	}
+
+	return batchGetItems(ctx, j.ddb, JobsTableName, "jobs", keys, j.batchRetryDelay, func(item map[string]*dynamodb.AttributeValue) error {
+		var entity JobEntity
+		if err := dynamodbattribute.UnmarshalMap(item, &entity); err != nil {
+			return err
+		}
+		found[entity.ID] = entity.ToModel()
+		return nil
+	})
+}
+
+// uniqueIDs returns the IDs without repeats, in the order they first appear
+func uniqueIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
 // GetAllJobs queries all jobs
 func (j *JobRepository) GetAllJobs(ctx context.Context) (jobs []*models.Job, err error) {
 	start := time.Now()
-	_, span := tracing.CreateDatabaseSpan(ctx, "query_all_jobs", JobsTableName)
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "query_all_jobs", JobsTableName)
 
 	defer func() {
 		j.mc.RecordDatabaseOperation("query_all_jobs", JobsTableName, start, err)
@@ -150,7 +492,7 @@ func (j *JobRepository) GetAllJobs(ctx context.Context) (jobs []*models.Job, err
 		ScanIndexForward: aws.Bool(false), // false for descending order since JobID is based on timestamp
 	}
 
-	result, err := j.ddb.Query(input)
+	result, err := j.ddb.QueryWithContext(ctx, input)
 	if err != nil {
 		return nil, err
 	}
@@ -169,9 +511,9 @@ func (j *JobRepository) GetAllJobs(ctx context.Context) (jobs []*models.Job, err
 }
 
 // UpdateJobStatus updates the status of a job
-func (j *JobRepository) UpdateJobStatus(ctx context.Context, id string, status models.JobStatus) (err error) {
+func (j *JobRepository) UpdateJobStatus(ctx context.Context, id string, status models.JobStatus, opts ...UpdateOption) (err error) {
 	start := time.Now()
-	_, span := tracing.CreateDatabaseSpan(ctx, "update_job_status", JobsTableName)
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "update_job_status", JobsTableName)
 
 	defer func() {
 		j.mc.RecordDatabaseOperation("update_job_status", JobsTableName, start, err)
@@ -188,7 +530,6 @@ func (j *JobRepository) UpdateJobStatus(ctx context.Context, id string, status m
 				S: aws.String(id),
 			},
 		},
-		UpdateExpression: aws.String("SET #status = :status, updated_at = :updated_at"),
 		ExpressionAttributeNames: map[string]*string{
 			"#status": aws.String("status"),
 		},
@@ -200,16 +541,42 @@ func (j *JobRepository) UpdateJobStatus(ctx context.Context, id string, status m
 				S: aws.String(time.Now().Format(time.RFC3339)),
 			},
 		},
+		ReturnValues: aws.String(dynamodb.ReturnValueUpdatedNew),
+	}
+	if err = addStatusChangeValues(input.ExpressionAttributeValues, status); err != nil {
+		return err
+	}
+	addVersionValues(input.ExpressionAttributeValues)
+	updateExpression := "SET #status = :status, updated_at = :updated_at, " + appendStatusChange + ", " + incrementVersion
+	if clause := addTerminalProgress(input.ExpressionAttributeValues, status); clause != "" {
+		updateExpression += ", " + clause
 	}
 
-	_, err = j.ddb.UpdateItem(input)
-	return err
+	options := newUpdateOptions(opts)
+	for _, clause := range []string{
+		options.addFailure(input.ExpressionAttributeValues),
+		options.addAttempt(input.ExpressionAttributeValues),
+		options.addFetchStatus(input.ExpressionAttributeValues),
+	} {
+		if clause != "" {
+			updateExpression += ", " + clause
+		}
+	}
+	input.UpdateExpression = aws.String(updateExpression)
+	options.addCondition(input)
+
+	output, err := j.ddb.UpdateItemWithContext(ctx, input)
+	if err != nil {
+		return options.conflict(id, err)
+	}
+
+	return j.trimStatusHistory(ctx, id, output.Attributes)
 }
 
 // UpdateJob updates a job
-func (j *JobRepository) UpdateJob(ctx context.Context, id string, status *models.JobStatus, result *models.AnalyzeResult) (err error) {
+func (j *JobRepository) UpdateJob(ctx context.Context, id string, status *models.JobStatus, result *models.AnalyzeResult, opts ...UpdateOption) (err error) {
 	start := time.Now()
-	_, span := tracing.CreateDatabaseSpan(ctx, "update_job", JobsTableName)
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "update_job", JobsTableName)
 
 	defer func() {
 		j.mc.RecordDatabaseOperation("update_job", JobsTableName, start, err)
@@ -220,17 +587,24 @@ func (j *JobRepository) UpdateJob(ctx context.Context, id string, status *models
 	expressionAttributeValues := make(map[string]*dynamodb.AttributeValue)
 	expressionAttributeNames := make(map[string]*string)
 
-	updateExpressions = append(updateExpressions, "updated_at = :updated_at")
+	updateExpressions = append(updateExpressions, "updated_at = :updated_at", incrementVersion)
 	expressionAttributeValues[":updated_at"] = &dynamodb.AttributeValue{
 		S: aws.String(time.Now().Format(time.RFC3339)),
 	}
+	addVersionValues(expressionAttributeValues)
 
 	if status != nil {
-		updateExpressions = append(updateExpressions, "#status = :status")
+		updateExpressions = append(updateExpressions, "#status = :status", appendStatusChange)
 		expressionAttributeNames["#status"] = aws.String("status")
 		expressionAttributeValues[":status"] = &dynamodb.AttributeValue{
 			S: aws.String(string(*status)),
 		}
+		if err := addStatusChangeValues(expressionAttributeValues, *status); err != nil {
+			return err
+		}
+		if clause := addTerminalProgress(expressionAttributeValues, *status); clause != "" {
+			updateExpressions = append(updateExpressions, clause)
+		}
 	}
 
 	if result != nil {
@@ -241,22 +615,25 @@ func (j *JobRepository) UpdateJob(ctx context.Context, id string, status *models
 		resultEntity := &AnalyzeResultEntity{}
 		resultEntity.FromModel(result)
 
-		resultAttr, err := dynamodbattribute.Marshal(resultEntity)
+		resultAttr, err := j.marshalResult(resultEntity)
 		if err != nil {
 			return err
 		}
-		if len(result.Headings) == 0 {
-			resultAttr.M["headings"] = &dynamodb.AttributeValue{
-				M: make(map[string]*dynamodb.AttributeValue),
-			}
+		expressionAttributeValues[":result"] = resultAttr
+		if j.resultAttributes {
+			updateExpressions = append(updateExpressions, resultAttributeValues(expressionAttributeValues, result))
 		}
+	}
 
-		if len(result.Links) == 0 {
-			resultAttr.M["links"] = &dynamodb.AttributeValue{
-				L: []*dynamodb.AttributeValue{},
-			}
+	options := newUpdateOptions(opts)
+	for _, clause := range []string{
+		options.addFailure(expressionAttributeValues),
+		options.addAttempt(expressionAttributeValues),
+		options.addFetchStatus(expressionAttributeValues),
+	} {
+		if clause != "" {
+			updateExpressions = append(updateExpressions, clause)
 		}
-		expressionAttributeValues[":result"] = resultAttr
 	}
 
 	input := &dynamodb.UpdateItemInput{
@@ -277,6 +654,522 @@ func (j *JobRepository) UpdateJob(ctx context.Context, id string, status *models
 		input.ExpressionAttributeNames = expressionAttributeNames
 	}
 
-	_, err = j.ddb.UpdateItem(input)
+	options.addCondition(input)
+
+	if status == nil {
+		_, err = j.ddb.UpdateItemWithContext(ctx, input)
+		return options.conflict(id, err)
+	}
+
+	// The updated attributes carry the appended history, used to enforce the cap
+	input.ReturnValues = aws.String(dynamodb.ReturnValueUpdatedNew)
+	output, err := j.ddb.UpdateItemWithContext(ctx, input)
+	if err != nil {
+		return options.conflict(id, err)
+	}
+
+	return j.trimStatusHistory(ctx, id, output.Attributes)
+}
+
+// UpdateJobProgress stores the progress of a running job.
+// It is a no-op once the job has left the running status, so a late update cannot overwrite the final progress.
+// Progress is not a change of the job's state, so it leaves the version alone.
+func (j *JobRepository) UpdateJobProgress(ctx context.Context, id string, progress float64) (err error) {
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "update_job_progress", JobsTableName)
+
+	defer func() {
+		j.mc.RecordDatabaseOperation("update_job_progress", JobsTableName, start, err)
+		span.Close(err)
+	}()
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(JobsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"partition_key": {
+				S: aws.String("1000"),
+			},
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		UpdateExpression:    aws.String("SET progress = :progress, updated_at = :updated_at"),
+		ConditionExpression: aws.String("#status = :running"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":progress": {
+				N: aws.String(strconv.FormatFloat(progress, 'f', -1, 64)),
+			},
+			":running": {
+				S: aws.String(string(models.JobStatusRunning)),
+			},
+			":updated_at": {
+				S: aws.String(time.Now().Format(time.RFC3339)),
+			},
+		},
+	}
+
+	_, err = j.ddb.UpdateItemWithContext(ctx, input)
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
+	return err
+}
+
+// HeartbeatJob records that the analysis running the job under attemptID was alive at the given time.
+// It fails with ErrStaleAttempt once the job no longer runs under that attempt. Heartbeats are frequent,
+// so they leave the version and updated_at alone and are not published.
+func (j *JobRepository) HeartbeatJob(ctx context.Context, id, attemptID string, at time.Time) (err error) {
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "heartbeat_job", JobsTableName)
+
+	defer func() {
+		j.mc.RecordDatabaseOperation("heartbeat_job", JobsTableName, start, err)
+		span.Close(err)
+	}()
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(JobsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"partition_key": {
+				S: aws.String("1000"),
+			},
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		UpdateExpression: aws.String("SET last_heartbeat_at = :heartbeat"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":heartbeat": {
+				S: aws.String(formatHeartbeat(at)),
+			},
+		},
+	}
+	options := newUpdateOptions([]UpdateOption{IfAttempt(attemptID)})
+	options.addCondition(input)
+
+	if _, err = j.ddb.UpdateItemWithContext(ctx, input); err != nil {
+		return options.conflict(id, err)
+	}
+	return nil
+}
+
+// formatHeartbeat formats a heartbeat time as JobEntity.LastHeartbeatAt is stored
+func formatHeartbeat(at time.Time) string {
+	return at.UTC().Format(time.RFC3339Nano)
+}
+
+// GetJobsByStatus queries one page of jobs in any of the given statuses, newest first.
+// The returned cursor is empty once the last page has been read.
+func (j *JobRepository) GetJobsByStatus(ctx context.Context, statuses []models.JobStatus, cursor string, limit int64) (jobs []*models.Job, next string, err error) {
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "query_jobs_by_status", JobsTableName)
+
+	defer func() {
+		j.mc.RecordDatabaseOperation("query_jobs_by_status", JobsTableName, start, err)
+		span.Close(err)
+	}()
+
+	expressionAttributeValues := map[string]*dynamodb.AttributeValue{
+		":partition_key": {
+			S: aws.String("1000"),
+		},
+	}
+	placeholders := make([]string, 0, len(statuses))
+	for i, status := range statuses {
+		placeholder := fmt.Sprintf(":status%d", i)
+		placeholders = append(placeholders, placeholder)
+		expressionAttributeValues[placeholder] = &dynamodb.AttributeValue{S: aws.String(string(status))}
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(JobsTableName),
+		KeyConditionExpression: aws.String("#partition_key = :partition_key"),
+		FilterExpression:       aws.String("#status IN (" + strings.Join(placeholders, ", ") + ")"),
+		ExpressionAttributeNames: map[string]*string{
+			"#partition_key": aws.String("partition_key"),
+			"#status":        aws.String("status"),
+		},
+		ExpressionAttributeValues: expressionAttributeValues,
+		ScanIndexForward:          aws.Bool(false),
+		Limit:                     aws.Int64(limit),
+	}
+
+	return j.queryJobPage(ctx, input, cursor)
+}
+
+// GetDeletedJobs queries one page of the jobs in the trash, newest first.
+// The returned cursor is empty once the last page has been read.
+func (j *JobRepository) GetDeletedJobs(ctx context.Context, cursor string, limit int64) (jobs []*models.Job, next string, err error) {
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "query_deleted_jobs", JobsTableName)
+
+	defer func() {
+		j.mc.RecordDatabaseOperation("query_deleted_jobs", JobsTableName, start, err)
+		span.Close(err)
+	}()
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(JobsTableName),
+		KeyConditionExpression: aws.String("#partition_key = :partition_key"),
+		FilterExpression:       aws.String("attribute_exists(deleted_at)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#partition_key": aws.String("partition_key"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":partition_key": {
+				S: aws.String("1000"),
+			},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int64(limit),
+	}
+
+	return j.queryJobPage(ctx, input, cursor)
+}
+
+// queryJobPage runs a paged query of the jobs partition starting after the cursor,
+// returning the jobs read and the cursor of the next page
+func (j *JobRepository) queryJobPage(ctx context.Context, input *dynamodb.QueryInput, cursor string) ([]*models.Job, string, error) {
+	if cursor != "" {
+		input.ExclusiveStartKey = map[string]*dynamodb.AttributeValue{
+			"partition_key": {
+				S: aws.String("1000"),
+			},
+			"id": {
+				S: aws.String(cursor),
+			},
+		}
+	}
+
+	result, err := j.ddb.QueryWithContext(ctx, input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	jobs := make([]*models.Job, 0, len(result.Items))
+	for _, item := range result.Items {
+		var entity JobEntity
+		err = dynamodbattribute.UnmarshalMap(item, &entity)
+		if err != nil {
+			return nil, "", err
+		}
+		jobs = append(jobs, entity.ToModel())
+	}
+
+	next := ""
+	if id, ok := result.LastEvaluatedKey["id"]; ok && id.S != nil {
+		next = *id.S
+	}
+
+	return jobs, next, nil
+}
+
+// CancelJob moves a pending or running job to cancelled.
+// It reports false without error when the job has already reached a terminal status.
+func (j *JobRepository) CancelJob(ctx context.Context, id string) (cancelled bool, err error) {
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "cancel_job", JobsTableName)
+
+	defer func() {
+		j.mc.RecordDatabaseOperation("cancel_job", JobsTableName, start, err)
+		span.Close(err)
+	}()
+
+	now := aws.String(time.Now().UTC().Format(time.RFC3339))
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(JobsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"partition_key": {
+				S: aws.String("1000"),
+			},
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		UpdateExpression:    aws.String("SET #status = :cancelled, updated_at = :now, completed_at = :now, progress = :progress, " + appendStatusChange + ", " + incrementVersion),
+		ConditionExpression: aws.String("#status IN (:pending, :running)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":cancelled": {
+				S: aws.String(string(models.JobStatusCancelled)),
+			},
+			":pending": {
+				S: aws.String(string(models.JobStatusPending)),
+			},
+			":running": {
+				S: aws.String(string(models.JobStatusRunning)),
+			},
+			":now": {
+				S: now,
+			},
+			":progress": {
+				N: aws.String("0"),
+			},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueUpdatedNew),
+	}
+	if err = addStatusChangeValues(input.ExpressionAttributeValues, models.JobStatusCancelled); err != nil {
+		return false, err
+	}
+	addVersionValues(input.ExpressionAttributeValues)
+
+	output, err := j.ddb.UpdateItemWithContext(ctx, input)
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, j.trimStatusHistory(ctx, id, output.Attributes)
+}
+
+// DeleteJob moves a job to the trash as of deletedAt, the item stays until it is purged.
+// It reports false without error when the job does not exist or is already in the trash.
+func (j *JobRepository) DeleteJob(ctx context.Context, id string, deletedAt time.Time) (deleted bool, err error) {
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "delete_job", JobsTableName)
+
+	defer func() {
+		j.mc.RecordDatabaseOperation("delete_job", JobsTableName, start, err)
+		span.Close(err)
+	}()
+
+	deletedAtAttr, err := dynamodbattribute.Marshal(deletedAt)
+	if err != nil {
+		return false, err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(JobsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"partition_key": {
+				S: aws.String("1000"),
+			},
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		UpdateExpression:    aws.String("SET deleted_at = :deleted_at, updated_at = :updated_at, " + incrementVersion),
+		ConditionExpression: aws.String("attribute_exists(id) AND attribute_not_exists(deleted_at)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":deleted_at": deletedAtAttr,
+			":updated_at": {
+				S: aws.String(time.Now().Format(time.RFC3339)),
+			},
+		},
+	}
+	addVersionValues(input.ExpressionAttributeValues)
+
+	_, err = j.ddb.UpdateItemWithContext(ctx, input)
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RestoreJob takes a job out of the trash, on condition that it is still the deletion made at deletedAt.
+// It reports false without error when the job was restored or purged in the meantime.
+func (j *JobRepository) RestoreJob(ctx context.Context, id string, deletedAt time.Time) (restored bool, err error) {
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "restore_job", JobsTableName)
+
+	defer func() {
+		j.mc.RecordDatabaseOperation("restore_job", JobsTableName, start, err)
+		span.Close(err)
+	}()
+
+	expected, err := dynamodbattribute.Marshal(deletedAt)
+	if err != nil {
+		return false, err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(JobsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"partition_key": {
+				S: aws.String("1000"),
+			},
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		UpdateExpression:    aws.String("SET updated_at = :updated_at, " + incrementVersion + " REMOVE deleted_at"),
+		ConditionExpression: aws.String("deleted_at = :deleted_at"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":deleted_at": expected,
+			":updated_at": {
+				S: aws.String(time.Now().Format(time.RFC3339)),
+			},
+		},
+	}
+	addVersionValues(input.ExpressionAttributeValues)
+
+	_, err = j.ddb.UpdateItemWithContext(ctx, input)
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// PurgeJob permanently removes a job from the trash, on condition that it is still the deletion made at deletedAt,
+// so a job restored and deleted again keeps its new restore window.
+// It reports false without error when the job is no longer in the trash under that deletion.
+func (j *JobRepository) PurgeJob(ctx context.Context, id string, deletedAt time.Time) (purged bool, err error) {
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "purge_job", JobsTableName)
+
+	defer func() {
+		j.mc.RecordDatabaseOperation("purge_job", JobsTableName, start, err)
+		span.Close(err)
+	}()
+
+	expected, err := dynamodbattribute.Marshal(deletedAt)
+	if err != nil {
+		return false, err
+	}
+
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(JobsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"partition_key": {
+				S: aws.String("1000"),
+			},
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		ConditionExpression: aws.String("deleted_at = :deleted_at"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":deleted_at": expected,
+		},
+	}
+
+	_, err = j.ddb.DeleteItemWithContext(ctx, input)
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// DiscardJob permanently removes a job that was never picked up, undoing its creation when it could not be queued.
+// It reports false without error when the job does not exist or is no longer pending, as it is then being analyzed.
+func (j *JobRepository) DiscardJob(ctx context.Context, id string) (discarded bool, err error) {
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "discard_job", JobsTableName)
+
+	defer func() {
+		j.mc.RecordDatabaseOperation("discard_job", JobsTableName, start, err)
+		span.Close(err)
+	}()
+
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(JobsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"partition_key": {
+				S: aws.String("1000"),
+			},
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		ConditionExpression: aws.String("#status = :pending"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pending": {
+				S: aws.String(string(models.JobStatusPending)),
+			},
+		},
+	}
+
+	_, err = j.ddb.DeleteItemWithContext(ctx, input)
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// addStatusChangeValues sets the expression values used by appendStatusChange
+func addStatusChangeValues(values map[string]*dynamodb.AttributeValue, status models.JobStatus) error {
+	change, err := dynamodbattribute.Marshal([]StatusChangeEntity{{Status: string(status), At: time.Now().UTC()}})
+	if err != nil {
+		return err
+	}
+
+	values[":status_change"] = change
+	values[":empty_history"] = &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}}
+	return nil
+}
+
+// addVersionValues sets the expression values used by incrementVersion
+func addVersionValues(values map[string]*dynamodb.AttributeValue) {
+	values[":zero"] = &dynamodb.AttributeValue{N: aws.String("0")}
+	values[":one"] = &dynamodb.AttributeValue{N: aws.String("1")}
+}
+
+// addTerminalProgress sets the progress a terminal status forces on the job,
+// returning the update clause storing it, or an empty clause for other statuses
+func addTerminalProgress(values map[string]*dynamodb.AttributeValue, status models.JobStatus) string {
+	progress, ok := models.TerminalProgress(status)
+	if !ok {
+		return ""
+	}
+
+	values[":progress"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatFloat(progress, 'f', -1, 64))}
+	return "progress = :progress"
+}
+
+// trimStatusHistory removes the oldest entries once the history returned by an append exceeds maxStatusHistory.
+// The removal is conditional on the length it was computed from, when a concurrent append changes it,
+// that append trims the history instead.
+func (j *JobRepository) trimStatusHistory(ctx context.Context, id string, attributes map[string]*dynamodb.AttributeValue) error {
+	history, ok := attributes["status_history"]
+	if !ok || len(history.L) <= maxStatusHistory {
+		return nil
+	}
+
+	excess := len(history.L) - maxStatusHistory
+	paths := make([]string, 0, excess)
+	for i := 0; i < excess; i++ {
+		paths = append(paths, fmt.Sprintf("status_history[%d]", i))
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(JobsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"partition_key": {
+				S: aws.String("1000"),
+			},
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		UpdateExpression:    aws.String("REMOVE " + strings.Join(paths, ", ")),
+		ConditionExpression: aws.String("size(status_history) = :size"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":size": {
+				N: aws.String(strconv.Itoa(len(history.L))),
+			},
+		},
+	}
+
+	_, err := j.ddb.UpdateItemWithContext(ctx, input)
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
 	return err
 }
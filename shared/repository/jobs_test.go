@@ -0,0 +1,961 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"shared/config"
+	"shared/models"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeJobsTable is an in-memory stand-in for the jobs table, keyed by id.
+// It understands the status, status history, progress, version, attempt, heartbeat, failure and trash updates issued by the repository.
+type fakeJobsTable struct {
+	dynamodbiface.DynamoDBAPI
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func newFakeJobsTable() *fakeJobsTable {
+	return &fakeJobsTable{items: make(map[string]map[string]*dynamodb.AttributeValue)}
+}
+
+func (f *fakeJobsTable) PutItemWithContext(_ aws.Context, input *dynamodb.PutItemInput, _ ...request.Option) (*dynamodb.PutItemOutput, error) {
+	f.items[*input.Item["id"].S] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeJobsTable) GetItemWithContext(_ aws.Context, input *dynamodb.GetItemInput, _ ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: f.items[*input.Key["id"].S]}, nil
+}
+
+func (f *fakeJobsTable) UpdateItemWithContext(_ aws.Context, input *dynamodb.UpdateItemInput, _ ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	item, ok := f.items[*input.Key["id"].S]
+	if !ok {
+		item = make(map[string]*dynamodb.AttributeValue)
+		f.items[*input.Key["id"].S] = item
+	}
+	values := input.ExpressionAttributeValues
+	conditionFailed := awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+
+	// Trimming the history: REMOVE status_history[0], ... IF size(status_history) = :size
+	if strings.HasPrefix(*input.UpdateExpression, "REMOVE ") {
+		history := item["status_history"]
+		if strconv.Itoa(len(history.L)) != *values[":size"].N {
+			return nil, conditionFailed
+		}
+		removed := strings.Count(*input.UpdateExpression, "status_history[")
+		history.L = history.L[removed:]
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	// Conditions require the job to be at the :expected version, to be running under the :expected_attempt,
+	// to be in the trash under :deleted_at or out of it, or else to be in one of the statuses given as :pending and :running
+	deletedAt, trashing := values[":deleted_at"]
+	restoring := strings.Contains(*input.UpdateExpression, "REMOVE deleted_at")
+	if attempt, ok := values[":expected_attempt"]; ok {
+		current, status := item["attempt_id"], item["status"]
+		if current == nil || *current.S != *attempt.S || status == nil || *status.S != string(models.JobStatusRunning) {
+			return nil, conditionFailed
+		}
+	}
+	if input.ConditionExpression != nil && strings.Contains(*input.ConditionExpression, "last_heartbeat_at") {
+		current, status := item["last_heartbeat_at"], item["status"]
+		expected, ok := values[":expected_heartbeat"]
+		if ok != (current != nil) || ok && *current.S != *expected.S || status == nil || *status.S != string(models.JobStatusRunning) {
+			return nil, conditionFailed
+		}
+	}
+	if expected, ok := values[":expected"]; ok {
+		if version(item) != *expected.N {
+			return nil, conditionFailed
+		}
+	} else if trashing {
+		current, inTrash := item["deleted_at"]
+		if restoring && (!inTrash || *current.S != *deletedAt.S) || !restoring && (inTrash || item["id"] == nil) {
+			return nil, conditionFailed
+		}
+	} else if input.ConditionExpression != nil {
+		status, allowed := item["status"], false
+		for _, key := range []string{":pending", ":running"} {
+			if v, ok := values[key]; ok && status != nil && *status.S == *v.S {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return nil, conditionFailed
+		}
+	}
+
+	for _, key := range []string{":status", ":cancelled"} {
+		if v, ok := values[key]; ok {
+			item["status"] = v
+		}
+	}
+	if v, ok := values[":result"]; ok {
+		item["result"] = v
+	}
+	if v, ok := values[":progress"]; ok {
+		item["progress"] = v
+	}
+	for _, attr := range []string{"has_login_form", "external_link_count", "html_version"} {
+		if v, ok := values[":"+attr]; ok {
+			item[attr] = v
+		}
+	}
+	if v, ok := values[":failure_code"]; ok {
+		item["failure_code"] = v
+		item["failure_reason"] = values[":failure_reason"]
+	}
+	if v, ok := values[":fetch_status_code"]; ok {
+		item["fetch_status_code"] = v
+	}
+	if v, ok := values[":attempt_id"]; ok {
+		item["attempt_id"] = v
+	}
+	if v, ok := values[":heartbeat"]; ok {
+		item["last_heartbeat_at"] = v
+	}
+	if trashing && restoring {
+		delete(item, "deleted_at")
+	} else if trashing {
+		item["deleted_at"] = deletedAt
+	}
+	if _, ok := values[":one"]; ok {
+		current, _ := strconv.Atoi(version(item))
+		item["version"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(current + 1))}
+	}
+
+	output := &dynamodb.UpdateItemOutput{}
+	if change, ok := values[":status_change"]; ok {
+		history, ok := item["status_history"]
+		if !ok {
+			history = &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}}
+			item["status_history"] = history
+		}
+		history.L = append(history.L, change.L...)
+		output.Attributes = map[string]*dynamodb.AttributeValue{
+			"status_history": {L: append([]*dynamodb.AttributeValue(nil), history.L...)},
+		}
+	}
+	return output, nil
+}
+
+func (f *fakeJobsTable) DeleteItemWithContext(_ aws.Context, input *dynamodb.DeleteItemInput, _ ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	id := *input.Key["id"].S
+	// Purging is conditional on the deletion it was computed from: deleted_at = :deleted_at,
+	// discarding on the job still being pending: #status = :pending
+	attr, expected := "deleted_at", input.ExpressionAttributeValues[":deleted_at"]
+	if pending, ok := input.ExpressionAttributeValues[":pending"]; ok {
+		attr, expected = "status", pending
+	}
+	current, ok := f.items[id][attr]
+	if !ok || *current.S != *expected.S {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+	delete(f.items, id)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+// Query returns all jobs in a single page, only those in the trash when filtered on deleted_at.
+// Queries of the result index return the jobs it holds, by external link count, honoring the result filters.
+func (f *fakeJobsTable) QueryWithContext(_ aws.Context, input *dynamodb.QueryInput, _ ...request.Option) (*dynamodb.QueryOutput, error) {
+	if input.IndexName != nil {
+		return f.queryResultIndex(input), nil
+	}
+	trashOnly := input.FilterExpression != nil && *input.FilterExpression == "attribute_exists(deleted_at)"
+
+	output := &dynamodb.QueryOutput{}
+	for _, item := range f.items {
+		if _, deleted := item["deleted_at"]; deleted || !trashOnly {
+			output.Items = append(output.Items, item)
+		}
+	}
+	return output, nil
+}
+
+func (f *fakeJobsTable) queryResultIndex(input *dynamodb.QueryInput) *dynamodb.QueryOutput {
+	values := input.ExpressionAttributeValues
+	count := func(item map[string]*dynamodb.AttributeValue) int {
+		n, _ := strconv.Atoi(*item["external_link_count"].N)
+		return n
+	}
+	minLinks, _ := strconv.Atoi(*values[":min_external_links"].N)
+
+	output := &dynamodb.QueryOutput{}
+	for _, item := range f.items {
+		if item["external_link_count"] == nil || count(item) < minLinks {
+			continue
+		}
+		if v, ok := values[":has_login_form"]; ok && *item["has_login_form"].BOOL != *v.BOOL {
+			continue
+		}
+		if v, ok := values[":html_version"]; ok && *item["html_version"].S != *v.S {
+			continue
+		}
+		output.Items = append(output.Items, item)
+	}
+	slices.SortFunc(output.Items, func(a, b map[string]*dynamodb.AttributeValue) int { return count(b) - count(a) })
+	return output
+}
+
+// version returns the stored version of an item, zero when it has none
+func version(item map[string]*dynamodb.AttributeValue) string {
+	if v, ok := item["version"]; ok {
+		return *v.N
+	}
+	return "0"
+}
+
+func newTestJobRepository(table *fakeJobsTable) *JobRepository {
+	repo := &JobRepository{mc: NoOpMetricsCollector{}}
+	WithJobDynamoDBClient(table)(repo)
+	return repo
+}
+
+func historyStatuses(history []models.StatusChange) []models.JobStatus {
+	statuses := make([]models.JobStatus, 0, len(history))
+	for _, change := range history {
+		statuses = append(statuses, change.Status)
+	}
+	return statuses
+}
+
+func TestJobRepository_StatusHistory_Lifecycle(t *testing.T) {
+	repo := newTestJobRepository(newFakeJobsTable())
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{
+		ID:        "job-1",
+		URL:       "https://example.com",
+		Status:    models.JobStatusPending,
+		CreatedAt: time.Now().UTC(),
+	}))
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusRunning))
+
+	// Result updates without a status change leave the history alone
+	require.NoError(t, repo.UpdateJob(ctx, "job-1", nil, &models.AnalyzeResult{PageTitle: "Example"}))
+
+	completed := models.JobStatusCompleted
+	require.NoError(t, repo.UpdateJob(ctx, "job-1", &completed, &models.AnalyzeResult{PageTitle: "Example"}))
+
+	job, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, []models.JobStatus{
+		models.JobStatusPending,
+		models.JobStatusRunning,
+		models.JobStatusCompleted,
+	}, historyStatuses(job.StatusHistory))
+	for i := 1; i < len(job.StatusHistory); i++ {
+		assert.False(t, job.StatusHistory[i].At.Before(job.StatusHistory[i-1].At), "history should be ordered by time")
+	}
+}
+
+func TestJobRepository_StatusHistory_Cancel(t *testing.T) {
+	repo := newTestJobRepository(newFakeJobsTable())
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-1", Status: models.JobStatusPending, CreatedAt: time.Now().UTC()}))
+	cancelled, err := repo.CancelJob(ctx, "job-1")
+	require.NoError(t, err)
+	require.True(t, cancelled)
+
+	// A second cancel fails its condition and must not record another change
+	cancelled, err = repo.CancelJob(ctx, "job-1")
+	require.NoError(t, err)
+	assert.False(t, cancelled)
+
+	job, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, []models.JobStatus{models.JobStatusPending, models.JobStatusCancelled}, historyStatuses(job.StatusHistory))
+}
+
+func TestJobRepository_StatusHistory_Cap(t *testing.T) {
+	table := newFakeJobsTable()
+	repo := newTestJobRepository(table)
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-1", Status: models.JobStatusPending, CreatedAt: time.Now().UTC()}))
+	for i := 0; i < 2*maxStatusHistory; i++ {
+		status := models.JobStatusRunning
+		if i == 2*maxStatusHistory-1 {
+			status = models.JobStatusFailed
+		}
+		require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", status))
+	}
+
+	assert.Len(t, table.items["job-1"]["status_history"].L, maxStatusHistory, "stored history should be trimmed")
+
+	job, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	require.Len(t, job.StatusHistory, maxStatusHistory)
+	assert.Equal(t, models.JobStatusFailed, job.StatusHistory[maxStatusHistory-1].Status, "the latest change is kept")
+	assert.Equal(t, models.JobStatusRunning, job.StatusHistory[0].Status, "the oldest changes are dropped")
+}
+
+func TestJobRepository_TrimStatusHistory_ConcurrentAppend(t *testing.T) {
+	table := newFakeJobsTable()
+	repo := newTestJobRepository(table)
+
+	history := make([]*dynamodb.AttributeValue, maxStatusHistory+2)
+	table.items["job-1"] = map[string]*dynamodb.AttributeValue{
+		"id":             {S: aws.String("job-1")},
+		"status_history": {L: history},
+	}
+
+	// The history grew after this trim was computed, the trim of the later append takes over
+	stale := map[string]*dynamodb.AttributeValue{"status_history": {L: history[:maxStatusHistory+1]}}
+	assert.NoError(t, repo.trimStatusHistory(context.Background(), "job-1", stale))
+	assert.Len(t, table.items["job-1"]["status_history"].L, maxStatusHistory+2)
+}
+
+func TestJobRepository_Progress(t *testing.T) {
+	repo := newTestJobRepository(newFakeJobsTable())
+	ctx := context.Background()
+
+	progress := func(id string) float64 {
+		job, err := repo.GetJob(ctx, id)
+		require.NoError(t, err)
+		return job.Progress
+	}
+
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-1", Status: models.JobStatusPending, CreatedAt: time.Now().UTC()}))
+
+	// Only a running job takes progress updates
+	require.NoError(t, repo.UpdateJobProgress(ctx, "job-1", 50))
+	assert.Equal(t, 0.0, progress("job-1"))
+
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusRunning))
+	require.NoError(t, repo.UpdateJobProgress(ctx, "job-1", 42.5))
+	assert.Equal(t, 42.5, progress("job-1"))
+
+	completed := models.JobStatusCompleted
+	require.NoError(t, repo.UpdateJob(ctx, "job-1", &completed, &models.AnalyzeResult{}))
+	assert.Equal(t, 100.0, progress("job-1"))
+
+	// A late update does not overwrite the final progress
+	require.NoError(t, repo.UpdateJobProgress(ctx, "job-1", 70))
+	assert.Equal(t, 100.0, progress("job-1"))
+
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-2", Status: models.JobStatusPending, CreatedAt: time.Now().UTC()}))
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-2", models.JobStatusRunning))
+	require.NoError(t, repo.UpdateJobProgress(ctx, "job-2", 60))
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-2", models.JobStatusFailed))
+	assert.Equal(t, 0.0, progress("job-2"))
+}
+
+func TestJobRepository_Failure(t *testing.T) {
+	repo := newTestJobRepository(newFakeJobsTable())
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-1", Status: models.JobStatusPending, CreatedAt: time.Now().UTC()}))
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusRunning))
+
+	job, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Empty(t, job.FailureCode, "only a failed job has a failure")
+
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusFailed,
+		WithFailure(models.FailureCodeFetch, "The page could not be fetched: 503 Service Unavailable"), WithFetchStatus(503)))
+
+	job, err = repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusFailed, job.Status)
+	assert.Equal(t, models.FailureCodeFetch, job.FailureCode)
+	assert.Equal(t, "The page could not be fetched: 503 Service Unavailable", job.FailureReason)
+	assert.Equal(t, 503, job.FetchStatusCode)
+}
+
+func TestJobRepository_Version(t *testing.T) {
+	table := newFakeJobsTable()
+	repo := newTestJobRepository(table)
+	ctx := context.Background()
+
+	getJob := func() *models.Job {
+		job, err := repo.GetJob(ctx, "job-1")
+		require.NoError(t, err)
+		return job
+	}
+
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-1", Status: models.JobStatusPending, CreatedAt: time.Now().UTC()}))
+	assert.Equal(t, int64(0), getJob().Version)
+
+	// A job stored without a version is at version zero
+	delete(table.items["job-1"], "version")
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusRunning, IfVersion(0)))
+	assert.Equal(t, int64(1), getJob().Version)
+
+	// Progress leaves the version alone, updates without an expected version still count
+	require.NoError(t, repo.UpdateJobProgress(ctx, "job-1", 30))
+	require.NoError(t, repo.UpdateJob(ctx, "job-1", nil, &models.AnalyzeResult{PageTitle: "Example"}))
+	assert.Equal(t, int64(2), getJob().Version)
+
+	// A writer holding a stale version loses to the one that updated the job first
+	cancelled, err := repo.CancelJob(ctx, "job-1")
+	require.NoError(t, err)
+	require.True(t, cancelled)
+
+	completed := models.JobStatusCompleted
+	err = repo.UpdateJob(ctx, "job-1", &completed, &models.AnalyzeResult{}, IfVersion(2))
+	require.ErrorIs(t, err, ErrVersionConflict)
+	var conflict *VersionConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, int64(2), conflict.Expected)
+
+	err = repo.UpdateJobStatus(ctx, "job-1", models.JobStatusFailed, IfVersion(2))
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	job := getJob()
+	assert.Equal(t, int64(3), job.Version)
+	assert.Equal(t, models.JobStatusCancelled, job.Status)
+	assert.Equal(t, []models.JobStatus{
+		models.JobStatusPending,
+		models.JobStatusRunning,
+		models.JobStatusCancelled,
+	}, historyStatuses(job.StatusHistory))
+
+	// Retrying on the fresh version goes through
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusFailed, IfVersion(job.Version)))
+	assert.Equal(t, int64(4), getJob().Version)
+}
+
+func TestJobRepository_Attempt(t *testing.T) {
+	table := newFakeJobsTable()
+	repo := newTestJobRepository(table)
+	ctx := context.Background()
+	completed := models.JobStatusCompleted
+
+	getJob := func() *models.Job {
+		job, err := repo.GetJob(ctx, "job-1")
+		require.NoError(t, err)
+		return job
+	}
+
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-1", Status: models.JobStatusPending, CreatedAt: time.Now().UTC()}))
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusRunning, IfVersion(0), WithAttempt("attempt-1")))
+	assert.Equal(t, "attempt-1", getJob().AttemptID)
+
+	// A retry takes the running job over, the first attempt can no longer finish it
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusRunning, IfVersion(1), WithAttempt("attempt-2")))
+	err := repo.UpdateJob(ctx, "job-1", &completed, &models.AnalyzeResult{PageTitle: "Stale"}, IfAttempt("attempt-1"))
+	assert.ErrorIs(t, err, ErrStaleAttempt)
+	err = repo.UpdateJobStatus(ctx, "job-1", models.JobStatusFailed, IfAttempt("attempt-1"), WithFailure(models.FailureCodeFetch, "timeout"))
+	assert.ErrorIs(t, err, ErrStaleAttempt)
+
+	job := getJob()
+	assert.Equal(t, models.JobStatusRunning, job.Status)
+	assert.Nil(t, job.Result)
+	assert.Empty(t, job.FailureCode)
+
+	// The current attempt cannot overwrite a job an operator finished either
+	cancelled, err := repo.CancelJob(ctx, "job-1")
+	require.NoError(t, err)
+	require.True(t, cancelled)
+	err = repo.UpdateJob(ctx, "job-1", &completed, &models.AnalyzeResult{}, IfAttempt("attempt-2"))
+	assert.ErrorIs(t, err, ErrStaleAttempt)
+	assert.Equal(t, models.JobStatusCancelled, getJob().Status)
+}
+
+func TestJobRepository_Attempt_Completes(t *testing.T) {
+	repo := newTestJobRepository(newFakeJobsTable())
+	ctx := context.Background()
+	completed := models.JobStatusCompleted
+
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-1", Status: models.JobStatusPending, CreatedAt: time.Now().UTC()}))
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusRunning, WithAttempt("attempt-1")))
+	require.NoError(t, repo.UpdateJob(ctx, "job-1", &completed, &models.AnalyzeResult{PageTitle: "Example"}, IfAttempt("attempt-1")))
+
+	job, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusCompleted, job.Status)
+	assert.Equal(t, "Example", job.Result.PageTitle)
+}
+
+func TestJobRepository_Heartbeat(t *testing.T) {
+	repo := newTestJobRepository(newFakeJobsTable())
+	ctx := context.Background()
+	beat := time.Date(2024, 5, 8, 12, 0, 0, 500, time.FixedZone("CEST", 2*60*60))
+
+	getJob := func() *models.Job {
+		job, err := repo.GetJob(ctx, "job-1")
+		require.NoError(t, err)
+		return job
+	}
+
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-1", Status: models.JobStatusPending, CreatedAt: time.Now().UTC()}))
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusRunning, WithAttempt("attempt-1")))
+	job := getJob()
+	assert.Nil(t, job.LastHeartbeatAt)
+
+	// The reconciler's view of the job goes stale once a heartbeat arrives
+	require.NoError(t, repo.HeartbeatJob(ctx, "job-1", "attempt-1", beat))
+	err := repo.UpdateJobStatus(ctx, "job-1", models.JobStatusFailed, IfHeartbeat(job.LastHeartbeatAt))
+	assert.ErrorIs(t, err, ErrJobAlive)
+
+	job = getJob()
+	require.NotNil(t, job.LastHeartbeatAt)
+	assert.True(t, beat.Equal(*job.LastHeartbeatAt))
+	assert.Equal(t, int64(1), job.Version, "heartbeats leave the version alone")
+
+	// With the latest heartbeat in hand, the job can be failed
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusFailed, IfVersion(job.Version), IfHeartbeat(job.LastHeartbeatAt)))
+	assert.Equal(t, models.JobStatusFailed, getJob().Status)
+
+	// Heartbeats of an attempt whose job finished are refused
+	err = repo.HeartbeatJob(ctx, "job-1", "attempt-1", beat.Add(time.Minute))
+	assert.ErrorIs(t, err, ErrStaleAttempt)
+	assert.True(t, beat.Equal(*getJob().LastHeartbeatAt))
+}
+
+func TestUpdateOptions_AddCondition(t *testing.T) {
+	testCases := []struct {
+		name              string
+		opts              []UpdateOption
+		expectedCondition *string
+		expectedValues    []string
+	}{
+		{
+			name: "Unconditional",
+		},
+		{
+			name:              "Version",
+			opts:              []UpdateOption{IfVersion(3)},
+			expectedCondition: aws.String("version = :expected"),
+			expectedValues:    []string{":expected"},
+		},
+		{
+			name:              "Attempt",
+			opts:              []UpdateOption{IfAttempt("attempt-1")},
+			expectedCondition: aws.String("attempt_id = :expected_attempt AND #status = :running"),
+			expectedValues:    []string{":expected_attempt", ":running"},
+		},
+		{
+			name:              "VersionAndAttempt",
+			opts:              []UpdateOption{IfVersion(0), IfAttempt("attempt-1")},
+			expectedCondition: aws.String("(attribute_not_exists(version) OR version = :expected) AND attempt_id = :expected_attempt AND #status = :running"),
+			expectedValues:    []string{":expected", ":expected_attempt", ":running"},
+		},
+		{
+			name:              "Pending",
+			opts:              []UpdateOption{IfPending()},
+			expectedCondition: aws.String("#status = :pending"),
+			expectedValues:    []string{":pending"},
+		},
+		{
+			name:              "NoHeartbeat",
+			opts:              []UpdateOption{IfHeartbeat(nil)},
+			expectedCondition: aws.String("#status = :running AND attribute_not_exists(last_heartbeat_at)"),
+			expectedValues:    []string{":running"},
+		},
+		{
+			name:              "VersionAndHeartbeat",
+			opts:              []UpdateOption{IfVersion(2), IfHeartbeat(aws.Time(time.Now()))},
+			expectedCondition: aws.String("version = :expected AND #status = :running AND last_heartbeat_at = :expected_heartbeat"),
+			expectedValues:    []string{":expected", ":expected_heartbeat", ":running"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			input := &dynamodb.UpdateItemInput{ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{}}
+			newUpdateOptions(tc.opts).addCondition(input)
+
+			assert.Equal(t, tc.expectedCondition, input.ConditionExpression)
+			for _, key := range tc.expectedValues {
+				assert.Contains(t, input.ExpressionAttributeValues, key)
+			}
+			if slices.Contains(tc.expectedValues, ":running") || slices.Contains(tc.expectedValues, ":pending") {
+				assert.Equal(t, "status", *input.ExpressionAttributeNames["#status"])
+			}
+		})
+	}
+}
+
+func TestJobRepository_Trash(t *testing.T) {
+	repo := newTestJobRepository(newFakeJobsTable())
+	ctx := context.Background()
+
+	getJob := func(id string) *models.Job {
+		job, err := repo.GetJob(ctx, id)
+		require.NoError(t, err)
+		return job
+	}
+
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-1", Status: models.JobStatusCompleted, CreatedAt: time.Now().UTC()}))
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-2", Status: models.JobStatusCompleted, CreatedAt: time.Now().UTC()}))
+
+	deleted, err := repo.DeleteJob(ctx, "job-1", time.Now().UTC())
+	require.NoError(t, err)
+	require.True(t, deleted)
+
+	first := getJob("job-1")
+	require.True(t, first.IsDeleted())
+	assert.Equal(t, int64(1), first.Version)
+
+	// Deleting twice keeps the original deletion time
+	deleted, err = repo.DeleteJob(ctx, "job-1", time.Now().UTC())
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	assert.Equal(t, first.DeletedAt, getJob("job-1").DeletedAt)
+
+	trash, next, err := repo.GetDeletedJobs(ctx, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Len(t, trash, 1)
+	assert.Equal(t, "job-1", trash[0].ID)
+
+	// A restore is tied to the deletion it was checked against
+	restored, err := repo.RestoreJob(ctx, "job-1", first.DeletedAt.Add(-time.Second))
+	require.NoError(t, err)
+	assert.False(t, restored)
+
+	restored, err = repo.RestoreJob(ctx, "job-1", *first.DeletedAt)
+	require.NoError(t, err)
+	require.True(t, restored)
+	assert.False(t, getJob("job-1").IsDeleted())
+
+	// So is a purge, a job restored and deleted again is not purged under its earlier deletion
+	deleted, err = repo.DeleteJob(ctx, "job-1", first.DeletedAt.Add(time.Minute))
+	require.NoError(t, err)
+	require.True(t, deleted)
+
+	purged, err := repo.PurgeJob(ctx, "job-1", *first.DeletedAt)
+	require.NoError(t, err)
+	assert.False(t, purged)
+
+	purged, err = repo.PurgeJob(ctx, "job-1", *getJob("job-1").DeletedAt)
+	require.NoError(t, err)
+	require.True(t, purged)
+
+	_, err = repo.GetJob(ctx, "job-1")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	assert.False(t, getJob("job-2").IsDeleted())
+}
+
+func TestJobRepository_DiscardJob(t *testing.T) {
+	repo := newTestJobRepository(newFakeJobsTable())
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-1", Status: models.JobStatusPending, CreatedAt: time.Now().UTC()}))
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-2", Status: models.JobStatusRunning, CreatedAt: time.Now().UTC()}))
+
+	discarded, err := repo.DiscardJob(ctx, "job-1")
+	require.NoError(t, err)
+	assert.True(t, discarded)
+	_, err = repo.GetJob(ctx, "job-1")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	// A job the analyzer already picked up is left alone
+	discarded, err = repo.DiscardJob(ctx, "job-2")
+	require.NoError(t, err)
+	assert.False(t, discarded)
+	_, err = repo.GetJob(ctx, "job-2")
+	assert.NoError(t, err)
+
+	discarded, err = repo.DiscardJob(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, discarded)
+}
+
+func TestStatusHistoryToModel_OrdersAndCaps(t *testing.T) {
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Interleaved appends can store changes out of order
+	entities := []StatusChangeEntity{
+		{Status: string(models.JobStatusPending), At: base},
+		{Status: string(models.JobStatusFailed), At: base.Add(2 * time.Second)},
+		{Status: string(models.JobStatusRunning), At: base.Add(time.Second)},
+	}
+	assert.Equal(t, []models.JobStatus{
+		models.JobStatusPending,
+		models.JobStatusRunning,
+		models.JobStatusFailed,
+	}, historyStatuses(statusHistoryToModel(entities)))
+
+	entities = entities[:0]
+	for i := 0; i < maxStatusHistory+5; i++ {
+		entities = append(entities, StatusChangeEntity{Status: string(models.JobStatusRunning), At: base.Add(time.Duration(i) * time.Second)})
+	}
+	history := statusHistoryToModel(entities)
+	require.Len(t, history, maxStatusHistory)
+	assert.Equal(t, base.Add(5*time.Second), history[0].At)
+
+	assert.Nil(t, statusHistoryToModel(nil))
+}
+
+func TestJobRepository_CancelsInFlightRequest(t *testing.T) {
+	testCases := []struct {
+		name string
+		call func(ctx context.Context, repo *JobRepository) error
+	}{
+		{
+			name: "PutItem",
+			call: func(ctx context.Context, repo *JobRepository) error {
+				return repo.CreateJob(ctx, &models.Job{ID: "job-1", URL: "https://example.com", Status: models.JobStatusPending})
+			},
+		},
+		{
+			name: "GetItem",
+			call: func(ctx context.Context, repo *JobRepository) error {
+				_, err := repo.GetJob(ctx, "job-1")
+				return err
+			},
+		},
+		{
+			name: "Query",
+			call: func(ctx context.Context, repo *JobRepository) error {
+				_, _, err := repo.GetJobsByStatus(ctx, []models.JobStatus{models.JobStatusRunning}, "", 10)
+				return err
+			},
+		},
+		{
+			name: "UpdateItem",
+			call: func(ctx context.Context, repo *JobRepository) error {
+				return repo.UpdateJobProgress(ctx, "job-1", 50)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The endpoint never answers, only the caller's context can end the request
+			arrived := make(chan struct{}, 1)
+			release := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case arrived <- struct{}{}:
+				default:
+				}
+				select {
+				case <-r.Context().Done():
+				case <-release:
+				}
+			}))
+			defer server.Close()
+			defer close(release)
+
+			client, err := NewDynamoDBClient(config.DynamoDBConfig{
+				Region:          "us-east-1",
+				Endpoint:        server.URL,
+				AccessKeyID:     "test",
+				SecretAccessKey: "test",
+			})
+			require.NoError(t, err)
+			repo := &JobRepository{mc: NoOpMetricsCollector{}}
+			WithJobDynamoDBClient(client)(repo)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				<-arrived
+				cancel()
+			}()
+
+			done := make(chan error, 1)
+			go func() {
+				done <- tc.call(ctx, repo)
+			}()
+
+			select {
+			case err := <-done:
+				var aerr awserr.Error
+				require.ErrorAs(t, err, &aerr)
+				assert.Equal(t, request.CanceledErrorCode, aerr.Code())
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s did not return after its context was cancelled", tc.name)
+			}
+		})
+	}
+}
+
+// batchGetStub serves BatchGetItem from stored jobs, leaving keys unprocessed for the first unprocessedRounds requests
+type batchGetStub struct {
+	dynamodbiface.DynamoDBAPI
+	jobs              map[string]bool
+	unprocessedRounds int
+	requests          [][]string
+}
+
+func (b *batchGetStub) BatchGetItemWithContext(_ aws.Context, input *dynamodb.BatchGetItemInput, _ ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	keys := input.RequestItems[JobsTableName].Keys
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, *key["id"].S)
+	}
+	b.requests = append(b.requests, ids)
+
+	output := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{}}
+	if len(b.requests) <= b.unprocessedRounds {
+		// Answer the first half and leave the rest for a retry
+		half := len(keys) / 2
+		keys, ids = keys[:half], ids[:half]
+		output.UnprocessedKeys = map[string]*dynamodb.KeysAndAttributes{
+			JobsTableName: {Keys: input.RequestItems[JobsTableName].Keys[half:]},
+		}
+	}
+	for _, id := range ids {
+		if b.jobs[id] {
+			output.Responses[JobsTableName] = append(output.Responses[JobsTableName], map[string]*dynamodb.AttributeValue{
+				"id":     {S: aws.String(id)},
+				"status": {S: aws.String(string(models.JobStatusCompleted))},
+			})
+		}
+	}
+	return output, nil
+}
+
+func jobIDs(jobs []*models.Job) []string {
+	ids := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	return ids
+}
+
+func TestJobRepository_GetJobsByIDs(t *testing.T) {
+	many := make([]string, 150)
+	for i := range many {
+		// Descending, so the order returned cannot come from the store
+		many[i] = "job-" + strconv.Itoa(len(many)-i)
+	}
+
+	testCases := []struct {
+		name              string
+		stored            []string
+		ids               []string
+		unprocessedRounds int
+		expectedIDs       []string
+		expectedRequests  []int
+		expectedError     string
+	}{
+		{
+			name:             "Chunked",
+			stored:           many,
+			ids:              many,
+			expectedIDs:      many,
+			expectedRequests: []int{100, 50},
+		},
+		{
+			name:             "MissingAndRepeated",
+			stored:           []string{"job-1", "job-3"},
+			ids:              []string{"job-3", "job-2", "job-1", "job-3"},
+			expectedIDs:      []string{"job-3", "job-1"},
+			expectedRequests: []int{3},
+		},
+		{
+			name:              "UnprocessedRetried",
+			stored:            []string{"job-1", "job-2", "job-3", "job-4"},
+			ids:               []string{"job-4", "job-3", "job-2", "job-1"},
+			unprocessedRounds: 2,
+			expectedIDs:       []string{"job-4", "job-3", "job-2", "job-1"},
+			expectedRequests:  []int{4, 2, 1},
+		},
+		{
+			name:              "UnprocessedGivenUp",
+			stored:            []string{"job-1", "job-2"},
+			ids:               []string{"job-1", "job-2"},
+			unprocessedRounds: maxBatchGetAttempts,
+			expectedRequests:  []int{2, 1, 1, 1, 1},
+			expectedError:     "1 jobs left unprocessed after 5 attempts",
+		},
+		{
+			name:        "NoIDs",
+			expectedIDs: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := &batchGetStub{jobs: make(map[string]bool), unprocessedRounds: tc.unprocessedRounds}
+			for _, id := range tc.stored {
+				stub.jobs[id] = true
+			}
+			repo := &JobRepository{mc: NoOpMetricsCollector{}}
+			WithJobDynamoDBClient(stub)(repo)
+
+			jobs, err := repo.GetJobsByIDs(context.Background(), tc.ids)
+
+			requestSizes := make([]int, 0, len(stub.requests))
+			for _, ids := range stub.requests {
+				requestSizes = append(requestSizes, len(ids))
+			}
+			if tc.expectedRequests == nil {
+				assert.Empty(t, requestSizes)
+			} else {
+				assert.Equal(t, tc.expectedRequests, requestSizes)
+			}
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedIDs, jobIDs(jobs))
+		})
+	}
+}
+
+func TestJobRepository_QueryJobsByResult(t *testing.T) {
+	table := newFakeJobsTable()
+	repo := newTestJobRepository(table)
+	ctx := context.Background()
+
+	_, _, err := repo.QueryJobsByResult(ctx, ResultQuery{}, "", 10)
+	assert.ErrorIs(t, err, ErrResultAttributesDisabled)
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-0", Status: models.JobStatusCompleted, Result: &models.AnalyzeResult{ExternalLinkCount: 500}}))
+	assert.NotContains(t, table.items["job-0"], "external_link_count", "result attributes are only written while enabled")
+	WithResultAttributes(true)(repo)
+
+	results := map[string]*models.AnalyzeResult{
+		"job-1": {HtmlVersion: "HTML5", ExternalLinkCount: 150, HasLoginForm: true},
+		"job-2": {HtmlVersion: "HTML5", ExternalLinkCount: 20},
+		"job-3": {HtmlVersion: "HTML 4.01 Strict", ExternalLinkCount: 300},
+	}
+	for _, id := range []string{"job-1", "job-2", "job-3", "job-4"} {
+		require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: id, Status: models.JobStatusPending, CreatedAt: time.Now().UTC()}))
+		if result, ok := results[id]; ok {
+			completed := models.JobStatusCompleted
+			require.NoError(t, repo.UpdateJob(ctx, id, &completed, result))
+		}
+	}
+
+	item := table.items["job-1"]
+	assert.True(t, *item["has_login_form"].BOOL)
+	assert.Equal(t, "150", *item["external_link_count"].N)
+	assert.Equal(t, "HTML5", *item["html_version"].S)
+	assert.NotContains(t, table.items["job-4"], "external_link_count", "a job without a result is left out of the index")
+
+	loginForm := true
+	testCases := []struct {
+		name     string
+		query    ResultQuery
+		expected []string
+	}{
+		{name: "All", query: ResultQuery{}, expected: []string{"job-3", "job-1", "job-2"}},
+		{name: "MinExternalLinks", query: ResultQuery{MinExternalLinks: 100}, expected: []string{"job-3", "job-1"}},
+		{name: "LoginForm", query: ResultQuery{HasLoginForm: &loginForm}, expected: []string{"job-1"}},
+		{name: "HTMLVersion", query: ResultQuery{HTMLVersion: "HTML5", MinExternalLinks: 10}, expected: []string{"job-1", "job-2"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jobs, next, err := repo.QueryJobsByResult(ctx, tc.query, "", 10)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, jobIDs(jobs))
+			assert.Empty(t, next)
+		})
+	}
+
+	_, _, err = repo.QueryJobsByResult(ctx, ResultQuery{}, "job-1", 10)
+	assert.ErrorContains(t, err, "invalid cursor")
+}
@@ -0,0 +1,483 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"shared/models"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryJobRepository is a JobRepositoryInterface keeping the jobs in memory, for tests and tools that need
+// the repository's behavior without DynamoDB. Jobs are kept as the entities DynamoDB stores and read back
+// through the same conversions, newest first by ID as in the table.
+// Unlike DynamoDB, updating a job that does not exist fails with ErrJobNotFound instead of creating a partial item.
+type MemoryJobRepository struct {
+	mu   sync.Mutex
+	jobs map[string]JobEntity
+}
+
+var _ JobRepositoryInterface = (*MemoryJobRepository)(nil)
+
+// NewMemoryJobRepository creates an empty in-memory job repository
+func NewMemoryJobRepository() *MemoryJobRepository {
+	return &MemoryJobRepository{jobs: make(map[string]JobEntity)}
+}
+
+// CreateJob stores a job, replacing any job with the same ID
+func (m *MemoryJobRepository) CreateJob(_ context.Context, job *models.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var entity JobEntity
+	entity.FromModel(job)
+	if job.Result != nil {
+		entity.setResultAttributes(job.Result)
+	}
+	if len(entity.StatusHistory) == 0 {
+		entity.StatusHistory = []StatusChangeEntity{{Status: entity.Status, At: job.CreatedAt}}
+	}
+	m.jobs[job.ID] = entity
+	return nil
+}
+
+// GetJob returns a job by ID
+func (m *MemoryJobRepository) GetJob(_ context.Context, id string) (*models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entity, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return entity.ToModel(), nil
+}
+
+// GetJobsByIDs returns the jobs with the given IDs in the order of ids, leaving out missing and repeated IDs
+func (m *MemoryJobRepository) GetJobsByIDs(_ context.Context, ids []string) ([]*models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]*models.Job, 0, len(ids))
+	for _, id := range uniqueIDs(ids) {
+		if entity, ok := m.jobs[id]; ok {
+			jobs = append(jobs, entity.ToModel())
+		}
+	}
+	return jobs, nil
+}
+
+// GetAllJobs returns every job, newest first
+func (m *MemoryJobRepository) GetAllJobs(_ context.Context) ([]*models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs, _ := m.page(func(JobEntity) bool { return true }, "", 0)
+	return jobs, nil
+}
+
+// UpdateJobStatus moves a job to a status, as JobRepository.UpdateJobStatus does
+func (m *MemoryJobRepository) UpdateJobStatus(_ context.Context, id string, status models.JobStatus, opts ...UpdateOption) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.update(id, newUpdateOptions(opts), func(entity *JobEntity) {
+		setStatus(entity, status)
+	})
+}
+
+// UpdateJob updates the status and result of a job, each only when given
+func (m *MemoryJobRepository) UpdateJob(_ context.Context, id string, status *models.JobStatus, result *models.AnalyzeResult, opts ...UpdateOption) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.update(id, newUpdateOptions(opts), func(entity *JobEntity) {
+		if status != nil {
+			setStatus(entity, *status)
+		}
+		if result != nil {
+			entity.Result = &AnalyzeResultEntity{}
+			entity.Result.FromModel(result)
+			entity.setResultAttributes(result)
+		}
+	})
+}
+
+// UpdateJobProgress stores the progress of a running job, it is a no-op for jobs in other statuses
+func (m *MemoryJobRepository) UpdateJobProgress(_ context.Context, id string, progress float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entity, ok := m.jobs[id]
+	if !ok || entity.Status != string(models.JobStatusRunning) {
+		return nil
+	}
+	entity.Progress = progress
+	entity.UpdatedAt = time.Now()
+	m.jobs[id] = entity
+	return nil
+}
+
+// HeartbeatJob records that the analysis running the job under attemptID was alive at the given time,
+// failing with ErrStaleAttempt once the job no longer runs under that attempt
+func (m *MemoryJobRepository) HeartbeatJob(_ context.Context, id, attemptID string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entity, ok := m.jobs[id]
+	if !ok || entity.AttemptID != attemptID || entity.Status != string(models.JobStatusRunning) {
+		return fmt.Errorf("%w: job %s is no longer running under attempt %s", ErrStaleAttempt, id, attemptID)
+	}
+	heartbeat := at.UTC()
+	entity.LastHeartbeatAt = &heartbeat
+	m.jobs[id] = entity
+	return nil
+}
+
+// GetJobsByStatus returns a page of the jobs in any of the given statuses, newest first
+func (m *MemoryJobRepository) GetJobsByStatus(_ context.Context, statuses []models.JobStatus, cursor string, limit int64) ([]*models.Job, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs, next := m.page(func(entity JobEntity) bool {
+		return slices.Contains(statuses, models.JobStatus(entity.Status))
+	}, cursor, limit)
+	return jobs, next, nil
+}
+
+// CancelJob moves a pending or running job to cancelled, reporting false for other jobs
+func (m *MemoryJobRepository) CancelJob(_ context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entity, ok := m.jobs[id]
+	if !ok || models.JobStatus(entity.Status).IsTerminal() {
+		return false, nil
+	}
+
+	setStatus(&entity, models.JobStatusCancelled)
+	completedAt := entity.UpdatedAt
+	entity.CompletedAt = &completedAt
+	entity.Version++
+	m.jobs[id] = entity
+	return true, nil
+}
+
+// DeleteJob moves a job to the trash, reporting false when it does not exist or is already there
+func (m *MemoryJobRepository) DeleteJob(_ context.Context, id string, deletedAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entity, ok := m.jobs[id]
+	if !ok || entity.DeletedAt != nil {
+		return false, nil
+	}
+
+	entity.DeletedAt = &deletedAt
+	entity.UpdatedAt = time.Now()
+	entity.Version++
+	m.jobs[id] = entity
+	return true, nil
+}
+
+// RestoreJob takes a job out of the trash, reporting false unless it is still the deletion made at deletedAt
+func (m *MemoryJobRepository) RestoreJob(_ context.Context, id string, deletedAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entity, ok := m.jobs[id]
+	if !ok || entity.DeletedAt == nil || !entity.DeletedAt.Equal(deletedAt) {
+		return false, nil
+	}
+
+	entity.DeletedAt = nil
+	entity.UpdatedAt = time.Now()
+	entity.Version++
+	m.jobs[id] = entity
+	return true, nil
+}
+
+// GetDeletedJobs returns a page of the jobs in the trash, newest first
+func (m *MemoryJobRepository) GetDeletedJobs(_ context.Context, cursor string, limit int64) ([]*models.Job, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs, next := m.page(func(entity JobEntity) bool { return entity.DeletedAt != nil }, cursor, limit)
+	return jobs, next, nil
+}
+
+// PurgeJob removes a job from the trash, reporting false unless it is still the deletion made at deletedAt
+func (m *MemoryJobRepository) PurgeJob(_ context.Context, id string, deletedAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entity, ok := m.jobs[id]
+	if !ok || entity.DeletedAt == nil || !entity.DeletedAt.Equal(deletedAt) {
+		return false, nil
+	}
+	delete(m.jobs, id)
+	return true, nil
+}
+
+// DiscardJob removes a pending job, reporting false for other jobs
+func (m *MemoryJobRepository) DiscardJob(_ context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entity, ok := m.jobs[id]
+	if !ok || entity.Status != string(models.JobStatusPending) {
+		return false, nil
+	}
+	delete(m.jobs, id)
+	return true, nil
+}
+
+// QueryJobsByResult returns a page of the jobs whose result is selected by the query, most external links first,
+// as the result index orders them. Result attributes are always kept in memory.
+// As with DynamoDB, the limit counts the jobs read from the index before the login form and HTML version
+// filters apply, so a page may be short or empty while the cursor tells there are more.
+func (m *MemoryJobRepository) QueryJobsByResult(_ context.Context, query ResultQuery, cursor string, limit int64) ([]*models.Job, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var after []string
+	if cursor != "" {
+		externalLinks, id, err := parseResultCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = []string{fmt.Sprintf("%020d", externalLinks), id}
+	}
+
+	// Ordered by the index key, the count and then the ID, both descending
+	key := func(e JobEntity) []string { return []string{fmt.Sprintf("%020d", *e.ExternalLinkCount), e.ID} }
+	var read []JobEntity
+	for _, entity := range m.jobs {
+		inIndex := entity.ExternalLinkCount != nil && *entity.ExternalLinkCount >= query.MinExternalLinks
+		if inIndex && (after == nil || slices.Compare(key(entity), after) < 0) {
+			read = append(read, entity)
+		}
+	}
+	slices.SortFunc(read, func(a, b JobEntity) int { return slices.Compare(key(b), key(a)) })
+
+	next := ""
+	if limit > 0 && int64(len(read)) > limit {
+		last := read[limit-1]
+		read, next = read[:limit], resultCursor(*last.ExternalLinkCount, last.ID)
+	}
+
+	jobs := make([]*models.Job, 0, len(read))
+	for _, entity := range read {
+		if query.matches(entity) {
+			jobs = append(jobs, entity.ToModel())
+		}
+	}
+	return jobs, next, nil
+}
+
+// update applies a change to a job as a versioned update: the update options are honored and the version counted
+func (m *MemoryJobRepository) update(id string, options updateOptions, change func(entity *JobEntity)) error {
+	entity, ok := m.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if options.expectedVersion != nil && entity.Version != *options.expectedVersion {
+		return &VersionConflictError{JobID: id, Expected: *options.expectedVersion}
+	}
+	if options.expectedAttempt != "" && (entity.AttemptID != options.expectedAttempt || entity.Status != string(models.JobStatusRunning)) {
+		return fmt.Errorf("%w: job %s is no longer running under attempt %s", ErrStaleAttempt, id, options.expectedAttempt)
+	}
+	if options.expectPending && entity.Status != string(models.JobStatusPending) {
+		return fmt.Errorf("%w: job %s is no longer pending", ErrStaleAttempt, id)
+	}
+	if options.checkHeartbeat && (entity.Status != string(models.JobStatusRunning) || !sameHeartbeat(entity.LastHeartbeatAt, options.expectedHeartbeat)) {
+		return fmt.Errorf("%w: job %s sent a heartbeat or finished meanwhile", ErrJobAlive, id)
+	}
+
+	change(&entity)
+	if options.failureCode != "" {
+		entity.FailureCode = string(options.failureCode)
+		entity.FailureReason = options.failureReason
+	}
+	if options.attemptID != "" {
+		entity.AttemptID = options.attemptID
+	}
+	if options.fetchStatusCode != 0 {
+		entity.FetchStatusCode = options.fetchStatusCode
+	}
+	entity.UpdatedAt = time.Now()
+	entity.Version++
+	m.jobs[id] = entity
+	return nil
+}
+
+// sameHeartbeat reports whether two heartbeats are the same, nil being the lack of one
+func sameHeartbeat(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+// page returns the jobs matching keep, newest first, starting after the cursor and up to limit when positive.
+// The returned cursor is empty once the last job has been returned.
+func (m *MemoryJobRepository) page(keep func(JobEntity) bool, cursor string, limit int64) ([]*models.Job, string) {
+	ids := slices.SortedFunc(maps.Keys(m.jobs), func(a, b string) int { return strings.Compare(b, a) })
+
+	jobs := make([]*models.Job, 0)
+	for i, id := range ids {
+		if cursor != "" && id >= cursor {
+			continue
+		}
+		if entity := m.jobs[id]; keep(entity) {
+			jobs = append(jobs, entity.ToModel())
+		}
+		if limit > 0 && int64(len(jobs)) == limit && i < len(ids)-1 {
+			return jobs, id
+		}
+	}
+	return jobs, ""
+}
+
+// setStatus moves a job to a status, recording the change and the progress a terminal status forces
+func setStatus(entity *JobEntity, status models.JobStatus) {
+	now := time.Now().UTC()
+	entity.Status = string(status)
+	entity.UpdatedAt = now
+	entity.StatusHistory = append(slices.Clone(entity.StatusHistory), StatusChangeEntity{Status: string(status), At: now})
+	if len(entity.StatusHistory) > maxStatusHistory {
+		entity.StatusHistory = entity.StatusHistory[len(entity.StatusHistory)-maxStatusHistory:]
+	}
+	if progress, ok := models.TerminalProgress(status); ok {
+		entity.Progress = progress
+	}
+}
+
+// MemoryTaskRepository is a TaskRepositoryInterface keeping the tasks in memory, for tests and tools that need
+// the repository's behavior without DynamoDB.
+type MemoryTaskRepository struct {
+	mu    sync.Mutex
+	tasks map[string]map[models.TaskType]models.Task
+}
+
+var _ TaskRepositoryInterface = (*MemoryTaskRepository)(nil)
+
+// NewMemoryTaskRepository creates an empty in-memory task repository
+func NewMemoryTaskRepository() *MemoryTaskRepository {
+	return &MemoryTaskRepository{tasks: make(map[string]map[models.TaskType]models.Task)}
+}
+
+// CreateTasks stores tasks, replacing any task of the same job and type
+func (m *MemoryTaskRepository) CreateTasks(_ context.Context, tasks ...*models.Task) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, task := range tasks {
+		if m.tasks[task.JobID] == nil {
+			m.tasks[task.JobID] = make(map[models.TaskType]models.Task)
+		}
+		stored := *task
+		stored.SubTasks = maps.Clone(task.SubTasks)
+		if stored.SubTasks == nil {
+			stored.SubTasks = make(map[string]models.SubTask)
+		}
+		m.tasks[task.JobID][task.Type] = stored
+	}
+	return nil
+}
+
+// UpdateTaskStatus sets the status of a task, creating it without subtasks when missing as DynamoDB does
+func (m *MemoryTaskRepository) UpdateTaskStatus(_ context.Context, jobId string, taskType models.TaskType, status models.TaskStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.tasks[jobId] == nil {
+		m.tasks[jobId] = make(map[models.TaskType]models.Task)
+	}
+	task, ok := m.tasks[jobId][taskType]
+	if !ok {
+		task = models.Task{JobID: jobId, Type: taskType}
+	}
+	task.Status = status
+	m.tasks[jobId][taskType] = task
+	return nil
+}
+
+// GetTasksByJobId returns the tasks of a job ordered by type, as the table's sort key orders them
+func (m *MemoryTaskRepository) GetTasksByJobId(_ context.Context, jobId string) ([]models.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tasks := m.jobTasks(jobId)
+	slices.SortFunc(tasks, func(a, b models.Task) int { return strings.Compare(string(a.Type), string(b.Type)) })
+	return tasks, nil
+}
+
+// GetTasksByJobIds returns the tasks of several jobs keyed by job ID, in the order they run.
+// Jobs without tasks are left out of the map.
+func (m *MemoryTaskRepository) GetTasksByJobIds(_ context.Context, jobIds []string) (map[string][]models.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	taskTypes := models.TaskTypes()
+	tasks := make(map[string][]models.Task)
+	for _, jobId := range uniqueIDs(jobIds) {
+		jobTasks := m.jobTasks(jobId)
+		if len(jobTasks) == 0 {
+			continue
+		}
+		slices.SortFunc(jobTasks, func(a, b models.Task) int {
+			return slices.Index(taskTypes, a.Type) - slices.Index(taskTypes, b.Type)
+		})
+		tasks[jobId] = jobTasks
+	}
+	return tasks, nil
+}
+
+// AddSubTaskByKey adds a subtask to a task
+func (m *MemoryTaskRepository) AddSubTaskByKey(_ context.Context, jobId string, taskType models.TaskType, key string, subtask models.SubTask) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.setSubTask(jobId, taskType, key, subtask)
+}
+
+// UpdateSubTaskByKey replaces a subtask of a task
+func (m *MemoryTaskRepository) UpdateSubTaskByKey(_ context.Context, jobId string, taskType models.TaskType, key string, subtask models.SubTask) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.setSubTask(jobId, taskType, key, subtask)
+}
+
+// DeleteTasksByJobId removes the tasks of a job
+func (m *MemoryTaskRepository) DeleteTasksByJobId(_ context.Context, jobId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.tasks, jobId)
+	return nil
+}
+
+// jobTasks returns copies of the tasks of a job, in no particular order
+func (m *MemoryTaskRepository) jobTasks(jobId string) []models.Task {
+	tasks := make([]models.Task, 0, len(m.tasks[jobId]))
+	for _, task := range m.tasks[jobId] {
+		task.SubTasks = maps.Clone(task.SubTasks)
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// setSubTask stores a subtask, failing as DynamoDB does when the task has no subtask map to store it in
+func (m *MemoryTaskRepository) setSubTask(jobId string, taskType models.TaskType, key string, subtask models.SubTask) error {
+	task, ok := m.tasks[jobId][taskType]
+	if !ok || task.SubTasks == nil {
+		return fmt.Errorf("task %s of job %s has no subtasks to update", taskType, jobId)
+	}
+	task.SubTasks[key] = subtask
+	return nil
+}
@@ -0,0 +1,245 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"shared/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryJobRepository_UpdateJobStatus(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryJobRepository()
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-1", Status: models.JobStatusRunning, Progress: 40}))
+
+	err := repo.UpdateJobStatus(ctx, "job-1", models.JobStatusFailed, IfVersion(3))
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	err = repo.UpdateJobStatus(ctx, "job-1", models.JobStatusFailed,
+		IfVersion(0), WithFailure(models.FailureCodeInternal, "stuck"))
+	require.NoError(t, err)
+
+	job, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusFailed, job.Status)
+	assert.Equal(t, int64(1), job.Version)
+	assert.Equal(t, float64(0), job.Progress)
+	assert.Equal(t, models.FailureCodeInternal, job.FailureCode)
+	assert.Equal(t, "stuck", job.FailureReason)
+	require.Len(t, job.StatusHistory, 2)
+	assert.Equal(t, models.JobStatusFailed, job.StatusHistory[1].Status)
+
+	assert.ErrorIs(t, repo.UpdateJobStatus(ctx, "missing", models.JobStatusFailed), ErrJobNotFound)
+}
+
+func TestMemoryJobRepository_Attempt(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryJobRepository()
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-1", Status: models.JobStatusPending}))
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusRunning, WithAttempt("attempt-1")))
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusRunning, WithAttempt("attempt-2")))
+
+	completed := models.JobStatusCompleted
+	assert.ErrorIs(t, repo.UpdateJob(ctx, "job-1", &completed, &models.AnalyzeResult{}, IfAttempt("attempt-1")), ErrStaleAttempt)
+	require.NoError(t, repo.UpdateJob(ctx, "job-1", &completed, &models.AnalyzeResult{}, IfAttempt("attempt-2")))
+	assert.ErrorIs(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusFailed, IfAttempt("attempt-2")), ErrStaleAttempt)
+
+	job, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusCompleted, job.Status)
+	assert.Equal(t, "attempt-2", job.AttemptID)
+}
+
+func TestMemoryJobRepository_IfPending(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryJobRepository()
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-1", Status: models.JobStatusPending}))
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-2", Status: models.JobStatusPending}))
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-2", models.JobStatusRunning, WithAttempt("attempt-1")))
+
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusFailed, IfPending()))
+	assert.ErrorIs(t, repo.UpdateJobStatus(ctx, "job-2", models.JobStatusFailed, IfPending()), ErrStaleAttempt)
+
+	job, err := repo.GetJob(ctx, "job-2")
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusRunning, job.Status)
+}
+
+func TestMemoryJobRepository_Heartbeat(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryJobRepository()
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-1", Status: models.JobStatusPending}))
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusRunning, WithAttempt("attempt-1")))
+
+	beat := time.Date(2024, 5, 8, 12, 0, 0, 0, time.UTC)
+	assert.ErrorIs(t, repo.HeartbeatJob(ctx, "job-1", "attempt-2", beat), ErrStaleAttempt)
+	assert.ErrorIs(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusFailed, IfHeartbeat(&beat)), ErrJobAlive)
+
+	require.NoError(t, repo.HeartbeatJob(ctx, "job-1", "attempt-1", beat))
+	assert.ErrorIs(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusFailed, IfHeartbeat(nil)), ErrJobAlive)
+	require.NoError(t, repo.UpdateJobStatus(ctx, "job-1", models.JobStatusFailed, IfHeartbeat(&beat)))
+
+	job, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusFailed, job.Status)
+	assert.Equal(t, &beat, job.LastHeartbeatAt)
+	assert.ErrorIs(t, repo.HeartbeatJob(ctx, "job-1", "attempt-1", beat), ErrStaleAttempt)
+}
+
+func TestMemoryJobRepository_GetJobsByStatus(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryJobRepository()
+	for i := 1; i <= 5; i++ {
+		status := models.JobStatusRunning
+		if i%2 == 0 {
+			status = models.JobStatusCompleted
+		}
+		require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: fmt.Sprintf("job-%d", i), Status: status}))
+	}
+
+	var ids []string
+	cursor := ""
+	for pages := 1; ; pages++ {
+		jobs, next, err := repo.GetJobsByStatus(ctx, []models.JobStatus{models.JobStatusRunning}, cursor, 2)
+		require.NoError(t, err)
+		for _, job := range jobs {
+			ids = append(ids, job.ID)
+		}
+		if next == "" {
+			break
+		}
+		require.Less(t, pages, 5)
+		cursor = next
+	}
+
+	assert.Equal(t, []string{"job-5", "job-3", "job-1"}, ids)
+}
+
+func TestMemoryJobRepository_QueryJobsByResult(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryJobRepository()
+	completed := models.JobStatusCompleted
+	for i, externalLinks := range []int{5, 120, 40, 120, 300} {
+		id := fmt.Sprintf("job-%d", i+1)
+		require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: id, Status: models.JobStatusRunning}))
+		require.NoError(t, repo.UpdateJob(ctx, id, &completed, &models.AnalyzeResult{ExternalLinkCount: externalLinks, HasLoginForm: i%2 == 0}))
+	}
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-6", Status: models.JobStatusPending}))
+
+	var ids []string
+	cursor := ""
+	for pages := 1; ; pages++ {
+		jobs, next, err := repo.QueryJobsByResult(ctx, ResultQuery{MinExternalLinks: 10}, cursor, 2)
+		require.NoError(t, err)
+		for _, job := range jobs {
+			ids = append(ids, job.ID)
+		}
+		if next == "" {
+			break
+		}
+		require.Less(t, pages, 5)
+		cursor = next
+	}
+	assert.Equal(t, []string{"job-5", "job-4", "job-2", "job-3"}, ids, "most external links first, then newest")
+
+	loginForm := true
+	jobs, _, err := repo.QueryJobsByResult(ctx, ResultQuery{HasLoginForm: &loginForm}, "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job-5", "job-3", "job-1"}, jobIDs(jobs))
+
+	// The limit counts the jobs read before filtering, as DynamoDB does
+	var pages [][]string
+	cursor = ""
+	for {
+		jobs, next, err := repo.QueryJobsByResult(ctx, ResultQuery{HasLoginForm: &loginForm}, cursor, 2)
+		require.NoError(t, err)
+		pages = append(pages, jobIDs(jobs))
+		if next == "" {
+			break
+		}
+		require.Less(t, len(pages), 5)
+		cursor = next
+	}
+	assert.Equal(t, [][]string{{"job-5"}, {"job-3"}, {"job-1"}}, pages)
+}
+
+func TestMemoryJobRepository_Trash(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryJobRepository()
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-1", Status: models.JobStatusCompleted}))
+	deletedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	deleted, err := repo.DeleteJob(ctx, "job-1", deletedAt)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+
+	trash, _, err := repo.GetDeletedJobs(ctx, "", 10)
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+
+	purged, err := repo.PurgeJob(ctx, "job-1", deletedAt.Add(time.Second))
+	require.NoError(t, err)
+	assert.False(t, purged, "a later deletion is not purged")
+
+	restored, err := repo.RestoreJob(ctx, "job-1", deletedAt)
+	require.NoError(t, err)
+	assert.True(t, restored)
+
+	job, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	assert.False(t, job.IsDeleted())
+	assert.Equal(t, int64(2), job.Version)
+}
+
+func TestMemoryJobRepository_CancelJob(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryJobRepository()
+	require.NoError(t, repo.CreateJob(ctx, &models.Job{ID: "job-1", Status: models.JobStatusRunning, Progress: 60}))
+
+	cancelled, err := repo.CancelJob(ctx, "job-1")
+	require.NoError(t, err)
+	assert.True(t, cancelled)
+
+	job, err := repo.GetJob(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.JobStatusCancelled, job.Status)
+	assert.NotNil(t, job.CompletedAt)
+	assert.Equal(t, float64(0), job.Progress)
+
+	cancelled, err = repo.CancelJob(ctx, "job-1")
+	require.NoError(t, err)
+	assert.False(t, cancelled)
+}
+
+func TestMemoryTaskRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryTaskRepository()
+	require.NoError(t, repo.CreateTasks(ctx,
+		&models.Task{JobID: "job-1", Type: models.TaskTypeVerifyingLinks, Status: models.TaskStatusPending},
+		&models.Task{JobID: "job-1", Type: models.TaskTypeExtracting, Status: models.TaskStatusCompleted},
+	))
+
+	require.NoError(t, repo.AddSubTaskByKey(ctx, "job-1", models.TaskTypeVerifyingLinks, "0",
+		models.SubTask{Type: models.SubTaskTypeValidatingLink, Status: models.TaskStatusRunning}))
+	require.NoError(t, repo.UpdateTaskStatus(ctx, "job-1", models.TaskTypeVerifyingLinks, models.TaskStatusRunning))
+	assert.Error(t, repo.AddSubTaskByKey(ctx, "job-2", models.TaskTypeVerifyingLinks, "0", models.SubTask{}))
+
+	byJob, err := repo.GetTasksByJobIds(ctx, []string{"job-1", "job-2"})
+	require.NoError(t, err)
+	require.Contains(t, byJob, "job-1")
+	assert.NotContains(t, byJob, "job-2")
+	tasks := byJob["job-1"]
+	require.Len(t, tasks, 2)
+	assert.Equal(t, models.TaskTypeExtracting, tasks[0].Type)
+	assert.Equal(t, models.TaskStatusRunning, tasks[1].Status)
+	assert.Len(t, tasks[1].SubTasks, 1)
+
+	require.NoError(t, repo.DeleteTasksByJobId(ctx, "job-1"))
+	tasks, err = repo.GetTasksByJobId(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Empty(t, tasks)
+}
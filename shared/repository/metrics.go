@@ -7,6 +7,7 @@ import (
 // MetricsCollector is an interface for recording database metrics
 type MetricsCollector interface {
 	RecordDatabaseOperation(operation, table string, start time.Time, err error)
+	RecordResultSize(table string, bytes int, truncated bool)
 }
 
 // NoOpMetricsCollector is a no-op implementation of MetricsCollector
@@ -15,3 +16,7 @@ type NoOpMetricsCollector struct{}
 // RecordDatabaseOperation is a no-op implementation of RecordDatabaseOperation
 func (n NoOpMetricsCollector) RecordDatabaseOperation(operation, table string, start time.Time, err error) {
 }
+
+// RecordResultSize is a no-op implementation of RecordResultSize
+func (n NoOpMetricsCollector) RecordResultSize(table string, bytes int, truncated bool) {
+}
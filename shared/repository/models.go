@@ -2,20 +2,49 @@ package repository
 
 import (
 	"shared/models"
+	"sort"
 	"time"
 )
 
 // JobEntity represents a job as stored in DynamoDB
 type JobEntity struct {
-	PartitionKey string               `dynamodbav:"partition_key"`
-	ID           string               `dynamodbav:"id"`
-	URL          string               `dynamodbav:"url"`
-	Status       string               `dynamodbav:"status"`
-	CreatedAt    time.Time            `dynamodbav:"created_at"`
-	UpdatedAt    time.Time            `dynamodbav:"updated_at"`
-	StartedAt    *time.Time           `dynamodbav:"started_at"`
-	CompletedAt  *time.Time           `dynamodbav:"completed_at"`
-	Result       *AnalyzeResultEntity `dynamodbav:"result"`
+	PartitionKey  string               `dynamodbav:"partition_key"`
+	ID            string               `dynamodbav:"id"`
+	URL           string               `dynamodbav:"url"`
+	Status        string               `dynamodbav:"status"`
+	CreatedAt     time.Time            `dynamodbav:"created_at"`
+	UpdatedAt     time.Time            `dynamodbav:"updated_at"`
+	StartedAt     *time.Time           `dynamodbav:"started_at"`
+	CompletedAt   *time.Time           `dynamodbav:"completed_at"`
+	Result        *AnalyzeResultEntity `dynamodbav:"result"`
+	GroupID       string               `dynamodbav:"group_id,omitempty"`
+	Tasks         []string             `dynamodbav:"tasks,omitempty"`
+	Progress      float64              `dynamodbav:"progress"`
+	VerifyScope   string               `dynamodbav:"verify_scope,omitempty"`
+	Version       int64                `dynamodbav:"version"`
+	DeletedAt     *time.Time           `dynamodbav:"deleted_at,omitempty"`
+	FailureCode   string               `dynamodbav:"failure_code,omitempty"`
+	FailureReason string               `dynamodbav:"failure_reason,omitempty"`
+	AttemptID     string               `dynamodbav:"attempt_id,omitempty"`
+
+	FetchStatusCode int    `dynamodbav:"fetch_status_code,omitempty"`
+	HeaderProfile   string `dynamodbav:"header_profile,omitempty"`
+	// LastHeartbeatAt is always stored in UTC, so conditions can compare it as written
+	LastHeartbeatAt *time.Time `dynamodbav:"last_heartbeat_at,omitempty"`
+
+	StatusHistory []StatusChangeEntity `dynamodbav:"status_history,omitempty"`
+
+	// HasLoginForm, ExternalLinkCount and HTMLVersion copy fields of the result to the item, see ResultIndexName.
+	// They are left out until the job has a result.
+	HasLoginForm      *bool  `dynamodbav:"has_login_form,omitempty"`
+	ExternalLinkCount *int   `dynamodbav:"external_link_count,omitempty"`
+	HTMLVersion       string `dynamodbav:"html_version,omitempty"`
+}
+
+// StatusChangeEntity represents a status change as stored in DynamoDB
+type StatusChangeEntity struct {
+	Status string    `dynamodbav:"status"`
+	At     time.Time `dynamodbav:"at"`
 }
 
 // ToModel converts JobEntity to domain model
@@ -26,15 +55,76 @@ func (e *JobEntity) ToModel() *models.Job {
 	}
 
 	return &models.Job{
-		ID:          e.ID,
-		URL:         e.URL,
-		Status:      models.JobStatus(e.Status),
-		CreatedAt:   e.CreatedAt,
-		UpdatedAt:   e.UpdatedAt,
-		StartedAt:   e.StartedAt,
-		CompletedAt: e.CompletedAt,
-		Result:      result,
+		ID:            e.ID,
+		URL:           e.URL,
+		Status:        models.JobStatus(e.Status),
+		CreatedAt:     e.CreatedAt,
+		UpdatedAt:     e.UpdatedAt,
+		StartedAt:     e.StartedAt,
+		CompletedAt:   e.CompletedAt,
+		Result:        result,
+		GroupID:       e.GroupID,
+		Tasks:         taskTypesToModel(e.Tasks),
+		Progress:      e.Progress,
+		VerifyScope:   models.LinkScope(e.VerifyScope),
+		Version:       e.Version,
+		DeletedAt:     e.DeletedAt,
+		FailureCode:   models.FailureCode(e.FailureCode),
+		FailureReason: e.FailureReason,
+		AttemptID:     e.AttemptID,
+
+		FetchStatusCode: e.FetchStatusCode,
+		HeaderProfile:   models.HeaderProfile(e.HeaderProfile),
+		LastHeartbeatAt: e.LastHeartbeatAt,
+		StatusHistory:   statusHistoryToModel(e.StatusHistory),
+	}
+}
+
+// statusHistoryToModel orders the stored changes by time and keeps the latest maxStatusHistory.
+// Concurrent appends may have been stored out of order.
+func statusHistoryToModel(entities []StatusChangeEntity) []models.StatusChange {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	history := make([]models.StatusChange, 0, len(entities))
+	for _, e := range entities {
+		history = append(history, models.StatusChange{Status: models.JobStatus(e.Status), At: e.At})
+	}
+	sort.SliceStable(history, func(i, k int) bool {
+		return history[i].At.Before(history[k].At)
+	})
+
+	if len(history) > maxStatusHistory {
+		history = history[len(history)-maxStatusHistory:]
+	}
+	return history
+}
+
+// taskTypesToModel converts stored task types, keeping nil for an empty list
+func taskTypesToModel(types []string) []models.TaskType {
+	if len(types) == 0 {
+		return nil
+	}
+
+	taskTypes := make([]models.TaskType, 0, len(types))
+	for _, t := range types {
+		taskTypes = append(taskTypes, models.TaskType(t))
+	}
+	return taskTypes
+}
+
+// taskTypesFromModel converts task types for storage, keeping nil for an empty list
+func taskTypesFromModel(taskTypes []models.TaskType) []string {
+	if len(taskTypes) == 0 {
+		return nil
+	}
+
+	types := make([]string, 0, len(taskTypes))
+	for _, t := range taskTypes {
+		types = append(types, string(t))
 	}
+	return types
 }
 
 // FromModel converts domain model to JobEntity
@@ -47,6 +137,26 @@ func (e *JobEntity) FromModel(job *models.Job) {
 	e.UpdatedAt = job.UpdatedAt
 	e.StartedAt = job.StartedAt
 	e.CompletedAt = job.CompletedAt
+	e.GroupID = job.GroupID
+	e.Tasks = taskTypesFromModel(job.Tasks)
+	e.Progress = job.Progress
+	e.VerifyScope = string(job.VerifyScope)
+	e.HeaderProfile = string(job.HeaderProfile)
+	e.Version = job.Version
+	e.DeletedAt = job.DeletedAt
+	e.FailureCode = string(job.FailureCode)
+	e.FailureReason = job.FailureReason
+	e.FetchStatusCode = job.FetchStatusCode
+	e.AttemptID = job.AttemptID
+	if job.LastHeartbeatAt != nil {
+		heartbeat := job.LastHeartbeatAt.UTC()
+		e.LastHeartbeatAt = &heartbeat
+	}
+
+	e.StatusHistory = make([]StatusChangeEntity, 0, len(job.StatusHistory))
+	for _, change := range job.StatusHistory {
+		e.StatusHistory = append(e.StatusHistory, StatusChangeEntity{Status: string(change.Status), At: change.At})
+	}
 
 	if job.Result != nil {
 		e.Result = &AnalyzeResultEntity{}
@@ -54,6 +164,49 @@ func (e *JobEntity) FromModel(job *models.Job) {
 	}
 }
 
+// setResultAttributes copies the queryable fields of a result to the item
+func (e *JobEntity) setResultAttributes(result *models.AnalyzeResult) {
+	hasLoginForm, externalLinks := result.HasLoginForm, result.ExternalLinkCount
+	e.HasLoginForm = &hasLoginForm
+	e.ExternalLinkCount = &externalLinks
+	e.HTMLVersion = result.HtmlVersion
+}
+
+// GroupEntity represents a comparison group as stored in DynamoDB
+type GroupEntity struct {
+	ID          string     `dynamodbav:"id"`
+	Label       string     `dynamodbav:"label"`
+	JobIDs      []string   `dynamodbav:"job_ids"`
+	Status      string     `dynamodbav:"status"`
+	CreatedAt   time.Time  `dynamodbav:"created_at"`
+	UpdatedAt   time.Time  `dynamodbav:"updated_at"`
+	CompletedAt *time.Time `dynamodbav:"completed_at"`
+}
+
+// ToModel converts GroupEntity to domain model
+func (e *GroupEntity) ToModel() *models.Group {
+	return &models.Group{
+		ID:          e.ID,
+		Label:       e.Label,
+		JobIDs:      e.JobIDs,
+		Status:      models.GroupStatus(e.Status),
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.UpdatedAt,
+		CompletedAt: e.CompletedAt,
+	}
+}
+
+// FromModel converts domain model to GroupEntity
+func (e *GroupEntity) FromModel(group *models.Group) {
+	e.ID = group.ID
+	e.Label = group.Label
+	e.JobIDs = group.JobIDs
+	e.Status = string(group.Status)
+	e.CreatedAt = group.CreatedAt
+	e.UpdatedAt = group.UpdatedAt
+	e.CompletedAt = group.CompletedAt
+}
+
 // TaskEntity represents a task as stored in DynamoDB
 type TaskEntity struct {
 	JobID    string                   `dynamodbav:"job_id"`
@@ -102,6 +255,63 @@ type AnalyzeResultEntity struct {
 	AccessibleLinks   int            `dynamodbav:"accessible_links"`
 	InaccessibleLinks int            `dynamodbav:"inaccessible_links"`
 	HasLoginForm      bool           `dynamodbav:"has_login_form"`
+	ExcludedLinks     int            `dynamodbav:"excluded_links"`
+	OutOfScopeLinks   int            `dynamodbav:"out_of_scope_links"`
+	UnverifiedLinks   int            `dynamodbav:"unverified_links"`
+
+	InsecureForms []InsecureFormEntity `dynamodbav:"insecure_forms,omitempty"`
+
+	SampledVerification        bool    `dynamodbav:"sampled_verification,omitempty"`
+	SampleFraction             float64 `dynamodbav:"sample_fraction,omitempty"`
+	EstimatedAccessibleLinks   int     `dynamodbav:"estimated_accessible_links,omitempty"`
+	EstimatedInaccessibleLinks int     `dynamodbav:"estimated_inaccessible_links,omitempty"`
+
+	RedundantRedirectLinks []LinkRedirectEntity        `dynamodbav:"redundant_redirect_links,omitempty"`
+	RedundantRedirectCount int                         `dynamodbav:"redundant_redirect_count"`
+	BrokenAnchors          []string                    `dynamodbav:"broken_anchors,omitempty"`
+	BrokenAnchorCount      int                         `dynamodbav:"broken_anchor_count"`
+	AssetLinks             map[string]int              `dynamodbav:"asset_links,omitempty"`
+	LinkClassifications    []LinkClassificationEntity  `dynamodbav:"link_classifications,omitempty"`
+	OriginalLinks          []string                    `dynamodbav:"original_links,omitempty"`
+	CollapsedLinks         int                         `dynamodbav:"collapsed_links,omitempty"`
+	TrackersDetected       []string                    `dynamodbav:"trackers_detected,omitempty"`
+	TrackerCount           int                         `dynamodbav:"tracker_count"`
+	CanonicalURL           string                      `dynamodbav:"canonical_url,omitempty"`
+	CanonicalMismatch      bool                        `dynamodbav:"canonical_mismatch,omitempty"`
+	OpenGraphURL           string                      `dynamodbav:"og_url,omitempty"`
+	CanonicalConsistency   *CanonicalConsistencyEntity `dynamodbav:"canonical_consistency,omitempty"`
+	IsAMP                  bool                        `dynamodbav:"is_amp,omitempty"`
+	Pagination             *PaginationEntity           `dynamodbav:"pagination,omitempty"`
+	NoIndex                bool                        `dynamodbav:"no_index,omitempty"`
+	NoFollow               bool                        `dynamodbav:"no_follow,omitempty"`
+
+	ImageCount         int `dynamodbav:"image_count"`
+	AccessibleImages   int `dynamodbav:"accessible_images"`
+	InaccessibleImages int `dynamodbav:"inaccessible_images"`
+	UnverifiedImages   int `dynamodbav:"unverified_images"`
+
+	InternalLinkDepthHistogram map[string]int `dynamodbav:"internal_link_depth_histogram,omitempty"`
+	MaxInternalLinkDepth       int            `dynamodbav:"max_internal_link_depth"`
+	NavOnlyPage                bool           `dynamodbav:"nav_only_page"`
+
+	LikelyClientSideRendered bool            `dynamodbav:"likely_client_side_rendered"`
+	Warnings                 []WarningEntity `dynamodbav:"warnings,omitempty"`
+
+	ResponseHeaders map[string]string `dynamodbav:"response_headers,omitempty"`
+
+	ContentEncoding  string   `dynamodbav:"content_encoding,omitempty"`
+	TransferredBytes int64    `dynamodbav:"transferred_bytes,omitempty"`
+	ContentBytes     int64    `dynamodbav:"content_bytes,omitempty"`
+	RedirectChain    []string `dynamodbav:"redirect_chain,omitempty"`
+
+	FetchTiming *FetchTimingEntity `dynamodbav:"fetch_timing,omitempty"`
+
+	PartialResult bool     `dynamodbav:"partial_result"`
+	SkippedTasks  []string `dynamodbav:"skipped_tasks,omitempty"`
+	FailedTasks   []string `dynamodbav:"failed_tasks,omitempty"`
+	Truncated     bool     `dynamodbav:"truncated"`
+
+	LinksTruncated bool `dynamodbav:"links_truncated,omitempty"`
 }
 
 // ToModel converts AnalyzeResultEntity to domain model
@@ -116,6 +326,63 @@ func (e *AnalyzeResultEntity) ToModel() *models.AnalyzeResult {
 		AccessibleLinks:   e.AccessibleLinks,
 		InaccessibleLinks: e.InaccessibleLinks,
 		HasLoginForm:      e.HasLoginForm,
+		ExcludedLinks:     e.ExcludedLinks,
+		OutOfScopeLinks:   e.OutOfScopeLinks,
+		UnverifiedLinks:   e.UnverifiedLinks,
+
+		InsecureForms: insecureFormsToModel(e.InsecureForms),
+
+		SampledVerification:        e.SampledVerification,
+		SampleFraction:             e.SampleFraction,
+		EstimatedAccessibleLinks:   e.EstimatedAccessibleLinks,
+		EstimatedInaccessibleLinks: e.EstimatedInaccessibleLinks,
+
+		RedundantRedirectLinks: linkRedirectsToModel(e.RedundantRedirectLinks),
+		RedundantRedirectCount: e.RedundantRedirectCount,
+		BrokenAnchors:          e.BrokenAnchors,
+		BrokenAnchorCount:      e.BrokenAnchorCount,
+		AssetLinks:             e.AssetLinks,
+		LinkClassifications:    linkClassificationsToModel(e.LinkClassifications),
+		OriginalLinks:          e.OriginalLinks,
+		CollapsedLinks:         e.CollapsedLinks,
+		TrackersDetected:       e.TrackersDetected,
+		TrackerCount:           e.TrackerCount,
+		CanonicalURL:           e.CanonicalURL,
+		CanonicalMismatch:      e.CanonicalMismatch,
+		OpenGraphURL:           e.OpenGraphURL,
+		CanonicalConsistency:   canonicalConsistencyToModel(e.CanonicalConsistency),
+		IsAMP:                  e.IsAMP,
+		Pagination:             paginationToModel(e.Pagination),
+		NoIndex:                e.NoIndex,
+		NoFollow:               e.NoFollow,
+
+		ImageCount:         e.ImageCount,
+		AccessibleImages:   e.AccessibleImages,
+		InaccessibleImages: e.InaccessibleImages,
+		UnverifiedImages:   e.UnverifiedImages,
+
+		InternalLinkDepthHistogram: e.InternalLinkDepthHistogram,
+		MaxInternalLinkDepth:       e.MaxInternalLinkDepth,
+		NavOnlyPage:                e.NavOnlyPage,
+
+		LikelyClientSideRendered: e.LikelyClientSideRendered,
+		Warnings:                 warningsToModel(e.Warnings),
+
+		ResponseHeaders: e.ResponseHeaders,
+
+		ContentEncoding:  e.ContentEncoding,
+		TransferredBytes: e.TransferredBytes,
+		ContentBytes:     e.ContentBytes,
+		RedirectChain:    e.RedirectChain,
+
+		FetchTiming: fetchTimingToModel(e.FetchTiming),
+
+		PartialResult: e.PartialResult,
+		SkippedTasks:  taskTypesToModel(e.SkippedTasks),
+		FailedTasks:   taskTypesToModel(e.FailedTasks),
+		Truncated:     e.Truncated,
+
+		LinksTruncated: e.LinksTruncated,
 	}
 }
 
@@ -130,23 +397,323 @@ func (e *AnalyzeResultEntity) FromModel(result *models.AnalyzeResult) {
 	e.AccessibleLinks = result.AccessibleLinks
 	e.InaccessibleLinks = result.InaccessibleLinks
 	e.HasLoginForm = result.HasLoginForm
+	e.ExcludedLinks = result.ExcludedLinks
+	e.OutOfScopeLinks = result.OutOfScopeLinks
+	e.UnverifiedLinks = result.UnverifiedLinks
+	e.InsecureForms = insecureFormsFromModel(result.InsecureForms)
+
+	e.SampledVerification = result.SampledVerification
+	e.SampleFraction = result.SampleFraction
+	e.EstimatedAccessibleLinks = result.EstimatedAccessibleLinks
+	e.EstimatedInaccessibleLinks = result.EstimatedInaccessibleLinks
+
+	e.RedundantRedirectLinks = linkRedirectsFromModel(result.RedundantRedirectLinks)
+	e.RedundantRedirectCount = result.RedundantRedirectCount
+	e.BrokenAnchors = result.BrokenAnchors
+	e.BrokenAnchorCount = result.BrokenAnchorCount
+	e.AssetLinks = result.AssetLinks
+	e.LinkClassifications = linkClassificationsFromModel(result.LinkClassifications)
+	e.OriginalLinks = result.OriginalLinks
+	e.CollapsedLinks = result.CollapsedLinks
+	e.TrackersDetected = result.TrackersDetected
+	e.TrackerCount = result.TrackerCount
+	e.CanonicalURL = result.CanonicalURL
+	e.CanonicalMismatch = result.CanonicalMismatch
+	e.OpenGraphURL = result.OpenGraphURL
+	e.CanonicalConsistency = canonicalConsistencyFromModel(result.CanonicalConsistency)
+	e.IsAMP = result.IsAMP
+	e.Pagination = paginationFromModel(result.Pagination)
+	e.NoIndex = result.NoIndex
+	e.NoFollow = result.NoFollow
+
+	e.ImageCount = result.ImageCount
+	e.AccessibleImages = result.AccessibleImages
+	e.InaccessibleImages = result.InaccessibleImages
+	e.UnverifiedImages = result.UnverifiedImages
+
+	e.InternalLinkDepthHistogram = result.InternalLinkDepthHistogram
+	e.MaxInternalLinkDepth = result.MaxInternalLinkDepth
+	e.NavOnlyPage = result.NavOnlyPage
+
+	e.LikelyClientSideRendered = result.LikelyClientSideRendered
+	e.Warnings = warningsFromModel(result.Warnings)
+
+	e.ResponseHeaders = result.ResponseHeaders
+
+	e.ContentEncoding = result.ContentEncoding
+	e.TransferredBytes = result.TransferredBytes
+	e.ContentBytes = result.ContentBytes
+	e.RedirectChain = result.RedirectChain
+
+	e.FetchTiming = fetchTimingFromModel(result.FetchTiming)
+
+	e.PartialResult = result.PartialResult
+	e.SkippedTasks = taskTypesFromModel(result.SkippedTasks)
+	e.FailedTasks = taskTypesFromModel(result.FailedTasks)
+	e.Truncated = result.Truncated
+	e.LinksTruncated = result.LinksTruncated
+}
+
+// WarningEntity represents a result warning as stored in DynamoDB
+type WarningEntity struct {
+	Code    string `dynamodbav:"code"`
+	Message string `dynamodbav:"message"`
+}
+
+// warningsToModel converts stored warnings, keeping nil for an empty list
+func warningsToModel(entities []WarningEntity) []models.Warning {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	warnings := make([]models.Warning, 0, len(entities))
+	for _, e := range entities {
+		warnings = append(warnings, models.Warning{Code: e.Code, Message: e.Message})
+	}
+	return warnings
+}
+
+// warningsFromModel converts warnings for storage, keeping nil for an empty list
+func warningsFromModel(warnings []models.Warning) []WarningEntity {
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	entities := make([]WarningEntity, 0, len(warnings))
+	for _, w := range warnings {
+		entities = append(entities, WarningEntity{Code: w.Code, Message: w.Message})
+	}
+	return entities
+}
+
+// LinkRedirectEntity represents a redirected link as stored in DynamoDB
+type LinkRedirectEntity struct {
+	From string `dynamodbav:"from"`
+	To   string `dynamodbav:"to"`
+}
+
+// linkRedirectsToModel converts stored link redirects, keeping nil for an empty list
+func linkRedirectsToModel(entities []LinkRedirectEntity) []models.LinkRedirect {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	redirects := make([]models.LinkRedirect, 0, len(entities))
+	for _, e := range entities {
+		redirects = append(redirects, models.LinkRedirect{From: e.From, To: e.To})
+	}
+	return redirects
+}
+
+// linkRedirectsFromModel converts link redirects for storage, keeping nil for an empty list
+func linkRedirectsFromModel(redirects []models.LinkRedirect) []LinkRedirectEntity {
+	if len(redirects) == 0 {
+		return nil
+	}
+
+	entities := make([]LinkRedirectEntity, 0, len(redirects))
+	for _, r := range redirects {
+		entities = append(entities, LinkRedirectEntity{From: r.From, To: r.To})
+	}
+	return entities
+}
+
+// LinkClassificationEntity represents the classification of a link as stored in DynamoDB
+type LinkClassificationEntity struct {
+	URL      string `dynamodbav:"url"`
+	External bool   `dynamodbav:"external"`
+	Reason   string `dynamodbav:"reason"`
+}
+
+// linkClassificationsToModel converts stored link classifications, keeping nil for an empty list
+func linkClassificationsToModel(entities []LinkClassificationEntity) []models.LinkClassification {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	classifications := make([]models.LinkClassification, 0, len(entities))
+	for _, e := range entities {
+		classifications = append(classifications, models.LinkClassification{
+			URL:      e.URL,
+			External: e.External,
+			Reason:   models.LinkClassificationReason(e.Reason),
+		})
+	}
+	return classifications
+}
+
+// linkClassificationsFromModel converts link classifications for storage, keeping nil for an empty list
+func linkClassificationsFromModel(classifications []models.LinkClassification) []LinkClassificationEntity {
+	if len(classifications) == 0 {
+		return nil
+	}
+
+	entities := make([]LinkClassificationEntity, 0, len(classifications))
+	for _, c := range classifications {
+		entities = append(entities, LinkClassificationEntity{URL: c.URL, External: c.External, Reason: string(c.Reason)})
+	}
+	return entities
+}
+
+// InsecureFormEntity represents an insecure form as stored in DynamoDB
+type InsecureFormEntity struct {
+	Action string `dynamodbav:"action"`
+	Reason string `dynamodbav:"reason"`
+}
+
+// insecureFormsToModel converts stored insecure forms, keeping nil for an empty list
+func insecureFormsToModel(entities []InsecureFormEntity) []models.InsecureForm {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	forms := make([]models.InsecureForm, 0, len(entities))
+	for _, e := range entities {
+		forms = append(forms, models.InsecureForm{Action: e.Action, Reason: models.InsecureFormReason(e.Reason)})
+	}
+	return forms
+}
+
+// insecureFormsFromModel converts insecure forms for storage, keeping nil for an empty list
+func insecureFormsFromModel(forms []models.InsecureForm) []InsecureFormEntity {
+	if len(forms) == 0 {
+		return nil
+	}
+
+	entities := make([]InsecureFormEntity, 0, len(forms))
+	for _, f := range forms {
+		entities = append(entities, InsecureFormEntity{Action: f.Action, Reason: string(f.Reason)})
+	}
+	return entities
+}
+
+// CanonicalConsistencyEntity represents the canonical URL consistency of a page as stored in DynamoDB
+type CanonicalConsistencyEntity struct {
+	Matches bool   `dynamodbav:"matches"`
+	Reason  string `dynamodbav:"reason,omitempty"`
+}
+
+// canonicalConsistencyToModel converts a stored canonical consistency, nil for results stored without one
+func canonicalConsistencyToModel(e *CanonicalConsistencyEntity) *models.CanonicalConsistency {
+	if e == nil {
+		return nil
+	}
+	return &models.CanonicalConsistency{Matches: e.Matches, Reason: models.CanonicalInconsistencyReason(e.Reason)}
+}
+
+// canonicalConsistencyFromModel converts a canonical consistency for storage
+func canonicalConsistencyFromModel(c *models.CanonicalConsistency) *CanonicalConsistencyEntity {
+	if c == nil {
+		return nil
+	}
+	return &CanonicalConsistencyEntity{Matches: c.Matches, Reason: string(c.Reason)}
+}
+
+// PaginationEntity represents the rel="next" chain of a page as stored in DynamoDB
+type PaginationEntity struct {
+	NextURL    string                 `dynamodbav:"next_url,omitempty"`
+	PrevURL    string                 `dynamodbav:"prev_url,omitempty"`
+	Pages      []PaginationPageEntity `dynamodbav:"pages,omitempty"`
+	StopReason string                 `dynamodbav:"stop_reason,omitempty"`
+}
+
+// PaginationPageEntity represents a page of a followed rel="next" chain as stored in DynamoDB
+type PaginationPageEntity struct {
+	URL        string         `dynamodbav:"url"`
+	Headings   map[string]int `dynamodbav:"headings,omitempty"`
+	LinkOffset int            `dynamodbav:"link_offset"`
+	LinkCount  int            `dynamodbav:"link_count"`
+}
+
+// paginationToModel converts a stored pagination, nil for results stored without one
+func paginationToModel(e *PaginationEntity) *models.Pagination {
+	if e == nil {
+		return nil
+	}
+	p := &models.Pagination{NextURL: e.NextURL, PrevURL: e.PrevURL, StopReason: models.PaginationStopReason(e.StopReason)}
+	for _, page := range e.Pages {
+		headings := page.Headings
+		if headings == nil {
+			headings = map[string]int{}
+		}
+		p.Pages = append(p.Pages, models.PaginationPage{URL: page.URL, Headings: headings, LinkOffset: page.LinkOffset, LinkCount: page.LinkCount})
+	}
+	return p
+}
+
+// paginationFromModel converts a pagination for storage
+func paginationFromModel(p *models.Pagination) *PaginationEntity {
+	if p == nil {
+		return nil
+	}
+	e := &PaginationEntity{NextURL: p.NextURL, PrevURL: p.PrevURL, StopReason: string(p.StopReason)}
+	for _, page := range p.Pages {
+		e.Pages = append(e.Pages, PaginationPageEntity{URL: page.URL, Headings: page.Headings, LinkOffset: page.LinkOffset, LinkCount: page.LinkCount})
+	}
+	return e
+}
+
+// FetchTimingEntity represents the phase durations of a page fetch as stored in DynamoDB
+type FetchTimingEntity struct {
+	DNSSeconds             float64 `dynamodbav:"dns_seconds"`
+	ConnectSeconds         float64 `dynamodbav:"connect_seconds"`
+	TLSHandshakeSeconds    float64 `dynamodbav:"tls_handshake_seconds"`
+	TimeToFirstByteSeconds float64 `dynamodbav:"ttfb_seconds"`
+	DownloadSeconds        float64 `dynamodbav:"download_seconds"`
+	TotalSeconds           float64 `dynamodbav:"total_seconds"`
+}
+
+// fetchTimingToModel converts a stored fetch timing, nil for results stored without one
+func fetchTimingToModel(e *FetchTimingEntity) *models.FetchTiming {
+	if e == nil {
+		return nil
+	}
+	return &models.FetchTiming{
+		DNSSeconds:             e.DNSSeconds,
+		ConnectSeconds:         e.ConnectSeconds,
+		TLSHandshakeSeconds:    e.TLSHandshakeSeconds,
+		TimeToFirstByteSeconds: e.TimeToFirstByteSeconds,
+		DownloadSeconds:        e.DownloadSeconds,
+		TotalSeconds:           e.TotalSeconds,
+	}
+}
+
+// fetchTimingFromModel converts a fetch timing for storage
+func fetchTimingFromModel(t *models.FetchTiming) *FetchTimingEntity {
+	if t == nil {
+		return nil
+	}
+	return &FetchTimingEntity{
+		DNSSeconds:             t.DNSSeconds,
+		ConnectSeconds:         t.ConnectSeconds,
+		TLSHandshakeSeconds:    t.TLSHandshakeSeconds,
+		TimeToFirstByteSeconds: t.TimeToFirstByteSeconds,
+		DownloadSeconds:        t.DownloadSeconds,
+		TotalSeconds:           t.TotalSeconds,
+	}
 }
 
 // SubTaskEntity represents a subtask as stored in DynamoDB
 type SubTaskEntity struct {
-	Type        string `dynamodbav:"type"`
-	Status      string `dynamodbav:"status"`
-	URL         string `dynamodbav:"url"`
-	Description string `dynamodbav:"description"`
+	Type          string `dynamodbav:"type"`
+	Status        string `dynamodbav:"status"`
+	URL           string `dynamodbav:"url"`
+	Description   string `dynamodbav:"description"`
+	ContentType   string `dynamodbav:"content_type,omitempty"`
+	ContentLength *int64 `dynamodbav:"content_length,omitempty"`
+	ErrorCode     string `dynamodbav:"error_code,omitempty"`
 }
 
 // ToModel converts SubTaskEntity to domain model
 func (e *SubTaskEntity) ToModel() *models.SubTask {
 	return &models.SubTask{
-		Type:        models.SubTaskType(e.Type),
-		Status:      models.TaskStatus(e.Status),
-		URL:         e.URL,
-		Description: e.Description,
+		Type:          models.SubTaskType(e.Type),
+		Status:        models.TaskStatus(e.Status),
+		URL:           e.URL,
+		Description:   e.Description,
+		ContentType:   e.ContentType,
+		ContentLength: e.ContentLength,
+		ErrorCode:     models.LinkErrorCode(e.ErrorCode),
 	}
 }
 
@@ -156,4 +723,7 @@ func (e *SubTaskEntity) FromModel(subTask *models.SubTask) {
 	e.Status = string(subTask.Status)
 	e.URL = subTask.URL
 	e.Description = subTask.Description
+	e.ContentType = subTask.ContentType
+	e.ContentLength = subTask.ContentLength
+	e.ErrorCode = string(subTask.ErrorCode)
 }
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shared/models"
+	"shared/tracing"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// ResultIndexName is the index of the jobs table over the result attributes copied to the job item, keyed by
+// the partition key and the external link count. Only jobs with a result are in it.
+const ResultIndexName = "result-external-links-index"
+
+// ErrResultAttributesDisabled is returned when querying jobs by their results while result attributes are disabled
+var ErrResultAttributesDisabled = errors.New("result attributes are disabled, set DYNAMODB_RESULT_ATTRIBUTES to query jobs by their results")
+
+// ResultQuery selects jobs by the result attributes copied to the job item.
+// The zero value selects every job with a result.
+type ResultQuery struct {
+	// MinExternalLinks keeps the jobs whose page has at least that many external links
+	MinExternalLinks int
+	// HasLoginForm keeps the jobs whose page has a login form when true, or has none when false
+	HasLoginForm *bool
+	// HTMLVersion keeps the jobs whose page is of that HTML version, such as HTML5
+	HTMLVersion string
+}
+
+// matches reports whether the result attributes of an item are selected by the query
+func (q ResultQuery) matches(e JobEntity) bool {
+	return e.ExternalLinkCount != nil && *e.ExternalLinkCount >= q.MinExternalLinks &&
+		(q.HasLoginForm == nil || e.HasLoginForm != nil && *e.HasLoginForm == *q.HasLoginForm) &&
+		(q.HTMLVersion == "" || e.HTMLVersion == q.HTMLVersion)
+}
+
+// WithResultAttributes copies the queryable result fields to the job item when results are stored,
+// which QueryJobsByResult needs along with the result index of the table
+func WithResultAttributes(enabled bool) JobOption {
+	return func(j *JobRepository) {
+		j.resultAttributes = enabled
+	}
+}
+
+// resultAttributeValues adds the values of the result attributes of a stored result, returning their update clause
+func resultAttributeValues(values map[string]*dynamodb.AttributeValue, result *models.AnalyzeResult) string {
+	values[":has_login_form"] = &dynamodb.AttributeValue{BOOL: aws.Bool(result.HasLoginForm)}
+	values[":external_link_count"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(result.ExternalLinkCount))}
+	values[":html_version"] = &dynamodb.AttributeValue{S: aws.String(result.HtmlVersion)}
+	return "has_login_form = :has_login_form, external_link_count = :external_link_count, html_version = :html_version"
+}
+
+// QueryJobsByResult queries one page of the jobs whose result is selected by the query, most external links first.
+// The limit counts the jobs read from the index before the login form and HTML version filters apply, so a page
+// may be short or even empty while more follow. The returned cursor is empty once the last page has been read.
+func (j *JobRepository) QueryJobsByResult(ctx context.Context, query ResultQuery, cursor string, limit int64) (jobs []*models.Job, next string, err error) {
+	if !j.resultAttributes {
+		return nil, "", ErrResultAttributesDisabled
+	}
+
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "query_jobs_by_result", JobsTableName)
+
+	defer func() {
+		j.mc.RecordDatabaseOperation("query_jobs_by_result", JobsTableName, start, err)
+		span.Close(err)
+	}()
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(JobsTableName),
+		IndexName:              aws.String(ResultIndexName),
+		KeyConditionExpression: aws.String("#partition_key = :partition_key AND external_link_count >= :min_external_links"),
+		ExpressionAttributeNames: map[string]*string{
+			"#partition_key": aws.String("partition_key"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":partition_key":      {S: aws.String("1000")},
+			":min_external_links": {N: aws.String(strconv.Itoa(query.MinExternalLinks))},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int64(limit),
+	}
+
+	var filters []string
+	if query.HasLoginForm != nil {
+		filters = append(filters, "has_login_form = :has_login_form")
+		input.ExpressionAttributeValues[":has_login_form"] = &dynamodb.AttributeValue{BOOL: query.HasLoginForm}
+	}
+	if query.HTMLVersion != "" {
+		filters = append(filters, "html_version = :html_version")
+		input.ExpressionAttributeValues[":html_version"] = &dynamodb.AttributeValue{S: aws.String(query.HTMLVersion)}
+	}
+	if len(filters) > 0 {
+		input.FilterExpression = aws.String(strings.Join(filters, " AND "))
+	}
+
+	if cursor != "" {
+		externalLinks, id, err := parseResultCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		input.ExclusiveStartKey = map[string]*dynamodb.AttributeValue{
+			"partition_key":       {S: aws.String("1000")},
+			"id":                  {S: aws.String(id)},
+			"external_link_count": {N: aws.String(strconv.Itoa(externalLinks))},
+		}
+	}
+
+	output, err := j.ddb.QueryWithContext(ctx, input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	jobs = make([]*models.Job, 0, len(output.Items))
+	for _, item := range output.Items {
+		var entity JobEntity
+		if err := dynamodbattribute.UnmarshalMap(item, &entity); err != nil {
+			return nil, "", err
+		}
+		jobs = append(jobs, entity.ToModel())
+	}
+
+	id, idOK := output.LastEvaluatedKey["id"]
+	count, countOK := output.LastEvaluatedKey["external_link_count"]
+	if idOK && countOK && id.S != nil && count.N != nil {
+		externalLinks, err := strconv.Atoi(*count.N)
+		if err != nil {
+			return nil, "", err
+		}
+		next = resultCursor(externalLinks, *id.S)
+	}
+	return jobs, next, nil
+}
+
+// resultCursor is the cursor of a page of jobs queried by result, ending at the job with the given ID and count,
+// as the index needs both to carry on from there
+func resultCursor(externalLinks int, id string) string {
+	return strconv.Itoa(externalLinks) + ":" + id
+}
+
+// parseResultCursor reads the external link count and job ID of a cursor made by resultCursor
+func parseResultCursor(cursor string) (int, string, error) {
+	count, id, ok := strings.Cut(cursor, ":")
+	externalLinks, err := strconv.Atoi(count)
+	if !ok || err != nil || id == "" {
+		return 0, "", fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return externalLinks, id, nil
+}
@@ -5,11 +5,13 @@ import (
 	"shared/config"
 	"shared/models"
 	"shared/tracing"
+	"slices"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
 const TasksTableName = "web-analyzer-tasks"
@@ -20,8 +22,10 @@ type TaskRepositoryInterface interface {
 	CreateTasks(ctx context.Context, tasks ...*models.Task) error
 	UpdateTaskStatus(ctx context.Context, jobId string, taskType models.TaskType, status models.TaskStatus) error
 	GetTasksByJobId(ctx context.Context, jobId string) ([]models.Task, error)
+	GetTasksByJobIds(ctx context.Context, jobIds []string) (map[string][]models.Task, error)
 	AddSubTaskByKey(ctx context.Context, jobId string, taskType models.TaskType, key string, subtask models.SubTask) error
 	UpdateSubTaskByKey(ctx context.Context, jobId string, taskType models.TaskType, key string, subtask models.SubTask) error
+	DeleteTasksByJobId(ctx context.Context, jobId string) error
 }
 
 // TaskOption is a function that configures the TaskRepository
@@ -34,10 +38,19 @@ func WithTaskMetrics(mc MetricsCollector) TaskOption {
 	}
 }
 
+// WithTaskDynamoDBClient overrides the DynamoDB client, mainly for tests
+func WithTaskDynamoDBClient(ddb dynamodbiface.DynamoDBAPI) TaskOption {
+	return func(t *TaskRepository) {
+		t.ddb = ddb
+	}
+}
+
 // TaskRepository is a struct for task repository
 type TaskRepository struct {
-	ddb *dynamodb.DynamoDB
+	ddb dynamodbiface.DynamoDBAPI
 	mc  MetricsCollector
+	// batchRetryDelay is the wait before the first retry of keys a batch read left unprocessed
+	batchRetryDelay time.Duration
 }
 
 // NewTaskRepository creates a new task repository
@@ -47,7 +60,7 @@ func NewTaskRepository(cfg config.DynamoDBConfig, opts ...TaskOption) (*TaskRepo
 		return nil, err
 	}
 
-	repo := &TaskRepository{ddb: ddb, mc: NoOpMetricsCollector{}}
+	repo := &TaskRepository{ddb: ddb, mc: NoOpMetricsCollector{}, batchRetryDelay: defaultBatchRetryDelay}
 	for _, opt := range opts {
 		opt(repo)
 	}
@@ -58,7 +71,7 @@ func NewTaskRepository(cfg config.DynamoDBConfig, opts ...TaskOption) (*TaskRepo
 // CreateTasks creates tasks
 func (t *TaskRepository) CreateTasks(ctx context.Context, tasks ...*models.Task) (err error) {
 	start := time.Now()
-	_, span := tracing.CreateDatabaseSpan(ctx, "create_tasks", TasksTableName)
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "create_tasks", TasksTableName)
 
 	defer func() {
 		t.mc.RecordDatabaseOperation("create_tasks", TasksTableName, start, err)
@@ -97,14 +110,14 @@ func (t *TaskRepository) CreateTasks(ctx context.Context, tasks ...*models.Task)
 		},
 	}
 
-	_, err = t.ddb.BatchWriteItem(input)
+	_, err = t.ddb.BatchWriteItemWithContext(ctx, input)
 	return err
 }
 
 // UpdateTaskStatus updates task status
 func (t *TaskRepository) UpdateTaskStatus(ctx context.Context, jobId string, taskType models.TaskType, status models.TaskStatus) (err error) {
 	start := time.Now()
-	_, span := tracing.CreateDatabaseSpan(ctx, "update_task_status", TasksTableName)
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "update_task_status", TasksTableName)
 
 	defer func() {
 		t.mc.RecordDatabaseOperation("update_task_status", TasksTableName, start, err)
@@ -132,14 +145,14 @@ func (t *TaskRepository) UpdateTaskStatus(ctx context.Context, jobId string, tas
 		},
 	}
 
-	_, err = t.ddb.UpdateItem(input)
+	_, err = t.ddb.UpdateItemWithContext(ctx, input)
 	return err
 }
 
 // GetTasksByJobId queries tasks by job ID
 func (t *TaskRepository) GetTasksByJobId(ctx context.Context, jobId string) (tasks []models.Task, err error) {
 	start := time.Now()
-	_, span := tracing.CreateDatabaseSpan(ctx, "query_tasks_by_job_id", TasksTableName)
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "query_tasks_by_job_id", TasksTableName)
 
 	defer func() {
 		t.mc.RecordDatabaseOperation("query_tasks_by_job_id", TasksTableName, start, err)
@@ -156,7 +169,7 @@ func (t *TaskRepository) GetTasksByJobId(ctx context.Context, jobId string) (tas
 		},
 	}
 
-	result, err := t.ddb.Query(input)
+	result, err := t.ddb.QueryWithContext(ctx, input)
 	if err != nil {
 		return nil, err
 	}
@@ -174,10 +187,61 @@ func (t *TaskRepository) GetTasksByJobId(ctx context.Context, jobId string) (tas
 	return tasks, nil
 }
 
+// GetTasksByJobIds reads the tasks of several jobs in batches, keyed by job ID.
+// Each job has a task of every type, so the tasks are read by key rather than queried job by job.
+// Jobs without tasks are left out of the map, repeated IDs are read once.
+func (t *TaskRepository) GetTasksByJobIds(ctx context.Context, jobIds []string) (tasks map[string][]models.Task, err error) {
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "batch_get_tasks", TasksTableName)
+
+	defer func() {
+		t.mc.RecordDatabaseOperation("batch_get_tasks", TasksTableName, start, err)
+		span.Close(err)
+	}()
+
+	taskTypes := models.TaskTypes()
+	keys := make([]map[string]*dynamodb.AttributeValue, 0, len(jobIds)*len(taskTypes))
+	for _, jobId := range uniqueIDs(jobIds) {
+		for _, taskType := range taskTypes {
+			keys = append(keys, map[string]*dynamodb.AttributeValue{
+				"job_id": {
+					S: aws.String(jobId),
+				},
+				"type": {
+					S: aws.String(string(taskType)),
+				},
+			})
+		}
+	}
+
+	tasks = make(map[string][]models.Task)
+	for chunk := range slices.Chunk(keys, maxBatchGetKeys) {
+		err := batchGetItems(ctx, t.ddb, TasksTableName, "tasks", chunk, t.batchRetryDelay, func(item map[string]*dynamodb.AttributeValue) error {
+			var entity TaskEntity
+			if err := dynamodbattribute.UnmarshalMap(item, &entity); err != nil {
+				return err
+			}
+			tasks[entity.JobID] = append(tasks[entity.JobID], *entity.ToModel())
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Batches answer in no particular order, list the tasks in the order they run
+	for _, jobTasks := range tasks {
+		slices.SortFunc(jobTasks, func(a, b models.Task) int {
+			return slices.Index(taskTypes, a.Type) - slices.Index(taskTypes, b.Type)
+		})
+	}
+	return tasks, nil
+}
+
 // AddSubTaskByKey adds a subtask by key
 func (t *TaskRepository) AddSubTaskByKey(ctx context.Context, jobId string, taskType models.TaskType, key string, subtask models.SubTask) (err error) {
 	start := time.Now()
-	_, span := tracing.CreateDatabaseSpan(ctx, "add_subtask", TasksTableName)
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "add_subtask", TasksTableName)
 
 	defer func() {
 		t.mc.RecordDatabaseOperation("add_subtask", TasksTableName, start, err)
@@ -215,14 +279,14 @@ func (t *TaskRepository) AddSubTaskByKey(ctx context.Context, jobId string, task
 		},
 	}
 
-	_, err = t.ddb.UpdateItem(input)
+	_, err = t.ddb.UpdateItemWithContext(ctx, input)
 	return err
 }
 
 // UpdateSubTaskByKey updates a subtask by key
 func (t *TaskRepository) UpdateSubTaskByKey(ctx context.Context, jobId string, taskType models.TaskType, key string, subtask models.SubTask) (err error) {
 	start := time.Now()
-	_, span := tracing.CreateDatabaseSpan(ctx, "update_subtask", TasksTableName)
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "update_subtask", TasksTableName)
 
 	defer func() {
 		t.mc.RecordDatabaseOperation("update_subtask", TasksTableName, start, err)
@@ -259,6 +323,49 @@ func (t *TaskRepository) UpdateSubTaskByKey(ctx context.Context, jobId string, t
 		},
 	}
 
-	_, err = t.ddb.UpdateItem(input)
+	_, err = t.ddb.UpdateItemWithContext(ctx, input)
 	return err
 }
+
+// DeleteTasksByJobId permanently removes the tasks of a job
+func (t *TaskRepository) DeleteTasksByJobId(ctx context.Context, jobId string) (err error) {
+	start := time.Now()
+	ctx, span := tracing.CreateDatabaseSpan(ctx, "delete_tasks_by_job_id", TasksTableName)
+
+	defer func() {
+		t.mc.RecordDatabaseOperation("delete_tasks_by_job_id", TasksTableName, start, err)
+		span.Close(err)
+	}()
+
+	result, err := t.ddb.QueryWithContext(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(TasksTableName),
+		KeyConditionExpression: aws.String("job_id = :job_id"),
+		ProjectionExpression:   aws.String("job_id, #type"),
+		ExpressionAttributeNames: map[string]*string{
+			"#type": aws.String("type"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":job_id": {
+				S: aws.String(jobId),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range result.Items {
+		_, err = t.ddb.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(TasksTableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				"job_id": item["job_id"],
+				"type":   item["type"],
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"shared/models"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// taskBatchGetStub serves BatchGetItem from the tasks of stored jobs, answering each batch in reverse order
+// and leaving its last key unprocessed for the first unprocessedRounds requests
+type taskBatchGetStub struct {
+	dynamodbiface.DynamoDBAPI
+	jobs              map[string]bool
+	unprocessedRounds int
+	requests          []int
+}
+
+func (b *taskBatchGetStub) BatchGetItemWithContext(_ aws.Context, input *dynamodb.BatchGetItemInput, _ ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	keys := input.RequestItems[TasksTableName].Keys
+	b.requests = append(b.requests, len(keys))
+
+	output := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{}}
+	if len(b.requests) <= b.unprocessedRounds {
+		output.UnprocessedKeys = map[string]*dynamodb.KeysAndAttributes{
+			TasksTableName: {Keys: keys[len(keys)-1:]},
+		}
+		keys = keys[:len(keys)-1]
+	}
+	for i := len(keys) - 1; i >= 0; i-- {
+		if b.jobs[*keys[i]["job_id"].S] {
+			output.Responses[TasksTableName] = append(output.Responses[TasksTableName], map[string]*dynamodb.AttributeValue{
+				"job_id": keys[i]["job_id"],
+				"type":   keys[i]["type"],
+				"status": {S: aws.String(string(models.TaskStatusPending))},
+			})
+		}
+	}
+	return output, nil
+}
+
+func TestTaskRepository_GetTasksByJobIds(t *testing.T) {
+	many := make([]string, 30)
+	for i := range many {
+		many[i] = "job-" + strconv.Itoa(i)
+	}
+	taskTypes := models.TaskTypes()
+
+	testCases := []struct {
+		name              string
+		stored            []string
+		ids               []string
+		unprocessedRounds int
+		expectedJobs      []string
+		expectedRequests  []int
+	}{
+		{
+			name:   "Chunked",
+			stored: many,
+			ids:    many,
+			// Every job has a task of each type, so a batch of 100 keys covers 25 jobs
+			expectedJobs:     many,
+			expectedRequests: []int{100, 20},
+		},
+		{
+			name:             "MissingAndRepeated",
+			stored:           []string{"job-1", "job-3"},
+			ids:              []string{"job-3", "job-2", "job-1", "job-3"},
+			expectedJobs:     []string{"job-1", "job-3"},
+			expectedRequests: []int{12},
+		},
+		{
+			name:              "UnprocessedRetried",
+			stored:            []string{"job-1"},
+			ids:               []string{"job-1"},
+			unprocessedRounds: 1,
+			expectedJobs:      []string{"job-1"},
+			expectedRequests:  []int{4, 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := &taskBatchGetStub{jobs: make(map[string]bool), unprocessedRounds: tc.unprocessedRounds}
+			for _, id := range tc.stored {
+				stub.jobs[id] = true
+			}
+			repo := &TaskRepository{mc: NoOpMetricsCollector{}}
+			WithTaskDynamoDBClient(stub)(repo)
+
+			tasks, err := repo.GetTasksByJobIds(context.Background(), tc.ids)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedRequests, stub.requests)
+
+			require.Len(t, tasks, len(tc.expectedJobs))
+			for _, id := range tc.expectedJobs {
+				require.Len(t, tasks[id], len(taskTypes), id)
+				for i, task := range tasks[id] {
+					assert.Equal(t, id, task.JobID)
+					assert.Equal(t, taskTypes[i], task.Type, "tasks should be listed in the order they run")
+				}
+			}
+		})
+	}
+}
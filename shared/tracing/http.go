@@ -72,6 +72,11 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return rw.ResponseWriter.Write(b)
 }
 
+// Unwrap returns the underlying [http.ResponseWriter] for [http.ResponseController]
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // tracingRoundTripper implements http.RoundTripper with tracing
 type tracingRoundTripper struct {
 	next http.RoundTripper